@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net"
@@ -28,6 +30,9 @@ import (
 
 	"isc.org/stork"
 	agentapi "isc.org/stork/api"
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/pki"
+	storkutil "isc.org/stork/util"
 )
 
 // Global Stork Agent state.
@@ -36,10 +41,24 @@ type StorkAgent struct {
 	AppMonitor     AppMonitor
 	HTTPClient     *HTTPClient // to communicate with Kea Control Agent and named statistics-channel
 	server         *grpc.Server
+	tlsIdentity    *tlsIdentity
 	logTailer      *logTailer
 	keaInterceptor *keaInterceptor
 	shutdownOnce   sync.Once
 	hookManager    *HookManager
+	inventory      *inventoryCollector
+	// Outbox with the Kea statistics sampled by the agent. It is nil if
+	// sampling the statistics is disabled.
+	KeaStatsOutbox *KeaStatsOutbox
+	// Private key generated for the certificate renewal. It is used
+	// when the server returns the signed certificate.
+	pendingCertKey   []byte
+	certRenewalMutex sync.Mutex
+	// Metrics describing the agent's own operation.
+	Metrics *SelfMetrics
+	// Time when the agent was created. It is used to check the contact
+	// with the server before the server connects for the first time.
+	startedAt time.Time
 
 	agentapi.UnimplementedAgentServer
 }
@@ -55,6 +74,9 @@ func NewStorkAgent(settings *cli.Context, appMonitor AppMonitor, httpClient *HTT
 		logTailer:      logTailer,
 		keaInterceptor: newKeaInterceptor(),
 		hookManager:    hookManager,
+		inventory:      newInventoryCollector(storkutil.NewSystemCommandExecutor()),
+		Metrics:        NewSelfMetrics(),
+		startedAt:      time.Now(),
 	}
 
 	registerKeaInterceptFns(sa)
@@ -62,57 +84,132 @@ func NewStorkAgent(settings *cli.Context, appMonitor AppMonitor, httpClient *HTT
 	return sa
 }
 
-// Creates the GRPC server callback using the provided cert store. The callback
-// returns the root CA on demand.
-func createGetRootCertificatesHandler(certStore *CertStore) func(*advancedtls.GetRootCAsParams) (*advancedtls.GetRootCAsResults, error) {
-	// Read the latest root CA cert from file for Stork Server's cert verification.
+// The TLS identity of the agent presented to the Stork Server and the root
+// CA used to verify the server. The certificates are read from the cert
+// store on first use and kept in memory. They are replaced only after the
+// new certificates are successfully read, so the connections are not
+// affected by partially written cert files.
+type tlsIdentity struct {
+	certStore *CertStore
+	mutex     *sync.RWMutex
+	cert      *tls.Certificate
+	rootCAs   *x509.CertPool
+}
+
+// Creates the TLS identity backed by the cert store.
+func newTLSIdentity(certStore *CertStore) *tlsIdentity {
+	return &tlsIdentity{
+		certStore: certStore,
+		mutex:     &sync.RWMutex{},
+	}
+}
+
+// Returns the agent's certificate. It is read from the cert store if it
+// hasn't been read yet.
+func (i *tlsIdentity) getCert() (*tls.Certificate, error) {
+	i.mutex.RLock()
+	cert := i.cert
+	i.mutex.RUnlock()
+	if cert != nil {
+		return cert, nil
+	}
+	cert, err := i.certStore.ReadTLSCert()
+	if err != nil {
+		return nil, err
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.cert = cert
+	return cert, nil
+}
+
+// Returns the root CA. It is read from the cert store if it hasn't been
+// read yet.
+func (i *tlsIdentity) getRootCAs() (*x509.CertPool, error) {
+	i.mutex.RLock()
+	rootCAs := i.rootCAs
+	i.mutex.RUnlock()
+	if rootCAs != nil {
+		return rootCAs, nil
+	}
+	rootCAs, err := i.certStore.ReadRootCA()
+	if err != nil {
+		return nil, err
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.rootCAs = rootCAs
+	return rootCAs, nil
+}
+
+// Reads the certificates from the cert store again and swaps them for the
+// new connections. The current certificates are preserved if any of the
+// new ones is invalid.
+func (i *tlsIdentity) reload() error {
+	cert, err := i.certStore.ReadTLSCert()
+	if err != nil {
+		return err
+	}
+	rootCAs, err := i.certStore.ReadRootCA()
+	if err != nil {
+		return err
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.cert = cert
+	i.rootCAs = rootCAs
+	return nil
+}
+
+// Creates the GRPC server callback using the provided TLS identity. The
+// callback returns the root CA on demand.
+func createGetRootCertificatesHandler(identity *tlsIdentity) func(*advancedtls.GetRootCAsParams) (*advancedtls.GetRootCAsResults, error) {
+	// Return the latest root CA cert for Stork Server's cert verification.
 	return func(params *advancedtls.GetRootCAsParams) (*advancedtls.GetRootCAsResults, error) {
-		certPool, err := certStore.ReadRootCA()
+		certPool, err := identity.getRootCAs()
 		if err != nil {
 			log.WithError(err).Error("Cannot extract root CA")
 			return nil, err
 		}
-		log.Info("Loaded CA cert")
 		return &advancedtls.GetRootCAsResults{
 			TrustCerts: certPool,
 		}, nil
 	}
 }
 
-// Creates the GRPC server callback using the provided cert store. The callback
-// returns the TLS certificate.
-func createGetIdentityCertificatesForServerHandler(certStore *CertStore) func(chi *tls.ClientHelloInfo) ([]*tls.Certificate, error) {
-	// Read the latest Stork Agent's cert from file for presenting its identity to the Stork server.
+// Creates the GRPC server callback using the provided TLS identity. The
+// callback returns the TLS certificate.
+func createGetIdentityCertificatesForServerHandler(identity *tlsIdentity) func(chi *tls.ClientHelloInfo) ([]*tls.Certificate, error) {
+	// Return the latest Stork Agent's cert for presenting its identity to the Stork server.
 	return func(chi *tls.ClientHelloInfo) ([]*tls.Certificate, error) {
-		certificate, err := certStore.ReadTLSCert()
+		certificate, err := identity.getCert()
 		if err != nil {
 			log.WithError(err).Error("Could not setup TLS key pair")
 			return nil, err
 		}
-		log.Info("Loaded server cert")
 		return []*tls.Certificate{certificate}, nil
 	}
 }
 
-// Prepare gRPC server with configured TLS.
-func newGRPCServerWithTLS() (*grpc.Server, error) {
+// Prepare gRPC server with configured TLS. The additional options, e.g.,
+// the interceptors, are appended to the default ones.
+func newGRPCServerWithTLS(identity *tlsIdentity, serverOptions ...grpc.ServerOption) (*grpc.Server, error) {
 	// Prepare structure for advanced TLS. It defines hook functions
-	// that dynamically load key and cert from files just before establishing
-	// connection. Thanks to this if these files changed in meantime then
-	// always latest version for new connections is used.
+	// that return the key and cert just before establishing connection.
+	// The TLS identity can be reloaded when the cert files change. Thanks
+	// to this always latest version for new connections is used.
 	// Beside that there is enabled client authentication and forced
 	// cert and host verification.
-	certStore := NewCertStoreDefault()
 	options := &advancedtls.ServerOptions{
 		// Pull latest root CA cert for stork server cert verification.
 		RootOptions: advancedtls.RootCertificateOptions{
 			// Read the latest root CA cert from file for Stork Server's cert verification.
-			GetRootCertificates: createGetRootCertificatesHandler(certStore),
+			GetRootCertificates: createGetRootCertificatesHandler(identity),
 		},
 		// Pull latest stork agent cert for presenting its identity to stork server.
 		IdentityOptions: advancedtls.IdentityCertificateOptions{
 			// Read the latest Stork Agent's cert from file for presenting its identity to the Stork server.
-			GetIdentityCertificatesForServer: createGetIdentityCertificatesForServerHandler(certStore),
+			GetIdentityCertificatesForServer: createGetIdentityCertificatesForServerHandler(identity),
 		},
 		// Force stork server cert verification.
 		RequireClientCert: true,
@@ -125,17 +222,34 @@ func newGRPCServerWithTLS() (*grpc.Server, error) {
 	}
 
 	timeoutOption := grpc.ConnectionTimeout(30 * time.Second)
-	srv := grpc.NewServer(grpc.Creds(creds), timeoutOption)
+	serverOptions = append([]grpc.ServerOption{grpc.Creds(creds), timeoutOption}, serverOptions...)
+	srv := grpc.NewServer(serverOptions...)
 	return srv, nil
 }
 
 // Setup the agent as gRPC server endpoint.
 func (sa *StorkAgent) Setup() error {
-	server, err := newGRPCServerWithTLS()
+	identity := newTLSIdentity(NewCertStoreDefault())
+	server, err := newGRPCServerWithTLS(identity, sa.Metrics.grpcServerOptions()...)
 	if err != nil {
 		return err
 	}
 	sa.server = server
+	sa.tlsIdentity = identity
+	return nil
+}
+
+// Reads the agent's certificates again and uses them for the new
+// connections from the Stork Server. The established connections are
+// not affected.
+func (sa *StorkAgent) ReloadTLSIdentity() error {
+	if sa.tlsIdentity == nil {
+		return nil
+	}
+	if err := sa.tlsIdentity.reload(); err != nil {
+		return errors.WithMessage(err, "could not reload the TLS certificates")
+	}
+	log.Info("Reloaded the TLS certificates")
 	return nil
 }
 
@@ -195,9 +309,70 @@ func (sa *StorkAgent) GetState(ctx context.Context, in *agentapi.GetStateReq) (*
 		AgentUsesHTTPCredentials: sa.HTTPClient.HasAuthenticationCredentials(),
 	}
 
+	if sa.inventory != nil {
+		state.Inventory = inventoryToAgentAPI(sa.inventory.collect())
+	}
+
+	// The server uses the certificate to check when it must be renewed.
+	if sa.tlsIdentity != nil {
+		if certPEM, err := sa.tlsIdentity.certStore.readCert(); err == nil {
+			state.AgentCertificate = string(certPEM)
+		}
+	}
+
 	return &state, nil
 }
 
+// Converts the inventory of the daemons to the format used in gRPC.
+func inventoryToAgentAPI(inventory []*daemonInventory) []*agentapi.DaemonInventory {
+	var converted []*agentapi.DaemonInventory
+	for _, daemon := range inventory {
+		var files []*agentapi.FileInventory
+		for _, file := range daemon.Files {
+			files = append(files, &agentapi.FileInventory{
+				Path:  file.Path,
+				Mode:  file.Mode,
+				Owner: file.Owner,
+				Group: file.Group,
+				Error: file.Error,
+			})
+		}
+		converted = append(converted, &agentapi.DaemonInventory{
+			Name:             daemon.Name,
+			Pid:              daemon.Pid,
+			Executable:       daemon.Executable,
+			Package:          daemon.Package,
+			SystemdUnit:      daemon.SystemdUnit,
+			SystemdUnitState: daemon.SystemdUnitState,
+			ListeningSockets: daemon.ListeningSockets,
+			Files:            files,
+		})
+	}
+	return converted
+}
+
+// Returns the configuration of the BIND 9 app with the specified control
+// access point. The configuration is the one parsed during the app
+// detection, so it includes the contents of the included files.
+func (sa *StorkAgent) GetBind9Config(ctx context.Context, in *agentapi.GetBind9ConfigReq) (*agentapi.GetBind9ConfigRsp, error) {
+	response := &agentapi.GetBind9ConfigRsp{
+		Status: &agentapi.Status{
+			Code: agentapi.Status_OK,
+		},
+	}
+
+	app := sa.AppMonitor.GetApp(AppTypeBind9, AccessPointControl, in.ControlAddress, in.ControlPort)
+	bind9App, ok := app.(*Bind9App)
+	if !ok || bind9App.config == nil {
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = "Cannot find BIND 9 app"
+		return response, nil
+	}
+
+	response.Config = bind9App.config.String()
+	return response, nil
+}
+
 // ForwardRndcCommand forwards one rndc command sent by the Stork Server to
 // the named daemon.
 func (sa *StorkAgent) ForwardRndcCommand(ctx context.Context, in *agentapi.ForwardRndcCommandReq) (*agentapi.ForwardRndcCommandRsp, error) {
@@ -297,7 +472,10 @@ func (sa *StorkAgent) ForwardToNamedStats(ctx context.Context, in *agentapi.Forw
 // HTTP (via Control Agent).
 func (sa *StorkAgent) ForwardToKeaOverHTTP(ctx context.Context, in *agentapi.ForwardToKeaOverHTTPReq) (*agentapi.ForwardToKeaOverHTTPRsp, error) {
 	// Call hook
-	if err := sa.hookManager.OnBeforeForwardToKeaOverHTTP(ctx, in); err != nil {
+	hookStarted := time.Now()
+	err := sa.hookManager.OnBeforeForwardToKeaOverHTTP(ctx, in)
+	sa.Metrics.observeHookCall("OnBeforeForwardToKeaOverHTTP", hookStarted)
+	if err != nil {
 		return nil, err
 	}
 
@@ -316,36 +494,105 @@ func (sa *StorkAgent) ForwardToKeaOverHTTP(ctx context.Context, in *agentapi.For
 		return response, nil
 	}
 
-	requests := in.GetKeaRequests()
+	response.KeaResponses = sa.forwardKeaRequests(in.GetKeaRequests(), reqURL, func(request []byte) ([]byte, error) {
+		keaRsp, err := sa.HTTPClient.Call(reqURL, bytes.NewBuffer(request))
+		if err != nil {
+			return nil, err
+		}
+		// Read the response body.
+		body, err := io.ReadAll(keaRsp.Body)
+		keaRsp.Body.Close()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read the body of the Kea response")
+		}
+		return body, nil
+	})
+
+	return response, nil
+}
+
+// Forwards one or more Kea commands sent by the Stork Server to the Kea
+// instance identified by the access point. The commands are sent over HTTP
+// if the access point belongs to the Kea Control Agent. They are sent
+// directly to the Kea daemon if the access point is the daemon's control
+// socket.
+func (sa *StorkAgent) ForwardToKea(ctx context.Context, in *agentapi.ForwardToKeaReq) (*agentapi.ForwardToKeaRsp, error) {
+	response := &agentapi.ForwardToKeaRsp{
+		Status: &agentapi.Status{
+			Code: agentapi.Status_OK, // all ok
+		},
+	}
+
+	ap := in.GetAccessPoint()
+	if ap == nil {
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = "Missing Kea access point"
+		return response, nil
+	}
+
+	switch ap.Type {
+	case AccessPointControl:
+		// Use the same path as the commands sent over HTTP, so the callouts
+		// are invoked for them.
+		httpRsp, err := sa.ForwardToKeaOverHTTP(ctx, &agentapi.ForwardToKeaOverHTTPReq{
+			Url:         storkutil.HostWithPortURL(ap.Address, ap.Port, ap.UseSecureProtocol),
+			KeaRequests: in.GetKeaRequests(),
+		})
+		if err != nil {
+			return nil, err
+		}
+		response.Status = httpRsp.Status
+		response.KeaResponses = httpRsp.KeaResponses
+	case AccessPointControlSocket:
+		app := sa.AppMonitor.GetApp(AppTypeKea, AccessPointControlSocket, ap.Address, 0)
+		if app == nil {
+			response.Status.Code = agentapi.Status_ERROR
+			response.Status.Message = fmt.Sprintf("Cannot find Kea daemon with the control socket %s", ap.Address)
+			return response, nil
+		}
+		socketClient := NewUnixSocketClient()
+		response.KeaResponses = sa.forwardKeaRequests(in.GetKeaRequests(), ap.Address, func(request []byte) ([]byte, error) {
+			return socketClient.Call(ap.Address, request)
+		})
+	default:
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = fmt.Sprintf("Unsupported Kea access point type: %s", ap.Type)
+	}
+
+	return response, nil
+}
 
+// Sends the Kea requests one by one using the specified function and
+// gathers the responses. The target is the URL to the Kea CA or the path
+// to the daemon's control socket. It is only used for logging.
+func (sa *StorkAgent) forwardKeaRequests(requests []*agentapi.KeaRequest, target string, send func(request []byte) ([]byte, error)) (responses []*agentapi.KeaResponse) {
 	// forward requests to kea one by one
 	for _, req := range requests {
 		rsp := &agentapi.KeaResponse{
 			Status: &agentapi.Status{},
 		}
-		// Try to forward the command to Kea Control Agent.
-		keaRsp, err := sa.HTTPClient.Call(reqURL, bytes.NewBuffer([]byte(req.Request)))
+		// Try to forward the command to Kea.
+		body, err := send([]byte(req.Request))
 		if err != nil {
 			log.WithFields(log.Fields{
-				"URL": reqURL,
-			}).Errorf("Failed to forward commands to Kea CA: %+v", err)
+				"target": target,
+			}).Errorf("Failed to forward commands to Kea: %+v", err)
+			sa.Metrics.countKeaError(keaErrorReasonCommunication)
 			rsp.Status.Code = agentapi.Status_ERROR
 			rsp.Status.Message = fmt.Sprintf("Failed to forward commands to Kea: %s", err.Error())
-			response.KeaResponses = append(response.KeaResponses, rsp)
+			responses = append(responses, rsp)
 			continue
 		}
 
-		// Read the response body.
-		body, err := io.ReadAll(keaRsp.Body)
-		keaRsp.Body.Close()
-		if err != nil {
-			log.WithFields(log.Fields{
-				"URL": reqURL,
-			}).Errorf("Failed to read the body of the Kea response to forwarded commands: %+v", err)
-			rsp.Status.Code = agentapi.Status_ERROR
-			rsp.Status.Message = fmt.Sprintf("Failed to read the body of the Kea response: %s", err.Error())
-			response.KeaResponses = append(response.KeaResponses, rsp)
-			continue
+		// Count the error results returned by Kea. The response may be
+		// malformed; it is reported to the server as is then.
+		keaResponses := keactrl.ResponseList{}
+		if json.Unmarshal(body, &keaResponses) == nil {
+			for _, keaResponse := range keaResponses {
+				if keaResponse.Result == keactrl.ResponseError {
+					sa.Metrics.countKeaError(keaErrorReasonCommand)
+				}
+			}
 		}
 
 		// Push Kea response for synchronous processing. It may modify the
@@ -353,7 +600,7 @@ func (sa *StorkAgent) ForwardToKeaOverHTTP(ctx context.Context, in *agentapi.For
 		body, err = sa.keaInterceptor.syncHandle(sa, req, body)
 		if err != nil {
 			log.WithFields(log.Fields{
-				"URL": reqURL,
+				"target": target,
 			}).Errorf("Failed to apply synchronous interceptors on Kea response: %+v", err)
 			continue
 		}
@@ -365,10 +612,9 @@ func (sa *StorkAgent) ForwardToKeaOverHTTP(ctx context.Context, in *agentapi.For
 
 		rsp.Response = body
 		rsp.Status.Code = agentapi.Status_OK
-		response.KeaResponses = append(response.KeaResponses, rsp)
+		responses = append(responses, rsp)
 	}
-
-	return response, nil
+	return responses
 }
 
 // Returns the tail of the specified file, typically a log file.
@@ -379,6 +625,7 @@ func (sa *StorkAgent) TailTextFile(ctx context.Context, in *agentapi.TailTextFil
 		},
 	}
 
+	sa.Metrics.countLogTailRequest("tail")
 	lines, err := sa.logTailer.tail(in.Path, in.Offset)
 	if err != nil {
 		response.Status.Code = agentapi.Status_ERROR
@@ -390,6 +637,153 @@ func (sa *StorkAgent) TailTextFile(ctx context.Context, in *agentapi.TailTextFil
 	return response, nil
 }
 
+// Follows the specified text file and streams the lines appended to it
+// until the server cancels the call. The lines are filtered using the
+// optional pattern and severity. An error is sent in the response status
+// when the file cannot be followed.
+func (sa *StorkAgent) FollowTextFile(in *agentapi.FollowTextFileReq, stream agentapi.Agent_FollowTextFileServer) error {
+	sa.Metrics.countLogTailRequest("follow")
+	filter, err := newLogFilter(in.Pattern, in.Severity)
+	if err == nil {
+		sa.Metrics.addLogFollowers(1)
+		defer sa.Metrics.addLogFollowers(-1)
+		err = sa.logTailer.follow(stream.Context(), in.Path, in.Offset, filter, func(lines []string) error {
+			return stream.Send(&agentapi.FollowTextFileRsp{
+				Status: &agentapi.Status{
+					Code: agentapi.Status_OK,
+				},
+				Lines: lines,
+			})
+		})
+	}
+	if err != nil {
+		log.WithError(err).WithField("file", in.Path).Warn("Failed to follow the text file")
+		return stream.Send(&agentapi.FollowTextFileRsp{
+			Status: &agentapi.Status{
+				Code:    agentapi.Status_ERROR,
+				Message: fmt.Sprintf("%s", err),
+			},
+		})
+	}
+	return nil
+}
+
+// Returns the Kea statistics samples stored in the outbox for the Kea app
+// with the specified control access point. The samples following the
+// specified sequence number are returned.
+func (sa *StorkAgent) GetKeaStatsSamples(ctx context.Context, in *agentapi.GetKeaStatsSamplesReq) (*agentapi.GetKeaStatsSamplesRsp, error) {
+	response := &agentapi.GetKeaStatsSamplesRsp{
+		Status: &agentapi.Status{
+			Code: agentapi.Status_OK, // all ok
+		},
+	}
+
+	if sa.KeaStatsOutbox == nil {
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = "Kea statistics outbox is disabled"
+		return response, nil
+	}
+
+	samples, lastSequence, err := sa.KeaStatsOutbox.getSamples(in.Address, in.Port, in.SinceSequence, int(in.Limit))
+	if err != nil {
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = fmt.Sprintf("%s", err)
+		return response, nil
+	}
+
+	for _, sample := range samples {
+		statistics, err := json.Marshal(sample.Statistics)
+		if err != nil {
+			response.Status.Code = agentapi.Status_ERROR
+			response.Status.Message = fmt.Sprintf("%s", err)
+			return response, nil
+		}
+		response.Samples = append(response.Samples, &agentapi.KeaStatsSample{
+			Sequence:   sample.Sequence,
+			SampledAt:  sample.SampledAt.Unix(),
+			Daemon:     sample.Daemon,
+			Statistics: string(statistics),
+		})
+	}
+	response.LastSequence = lastSequence
+
+	return response, nil
+}
+
+// Generates a new private key and returns the CSR for renewing the agent
+// certificate. The key is kept in memory until the signed certificate is
+// installed. The current key and certificate remain in use, so the
+// renewal can be safely abandoned by the server.
+func (sa *StorkAgent) RenewCertificate(ctx context.Context, in *agentapi.RenewCertificateReq) (*agentapi.RenewCertificateRsp, error) {
+	response := &agentapi.RenewCertificateRsp{
+		Status: &agentapi.Status{
+			Code: agentapi.Status_OK, // all ok
+		},
+	}
+
+	if sa.tlsIdentity == nil {
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = "agent is not set up"
+		return response, nil
+	}
+
+	keyPEM, err := pki.GenKey()
+	if err == nil {
+		var csrPEM []byte
+		csrPEM, err = sa.tlsIdentity.certStore.GenerateRenewalCSR(keyPEM)
+		response.Csr = string(csrPEM)
+	}
+	if err != nil {
+		log.WithError(err).Error("Cannot prepare the certificate renewal")
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = fmt.Sprintf("%s", err)
+		return response, nil
+	}
+
+	sa.certRenewalMutex.Lock()
+	defer sa.certRenewalMutex.Unlock()
+	sa.pendingCertKey = keyPEM
+
+	log.Info("Generated new private key for the certificate renewal")
+	return response, nil
+}
+
+// Installs the agent certificate renewed by the server along with the
+// server CA certificates. The certificate must have been signed for the
+// CSR returned by the last RenewCertificate call. The new certificates
+// are used for the subsequent connections from the server.
+func (sa *StorkAgent) InstallCertificate(ctx context.Context, in *agentapi.InstallCertificateReq) (*agentapi.InstallCertificateRsp, error) {
+	response := &agentapi.InstallCertificateRsp{
+		Status: &agentapi.Status{
+			Code: agentapi.Status_OK, // all ok
+		},
+	}
+
+	sa.certRenewalMutex.Lock()
+	defer sa.certRenewalMutex.Unlock()
+
+	if sa.tlsIdentity == nil || sa.pendingCertKey == nil {
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = "certificate renewal has not been requested"
+		return response, nil
+	}
+
+	err := sa.tlsIdentity.certStore.InstallRenewedCert(sa.pendingCertKey, []byte(in.Certificate), []byte(in.CaCertificates))
+	if err == nil {
+		sa.pendingCertKey = nil
+		err = sa.ReloadTLSIdentity()
+	}
+	if err != nil {
+		log.WithError(err).Error("Cannot install the renewed certificate")
+		response.Status.Code = agentapi.Status_ERROR
+		response.Status.Message = fmt.Sprintf("%s", err)
+		return response, nil
+	}
+
+	log.Info("Installed the renewed certificate")
+	return response, nil
+}
+
 // Starts the gRPC and HTTP listeners.
 func (sa *StorkAgent) Serve() error {
 	// Install gRPC API handlers.
@@ -19,7 +19,9 @@ import (
 
 	"isc.org/stork"
 	agentapi "isc.org/stork/api"
+	bind9config "isc.org/stork/appcfg/bind9"
 	"isc.org/stork/hooks"
+	"isc.org/stork/pki"
 	"isc.org/stork/testutil"
 )
 
@@ -577,6 +579,43 @@ func TestForwardRndcCommandEmpty(t *testing.T) {
 	require.Empty(t, rsp.Status.Message)
 }
 
+// Test that the BIND 9 configuration parsed during the app detection is
+// returned.
+func TestGetBind9Config(t *testing.T) {
+	sa, ctx := setupAgentTest()
+
+	config, err := bind9config.Parse(`controls { inet 127.0.0.1 port 1234 allow { localhost; }; };`)
+	require.NoError(t, err)
+
+	fam, _ := sa.AppMonitor.(*FakeAppMonitor)
+	fam.Apps = []App{
+		&Bind9App{
+			BaseApp: BaseApp{
+				Type:         AppTypeBind9,
+				AccessPoints: makeAccessPoint(AccessPointControl, "127.0.0.1", "_", 1234, false),
+			},
+			config: config,
+		},
+	}
+
+	rsp, err := sa.GetBind9Config(ctx, &agentapi.GetBind9ConfigReq{
+		ControlAddress: "127.0.0.1",
+		ControlPort:    1234,
+	})
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_OK, rsp.Status.Code)
+	require.Equal(t, config.String(), rsp.Config)
+
+	// Unknown app.
+	rsp, err = sa.GetBind9Config(ctx, &agentapi.GetBind9ConfigReq{
+		ControlAddress: "127.0.0.1",
+		ControlPort:    1235,
+	})
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_ERROR, rsp.Status.Code)
+	require.Empty(t, rsp.Config)
+}
+
 // Test that the tail of the text file can be fetched.
 func TestTailTextFile(t *testing.T) {
 	sa, ctx := setupAgentTest()
@@ -638,7 +677,7 @@ func TestGetRootCertificatesForMissingOrInvalidFiles(t *testing.T) {
 
 	// Missing cert file error.
 	certStore := NewCertStoreDefault()
-	getRootCertificates := createGetRootCertificatesHandler(certStore)
+	getRootCertificates := createGetRootCertificatesHandler(newTLSIdentity(certStore))
 	_, err = getRootCertificates(params)
 	require.ErrorContains(t, err, "could not read the root CA")
 	require.ErrorContains(t, err, fmt.Sprintf("open %s/certs/ca.pem: no such file or directory", tmpDir))
@@ -658,7 +697,7 @@ func TestGetRootCertificates(t *testing.T) {
 
 	// All should be ok.
 	certStore := NewCertStoreDefault()
-	getRootCertificates := createGetRootCertificatesHandler(certStore)
+	getRootCertificates := createGetRootCertificatesHandler(newTLSIdentity(certStore))
 	params := &advancedtls.GetRootCAsParams{}
 	result, err := getRootCertificates(params)
 	require.NoError(t, err)
@@ -685,7 +724,7 @@ func TestGetIdentityCertificatesForServerForMissingOrInvalid(t *testing.T) {
 
 	// Missing key files.
 	certStore := NewCertStoreDefault()
-	getIdentityCertificatesForServer := createGetIdentityCertificatesForServerHandler(certStore)
+	getIdentityCertificatesForServer := createGetIdentityCertificatesForServerHandler(newTLSIdentity(certStore))
 	_, err = getIdentityCertificatesForServer(info)
 	require.ErrorContains(t, err, "could not read the private key")
 	require.ErrorContains(t, err, fmt.Sprintf("open %s/certs/key.pem: no such file or directory", tmpDir))
@@ -708,16 +747,65 @@ func TestGetIdentityCertificatesForServer(t *testing.T) {
 
 	// Now it should work.
 	certStore := NewCertStoreDefault()
-	getIdentityCertificatesForServer := createGetIdentityCertificatesForServerHandler(certStore)
+	getIdentityCertificatesForServer := createGetIdentityCertificatesForServerHandler(newTLSIdentity(certStore))
 	info := &tls.ClientHelloInfo{}
 	certs, err := getIdentityCertificatesForServer(info)
 	require.NoError(t, err)
 	require.NotEmpty(t, certs)
 }
 
+// Checks if the TLS identity keeps the certificates in memory and replaces
+// them only after successful reload.
+func TestTLSIdentityReload(t *testing.T) {
+	cleanup, err := GenerateSelfSignedCerts()
+	require.NoError(t, err)
+	defer cleanup()
+
+	identity := newTLSIdentity(NewCertStoreDefault())
+	cert, err := identity.getCert()
+	require.NoError(t, err)
+	require.NotNil(t, cert)
+	rootCAs, err := identity.getRootCAs()
+	require.NoError(t, err)
+	require.NotNil(t, rootCAs)
+
+	// The cached certificate is returned even if the file is broken.
+	certContent, err := os.ReadFile(CertPEMFile)
+	require.NoError(t, err)
+	err = os.WriteFile(CertPEMFile, []byte("CertPEMFile"), 0o600)
+	require.NoError(t, err)
+	returnedCert, err := identity.getCert()
+	require.NoError(t, err)
+	require.Same(t, cert, returnedCert)
+
+	// The reload fails and the previous certificate is preserved.
+	err = identity.reload()
+	require.Error(t, err)
+	returnedCert, err = identity.getCert()
+	require.NoError(t, err)
+	require.Same(t, cert, returnedCert)
+
+	// The valid certificate is swapped.
+	err = os.WriteFile(CertPEMFile, certContent, 0o600)
+	require.NoError(t, err)
+	err = identity.reload()
+	require.NoError(t, err)
+	returnedCert, err = identity.getCert()
+	require.NoError(t, err)
+	require.NotSame(t, cert, returnedCert)
+	require.Equal(t, cert.Certificate, returnedCert.Certificate)
+}
+
+// Checks if reloading the TLS identity of the agent that hasn't been set up
+// is a no-op.
+func TestReloadTLSIdentityNotSetup(t *testing.T) {
+	sa := &StorkAgent{}
+	require.NoError(t, sa.ReloadTLSIdentity())
+}
+
 // Check if newGRPCServerWithTLS can create gRPC server.
 func TestNewGRPCServerWithTLS(t *testing.T) {
-	srv, err := newGRPCServerWithTLS()
+	srv, err := newGRPCServerWithTLS(newTLSIdentity(NewCertStoreDefault()))
 	require.NoError(t, err)
 	require.NotNil(t, srv)
 }
@@ -750,3 +838,117 @@ func TestHostAndPortParams(t *testing.T) {
 	require.Contains(t, stdoutStr, "127.0.0.1")
 	require.Contains(t, stdoutStr, "9876")
 }
+
+// Test that the agent certificate is renewed and used for the subsequent
+// connections.
+func TestRenewAndInstallCertificate(t *testing.T) {
+	cleanup, err := GenerateSelfSignedCerts()
+	require.NoError(t, err)
+	defer cleanup()
+	sa, ctx := setupAgentTest()
+
+	oldCert, err := sa.tlsIdentity.getCert()
+	require.NoError(t, err)
+
+	renewRsp, err := sa.RenewCertificate(ctx, &agentapi.RenewCertificateReq{})
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_OK, renewRsp.Status.Code)
+	require.NotEmpty(t, renewRsp.Csr)
+
+	// The current certificate is in use until the new one is installed.
+	state, err := sa.GetState(ctx, &agentapi.GetStateReq{})
+	require.NoError(t, err)
+	certPEM, err := os.ReadFile(CertPEMFile)
+	require.NoError(t, err)
+	require.EqualValues(t, certPEM, state.AgentCertificate)
+
+	_, caKeyPEM, _, caCertPEM, err := pki.GenCAKeyCert(1)
+	require.NoError(t, err)
+	newCertPEM, _, paramsErr, innerErr := pki.SignCert([]byte(renewRsp.Csr), 2, caCertPEM, caKeyPEM)
+	require.NoError(t, paramsErr)
+	require.NoError(t, innerErr)
+
+	installRsp, err := sa.InstallCertificate(ctx, &agentapi.InstallCertificateReq{
+		Certificate:    string(newCertPEM),
+		CaCertificates: string(caCertPEM),
+	})
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_OK, installRsp.Status.Code)
+
+	newCert, err := sa.tlsIdentity.getCert()
+	require.NoError(t, err)
+	require.NotEqual(t, oldCert.Certificate, newCert.Certificate)
+
+	// The key is used only once.
+	installRsp, err = sa.InstallCertificate(ctx, &agentapi.InstallCertificateReq{
+		Certificate:    string(newCertPEM),
+		CaCertificates: string(caCertPEM),
+	})
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_ERROR, installRsp.Status.Code)
+}
+
+// Test that the certificate cannot be installed without requesting the
+// renewal first.
+func TestInstallCertificateNotRequested(t *testing.T) {
+	cleanup, err := GenerateSelfSignedCerts()
+	require.NoError(t, err)
+	defer cleanup()
+	sa, ctx := setupAgentTest()
+
+	certPEM, err := os.ReadFile(CertPEMFile)
+	require.NoError(t, err)
+	caCertPEM, err := os.ReadFile(RootCAFile)
+	require.NoError(t, err)
+
+	rsp, err := sa.InstallCertificate(ctx, &agentapi.InstallCertificateReq{
+		Certificate:    string(certPEM),
+		CaCertificates: string(caCertPEM),
+	})
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_ERROR, rsp.Status.Code)
+}
+
+// Test that the certificate renewal fails for the agent that hasn't been
+// set up.
+func TestRenewCertificateNotSetup(t *testing.T) {
+	sa := &StorkAgent{}
+	rsp, err := sa.RenewCertificate(context.Background(), &agentapi.RenewCertificateReq{})
+	require.NoError(t, err)
+	require.Equal(t, agentapi.Status_ERROR, rsp.Status.Code)
+}
+
+// Test that the inventory of the daemons is converted to the gRPC format.
+func TestInventoryToAgentAPI(t *testing.T) {
+	inventory := inventoryToAgentAPI([]*daemonInventory{
+		{
+			Name:             "kea-dhcp4",
+			Pid:              1234,
+			Executable:       "/usr/sbin/kea-dhcp4",
+			Package:          "isc-kea-dhcp4-server",
+			SystemdUnit:      "isc-kea-dhcp4-server.service",
+			SystemdUnitState: "enabled",
+			ListeningSockets: []string{"udp 0.0.0.0:67"},
+			Files: []fileInventory{
+				{
+					Path:  "/etc/kea/kea-dhcp4.conf",
+					Mode:  "-rw-r-----",
+					Owner: "root",
+					Group: "_kea",
+				},
+			},
+		},
+	})
+
+	require.Len(t, inventory, 1)
+	require.Equal(t, "kea-dhcp4", inventory[0].Name)
+	require.EqualValues(t, 1234, inventory[0].Pid)
+	require.Equal(t, "/usr/sbin/kea-dhcp4", inventory[0].Executable)
+	require.Equal(t, "isc-kea-dhcp4-server", inventory[0].Package)
+	require.Equal(t, "isc-kea-dhcp4-server.service", inventory[0].SystemdUnit)
+	require.Equal(t, "enabled", inventory[0].SystemdUnitState)
+	require.Equal(t, []string{"udp 0.0.0.0:67"}, inventory[0].ListeningSockets)
+	require.Len(t, inventory[0].Files, 1)
+	require.Equal(t, "-rw-r-----", inventory[0].Files[0].Mode)
+	require.Equal(t, "_kea", inventory[0].Files[0].Group)
+}
@@ -7,11 +7,11 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
-	"strconv"
 	"strings"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	bind9config "isc.org/stork/appcfg/bind9"
 	storkutil "isc.org/stork/util"
 )
 
@@ -46,6 +46,8 @@ func (k *Bind9RndcKey) String() string {
 type Bind9App struct {
 	BaseApp
 	RndcClient *RndcClient // to communicate with BIND 9 via rndc
+	// Configuration parsed during the app detection.
+	config *bind9config.Config
 }
 
 // Get base information about BIND 9 app.
@@ -138,144 +140,48 @@ func (rc *RndcClient) SendCommand(command []string) (output []byte, err error) {
 	return rc.execute(rndcCommand)
 }
 
-// getRndcKey looks for the key with a given `name` in `contents`.
-//
-// Example key clause:
-//
-//	key "name" {
-//		algorithm "hmac-sha256";
-//		secret "OmItW1lOyLVUEuvv+Fme+Q==";
-//	};
-func getRndcKey(contents, name string) (controlKey *Bind9RndcKey) {
-	pattern := regexp.MustCompile(`(?s)key\s+\"(\S+)\"\s+\{(.*?)\}\s*;`)
-	keys := pattern.FindAllStringSubmatch(contents, -1)
-	if len(keys) == 0 {
+// getRndcKey looks for the key with a given `name` in the configuration.
+// It returns nil if the key doesn't exist or lacks the algorithm or the
+// secret.
+func getRndcKey(config *bind9config.Config, name string) *Bind9RndcKey {
+	key := config.GetKey(name)
+	if key == nil {
 		return nil
 	}
-
-	for _, key := range keys {
-		if key[1] != name {
-			continue
-		}
-		pattern = regexp.MustCompile(`(?s)algorithm\s+\"(\S+)\";`)
-		algorithm := pattern.FindStringSubmatch(key[2])
-		if len(algorithm) < 2 {
-			log.Warnf("No key algorithm found for name %s", name)
-			return nil
-		}
-
-		pattern = regexp.MustCompile(`(?s)secret\s+\"(\S+)\";`)
-		secret := pattern.FindStringSubmatch(key[2])
-		if len(secret) < 2 {
-			log.Warnf("No key secret found for name %s", name)
-			return nil
-		}
-
-		// this key clause matches the name we are looking for
-		controlKey = &Bind9RndcKey{
-			Name:      name,
-			Algorithm: algorithm[1],
-			Secret:    secret[1],
-		}
-		break
+	return &Bind9RndcKey{
+		Name:      key.Name,
+		Algorithm: key.Algorithm,
+		Secret:    key.Secret,
 	}
-
-	return controlKey
 }
 
-// parseInetSpec parses an inet statement from a named configuration excerpt.
-// The inet statement is defined by inet_spec:
-//
-//	inet_spec = ( ip_addr | * ) [ port ip_port ]
-//				allow { address_match_list }
-//				keys { key_list };
-//
-// This function returns the ip_addr, port and the first key that is
-// referenced in the key_list.  If instead of an ip_addr, the asterisk (*) is
-// specified, this function will return 'localhost' as an address.
-func parseInetSpec(config, excerpt string) (address string, port int64, key *Bind9RndcKey) {
-	pattern := regexp.MustCompile(
-		`(?s)` + // Enable multiline mode (\s matches a new line).
-			`inet` + // Fixed prefix statement.
-			`\s+` + // Mandatory spacing.
-			// First matching group.
-			`(\S+\s*\S*\s*\d*)` + // ( ip_addr | * ) [ port ip_port ]
-			`\s+` + // Mandatory spacing.
-			`allow` + // Fixed statement.
-			`\s*\{\s*` + // Opening clause surrounded by optional spacing.
-			// Non-matching group repeated zero or more times.
-			`(?:\s*\S+\s*;\s*)*` + // address_match_list
-			`\s*\}` + // Closing clause prepended by an optional spacing.
-			// Second matching group.
-			`(.*)` + // keys { key_list }; (pattern matched below)
-			`;`, // Trailing semicolon.
-	)
-	match := pattern.FindStringSubmatch(excerpt)
-	if len(match) == 0 {
-		log.Warnf("Cannot parse BIND 9 inet configuration: no match (%+v)", config)
-		return "", 0, nil
-	}
-
-	inetSpec := regexp.MustCompile(`\s+`).Split(match[1], 3)
-	switch len(inetSpec) {
-	case 1:
-		address = inetSpec[0]
-	case 3:
-		address = inetSpec[0]
-		if inetSpec[1] != "port" {
-			log.Warnf("Cannot parse BIND 9 control port: bad port statement (%+v)", inetSpec)
-			return "", 0, nil
-		}
-
-		iPort, err := strconv.Atoi(inetSpec[2])
-		if err != nil {
-			log.Warnf("Cannot parse BIND 9 control port: %+v (%+v)", inetSpec, err)
-			return "", 0, nil
-		}
-		port = int64(iPort)
-	case 2:
-	default:
-		log.Warnf("Cannot parse BIND 9 inet_spec configuration: no match (%+v)", inetSpec)
-		return "", 0, nil
-	}
-
-	if len(match) == 3 {
-		// Find a key clause. This pattern is build up like this:
-		// keys\s*                - keys
-		// \{\s*                  - {
-		// \"(.*?)\"\s*;\s*       - key_list (first)
-		// (?:\s*\".*?\"\s*;\s*)* - key_list (remainder)
-		// \}                     - }
-		pattern = regexp.MustCompile(`(?s)keys\s*\{\s*\"(.*?)\"\s*;\s*(?:\s*\".*?\"\s*;\s*)*\}`)
-
-		keyName := pattern.FindStringSubmatch(match[2])
-		if len(keyName) > 1 {
-			key = getRndcKey(config, keyName[1])
-			if key == nil {
-				log.WithField("key", keyName[1]).Warn("Cannot find key details")
-			}
-		}
-	}
-
+// Returns the address and port of the inet clause. If the port is not
+// specified, the default port is returned. If instead of an ip_addr, the
+// asterisk (*) is specified, this function will return 'localhost' as an
+// address.
+func getInetSpecAddress(spec *bind9config.InetSpec, defaultPort int64) (address string, port int64) {
+	address = spec.Address
 	if address == "*" {
 		address = "localhost"
 	}
-
-	return address, port, key
+	port = spec.Port
+	if port == 0 {
+		port = defaultPort
+	}
+	return address, port
 }
 
 // getCtrlAddressFromBind9Config retrieves the rndc control access address,
-// port, and secret key (if configured) from the configuration `text`.
+// port, and secret key (if configured) from the configuration.
 //
 // We need to cover the following cases:
 // - no controls clause - BIND9 will open a control socket on localhost
 // - empty controls clause - no control socket will be opened
 // - controls clause with keys - BIND9 will open a control socket
 //
-// Multiple controls clauses may be configured but currently this function
-// only matches the first one.  Multiple access points may be listed inside
-// a single controls clause, but this function currently only matches the
-// first in the list.  A controls clause may look like this:
+// Multiple access points may be listed inside the controls clauses, but
+// this function only returns the first one. A controls clause may look
+// like this:
 //
 //		controls {
 //			inet 127.0.0.1 allow {localhost;};
@@ -284,52 +190,40 @@ func parseInetSpec(config, excerpt string) (address string, port int64, key *Bin
 //		};
 //
 // In this example, "rndc-users" and "rndc-remote" refer to an acl and key
-// clauses.
-//
-// Finding the key is done by looking if the control access point has a
-// keys parameter and if so, it looks in `path` for a key clause with the
-// same name.
-func getCtrlAddressFromBind9Config(text string) (controlAddress string, controlPort int64, controlKey *Bind9RndcKey) {
-	// Match the following clause:
-	//     controls {
-	//         inet inet_spec [inet_spec] ;
-	//     };
-	// or
-	//     controls { /maybe some whitespace chars here/ };
-	pattern := regexp.MustCompile(`(?s)controls\s*\{\s*(.*)\s*\}\s*;`)
-	controls := pattern.FindStringSubmatch(text)
-	if len(controls) == 0 {
+// clauses. The returned key is the first key listed in the inet clause.
+func getCtrlAddressFromBind9Config(config *bind9config.Config) (controlAddress string, controlPort int64, controlKey *Bind9RndcKey) {
+	if !config.HasControls() {
 		log.Debugf("BIND9 has no `controls` clause, assuming defaults (127.0.0.1, port 953)")
-		return "127.0.0.1", 953, nil
+		return "127.0.0.1", RndcDefaultPort, nil
 	}
 
-	// See if there's any non-whitespace characters in the controls clause.
-	// If not, there's `controls {};`, which means: disable control socket.
-	txt := strings.TrimSpace(controls[1])
-	if len(txt) == 0 {
+	controls, err := config.GetControls()
+	if err != nil {
+		log.WithError(err).Warn("Cannot parse BIND 9 controls clause")
+		return "", 0, nil
+	}
+	// There's `controls {};`, which means: disable control socket.
+	if len(controls) == 0 {
 		log.Debugf("BIND9 has rndc support disabled (empty 'controls' found)")
 		return "", 0, nil
 	}
 
-	// We only pick the first match, but the controls clause
-	// can list multiple control access points.
-	controlAddress, controlPort, controlKey = parseInetSpec(text, controls[1])
-	if controlAddress != "" {
-		// If no port was provided, use the default rndc port.
-		if controlPort == 0 {
-			controlPort = RndcDefaultPort
+	controlAddress, controlPort = getInetSpecAddress(controls[0], RndcDefaultPort)
+	if len(controls[0].Keys) > 0 {
+		controlKey = getRndcKey(config, controls[0].Keys[0])
+		if controlKey == nil {
+			log.WithField("key", controls[0].Keys[0]).Warn("Cannot find key details")
 		}
 	}
 	return controlAddress, controlPort, controlKey
 }
 
 // getStatisticsChannelFromBind9Config retrieves the statistics channel access
-// address, port, and secret key (if configured) from the configuration `text`.
+// address and port from the configuration.
 //
-// Multiple statistics-channels clauses may be configured but currently this
-// function only matches the first one.  Multiple access points may be listed
-// inside a single controls clause, but this function currently only matches
-// the first in the list.  A statistics-channels clause may look like this:
+// Multiple access points may be listed inside the statistics-channels
+// clauses, but this function only returns the first one. A
+// statistics-channels clause may look like this:
 //
 //	statistics-channels {
 //		inet 10.1.10.10 port 8080 allow { 192.168.2.10; 10.1.10.2; };
@@ -337,27 +231,16 @@ func getCtrlAddressFromBind9Config(text string) (controlAddress string, controlP
 //	};
 //
 // In this example, "stats-clients" refers to an acl clause.
-func getStatisticsChannelFromBind9Config(text string) (statsAddress string, statsPort int64) {
-	// Match the following clause:
-	//     statistics-channels {
-	//         inet inet_spec [inet_spec] ;
-	//     };
-	pattern := regexp.MustCompile(`(?s)statistics-channels\s*\{\s*(.*)\s*\}\s*;`)
-	channels := pattern.FindStringSubmatch(text)
-	if len(channels) == 0 {
+func getStatisticsChannelFromBind9Config(config *bind9config.Config) (statsAddress string, statsPort int64) {
+	channels, err := config.GetStatisticsChannels()
+	if err != nil {
+		log.WithError(err).Warn("Cannot parse BIND 9 statistics-channels clause")
 		return "", 0
 	}
-
-	// We only pick the first match, but the statistics-channels clause
-	// can list multiple control access points.
-	statsAddress, statsPort, _ = parseInetSpec(text, channels[1])
-	if statsAddress != "" {
-		// If no port was provided, use the default statistics channel port.
-		if statsPort == 0 {
-			statsPort = StatsChannelDefaultPort
-		}
+	if len(channels) == 0 {
+		return "", 0
 	}
-	return statsAddress, statsPort
+	return getInetSpecAddress(channels[0], StatsChannelDefaultPort)
 }
 
 // Determine executable using base named directory or system default paths.
@@ -423,20 +306,60 @@ func parseNamedDefaultPath(output []byte) string {
 	return namedConfMatch[1]
 }
 
+// Returns the root directory of the process with a given PID as a path
+// accessible to the agent. The processes running in a container use their
+// own mount namespace, so their files are reachable via the root link in
+// the proc file system. The chrooted processes share the mount namespace
+// with the agent and their root link points to the chroot directory. It
+// returns an empty string if the process uses the same root directory as
+// the agent or the root directory cannot be determined, e.g., due to
+// insufficient permissions.
+func getProcessRootDir(procDirectory string, pid int32) string {
+	processDir := path.Join(procDirectory, fmt.Sprint(pid))
+	namespace, err := os.Readlink(path.Join(processDir, "ns", "mnt"))
+	if err != nil {
+		return ""
+	}
+	if ownNamespace, err := os.Readlink(path.Join(procDirectory, "self", "ns", "mnt")); err == nil && ownNamespace != namespace {
+		return path.Join(processDir, "root")
+	}
+	rootDir, err := os.Readlink(path.Join(processDir, "root"))
+	if err != nil || rootDir == "/" {
+		return ""
+	}
+	return rootDir
+}
+
+// Converts the path as seen by named running in a given root directory to
+// the path accessible to the agent.
+func resolveBind9Path(rootDir, namedPath string) string {
+	if rootDir == "" {
+		return namedPath
+	}
+	return path.Join(rootDir, namedPath)
+}
+
 // Detects the running Bind 9 application.
 // It accepts the components of the Bind 9 process name (the "match" argument),
 // the current working directory of the process (the "cwd" argument; it may be
-// empty), and a command executor instance. It uses multiple steps to attempt
-// detection:
+// empty), the root directory of the process (the "rootDir" argument; it is
+// empty if named shares the root directory with the agent), and a command
+// executor instance. It uses multiple steps to attempt detection:
 //
 // Step 1: Try to parse -c parameter of the running process.
 // Step 2: Checks if STORK_BIND9_CONFIG is defined. If it is, uses that.
 // Step 3: Try to parse output of the named -V command.
 // Step 4: Try to find named.conf in the default locations.
 //
-// Returns the collected data or nil if the Bind 9 is not recognized or any
-// error occurs.
-func detectBind9App(match []string, cwd string, executor storkutil.CommandExecutor) App {
+// The paths found in steps 1, 3 and 4 are relative to the root directory of
+// named. If the root directory is not specified, the directory specified in
+// the -t parameter is used. The path specified in the STORK_BIND9_CONFIG is
+// always relative to the agent's root directory.
+//
+// Each named instance running on the host is detected separately and results
+// in a distinct app. Returns the collected data or nil if the Bind 9 is not
+// recognized or any error occurs.
+func detectBind9App(match []string, cwd, rootDir string, executor storkutil.CommandExecutor) App {
 	if len(match) < 3 {
 		log.Warnf("Problem with parsing BIND 9 cmdline: %s", match[0])
 		return nil
@@ -446,6 +369,28 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 	namedDir := match[1]
 	bind9Params := match[2]
 	bind9ConfPath := ""
+	// Path to the config file accessible to the agent. It differs from the
+	// path seen by named when it runs in a chroot or in a container.
+	bind9HostConfPath := ""
+
+	// named changes its root directory to the one specified with -t.
+	if rootDir == "" {
+		chrootPattern := regexp.MustCompile(`-t\s+(\S+)`)
+		if m := chrootPattern.FindStringSubmatch(bind9Params); m != nil {
+			rootDir = m[1]
+			if !path.IsAbs(rootDir) {
+				rootDir = path.Join(cwd, rootDir)
+			}
+		}
+	}
+	if rootDir != "" {
+		log.Debugf("BIND 9 runs in the %s root directory.", rootDir)
+		// The working directory returned for the chrooted process includes
+		// the chroot directory.
+		if relativeCwd, err := filepath.Rel(rootDir, cwd); cwd != "" && err == nil && !strings.HasPrefix(relativeCwd, "..") {
+			cwd = path.Join("/", relativeCwd)
+		}
+	}
 
 	// look for config file in cmd params
 	paramsPattern := regexp.MustCompile(`-c\s+(\S+)`)
@@ -459,7 +404,8 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 		if !path.IsAbs(bind9ConfPath) {
 			bind9ConfPath = path.Join(cwd, bind9ConfPath)
 		}
-		log.Debugf("Found BIND 9 config file in %s based on -c parameter of a running process.", bind9ConfPath)
+		bind9HostConfPath = resolveBind9Path(rootDir, bind9ConfPath)
+		log.Debugf("Found BIND 9 config file in %s based on -c parameter of a running process.", bind9HostConfPath)
 	}
 
 	// STEP 2: Check if STORK_BIND9_CONFIG variable is specified it is, we'll use
@@ -469,6 +415,9 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 			log.Debugf("Looking for BIND 9 config in %s as specified in STORK_BIND9_CONFIG variable.", f)
 			if executor.IsFileExist(f) {
 				bind9ConfPath = f
+				bind9HostConfPath = f
+				// The path is not relative to the root directory of named.
+				rootDir = ""
 				log.Infof("Found BIND 9 config file in %s, based on STORK_BIND9_CONFIG variable", f)
 			} else {
 				log.Errorf("File specified in STORK_BIND9_CONFIG (%s) not found or unreadable.", f)
@@ -500,7 +449,8 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 		}
 		bind9ConfPath = parseNamedDefaultPath(out)
 		if len(bind9ConfPath) > 0 {
-			log.Infof("Found BIND 9 config file in %s based on output of `named -V`.", bind9ConfPath)
+			bind9HostConfPath = resolveBind9Path(rootDir, bind9ConfPath)
+			log.Infof("Found BIND 9 config file in %s based on output of `named -V`.", bind9HostConfPath)
 		}
 	}
 
@@ -509,10 +459,12 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 		log.Debugf("Looking for BIND 9 config file in typical locations.")
 		// config path not found in cmdline params so try to guess its location
 		for _, f := range getPotentialNamedConfLocations() {
-			log.Debugf("Looking for BIND 9 config file in %s", f)
-			if executor.IsFileExist(f) {
+			hostPath := resolveBind9Path(rootDir, f)
+			log.Debugf("Looking for BIND 9 config file in %s", hostPath)
+			if executor.IsFileExist(hostPath) {
 				bind9ConfPath = f
-				log.Infof("Found BIND 9 config file in %s based on typical locations.", bind9ConfPath)
+				bind9HostConfPath = hostPath
+				log.Infof("Found BIND 9 config file in %s based on typical locations.", bind9HostConfPath)
 				break
 			}
 		}
@@ -531,17 +483,40 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 		return nil
 	}
 
-	out, err := executor.Output(namedCheckconfPath, "-p", bind9ConfPath)
-	if err != nil {
-		log.Warnf("Cannot parse BIND 9 config file %s: %+v; %s", bind9ConfPath, err, out)
-		return nil
+	var config *bind9config.Config
+	if rootDir == "" {
+		out, err := executor.Output(namedCheckconfPath, "-p", bind9HostConfPath)
+		if err != nil {
+			log.Warnf("Cannot parse BIND 9 config file %s: %+v; %s", bind9HostConfPath, err, out)
+			return nil
+		}
+		if config, err = bind9config.Parse(string(out)); err != nil {
+			log.WithError(err).Warnf("Cannot parse BIND 9 config file %s", bind9HostConfPath)
+			return nil
+		}
+	} else {
+		// The include statements in the config are relative to the root
+		// directory of named. Changing the root directory requires root
+		// privileges, so fall back to parsing the config and the included
+		// files without named-checkconf, resolving the includes in the
+		// root directory.
+		out, err := executor.Output(namedCheckconfPath, "-t", rootDir, "-p", bind9ConfPath)
+		if err == nil {
+			config, err = bind9config.Parse(string(out))
+		} else {
+			log.Debugf("Cannot parse BIND 9 config file %s in the %s root directory using %s: %+v; %s", bind9ConfPath, rootDir, namedCheckconfExec, err, out)
+			config, err = bind9config.ParseFile(bind9HostConfPath, rootDir)
+		}
+		if err != nil {
+			log.WithError(err).Warnf("Cannot parse BIND 9 config file %s in the %s root directory", bind9ConfPath, rootDir)
+			return nil
+		}
 	}
-	cfgText := string(out)
 
 	// look for control address in config
-	ctrlAddress, ctrlPort, ctrlKey := getCtrlAddressFromBind9Config(cfgText)
+	ctrlAddress, ctrlPort, ctrlKey := getCtrlAddressFromBind9Config(config)
 	if ctrlPort == 0 || len(ctrlAddress) == 0 {
-		log.Warnf("Found BIND 9 config file (%s) but rndc support was disabled (empty `controls` clause)", bind9ConfPath)
+		log.Warnf("Found BIND 9 config file (%s) but rndc support was disabled (empty `controls` clause)", bind9HostConfPath)
 		return nil
 	}
 
@@ -560,7 +535,7 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 	}
 
 	// look for statistics channel address in config
-	address, port := getStatisticsChannelFromBind9Config(cfgText)
+	address, port := getStatisticsChannelFromBind9Config(config)
 	if port > 0 && len(address) != 0 {
 		accessPoints = append(accessPoints, AccessPoint{
 			Type:    AccessPointStatistics,
@@ -581,7 +556,7 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 	rndcClient := NewRndcClient(rndc)
 	err = rndcClient.DetermineDetails(
 		baseNamedDir,
-		path.Dir(bind9ConfPath),
+		path.Dir(bind9HostConfPath),
 		ctrlAddress,
 		ctrlPort,
 		ctrlKey,
@@ -599,6 +574,7 @@ func detectBind9App(match []string, cwd string, executor storkutil.CommandExecut
 			AccessPoints: accessPoints,
 		},
 		RndcClient: rndcClient,
+		config:     config,
 	}
 
 	return bind9App
@@ -3,15 +3,24 @@ package agent
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	bind9config "isc.org/stork/appcfg/bind9"
 	"isc.org/stork/testutil"
 	storkutil "isc.org/stork/util"
 )
 
+// Parses the BIND 9 configuration used in the tests.
+func parseBind9Config(t *testing.T, text string) *bind9config.Config {
+	config, err := bind9config.Parse(text)
+	require.NoError(t, err)
+	return config
+}
+
 // Test the function which extracts the list of log files from the Bind9
 // application by sending the request to the Kea Control Agent and the
 // daemons behind it.
@@ -173,7 +182,7 @@ func TestGetCtrlAddressFromBind9Config(t *testing.T) {
 
 	for name, test := range testCases {
 		t.Run(name, func(t *testing.T) {
-			a, b, c := getCtrlAddressFromBind9Config(test.config)
+			a, b, c := getCtrlAddressFromBind9Config(parseBind9Config(t, test.config))
 			require.Equal(t, a, test.expAddr)
 			require.Equal(t, b, test.expPort)
 			require.Nil(t, c, test.expKey)
@@ -186,6 +195,7 @@ func TestGetCtrlAddressFromBind9Config(t *testing.T) {
 type testCommandExecutor struct {
 	configPathInNamedOutput *string
 	outputs                 map[string]string
+	chrootDisallowed        bool
 }
 
 // Constructs a new instance of the test command executor.
@@ -209,6 +219,13 @@ func (e *testCommandExecutor) addCheckConfOutput(path, content string) *testComm
 	return e
 }
 
+// Makes the named-checkconf calls with the -t parameter fail, as if the
+// agent had no privileges to change the root directory.
+func (e *testCommandExecutor) disallowChroot() *testCommandExecutor {
+	e.chrootDisallowed = true
+	return e
+}
+
 // Set the named configuration path used in the output of the named -V call.
 // If the path is not set, the output doesn't contain the configuration path.
 func (e *testCommandExecutor) setConfigPathInNamedOutput(path string) *testCommandExecutor {
@@ -220,8 +237,14 @@ func (e *testCommandExecutor) setConfigPathInNamedOutput(path string) *testComma
 // specified files contents, similar to "cat" command.
 func (e *testCommandExecutor) Output(command string, args ...string) ([]byte, error) {
 	if strings.Contains(command, "named-checkconf") {
-		// Pretending to run named-checkconf -p <config-file>.
+		// Pretending to run named-checkconf [-t <directory>] -p <config-file>.
 		path := args[1]
+		if args[0] == "-t" {
+			if e.chrootDisallowed {
+				return nil, errors.New("chroot: operation not permitted")
+			}
+			path = filepath.Join(args[1], args[3])
+		}
 		content, ok := e.outputs[path]
 
 		if !ok {
@@ -282,7 +305,7 @@ func TestDetectBind9Step1ProcessCmdLine(t *testing.T) {
 		addCheckConfOutput(config1Path, config1)
 
 	// Now run the detection as usual
-	app := detectBind9App([]string{"", "/dir", fmt.Sprintf("-c %s", config1Path)}, "", executor)
+	app := detectBind9App([]string{"", "/dir", fmt.Sprintf("-c %s", config1Path)}, "", "", executor)
 	require.NotNil(t, app)
 	require.Equal(t, app.GetBaseApp().Type, AppTypeBind9)
 	require.Len(t, app.GetBaseApp().AccessPoints, 1)
@@ -316,7 +339,7 @@ func TestDetectBind9Step2EnvVar(t *testing.T) {
 		addCheckConfOutput(confPath, config)
 
 	namedDir := "/dir/usr/sbin"
-	app := detectBind9App([]string{"", namedDir, "-some -params"}, "", executor)
+	app := detectBind9App([]string{"", namedDir, "-some -params"}, "", "", executor)
 	require.NotNil(t, app)
 	require.Equal(t, app.GetBaseApp().Type, AppTypeBind9)
 	require.Len(t, app.GetBaseApp().AccessPoints, 1)
@@ -346,7 +369,7 @@ func TestDetectBind9Step3BindVOutput(t *testing.T) {
 
 	// Now run the detection as usual
 	namedDir := "/dir/usr/sbin"
-	app := detectBind9App([]string{"", namedDir, "-some -params"}, "", executor)
+	app := detectBind9App([]string{"", namedDir, "-some -params"}, "", "", executor)
 	require.NotNil(t, app)
 	require.Equal(t, app.GetBaseApp().Type, AppTypeBind9)
 	require.Len(t, app.GetBaseApp().AccessPoints, 1)
@@ -377,7 +400,7 @@ func TestDetectBind9Step4TypicalLocations(t *testing.T) {
 
 		t.Run(expectedPath, func(t *testing.T) {
 			// Act
-			app := detectBind9App([]string{"", "/dir", "-some -params"}, "", executor)
+			app := detectBind9App([]string{"", "/dir", "-some -params"}, "", "", executor)
 
 			// Assert
 			require.NotNil(t, app)
@@ -436,7 +459,7 @@ func TestDetectBind9DetectOrder(t *testing.T) {
 	os.Setenv("STORK_BIND9_CONFIG", config2Path)
 
 	// Now run the detection as usual
-	app := detectBind9App([]string{"", "/dir", fmt.Sprintf("-c %s", config1Path)}, "", executor)
+	app := detectBind9App([]string{"", "/dir", fmt.Sprintf("-c %s", config1Path)}, "", "", executor)
 	require.NotNil(t, app)
 	require.Equal(t, app.GetBaseApp().Type, AppTypeBind9)
 	require.Len(t, app.GetBaseApp().AccessPoints, 1)
@@ -447,13 +470,78 @@ func TestDetectBind9DetectOrder(t *testing.T) {
 	require.EqualValues(t, "foo:hmac-sha256:abcd", point.Key)
 }
 
+// Checks that the config file of named running in a chroot specified with
+// the -t parameter is found in the chroot directory. Each instance is
+// detected as a separate app.
+func TestDetectBind9Chroot(t *testing.T) {
+	config1 := `key "foo" { algorithm "hmac-sha256"; secret "abcd";};
+                controls { inet 127.0.0.1 port 1111 allow { localhost; } keys { "foo"; }; };`
+	config2 := `key "foo" { algorithm "hmac-sha256"; secret "abcd";};
+                controls { inet 127.0.0.1 port 2222 allow { localhost; } keys { "foo"; }; };`
+
+	executor := newTestCommandExecutor().
+		addCheckConfOutput("/var/named/one/etc/named.conf", config1).
+		addCheckConfOutput("/var/named/two/etc/bind/named.conf", config2)
+
+	app1 := detectBind9App([]string{"", "/usr/sbin", "-u bind -t /var/named/one -c /etc/named.conf"}, "/var/named/one", "", executor)
+	require.NotNil(t, app1)
+	require.Len(t, app1.GetBaseApp().AccessPoints, 1)
+	require.EqualValues(t, 1111, app1.GetBaseApp().AccessPoints[0].Port)
+
+	// The config in the default location relative to the chroot.
+	app2 := detectBind9App([]string{"", "/usr/sbin", "-u bind -t /var/named/two"}, "", "", executor)
+	require.NotNil(t, app2)
+	require.Len(t, app2.GetBaseApp().AccessPoints, 1)
+	require.EqualValues(t, 2222, app2.GetBaseApp().AccessPoints[0].Port)
+}
+
+// Checks that the config file of named running in a container is found
+// in the root directory of the process. The config and the files it
+// includes are parsed without named-checkconf if the agent has no
+// privileges to change the root directory.
+func TestDetectBind9ProcessRootDir(t *testing.T) {
+	rootDir := t.TempDir()
+	err := os.MkdirAll(filepath.Join(rootDir, "etc/bind"), 0o755)
+	require.NoError(t, err)
+	// The absolute path of the included file is relative to the root
+	// directory of named.
+	err = os.WriteFile(filepath.Join(rootDir, "etc/bind/named.conf"), []byte(`
+		include "/etc/bind/rndc.key";
+		controls { inet 192.0.2.1 port 1234 allow { localhost; } keys { "foo"; }; };
+	`), 0o600)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(rootDir, "etc/bind/rndc.key"), []byte(`
+		key "foo" { algorithm "hmac-sha256"; secret "abcd"; };
+	`), 0o600)
+	require.NoError(t, err)
+
+	executor := newTestCommandExecutor().disallowChroot()
+
+	app := detectBind9App([]string{"", "/usr/sbin", "-c /etc/bind/named.conf"}, "/", rootDir, executor)
+	require.NotNil(t, app)
+	require.Len(t, app.GetBaseApp().AccessPoints, 1)
+	point := app.GetBaseApp().AccessPoints[0]
+	require.Equal(t, "192.0.2.1", point.Address)
+	require.EqualValues(t, 1234, point.Port)
+	require.EqualValues(t, "foo:hmac-sha256:abcd", point.Key)
+
+	// The config isn't found outside the root directory.
+	app = detectBind9App([]string{"", "/usr/sbin", "-c /etc/bind/named.conf"}, "/", "", executor)
+	require.Nil(t, app)
+}
+
+// Test that the root directory of the agent's own process is not reported.
+func TestGetProcessRootDirOwnProcess(t *testing.T) {
+	require.Empty(t, getProcessRootDir("/proc", int32(os.Getpid())))
+	require.Empty(t, getProcessRootDir("/non-existing", int32(os.Getpid())))
+}
+
 // Test that the empty string is returned if the configuration content is empty.
 func TestGetRndcKeyEmptyData(t *testing.T) {
-	require.Nil(t, getRndcKey("", "key"))
+	require.Nil(t, getRndcKey(parseBind9Config(t, ""), "key"))
 }
 
-// Test that the empty string is returned if the configuration content contains
-// no 'key' clause.
+// Test that the invalid configuration content is rejected by the parser.
 func TestGetRndcKeyInvalidData(t *testing.T) {
 	// Arrange
 	content := `
@@ -461,8 +549,25 @@ func TestGetRndcKeyInvalidData(t *testing.T) {
 		secret  "baz"
 	`
 
+	// Act
+	config, err := bind9config.Parse(content)
+
+	// Assert
+	require.Error(t, err)
+	require.Nil(t, config)
+}
+
+// Test that the empty string is returned if the configuration content contains
+// no 'key' clause.
+func TestGetRndcKeyNoKeyClause(t *testing.T) {
+	// Arrange
+	content := `
+		algorithm  "bar";
+		secret  "baz";
+	`
+
 	// Act & Assert
-	require.Nil(t, getRndcKey(content, "key"))
+	require.Nil(t, getRndcKey(parseBind9Config(t, content), "key"))
 }
 
 // Test that the empty string is returned if the key with a given name doesn't
@@ -475,7 +580,7 @@ func TestGetRndcKeyUnknownKey(t *testing.T) {
 	};`
 
 	// Act & Assert
-	require.Nil(t, getRndcKey(content, "key"))
+	require.Nil(t, getRndcKey(parseBind9Config(t, content), "key"))
 }
 
 // Test that the empty string is returned if a given name is an empty string.
@@ -487,7 +592,7 @@ func TestGetRndcKeyBlankName(t *testing.T) {
 	};`
 
 	// Act & Assert
-	require.Nil(t, getRndcKey(content, ""))
+	require.Nil(t, getRndcKey(parseBind9Config(t, content), ""))
 }
 
 // Test that the empty string is returned if the algorithm property is missing.
@@ -498,7 +603,7 @@ func TestGetRndcKeyMissingAlgorithm(t *testing.T) {
 	};`
 
 	// Act & Assert
-	require.Nil(t, getRndcKey(content, "foo"))
+	require.Nil(t, getRndcKey(parseBind9Config(t, content), "foo"))
 }
 
 // Test that the empty string is returned if the secret property is missing.
@@ -509,7 +614,7 @@ func TestGetRndcKeyMissingSecret(t *testing.T) {
 	};`
 
 	// Act & Assert
-	require.Nil(t, getRndcKey(content, "foo"))
+	require.Nil(t, getRndcKey(parseBind9Config(t, content), "foo"))
 }
 
 // Test that the combination of algorithm and secret is returned if the key
@@ -522,7 +627,7 @@ func TestGetRndcKeyValidData(t *testing.T) {
 	};`
 
 	// Act
-	key := getRndcKey(content, "foo")
+	key := getRndcKey(parseBind9Config(t, content), "foo")
 
 	// Assert
 	require.NotNil(t, key)
@@ -546,7 +651,7 @@ func TestGetRndcKeyValidDataMultipleKeys(t *testing.T) {
 	};`
 
 	// Act
-	key := getRndcKey(content, "foo")
+	key := getRndcKey(parseBind9Config(t, content), "foo")
 
 	// Assert
 	require.NotNil(t, key)
@@ -557,7 +662,7 @@ func TestGetRndcKeyValidDataMultipleKeys(t *testing.T) {
 
 // Test that the key name is recognized for various formatting styles of the
 // inet clause.
-func TestParseInetSpecForDifferentKeysFormatting(t *testing.T) {
+func TestGetCtrlAddressForDifferentKeysFormatting(t *testing.T) {
 	// Arrange
 	keysClauses := map[string]string{
 		"single-name-single-space-around":    `keys { "rndc-key"; }`,
@@ -595,7 +700,7 @@ func TestParseInetSpecForDifferentKeysFormatting(t *testing.T) {
 				`, inetClause)
 
 				// Act
-				_, _, key := parseInetSpec(config, inetClause)
+				_, _, key := getCtrlAddressFromBind9Config(parseBind9Config(t, config))
 
 				// Assert
 				require.NotNil(t, key)
@@ -619,3 +724,19 @@ func TestBind9RndcKeyString(t *testing.T) {
 	// Act & Assert
 	require.EqualValues(t, "foo:bar:baz", key.String())
 }
+
+// Test that the first statistics channel is returned and the default port
+// is used when the port is not specified.
+func TestGetStatisticsChannelFromBind9Config(t *testing.T) {
+	address, port := getStatisticsChannelFromBind9Config(parseBind9Config(t, `
+		statistics-channels {
+			inet * allow { localhost; };
+			inet 127.0.0.1 port 8053 allow { localhost; };
+		};`))
+	require.Equal(t, "localhost", address)
+	require.EqualValues(t, StatsChannelDefaultPort, port)
+
+	address, port = getStatisticsChannelFromBind9Config(parseBind9Config(t, `options { };`))
+	require.Empty(t, address)
+	require.Zero(t, port)
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"sync"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -20,8 +21,9 @@ var CredentialsFile = "/etc/stork/agent-credentials.json" //nolint:gochecknoglob
 
 // HTTPClient is a normal http client.
 type HTTPClient struct {
-	client      *http.Client
-	credentials *CredentialsStore
+	client           *http.Client
+	credentials      *CredentialsStore
+	credentialsMutex *sync.RWMutex
 }
 
 // Create a client to contact with Kea Control Agent or named statistics-channel.
@@ -75,35 +77,15 @@ func NewHTTPClient(skipTLSVerification bool) (*HTTPClient, error) {
 		Transport: httpTransport,
 	}
 
-	credentialsStore := NewCredentialsStore()
-	// Check if the credential file exist
-	_, err = os.Stat(CredentialsFile)
-	switch {
-	case err == nil:
-		file, err := os.Open(CredentialsFile)
-		if err == nil {
-			defer file.Close()
-			err = credentialsStore.Read(file)
-			err = errors.WithMessagef(err, "could not read the credentials file (%s)", CredentialsFile)
-		}
-		if err == nil {
-			log.Infof("Configured to use the Basic Auth credentials from file (%s)", CredentialsFile)
-		} else {
-			log.WithError(err).Warnf("Could not read the Basic Auth credentials from file (%s)", CredentialsFile)
-			return nil, err
-		}
-	case errors.Is(err, os.ErrNotExist):
-		// The credentials file may not exist.
-		log.Infof("The Basic Auth credentials file (%s) is missing - HTTP authentication is not used", CredentialsFile)
-	default:
-		// Unexpected error.
-		log.WithError(err).Error("Could not access the Basic Auth credentials file")
+	credentialsStore, err := readCredentialsFile()
+	if err != nil {
 		return nil, err
 	}
 
 	client := &HTTPClient{
-		client:      httpClient,
-		credentials: credentialsStore,
+		client:           httpClient,
+		credentials:      credentialsStore,
+		credentialsMutex: &sync.RWMutex{},
 	}
 
 	return client, nil
@@ -122,7 +104,10 @@ func (c *HTTPClient) Call(url string, payload io.Reader) (*http.Response, error)
 	}
 	req.Header.Add("Content-Type", "application/json")
 
-	if basicAuth, ok := c.credentials.GetBasicAuthByURL(url); ok {
+	c.credentialsMutex.RLock()
+	basicAuth, ok := c.credentials.GetBasicAuthByURL(url)
+	c.credentialsMutex.RUnlock()
+	if ok {
 		secret := fmt.Sprintf("%s:%s", basicAuth.User, basicAuth.Password)
 		encodedSecret := base64.StdEncoding.EncodeToString([]byte(secret))
 		headerContent := fmt.Sprintf("Basic %s", encodedSecret)
@@ -139,5 +124,52 @@ func (c *HTTPClient) Call(url string, payload io.Reader) (*http.Response, error)
 // Indicates if the Stork Agent attaches the authentication credentials to
 // the requests.
 func (c *HTTPClient) HasAuthenticationCredentials() bool {
+	c.credentialsMutex.RLock()
+	defer c.credentialsMutex.RUnlock()
 	return !c.credentials.IsEmpty()
 }
+
+// Reads the credentials file again and replaces the credentials used by
+// the client. The requests in progress are not affected. The current
+// credentials are preserved if the file is invalid.
+func (c *HTTPClient) ReloadCredentials() error {
+	credentialsStore, err := readCredentialsFile()
+	if err != nil {
+		return err
+	}
+	c.credentialsMutex.Lock()
+	defer c.credentialsMutex.Unlock()
+	c.credentials = credentialsStore
+	return nil
+}
+
+// Reads the Basic Auth credentials from the credentials file. It returns
+// an empty store if the file doesn't exist.
+func readCredentialsFile() (*CredentialsStore, error) {
+	credentialsStore := NewCredentialsStore()
+	// Check if the credential file exist
+	_, err := os.Stat(CredentialsFile)
+	switch {
+	case err == nil:
+		file, err := os.Open(CredentialsFile)
+		if err == nil {
+			defer file.Close()
+			err = credentialsStore.Read(file)
+			err = errors.WithMessagef(err, "could not read the credentials file (%s)", CredentialsFile)
+		}
+		if err == nil {
+			log.Infof("Configured to use the Basic Auth credentials from file (%s)", CredentialsFile)
+		} else {
+			log.WithError(err).Warnf("Could not read the Basic Auth credentials from file (%s)", CredentialsFile)
+			return nil, err
+		}
+	case errors.Is(err, os.ErrNotExist):
+		// The credentials file may not exist.
+		log.Infof("The Basic Auth credentials file (%s) is missing - HTTP authentication is not used", CredentialsFile)
+	default:
+		// Unexpected error.
+		log.WithError(err).Error("Could not access the Basic Auth credentials file")
+		return nil, err
+	}
+	return credentialsStore, nil
+}
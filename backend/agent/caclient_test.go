@@ -12,6 +12,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"isc.org/stork/testutil"
 	storkutil "isc.org/stork/util"
 )
 
@@ -247,3 +248,52 @@ func TestHasAuthenticationCredentialsMissingFile(t *testing.T) {
 	require.NoError(t, err)
 	require.False(t, client.HasAuthenticationCredentials())
 }
+
+// Test that the credentials are replaced after reloading the credentials
+// file and preserved if the new file is invalid.
+func TestReloadCredentials(t *testing.T) {
+	restorePaths := RememberPaths()
+	defer restorePaths()
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	CredentialsFile = path.Join(sb.BasePath, "credentials.json")
+
+	client, err := NewHTTPClient(true)
+	require.NoError(t, err)
+	require.False(t, client.HasAuthenticationCredentials())
+
+	// Add the credentials.
+	content := `{
+		"basic_auth": [
+			{
+				"ip": "192.0.2.1",
+				"port": 8000,
+				"user": "foo",
+				"password": "bar"
+			}
+		]
+	}`
+	err = os.WriteFile(CredentialsFile, []byte(content), 0o600)
+	require.NoError(t, err)
+
+	err = client.ReloadCredentials()
+	require.NoError(t, err)
+	require.True(t, client.HasAuthenticationCredentials())
+
+	// Break the credentials file.
+	err = os.WriteFile(CredentialsFile, []byte("{"), 0o600)
+	require.NoError(t, err)
+
+	err = client.ReloadCredentials()
+	require.Error(t, err)
+	require.True(t, client.HasAuthenticationCredentials())
+
+	// Remove the credentials file.
+	err = os.Remove(CredentialsFile)
+	require.NoError(t, err)
+
+	err = client.ReloadCredentials()
+	require.NoError(t, err)
+	require.False(t, client.HasAuthenticationCredentials())
+}
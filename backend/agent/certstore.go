@@ -319,6 +319,103 @@ func (s *CertStore) WriteCertPEM(certPEM []byte) error {
 	return s.writeCert(certPEM)
 }
 
+// Generates the CSR for renewing the current agent certificate using the
+// provided private key. The new certificate is requested for the same
+// hostnames and IP addresses as the current one. Returns the CSR
+// serialized to the PEM format or error.
+func (s *CertStore) GenerateRenewalCSR(keyPEM []byte) ([]byte, error) {
+	certPEM, err := s.readCert()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := pki.ParseCert(certPEM)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not parse the current cert")
+	}
+	csrPEM, _, err := pki.GenCSRUsingKey("agent", cert.DNSNames, cert.IPAddresses, keyPEM)
+	if err != nil {
+		return nil, errors.WithMessage(err, "could not generate CSR for the cert renewal")
+	}
+	return csrPEM, nil
+}
+
+// Replaces the private key, cert and root CA files with the renewed ones.
+// The cert must match the private key and must be signed by one of the
+// provided root CA certs. The root CA content may hold multiple certs,
+// e.g., during the server CA rollover. The files are not modified if any
+// of the provided contents is invalid.
+func (s *CertStore) InstallRenewedCert(keyPEM, certPEM, rootCAPEM []byte) error {
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return errors.Wrapf(err, "the renewed cert doesn't match the private key")
+	}
+	cert, err := pki.ParseCert(certPEM)
+	if err != nil {
+		return errors.WithMessage(err, "the renewed cert is invalid")
+	}
+	rootCAs := x509.NewCertPool()
+	if ok := rootCAs.AppendCertsFromPEM(rootCAPEM); !ok {
+		return errors.New("the provided root CA PEM content is invalid")
+	}
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     rootCAs,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return errors.Wrapf(err, "the renewed cert is not signed by the provided root CA")
+	}
+
+	return s.replaceFiles(
+		[]string{s.rootCAPEMPath, s.keyPEMPath, s.certPEMPath},
+		[][]byte{rootCAPEM, keyPEM, certPEM},
+	)
+}
+
+// Replaces the contents of the specified files. The contents are first
+// written to the temporary files in the same directories, and the temporary
+// files are renamed to the target files when all of them are written. If
+// any of the renames fails, the already replaced files are restored from
+// their previous contents. It prevents leaving the files that don't match
+// each other, e.g., a private key and a cert, when writing one of them
+// fails.
+func (s *CertStore) replaceFiles(paths []string, contents [][]byte) (err error) {
+	tempPaths := make([]string, 0, len(paths))
+	defer func() {
+		for _, tempPath := range tempPaths {
+			_ = s.removeIfExist(tempPath)
+		}
+	}()
+	for i, path := range paths {
+		tempPath := path + ".new"
+		tempPaths = append(tempPaths, tempPath)
+		if err = s.write(tempPath, contents[i]); err != nil {
+			return err
+		}
+	}
+
+	// Remember the current contents to restore them on error.
+	oldContents := make([][]byte, len(paths))
+	for i, path := range paths {
+		if ok, _ := s.isExist(path); ok {
+			if oldContents[i], err = s.read(path); err != nil {
+				return err
+			}
+		}
+	}
+
+	for i, path := range paths {
+		if err = os.Rename(tempPaths[i], path); err != nil {
+			err = errors.Wrapf(err, "could not replace the file: %s", path)
+			for j := 0; j < i; j++ {
+				if oldContents[j] != nil {
+					_ = s.write(paths[j], oldContents[j])
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 // Checks if all files managed by the cert store are valid (they exist and
 // have proper contents). Returns an error that describes all occurred
 // problems. Returns nil if all is OK.
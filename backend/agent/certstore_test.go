@@ -2,10 +2,12 @@ package agent
 
 import (
 	"os"
+	"path/filepath"
 	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	"isc.org/stork/pki"
 	storkutil "isc.org/stork/util"
 )
 
@@ -292,3 +294,134 @@ func TestCertStoreIsNotEmpty(t *testing.T) {
 		})
 	}
 }
+
+// Test that the CSR for the cert renewal is generated for the hostnames
+// and IP addresses of the current cert.
+func TestGenerateRenewalCSR(t *testing.T) {
+	// Arrange
+	teardown, _ := GenerateSelfSignedCerts()
+	defer teardown()
+	store := NewCertStoreDefault()
+	keyPEM, _ := pki.GenKey()
+
+	// Act
+	csrPEM, err := store.GenerateRenewalCSR(keyPEM)
+
+	// Assert
+	require.NoError(t, err)
+	_, caKeyPEM, _, caCertPEM, _ := pki.GenCAKeyCert(1)
+	certPEM, _, paramsErr, innerErr := pki.SignCert(csrPEM, 2, caCertPEM, caKeyPEM)
+	require.NoError(t, paramsErr)
+	require.NoError(t, innerErr)
+	cert, err := pki.ParseCert(certPEM)
+	require.NoError(t, err)
+	require.Equal(t, []string{"foobar"}, cert.DNSNames)
+	require.Len(t, cert.IPAddresses, 1)
+	require.Equal(t, "127.0.0.1", cert.IPAddresses[0].String())
+}
+
+// Test that the CSR for the cert renewal cannot be generated without the
+// current cert.
+func TestGenerateRenewalCSRForMissingCert(t *testing.T) {
+	// Arrange
+	teardown, _ := GenerateSelfSignedCerts()
+	defer teardown()
+	store := NewCertStoreDefault()
+	os.Remove(CertPEMFile)
+	keyPEM, _ := pki.GenKey()
+
+	// Act
+	csrPEM, err := store.GenerateRenewalCSR(keyPEM)
+
+	// Assert
+	require.Error(t, err)
+	require.Nil(t, csrPEM)
+}
+
+// Test that the renewed cert is installed along with its private key and
+// the root CA bundle.
+func TestInstallRenewedCert(t *testing.T) {
+	// Arrange
+	teardown, _ := GenerateSelfSignedCerts()
+	defer teardown()
+	store := NewCertStoreDefault()
+	oldRootCAPEM, _ := os.ReadFile(RootCAFile)
+	keyPEM, _ := pki.GenKey()
+	csrPEM, _ := store.GenerateRenewalCSR(keyPEM)
+	_, caKeyPEM, _, caCertPEM, _ := pki.GenCAKeyCert(1)
+	certPEM, _, _, _ := pki.SignCert(csrPEM, 2, caCertPEM, caKeyPEM)
+	rootCAPEM := append(append([]byte{}, oldRootCAPEM...), caCertPEM...)
+
+	// Act
+	err := store.InstallRenewedCert(keyPEM, certPEM, rootCAPEM)
+
+	// Assert
+	require.NoError(t, err)
+	content, _ := os.ReadFile(KeyPEMFile)
+	require.Equal(t, keyPEM, content)
+	content, _ = os.ReadFile(CertPEMFile)
+	require.Equal(t, certPEM, content)
+	content, _ = os.ReadFile(RootCAFile)
+	require.Equal(t, rootCAPEM, content)
+}
+
+// Test that none of the files is replaced when writing one of them fails.
+func TestInstallRenewedCertWriteError(t *testing.T) {
+	// Arrange
+	teardown, _ := GenerateSelfSignedCerts()
+	defer teardown()
+	store := NewCertStoreDefault()
+	oldKeyPEM, _ := os.ReadFile(KeyPEMFile)
+	oldCertPEM, _ := os.ReadFile(CertPEMFile)
+	oldRootCAPEM, _ := os.ReadFile(RootCAFile)
+	keyPEM, _ := pki.GenKey()
+	csrPEM, _ := store.GenerateRenewalCSR(keyPEM)
+	_, caKeyPEM, _, caCertPEM, _ := pki.GenCAKeyCert(1)
+	certPEM, _, _, _ := pki.SignCert(csrPEM, 2, caCertPEM, caKeyPEM)
+
+	// The temporary cert file can't be created because a non-empty
+	// directory exists under its path.
+	_ = os.MkdirAll(filepath.Join(CertPEMFile+".new", "foo"), 0o700)
+
+	// Act
+	err := store.InstallRenewedCert(keyPEM, certPEM, caCertPEM)
+
+	// Assert
+	require.Error(t, err)
+	content, _ := os.ReadFile(KeyPEMFile)
+	require.Equal(t, oldKeyPEM, content)
+	content, _ = os.ReadFile(CertPEMFile)
+	require.Equal(t, oldCertPEM, content)
+	content, _ = os.ReadFile(RootCAFile)
+	require.Equal(t, oldRootCAPEM, content)
+	require.NoFileExists(t, KeyPEMFile+".new")
+	require.NoFileExists(t, RootCAFile+".new")
+}
+
+// Test that the renewed cert is not installed if it is not signed by the
+// provided root CA or doesn't match the private key.
+func TestInstallRenewedCertInvalid(t *testing.T) {
+	// Arrange
+	teardown, _ := GenerateSelfSignedCerts()
+	defer teardown()
+	store := NewCertStoreDefault()
+	oldCertPEM, _ := os.ReadFile(CertPEMFile)
+	keyPEM, _ := pki.GenKey()
+	otherKeyPEM, _ := pki.GenKey()
+	csrPEM, _ := store.GenerateRenewalCSR(keyPEM)
+	_, caKeyPEM, _, caCertPEM, _ := pki.GenCAKeyCert(1)
+	_, _, _, otherCACertPEM, _ := pki.GenCAKeyCert(3)
+	certPEM, _, _, _ := pki.SignCert(csrPEM, 2, caCertPEM, caKeyPEM)
+
+	// Act
+	errWrongCA := store.InstallRenewedCert(keyPEM, certPEM, otherCACertPEM)
+	errWrongKey := store.InstallRenewedCert(otherKeyPEM, certPEM, caCertPEM)
+	errInvalidCA := store.InstallRenewedCert(keyPEM, certPEM, []byte("foo"))
+
+	// Assert
+	require.Error(t, errWrongCA)
+	require.Error(t, errWrongKey)
+	require.Error(t, errInvalidCA)
+	content, _ := os.ReadFile(CertPEMFile)
+	require.Equal(t, oldCertPEM, content)
+}
@@ -0,0 +1,116 @@
+package agent
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Default interval of checking the watched files for changes.
+const DefaultFileWatcherInterval = 5 * time.Second
+
+// State of the watched file used to detect its changes.
+type watchedFileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+}
+
+// Periodically checks if the watched files have changed. It compares the
+// modification times and sizes of the files rather than relying on the
+// file system notifications, so it also detects the files replaced by
+// renaming or re-created after removal. The changes are signalled over a
+// channel. The receiver is expected to re-read all files it is interested
+// in, so the notifications are coalesced when the receiver is busy.
+type FileWatcher struct {
+	paths    []string
+	states   map[string]watchedFileState
+	interval time.Duration
+	ticker   *time.Ticker
+	changes  chan bool
+	done     chan bool
+	wg       *sync.WaitGroup
+}
+
+// Creates a watcher checking the specified files every interval. The
+// current states of the files are remembered, so only the later changes
+// are signalled.
+func NewFileWatcher(interval time.Duration, paths ...string) *FileWatcher {
+	watcher := &FileWatcher{
+		paths:    paths,
+		states:   make(map[string]watchedFileState),
+		interval: interval,
+		changes:  make(chan bool, 1),
+		done:     make(chan bool),
+		wg:       &sync.WaitGroup{},
+	}
+	watcher.checkFiles()
+	return watcher
+}
+
+// Returns the channel receiving a value when some of the watched files
+// have changed.
+func (w *FileWatcher) Changes() <-chan bool {
+	return w.changes
+}
+
+// Starts checking the files in background.
+func (w *FileWatcher) Start() {
+	w.ticker = time.NewTicker(w.interval)
+	w.wg.Add(1)
+	go w.watchLoop()
+}
+
+// Stops checking the files.
+func (w *FileWatcher) Shutdown() {
+	if w.ticker == nil {
+		return
+	}
+	w.ticker.Stop()
+	w.done <- true
+	w.wg.Wait()
+	w.ticker = nil
+}
+
+// Checks the files every interval until the watcher is shut down.
+func (w *FileWatcher) watchLoop() {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-w.ticker.C:
+			changed := w.checkFiles()
+			if len(changed) == 0 {
+				continue
+			}
+			log.WithField("files", changed).Info("Detected changes in the watched files")
+			select {
+			case w.changes <- true:
+			default:
+				// The previous notification hasn't been received yet.
+			}
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// Compares the current states of the files with the remembered ones and
+// returns the paths of the changed files.
+func (w *FileWatcher) checkFiles() (changed []string) {
+	for _, path := range w.paths {
+		state := watchedFileState{}
+		if info, err := os.Stat(path); err == nil {
+			state.exists = true
+			state.modTime = info.ModTime()
+			state.size = info.Size()
+		}
+		previous, known := w.states[path]
+		if known && previous != state {
+			changed = append(changed, path)
+		}
+		w.states[path] = state
+	}
+	return changed
+}
@@ -0,0 +1,64 @@
+package agent
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/testutil"
+)
+
+// Test that the watcher detects the modified, created and removed files.
+func TestFileWatcherCheckFiles(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	existingFile, err := sb.Write("existing.txt", "foo")
+	require.NoError(t, err)
+	missingFile := path.Join(sb.BasePath, "missing.txt")
+
+	watcher := NewFileWatcher(time.Hour, existingFile, missingFile)
+	require.Empty(t, watcher.checkFiles())
+
+	// Modify the existing file.
+	err = os.WriteFile(existingFile, []byte("foobar"), 0o600)
+	require.NoError(t, err)
+	require.Equal(t, []string{existingFile}, watcher.checkFiles())
+	require.Empty(t, watcher.checkFiles())
+
+	// Create the missing file.
+	_, err = sb.Write("missing.txt", "bar")
+	require.NoError(t, err)
+	require.Equal(t, []string{missingFile}, watcher.checkFiles())
+
+	// Remove the file.
+	err = os.Remove(existingFile)
+	require.NoError(t, err)
+	require.Equal(t, []string{existingFile}, watcher.checkFiles())
+}
+
+// Test that the watcher signals the changes when started.
+func TestFileWatcherChanges(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	file := path.Join(sb.BasePath, "file.txt")
+	watcher := NewFileWatcher(10*time.Millisecond, file)
+	watcher.Start()
+	defer watcher.Shutdown()
+
+	_, err := sb.Write("file.txt", "foo")
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		select {
+		case <-watcher.Changes():
+			return true
+		default:
+			return false
+		}
+	}, time.Second, 10*time.Millisecond)
+}
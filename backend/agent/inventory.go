@@ -0,0 +1,390 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/shirou/gopsutil/process"
+	log "github.com/sirupsen/logrus"
+	storkutil "isc.org/stork/util"
+)
+
+// Facts about a file used by a daemon, e.g., a configuration file or
+// a control socket.
+type fileInventory struct {
+	Path  string
+	Mode  string
+	Owner string
+	Group string
+	Error string
+}
+
+// Facts about the host related to a running Kea or BIND 9 daemon. They
+// are gathered for troubleshooting.
+type daemonInventory struct {
+	Name             string
+	Pid              int32
+	Executable       string
+	Package          string
+	SystemdUnit      string
+	SystemdUnitState string
+	ListeningSockets []string
+	Files            []fileInventory
+}
+
+// The systemd unit states are cached for this period. The unit may be
+// enabled or disabled by the administrator, so the state is refreshed
+// from time to time.
+const systemdUnitStateCacheLifetime = 10 * time.Minute
+
+// The cached state of the systemd unit.
+type systemdUnitState struct {
+	state     string
+	checkedAt time.Time
+}
+
+// Gathers the inventory of the Kea and BIND 9 daemons running on the host.
+// The packages providing the executables are looked up using the system
+// package managers. The results are cached because the lookups are slow.
+// The systemd unit states are cached too, because they are gathered on
+// every state request from the server.
+type inventoryCollector struct {
+	executor storkutil.CommandExecutor
+	// Directory with the processes' information, typically /proc.
+	procDirectory   string
+	packages        map[string]string
+	packagesMutex   *sync.Mutex
+	unitStates      map[string]systemdUnitState
+	unitStatesMutex *sync.Mutex
+}
+
+// Creates the inventory collector using the specified command executor.
+func newInventoryCollector(executor storkutil.CommandExecutor) *inventoryCollector {
+	return &inventoryCollector{
+		executor:        executor,
+		procDirectory:   "/proc",
+		packages:        make(map[string]string),
+		packagesMutex:   &sync.Mutex{},
+		unitStates:      make(map[string]systemdUnitState),
+		unitStatesMutex: &sync.Mutex{},
+	}
+}
+
+// Checks if the process name belongs to a Kea or BIND 9 daemon.
+func isInventoriedProcess(name string) bool {
+	return name == keaProcName || name == namedProcName || keaDaemonProcNames[name]
+}
+
+// Returns the inventory of the running Kea and BIND 9 daemons ordered by
+// the process names and PIDs.
+func (c *inventoryCollector) collect() []*daemonInventory {
+	processes, err := process.Processes()
+	if err != nil {
+		log.WithError(err).Warn("Cannot list the processes for the inventory")
+		return nil
+	}
+	var inventory []*daemonInventory
+	for _, p := range processes {
+		name, _ := p.Name()
+		if !isInventoriedProcess(name) {
+			continue
+		}
+		inventory = append(inventory, c.collectForProcess(p, name))
+	}
+	sort.Slice(inventory, func(i, j int) bool {
+		if inventory[i].Name != inventory[j].Name {
+			return inventory[i].Name < inventory[j].Name
+		}
+		return inventory[i].Pid < inventory[j].Pid
+	})
+	return inventory
+}
+
+// Gathers the inventory of a single daemon process. The facts which cannot
+// be determined are left empty.
+func (c *inventoryCollector) collectForProcess(p *process.Process, name string) *daemonInventory {
+	inventory := &daemonInventory{
+		Name: name,
+		Pid:  p.Pid,
+	}
+
+	if executable, err := p.Exe(); err == nil {
+		inventory.Executable = executable
+		inventory.Package = c.lookupPackage(executable)
+	}
+
+	if cgroup, err := os.ReadFile(path.Join(c.procDirectory, strconv.Itoa(int(p.Pid)), "cgroup")); err == nil {
+		inventory.SystemdUnit = parseSystemdUnit(string(cgroup))
+		if inventory.SystemdUnit != "" {
+			inventory.SystemdUnitState = c.getSystemdUnitState(inventory.SystemdUnit)
+		}
+	}
+
+	var filePaths []string
+	if args, err := p.CmdlineSlice(); err == nil {
+		cwd, _ := p.Cwd()
+		if configPath := findConfigPath(args, cwd); configPath != "" {
+			filePaths = append(filePaths, configPath)
+		}
+	}
+
+	for _, kind := range []string{"inet", "unix"} {
+		connections, err := psnet.ConnectionsPid(kind, p.Pid)
+		if err != nil {
+			continue
+		}
+		if kind == "unix" {
+			c.setUnixSocketsStatus(p.Pid, connections)
+		}
+		for _, connection := range connections {
+			socket, ok := formatListeningSocket(connection)
+			if !ok {
+				continue
+			}
+			inventory.ListeningSockets = append(inventory.ListeningSockets, socket)
+			if connection.Family == syscall.AF_UNIX {
+				filePaths = append(filePaths, connection.Laddr.IP)
+			}
+		}
+	}
+	sort.Strings(inventory.ListeningSockets)
+
+	for _, filePath := range filePaths {
+		inventory.Files = append(inventory.Files, inspectFile(filePath))
+	}
+	return inventory
+}
+
+// Returns the name of the package providing the executable. It supports
+// the dpkg, rpm and apk package managers. It returns an empty string if
+// the executable doesn't belong to any package.
+func (c *inventoryCollector) lookupPackage(executable string) string {
+	c.packagesMutex.Lock()
+	defer c.packagesMutex.Unlock()
+	if pkg, ok := c.packages[executable]; ok {
+		return pkg
+	}
+
+	pkg := ""
+	for _, tool := range []string{"dpkg", "rpm", "apk"} {
+		if _, err := c.executor.LookPath(tool); err != nil {
+			continue
+		}
+		var args []string
+		switch tool {
+		case "dpkg":
+			args = []string{"-S", executable}
+		case "rpm":
+			args = []string{"-qf", executable}
+		default:
+			args = []string{"info", "--who-owns", executable}
+		}
+		output, err := c.executor.Output(tool, args...)
+		if err != nil {
+			continue
+		}
+		if pkg = parsePackageOwner(tool, string(output)); pkg != "" {
+			break
+		}
+	}
+	c.packages[executable] = pkg
+	return pkg
+}
+
+// Returns the output of the systemctl is-enabled command for the unit,
+// e.g., enabled or disabled. The command exits with an error for the
+// disabled units, so its output is used regardless of the exit status.
+// The state is cached for the systemdUnitStateCacheLifetime.
+func (c *inventoryCollector) getSystemdUnitState(unit string) string {
+	c.unitStatesMutex.Lock()
+	defer c.unitStatesMutex.Unlock()
+	if cached, ok := c.unitStates[unit]; ok && time.Since(cached.checkedAt) < systemdUnitStateCacheLifetime {
+		return cached.state
+	}
+
+	state := ""
+	if _, err := c.executor.LookPath("systemctl"); err == nil {
+		output, _ := c.executor.Output("systemctl", "is-enabled", unit)
+		state = strings.TrimSpace(string(output))
+	}
+	c.unitStates[unit] = systemdUnitState{
+		state:     state,
+		checkedAt: time.Now(),
+	}
+	return state
+}
+
+// Extracts the name of the systemd service from the contents of the
+// process cgroup file. It returns an empty string if the process doesn't
+// run in a systemd service.
+func parseSystemdUnit(cgroup string) string {
+	for _, line := range strings.Split(cgroup, "\n") {
+		// The line format is hierarchy-ID:controllers:path.
+		fields := strings.SplitN(line, ":", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		for _, element := range strings.Split(fields[2], "/") {
+			if strings.HasSuffix(element, ".service") {
+				return element
+			}
+		}
+	}
+	return ""
+}
+
+// Extracts the package name from the output of the package manager
+// querying the owner of a file.
+func parsePackageOwner(tool, output string) string {
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return ""
+	}
+	line := strings.Split(output, "\n")[0]
+	switch tool {
+	case "dpkg":
+		// isc-kea-dhcp4-server: /usr/sbin/kea-dhcp4
+		if name, _, found := strings.Cut(line, ":"); found {
+			return strings.TrimSpace(name)
+		}
+	case "rpm":
+		// isc-kea-2.2.0-isc20220726133113.el8.x86_64
+		if !strings.Contains(line, " ") {
+			return line
+		}
+	case "apk":
+		// /usr/sbin/kea-dhcp4 is owned by isc-kea-dhcp4-2.2.0-r0
+		if _, name, found := strings.Cut(line, " is owned by "); found {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// Returns the path to the configuration file specified with the -c switch
+// in the command line. The relative path is resolved using the working
+// directory of the process.
+func findConfigPath(args []string, cwd string) string {
+	for i, arg := range args {
+		if arg == "-c" && i+1 < len(args) {
+			configPath := args[i+1]
+			if !filepath.IsAbs(configPath) && cwd != "" {
+				configPath = filepath.Join(cwd, configPath)
+			}
+			return configPath
+		}
+	}
+	return ""
+}
+
+// The flag of the unix domain socket accepting the connections
+// (__SO_ACCEPTCON) in the /proc/net/unix file.
+const unixSocketAcceptConFlag = 0x10000
+
+// Sets the LISTEN status of the process's unix domain sockets accepting the
+// connections. gopsutil doesn't report the status of these sockets, so the
+// socket inodes are looked up in the process's file descriptors and matched
+// with the listening sockets from the /proc/<pid>/net/unix file.
+func (c *inventoryCollector) setUnixSocketsStatus(pid int32, connections []psnet.ConnectionStat) {
+	processDirectory := path.Join(c.procDirectory, strconv.Itoa(int(pid)))
+	contents, err := os.ReadFile(path.Join(processDirectory, "net", "unix"))
+	if err != nil {
+		return
+	}
+	listeningInodes := parseListeningUnixSocketInodes(string(contents))
+	for i := range connections {
+		link, err := os.Readlink(path.Join(processDirectory, "fd", strconv.FormatUint(uint64(connections[i].Fd), 10)))
+		if err != nil {
+			continue
+		}
+		inode := strings.TrimSuffix(strings.TrimPrefix(link, "socket:["), "]")
+		if listeningInodes[inode] {
+			connections[i].Status = "LISTEN"
+		}
+	}
+}
+
+// Parses the contents of the /proc/net/unix file and returns the inodes of
+// the unix domain sockets in the LISTEN state.
+func parseListeningUnixSocketInodes(contents string) map[string]bool {
+	inodes := make(map[string]bool)
+	lines := strings.Split(contents, "\n")
+	// The first line is a header:
+	// Num RefCount Protocol Flags Type St Inode Path
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 7 {
+			continue
+		}
+		flags, err := strconv.ParseUint(fields[3], 16, 32)
+		if err != nil || flags&unixSocketAcceptConFlag == 0 {
+			continue
+		}
+		inodes[fields[6]] = true
+	}
+	return inodes
+}
+
+// Formats the listening socket as a protocol followed by the local address,
+// e.g., tcp 127.0.0.1:8000. The second returned value is false if the
+// socket doesn't listen for the connections or datagrams.
+func formatListeningSocket(connection psnet.ConnectionStat) (string, bool) {
+	if connection.Family == syscall.AF_UNIX {
+		if connection.Status != "LISTEN" || connection.Laddr.IP == "" {
+			return "", false
+		}
+		return fmt.Sprintf("unix %s", connection.Laddr.IP), true
+	}
+	address := net.JoinHostPort(connection.Laddr.IP, strconv.FormatUint(uint64(connection.Laddr.Port), 10))
+	switch connection.Type {
+	case syscall.SOCK_STREAM:
+		if connection.Status != "LISTEN" {
+			return "", false
+		}
+		return fmt.Sprintf("tcp %s", address), true
+	case syscall.SOCK_DGRAM:
+		if connection.Raddr.Port != 0 {
+			return "", false
+		}
+		return fmt.Sprintf("udp %s", address), true
+	default:
+		return "", false
+	}
+}
+
+// Returns the permissions and the ownership of the file.
+func inspectFile(filePath string) fileInventory {
+	inventory := fileInventory{
+		Path: filePath,
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		inventory.Error = err.Error()
+		return inventory
+	}
+	inventory.Mode = info.Mode().String()
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		uid := strconv.FormatUint(uint64(stat.Uid), 10)
+		inventory.Owner = uid
+		if u, err := user.LookupId(uid); err == nil {
+			inventory.Owner = u.Username
+		}
+		gid := strconv.FormatUint(uint64(stat.Gid), 10)
+		inventory.Group = gid
+		if g, err := user.LookupGroupId(gid); err == nil {
+			inventory.Group = g.Name
+		}
+	}
+	return inventory
+}
@@ -0,0 +1,237 @@
+package agent
+
+import (
+	"os"
+	"path"
+	"syscall"
+	"testing"
+
+	"github.com/pkg/errors"
+	psnet "github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/testutil"
+)
+
+// Command executor returning the predefined outputs of the commands. It
+// counts the executed commands.
+type inventoryTestExecutor struct {
+	outputs map[string]string
+	calls   int
+}
+
+// Returns the predefined output of the command or an error if there is
+// no output for the command.
+func (e *inventoryTestExecutor) Output(command string, args ...string) ([]byte, error) {
+	e.calls++
+	output, ok := e.outputs[command]
+	if !ok {
+		return nil, errors.New("command failed")
+	}
+	return []byte(output), nil
+}
+
+// Returns the path to the command if there is a predefined output for it.
+func (e *inventoryTestExecutor) LookPath(command string) (string, error) {
+	if _, ok := e.outputs[command]; ok {
+		return "/usr/bin/" + command, nil
+	}
+	return "", errors.New("command not found")
+}
+
+// Checks if the file exists.
+func (e *inventoryTestExecutor) IsFileExist(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// Test that the systemd service is extracted from the cgroup file.
+func TestParseSystemdUnit(t *testing.T) {
+	require.Equal(t, "isc-kea-dhcp4-server.service",
+		parseSystemdUnit("0::/system.slice/isc-kea-dhcp4-server.service\n"))
+	require.Equal(t, "named.service",
+		parseSystemdUnit("12:pids:/system.slice/named.service\n1:name=systemd:/system.slice/named.service\n"))
+	require.Empty(t, parseSystemdUnit("0::/user.slice/user-1000.slice/session-2.scope\n"))
+	require.Empty(t, parseSystemdUnit(""))
+}
+
+// Test that the package names are extracted from the package managers'
+// outputs.
+func TestParsePackageOwner(t *testing.T) {
+	require.Equal(t, "isc-kea-dhcp4-server", parsePackageOwner("dpkg", "isc-kea-dhcp4-server: /usr/sbin/kea-dhcp4\n"))
+	require.Equal(t, "isc-kea-2.2.0-1.el8.x86_64", parsePackageOwner("rpm", "isc-kea-2.2.0-1.el8.x86_64\n"))
+	require.Equal(t, "isc-kea-dhcp4-2.2.0-r0", parsePackageOwner("apk", "/usr/sbin/kea-dhcp4 is owned by isc-kea-dhcp4-2.2.0-r0\n"))
+	require.Empty(t, parsePackageOwner("rpm", "file /usr/sbin/kea-dhcp4 is not owned by any package"))
+	require.Empty(t, parsePackageOwner("dpkg", ""))
+}
+
+// Test that the configuration path is found in the command line.
+func TestFindConfigPath(t *testing.T) {
+	require.Equal(t, "/etc/kea/kea-dhcp4.conf",
+		findConfigPath([]string{"kea-dhcp4", "-c", "/etc/kea/kea-dhcp4.conf"}, "/root"))
+	require.Equal(t, "/etc/kea/kea-dhcp4.conf",
+		findConfigPath([]string{"kea-dhcp4", "-c", "kea-dhcp4.conf"}, "/etc/kea"))
+	require.Empty(t, findConfigPath([]string{"named", "-u", "bind"}, "/"))
+	require.Empty(t, findConfigPath([]string{"named", "-c"}, "/"))
+}
+
+// Test that only the listening sockets are formatted.
+func TestFormatListeningSocket(t *testing.T) {
+	socket, ok := formatListeningSocket(psnet.ConnectionStat{
+		Family: syscall.AF_INET,
+		Type:   syscall.SOCK_STREAM,
+		Laddr:  psnet.Addr{IP: "127.0.0.1", Port: 8000},
+		Status: "LISTEN",
+	})
+	require.True(t, ok)
+	require.Equal(t, "tcp 127.0.0.1:8000", socket)
+
+	_, ok = formatListeningSocket(psnet.ConnectionStat{
+		Family: syscall.AF_INET,
+		Type:   syscall.SOCK_STREAM,
+		Laddr:  psnet.Addr{IP: "127.0.0.1", Port: 8000},
+		Raddr:  psnet.Addr{IP: "127.0.0.1", Port: 41000},
+		Status: "ESTABLISHED",
+	})
+	require.False(t, ok)
+
+	socket, ok = formatListeningSocket(psnet.ConnectionStat{
+		Family: syscall.AF_INET6,
+		Type:   syscall.SOCK_DGRAM,
+		Laddr:  psnet.Addr{IP: "::", Port: 547},
+	})
+	require.True(t, ok)
+	require.Equal(t, "udp [::]:547", socket)
+
+	socket, ok = formatListeningSocket(psnet.ConnectionStat{
+		Family: syscall.AF_UNIX,
+		Type:   syscall.SOCK_STREAM,
+		Laddr:  psnet.Addr{IP: "/run/kea/kea4-ctrl-socket"},
+		Status: "LISTEN",
+	})
+	require.True(t, ok)
+	require.Equal(t, "unix /run/kea/kea4-ctrl-socket", socket)
+
+	_, ok = formatListeningSocket(psnet.ConnectionStat{
+		Family: syscall.AF_UNIX,
+		Type:   syscall.SOCK_STREAM,
+		Laddr:  psnet.Addr{IP: "/run/kea/kea4-ctrl-socket"},
+		Status: "NONE",
+	})
+	require.False(t, ok)
+
+	_, ok = formatListeningSocket(psnet.ConnectionStat{
+		Family: syscall.AF_UNIX,
+		Type:   syscall.SOCK_STREAM,
+	})
+	require.False(t, ok)
+}
+
+// Test that the listening unix domain sockets are found in the
+// /proc/net/unix contents.
+func TestParseListeningUnixSocketInodes(t *testing.T) {
+	contents := `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 21386 /run/kea/kea4-ctrl-socket
+0000000000000000: 00000003 00000000 00000000 0001 03 21401 /run/kea/kea4-ctrl-socket
+0000000000000000: 00000002 00000000 00000000 0002 01 21390
+`
+
+	inodes := parseListeningUnixSocketInodes(contents)
+
+	require.Equal(t, map[string]bool{"21386": true}, inodes)
+}
+
+// Test that only the unix domain sockets accepting the connections get
+// the LISTEN status.
+func TestSetUnixSocketsStatus(t *testing.T) {
+	// Arrange
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	_, _ = sb.Write("42/net/unix", `Num       RefCount Protocol Flags    Type St Inode Path
+0000000000000000: 00000002 00000000 00010000 0001 01 21386 /run/kea/kea4-ctrl-socket
+0000000000000000: 00000003 00000000 00000000 0001 03 21401 /run/kea/kea4-ctrl-socket
+`)
+	fdDirectory, _ := sb.JoinDir("42/fd")
+	require.NoError(t, os.Symlink("socket:[21386]", path.Join(fdDirectory, "3")))
+	require.NoError(t, os.Symlink("socket:[21401]", path.Join(fdDirectory, "4")))
+
+	collector := newInventoryCollector(nil)
+	collector.procDirectory = sb.BasePath
+
+	connections := []psnet.ConnectionStat{
+		{Fd: 3, Family: syscall.AF_UNIX, Laddr: psnet.Addr{IP: "/run/kea/kea4-ctrl-socket"}, Status: "NONE"},
+		{Fd: 4, Family: syscall.AF_UNIX, Laddr: psnet.Addr{IP: "/run/kea/kea4-ctrl-socket"}, Status: "NONE"},
+	}
+
+	// Act
+	collector.setUnixSocketsStatus(42, connections)
+
+	// Assert
+	require.Equal(t, "LISTEN", connections[0].Status)
+	require.Equal(t, "NONE", connections[1].Status)
+}
+
+// Test that the permissions of the file are inspected.
+func TestInspectFile(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	filePath, _ := sb.Write("kea-dhcp4.conf", "{}")
+	require.NoError(t, os.Chmod(filePath, 0o640))
+
+	inventory := inspectFile(filePath)
+	require.Equal(t, filePath, inventory.Path)
+	require.Equal(t, "-rw-r-----", inventory.Mode)
+	require.NotEmpty(t, inventory.Owner)
+	require.NotEmpty(t, inventory.Group)
+	require.Empty(t, inventory.Error)
+
+	inventory = inspectFile(path.Join(sb.BasePath, "missing.conf"))
+	require.Empty(t, inventory.Mode)
+	require.NotEmpty(t, inventory.Error)
+}
+
+// Test that the package providing the executable is looked up once.
+func TestLookupPackage(t *testing.T) {
+	executor := &inventoryTestExecutor{
+		outputs: map[string]string{
+			"dpkg": "isc-kea-dhcp4-server: /usr/sbin/kea-dhcp4\n",
+		},
+	}
+	collector := newInventoryCollector(executor)
+
+	require.Equal(t, "isc-kea-dhcp4-server", collector.lookupPackage("/usr/sbin/kea-dhcp4"))
+	require.Equal(t, "isc-kea-dhcp4-server", collector.lookupPackage("/usr/sbin/kea-dhcp4"))
+	require.Equal(t, 1, executor.calls)
+}
+
+// Test that the package is not found when no package manager is available.
+func TestLookupPackageNoPackageManager(t *testing.T) {
+	collector := newInventoryCollector(&inventoryTestExecutor{})
+	require.Empty(t, collector.lookupPackage("/usr/sbin/kea-dhcp4"))
+}
+
+// Test that the state of the systemd unit is returned and cached.
+func TestGetSystemdUnitState(t *testing.T) {
+	executor := &inventoryTestExecutor{
+		outputs: map[string]string{
+			"systemctl": "enabled\n",
+		},
+	}
+	collector := newInventoryCollector(executor)
+	require.Equal(t, "enabled", collector.getSystemdUnitState("isc-kea-dhcp4-server.service"))
+	require.Equal(t, "enabled", collector.getSystemdUnitState("isc-kea-dhcp4-server.service"))
+	require.Equal(t, 1, executor.calls)
+
+	// The state is checked again when the cached one is outdated.
+	executor.outputs["systemctl"] = "disabled\n"
+	cached := collector.unitStates["isc-kea-dhcp4-server.service"]
+	cached.checkedAt = cached.checkedAt.Add(-systemdUnitStateCacheLifetime)
+	collector.unitStates["isc-kea-dhcp4-server.service"] = cached
+	require.Equal(t, "disabled", collector.getSystemdUnitState("isc-kea-dhcp4-server.service"))
+	require.Equal(t, 2, executor.calls)
+
+	collector = newInventoryCollector(&inventoryTestExecutor{})
+	require.Empty(t, collector.getSystemdUnitState("isc-kea-dhcp4-server.service"))
+}
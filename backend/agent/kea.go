@@ -19,6 +19,10 @@ type KeaApp struct {
 	BaseApp
 	HTTPClient        *HTTPClient // to communicate with Kea Control Agent
 	ConfiguredDaemons []string
+	// Paths to the control sockets of the daemons behind the Kea Control
+	// Agent. They are used to exclude these daemons from the detection of
+	// the daemons controlled directly over the control sockets.
+	controlSocketPaths []string
 }
 
 // Get base information about Kea app.
@@ -26,31 +30,66 @@ func (ka *KeaApp) GetBaseApp() *BaseApp {
 	return &ka.BaseApp
 }
 
+// Returns true if the app is controlled directly over the daemon's control
+// socket rather than through the Kea Control Agent.
+func (ka *KeaApp) usesControlSocket() bool {
+	return ka.BaseApp.AccessPoints[0].Type == AccessPointControlSocket
+}
+
+// Returns the access point used to send commands to the Kea app. It is
+// the Kea Control Agent's access point or the daemon's control socket
+// access point if the daemon runs without the Control Agent.
+func getKeaControlAccessPoint(app App) (*AccessPoint, error) {
+	ap, err := getAccessPoint(app, AccessPointControl)
+	if err != nil {
+		if socketAP, socketErr := getAccessPoint(app, AccessPointControlSocket); socketErr == nil {
+			return socketAP, nil
+		}
+	}
+	return ap, err
+}
+
+// Sends a raw command to Kea using the specified access point and returns
+// the raw response. If the access point is the Kea Control Agent's one, the
+// command is sent over HTTP. If it is a control socket access point, the
+// command is sent directly to the Kea daemon. In both cases the response is
+// a list of responses like the one returned by the Kea Control Agent.
+func sendToKea(httpClient *HTTPClient, ap *AccessPoint, request []byte) ([]byte, error) {
+	if ap.Type == AccessPointControlSocket {
+		return NewUnixSocketClient().Call(ap.Address, request)
+	}
+
+	caURL := storkutil.HostWithPortURL(ap.Address, ap.Port, ap.UseSecureProtocol)
+	response, err := httpClient.Call(caURL, bytes.NewBuffer(request))
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to send command to Kea: %s", caURL)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to read Kea response body received from %s", caURL)
+	}
+	return body, nil
+}
+
 // Sends a command to Kea and returns a response.
 func (ka *KeaApp) sendCommand(command *keactrl.Command, responses interface{}) error {
 	ap := &ka.BaseApp.AccessPoints[0]
-	caURL := storkutil.HostWithPortURL(ap.Address, ap.Port, ap.UseSecureProtocol)
 
 	// Get the textual representation of the command.
 	request := command.Marshal()
 
 	// Send the command to the Kea server.
-	response, err := ka.HTTPClient.Call(caURL, bytes.NewBuffer([]byte(request)))
+	body, err := sendToKea(ka.HTTPClient, ap, []byte(request))
 	if err != nil {
-		return errors.WithMessagef(err, "failed to send command to Kea: %s", caURL)
-	}
-
-	// Read the response.
-	body, err := io.ReadAll(response.Body)
-	response.Body.Close()
-	if err != nil {
-		return errors.WithMessagef(err, "failed to read Kea response body received from %s", caURL)
+		return err
 	}
 
 	// Parse the response.
 	err = keactrl.UnmarshalResponseList(command, body, responses)
 	if err != nil {
-		return errors.WithMessagef(err, "failed to parse Kea response body received from %s", caURL)
+		return errors.WithMessagef(err, "failed to parse Kea response body received from %s", ap.Address)
 	}
 	return nil
 }
@@ -131,6 +170,12 @@ func (ka *KeaApp) DetectAllowedLogs() ([]string, error) {
 	// Allow the log files used by the CA.
 	paths := collectKeaAllowedLogs(&responses[0])
 
+	// The daemon controlled over the control socket has been queried
+	// directly. There are no other daemons behind it.
+	if ka.usesControlSocket() {
+		return paths, nil
+	}
+
 	// Arguments should be returned in response to the config-get command.
 	rawConfig := responses[0].Arguments
 	if rawConfig == nil {
@@ -203,12 +248,8 @@ func detectKeaApp(match []string, cwd string, httpClient *HTTPClient) App {
 		log.Warnf("Problem parsing Kea cmdline: %s", match[0])
 		return nil
 	}
-	keaConfPath := match[2]
-
 	// if path to config is not absolute then join it with CWD of kea
-	if !strings.HasPrefix(keaConfPath, "/") {
-		keaConfPath = path.Join(cwd, keaConfPath)
-	}
+	keaConfPath := resolveKeaPath(match[2], cwd)
 
 	config, err := readKeaConfig(keaConfPath)
 	if err != nil {
@@ -234,13 +275,83 @@ func detectKeaApp(match []string, cwd string, httpClient *HTTPClient) App {
 			UseSecureProtocol: config.UseSecureProtocol(),
 		},
 	}
+	var controlSocketPaths []string
+	if sockets := config.GetControlSockets(); sockets != nil {
+		for _, socket := range []*keaconfig.ControlSocket{sockets.D2, sockets.Dhcp4, sockets.Dhcp6} {
+			if socket != nil && socket.SocketName != "" {
+				controlSocketPaths = append(controlSocketPaths, resolveKeaPath(socket.SocketName, cwd))
+			}
+		}
+	}
+
 	keaApp := &KeaApp{
 		BaseApp: BaseApp{
 			Type:         AppTypeKea,
 			AccessPoints: accessPoints,
 		},
+		HTTPClient:         httpClient,
+		ConfiguredDaemons:  config.GetControlSockets().GetConfiguredDaemonNames(),
+		controlSocketPaths: controlSocketPaths,
+	}
+
+	return keaApp
+}
+
+// Returns the path joined with the current working directory of the Kea
+// process if the path is relative.
+func resolveKeaPath(filePath, cwd string) string {
+	if !strings.HasPrefix(filePath, "/") {
+		return path.Join(cwd, filePath)
+	}
+	return filePath
+}
+
+// Detects the Kea daemon running without the Kea Control Agent. The daemon
+// is controlled directly over the UNIX domain socket specified in the
+// control-socket entry of its configuration. The daemons using the sockets
+// from the caSocketPaths set are handled by the Control Agents and they
+// are skipped.
+func detectKeaDaemonApp(match []string, cwd string, httpClient *HTTPClient, caSocketPaths map[string]bool) App {
+	if len(match) < 3 {
+		log.Warnf("Problem parsing Kea cmdline: %s", match[0])
+		return nil
+	}
+	keaConfPath := resolveKeaPath(match[2], cwd)
+
+	config, err := readKeaConfig(keaConfPath)
+	if err != nil {
+		log.WithError(err).Error("Invalid Kea daemon config")
+		return nil
+	}
+
+	socket := config.GetControlSocket()
+	if socket == nil || socket.SocketName == "" {
+		log.WithField("config", keaConfPath).
+			Warn("Kea daemon has no control socket configured; it cannot be monitored")
+		return nil
+	}
+	if socket.SocketType != "" && socket.SocketType != "unix" {
+		log.WithField("config", keaConfPath).
+			Warnf("Unsupported Kea control socket type: %s", socket.SocketType)
+		return nil
+	}
+	socketPath := resolveKeaPath(socket.SocketName, cwd)
+	if caSocketPaths[socketPath] {
+		return nil
+	}
+
+	keaApp := &KeaApp{
+		BaseApp: BaseApp{
+			Type: AppTypeKea,
+			AccessPoints: []AccessPoint{
+				{
+					Type:    AccessPointControlSocket,
+					Address: socketPath,
+				},
+			},
+		},
 		HTTPClient:        httpClient,
-		ConfiguredDaemons: config.GetControlSockets().GetConfiguredDaemonNames(),
+		ConfiguredDaemons: []string{config.GetDaemonName()},
 	}
 
 	return keaApp
@@ -0,0 +1,70 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	keactrl "isc.org/stork/appctrl/kea"
+)
+
+// Default timeout for the communication over the Kea control socket. It
+// covers connecting to the socket, sending the command and receiving the
+// complete response.
+const defaultKeaSocketTimeout = 30 * time.Second
+
+// A client sending commands directly to the Kea daemons over their UNIX
+// domain control sockets. It is used to communicate with the Kea daemons
+// running without the Kea Control Agent.
+type UnixSocketClient struct {
+	timeout time.Duration
+}
+
+// Creates a client communicating with the Kea daemons over the UNIX
+// domain sockets.
+func NewUnixSocketClient() *UnixSocketClient {
+	return &UnixSocketClient{
+		timeout: defaultKeaSocketTimeout,
+	}
+}
+
+// Sends a command to the Kea daemon over the specified control socket and
+// returns the response. The Kea daemons don't accept the service parameter,
+// so it is removed from the command before sending. The daemon returns a
+// single response object rather than a list of responses returned by the
+// Kea Control Agent. The response is wrapped in a single-element list to
+// make it indistinguishable from the Control Agent's response for the
+// callers.
+func (c *UnixSocketClient) Call(socketPath string, request []byte) ([]byte, error) {
+	command, err := keactrl.NewCommandFromJSON(string(request))
+	if err != nil {
+		return nil, err
+	}
+	command.Daemons = nil
+
+	conn, err := net.DialTimeout("unix", socketPath, c.timeout)
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem connecting to Kea control socket %s", socketPath)
+	}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, errors.Wrapf(err, "problem setting deadline for Kea control socket %s", socketPath)
+	}
+
+	if _, err = conn.Write([]byte(command.Marshal())); err != nil {
+		return nil, errors.Wrapf(err, "problem sending command to Kea control socket %s", socketPath)
+	}
+
+	// Kea doesn't terminate the response with any delimiter and it may
+	// send a large response in several chunks. Decoding a single JSON
+	// value is the only reliable way to find the end of the response.
+	var response json.RawMessage
+	if err = json.NewDecoder(conn).Decode(&response); err != nil {
+		return nil, errors.Wrapf(err, "problem reading response from Kea control socket %s", socketPath)
+	}
+
+	return []byte("[" + string(response) + "]"), nil
+}
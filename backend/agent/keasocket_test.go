@@ -0,0 +1,78 @@
+package agent
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/testutil"
+)
+
+// Starts a fake Kea daemon listening on the UNIX domain socket. It responds
+// with the specified response to the first received command and returns the
+// received command over the channel.
+func startFakeKeaSocket(t *testing.T, socketPath, response string) <-chan map[string]interface{} {
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan map[string]interface{}, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var command map[string]interface{}
+		if err := json.NewDecoder(conn).Decode(&command); err != nil {
+			return
+		}
+		received <- command
+		_, _ = conn.Write([]byte(response))
+	}()
+	return received
+}
+
+// Test that the command is sent to the Kea daemon over the control socket
+// and the response is wrapped in a list.
+func TestUnixSocketClientCall(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	socketPath, err := sb.Join("kea.sock")
+	require.NoError(t, err)
+
+	received := startFakeKeaSocket(t, socketPath, `{ "result": 0, "text": "ok" }`)
+
+	client := NewUnixSocketClient()
+	response, err := client.Call(socketPath, []byte(`{ "command": "config-get", "service": [ "dhcp4" ] }`))
+	require.NoError(t, err)
+	require.JSONEq(t, `[{ "result": 0, "text": "ok" }]`, string(response))
+
+	// The service parameter is not supported by the Kea daemons.
+	command := <-received
+	require.Equal(t, "config-get", command["command"])
+	require.NotContains(t, command, "service")
+}
+
+// Test that an error is returned when the control socket doesn't exist.
+func TestUnixSocketClientCallMissingSocket(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	socketPath, err := sb.Join("missing.sock")
+	require.NoError(t, err)
+
+	client := NewUnixSocketClient()
+	response, err := client.Call(socketPath, []byte(`{ "command": "config-get" }`))
+	require.Error(t, err)
+	require.Nil(t, response)
+}
+
+// Test that an error is returned for a malformed command.
+func TestUnixSocketClientCallMalformedCommand(t *testing.T) {
+	client := NewUnixSocketClient()
+	response, err := client.Call("/tmp/kea.sock", []byte(`{ "command": `))
+	require.Error(t, err)
+	require.Nil(t, response)
+}
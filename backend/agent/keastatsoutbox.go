@@ -0,0 +1,220 @@
+package agent
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+)
+
+// Default maximum number of the samples returned by the agent in a single
+// response.
+const defaultStatsSamplesLimit = 1000
+
+// Matches the names of the statistics used by the server to backfill the
+// missed samples: the sent responses counters used to calculate the RPS
+// and the subnet address and delegated prefix counters used to calculate
+// the utilization. Other statistics are not stored in the outbox.
+var outboxStatisticPattern = regexp.MustCompile(`^(pkt4-ack-sent|pkt6-reply-sent|subnet\[\d+\]\.(total|assigned|declined)-(addresses|nas|pds))$`)
+
+// Statistics outbox periodically samples the statistics of the Kea DHCP
+// daemons, independently of the Stork Server, and stores them in the
+// bounded on-disk ring buffer. The server fetches the samples it missed
+// while it was unable to communicate with the agent and backfills them in
+// its database.
+type KeaStatsOutbox struct {
+	AppMonitor AppMonitor
+	HTTPClient *HTTPClient
+	interval   time.Duration
+	buffer     *statsRingBuffer
+	ticker     *time.Ticker
+	done       chan bool
+	wg         *sync.WaitGroup
+}
+
+// Creates the statistics outbox using the settings specified in the command
+// line. It returns nil if sampling the statistics is disabled, i.e., the
+// sampling interval is zero.
+func NewKeaStatsOutbox(settings *cli.Context, appMonitor AppMonitor, httpClient *HTTPClient) (*KeaStatsOutbox, error) {
+	interval := settings.Int("stats-outbox-interval")
+	if interval <= 0 {
+		return nil, nil
+	}
+	buffer, err := newStatsRingBuffer(settings.String("stats-outbox-directory"), settings.Int("stats-outbox-capacity"))
+	if err != nil {
+		return nil, err
+	}
+	return &KeaStatsOutbox{
+		AppMonitor: appMonitor,
+		HTTPClient: httpClient,
+		interval:   time.Duration(interval) * time.Second,
+		buffer:     buffer,
+		done:       make(chan bool),
+		wg:         &sync.WaitGroup{},
+	}, nil
+}
+
+// Starts sampling the statistics in background.
+func (o *KeaStatsOutbox) Start() {
+	log.WithFields(log.Fields{
+		"directory": o.buffer.directory,
+		"interval":  o.interval,
+	}).Info("Starting Kea statistics outbox")
+
+	o.ticker = time.NewTicker(o.interval)
+	o.wg.Add(1)
+	go o.samplingLoop()
+}
+
+// Stops sampling the statistics.
+func (o *KeaStatsOutbox) Shutdown() {
+	if o.ticker == nil {
+		return
+	}
+	log.Info("Stopping Kea statistics outbox")
+	o.ticker.Stop()
+	o.done <- true
+	o.wg.Wait()
+	o.ticker = nil
+	log.Info("Stopped Kea statistics outbox")
+}
+
+// Samples the statistics every interval until the outbox is shut down.
+func (o *KeaStatsOutbox) samplingLoop() {
+	defer o.wg.Done()
+	for {
+		select {
+		case <-o.ticker.C:
+			if err := o.sampleStats(); err != nil {
+				log.WithError(err).Warn("Some errors were encountered while sampling stats from Kea")
+			}
+		case <-o.done:
+			return
+		}
+	}
+}
+
+// Sends the statistic-get-all command to the DHCP daemons of all detected
+// Kea apps and stores their responses in the ring buffer. Each daemon's
+// response is stored as a separate sample. It returns the last encountered
+// error.
+func (o *KeaStatsOutbox) sampleStats() error {
+	var lastErr error
+	for _, app := range o.AppMonitor.GetApps() {
+		if app.GetBaseApp().Type != AppTypeKea {
+			continue
+		}
+
+		var services []string
+		for _, daemon := range app.GetConfiguredDaemons() {
+			if daemon == "dhcp4" || daemon == "dhcp6" {
+				services = append(services, daemon)
+			}
+		}
+		if len(services) == 0 {
+			continue
+		}
+
+		ctrl, err := getKeaControlAccessPoint(app)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		request, err := json.Marshal(map[string]any{
+			"command":   "statistic-get-all",
+			"service":   services,
+			"arguments": map[string]any{},
+		})
+		if err != nil {
+			lastErr = errors.Wrap(err, "cannot serialize a request to JSON")
+			continue
+		}
+
+		sampledAt := time.Now().UTC()
+		response, err := sendToKea(o.HTTPClient, ctrl, request)
+		if err != nil {
+			lastErr = errors.WithMessage(err, "problem sampling stats from Kea")
+			continue
+		}
+
+		statistics, err := parseStatisticGetAllResponse(response)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// The responses are matched with the daemons by their positions.
+		for i, daemonStatistics := range statistics {
+			if i >= len(services) || daemonStatistics == nil {
+				continue
+			}
+			err = o.buffer.append(&statsSample{
+				SampledAt:  sampledAt,
+				Address:    ctrl.Address,
+				Port:       ctrl.Port,
+				Daemon:     services[i],
+				Statistics: daemonStatistics,
+			})
+			if err != nil {
+				lastErr = err
+			}
+		}
+	}
+	return lastErr
+}
+
+// Returns the samples of the Kea app with the specified control access point
+// address and port, appended after the sample with the specified sequence
+// number. The second returned value is the sequence number of the last
+// sample in the outbox.
+func (o *KeaStatsOutbox) getSamples(address string, port int64, sequence uint64, limit int) ([]*statsSample, uint64, error) {
+	if limit <= 0 {
+		limit = defaultStatsSamplesLimit
+	}
+	return o.buffer.readSince(sequence, limit, func(sample *statsSample) bool {
+		return sample.Address == address && sample.Port == port
+	})
+}
+
+// Parses the response to the statistic-get-all command returned by the Kea
+// daemons. It returns the most recent values of the statistics used by the
+// server returned by each daemon, in the order of the daemons' responses. The statistics are
+// nil for a daemon returning an error. The numbers are preserved without
+// conversion to floating point to not lose precision of big counters.
+func parseStatisticGetAllResponse(response []byte) ([]map[string]json.Number, error) {
+	var rawResponses []struct {
+		Result    int
+		Text      string
+		Arguments map[string][][]any
+	}
+	decoder := json.NewDecoder(bytes.NewReader(response))
+	decoder.UseNumber()
+	if err := decoder.Decode(&rawResponses); err != nil {
+		return nil, errors.Wrap(err, "failed to parse statistic-get-all response from Kea")
+	}
+
+	statistics := make([]map[string]json.Number, len(rawResponses))
+	for i, rawResponse := range rawResponses {
+		if rawResponse.Result != 0 {
+			log.WithField("text", rawResponse.Text).Warn("Kea returned an error to statistic-get-all command")
+			continue
+		}
+		daemonStatistics := make(map[string]json.Number)
+		for name, samples := range rawResponse.Arguments {
+			if !outboxStatisticPattern.MatchString(name) || len(samples) == 0 || len(samples[0]) == 0 {
+				continue
+			}
+			if value, ok := samples[0][0].(json.Number); ok {
+				daemonStatistics[name] = value
+			}
+		}
+		statistics[i] = daemonStatistics
+	}
+	return statistics, nil
+}
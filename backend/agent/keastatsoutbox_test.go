@@ -0,0 +1,75 @@
+package agent
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/testutil"
+)
+
+// Test that the response to the statistic-get-all command is parsed, the
+// big counters are preserved without losing precision and the statistics
+// not used by the server are skipped.
+func TestParseStatisticGetAllResponse(t *testing.T) {
+	response := []byte(`[
+		{
+			"result": 0,
+			"arguments": {
+				"pkt4-ack-sent": [ [ 12, "2023-03-14 10:13:00.000000" ], [ 10, "2023-03-14 10:12:00.000000" ] ],
+				"subnet[1].total-addresses": [ [ 18446744073709551615, "2023-03-14 10:13:00.000000" ] ],
+				"subnet[1].assigned-addresses": [ [ 5, "2023-03-14 10:13:00.000000" ] ],
+				"subnet[1].pool[0].assigned-addresses": [ [ 5, "2023-03-14 10:13:00.000000" ] ],
+				"pkt4-discover-received": [ [ 20, "2023-03-14 10:13:00.000000" ] ],
+				"pkt4-nak-sent": [ ]
+			}
+		},
+		{
+			"result": 1,
+			"text": "unable to forward command to the dhcp6 service"
+		}
+	]`)
+
+	statistics, err := parseStatisticGetAllResponse(response)
+	require.NoError(t, err)
+	require.Len(t, statistics, 2)
+
+	require.Len(t, statistics[0], 3)
+	require.Equal(t, json.Number("12"), statistics[0]["pkt4-ack-sent"])
+	require.Equal(t, json.Number("18446744073709551615"), statistics[0]["subnet[1].total-addresses"])
+	require.Equal(t, json.Number("5"), statistics[0]["subnet[1].assigned-addresses"])
+	require.Nil(t, statistics[1])
+}
+
+// Test that an error is returned for the malformed statistic-get-all
+// response.
+func TestParseStatisticGetAllResponseMalformed(t *testing.T) {
+	statistics, err := parseStatisticGetAllResponse([]byte(`{"result": 0`))
+	require.Error(t, err)
+	require.Nil(t, statistics)
+}
+
+// Test that the samples are filtered by the control access point of the app.
+func TestKeaStatsOutboxGetSamples(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	buffer, err := newStatsRingBuffer(sb.BasePath, 100)
+	require.NoError(t, err)
+	outbox := &KeaStatsOutbox{buffer: buffer}
+
+	require.NoError(t, buffer.append(newTestStatsSample("192.0.2.1", 1)))
+	require.NoError(t, buffer.append(newTestStatsSample("192.0.2.2", 2)))
+	require.NoError(t, buffer.append(newTestStatsSample("192.0.2.1", 3)))
+
+	samples, lastSequence, err := outbox.getSamples("192.0.2.1", 8000, 1, 0)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, lastSequence)
+	require.Len(t, samples, 1)
+	require.EqualValues(t, 3, samples[0].Sequence)
+
+	samples, _, err = outbox.getSamples("192.0.2.1", 8001, 0, 0)
+	require.NoError(t, err)
+	require.Empty(t, samples)
+}
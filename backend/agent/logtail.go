@@ -2,26 +2,50 @@ package agent
 
 import (
 	"bufio"
+	"context"
 	"io"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
+// Default interval between the checks for new lines in the followed file.
+const defaultFollowInterval = 500 * time.Millisecond
+
+// Maximum number of lines sent in a single batch when following a file.
+const maxFollowBatchSize = 1000
+
+// Severity levels of the log messages ordered from the least to the most
+// severe. They cover the severities used by Kea and BIND 9.
+const (
+	logSeverityUnknown = iota - 1
+	logSeverityDebug
+	logSeverityInfo
+	logSeverityNotice
+	logSeverityWarning
+	logSeverityError
+	logSeverityFatal
+)
+
 // Log tailer provides means for viewing log files. It maintains the list of
 // unique files which can be viewed. If the file is not on the list of the allowed
 // files, an error is returned upon an attempt to view it.
 type logTailer struct {
-	allowedPaths map[string]bool
-	mutex        *sync.Mutex
+	allowedPaths   map[string]bool
+	mutex          *sync.Mutex
+	followInterval time.Duration
 }
 
 // Creates new instance of the log tailer.
 func newLogTailer() *logTailer {
 	lt := &logTailer{
-		allowedPaths: make(map[string]bool),
-		mutex:        new(sync.Mutex),
+		allowedPaths:   make(map[string]bool),
+		mutex:          new(sync.Mutex),
+		followInterval: defaultFollowInterval,
 	}
 	return lt
 }
@@ -87,3 +111,235 @@ func (lt *logTailer) tail(path string, offset int64) (lines []string, err error)
 	}
 	return lines, err
 }
+
+// Filter selecting the log lines matching the regular expression and
+// having at least the specified severity. The lines without the severity,
+// e.g., the subsequent lines of the multi-line messages, inherit the
+// severity of the preceding line.
+type logFilter struct {
+	pattern         *regexp.Regexp
+	minSeverity     int
+	severityPattern *regexp.Regexp
+	lastSeverity    int
+}
+
+// Converts the severity name to the severity level. It accepts the
+// severity names used by Kea and BIND 9.
+func parseLogSeverity(name string) (int, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return logSeverityDebug, nil
+	case "info":
+		return logSeverityInfo, nil
+	case "notice":
+		return logSeverityNotice, nil
+	case "warn", "warning":
+		return logSeverityWarning, nil
+	case "error":
+		return logSeverityError, nil
+	case "fatal", "critical":
+		return logSeverityFatal, nil
+	default:
+		return logSeverityUnknown, errors.Errorf("unknown log severity: %s", name)
+	}
+}
+
+// Creates a new log filter. The pattern is a regular expression the lines
+// must match. The severity is the minimum severity of the accepted lines.
+// Any of them can be empty to not filter by it.
+func newLogFilter(pattern, severity string) (*logFilter, error) {
+	filter := &logFilter{
+		minSeverity:  logSeverityUnknown,
+		lastSeverity: logSeverityUnknown,
+	}
+	if pattern != "" {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid log filter pattern: %s", pattern)
+		}
+		filter.pattern = re
+	}
+	if severity != "" {
+		minSeverity, err := parseLogSeverity(severity)
+		if err != nil {
+			return nil, err
+		}
+		filter.minSeverity = minSeverity
+		// Kea logs the severity in upper case followed by the logger name
+		// in square brackets. BIND 9 logs it in lower case followed by a
+		// colon, optionally with the debug level.
+		filter.severityPattern = regexp.MustCompile(
+			`(?:^|\s)(DEBUG|INFO|WARN|ERROR|FATAL)\s+\[|(?:^|\s)(debug|info|notice|warning|error|critical)(?: \d+)?:\s`,
+		)
+	}
+	return filter, nil
+}
+
+// Returns the severity of the log line or logSeverityUnknown if the line
+// doesn't contain it.
+func (f *logFilter) detectSeverity(line string) int {
+	match := f.severityPattern.FindStringSubmatch(line)
+	if match == nil {
+		return logSeverityUnknown
+	}
+	name := match[1]
+	if name == "" {
+		name = match[2]
+	}
+	severity, err := parseLogSeverity(name)
+	if err != nil {
+		return logSeverityUnknown
+	}
+	return severity
+}
+
+// Checks if the log line passes the filter. The nil filter accepts all
+// lines. The lines of unknown severity are rejected when the minimum
+// severity is specified.
+func (f *logFilter) accepts(line string) bool {
+	if f == nil {
+		return true
+	}
+	if f.severityPattern != nil {
+		if severity := f.detectSeverity(line); severity != logSeverityUnknown {
+			f.lastSeverity = severity
+		}
+		if f.lastSeverity < f.minSeverity {
+			return false
+		}
+	}
+	if f.pattern != nil && !f.pattern.MatchString(line) {
+		return false
+	}
+	return true
+}
+
+// Follows the specified log file like the tail -f command. The lines
+// following the offset relative to the end of the file are sent first.
+// Next, the lines appended to the file are sent as they arrive. The lines
+// are sent in batches using the send function. Only the lines accepted
+// by the filter are sent. The filter may be nil to send all lines. The
+// function detects the file rotation and truncation and continues reading
+// the new file contents. It returns when the context is canceled, the send
+// function returns an error or reading the file fails.
+func (lt *logTailer) follow(ctx context.Context, path string, offset int64, filter *logFilter, send func(lines []string) error) error {
+	// Check if it is allowed to follow this file.
+	if !lt.allowed(path) {
+		return errors.Errorf("access forbidden to the %s", path)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to open file for following: %s", path)
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	stat, err := f.Stat()
+	if err != nil {
+		return errors.WithMessagef(err, "failed to stat the file opened for following: %s", path)
+	}
+
+	// Can't go beyond the file size.
+	if offset > stat.Size() {
+		offset = stat.Size()
+	}
+	position, err := f.Seek(-offset, io.SeekEnd)
+	if err != nil {
+		return errors.WithMessagef(err, "failed to seek in the file opened for following: %s", path)
+	}
+
+	reader := bufio.NewReader(f)
+	// The last line may be incomplete when the writer hasn't finished
+	// writing it yet. Such a line is held until it is complete.
+	partial := ""
+
+	// Reads the complete lines available in the file and sends them.
+	readLines := func() error {
+		var lines []string
+		for {
+			chunk, err := reader.ReadString('\n')
+			position += int64(len(chunk))
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					return errors.WithMessagef(err, "failed to read the followed file: %s", path)
+				}
+				partial += chunk
+				break
+			}
+			line := strings.TrimRight(partial+chunk, "\r\n")
+			partial = ""
+			if !filter.accepts(line) {
+				continue
+			}
+			lines = append(lines, line)
+			if len(lines) == maxFollowBatchSize {
+				if err := send(lines); err != nil {
+					return err
+				}
+				lines = nil
+			}
+		}
+		if len(lines) > 0 {
+			return send(lines)
+		}
+		return nil
+	}
+
+	ticker := time.NewTicker(lt.followInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := readLines(); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		current, err := os.Stat(path)
+		if err != nil {
+			// The file may be temporarily missing during the rotation.
+			continue
+		}
+
+		switch {
+		case !os.SameFile(stat, current):
+			// The file has been rotated. Read the rest of the old file
+			// and switch to the new one.
+			if err := readLines(); err != nil {
+				return err
+			}
+			if partial != "" && filter.accepts(partial) {
+				if err := send([]string{partial}); err != nil {
+					return err
+				}
+			}
+			partial = ""
+			newFile, err := os.Open(path)
+			if err != nil {
+				// The new file may not be ready yet. Try again later.
+				continue
+			}
+			_ = f.Close()
+			f = newFile
+			if stat, err = f.Stat(); err != nil {
+				return errors.WithMessagef(err, "failed to stat the rotated file opened for following: %s", path)
+			}
+			reader.Reset(f)
+			position = 0
+		case current.Size() < position:
+			// The file has been truncated. Start reading from the beginning.
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return errors.WithMessagef(err, "failed to seek in the truncated file: %s", path)
+			}
+			reader.Reset(f)
+			position = 0
+			partial = ""
+		}
+	}
+}
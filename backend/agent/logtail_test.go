@@ -1,12 +1,16 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/testutil"
 )
 
 // Test that the new instance of the log tailer can be created and that
@@ -62,3 +66,198 @@ func TestTailNotExistingFile(t *testing.T) {
 	_, err := lt.tail("non-existing-file", 100)
 	require.Error(t, err)
 }
+
+// Starts following the specified file in the background. It returns the
+// channel receiving the lines sent by the follow function, the channel
+// receiving the follow result and the function canceling the following.
+func startFollowing(t *testing.T, lt *logTailer, path string, offset int64, filter *logFilter) (<-chan string, <-chan error, context.CancelFunc) {
+	lt.followInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	lines := make(chan string, 100)
+	result := make(chan error, 1)
+	go func() {
+		result <- lt.follow(ctx, path, offset, filter, func(batch []string) error {
+			for _, line := range batch {
+				lines <- line
+			}
+			return nil
+		})
+	}()
+	t.Cleanup(cancel)
+	return lines, result, cancel
+}
+
+// Waits for the next line sent by the follow function.
+func nextLine(t *testing.T, lines <-chan string) string {
+	select {
+	case line := <-lines:
+		return line
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout waiting for the followed line")
+		return ""
+	}
+}
+
+// Appends the contents to the specified file.
+func appendToFile(t *testing.T, path, contents string) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = f.WriteString(contents)
+	require.NoError(t, err)
+}
+
+// Test that the following the not allowed file results in an error.
+func TestFollowForbidden(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	path, _ := sb.Write("kea.log", "Some contents\n")
+
+	lt := newLogTailer()
+	err := lt.follow(context.Background(), path, 100, nil, func([]string) error { return nil })
+	require.ErrorContains(t, err, "access forbidden")
+}
+
+// Test that the lines appended to the followed file are sent, including
+// the lines appended in several writes.
+func TestFollow(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	path, _ := sb.Write("kea.log", "first\nsecond\n")
+
+	lt := newLogTailer()
+	lt.allow(path)
+	lines, result, cancel := startFollowing(t, lt, path, 7, nil)
+
+	// Only the line following the offset is initially sent.
+	require.Equal(t, "second", nextLine(t, lines))
+
+	appendToFile(t, path, "third\nfou")
+	require.Equal(t, "third", nextLine(t, lines))
+
+	// The incomplete line is sent when it is complete.
+	appendToFile(t, path, "rth\n")
+	require.Equal(t, "fourth", nextLine(t, lines))
+
+	cancel()
+	require.NoError(t, <-result)
+}
+
+// Test that the following continues after the file rotation.
+func TestFollowRotation(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	path, _ := sb.Write("kea.log", "first\n")
+
+	lt := newLogTailer()
+	lt.allow(path)
+	lines, result, cancel := startFollowing(t, lt, path, 100, nil)
+	require.Equal(t, "first", nextLine(t, lines))
+
+	appendToFile(t, path, "second\n")
+	require.NoError(t, os.Rename(path, path+".1"))
+	_, err := sb.Write("kea.log", "third\n")
+	require.NoError(t, err)
+
+	require.Equal(t, "second", nextLine(t, lines))
+	require.Equal(t, "third", nextLine(t, lines))
+
+	cancel()
+	require.NoError(t, <-result)
+}
+
+// Test that the following continues after the file truncation.
+func TestFollowTruncation(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	path, _ := sb.Write("kea.log", "first line\n")
+
+	lt := newLogTailer()
+	lt.allow(path)
+	lines, result, cancel := startFollowing(t, lt, path, 100, nil)
+	require.Equal(t, "first line", nextLine(t, lines))
+
+	require.NoError(t, os.Truncate(path, 0))
+	// Let the follow function notice the truncation.
+	time.Sleep(100 * time.Millisecond)
+	appendToFile(t, path, "second\n")
+	require.Equal(t, "second", nextLine(t, lines))
+
+	cancel()
+	require.NoError(t, <-result)
+}
+
+// Test that the followed lines are filtered.
+func TestFollowFilter(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	path, _ := sb.Write("kea.log", "2023-07-14 10:00:00.123 INFO  [kea-dhcp4.dhcp4/1.2] DHCP4_STARTED\n")
+
+	filter, err := newLogFilter("DHCP4", "warn")
+	require.NoError(t, err)
+
+	lt := newLogTailer()
+	lt.allow(path)
+	lines, result, cancel := startFollowing(t, lt, path, 1000, filter)
+
+	appendToFile(t, path, "2023-07-14 10:00:00.124 WARN  [kea-dhcp4.dhcpsrv/1.2] DHCPSRV_ISSUE\n")
+	appendToFile(t, path, "2023-07-14 10:00:00.125 ERROR [kea-dhcp4.dhcp4/1.2] DHCP4_ERROR\n")
+	require.Equal(t, "2023-07-14 10:00:00.125 ERROR [kea-dhcp4.dhcp4/1.2] DHCP4_ERROR", nextLine(t, lines))
+
+	cancel()
+	require.NoError(t, <-result)
+}
+
+// Test parsing the log severity names.
+func TestParseLogSeverity(t *testing.T) {
+	severity, err := parseLogSeverity("WARN")
+	require.NoError(t, err)
+	require.Equal(t, logSeverityWarning, severity)
+
+	severity, err = parseLogSeverity("warning")
+	require.NoError(t, err)
+	require.Equal(t, logSeverityWarning, severity)
+
+	severity, err = parseLogSeverity("critical")
+	require.NoError(t, err)
+	require.Equal(t, logSeverityFatal, severity)
+
+	_, err = parseLogSeverity("foo")
+	require.Error(t, err)
+}
+
+// Test that the log filter cannot be created for invalid parameters.
+func TestNewLogFilterInvalid(t *testing.T) {
+	_, err := newLogFilter("[", "")
+	require.Error(t, err)
+
+	_, err = newLogFilter("", "foo")
+	require.Error(t, err)
+}
+
+// Test that the log filter selects the lines by severity for Kea and
+// BIND 9 log formats.
+func TestLogFilterSeverity(t *testing.T) {
+	filter, err := newLogFilter("", "warning")
+	require.NoError(t, err)
+
+	// The line of unknown severity is rejected.
+	require.False(t, filter.accepts("continuation"))
+
+	require.False(t, filter.accepts("2023-07-14 10:00:00.123 DEBUG [kea-dhcp4.packets/1.2] DHCP4_PACKET"))
+	require.True(t, filter.accepts("2023-07-14 10:00:00.123 FATAL [kea-dhcp4.dhcp4/1.2] DHCP4_FAILED"))
+	require.False(t, filter.accepts("14-Jul-2023 10:00:00.000 general: info: running"))
+	require.False(t, filter.accepts("14-Jul-2023 10:00:00.000 debug 3: loading"))
+	require.True(t, filter.accepts("14-Jul-2023 10:00:00.000 general: error: failure"))
+
+	// The subsequent lines of the multi-line message inherit the severity.
+	require.True(t, filter.accepts("  continuation"))
+	require.False(t, filter.accepts("14-Jul-2023 10:00:00.000 notice: reloading"))
+	require.False(t, filter.accepts("  continuation"))
+}
+
+// Test that the nil filter accepts all lines.
+func TestNilLogFilter(t *testing.T) {
+	var filter *logFilter
+	require.True(t, filter.accepts("anything"))
+}
@@ -24,10 +24,14 @@ type AccessPoint struct {
 	Key               string
 }
 
-// Currently supported types are: "control" and "statistics".
+// Currently supported types are: "control", "control-socket" and
+// "statistics". The "control-socket" access point is used by the Kea
+// daemons running without the Kea Control Agent. Its address is the path
+// to the daemon's UNIX domain control socket and the port is zero.
 const (
-	AccessPointControl    = "control"
-	AccessPointStatistics = "statistics"
+	AccessPointControl       = "control"
+	AccessPointControlSocket = "control-socket"
+	AccessPointStatistics    = "statistics"
 )
 
 // Base application information. This structure is embedded
@@ -78,6 +82,14 @@ const (
 	namedProcName = "named"
 )
 
+// Names of the Kea daemons that are detected when they are running without
+// the Kea Control Agent.
+var keaDaemonProcNames = map[string]bool{ //nolint:gochecknoglobals
+	"kea-dhcp4":     true,
+	"kea-dhcp6":     true,
+	"kea-dhcp-ddns": true,
+}
+
 // Creates an AppMonitor instance. It used to start it as well, but this is now done
 // by a dedicated method Start(). Make sure you call Start() before using app monitor.
 func NewAppMonitor() AppMonitor {
@@ -177,7 +189,10 @@ func printNewOrUpdatedApps(newApps []App, oldApps []App) {
 		for _, app := range newUpdatedApps {
 			var acPts []string
 			for _, acPt := range app.GetBaseApp().AccessPoints {
-				url := storkutil.HostWithPortURL(acPt.Address, acPt.Port, acPt.UseSecureProtocol)
+				url := acPt.Address
+				if acPt.Type != AccessPointControlSocket {
+					url = storkutil.HostWithPortURL(acPt.Address, acPt.Port, acPt.UseSecureProtocol)
+				}
 				authKeyFoundStr := "not found"
 				if acPt.Key != "" {
 					authKeyFoundStr = "found"
@@ -196,24 +211,44 @@ func printNewOrUpdatedApps(newApps []App, oldApps []App) {
 }
 
 func (sm *appMonitor) detectApps(storkAgent *StorkAgent) {
+	// The agent may be nil when running some tests.
+	if storkAgent != nil {
+		defer storkAgent.Metrics.observeAppDetection(time.Now())
+	}
+
 	// Kea app is being detected by browsing list of processes in the system
 	// where cmdline of the process contains given pattern with kea-ctrl-agent
 	// substring. Such found processes are being processed further and all other
 	// Kea daemons are discovered and queried for their versions, etc.
 	keaPattern := regexp.MustCompile(`(.*?)kea-ctrl-agent\s+.*-c\s+(\S+)`)
+	// The Kea daemons running without the Kea Control Agent are detected
+	// using a similar pattern. They are controlled directly over the control
+	// sockets specified in their configurations.
+	keaDaemonPattern := regexp.MustCompile(`(.*?)kea-dhcp(?:4|6|-ddns)\s+.*-c\s+(\S+)`)
 	// BIND 9 app is being detecting by browsing list of processes in the system
 	// where cmdline of the process contains given pattern with named substring.
 	bind9Pattern := regexp.MustCompile(`(.*?)named\s+(.*)`)
 
 	var apps []App
 
+	// The Kea daemons are processed after all Control Agents are found
+	// because only the daemons not handled by any Control Agent are
+	// controlled directly over the control sockets.
+	type keaDaemonProcess struct {
+		pid   int32
+		match []string
+		cwd   string
+	}
+	var keaDaemonProcesses []keaDaemonProcess
+	caSocketPaths := make(map[string]bool)
+
 	processes, _ := process.Processes()
 	for _, p := range processes {
 		procName, _ := p.Name()
 		cmdline := ""
 		cwd := ""
 		var err error
-		if procName == keaProcName || procName == namedProcName {
+		if procName == keaProcName || procName == namedProcName || keaDaemonProcNames[procName] {
 			cmdline, err = p.Cmdline()
 			if err != nil {
 				log.WithError(err).Warnf("Cannot get process command line")
@@ -234,17 +269,29 @@ func (sm *appMonitor) detectApps(storkAgent *StorkAgent) {
 				if keaApp != nil {
 					keaApp.GetBaseApp().Pid = p.Pid
 					apps = append(apps, keaApp)
+					for _, socketPath := range keaApp.(*KeaApp).controlSocketPaths {
+						caSocketPaths[socketPath] = true
+					}
 				}
 			}
 			continue
 		}
 
+		if keaDaemonProcNames[procName] {
+			m := keaDaemonPattern.FindStringSubmatch(cmdline)
+			if m != nil {
+				keaDaemonProcesses = append(keaDaemonProcesses, keaDaemonProcess{p.Pid, m, cwd})
+			}
+			continue
+		}
+
 		if procName == namedProcName {
 			// detect bind9
 			m := bind9Pattern.FindStringSubmatch(cmdline)
 			if m != nil {
 				cmdr := storkutil.NewSystemCommandExecutor()
-				bind9App := detectBind9App(m, cwd, cmdr)
+				rootDir := getProcessRootDir("/proc", p.Pid)
+				bind9App := detectBind9App(m, cwd, rootDir, cmdr)
 				if bind9App != nil {
 					bind9App.GetBaseApp().Pid = p.Pid
 					apps = append(apps, bind9App)
@@ -254,6 +301,14 @@ func (sm *appMonitor) detectApps(storkAgent *StorkAgent) {
 		}
 	}
 
+	for _, p := range keaDaemonProcesses {
+		keaApp := detectKeaDaemonApp(p.match, p.cwd, storkAgent.HTTPClient, caSocketPaths)
+		if keaApp != nil {
+			keaApp.GetBaseApp().Pid = p.pid
+			apps = append(apps, keaApp)
+		}
+	}
+
 	// check changes in apps and print them
 	printNewOrUpdatedApps(apps, sm.apps)
 
@@ -325,7 +380,7 @@ func getAccessPoint(app App, accessType string) (*AccessPoint, error) {
 			continue
 		}
 
-		if point.Port == 0 {
+		if point.Port == 0 && point.Type != AccessPointControlSocket {
 			return nil, errors.Errorf("%s access point does not have port number", accessType)
 		} else if len(point.Address) == 0 {
 			return nil, errors.Errorf("%s access point does not have address", accessType)
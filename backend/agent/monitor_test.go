@@ -164,6 +164,14 @@ func TestDetectApps(t *testing.T) {
 	am.detectApps(sa)
 }
 
+// Test that detecting the apps doesn't panic when the agent is not set.
+func TestDetectAppsNilAgent(t *testing.T) {
+	am := &appMonitor{}
+	require.NotPanics(t, func() {
+		am.detectApps(nil)
+	})
+}
+
 // Test that detectAllowedLogs does not panic when Kea server is unreachable.
 func TestDetectAllowedLogsKeaUnreachable(t *testing.T) {
 	am := &appMonitor{}
@@ -213,7 +221,7 @@ func newTestCommandExecutorDefault() *testCommandExecutor {
 func TestDetectBind9AppAbsPath(t *testing.T) {
 	// check BIND 9 app detection
 	executor := newTestCommandExecutorDefault()
-	app := detectBind9App([]string{"", "/dir", "-c /etc/named.conf"}, "", executor)
+	app := detectBind9App([]string{"", "/dir", "-c /etc/named.conf"}, "", "", executor)
 	require.NotNil(t, app)
 	require.Equal(t, app.GetBaseApp().Type, AppTypeBind9)
 	require.Len(t, app.GetBaseApp().AccessPoints, 2)
@@ -232,7 +240,7 @@ func TestDetectBind9AppAbsPath(t *testing.T) {
 // Check BIND 9 app detection when its conf file is relative to CWD of its process.
 func TestDetectBind9AppRelativePath(t *testing.T) {
 	executor := newTestCommandExecutorDefault()
-	app := detectBind9App([]string{"", "/dir", "-c named.conf"}, "/etc", executor)
+	app := detectBind9App([]string{"", "/dir", "-c named.conf"}, "/etc", "", executor)
 	require.NotNil(t, app)
 	require.Equal(t, app.GetBaseApp().Type, AppTypeBind9)
 }
@@ -1,11 +1,9 @@
 package agent
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net"
 	"net/http"
 	"regexp"
@@ -20,8 +18,6 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
-
-	storkutil "isc.org/stork/util"
 )
 
 // Parsed subnet list from Kea `subnet4-list` and `subnet6-list` response.
@@ -111,8 +107,16 @@ type GetAllStatisticResponseItemValue struct {
 }
 
 // UnmarshalJSON implements json.Unmarshaler. It unpacks the Kea response
-// to simpler Go-friendly form.
+// to simpler Go-friendly form. It assumes that the first response comes
+// from the DHCPv4 server and the second one from the DHCPv6 server.
 func (r *GetAllStatisticsResponse) UnmarshalJSON(b []byte) error {
+	return r.unmarshalDaemonResponses(b, []string{"dhcp4", "dhcp6"})
+}
+
+// Unpacks the Kea response to simpler Go-friendly form. The responses are
+// matched with the daemons by their positions in the specified list of
+// the daemon names, i.e., the service list of the command.
+func (r *GetAllStatisticsResponse) unmarshalDaemonResponses(b []byte, daemons []string) error {
 	// Raw structures - corresponding to real received JSON.
 	type ResponseRawItem struct {
 		Result int64
@@ -151,15 +155,19 @@ func (r *GetAllStatisticsResponse) UnmarshalJSON(b []byte) error {
 			return errors.Errorf("response result from Kea != 0: %d", item.Result)
 		}
 
-		// daemon 0 is dhcp4, 1 is dhcp6
-		isDhcp4 := daemonIdx == 0
+		if daemonIdx >= len(daemons) {
+			return errors.Errorf("received %d responses from Kea to the command sent to %d daemons", len(obj), len(daemons))
+		}
 		var statMap map[string]GetAllStatisticResponseItemValue
-		if isDhcp4 {
+		switch daemons[daemonIdx] {
+		case "dhcp4":
 			r.Dhcp4 = make(map[string]GetAllStatisticResponseItemValue)
 			statMap = r.Dhcp4
-		} else {
+		case "dhcp6":
 			r.Dhcp6 = make(map[string]GetAllStatisticResponseItemValue)
 			statMap = r.Dhcp6
+		default:
+			return errors.Errorf("unsupported daemon %s in the statistics response from Kea", daemons[daemonIdx])
 		}
 
 		if item.Arguments == nil {
@@ -222,9 +230,10 @@ type subnetNameLookup interface {
 	setFamily(int8)
 }
 
-// An object that implements this interface can send requests to the Kea CA.
+// An object that implements this interface can send requests to Kea, either
+// to the Kea CA or directly to the daemon over its control socket.
 type keaCommandSender interface {
-	sendCommandToKeaCA(ctrl *AccessPoint, request string) ([]byte, error)
+	sendCommandToKea(ctrl *AccessPoint, request string) ([]byte, error)
 }
 
 // Subnet name lookup that fetches the subnet names only if necessary.
@@ -269,7 +278,7 @@ func (l *lazySubnetNameLookup) fetchAndCacheNames() SubnetList {
 		}`
 	}
 
-	response, err := l.sender.sendCommandToKeaCA(l.accessPoint, request)
+	response, err := l.sender.sendCommandToKea(l.accessPoint, request)
 	var target SubnetList
 	if err == nil {
 		err = json.Unmarshal(response, &target)
@@ -819,7 +828,7 @@ func (pke *PromKeaExporter) collectStats() error {
 		requestData["service"] = services
 
 		// get stats from kea
-		ctrl, err := getAccessPoint(app, AccessPointControl)
+		ctrl, err := getKeaControlAccessPoint(app)
 		if err != nil {
 			lastErr = err
 			log.WithError(err).Error("Problem getting stats from Kea: bad Kea access control point")
@@ -835,16 +844,17 @@ func (pke *PromKeaExporter) collectStats() error {
 		}
 
 		// Fetching statistics
-		responseData, err := pke.sendCommandToKeaCA(ctrl, string(requestDataBytes))
+		responseData, err := pke.sendCommandToKea(ctrl, string(requestDataBytes))
 		if err != nil {
 			lastErr = err
 			log.Errorf("Problem fetching stats from Kea: %+v", err)
 			continue
 		}
 
-		// Parse response
+		// Parse response. The responses are returned in the order of the
+		// queried daemons.
 		var response GetAllStatisticsResponse
-		err = json.Unmarshal(responseData, &response)
+		err = response.unmarshalDaemonResponses(responseData, services)
 		if err != nil {
 			lastErr = err
 			log.Errorf("Failed to parse responses from Kea: %s", err)
@@ -870,17 +880,13 @@ func (pke *PromKeaExporter) collectStats() error {
 	return lastErr
 }
 
-// Send any command to Kea CA and returns body content.
-func (pke *PromKeaExporter) sendCommandToKeaCA(ctrl *AccessPoint, request string) ([]byte, error) {
-	caURL := storkutil.HostWithPortURL(ctrl.Address, ctrl.Port, ctrl.UseSecureProtocol)
-	httpRsp, err := pke.HTTPClient.Call(caURL, bytes.NewBuffer([]byte(request)))
-	if err != nil {
-		return nil, errors.Wrap(err, "problem getting stats from Kea")
-	}
-	body, err := io.ReadAll(httpRsp.Body)
-	httpRsp.Body.Close()
+// Send any command to Kea and returns body content. The command is sent to
+// the Kea CA or directly to the daemon over the control socket, depending
+// on the access point type.
+func (pke *PromKeaExporter) sendCommandToKea(ctrl *AccessPoint, request string) ([]byte, error) {
+	body, err := sendToKea(pke.HTTPClient, ctrl, []byte(request))
 	if err != nil {
-		return nil, errors.Wrap(err, "problem reading stats response from Kea")
+		return nil, errors.WithMessage(err, "problem getting stats from Kea")
 	}
 	return body, nil
 }
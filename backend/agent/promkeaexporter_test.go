@@ -168,6 +168,36 @@ func TestUnmarshalKeaGetAllStatisticsResponse(t *testing.T) {
 	require.EqualValues(t, "2021-10-14 10:44:18.687243", *response.Dhcp4["reclaimed-leases"].Timestamp)
 }
 
+// Test that the Kea JSON get-all-stats response is matched with the queried
+// daemons by their names.
+func TestUnmarshalKeaGetAllStatisticsResponseDaemons(t *testing.T) {
+	// Arrange
+	rawResponse := `
+	[
+		{
+			"arguments": {
+				"subnet[1].total-nas": [ [100, "2021-10-14 10:44:18.687221"] ]
+			},
+			"result": 0
+		}
+	]`
+
+	// Act
+	var response GetAllStatisticsResponse
+	err := response.unmarshalDaemonResponses([]byte(rawResponse), []string{"dhcp6"})
+
+	// Assert
+	require.NoError(t, err)
+	require.Nil(t, response.Dhcp4)
+	require.NotNil(t, response.Dhcp6)
+	require.EqualValues(t, 100, response.Dhcp6["subnet[1].total-nas"].Value)
+
+	// The response from an unexpected daemon is rejected.
+	response = GetAllStatisticsResponse{}
+	err = response.unmarshalDaemonResponses([]byte(rawResponse), []string{})
+	require.Error(t, err)
+}
+
 // Test if the Kea JSON subnet4-list or subnet6-list response in unmarshal correctly.
 func TestUnmarshalSubnetListOKResponse(t *testing.T) {
 	// Arrange
@@ -331,7 +361,7 @@ func newFakeKeaCASender() *FakeKeaCASender {
 }
 
 // Increment call counter and return fixed data.
-func (s *FakeKeaCASender) sendCommandToKeaCA(ctrl *AccessPoint, request string) ([]byte, error) {
+func (s *FakeKeaCASender) sendCommandToKea(ctrl *AccessPoint, request string) ([]byte, error) {
 	s.callCount++
 	return s.payload, s.err
 }
@@ -0,0 +1,212 @@
+package agent
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// Namespace of the metrics describing the Stork Agent itself.
+const selfMetricsNamespace = "storkagent"
+
+// Reasons of the Kea errors counted by the agent.
+const (
+	// The agent could not communicate with Kea.
+	keaErrorReasonCommunication = "communication"
+	// Kea returned an error result for the forwarded command.
+	keaErrorReasonCommand = "command"
+)
+
+// Metrics describing the Stork Agent's own operation, i.e., the gRPC
+// requests received from the Stork Server, the errors returned by Kea, the
+// log viewer usage, the hook calls and the app detection. They are
+// registered in the dedicated registry, so they are exported separately
+// from the Kea and BIND 9 metrics. All methods are safe to call on a nil
+// instance; they do nothing then.
+type SelfMetrics struct {
+	Registry *prometheus.Registry
+
+	grpcRequests          *prometheus.CounterVec
+	grpcRequestDuration   *prometheus.HistogramVec
+	keaErrors             *prometheus.CounterVec
+	logTailRequests       *prometheus.CounterVec
+	logFollowers          prometheus.Gauge
+	hookCallDuration      *prometheus.HistogramVec
+	appDetectionDuration  prometheus.Histogram
+	serverLastContactTime prometheus.Gauge
+
+	// Time of the last request received from the Stork Server in
+	// nanoseconds since the epoch. It is zero if the server hasn't
+	// contacted the agent yet.
+	lastServerContact atomic.Int64
+}
+
+// Creates the agent's self metrics and registers them in a new registry.
+func NewSelfMetrics() *SelfMetrics {
+	registry := prometheus.NewRegistry()
+	factory := promauto.With(registry)
+
+	return &SelfMetrics{
+		Registry: registry,
+		grpcRequests: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "grpc",
+			Name:      "requests_total",
+			Help:      "Number of gRPC requests received from the Stork Server",
+		}, []string{"method", "code"}),
+		grpcRequestDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "grpc",
+			Name:      "request_duration_seconds",
+			Help:      "Duration of handling the gRPC requests received from the Stork Server",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+		keaErrors: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "kea",
+			Name:      "errors_total",
+			Help:      "Number of failures to forward the commands to Kea and the error results returned by Kea",
+		}, []string{"reason"}),
+		logTailRequests: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "log_tail",
+			Name:      "requests_total",
+			Help:      "Number of requests to tail or follow the log files",
+		}, []string{"method"}),
+		logFollowers: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "log_tail",
+			Name:      "followers",
+			Help:      "Number of the log files currently followed",
+		}),
+		hookCallDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "hook",
+			Name:      "call_duration_seconds",
+			Help:      "Duration of the hook callouts",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"callout"}),
+		appDetectionDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "app_monitor",
+			Name:      "detection_duration_seconds",
+			Help:      "Duration of detecting the apps running on the machine",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		serverLastContactTime: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: selfMetricsNamespace,
+			Subsystem: "server",
+			Name:      "last_contact_timestamp_seconds",
+			Help:      "Time of the last request received from the Stork Server",
+		}),
+	}
+}
+
+// Records the gRPC request received from the Stork Server. The method is
+// the full gRPC method name. The error is the one returned by the handler.
+func (m *SelfMetrics) observeGRPCRequest(method string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.grpcRequests.WithLabelValues(method, status.Code(err).String()).Inc()
+	m.grpcRequestDuration.WithLabelValues(method).Observe(duration.Seconds())
+}
+
+// Records that the Stork Server has just contacted the agent.
+func (m *SelfMetrics) markServerContact() {
+	if m == nil {
+		return
+	}
+	now := time.Now()
+	m.lastServerContact.Store(now.UnixNano())
+	m.serverLastContactTime.Set(float64(now.Unix()))
+}
+
+// Returns the time of the last request received from the Stork Server. It
+// returns zero time if the server hasn't contacted the agent yet.
+func (m *SelfMetrics) getLastServerContact() time.Time {
+	if m == nil {
+		return time.Time{}
+	}
+	lastContact := m.lastServerContact.Load()
+	if lastContact == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, lastContact)
+}
+
+// Counts the Kea error with the specified reason.
+func (m *SelfMetrics) countKeaError(reason string) {
+	if m == nil {
+		return
+	}
+	m.keaErrors.WithLabelValues(reason).Inc()
+}
+
+// Counts the request to tail or follow a log file.
+func (m *SelfMetrics) countLogTailRequest(method string) {
+	if m == nil {
+		return
+	}
+	m.logTailRequests.WithLabelValues(method).Inc()
+}
+
+// Adjusts the number of the currently followed log files by the specified
+// delta.
+func (m *SelfMetrics) addLogFollowers(delta int) {
+	if m == nil {
+		return
+	}
+	m.logFollowers.Add(float64(delta))
+}
+
+// Records the duration of the hook callout started at the specified time.
+func (m *SelfMetrics) observeHookCall(callout string, started time.Time) {
+	if m == nil {
+		return
+	}
+	m.hookCallDuration.WithLabelValues(callout).Observe(time.Since(started).Seconds())
+}
+
+// Records the duration of the app detection started at the specified time.
+func (m *SelfMetrics) observeAppDetection(started time.Time) {
+	if m == nil {
+		return
+	}
+	m.appDetectionDuration.Observe(time.Since(started).Seconds())
+}
+
+// gRPC interceptor recording the unary requests received from the Stork
+// Server. The server contact is marked when the request is received.
+func (m *SelfMetrics) unaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	m.markServerContact()
+	started := time.Now()
+	rsp, err := handler(ctx, req)
+	m.observeGRPCRequest(info.FullMethod, err, time.Since(started))
+	return rsp, err
+}
+
+// gRPC interceptor recording the streaming requests received from the
+// Stork Server. The server contact is marked when the stream is opened, so
+// the long-lasting streams, e.g., following a log file, don't delay it.
+func (m *SelfMetrics) streamServerInterceptor(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	m.markServerContact()
+	started := time.Now()
+	err := handler(srv, stream)
+	m.observeGRPCRequest(info.FullMethod, err, time.Since(started))
+	return err
+}
+
+// Returns the gRPC server options installing the interceptors that record
+// the requests received from the Stork Server.
+func (m *SelfMetrics) grpcServerOptions() []grpc.ServerOption {
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(m.unaryServerInterceptor),
+		grpc.ChainStreamInterceptor(m.streamServerInterceptor),
+	}
+}
@@ -0,0 +1,112 @@
+package agent
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gopkg.in/h2non/gock.v1"
+	agentapi "isc.org/stork/api"
+)
+
+// Test that the self metrics are registered in their own registry with
+// the storkagent prefix.
+func TestNewSelfMetrics(t *testing.T) {
+	metrics := NewSelfMetrics()
+	metrics.countKeaError(keaErrorReasonCommand)
+
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	require.NotEmpty(t, families)
+	for _, family := range families {
+		require.Contains(t, family.GetName(), "storkagent_")
+	}
+}
+
+// Test that the methods of the nil self metrics don't panic.
+func TestSelfMetricsNil(t *testing.T) {
+	var metrics *SelfMetrics
+	require.NotPanics(t, func() {
+		metrics.observeGRPCRequest("foo", nil, time.Second)
+		metrics.markServerContact()
+		metrics.countKeaError(keaErrorReasonCommunication)
+		metrics.countLogTailRequest("tail")
+		metrics.addLogFollowers(1)
+		metrics.observeHookCall("foo", time.Now())
+		metrics.observeAppDetection(time.Now())
+	})
+	require.Zero(t, metrics.getLastServerContact())
+}
+
+// Test that the unary interceptor counts the requests per method and
+// status code and marks the server contact.
+func TestSelfMetricsUnaryServerInterceptor(t *testing.T) {
+	metrics := NewSelfMetrics()
+	require.Zero(t, metrics.getLastServerContact())
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/agentapi.Agent/Ping"}
+	rsp, err := metrics.unaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return "pong", nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, "pong", rsp)
+
+	_, err = metrics.unaryServerInterceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "foo")
+	})
+	require.Error(t, err)
+
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.grpcRequests.WithLabelValues(info.FullMethod, codes.OK.String())))
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.grpcRequests.WithLabelValues(info.FullMethod, codes.Unavailable.String())))
+	require.EqualValues(t, 1, testutil.CollectAndCount(metrics.grpcRequestDuration))
+	require.WithinDuration(t, time.Now(), metrics.getLastServerContact(), 5*time.Second)
+}
+
+// Test that the stream interceptor counts the requests.
+func TestSelfMetricsStreamServerInterceptor(t *testing.T) {
+	metrics := NewSelfMetrics()
+
+	info := &grpc.StreamServerInfo{FullMethod: "/agentapi.Agent/FollowTextFile"}
+	err := metrics.streamServerInterceptor(nil, nil, info, func(srv interface{}, stream grpc.ServerStream) error {
+		require.False(t, metrics.getLastServerContact().IsZero())
+		return errors.New("foo")
+	})
+	require.Error(t, err)
+	require.EqualValues(t, 1, testutil.ToFloat64(metrics.grpcRequests.WithLabelValues(info.FullMethod, codes.Unknown.String())))
+}
+
+// Test that the failures to communicate with Kea and the error results
+// returned by Kea are counted.
+func TestForwardToKeaOverHTTPCountErrors(t *testing.T) {
+	sa, ctx := setupAgentTest()
+	sa.Metrics = NewSelfMetrics()
+
+	defer gock.Off()
+	gock.New("http://localhost:45634").
+		Post("/").
+		Reply(200).
+		JSON([]map[string]int{{"result": 1}, {"result": 0}})
+
+	req := &agentapi.ForwardToKeaOverHTTPReq{
+		Url:         "http://localhost:45634/",
+		KeaRequests: []*agentapi.KeaRequest{{Request: "{ \"command\": \"list-commands\"}"}},
+	}
+	_, err := sa.ForwardToKeaOverHTTP(ctx, req)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, testutil.ToFloat64(sa.Metrics.keaErrors.WithLabelValues(keaErrorReasonCommand)))
+	require.Zero(t, testutil.ToFloat64(sa.Metrics.keaErrors.WithLabelValues(keaErrorReasonCommunication)))
+
+	// No more mocked responses, so the communication fails.
+	_, err = sa.ForwardToKeaOverHTTP(ctx, req)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, testutil.ToFloat64(sa.Metrics.keaErrors.WithLabelValues(keaErrorReasonCommunication)))
+
+	// The durations of the hook calls have been recorded.
+	require.EqualValues(t, 1, testutil.CollectAndCount(sa.Metrics.hookCallDuration))
+}
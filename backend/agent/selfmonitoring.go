@@ -0,0 +1,229 @@
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/pki"
+)
+
+// The maximum time since the last request from the Stork Server for which
+// the server is considered to be in contact with the agent. The server
+// pulls the machine state every 30 seconds by default, so the timeout
+// tolerates a few missed pulls. The same timeout is applied after the
+// agent starts, before the server contacts it for the first time.
+const serverContactTimeout = 5 * time.Minute
+
+// Health of the agent's TLS certificates.
+type certificatesHealth struct {
+	Valid    bool       `json:"valid"`
+	NotAfter *time.Time `json:"notAfter,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Health of the connection between the Stork Server and the agent. The
+// contact with the server is not required if the agent listens for the
+// Prometheus requests only.
+type serverContactHealth struct {
+	Required    bool       `json:"required"`
+	Recent      bool       `json:"recent"`
+	LastContact *time.Time `json:"lastContact,omitempty"`
+}
+
+// Health of a detected app. The app is responding if it returned a
+// response to the command sent over its control access point.
+type appHealth struct {
+	Type       string `json:"type"`
+	Address    string `json:"address"`
+	Port       int64  `json:"port"`
+	Responding bool   `json:"responding"`
+	Error      string `json:"error,omitempty"`
+}
+
+// The report returned by the health endpoint. The agent is healthy if its
+// certificates are valid, the server has contacted it recently (if it is
+// required) and all detected apps are responding.
+type healthReport struct {
+	Healthy      bool                `json:"healthy"`
+	Certificates certificatesHealth  `json:"certificates"`
+	Server       serverContactHealth `json:"server"`
+	Apps         []appHealth         `json:"apps"`
+}
+
+// Checks if the agent's certificates are present and valid at the
+// moment.
+func (sa *StorkAgent) checkCertificates() (health certificatesHealth) {
+	if sa.tlsIdentity == nil {
+		health.Error = "agent is not set up"
+		return
+	}
+	if err := sa.tlsIdentity.certStore.IsValid(); err != nil {
+		health.Error = err.Error()
+		return
+	}
+	certPEM, err := sa.tlsIdentity.certStore.readCert()
+	if err != nil {
+		health.Error = err.Error()
+		return
+	}
+	cert, err := pki.ParseCert(certPEM)
+	if err != nil {
+		health.Error = err.Error()
+		return
+	}
+	health.NotAfter = &cert.NotAfter
+
+	now := time.Now()
+	switch {
+	case now.Before(cert.NotBefore):
+		health.Error = "agent certificate is not valid yet"
+	case now.After(cert.NotAfter):
+		health.Error = "agent certificate has expired"
+	default:
+		health.Valid = true
+	}
+	return
+}
+
+// Checks if the Stork Server has contacted the agent within the timeout.
+func (sa *StorkAgent) checkServerContact() (health serverContactHealth) {
+	health.Required = sa.Settings == nil || !sa.Settings.Bool("listen-prometheus-only")
+
+	since := sa.startedAt
+	if lastContact := sa.Metrics.getLastServerContact(); !lastContact.IsZero() {
+		health.LastContact = &lastContact
+		since = lastContact
+	}
+	health.Recent = time.Since(since) < serverContactTimeout
+	return
+}
+
+// Sends a command to the app to check if it is responding. The version-get
+// command is sent to Kea and the status command is sent to BIND 9.
+func checkApp(app App) (health appHealth) {
+	health.Type = app.GetBaseApp().Type
+	if len(app.GetBaseApp().AccessPoints) > 0 {
+		health.Address = app.GetBaseApp().AccessPoints[0].Address
+		health.Port = app.GetBaseApp().AccessPoints[0].Port
+	}
+
+	var err error
+	switch concreteApp := app.(type) {
+	case *KeaApp:
+		responses := keactrl.ResponseList{}
+		err = concreteApp.sendCommand(keactrl.NewCommand("version-get", nil, nil), &responses)
+	case *Bind9App:
+		_, err = concreteApp.sendCommand([]string{"status"})
+	default:
+		err = errors.Errorf("unsupported app type: %s", health.Type)
+	}
+	if err != nil {
+		health.Error = err.Error()
+		return
+	}
+	health.Responding = true
+	return
+}
+
+// Checks the health of the agent and the detected apps.
+func (sa *StorkAgent) checkHealth() *healthReport {
+	report := &healthReport{
+		Certificates: sa.checkCertificates(),
+		Server:       sa.checkServerContact(),
+		Apps:         []appHealth{},
+	}
+	report.Healthy = report.Certificates.Valid && (report.Server.Recent || !report.Server.Required)
+
+	for _, app := range sa.AppMonitor.GetApps() {
+		health := checkApp(app)
+		report.Healthy = report.Healthy && health.Responding
+		report.Apps = append(report.Apps, health)
+	}
+	return report
+}
+
+// HTTP handler returning the agent's health report. It responds with
+// the 200 status code if the agent is healthy and with the 503 status code
+// otherwise.
+func (sa *StorkAgent) serveHealth(w http.ResponseWriter, r *http.Request) {
+	report := sa.checkHealth()
+	w.Header().Set("Content-Type", "application/json")
+	if report.Healthy {
+		w.WriteHeader(http.StatusOK)
+	} else {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.WithError(err).Warn("Failed to send the agent health report")
+	}
+}
+
+// HTTP server exposing the agent's self metrics and the health report.
+// It listens on the local address by default, so the health can be
+// checked by the local tools, e.g., a container orchestrator or systemd
+// watchdog.
+type SelfMonitoringServer struct {
+	Settings   *cli.Context
+	StorkAgent *StorkAgent
+	HTTPServer *http.Server
+}
+
+// Creates the server exposing the self metrics under /metrics and the
+// health report under /health.
+func NewSelfMonitoringServer(settings *cli.Context, storkAgent *StorkAgent) *SelfMonitoringServer {
+	mux := http.NewServeMux()
+	if storkAgent.Metrics != nil {
+		mux.Handle("/metrics", promhttp.HandlerFor(storkAgent.Metrics.Registry, promhttp.HandlerOpts{}))
+	}
+	mux.HandleFunc("/health", storkAgent.serveHealth)
+
+	return &SelfMonitoringServer{
+		Settings:   settings,
+		StorkAgent: storkAgent,
+		HTTPServer: &http.Server{
+			Handler: mux,
+			// Protection against Slowloris Attack (G112).
+			ReadHeaderTimeout: 60 * time.Second,
+		},
+	}
+}
+
+// Starts the HTTP server. The server is not started if the port is zero.
+func (s *SelfMonitoringServer) Start() {
+	port := s.Settings.Int("self-monitoring-port")
+	if port == 0 {
+		log.Info("Self-monitoring endpoint is disabled")
+		return
+	}
+	addrPort := net.JoinHostPort(s.Settings.String("self-monitoring-address"), strconv.Itoa(port))
+	s.HTTPServer.Addr = addrPort
+
+	log.Printf("Self-monitoring endpoint listening on %s", addrPort)
+
+	go func() {
+		err := s.HTTPServer.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.WithError(err).Error("Problem serving the self-monitoring endpoint")
+		}
+	}()
+}
+
+// Stops the HTTP server.
+func (s *SelfMonitoringServer) Shutdown() {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	s.HTTPServer.SetKeepAlivesEnabled(false)
+	if err := s.HTTPServer.Shutdown(ctx); err != nil {
+		log.WithError(err).Warn("Could not gracefully shut down the self-monitoring endpoint")
+	}
+}
@@ -0,0 +1,140 @@
+package agent
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+	"gopkg.in/h2non/gock.v1"
+)
+
+// Test that the agent lacking the certificates is unhealthy.
+func TestCheckHealthNotSetUp(t *testing.T) {
+	sa := &StorkAgent{
+		AppMonitor: &FakeAppMonitor{},
+		startedAt:  time.Now(),
+	}
+
+	report := sa.checkHealth()
+	require.False(t, report.Healthy)
+	require.False(t, report.Certificates.Valid)
+	require.Equal(t, "agent is not set up", report.Certificates.Error)
+	// The server hasn't contacted the agent yet, but it has just started.
+	require.True(t, report.Server.Required)
+	require.True(t, report.Server.Recent)
+	require.Nil(t, report.Server.LastContact)
+	require.Empty(t, report.Apps)
+}
+
+// Test that the health of the certificates, the contact with the server
+// and the apps is checked.
+func TestCheckHealth(t *testing.T) {
+	cleanup, err := GenerateSelfSignedCerts()
+	require.NoError(t, err)
+	defer cleanup()
+
+	sa, _ := setupAgentTest()
+	sa.Metrics = NewSelfMetrics()
+
+	defer gock.Off()
+	gock.New("http://localhost:45634").
+		Post("/").
+		Persist().
+		Reply(200).
+		JSON([]map[string]interface{}{{"result": 0, "text": "2.2.0"}})
+
+	fam, _ := sa.AppMonitor.(*FakeAppMonitor)
+	fam.Apps = []App{
+		&KeaApp{
+			BaseApp: BaseApp{
+				Type:         AppTypeKea,
+				AccessPoints: makeAccessPoint(AccessPointControl, "localhost", "", 45634, false),
+			},
+			HTTPClient: sa.HTTPClient,
+		},
+		&Bind9App{
+			BaseApp: BaseApp{
+				Type:         AppTypeBind9,
+				AccessPoints: makeAccessPoint(AccessPointControl, "127.0.0.1", "_", 953, false),
+			},
+			RndcClient: NewRndcClient(mockRndc),
+		},
+	}
+
+	// The server hasn't contacted the agent for a long time.
+	report := sa.checkHealth()
+	require.False(t, report.Healthy)
+	require.True(t, report.Certificates.Valid)
+	require.NotNil(t, report.Certificates.NotAfter)
+	require.False(t, report.Server.Recent)
+	require.Len(t, report.Apps, 2)
+	require.Equal(t, appHealth{Type: AppTypeKea, Address: "localhost", Port: 45634, Responding: true}, report.Apps[0])
+	require.Equal(t, appHealth{Type: AppTypeBind9, Address: "127.0.0.1", Port: 953, Responding: true}, report.Apps[1])
+
+	// All is fine after the server contacts the agent.
+	sa.Metrics.markServerContact()
+	report = sa.checkHealth()
+	require.True(t, report.Healthy)
+	require.True(t, report.Server.Recent)
+	require.NotNil(t, report.Server.LastContact)
+
+	// The app is not responding.
+	fam.Apps[1].(*Bind9App).RndcClient = NewRndcClient(mockRndcError)
+	report = sa.checkHealth()
+	require.False(t, report.Healthy)
+	require.False(t, report.Apps[1].Responding)
+	require.Contains(t, report.Apps[1].Error, "mocking an error")
+}
+
+// Test that the contact with the server is not required when the agent
+// listens for the Prometheus requests only.
+func TestCheckServerContactNotRequired(t *testing.T) {
+	flags := flag.NewFlagSet("test", 0)
+	flags.Bool("listen-prometheus-only", true, "")
+	sa := &StorkAgent{
+		Settings: cli.NewContext(nil, flags, nil),
+	}
+
+	health := sa.checkServerContact()
+	require.False(t, health.Required)
+	require.False(t, health.Recent)
+}
+
+// Test that the health endpoint returns the report and the status code
+// depending on the agent's health.
+func TestServeHealth(t *testing.T) {
+	sa := &StorkAgent{
+		AppMonitor: &FakeAppMonitor{},
+		Metrics:    NewSelfMetrics(),
+	}
+	server := NewSelfMonitoringServer(cli.NewContext(nil, flag.NewFlagSet("", 0), nil), sa)
+
+	recorder := httptest.NewRecorder()
+	server.HTTPServer.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/health", nil))
+	require.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var report healthReport
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+	require.False(t, report.Healthy)
+	require.Equal(t, "agent is not set up", report.Certificates.Error)
+}
+
+// Test that the self metrics are exported by the self-monitoring server.
+func TestSelfMonitoringServerMetrics(t *testing.T) {
+	sa := &StorkAgent{
+		Metrics: NewSelfMetrics(),
+	}
+	sa.Metrics.countLogTailRequest("tail")
+	server := NewSelfMonitoringServer(cli.NewContext(nil, flag.NewFlagSet("", 0), nil), sa)
+
+	recorder := httptest.NewRecorder()
+	server.HTTPServer.Handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	require.Contains(t, recorder.Body.String(), `storkagent_log_tail_requests_total{method="tail"} 1`)
+}
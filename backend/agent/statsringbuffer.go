@@ -0,0 +1,230 @@
+package agent
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Number of segments the statistics ring buffer is divided into. The oldest
+// segment is removed when the buffer is full.
+const statsRingBufferSegments = 10
+
+// A single sample of the Kea statistics stored in the ring buffer. It holds
+// the most recent values of all statistics returned by a daemon.
+type statsSample struct {
+	Sequence   uint64                 `json:"seq"`
+	SampledAt  time.Time              `json:"sampledAt"`
+	Address    string                 `json:"address"`
+	Port       int64                  `json:"port"`
+	Daemon     string                 `json:"daemon"`
+	Statistics map[string]json.Number `json:"statistics"`
+}
+
+// Bounded ring buffer of the statistics samples persisted on disk. The
+// samples survive the agent restarts. The buffer is divided into segment
+// files holding a fixed number of samples in the JSON Lines format. The
+// segment file name is the sequence number of the first sample it holds.
+// When the buffer is full the oldest segment is removed.
+type statsRingBuffer struct {
+	directory    string
+	segmentSize  int
+	maxSegments  int
+	mutex        *sync.Mutex
+	segments     []uint64
+	lastSequence uint64
+	currentCount int
+}
+
+// Creates the ring buffer storing its segments in the specified directory.
+// The capacity is the maximum number of the stored samples. The directory
+// is created if it doesn't exist. The samples stored in the directory by
+// the previous agent instance are preserved.
+func newStatsRingBuffer(directory string, capacity int) (*statsRingBuffer, error) {
+	if capacity <= 0 {
+		return nil, errors.Errorf("statistics ring buffer capacity must be positive: %d", capacity)
+	}
+	segmentSize := capacity / statsRingBufferSegments
+	if segmentSize == 0 {
+		segmentSize = 1
+	}
+	buffer := &statsRingBuffer{
+		directory:   directory,
+		segmentSize: segmentSize,
+		maxSegments: (capacity + segmentSize - 1) / segmentSize,
+		mutex:       &sync.Mutex{},
+	}
+	if err := os.MkdirAll(directory, 0o700); err != nil {
+		return nil, errors.Wrapf(err, "cannot create statistics ring buffer directory %s", directory)
+	}
+	if err := buffer.recover(); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+// Returns the path to the segment file starting with the specified sequence
+// number.
+func (b *statsRingBuffer) getSegmentPath(firstSequence uint64) string {
+	return path.Join(b.directory, fmt.Sprintf("%020d.jsonl", firstSequence))
+}
+
+// Reads the samples from the segment file. The invalid lines, e.g., the
+// last line incompletely written before the agent crash, are skipped.
+// The second returned value is false if any line was skipped.
+func (b *statsRingBuffer) readSegment(firstSequence uint64) ([]*statsSample, bool, error) {
+	f, err := os.Open(b.getSegmentPath(firstSequence))
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "cannot open statistics ring buffer segment %d", firstSequence)
+	}
+	defer f.Close()
+
+	var samples []*statsSample
+	valid := true
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			var sample statsSample
+			if jsonErr := json.Unmarshal(line, &sample); jsonErr != nil {
+				valid = false
+			} else {
+				samples = append(samples, &sample)
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, false, errors.Wrapf(err, "cannot read statistics ring buffer segment %d", firstSequence)
+		}
+	}
+	return samples, valid, nil
+}
+
+// Finds the segments stored in the directory and restores the sequence
+// number of the last sample.
+func (b *statsRingBuffer) recover() error {
+	entries, err := os.ReadDir(b.directory)
+	if err != nil {
+		return errors.Wrapf(err, "cannot read statistics ring buffer directory %s", b.directory)
+	}
+	segmentPattern := regexp.MustCompile(`^(\d{20})\.jsonl$`)
+	for _, entry := range entries {
+		match := segmentPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+		firstSequence, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		b.segments = append(b.segments, firstSequence)
+	}
+	sort.Slice(b.segments, func(i, j int) bool {
+		return b.segments[i] < b.segments[j]
+	})
+	if len(b.segments) == 0 {
+		return nil
+	}
+
+	lastSegment := b.segments[len(b.segments)-1]
+	samples, valid, err := b.readSegment(lastSegment)
+	if err != nil {
+		return err
+	}
+	b.lastSequence = lastSegment - 1
+	if len(samples) > 0 {
+		b.lastSequence = samples[len(samples)-1].Sequence
+	}
+	b.currentCount = len(samples)
+	if !valid {
+		// Don't append to the segment with the corrupted contents.
+		b.currentCount = b.segmentSize
+		log.WithField("segment", b.getSegmentPath(lastSegment)).
+			Warn("Statistics ring buffer segment contains invalid samples")
+	}
+	return nil
+}
+
+// Appends the sample to the buffer. It assigns the next sequence number to
+// the sample. The oldest segment is removed when the buffer is full.
+func (b *statsRingBuffer) append(sample *statsSample) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	sample.Sequence = b.lastSequence + 1
+
+	if len(b.segments) == 0 || b.currentCount >= b.segmentSize {
+		// Make room for the new segment.
+		for len(b.segments) >= b.maxSegments {
+			if err := os.Remove(b.getSegmentPath(b.segments[0])); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return errors.Wrapf(err, "cannot remove the oldest statistics ring buffer segment %d", b.segments[0])
+			}
+			b.segments = b.segments[1:]
+		}
+		b.segments = append(b.segments, sample.Sequence)
+		b.currentCount = 0
+	}
+
+	data, err := json.Marshal(sample)
+	if err != nil {
+		return errors.Wrap(err, "cannot serialize statistics sample")
+	}
+
+	lastSegment := b.segments[len(b.segments)-1]
+	f, err := os.OpenFile(b.getSegmentPath(lastSegment), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Wrapf(err, "cannot open statistics ring buffer segment %d", lastSegment)
+	}
+	defer f.Close()
+	if _, err = f.Write(append(data, '\n')); err != nil {
+		return errors.Wrapf(err, "cannot write to statistics ring buffer segment %d", lastSegment)
+	}
+
+	b.lastSequence = sample.Sequence
+	b.currentCount++
+	return nil
+}
+
+// Returns the samples with the sequence numbers greater than the specified
+// one, accepted by the filter function, in the order they were appended.
+// At most limit samples are returned. The second returned value is the
+// sequence number of the last sample in the buffer.
+func (b *statsRingBuffer) readSince(sequence uint64, limit int, filter func(*statsSample) bool) ([]*statsSample, uint64, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	var samples []*statsSample
+	for i, firstSequence := range b.segments {
+		// Skip the segments holding only the older samples.
+		if i+1 < len(b.segments) && b.segments[i+1] <= sequence+1 {
+			continue
+		}
+		segmentSamples, _, err := b.readSegment(firstSequence)
+		if err != nil {
+			return nil, b.lastSequence, err
+		}
+		for _, sample := range segmentSamples {
+			if sample.Sequence <= sequence || (filter != nil && !filter(sample)) {
+				continue
+			}
+			samples = append(samples, sample)
+			if len(samples) == limit {
+				return samples, b.lastSequence, nil
+			}
+		}
+	}
+	return samples, b.lastSequence, nil
+}
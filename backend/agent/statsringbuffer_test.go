@@ -0,0 +1,155 @@
+package agent
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/testutil"
+)
+
+// Creates a sample of the statistics for testing.
+func newTestStatsSample(address string, value int64) *statsSample {
+	return &statsSample{
+		SampledAt: time.Now().UTC(),
+		Address:   address,
+		Port:      8000,
+		Daemon:    "dhcp4",
+		Statistics: map[string]json.Number{
+			"pkt4-ack-sent": json.Number(strconv.FormatInt(value, 10)),
+		},
+	}
+}
+
+// Test that the samples appended to the ring buffer are returned in order
+// and the sequence numbers are assigned.
+func TestStatsRingBufferAppendReadSince(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	buffer, err := newStatsRingBuffer(path.Join(sb.BasePath, "outbox"), 100)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		address := "192.0.2.1"
+		if i%2 == 1 {
+			address = "192.0.2.2"
+		}
+		require.NoError(t, buffer.append(newTestStatsSample(address, int64(i))))
+	}
+
+	samples, lastSequence, err := buffer.readSince(0, 100, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, lastSequence)
+	require.Len(t, samples, 5)
+	for i, sample := range samples {
+		require.EqualValues(t, i+1, sample.Sequence)
+	}
+
+	samples, lastSequence, err = buffer.readSince(2, 100, func(sample *statsSample) bool {
+		return sample.Address == "192.0.2.1"
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 5, lastSequence)
+	require.Len(t, samples, 2)
+	require.EqualValues(t, 3, samples[0].Sequence)
+	require.EqualValues(t, 5, samples[1].Sequence)
+
+	samples, _, err = buffer.readSince(0, 2, nil)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	require.EqualValues(t, 2, samples[1].Sequence)
+}
+
+// Test that the oldest segment is removed when the ring buffer is full.
+func TestStatsRingBufferPruneOldestSegment(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	directory := path.Join(sb.BasePath, "outbox")
+	buffer, err := newStatsRingBuffer(directory, 20)
+	require.NoError(t, err)
+
+	for i := 0; i < 25; i++ {
+		require.NoError(t, buffer.append(newTestStatsSample("192.0.2.1", int64(i))))
+	}
+
+	entries, err := os.ReadDir(directory)
+	require.NoError(t, err)
+	require.Len(t, entries, 10)
+
+	samples, lastSequence, err := buffer.readSince(0, 100, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 25, lastSequence)
+	require.Len(t, samples, 19)
+	require.EqualValues(t, 7, samples[0].Sequence)
+}
+
+// Test that the samples and the sequence numbers survive the agent restart.
+func TestStatsRingBufferRecover(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	directory := path.Join(sb.BasePath, "outbox")
+	buffer, err := newStatsRingBuffer(directory, 30)
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, buffer.append(newTestStatsSample("192.0.2.1", int64(i))))
+	}
+
+	buffer, err = newStatsRingBuffer(directory, 30)
+	require.NoError(t, err)
+	require.NoError(t, buffer.append(newTestStatsSample("192.0.2.1", 4)))
+
+	samples, lastSequence, err := buffer.readSince(0, 100, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, lastSequence)
+	require.Len(t, samples, 5)
+	require.EqualValues(t, 5, samples[4].Sequence)
+}
+
+// Test that the sample partially written before the agent crash is skipped
+// and the new samples are appended to a new segment.
+func TestStatsRingBufferRecoverCorruptedSegment(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	directory := path.Join(sb.BasePath, "outbox")
+	buffer, err := newStatsRingBuffer(directory, 30)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, buffer.append(newTestStatsSample("192.0.2.1", int64(i))))
+	}
+
+	f, err := os.OpenFile(buffer.getSegmentPath(1), os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq": 3, "sampledAt": "20`)
+	require.NoError(t, err)
+	f.Close()
+
+	buffer, err = newStatsRingBuffer(directory, 30)
+	require.NoError(t, err)
+	require.NoError(t, buffer.append(newTestStatsSample("192.0.2.1", 2)))
+
+	samples, lastSequence, err := buffer.readSince(0, 100, nil)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, lastSequence)
+	require.Len(t, samples, 3)
+	require.EqualValues(t, 3, samples[2].Sequence)
+	require.FileExists(t, buffer.getSegmentPath(3))
+}
+
+// Test that the capacity of the ring buffer must be positive.
+func TestNewStatsRingBufferInvalidCapacity(t *testing.T) {
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+
+	buffer, err := newStatsRingBuffer(sb.BasePath, 0)
+	require.Error(t, err)
+	require.Nil(t, buffer)
+}
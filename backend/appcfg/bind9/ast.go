@@ -0,0 +1,218 @@
+package bind9config
+
+import (
+	"strings"
+)
+
+// A single element of the statement. It is either a word, a quoted string
+// or a block of statements enclosed in braces.
+type Element struct {
+	Word   string `json:"word,omitempty"`
+	Quoted bool   `json:"quoted,omitempty"`
+	Block  *Block `json:"block,omitempty"`
+}
+
+// A block of statements enclosed in braces, e.g., the contents of the
+// options or zone clause, or the address match list.
+type Block struct {
+	Statements []*Statement `json:"statements"`
+}
+
+// A statement terminated with a semicolon. The first element is typically
+// the keyword, e.g., zone, followed by its arguments. For instance, the
+// statement:
+//
+//	zone "example.org" IN { type primary; };
+//
+// consists of the zone, example.org and IN words and a block holding the
+// zone parameters.
+type Statement struct {
+	Elements []*Element `json:"elements"`
+}
+
+// Checks if the element is a block.
+func (e *Element) IsBlock() bool {
+	return e.Block != nil
+}
+
+// Returns the element in the BIND 9 configuration format. The words are
+// quoted when they were quoted in the parsed configuration or they contain
+// characters not allowed in the unquoted words.
+func (e *Element) String() string {
+	if e.IsBlock() {
+		return e.Block.format("", " ")
+	}
+	if e.Quoted || e.Word == "" || strings.IndexFunc(e.Word, func(c rune) bool {
+		return c < 128 && isWordBoundary(byte(c))
+	}) >= 0 {
+		return quoteWord(e.Word)
+	}
+	return e.Word
+}
+
+// Returns the word enclosed in double quotes. The quotes and backslashes
+// within the word are escaped with a backslash, like BIND 9 expects. Other
+// characters, including the non-ASCII ones and the new lines, are left
+// intact.
+func quoteWord(word string) string {
+	var quoted strings.Builder
+	quoted.WriteByte('"')
+	for i := 0; i < len(word); i++ {
+		if word[i] == '"' || word[i] == '\\' {
+			quoted.WriteByte('\\')
+		}
+		quoted.WriteByte(word[i])
+	}
+	quoted.WriteByte('"')
+	return quoted.String()
+}
+
+// Returns the name of the statement being its first word. It returns an
+// empty string for the statements beginning with a block, e.g., nested
+// address match lists.
+func (s *Statement) Name() string {
+	return s.GetWord(0)
+}
+
+// Returns the word at the specified position. It returns an empty string
+// if the element at this position doesn't exist or it is a block.
+func (s *Statement) GetWord(index int) string {
+	if index < 0 || index >= len(s.Elements) || s.Elements[index].IsBlock() {
+		return ""
+	}
+	return s.Elements[index].Word
+}
+
+// Returns the first block of the statement or nil if the statement
+// has no blocks.
+func (s *Statement) GetBlock() *Block {
+	for _, element := range s.Elements {
+		if element.IsBlock() {
+			return element.Block
+		}
+	}
+	return nil
+}
+
+// Returns the index of the keyword among the statement's elements or -1
+// if the keyword is absent. The first element is not considered a keyword.
+func (s *Statement) findKeyword(keyword string) int {
+	for i := 1; i < len(s.Elements); i++ {
+		if !s.Elements[i].IsBlock() && !s.Elements[i].Quoted && s.Elements[i].Word == keyword {
+			return i
+		}
+	}
+	return -1
+}
+
+// Returns the word following the keyword, e.g., a port number following
+// the port keyword. It returns an empty string if the keyword is absent
+// or it is followed by a block.
+func (s *Statement) GetKeywordValue(keyword string) string {
+	index := s.findKeyword(keyword)
+	if index < 0 {
+		return ""
+	}
+	return s.GetWord(index + 1)
+}
+
+// Returns the block following the keyword, e.g., an address match list
+// following the allow keyword. It returns nil if the keyword is absent or
+// it is not followed by a block.
+func (s *Statement) GetKeywordBlock(keyword string) *Block {
+	index := s.findKeyword(keyword)
+	if index < 0 || index+1 >= len(s.Elements) {
+		return nil
+	}
+	return s.Elements[index+1].Block
+}
+
+// Returns the statement in the BIND 9 configuration format in a single
+// line, e.g., an element of the address match list.
+func (s *Statement) String() string {
+	return s.format("", " ")
+}
+
+// Returns the statement in the BIND 9 configuration format. The nested
+// statements are indented using the specified indentation and separated
+// with the specified separator.
+func (s *Statement) format(indent, separator string) string {
+	elements := make([]string, 0, len(s.Elements))
+	for _, element := range s.Elements {
+		if element.IsBlock() {
+			elements = append(elements, element.Block.format(indent, separator))
+			continue
+		}
+		elements = append(elements, element.String())
+	}
+	return strings.Join(elements, " ") + ";"
+}
+
+// Returns the first statement with the specified name or nil if there is
+// no such statement.
+func (b *Block) GetStatement(name string) *Statement {
+	for _, statement := range b.Statements {
+		if statement.Name() == name {
+			return statement
+		}
+	}
+	return nil
+}
+
+// Returns all statements with the specified name.
+func (b *Block) GetStatements(name string) []*Statement {
+	var statements []*Statement
+	for _, statement := range b.Statements {
+		if statement.Name() == name {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// Returns the value of the option, i.e., the second word of the first
+// statement with the specified name. For example, it returns primary for
+// the type option in the zone block holding the "type primary;" statement.
+// It returns an empty string if the option is absent.
+func (b *Block) GetOption(name string) string {
+	if statement := b.GetStatement(name); statement != nil {
+		return statement.GetWord(1)
+	}
+	return ""
+}
+
+// Returns the statements of the block as strings. It is useful for the
+// blocks holding lists, e.g., the address match lists.
+func (b *Block) GetList() []string {
+	list := make([]string, 0, len(b.Statements))
+	for _, statement := range b.Statements {
+		list = append(list, strings.TrimSuffix(statement.String(), ";"))
+	}
+	return list
+}
+
+// Returns the block in the BIND 9 configuration format. If the separator
+// is a new line, each nested statement is printed in a separate line
+// using the indentation one level deeper than the specified one.
+func (b *Block) format(indent, separator string) string {
+	if len(b.Statements) == 0 {
+		return "{ }"
+	}
+	nestedIndent := ""
+	if separator == "\n" {
+		nestedIndent = indent + "\t"
+	}
+	var builder strings.Builder
+	builder.WriteString("{")
+	for _, statement := range b.Statements {
+		builder.WriteString(separator)
+		builder.WriteString(nestedIndent)
+		builder.WriteString(statement.format(nestedIndent, separator))
+	}
+	builder.WriteString(separator)
+	if separator == "\n" {
+		builder.WriteString(indent)
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
@@ -0,0 +1,350 @@
+// Package bind9config implements functions to parse and manage the BIND 9
+// configurations.
+package bind9config
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// A structure holding the parsed BIND 9 configuration. It holds the
+// abstract syntax tree of the configuration, i.e., the top level statements
+// with their nested blocks. The functions returning the typed views of
+// the selected clauses, e.g., keys or zones, are provided for convenience.
+// It is marshalled and unmarshalled for storing the BIND 9 configuration
+// in the Stork database.
+type Config struct {
+	Statements []*Statement `json:"statements"`
+}
+
+// A key clause used to authenticate the rndc and zone transfers.
+type Key struct {
+	Name      string
+	Algorithm string
+	Secret    string
+	// Name of the view including the key. It is empty for the keys
+	// defined outside of the views.
+	View string
+}
+
+// An acl clause naming the address match list.
+type ACL struct {
+	Name             string
+	AddressMatchList []string
+}
+
+// The inet clause in the controls or statistics-channels clause. The port
+// is zero if it is not specified. The address is an asterisk if named
+// listens on all addresses.
+type InetSpec struct {
+	Address  string
+	Port     int64
+	Allow    []string
+	Keys     []string
+	ReadOnly bool
+}
+
+// A zone clause in the configuration or in a view.
+type Zone struct {
+	Name  string
+	Class string
+	Type  string
+	File  string
+	// Name of the view including the zone. It is empty for the zones
+	// defined outside of the views.
+	View string
+	// Addresses of the primary servers from which the secondary zone is
+	// transferred. The named primaries lists are expanded.
+	Primaries []string
+}
+
+// A view clause.
+type View struct {
+	Name         string
+	Class        string
+	MatchClients []string
+	Zones        []*Zone
+}
+
+// Returns the top level statements with the specified name.
+func (c *Config) GetStatements(name string) []*Statement {
+	return (&Block{Statements: c.Statements}).GetStatements(name)
+}
+
+// Returns the options clause or nil if it is not specified.
+func (c *Config) GetOptions() *Block {
+	if options := c.GetStatements("options"); len(options) > 0 {
+		return options[0].GetBlock()
+	}
+	return nil
+}
+
+// Converts the key statements in the block to the keys belonging to the
+// specified view. The keys lacking the algorithm or the secret are skipped.
+func (c *Config) getKeys(statements []*Statement, view string) []*Key {
+	var keys []*Key
+	for _, statement := range statements {
+		if statement.Name() != "key" {
+			continue
+		}
+		block := statement.GetBlock()
+		if block == nil {
+			continue
+		}
+		key := &Key{
+			Name:      statement.GetWord(1),
+			Algorithm: block.GetOption("algorithm"),
+			Secret:    block.GetOption("secret"),
+			View:      view,
+		}
+		if key.Name == "" || key.Algorithm == "" || key.Secret == "" {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// Returns the keys specified in the configuration, including the keys
+// defined in the views. The keys defined outside of the views are
+// returned first. The keys lacking the algorithm or the secret are
+// skipped.
+func (c *Config) GetKeys() []*Key {
+	keys := c.getKeys(c.Statements, "")
+	for _, statement := range c.GetStatements("view") {
+		if block := statement.GetBlock(); block != nil {
+			keys = append(keys, c.getKeys(block.Statements, statement.GetWord(1))...)
+		}
+	}
+	return keys
+}
+
+// Returns the key with the specified name defined outside of the views
+// or nil if there is no such key. The keys defined in the views are not
+// returned because they can't be used by the controls clause.
+func (c *Config) GetKey(name string) *Key {
+	for _, key := range c.getKeys(c.Statements, "") {
+		if key.Name == name {
+			return key
+		}
+	}
+	return nil
+}
+
+// Returns the ACLs specified in the configuration.
+func (c *Config) GetACLs() []*ACL {
+	var acls []*ACL
+	for _, statement := range c.GetStatements("acl") {
+		acl := &ACL{
+			Name:             statement.GetWord(1),
+			AddressMatchList: []string{},
+		}
+		if block := statement.GetBlock(); block != nil {
+			acl.AddressMatchList = block.GetList()
+		}
+		acls = append(acls, acl)
+	}
+	return acls
+}
+
+// Returns the addresses of the primary servers listed in the block of the
+// primaries (or legacy masters) clause. The names of the primaries lists
+// defined at the top level are replaced with the addresses they hold. The
+// visited lists are tracked to prevent the infinite recursion.
+func (c *Config) getPrimaries(block *Block, visited map[string]bool) []string {
+	var primaries []string
+	for _, statement := range block.Statements {
+		name := statement.Name()
+		if net.ParseIP(name) != nil {
+			primaries = append(primaries, name)
+			continue
+		}
+		if name == "" || visited[name] {
+			continue
+		}
+		visited[name] = true
+		for _, list := range append(c.GetStatements("primaries"), c.GetStatements("masters")...) {
+			if list.GetWord(1) == name && list.GetBlock() != nil {
+				primaries = append(primaries, c.getPrimaries(list.GetBlock(), visited)...)
+			}
+		}
+	}
+	return primaries
+}
+
+// Converts the zone statements in the block to the zones belonging to
+// the specified view.
+func (c *Config) getZones(statements []*Statement, view string) []*Zone {
+	var zones []*Zone
+	for _, statement := range statements {
+		if statement.Name() != "zone" {
+			continue
+		}
+		zone := &Zone{
+			Name:  statement.GetWord(1),
+			Class: statement.GetWord(2),
+			View:  view,
+		}
+		if block := statement.GetBlock(); block != nil {
+			zone.Type = block.GetOption("type")
+			zone.File = block.GetOption("file")
+			for _, name := range []string{"primaries", "masters"} {
+				if primaries := block.GetStatement(name); primaries != nil && primaries.GetBlock() != nil {
+					zone.Primaries = c.getPrimaries(primaries.GetBlock(), make(map[string]bool))
+					break
+				}
+			}
+		}
+		zones = append(zones, zone)
+	}
+	return zones
+}
+
+// Returns the zones defined outside of the views.
+func (c *Config) GetZones() []*Zone {
+	return c.getZones(c.Statements, "")
+}
+
+// Returns the views with their zones.
+func (c *Config) GetViews() []*View {
+	var views []*View
+	for _, statement := range c.GetStatements("view") {
+		view := &View{
+			Name:  statement.GetWord(1),
+			Class: statement.GetWord(2),
+		}
+		if block := statement.GetBlock(); block != nil {
+			if matchClients := block.GetStatement("match-clients"); matchClients != nil && matchClients.GetBlock() != nil {
+				view.MatchClients = matchClients.GetBlock().GetList()
+			}
+			view.Zones = c.getZones(block.Statements, view.Name)
+		}
+		views = append(views, view)
+	}
+	return views
+}
+
+// Returns all zones including the ones defined in the views.
+func (c *Config) GetAllZones() []*Zone {
+	zones := c.GetZones()
+	for _, view := range c.GetViews() {
+		zones = append(zones, view.Zones...)
+	}
+	return zones
+}
+
+// Converts the inet statement to the structure. It returns an error if
+// the address is missing or the port is not a number.
+//
+//	inet ( ip_addr | * ) [ port ( ip_port | * ) ]
+//		allow { address_match_list }
+//		[ keys { key_list } ] [ read-only boolean ];
+func parseInetSpec(statement *Statement) (*InetSpec, error) {
+	spec := &InetSpec{
+		Address: statement.GetWord(1),
+		Allow:   []string{},
+		Keys:    []string{},
+	}
+	if spec.Address == "" {
+		return nil, errors.Errorf("missing address in the inet clause: %s", statement)
+	}
+	if port := statement.GetKeywordValue("port"); port != "" && port != "*" {
+		value, err := strconv.ParseUint(port, 10, 16)
+		if err != nil {
+			return nil, errors.Errorf("invalid port in the inet clause: %s", statement)
+		}
+		spec.Port = int64(value)
+	}
+	if allow := statement.GetKeywordBlock("allow"); allow != nil {
+		spec.Allow = allow.GetList()
+	}
+	if keys := statement.GetKeywordBlock("keys"); keys != nil {
+		for _, key := range keys.Statements {
+			spec.Keys = append(spec.Keys, key.Name())
+		}
+	}
+	switch strings.ToLower(statement.GetKeywordValue("read-only")) {
+	case "yes", "true", "1":
+		spec.ReadOnly = true
+	}
+	return spec, nil
+}
+
+// Returns the inet clauses in all top level statements with the specified
+// name.
+func (c *Config) getInetSpecs(name string) ([]*InetSpec, error) {
+	specs := []*InetSpec{}
+	for _, statement := range c.GetStatements(name) {
+		block := statement.GetBlock()
+		if block == nil {
+			continue
+		}
+		for _, inet := range block.GetStatements("inet") {
+			spec, err := parseInetSpec(inet)
+			if err != nil {
+				return nil, err
+			}
+			specs = append(specs, spec)
+		}
+	}
+	return specs, nil
+}
+
+// Checks if the controls clause is specified. named opens the default
+// control channel if it is absent.
+func (c *Config) HasControls() bool {
+	return len(c.GetStatements("controls")) > 0
+}
+
+// Returns the inet control channels specified in the controls clauses.
+// It returns an empty list if the controls clause is absent or empty.
+func (c *Config) GetControls() ([]*InetSpec, error) {
+	return c.getInetSpecs("controls")
+}
+
+// Returns the inet clauses of the statistics-channels clauses.
+func (c *Config) GetStatisticsChannels() ([]*InetSpec, error) {
+	return c.getInetSpecs("statistics-channels")
+}
+
+// Replaces the key secrets with empty strings. It is used to return the
+// configuration to the users lacking the privileges to see the secrets.
+func (c *Config) HideSensitiveData() {
+	hideSecrets(c.Statements)
+}
+
+// Replaces the values of the secret statements in the key clauses with
+// empty strings. The key clauses may be nested in the views.
+func hideSecrets(statements []*Statement) {
+	for _, statement := range statements {
+		block := statement.GetBlock()
+		if block == nil {
+			continue
+		}
+		if statement.Name() != "key" {
+			hideSecrets(block.Statements)
+			continue
+		}
+		for _, secret := range block.GetStatements("secret") {
+			if len(secret.Elements) > 1 {
+				secret.Elements[1].Word = ""
+				secret.Elements[1].Quoted = true
+			}
+		}
+	}
+}
+
+// Returns the configuration in the BIND 9 configuration format. Each
+// statement is printed in a separate line and the nested statements
+// are indented using tabs.
+func (c *Config) String() string {
+	var builder strings.Builder
+	for _, statement := range c.Statements {
+		builder.WriteString(statement.format("", "\n"))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
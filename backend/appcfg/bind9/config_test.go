@@ -0,0 +1,252 @@
+package bind9config
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// A configuration used in the tests.
+const testConfig = `
+key "rndc-key" {
+	algorithm "hmac-sha256";
+	secret "abcd";
+};
+key "incomplete" {
+	algorithm "hmac-sha256";
+};
+acl "trusted" {
+	192.0.2.0/24;
+	!192.0.2.1;
+	key "rndc-key";
+};
+options {
+	directory "/var/cache/bind";
+};
+controls {
+	inet * port 953 allow { localhost; } keys { "rndc-key"; };
+	inet ::1 allow { ::1; } read-only yes;
+};
+statistics-channels {
+	inet 127.0.0.1 port 8053 allow { trusted; };
+};
+zone "." {
+	type hint;
+	file "/usr/share/dns/root.hints";
+};
+view "internal" IN {
+	match-clients { trusted; };
+	key "view-key" {
+		algorithm "hmac-sha256";
+		secret "efgh";
+	};
+	zone "example.org" IN {
+		type primary;
+		file "/etc/bind/db.example.org";
+	};
+};
+`
+
+// Parses the test configuration.
+func parseTestConfig(t *testing.T) *Config {
+	config, err := Parse(testConfig)
+	require.NoError(t, err)
+	return config
+}
+
+// Test getting the keys.
+func TestGetKeys(t *testing.T) {
+	config := parseTestConfig(t)
+
+	keys := config.GetKeys()
+	require.Len(t, keys, 2)
+	require.Equal(t, &Key{Name: "rndc-key", Algorithm: "hmac-sha256", Secret: "abcd"}, keys[0])
+	require.Equal(t, &Key{Name: "view-key", Algorithm: "hmac-sha256", Secret: "efgh", View: "internal"}, keys[1])
+
+	require.NotNil(t, config.GetKey("rndc-key"))
+	require.Nil(t, config.GetKey("incomplete"))
+	require.Nil(t, config.GetKey(""))
+	// The keys in the views can't be used by the controls clause.
+	require.Nil(t, config.GetKey("view-key"))
+}
+
+// Test getting the ACLs.
+func TestGetACLs(t *testing.T) {
+	acls := parseTestConfig(t).GetACLs()
+	require.Len(t, acls, 1)
+	require.Equal(t, "trusted", acls[0].Name)
+	require.Equal(t, []string{"192.0.2.0/24", "!192.0.2.1", `key "rndc-key"`}, acls[0].AddressMatchList)
+}
+
+// Test getting the control channels.
+func TestGetControls(t *testing.T) {
+	config := parseTestConfig(t)
+	require.True(t, config.HasControls())
+
+	controls, err := config.GetControls()
+	require.NoError(t, err)
+	require.Len(t, controls, 2)
+	require.Equal(t, &InetSpec{
+		Address: "*",
+		Port:    953,
+		Allow:   []string{"localhost"},
+		Keys:    []string{"rndc-key"},
+	}, controls[0])
+	require.Equal(t, &InetSpec{
+		Address:  "::1",
+		Allow:    []string{"::1"},
+		Keys:     []string{},
+		ReadOnly: true,
+	}, controls[1])
+}
+
+// Test that the missing and empty controls clauses are distinguished.
+func TestGetControlsMissingOrEmpty(t *testing.T) {
+	config, err := Parse(`options { };`)
+	require.NoError(t, err)
+	require.False(t, config.HasControls())
+	controls, err := config.GetControls()
+	require.NoError(t, err)
+	require.Empty(t, controls)
+
+	config, err = Parse(`controls { };`)
+	require.NoError(t, err)
+	require.True(t, config.HasControls())
+	controls, err = config.GetControls()
+	require.NoError(t, err)
+	require.Empty(t, controls)
+}
+
+// Test that an error is returned for an invalid port in the inet clause.
+func TestGetControlsInvalidPort(t *testing.T) {
+	config, err := Parse(`controls { inet 127.0.0.1 port foo allow { localhost; }; };`)
+	require.NoError(t, err)
+	controls, err := config.GetControls()
+	require.ErrorContains(t, err, "invalid port in the inet clause")
+	require.Nil(t, controls)
+}
+
+// Test getting the statistics channels.
+func TestGetStatisticsChannels(t *testing.T) {
+	channels, err := parseTestConfig(t).GetStatisticsChannels()
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	require.Equal(t, "127.0.0.1", channels[0].Address)
+	require.EqualValues(t, 8053, channels[0].Port)
+	require.Equal(t, []string{"trusted"}, channels[0].Allow)
+}
+
+// Test getting the views and zones.
+func TestGetViewsAndZones(t *testing.T) {
+	config := parseTestConfig(t)
+
+	zones := config.GetZones()
+	require.Len(t, zones, 1)
+	require.Equal(t, &Zone{Name: ".", Type: "hint", File: "/usr/share/dns/root.hints"}, zones[0])
+
+	views := config.GetViews()
+	require.Len(t, views, 1)
+	require.Equal(t, "internal", views[0].Name)
+	require.Equal(t, "IN", views[0].Class)
+	require.Equal(t, []string{"trusted"}, views[0].MatchClients)
+	require.Len(t, views[0].Zones, 1)
+	require.Equal(t, &Zone{
+		Name:  "example.org",
+		Class: "IN",
+		Type:  "primary",
+		File:  "/etc/bind/db.example.org",
+		View:  "internal",
+	}, views[0].Zones[0])
+
+	require.Len(t, config.GetAllZones(), 2)
+}
+
+// Test that the primary servers of the secondary zones are returned and
+// the named primaries lists are expanded.
+func TestGetZonesPrimaries(t *testing.T) {
+	config, err := Parse(`
+		primaries "upstream" { 192.0.2.1; "nested"; };
+		masters "nested" { 2001:db8::1 port 5353 key "xfr"; "upstream"; };
+		zone "example.org" {
+			type secondary;
+			primaries { "upstream"; 192.0.2.2; };
+		};
+		zone "example.com" {
+			type slave;
+			masters { 192.0.2.3; };
+		};
+		zone "example.net" {
+			type primary;
+		};
+	`)
+	require.NoError(t, err)
+
+	zones := config.GetZones()
+	require.Len(t, zones, 3)
+	require.Equal(t, []string{"192.0.2.1", "2001:db8::1", "192.0.2.2"}, zones[0].Primaries)
+	require.Equal(t, []string{"192.0.2.3"}, zones[1].Primaries)
+	require.Nil(t, zones[2].Primaries)
+}
+
+// Test that the key secrets are hidden, including the keys in the views.
+func TestHideSensitiveData(t *testing.T) {
+	config := parseTestConfig(t)
+	config.HideSensitiveData()
+
+	require.Empty(t, config.GetKeys())
+	text := config.String()
+	require.NotContains(t, text, "abcd")
+	require.NotContains(t, text, "efgh")
+	require.Contains(t, text, `secret "";`)
+}
+
+// Test that the configuration is printed in the BIND 9 format and it can
+// be parsed again.
+func TestConfigString(t *testing.T) {
+	config, err := Parse(`zone "example.org" { type primary; allow-transfer { key "xfr"; 192.0.2.1; }; };`)
+	require.NoError(t, err)
+	require.Equal(t, `zone "example.org" {
+	type primary;
+	allow-transfer {
+		key "xfr";
+		192.0.2.1;
+	};
+};
+`, config.String())
+
+	reparsed, err := Parse(config.String())
+	require.NoError(t, err)
+	require.Equal(t, config, reparsed)
+
+	config = parseTestConfig(t)
+	reparsed, err = Parse(config.String())
+	require.NoError(t, err)
+	require.Equal(t, config, reparsed)
+}
+
+// Test that the quoted words are printed using the BIND 9 escaping rules
+// rather than the Go ones.
+func TestConfigStringQuoting(t *testing.T) {
+	config, err := Parse(`zone "zażółć.example.org" { file "/etc/bind/a \"b\" c\\d"; };`)
+	require.NoError(t, err)
+	require.Equal(t, `zone "zażółć.example.org" {
+	file "/etc/bind/a \"b\" c\\d";
+};
+`, config.String())
+
+	reparsed, err := Parse(config.String())
+	require.NoError(t, err)
+	require.Equal(t, config, reparsed)
+}
+
+// Test that the configuration is marshalled to JSON and unmarshalled back.
+func TestConfigJSON(t *testing.T) {
+	config := parseTestConfig(t)
+	data, err := json.Marshal(config)
+	require.NoError(t, err)
+
+	var unmarshalled Config
+	require.NoError(t, json.Unmarshal(data, &unmarshalled))
+	require.Equal(t, config, &unmarshalled)
+}
@@ -0,0 +1,229 @@
+package bind9config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// Maximum nesting level of the include statements. It protects against
+// the include loops.
+const maxIncludeDepth = 16
+
+// Reads the contents of an included file.
+type fileReader func(path string) ([]byte, error)
+
+// Recursive descent parser of the BIND 9 configuration. The grammar
+// is generic:
+//
+//	statements = { statement }
+//	statement  = element { element } ";"
+//	element    = word | quoted-string | "{" statements "}"
+//
+// It doesn't validate the keywords, so it accepts the configurations of
+// all BIND 9 versions.
+type parser struct {
+	tokens   []token
+	position int
+	// Name of the parsed file used in the error messages. It is empty
+	// when the configuration text is parsed.
+	source string
+	// Resolves the include statements. The include statements are left
+	// intact if it is nil.
+	includer *includer
+}
+
+// Resolves the include statements by parsing the included files.
+type includer struct {
+	// Root directory of named. The absolute paths in the include statements
+	// are relative to it.
+	rootDir string
+	// Directory the relative paths in the include statements are relative to.
+	baseDir string
+	// Paths to the files being parsed to detect the include loops.
+	stack []string
+	read  fileReader
+}
+
+// Creates the parser of the configuration text.
+func newParser(text, source string, includer *includer) (*parser, error) {
+	tokens, err := tokenize(text)
+	if err != nil {
+		if source != "" {
+			return nil, errors.WithMessagef(err, "cannot parse %s", source)
+		}
+		return nil, err
+	}
+	return &parser{
+		tokens:   tokens,
+		source:   source,
+		includer: includer,
+	}, nil
+}
+
+// Returns an error containing the source of the configuration and the
+// line number of the current token.
+func (p *parser) errorf(line int, format string, args ...any) error {
+	err := errors.Errorf(format, args...)
+	if p.source != "" {
+		return errors.WithMessagef(err, "%s:%d", p.source, line)
+	}
+	return errors.WithMessagef(err, "line %d", line)
+}
+
+// Returns the line number of the last token or zero if there are no tokens.
+func (p *parser) lastLine() int {
+	if len(p.tokens) == 0 {
+		return 0
+	}
+	return p.tokens[len(p.tokens)-1].line
+}
+
+// Parses the statements until the end of the input or the closing brace.
+// The closing brace is not consumed.
+func (p *parser) parseStatements() ([]*Statement, error) {
+	statements := []*Statement{}
+	for p.position < len(p.tokens) {
+		switch p.tokens[p.position].kind {
+		case tokenCloseBrace:
+			return statements, nil
+		case tokenSemicolon:
+			// Ignore the empty statements.
+			p.position++
+			continue
+		}
+		statement, err := p.parseStatement()
+		if err != nil {
+			return nil, err
+		}
+		if p.includer != nil && statement.Name() == "include" {
+			included, err := p.includer.include(statement)
+			if err != nil {
+				return nil, err
+			}
+			statements = append(statements, included...)
+			continue
+		}
+		statements = append(statements, statement)
+	}
+	return statements, nil
+}
+
+// Parses a single statement terminated with a semicolon.
+func (p *parser) parseStatement() (*Statement, error) {
+	statement := &Statement{}
+	for p.position < len(p.tokens) {
+		current := p.tokens[p.position]
+		switch current.kind {
+		case tokenSemicolon:
+			p.position++
+			return statement, nil
+		case tokenCloseBrace:
+			return nil, p.errorf(current.line, "missing semicolon before '}'")
+		case tokenOpenBrace:
+			p.position++
+			statements, err := p.parseStatements()
+			if err != nil {
+				return nil, err
+			}
+			if p.position >= len(p.tokens) {
+				return nil, p.errorf(current.line, "unterminated block")
+			}
+			// Consume the closing brace.
+			p.position++
+			statement.Elements = append(statement.Elements, &Element{
+				Block: &Block{Statements: statements},
+			})
+		default:
+			p.position++
+			statement.Elements = append(statement.Elements, &Element{
+				Word:   current.text,
+				Quoted: current.kind == tokenString,
+			})
+		}
+	}
+	return nil, p.errorf(p.lastLine(), "missing semicolon at the end of the statement")
+}
+
+// Parses the whole configuration. It returns an error if there are
+// unbalanced braces.
+func (p *parser) parse() (*Config, error) {
+	statements, err := p.parseStatements()
+	if err != nil {
+		return nil, err
+	}
+	if p.position < len(p.tokens) {
+		return nil, p.errorf(p.tokens[p.position].line, "unexpected '}'")
+	}
+	return &Config{Statements: statements}, nil
+}
+
+// Parses the file specified in the include statement and returns its
+// statements.
+func (i *includer) include(statement *Statement) ([]*Statement, error) {
+	includedPath := statement.GetWord(1)
+	if includedPath == "" {
+		return nil, errors.New("include statement lacks the file path")
+	}
+	if filepath.IsAbs(includedPath) {
+		includedPath = filepath.Join(i.rootDir, includedPath)
+	} else {
+		includedPath = filepath.Join(i.baseDir, includedPath)
+	}
+	if len(i.stack) >= maxIncludeDepth {
+		return nil, errors.Errorf("too many nested include statements while including %s", includedPath)
+	}
+	for _, parsedPath := range i.stack {
+		if parsedPath == includedPath {
+			return nil, errors.Errorf("include loop detected for %s", includedPath)
+		}
+	}
+	config, err := i.parseFile(includedPath)
+	if err != nil {
+		return nil, err
+	}
+	return config.Statements, nil
+}
+
+// Reads and parses a configuration file resolving its include statements.
+func (i *includer) parseFile(path string) (*Config, error) {
+	contents, err := i.read(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "cannot read %s", path)
+	}
+	i.stack = append(i.stack, path)
+	defer func() {
+		i.stack = i.stack[:len(i.stack)-1]
+	}()
+	p, err := newParser(string(contents), path, i)
+	if err != nil {
+		return nil, err
+	}
+	return p.parse()
+}
+
+// Parses the BIND 9 configuration text. The include statements are left
+// intact. It is appropriate for parsing the output of the named-checkconf
+// -p command which contains the contents of the included files.
+func Parse(text string) (*Config, error) {
+	p, err := newParser(text, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return p.parse()
+}
+
+// Parses the BIND 9 configuration file and the files it includes. The
+// absolute paths in the include statements are relative to the root
+// directory of named, e.g., the chroot directory. The root directory is
+// empty if named runs in the same root directory as the caller. The
+// relative paths are relative to the directory of the parsed file.
+func ParseFile(path, rootDir string) (*Config, error) {
+	i := &includer{
+		rootDir: rootDir,
+		baseDir: filepath.Dir(path),
+		read:    os.ReadFile,
+	}
+	return i.parseFile(path)
+}
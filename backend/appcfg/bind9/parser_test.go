@@ -0,0 +1,133 @@
+package bind9config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the statements with the nested blocks are parsed.
+func TestParse(t *testing.T) {
+	config, err := Parse(`
+		options {
+			directory "/var/cache/bind";
+		};
+		controls {
+			inet 127.0.0.1 port 953 allow { localhost; } keys { "rndc-key"; };
+		};
+	`)
+	require.NoError(t, err)
+	require.Len(t, config.Statements, 2)
+
+	options := config.Statements[0]
+	require.Equal(t, "options", options.Name())
+	require.Len(t, options.Elements, 2)
+	require.True(t, options.Elements[1].IsBlock())
+	require.Equal(t, "/var/cache/bind", options.GetBlock().GetOption("directory"))
+
+	controls := config.Statements[1].GetBlock()
+	require.NotNil(t, controls)
+	inet := controls.GetStatement("inet")
+	require.NotNil(t, inet)
+	require.Len(t, inet.Elements, 8)
+	require.Equal(t, "127.0.0.1", inet.GetWord(1))
+	require.Equal(t, "953", inet.GetKeywordValue("port"))
+	require.Equal(t, []string{"localhost"}, inet.GetKeywordBlock("allow").GetList())
+	require.Equal(t, []string{`"rndc-key"`}, inet.GetKeywordBlock("keys").GetList())
+}
+
+// Test that the empty configuration and the empty statements are accepted.
+func TestParseEmpty(t *testing.T) {
+	config, err := Parse("")
+	require.NoError(t, err)
+	require.Empty(t, config.Statements)
+
+	config, err = Parse("; controls { }; ;")
+	require.NoError(t, err)
+	require.Len(t, config.Statements, 1)
+	require.Empty(t, config.Statements[0].GetBlock().Statements)
+}
+
+// Test that the syntax errors are reported with the line numbers.
+func TestParseErrors(t *testing.T) {
+	testCases := map[string]string{
+		"options {\n\tdirectory \"/var\"\n};":  "line 3: missing semicolon before '}'",
+		"options {\n\tdirectory \"/var\";\n}":  "line 3: missing semicolon at the end of the statement",
+		"options {\n\tdirectory \"/var\";\n":   "line 1: unterminated block",
+		"options { };\n};":                     "line 2: unexpected '}'",
+		"options {\n\tdirectory \"/var;\n};\n": "unterminated quoted string at line 2",
+	}
+	for text, expectedError := range testCases {
+		text := text
+		expectedError := expectedError
+		t.Run(expectedError, func(t *testing.T) {
+			config, err := Parse(text)
+			require.ErrorContains(t, err, expectedError)
+			require.Nil(t, config)
+		})
+	}
+}
+
+// Test that the include statements are left intact when the configuration
+// text is parsed.
+func TestParseInclude(t *testing.T) {
+	config, err := Parse(`include "/etc/bind/named.conf.local";`)
+	require.NoError(t, err)
+	require.Len(t, config.Statements, 1)
+	require.Equal(t, "include", config.Statements[0].Name())
+}
+
+// Test that the included files are parsed and their statements replace
+// the include statements.
+func TestParseFileInclude(t *testing.T) {
+	rootDir := t.TempDir()
+	configDir := filepath.Join(rootDir, "etc", "bind")
+	require.NoError(t, os.MkdirAll(configDir, 0o755))
+
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "named.conf"), []byte(`
+		include "/etc/bind/named.conf.options";
+		view "internal" {
+			include "zones.conf";
+		};
+	`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "named.conf.options"), []byte(`
+		options { directory "/var/cache/bind"; };
+	`), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(configDir, "zones.conf"), []byte(`
+		zone "example.org" { type primary; file "example.org.db"; };
+	`), 0o600))
+
+	config, err := ParseFile(filepath.Join(configDir, "named.conf"), rootDir)
+	require.NoError(t, err)
+	require.Len(t, config.Statements, 2)
+	require.Equal(t, "/var/cache/bind", config.GetOptions().GetOption("directory"))
+
+	views := config.GetViews()
+	require.Len(t, views, 1)
+	require.Len(t, views[0].Zones, 1)
+	require.Equal(t, "example.org", views[0].Zones[0].Name)
+}
+
+// Test that the errors in the included files contain the file names and
+// the include loops are detected.
+func TestParseFileIncludeErrors(t *testing.T) {
+	configDir := t.TempDir()
+	configPath := filepath.Join(configDir, "named.conf")
+	includedPath := filepath.Join(configDir, "included.conf")
+
+	require.NoError(t, os.WriteFile(configPath, []byte(`include "included.conf";`), 0o600))
+	require.NoError(t, os.WriteFile(includedPath, []byte("options {\n};\n}"), 0o600))
+
+	_, err := ParseFile(configPath, "")
+	require.ErrorContains(t, err, includedPath+":3: unexpected '}'")
+
+	require.NoError(t, os.WriteFile(includedPath, []byte(`include "named.conf";`), 0o600))
+	_, err = ParseFile(configPath, "")
+	require.ErrorContains(t, err, "include loop detected")
+
+	require.NoError(t, os.Remove(includedPath))
+	_, err = ParseFile(configPath, "")
+	require.ErrorContains(t, err, "cannot read "+includedPath)
+}
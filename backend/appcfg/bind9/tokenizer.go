@@ -0,0 +1,105 @@
+package bind9config
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Type of the token found in the BIND 9 configuration.
+type tokenType int
+
+// Supported token types.
+const (
+	tokenWord tokenType = iota
+	tokenString
+	tokenOpenBrace
+	tokenCloseBrace
+	tokenSemicolon
+)
+
+// A single token found in the BIND 9 configuration. The text of the
+// quoted string doesn't include the quotes. The line number is used in
+// the error messages.
+type token struct {
+	kind tokenType
+	text string
+	line int
+}
+
+// Checks if the character terminates a word.
+func isWordBoundary(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '{', '}', ';', '"':
+		return true
+	default:
+		return false
+	}
+}
+
+// Splits the BIND 9 configuration into tokens. It skips the comments in
+// the C (/* */), C++ (//) and shell (#) styles. It returns an error if a
+// comment or a quoted string is not terminated.
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+	line := 1
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '#' || strings.HasPrefix(text[i:], "//"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				i = len(text)
+			} else {
+				i += end
+			}
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.Errorf("unterminated comment at line %d", line)
+			}
+			line += strings.Count(text[i:i+2+end], "\n")
+			i += end + 4
+		case c == '{':
+			tokens = append(tokens, token{kind: tokenOpenBrace, text: "{", line: line})
+			i++
+		case c == '}':
+			tokens = append(tokens, token{kind: tokenCloseBrace, text: "}", line: line})
+			i++
+		case c == ';':
+			tokens = append(tokens, token{kind: tokenSemicolon, text: ";", line: line})
+			i++
+		case c == '"':
+			startLine := line
+			var value strings.Builder
+			i++
+			for ; i < len(text) && text[i] != '"'; i++ {
+				if text[i] == '\\' && i+1 < len(text) {
+					i++
+				}
+				if text[i] == '\n' {
+					line++
+				}
+				value.WriteByte(text[i])
+			}
+			if i >= len(text) {
+				return nil, errors.Errorf("unterminated quoted string at line %d", startLine)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: value.String(), line: startLine})
+			i++
+		default:
+			start := i
+			for i < len(text) && !isWordBoundary(text[i]) &&
+				!strings.HasPrefix(text[i:], "//") && !strings.HasPrefix(text[i:], "/*") {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: text[start:i], line: line})
+		}
+	}
+	return tokens, nil
+}
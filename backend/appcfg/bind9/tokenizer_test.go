@@ -0,0 +1,57 @@
+package bind9config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Test that the configuration is split into tokens and the comments are
+// skipped.
+func TestTokenize(t *testing.T) {
+	tokens, err := tokenize(`# shell comment
+	options { // C++ comment
+		directory "/var/cache/bind"; /* C comment
+		spanning multiple lines */ listen-on-v6 { any; };
+	};`)
+	require.NoError(t, err)
+
+	require.Len(t, tokens, 13)
+	require.Equal(t, token{kind: tokenWord, text: "options", line: 2}, tokens[0])
+	require.Equal(t, token{kind: tokenOpenBrace, text: "{", line: 2}, tokens[1])
+	require.Equal(t, token{kind: tokenWord, text: "directory", line: 3}, tokens[2])
+	require.Equal(t, token{kind: tokenString, text: "/var/cache/bind", line: 3}, tokens[3])
+	require.Equal(t, token{kind: tokenSemicolon, text: ";", line: 3}, tokens[4])
+	require.Equal(t, token{kind: tokenWord, text: "listen-on-v6", line: 4}, tokens[5])
+	require.Equal(t, token{kind: tokenWord, text: "any", line: 4}, tokens[7])
+	require.Equal(t, token{kind: tokenCloseBrace, text: "}", line: 5}, tokens[11])
+}
+
+// Test that the words adjacent to the special characters are recognized.
+func TestTokenizeNoSpaces(t *testing.T) {
+	tokens, err := tokenize(`keys{"rndc-key";10.0.0.0/8;}`)
+	require.NoError(t, err)
+	require.Len(t, tokens, 7)
+	require.Equal(t, "keys", tokens[0].text)
+	require.Equal(t, tokenString, tokens[2].kind)
+	require.Equal(t, "rndc-key", tokens[2].text)
+	require.Equal(t, tokenWord, tokens[4].kind)
+	require.Equal(t, "10.0.0.0/8", tokens[4].text)
+}
+
+// Test that the escaped characters in the quoted strings are unescaped.
+func TestTokenizeEscapedQuote(t *testing.T) {
+	tokens, err := tokenize(`"foo \"bar\""`)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	require.Equal(t, `foo "bar"`, tokens[0].text)
+}
+
+// Test that an error is returned for the unterminated comments and strings.
+func TestTokenizeUnterminated(t *testing.T) {
+	_, err := tokenize("options {\n/* comment")
+	require.ErrorContains(t, err, "unterminated comment at line 2")
+
+	_, err = tokenize("options {\ndirectory \"/var;\n};")
+	require.ErrorContains(t, err, "unterminated quoted string at line 2")
+}
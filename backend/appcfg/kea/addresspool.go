@@ -30,7 +30,10 @@ type PoolParameters struct {
 
 // Represents an address pool structure within a Kea configuration.
 type Pool struct {
-	Pool       string             `json:"pool"`
+	Pool string `json:"pool"`
+	// Identifier of the pool used in the names of the pool statistics.
+	// The pools without the identifier have the default identifier of 0.
+	PoolID     int64              `json:"pool-id,omitempty"`
 	OptionData []SingleOptionData `json:"option-data,omitempty"`
 	ClientClassParameters
 }
@@ -34,6 +34,18 @@ func TestParseAddressPoolPrefix(t *testing.T) {
 	require.Equal(t, "192.1.0.0-192.1.255.255", pool.Pool)
 }
 
+// Test parsing the pool identifier.
+func TestParseAddressPoolID(t *testing.T) {
+	input := `{
+		"pool": "192.0.2.1-192.0.2.10",
+		"pool-id": 5
+	}`
+	var pool Pool
+	err := json.Unmarshal([]byte(input), &pool)
+	require.NoError(t, err)
+	require.EqualValues(t, 5, pool.PoolID)
+}
+
 // Test parsing an IPv6 address range, ensuring that the whitespace
 // is removed between the lower bound and the upper bound.
 func TestParseAddressPoolIPv6Range(t *testing.T) {
@@ -4,8 +4,9 @@ var _ commonConfigAccessor = (*D2Config)(nil)
 
 // Represents a D2 (DHCP-DDNS) Kea configuration.
 type D2Config struct {
-	HookLibraries []HookLibrary `json:"hooks-libraries"`
-	Loggers       []Logger      `json:"loggers"`
+	ControlSocket *ControlSocket `json:"control-socket"`
+	HookLibraries []HookLibrary  `json:"hooks-libraries"`
+	Loggers       []Logger       `json:"loggers"`
 }
 
 // Returns the hook libraries configured in the D2 server.
@@ -38,10 +38,11 @@ type Peer struct {
 	AutoFailover *bool   `json:"auto-failover"`
 }
 
-// Convenience function returning the first HA configuration. Note that Kea in
-// fact supports only one configuration these days. It may be extended in the
-// future but for now it is convenient to directly access the first HA
-// configuration.
+// Convenience function returning the first HA configuration. Kea 2.4.0 and
+// later supports multiple HA relationships in the hub-and-spoke configuration.
+// Use this function only to access the parameters that must be the same in
+// all relationships, e.g., the multi-threading configuration. Use the
+// GetAllValid function to access all relationships.
 func (params HALibraryParams) GetFirst() *HA {
 	if len(params.HA) > 0 {
 		return &params.HA[0]
@@ -49,6 +50,44 @@ func (params HALibraryParams) GetFirst() *HA {
 	return &HA{}
 }
 
+// Returns all valid HA relationships configured for a Kea server. A server
+// that isn't a hub in the hub-and-spoke configuration has one relationship.
+func (params HALibraryParams) GetAllValid() (relationships []HA) {
+	for _, relationship := range params.HA {
+		if relationship.IsValid() {
+			relationships = append(relationships, relationship)
+		}
+	}
+	return relationships
+}
+
+// Returns the peer configuration of the server specified by this-server-name
+// or nil if such peer is not configured.
+func (c HA) GetThisServer() *Peer {
+	if c.ThisServerName == nil {
+		return nil
+	}
+	for i, p := range c.Peers {
+		if p.Name != nil && *p.Name == *c.ThisServerName {
+			return &c.Peers[i]
+		}
+	}
+	return nil
+}
+
+// Returns the name identifying the HA relationship. Kea doesn't name the
+// relationships, but the server names are unique across all relationships
+// of a hub. Therefore, the relationship is identified by the name of its
+// primary server. It returns an empty string if there is no primary server.
+func (c HA) GetRelationshipName() string {
+	for _, p := range c.Peers {
+		if p.Role != nil && *p.Role == "primary" && p.Name != nil {
+			return *p.Name
+		}
+	}
+	return ""
+}
+
 // Checks if the mandatory Kea HA configuration parameters are set. It doesn't
 // check parameters consistency, though.
 func (c HA) IsValid() bool {
@@ -46,3 +46,47 @@ func TestHAConfigParametersValid(t *testing.T) {
 	cfg.Peers = append(cfg.Peers, p)
 	require.False(t, cfg.IsValid())
 }
+
+// Checks if the valid HA relationships are returned.
+func TestGetAllValidRelationships(t *testing.T) {
+	thisServerName := "server1"
+	haMode := "load-balancing"
+	params := HALibraryParams{
+		HA: []HA{
+			{
+				ThisServerName: &thisServerName,
+				Mode:           &haMode,
+			},
+			{
+				Mode: &haMode,
+			},
+			{
+				ThisServerName: &thisServerName,
+				Mode:           &haMode,
+			},
+		},
+	}
+	require.Len(t, params.GetAllValid(), 2)
+	require.Empty(t, HALibraryParams{}.GetAllValid())
+}
+
+// Checks if this server's peer and the relationship name are returned.
+func TestGetThisServerAndRelationshipName(t *testing.T) {
+	names := []string{"server1", "server2"}
+	roles := []string{"secondary", "primary"}
+	url := "http://example.org/"
+	cfg := HA{
+		ThisServerName: &names[0],
+		Peers: []Peer{
+			{Name: &names[0], URL: &url, Role: &roles[0]},
+			{Name: &names[1], URL: &url, Role: &roles[1]},
+		},
+	}
+	require.NotNil(t, cfg.GetThisServer())
+	require.Equal(t, "server1", *cfg.GetThisServer().Name)
+	require.Equal(t, "server2", cfg.GetRelationshipName())
+
+	cfg.ThisServerName = nil
+	require.Nil(t, cfg.GetThisServer())
+	require.Empty(t, HA{}.GetRelationshipName())
+}
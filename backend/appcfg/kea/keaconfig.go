@@ -343,6 +343,41 @@ func (c *Config) GetLoggers() (loggers []Logger) {
 	return
 }
 
+// Returns the control socket configured for the DHCP server or the D2
+// server. It returns nil if the control socket is not configured or if
+// the configuration belongs to the Kea Control Agent.
+func (c *Config) GetControlSocket() *ControlSocket {
+	switch {
+	case c.IsDHCPv4():
+		return c.DHCPv4Config.ControlSocket
+	case c.IsDHCPv6():
+		return c.DHCPv6Config.ControlSocket
+	case c.IsD2():
+		return c.D2Config.ControlSocket
+	default:
+		return nil
+	}
+}
+
+// Returns the name of the daemon owning the configuration. The names
+// are the same as the ones used in the service list of the Kea commands,
+// i.e., "ca", "dhcp4", "dhcp6" and "d2". It returns an empty string if
+// the configuration type is unknown.
+func (c *Config) GetDaemonName() string {
+	switch {
+	case c.IsCtrlAgent():
+		return "ca"
+	case c.IsDHCPv4():
+		return "dhcp4"
+	case c.IsDHCPv6():
+		return "dhcp6"
+	case c.IsD2():
+		return "d2"
+	default:
+		return ""
+	}
+}
+
 // Finds and returns a subnet (i.e., Subnet4 or Subnet6) having the specified
 // prefix. The type of the returned object behind the interface depends on
 // the type of the configured DHCP server. It always returns a nil interface
@@ -390,6 +425,95 @@ func (c *Config) GetDHCPOptions() (options []SingleOptionData) {
 	return
 }
 
+// Returns true if the DHCP server fetches its configuration from the
+// config backend, i.e., the config-control holds at least one config
+// database. It always returns false for non-DHCP servers.
+func (c *Config) UsesConfigBackend() bool {
+	return len(c.GetAllDatabases().Config) > 0
+}
+
+// Merges the partial configuration of the DHCP server into its raw
+// configuration and returns the merged configuration suitable for the
+// config-test and config-set commands. The partial configuration holds
+// the parameters at the server level (e.g., valid-lifetime), without the
+// top-level Dhcp4 or Dhcp6 key. The maps are merged recursively. The other
+// values, including lists (e.g., option-data), replace the existing values.
+// A nil value removes the parameter from the configuration, so the server
+// uses its default value. The merged configuration doesn't include the
+// top-level entries other than the server configuration (e.g., the hash
+// returned by the recent Kea versions). The original configuration is not
+// modified. It returns an error for non-DHCP servers.
+func (c *Config) MergeDHCPParameters(partial RawConfig) (RawConfig, error) {
+	rootName := c.GetRootName()
+	if rootName != "Dhcp4" && rootName != "Dhcp6" {
+		return nil, errors.New("global parameters can only be merged into the DHCP server configuration")
+	}
+	// Copy the existing configuration, so it is not modified by merging.
+	marshalled, err := json.Marshal(c.Raw[rootName])
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem copying the %s configuration", rootName)
+	}
+	var merged map[string]any
+	if err = json.Unmarshal(marshalled, &merged); err != nil {
+		return nil, errors.Wrapf(err, "problem copying the %s configuration", rootName)
+	}
+	if merged == nil {
+		merged = make(map[string]any)
+	}
+	mergeRawMaps(merged, partial)
+	return RawConfig{
+		rootName: merged,
+	}, nil
+}
+
+// Returns the top-level key of the configuration, i.e., "Control-agent",
+// "DhcpDdns", "Dhcp4" or "Dhcp6". It returns an empty string if the
+// configuration type is unknown.
+func (c *Config) GetRootName() string {
+	switch {
+	case c.IsCtrlAgent():
+		return "Control-agent"
+	case c.IsD2():
+		return "DhcpDdns"
+	case c.IsDHCPv4():
+		return "Dhcp4"
+	case c.IsDHCPv6():
+		return "Dhcp6"
+	default:
+		return ""
+	}
+}
+
+// Returns the configuration suitable for the config-test and config-set
+// commands. It contains the top-level configuration element of the server
+// only. Other top-level parameters, e.g., the hash returned by the recent
+// Kea versions in the config-get response, are excluded.
+func (c *Config) GetSettableConfig() RawConfig {
+	rootName := c.GetRootName()
+	if rootName == "" {
+		return nil
+	}
+	return RawConfig{rootName: c.Raw[rootName]}
+}
+
+// Recursively merges the source map into the destination map. The nil
+// values remove the respective entries from the destination map.
+func mergeRawMaps(dst, src map[string]any) {
+	for key, srcValue := range src {
+		if srcValue == nil {
+			delete(dst, key)
+			continue
+		}
+		srcMap, srcIsMap := srcValue.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			mergeRawMaps(dstMap, srcMap)
+			continue
+		}
+		dst[key] = srcValue
+	}
+}
+
 // Recursively hides sensitive data in the configuration. It traverses the raw
 // configuration and nullifies the values for the following keys: password,
 // secret, token. It doesn't modify the parsed configuration.
@@ -397,14 +521,50 @@ func (c *Config) HideSensitiveData() {
 	hideSensitiveData((*map[string]any)(&c.Raw))
 }
 
+// Checks if the key holds sensitive data, i.e., it is password, secret or
+// token.
+func isSensitiveKey(key string) bool {
+	keyNormalized := strings.ToLower(key)
+	return keyNormalized == "password" || keyNormalized == "secret" || keyNormalized == "token"
+}
+
+// Checks if the configuration contains any of the keys holding sensitive
+// data (password, secret, token) at any level. The users who see the
+// configurations with the hidden sensitive data must not submit such keys
+// because their values can't be distinguished from the deleted ones.
+func (c RawConfig) HasSensitiveData() bool {
+	return hasSensitiveData(c)
+}
+
+// Recursively checks if the value contains any of the keys holding
+// sensitive data.
+func hasSensitiveData(value any) bool {
+	switch v := value.(type) {
+	case RawConfig:
+		return hasSensitiveData(map[string]any(v))
+	case map[string]any:
+		for key, entryValue := range v {
+			if isSensitiveKey(key) || hasSensitiveData(entryValue) {
+				return true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if hasSensitiveData(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // Hides the sensitive data in the configuration map. It traverses the raw
 // configuration and nullifies the values for the following keys: password,
 // secret, token.
 func hideSensitiveData(obj *map[string]any) {
 	for entryKey, entryValue := range *obj {
 		// Check if the value holds sensitive data.
-		entryKeyNormalized := strings.ToLower(entryKey)
-		if entryKeyNormalized == "password" || entryKeyNormalized == "secret" || entryKeyNormalized == "token" {
+		if isSensitiveKey(entryKey) {
 			(*obj)[entryKey] = nil
 			continue
 		}
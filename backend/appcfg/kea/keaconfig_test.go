@@ -393,6 +393,53 @@ func TestGetControlSockets(t *testing.T) {
 	require.Nil(t, sockets.NetConf)
 }
 
+// Verifies that the control socket configured in the DHCP server is returned.
+func TestGetControlSocket(t *testing.T) {
+	configStr := `{
+        "Dhcp4": {
+            "control-socket": {
+                "socket-type": "unix",
+                "socket-name": "/path/to/the/unix/socket-v4"
+            }
+        }
+    }`
+
+	cfg, err := NewConfig(configStr)
+	require.NoError(t, err)
+	require.NotNil(t, cfg)
+
+	socket := cfg.GetControlSocket()
+	require.NotNil(t, socket)
+	require.Equal(t, "unix", socket.SocketType)
+	require.Equal(t, "/path/to/the/unix/socket-v4", socket.SocketName)
+}
+
+// Verifies that nil is returned if the control socket is not configured
+// or the configuration belongs to the Control Agent.
+func TestGetControlSocketForMissingEntry(t *testing.T) {
+	cfg, err := NewConfig(`{ "Dhcp6": { } }`)
+	require.NoError(t, err)
+	require.Nil(t, cfg.GetControlSocket())
+
+	cfg, err = NewConfig(`{ "Control-agent": { } }`)
+	require.NoError(t, err)
+	require.Nil(t, cfg.GetControlSocket())
+}
+
+// Verifies that the daemon name is derived from the configuration type.
+func TestGetDaemonName(t *testing.T) {
+	for configStr, name := range map[string]string{
+		`{ "Control-agent": { } }`: "ca",
+		`{ "Dhcp4": { } }`:         "dhcp4",
+		`{ "Dhcp6": { } }`:         "dhcp6",
+		`{ "DhcpDdns": { } }`:      "d2",
+	} {
+		cfg, err := NewConfig(configStr)
+		require.NoError(t, err)
+		require.Equal(t, name, cfg.GetDaemonName())
+	}
+}
+
 // Verifies that nil is returned if the control-sockets entry is not configured.
 func TestGetControlSocketsForMissingEntry(t *testing.T) {
 	configStr := `{ "Control-agent": { } }`
@@ -1659,3 +1706,190 @@ func TestGetDHCPOptions6(t *testing.T) {
 	require.Equal(t, "nis-servers", options[1].Name)
 	require.Equal(t, dhcpmodel.DHCPv6OptionSpace, options[0].Space)
 }
+
+// Test checking whether the DHCP server uses the config backend.
+func TestUsesConfigBackend(t *testing.T) {
+	cfg, err := NewConfig(`{
+		"Dhcp4": {
+			"config-control": {
+				"config-databases": [
+					{
+						"type": "mysql",
+						"name": "kea"
+					}
+				]
+			}
+		}
+	}`)
+	require.NoError(t, err)
+	require.True(t, cfg.UsesConfigBackend())
+
+	cfg, err = NewConfig(`{
+		"Dhcp6": {
+			"config-control": {
+				"config-databases": []
+			}
+		}
+	}`)
+	require.NoError(t, err)
+	require.False(t, cfg.UsesConfigBackend())
+
+	cfg, err = NewConfig(`{ "Control-agent": { } }`)
+	require.NoError(t, err)
+	require.False(t, cfg.UsesConfigBackend())
+}
+
+// Test getting the top-level configuration key.
+func TestGetRootName(t *testing.T) {
+	for name, configStr := range map[string]string{
+		"Control-agent": `{ "Control-agent": { } }`,
+		"DhcpDdns":      `{ "DhcpDdns": { } }`,
+		"Dhcp4":         `{ "Dhcp4": { } }`,
+		"Dhcp6":         `{ "Dhcp6": { } }`,
+	} {
+		cfg, err := NewConfig(configStr)
+		require.NoError(t, err)
+		require.Equal(t, name, cfg.GetRootName())
+	}
+}
+
+// Test getting the configuration suitable for the config-set command.
+func TestGetSettableConfig(t *testing.T) {
+	cfg, err := NewConfig(`{
+		"Dhcp4": {
+			"valid-lifetime": 1000
+		},
+		"hash": "ABCDEF"
+	}`)
+	require.NoError(t, err)
+
+	settable := cfg.GetSettableConfig()
+	require.Len(t, settable, 1)
+	require.Contains(t, settable, "Dhcp4")
+	require.EqualValues(t, 1000, settable["Dhcp4"].(map[string]any)["valid-lifetime"])
+}
+
+// Test merging the partial configuration into the DHCP server configuration.
+func TestMergeDHCPParameters(t *testing.T) {
+	cfg, err := NewConfig(`{
+		"Dhcp4": {
+			"valid-lifetime": 1000,
+			"renew-timer": 500,
+			"lease-database": {
+				"type": "memfile",
+				"lfc-interval": 3600
+			},
+			"option-data": [
+				{
+					"name": "routers",
+					"data": "192.0.2.1"
+				}
+			],
+			"subnet4": [
+				{
+					"id": 1,
+					"subnet": "192.0.2.0/24"
+				}
+			]
+		},
+		"hash": "abcd"
+	}`)
+	require.NoError(t, err)
+
+	merged, err := cfg.MergeDHCPParameters(RawConfig{
+		"valid-lifetime": 2000,
+		"renew-timer":    nil,
+		"lease-database": map[string]any{
+			"lfc-interval": 7200,
+		},
+		"option-data": []any{
+			map[string]any{
+				"name": "domain-name-servers",
+				"data": "192.0.2.2",
+			},
+		},
+		"authoritative": true,
+	})
+	require.NoError(t, err)
+
+	marshalled, err := json.Marshal(merged)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"Dhcp4": {
+			"valid-lifetime": 2000,
+			"authoritative": true,
+			"lease-database": {
+				"type": "memfile",
+				"lfc-interval": 7200
+			},
+			"option-data": [
+				{
+					"name": "domain-name-servers",
+					"data": "192.0.2.2"
+				}
+			],
+			"subnet4": [
+				{
+					"id": 1,
+					"subnet": "192.0.2.0/24"
+				}
+			]
+		}
+	}`, string(marshalled))
+
+	// The original configuration should not be modified.
+	dhcp4, ok := cfg.Raw["Dhcp4"].(map[string]any)
+	require.True(t, ok)
+	require.EqualValues(t, 1000, dhcp4["valid-lifetime"])
+	require.EqualValues(t, 500, dhcp4["renew-timer"])
+	require.Contains(t, cfg.Raw, "hash")
+}
+
+// Test that merging the partial configuration fails for non-DHCP servers.
+func TestMergeDHCPParametersNonDHCP(t *testing.T) {
+	cfg, err := NewConfig(`{ "DhcpDdns": { } }`)
+	require.NoError(t, err)
+
+	merged, err := cfg.MergeDHCPParameters(RawConfig{
+		"valid-lifetime": 2000,
+	})
+	require.Error(t, err)
+	require.Nil(t, merged)
+}
+
+// Test that the keys holding sensitive data are found at any level of
+// the configuration.
+func TestRawConfigHasSensitiveData(t *testing.T) {
+	require.False(t, RawConfig{
+		"valid-lifetime": 2000,
+		"lease-database": map[string]any{
+			"type": "memfile",
+		},
+		"option-data": []any{
+			map[string]any{
+				"name": "domain-name-servers",
+			},
+		},
+	}.HasSensitiveData())
+
+	require.True(t, RawConfig{
+		"lease-database": map[string]any{
+			"Password": nil,
+		},
+	}.HasSensitiveData())
+
+	require.True(t, RawConfig{
+		"hooks-libraries": []any{
+			map[string]any{
+				"library": "libdhcp_example.so",
+				"parameters": map[string]any{
+					"token": "foo",
+				},
+			},
+		},
+	}.HasSensitiveData())
+
+	require.True(t, RawConfig{
+		"secret": "bar",
+	}.HasSensitiveData())
+}
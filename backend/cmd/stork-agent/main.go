@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"os/user"
 	"strconv"
+	"strings"
 	"syscall"
 
 	"github.com/pkg/errors"
@@ -103,24 +104,52 @@ func runAgent(settings *cli.Context, reload bool) error {
 	storkAgent := agent.NewStorkAgent(settings, appMonitor, httpClient, hookManager)
 
 	// Prepare Prometheus exporters.
-	promKeaExporter := agent.NewPromKeaExporter(settings, appMonitor, httpClient)
-	promBind9Exporter := agent.NewPromBind9Exporter(settings, appMonitor, httpClient)
+	components := &reloadableComponents{
+		settings:          settings,
+		appMonitor:        appMonitor,
+		httpClient:        httpClient,
+		storkAgent:        storkAgent,
+		promKeaExporter:   agent.NewPromKeaExporter(settings, appMonitor, httpClient),
+		promBind9Exporter: agent.NewPromBind9Exporter(settings, appMonitor, httpClient),
+		selfMonitoring:    agent.NewSelfMonitoringServer(settings, storkAgent),
+	}
 
 	err = storkAgent.Setup()
 	if err != nil {
 		log.Fatalf("FATAL error: %+v", err)
 	}
 
+	// Prepare the outbox sampling the Kea statistics for the server.
+	keaStatsOutbox, err := agent.NewKeaStatsOutbox(settings, appMonitor, httpClient)
+	if err != nil {
+		log.WithError(err).Error("Could not initialize the Kea statistics outbox")
+	}
+	storkAgent.KeaStatsOutbox = keaStatsOutbox
+
 	// Let's start the app monitor.
 	appMonitor.Start(storkAgent)
 
+	if keaStatsOutbox != nil {
+		keaStatsOutbox.Start()
+		defer keaStatsOutbox.Shutdown()
+	}
+
+	// Expose the agent's own metrics and health. The server may be
+	// replaced on reload.
+	components.selfMonitoring.Start()
+	defer func() {
+		components.selfMonitoring.Shutdown()
+	}()
+
 	// Only start the exporters if they're enabled.
 	if !settings.Bool("listen-stork-only") {
-		promKeaExporter.Start()
-		defer promKeaExporter.Shutdown()
-
-		promBind9Exporter.Start()
-		defer promBind9Exporter.Shutdown()
+		components.promKeaExporter.Start()
+		components.promBind9Exporter.Start()
+		// The exporters may be replaced on reload.
+		defer func() {
+			components.promKeaExporter.Shutdown()
+			components.promBind9Exporter.Shutdown()
+		}()
 	}
 
 	// Only start the agent service if it's enabled.
@@ -133,24 +162,188 @@ func runAgent(settings *cli.Context, reload bool) error {
 		defer storkAgent.Shutdown(reload)
 	}
 
+	// Watch the files read at startup to apply their changes in place.
+	watchedFiles := []string{agent.CredentialsFile, agent.KeyPEMFile, agent.CertPEMFile, agent.RootCAFile}
+	if settings.Bool("use-env-file") {
+		watchedFiles = append(watchedFiles, settings.Path("env-file"))
+	}
+	fileWatcher := agent.NewFileWatcher(agent.DefaultFileWatcherInterval, watchedFiles...)
+	fileWatcher.Start()
+	defer fileWatcher.Shutdown()
+
 	// Handle signals.
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGHUP)
-	sig := <-c
-	switch sig {
-	case syscall.SIGHUP:
-		log.Info("Reloading Stork Agent after receiving SIGHUP signal")
-		// Trigger shutdown with setting the reload flag. It doesn't
-		// matter we have deferred another shutdown already. It will
-		// be executed only once.
-		storkAgent.Shutdown(true)
-		return &sighupError{}
-	default:
-		log.Info("Received Ctrl-C signal")
-		return &ctrlcError{}
+	defer signal.Stop(c)
+	for {
+		select {
+		case <-fileWatcher.Changes():
+			if components.reload() {
+				log.Warning("Some of the changed settings are applied only after restarting the Stork Agent or sending it a SIGHUP signal")
+			}
+		case sig := <-c:
+			if sig != syscall.SIGHUP {
+				log.Info("Received Ctrl-C signal")
+				return &ctrlcError{}
+			}
+			log.Info("Reloading Stork Agent after receiving SIGHUP signal")
+			if !components.reload() {
+				continue
+			}
+			log.Info("Restarting Stork Agent to apply the changed settings")
+			// Trigger shutdown with setting the reload flag. It doesn't
+			// matter we have deferred another shutdown already. It will
+			// be executed only once.
+			storkAgent.Shutdown(true)
+			return &sighupError{}
+		}
+	}
+}
+
+// Names of the settings used by the Prometheus Kea exporter. The exporter is
+// restarted on reload when any of them changes.
+var promKeaExporterSettings = []string{ //nolint:gochecknoglobals
+	"prometheus-kea-exporter-address",
+	"prometheus-kea-exporter-port",
+	"prometheus-kea-exporter-interval",
+	"prometheus-kea-exporter-per-subnet-stats",
+}
+
+// Names of the settings used by the Prometheus BIND 9 exporter. The exporter
+// is restarted on reload when any of them changes.
+var promBind9ExporterSettings = []string{ //nolint:gochecknoglobals
+	"prometheus-bind9-exporter-address",
+	"prometheus-bind9-exporter-port",
+	"prometheus-bind9-exporter-interval",
+}
+
+// Names of the settings used by the self-monitoring endpoint. The endpoint
+// is restarted on reload when any of them changes.
+var selfMonitoringSettings = []string{ //nolint:gochecknoglobals
+	"self-monitoring-address",
+	"self-monitoring-port",
+}
+
+// Names of the settings which changes are applied only by restarting the
+// agent.
+var restartRequiredSettings = []string{ //nolint:gochecknoglobals
+	"host",
+	"port",
+	"listen-prometheus-only",
+	"listen-stork-only",
+	"skip-tls-cert-verification",
+	"server-url",
+	"hook-directory",
+	"stats-outbox-interval",
+	"stats-outbox-directory",
+	"stats-outbox-capacity",
+}
+
+// The agent components which configuration can be reloaded without
+// restarting the agent.
+type reloadableComponents struct {
+	settings          *cli.Context
+	appMonitor        agent.AppMonitor
+	httpClient        *agent.HTTPClient
+	storkAgent        *agent.StorkAgent
+	promKeaExporter   *agent.PromKeaExporter
+	promBind9Exporter *agent.PromBind9Exporter
+	selfMonitoring    *agent.SelfMonitoringServer
+}
+
+// Applies the changes in the TLS certificates, the credentials file and
+// the settings. The established connections with the Stork Server are
+// preserved. The exporters are restarted only if their settings have
+// changed. It returns true if some of the settings have changed and the
+// agent must be restarted to apply them.
+func (rc *reloadableComponents) reload() (restartRequired bool) {
+	if err := rc.storkAgent.ReloadTLSIdentity(); err != nil {
+		log.WithError(err).Error("Could not reload the TLS certificates; the previous ones are still used")
+	}
+
+	if err := rc.httpClient.ReloadCredentials(); err != nil {
+		log.WithError(err).Error("Could not reload the credentials; the previous ones are still used")
+	}
+
+	settings, err := readSettings()
+	if err != nil {
+		log.WithError(err).Error("Could not reload the settings; the previous ones are still used")
+		return false
+	}
+
+	if settingsChanged(rc.settings, settings, restartRequiredSettings) {
+		return true
+	}
+
+	if settingsChanged(rc.settings, settings, selfMonitoringSettings) {
+		rc.selfMonitoring.Shutdown()
+		rc.selfMonitoring = agent.NewSelfMonitoringServer(settings, rc.storkAgent)
+		rc.selfMonitoring.Start()
+	}
+
+	if !settings.Bool("listen-stork-only") {
+		if settingsChanged(rc.settings, settings, promKeaExporterSettings) {
+			rc.promKeaExporter.Shutdown()
+			rc.promKeaExporter = agent.NewPromKeaExporter(settings, rc.appMonitor, rc.httpClient)
+			rc.promKeaExporter.Start()
+		}
+		if settingsChanged(rc.settings, settings, promBind9ExporterSettings) {
+			rc.promBind9Exporter.Shutdown()
+			rc.promBind9Exporter = agent.NewPromBind9Exporter(settings, rc.appMonitor, rc.httpClient)
+			rc.promBind9Exporter.Start()
+		}
+	}
+	rc.settings = settings
+	return false
+}
+
+// Checks if any of the specified settings has a different value.
+func settingsChanged(previous, current *cli.Context, names []string) bool {
+	for _, name := range names {
+		if fmt.Sprint(previous.Value(name)) != fmt.Sprint(current.Value(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The environment variables of the process before any environment file was
+// loaded. The settings are read again from this environment, so the
+// variables removed from the environment file are no longer used.
+var startupEnvironment = os.Environ() //nolint:gochecknoglobals
+
+// Replaces the environment variables of the process with the ones it was
+// started with. It unsets the variables loaded from the environment file.
+func restoreStartupEnvironment() {
+	os.Clearenv()
+	for _, pair := range startupEnvironment {
+		key, value, _ := strings.Cut(pair, "=")
+		os.Setenv(key, value)
 	}
 }
 
+// Parses the command line arguments, the environment variables and the
+// environment file again to get the current settings. The environment file
+// is applied to the startup environment of the process rather than to the
+// variables loaded from its previous content.
+func readSettings() (*cli.Context, error) {
+	restoreStartupEnvironment()
+
+	var settings *cli.Context
+	app := setupApp(false)
+	app.Action = func(c *cli.Context) error {
+		settings = c
+		return nil
+	}
+	if err := app.Run(os.Args); err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		return nil, errors.New("the agent is not started with the command line arguments")
+	}
+	return settings, nil
+}
+
 // Helper function that checks command line options and runs registration.
 func runRegister(cfg *cli.Context) {
 	agentAddr := ""
@@ -275,6 +468,38 @@ func setupApp(reload bool) *cli.App {
 				Usage:   "How often the Stork Agent collects stats from BIND 9, in seconds",
 				EnvVars: []string{"STORK_AGENT_PROMETHEUS_BIND9_EXPORTER_INTERVAL"},
 			},
+			// Self-monitoring settings
+			&cli.StringFlag{
+				Name:    "self-monitoring-address",
+				Value:   "127.0.0.1",
+				Usage:   "The IP or hostname to listen on for the requests for the Stork Agent's own metrics and health",
+				EnvVars: []string{"STORK_AGENT_SELF_MONITORING_ADDRESS"},
+			},
+			&cli.IntFlag{
+				Name:    "self-monitoring-port",
+				Value:   9548,
+				Usage:   "The port to listen on for the requests for the Stork Agent's own metrics and health; 0 disables the endpoint",
+				EnvVars: []string{"STORK_AGENT_SELF_MONITORING_PORT"},
+			},
+			// Kea statistics outbox settings
+			&cli.IntFlag{
+				Name:    "stats-outbox-interval",
+				Value:   0,
+				Usage:   "How often the Stork Agent samples stats from Kea for the Stork Server, in seconds; 0 (default) disables sampling",
+				EnvVars: []string{"STORK_AGENT_STATS_OUTBOX_INTERVAL"},
+			},
+			&cli.StringFlag{
+				Name:    "stats-outbox-directory",
+				Value:   "/var/lib/stork-agent/stats-outbox",
+				Usage:   "The directory where the Stork Agent stores the sampled stats until the Stork Server fetches them",
+				EnvVars: []string{"STORK_AGENT_STATS_OUTBOX_DIRECTORY"},
+			},
+			&cli.IntFlag{
+				Name:    "stats-outbox-capacity",
+				Value:   10000,
+				Usage:   "The maximum number of the sampled stats stored by the Stork Agent; the oldest samples are removed when it is exceeded",
+				EnvVars: []string{"STORK_AGENT_STATS_OUTBOX_CAPACITY"},
+			},
 			&cli.BoolFlag{
 				Name:    "skip-tls-cert-verification",
 				Value:   false,
@@ -393,6 +618,9 @@ authorization in the server using either the UI or the ReST API (agent-token-bas
 func main() {
 	reload := false
 	for {
+		if reload {
+			restoreStartupEnvironment()
+		}
 		storkutil.SetupLogging()
 		app := setupApp(reload)
 		err := app.Run(os.Args)
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"io"
 	"os"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
 	"isc.org/stork"
 	"isc.org/stork/testutil"
 )
@@ -153,3 +155,69 @@ func TestCtrlCError(t *testing.T) {
 	err := &ctrlcError{}
 	require.Equal(t, "received Ctrl-C signal", err.Error())
 }
+
+// Test that the changes of the selected settings are detected.
+func TestSettingsChanged(t *testing.T) {
+	// Arrange
+	newSettings := func(port int, address string) *cli.Context {
+		flags := flag.NewFlagSet("test", 0)
+		flags.Int("prometheus-kea-exporter-port", port, "usage")
+		flags.String("prometheus-kea-exporter-address", address, "usage")
+		return cli.NewContext(nil, flags, nil)
+	}
+	previous := newSettings(9547, "0.0.0.0")
+
+	// Act & Assert
+	require.False(t, settingsChanged(previous, newSettings(9547, "0.0.0.0"), promKeaExporterSettings))
+	require.True(t, settingsChanged(previous, newSettings(9548, "0.0.0.0"), promKeaExporterSettings))
+	require.True(t, settingsChanged(previous, newSettings(9547, "127.0.0.1"), promKeaExporterSettings))
+	require.False(t, settingsChanged(previous, newSettings(9548, "127.0.0.1"), promBind9ExporterSettings))
+}
+
+// Test that the settings are read from the command line arguments.
+func TestReadSettings(t *testing.T) {
+	// Arrange
+	defer testutil.CreateOsArgsRestorePoint()()
+	os.Args = []string{"stork-agent", "--prometheus-kea-exporter-port", "9999"}
+
+	// Act
+	settings, err := readSettings()
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 9999, settings.Int("prometheus-kea-exporter-port"))
+	require.EqualValues(t, 9119, settings.Int("prometheus-bind9-exporter-port"))
+}
+
+// Test that the variables removed from the environment file are not used
+// after reading the settings again.
+func TestReadSettingsRemovedEnvironmentFileVariable(t *testing.T) {
+	// Arrange
+	defer testutil.CreateOsArgsRestorePoint()()
+	defer testutil.CreateEnvironmentRestorePoint()()
+	defer func(environment []string) {
+		startupEnvironment = environment
+	}(startupEnvironment)
+	startupEnvironment = os.Environ()
+
+	sb := testutil.NewSandbox()
+	defer sb.Close()
+	envPath, _ := sb.Write("agent.env", "STORK_AGENT_PROMETHEUS_KEA_EXPORTER_PORT=9999")
+	os.Args = []string{"stork-agent", "--use-env-file", "--env-file", envPath}
+
+	settings, err := readSettings()
+	require.NoError(t, err)
+	require.EqualValues(t, 9999, settings.Int("prometheus-kea-exporter-port"))
+
+	_, _ = sb.Write("agent.env", "STORK_AGENT_PROMETHEUS_KEA_EXPORTER_INTERVAL=20")
+
+	// Act
+	settings, err = readSettings()
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 9547, settings.Int("prometheus-kea-exporter-port"))
+	require.EqualValues(t, 20, settings.Int("prometheus-kea-exporter-interval"))
+	_, ok := os.LookupEnv("STORK_AGENT_PROMETHEUS_KEA_EXPORTER_PORT")
+	require.False(t, ok)
+}
@@ -198,6 +198,22 @@ func runCertImport(settings *cli.Context) error {
 	return certs.ImportSecret(db, settings.String("object"), settings.String("file"))
 }
 
+// Execute CA rollover start command.
+func runCARolloverStart(settings *cli.Context) error {
+	db := getDBConn(settings)
+	defer db.Close()
+
+	return certs.StartCARollover(db)
+}
+
+// Execute CA rollover finish command.
+func runCARolloverFinish(settings *cli.Context) error {
+	db := getDBConn(settings)
+	defer db.Close()
+
+	return certs.FinishCARollover(db)
+}
+
 // Inspect the hook file.
 func inspectHookFile(path string, library *hooksutil.LibraryManager, err error) {
 	if err != nil {
@@ -524,6 +540,24 @@ func setupApp() *cli.App {
 				Category:    "Certificates Management",
 				Action:      runCertImport,
 			},
+			{
+				Name:        "cert-rollover-start",
+				Usage:       "Generate the next server CA and start signing the agent certificates with it",
+				UsageText:   "stork-tool cert-rollover-start [options for db connection]",
+				Description: `The server must be restarted to trust the agent certificates signed by the next CA. The agents renew their certificates automatically.`,
+				Flags:       dbFlags,
+				Category:    "Certificates Management",
+				Action:      runCARolloverStart,
+			},
+			{
+				Name:        "cert-rollover-finish",
+				Usage:       "Replace the server CA with the next one and generate the new server certificate",
+				UsageText:   "stork-tool cert-rollover-finish [options for db connection]",
+				Description: `The server must be restarted to use the new server certificate. The agents which haven't renewed their certificates must be registered again.`,
+				Flags:       dbFlags,
+				Category:    "Certificates Management",
+				Action:      runCARolloverFinish,
+			},
 			{
 				Name:        "hook-inspect",
 				Usage:       "Prints details about hooks",
@@ -6,6 +6,8 @@ import (
 	"crypto/x509"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
@@ -15,6 +17,7 @@ import (
 	"google.golang.org/grpc/security/advancedtls"
 
 	agentapi "isc.org/stork/api"
+	bind9config "isc.org/stork/appcfg/bind9"
 	keactrl "isc.org/stork/appctrl/kea"
 	"isc.org/stork/server/eventcenter"
 )
@@ -48,6 +51,50 @@ type AgentStats struct {
 	CurrentErrors int64
 	AppCommStats  map[AppCommStatsKey]interface{}
 	mutex         *sync.Mutex
+	// The number of requests waiting in the queue or being processed by
+	// the agent's worker. It is accessed atomically.
+	queueDepth int64
+	// The duration of the last call to the agent in nanoseconds. It is
+	// accessed atomically.
+	lastLatency int64
+	// The total duration of all calls to the agent in nanoseconds. It is
+	// accessed atomically.
+	totalLatency int64
+	// The number of completed calls to the agent. It is accessed atomically.
+	requestsCount int64
+}
+
+// Returns the number of requests to the agent that are queued or being
+// processed.
+func (stats *AgentStats) GetQueueDepth() int64 {
+	return atomic.LoadInt64(&stats.queueDepth)
+}
+
+// Returns the duration of the last call to the agent.
+func (stats *AgentStats) GetLastLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&stats.lastLatency))
+}
+
+// Returns the average duration of the calls to the agent. It returns zero
+// if no call has been completed yet.
+func (stats *AgentStats) GetAverageLatency() time.Duration {
+	count := atomic.LoadInt64(&stats.requestsCount)
+	if count == 0 {
+		return 0
+	}
+	return time.Duration(atomic.LoadInt64(&stats.totalLatency) / count)
+}
+
+// Returns the number of completed calls to the agent.
+func (stats *AgentStats) GetRequestsCount() int64 {
+	return atomic.LoadInt64(&stats.requestsCount)
+}
+
+// Records the duration of the completed call to the agent.
+func (stats *AgentStats) recordLatency(latency time.Duration) {
+	atomic.StoreInt64(&stats.lastLatency, int64(latency))
+	atomic.AddInt64(&stats.totalLatency, int64(latency))
+	atomic.AddInt64(&stats.requestsCount, 1)
 }
 
 // Runtime information about the agent, e.g. connection, communication
@@ -57,6 +104,13 @@ type Agent struct {
 	Client   agentapi.AgentClient
 	GrpcConn *grpc.ClientConn
 	Stats    AgentStats
+	// Protects the client and the connection replaced when the connection
+	// to the agent is re-established. The streaming calls use the client
+	// outside of the agent's worker.
+	clientMutex sync.RWMutex
+	// Queue of the requests to the agent. The requests are processed by
+	// the agent's worker in the order they were queued.
+	requests chan *commLoopReq
 }
 
 // Prepare TLS credentials with configured certs and verification options.
@@ -104,8 +158,20 @@ func prepareTLSCreds(caCertPEM, serverCertPEM, serverKeyPEM []byte) (credentials
 	return creds, nil
 }
 
+// Returns the gRPC client of the agent. The client is replaced when the
+// connection to the agent is re-established, so it must be read under
+// the lock.
+func (agent *Agent) getClient() agentapi.AgentClient {
+	agent.clientMutex.RLock()
+	defer agent.clientMutex.RUnlock()
+	return agent.Client
+}
+
 // Prepare gRPC connection to agent.
 func (agent *Agent) MakeGrpcConnection(caCertPEM, serverCertPEM, serverKeyPEM []byte) error {
+	agent.clientMutex.Lock()
+	defer agent.clientMutex.Unlock()
+
 	// If there is any old connection then clean it up
 	if agent.GrpcConn != nil {
 		agent.GrpcConn.Close()
@@ -143,14 +209,21 @@ type ConnectedAgents interface {
 	ForwardToNamedStats(ctx context.Context, agentAddress string, agentPort int64, statsAddress string, statsPort int64, path string, statsOutput interface{}) error
 	ForwardToKeaOverHTTP(ctx context.Context, app ControlledApp, commands []keactrl.SerializableCommand, cmdResponses ...interface{}) (*KeaCmdsResult, error)
 	TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64) ([]string, error)
+	FollowTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64, pattern, severity string, handler func(lines []string) error) error
+	GetKeaStatsSamples(ctx context.Context, app ControlledApp, sinceSequence uint64, limit int64) (*KeaStatsSamples, error)
+	RenewCertificate(ctx context.Context, address string, agentPort int64) ([]byte, error)
+	InstallCertificate(ctx context.Context, address string, agentPort int64, certPEM, caCertPEM []byte) error
+	GetBind9Config(ctx context.Context, app ControlledApp) (*bind9config.Config, error)
 }
 
 // Agents management map. It tracks Agents currently connected to the Server.
+// Each agent has its own worker sending the requests to this agent. The
+// requests to different agents are sent in parallel.
 type connectedAgentsData struct {
 	Settings      *AgentsSettings
 	EventCenter   eventcenter.EventCenter
 	AgentsMap     map[string]*Agent
-	CommLoopReqs  chan *commLoopReq
+	agentsMutex   *sync.RWMutex
 	DoneCommLoop  chan bool
 	Wg            *sync.WaitGroup
 	serverCertPEM []byte
@@ -164,7 +237,7 @@ func NewConnectedAgents(settings *AgentsSettings, eventCenter eventcenter.EventC
 		Settings:      settings,
 		EventCenter:   eventCenter,
 		AgentsMap:     make(map[string]*Agent),
-		CommLoopReqs:  make(chan *commLoopReq),
+		agentsMutex:   &sync.RWMutex{},
 		DoneCommLoop:  make(chan bool),
 		Wg:            &sync.WaitGroup{},
 		caCertPEM:     caCertPEM,
@@ -172,27 +245,39 @@ func NewConnectedAgents(settings *AgentsSettings, eventCenter eventcenter.EventC
 		serverKeyPEM:  serverKeyPEM,
 	}
 
-	agents.Wg.Add(1)
-	go agents.communicationLoop()
-
 	return &agents
 }
 
 // Shutdown agents in agents map.
 func (agents *connectedAgentsData) Shutdown() {
 	log.Printf("Stopping communication with agents")
+	// Stop the workers first, so they don't use the closed connections.
+	close(agents.DoneCommLoop)
+	agents.Wg.Wait()
+
+	agents.agentsMutex.RLock()
+	defer agents.agentsMutex.RUnlock()
 	for _, agent := range agents.AgentsMap {
 		agent.GrpcConn.Close()
 	}
-
-	close(agents.CommLoopReqs)
-	agents.DoneCommLoop <- true
-	agents.Wg.Wait()
 	log.Printf("Stopped communication with agents")
 }
 
-// Get Agent object by its address.
+// Returns the agent with the specified address from the agents map. The
+// second returned value is false if the agent doesn't exist.
+func (agents *connectedAgentsData) getAgent(address string) (*Agent, bool) {
+	agents.agentsMutex.RLock()
+	defer agents.agentsMutex.RUnlock()
+	agent, ok := agents.AgentsMap[address]
+	return agent, ok
+}
+
+// Get Agent object by its address. A new agent is created and its worker
+// is started if the agent doesn't exist yet.
 func (agents *connectedAgentsData) GetConnectedAgent(address string) (*Agent, error) {
+	agents.agentsMutex.Lock()
+	defer agents.agentsMutex.Unlock()
+
 	// Look for agent in Agents map and if found then return it
 	agent, ok := agents.AgentsMap[address]
 	if ok {
@@ -207,6 +292,7 @@ func (agents *connectedAgentsData) GetConnectedAgent(address string) (*Agent, er
 	agent.Address = address
 	agent.Stats.AppCommStats = make(map[AppCommStatsKey]interface{})
 	agent.Stats.mutex = new(sync.Mutex)
+	agent.requests = make(chan *commLoopReq, agentRequestsQueueSize)
 	err := agent.MakeGrpcConnection(agents.caCertPEM, agents.serverCertPEM, agents.serverKeyPEM)
 	if err != nil {
 		return nil, err
@@ -214,6 +300,10 @@ func (agents *connectedAgentsData) GetConnectedAgent(address string) (*Agent, er
 
 	// Store it in Agents map
 	agents.AgentsMap[address] = agent
+
+	// Start the worker sending the requests to this agent.
+	agents.Wg.Add(1)
+	go agents.agentWorker(agent)
 	log.WithFields(log.Fields{
 		"address": address,
 	}).Info("Connecting to new agent")
@@ -228,7 +318,7 @@ func (agents *connectedAgentsData) GetConnectedAgentStats(address string, port i
 	if port != 0 {
 		address = fmt.Sprintf("%s:%d", address, port)
 	}
-	if agent, ok := agents.AgentsMap[address]; ok {
+	if agent, ok := agents.getAgent(address); ok {
 		return &agent.Stats
 	}
 	return nil
@@ -1,8 +1,11 @@
 package agentcomm
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net"
 	"reflect"
 	"strconv"
@@ -13,6 +16,7 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	agentapi "isc.org/stork/api"
+	bind9config "isc.org/stork/appcfg/bind9"
 	keactrl "isc.org/stork/appctrl/kea"
 	dbmodel "isc.org/stork/server/database/model"
 	storkutil "isc.org/stork/util"
@@ -28,10 +32,12 @@ type AccessPoint struct {
 	UseSecureProtocol bool
 }
 
-// Currently supported types are: "control" and "statistics".
+// Currently supported types are: "control", "control-socket" and
+// "statistics".
 const (
-	AccessPointControl    = "control"
-	AccessPointStatistics = "statistics"
+	AccessPointControl       = "control"
+	AccessPointControlSocket = "control-socket"
+	AccessPointStatistics    = "statistics"
 )
 
 // The application entry detected by an agent. It unambiguously indicates the
@@ -68,6 +74,8 @@ type State struct {
 	VirtualizationRole       string
 	HostID                   string
 	AgentUsesHTTPCredentials bool
+	AgentCertificate         string
+	Inventory                []dbmodel.DaemonInventory
 	LastVisitedAt            time.Time
 	Error                    string
 	Apps                     []*App
@@ -78,10 +86,28 @@ type State struct {
 type ControlledApp interface {
 	dbmodel.AppTag
 	GetControlAccessPoint() (string, int64, string, bool, error)
+	UsesControlSocket() bool
 	GetMachineTag() dbmodel.MachineTag
 	GetDaemonTags() []dbmodel.DaemonTag
 }
 
+// Statistics of a single Kea daemon sampled by the agent while the server
+// was possibly unable to communicate with it. The statistics map holds the
+// most recent values of the statistics returned by the daemon.
+type KeaStatsSample struct {
+	Sequence   uint64
+	SampledAt  time.Time
+	Daemon     string
+	Statistics map[string]json.Number
+}
+
+// Kea statistics samples fetched from the agent's outbox. The last
+// sequence is the sequence number of the last sample stored by the agent.
+type KeaStatsSamples struct {
+	Samples      []*KeaStatsSample
+	LastSequence uint64
+}
+
 // MakeAccessPoint is an utility to make an array of one access point.
 func MakeAccessPoint(tp, address, key string, port int64) []AccessPoint {
 	return []AccessPoint{{
@@ -138,6 +164,30 @@ func (agents *connectedAgentsData) GetState(ctx context.Context, address string,
 		})
 	}
 
+	var inventory []dbmodel.DaemonInventory
+	for _, daemon := range grpcState.Inventory {
+		var files []dbmodel.DaemonFileInventory
+		for _, file := range daemon.Files {
+			files = append(files, dbmodel.DaemonFileInventory{
+				Path:  file.Path,
+				Mode:  file.Mode,
+				Owner: file.Owner,
+				Group: file.Group,
+				Error: file.Error,
+			})
+		}
+		inventory = append(inventory, dbmodel.DaemonInventory{
+			Name:             daemon.Name,
+			Pid:              daemon.Pid,
+			Executable:       daemon.Executable,
+			Package:          daemon.Package,
+			SystemdUnit:      daemon.SystemdUnit,
+			SystemdUnitState: daemon.SystemdUnitState,
+			ListeningSockets: daemon.ListeningSockets,
+			Files:            files,
+		})
+	}
+
 	state := State{
 		Address:                  address,
 		AgentVersion:             grpcState.AgentVersion,
@@ -157,6 +207,8 @@ func (agents *connectedAgentsData) GetState(ctx context.Context, address string,
 		VirtualizationRole:       grpcState.VirtualizationRole,
 		HostID:                   grpcState.HostID,
 		AgentUsesHTTPCredentials: grpcState.AgentUsesHTTPCredentials,
+		AgentCertificate:         grpcState.AgentCertificate,
+		Inventory:                inventory,
 		LastVisitedAt:            storkutil.UTCNow(),
 		Error:                    grpcState.Error,
 		Apps:                     apps,
@@ -195,7 +247,7 @@ func (agents *connectedAgentsData) ForwardRndcCommand(ctx context.Context, app C
 	// Send the command to the Stork Agent.
 	resp, err := agents.sendAndRecvViaQueue(addrPort, req)
 	if err != nil {
-		if agent, agentExists := agents.AgentsMap[addrPort]; agentExists {
+		if agent, agentExists := agents.getAgent(addrPort); agentExists {
 			agent.Stats.mutex.Lock()
 			defer agent.Stats.mutex.Unlock()
 			if agent.Stats.CurrentErrors == 1 {
@@ -249,7 +301,7 @@ func (agents *connectedAgentsData) ForwardRndcCommand(ctx context.Context, app C
 
 	// Start updating error statistics for this agent and the BIND9 app we've
 	// been communicating with.
-	agent, agentExists := agents.AgentsMap[addrPort]
+	agent, agentExists := agents.getAgent(addrPort)
 	if agentExists {
 		// This function may be called by multiple goroutines, so we need to make
 		// sure that the statistics update is safe in terms of concurrent access.
@@ -304,7 +356,7 @@ func (agents *connectedAgentsData) ForwardToNamedStats(ctx context.Context, agen
 	// Send the commands to the Stork Agent.
 	storkRsp, err := agents.sendAndRecvViaQueue(addrPort, storkReq)
 	if err != nil {
-		if agent, agentExists := agents.AgentsMap[addrPort]; agentExists {
+		if agent, agentExists := agents.getAgent(addrPort); agentExists {
 			agent.Stats.mutex.Lock()
 			defer agent.Stats.mutex.Unlock()
 			if agent.Stats.CurrentErrors == 1 {
@@ -342,7 +394,7 @@ func (agents *connectedAgentsData) ForwardToNamedStats(ctx context.Context, agen
 
 	// Start updating error statistics for this agent and the BIND9 app we've
 	// been communicating with.
-	agent, agentExists := agents.AgentsMap[addrPort]
+	agent, agentExists := agents.getAgent(addrPort)
 	if agentExists {
 		// This function may be called by multiple goroutines, so we need to make
 		// sure that the statistics update is safe in terms of concurrent access.
@@ -401,9 +453,18 @@ func (result *KeaCmdsResult) GetFirstError() error {
 	return nil
 }
 
+// A common interface to the responses to the commands forwarded to Kea
+// over HTTP and over the control socket.
+type keaForwardResponse interface {
+	GetStatus() *agentapi.Status
+	GetKeaResponses() []*agentapi.KeaResponse
+}
+
 // Forwards a Kea command via the Stork Agent and Kea Control Agent and then
 // parses the response. caAddress and caPort are used to construct the URL
-// of the Kea Control Agent to which the command should be sent.
+// of the Kea Control Agent to which the command should be sent. If the
+// Kea daemon runs without the Control Agent, the command is sent by the
+// Stork Agent directly to the daemon's control socket.
 func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, app ControlledApp, commands []keactrl.SerializableCommand, cmdResponses ...interface{}) (*KeaCmdsResult, error) {
 	agentAddress := app.GetMachineTag().GetAddress()
 	agentPort := app.GetMachineTag().GetAgentPort()
@@ -414,18 +475,38 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, app
 	}
 
 	addrPort := net.JoinHostPort(agentAddress, strconv.FormatInt(agentPort, 10))
-	caURL := storkutil.HostWithPortURL(caAddress, caPort, caUseSecureProtocol)
 
 	// Prepare the on-wire representation of the commands.
-	fdReq := &agentapi.ForwardToKeaOverHTTPReq{
-		Url: caURL,
-	}
+	var keaRequests []*agentapi.KeaRequest
 	for _, cmd := range commands {
-		fdReq.KeaRequests = append(fdReq.KeaRequests, &agentapi.KeaRequest{
+		keaRequests = append(keaRequests, &agentapi.KeaRequest{
 			Request: cmd.Marshal(),
 		})
 	}
 
+	// The control socket path is used instead of the URL in the logs and
+	// error messages if the daemon is controlled over the socket.
+	var (
+		caURL string
+		fdReq any
+	)
+	if app.UsesControlSocket() {
+		caURL = caAddress
+		fdReq = &agentapi.ForwardToKeaReq{
+			AccessPoint: &agentapi.AccessPoint{
+				Type:    AccessPointControlSocket,
+				Address: caAddress,
+			},
+			KeaRequests: keaRequests,
+		}
+	} else {
+		caURL = storkutil.HostWithPortURL(caAddress, caPort, caUseSecureProtocol)
+		fdReq = &agentapi.ForwardToKeaOverHTTPReq{
+			Url:         caURL,
+			KeaRequests: keaRequests,
+		}
+	}
+
 	// Send the commands to the Stork Agent.
 	resp, err := agents.sendAndRecvViaQueue(addrPort, fdReq)
 
@@ -433,7 +514,7 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, app
 	// and not panic if someone has screwed up something in the code.
 	// Concurrent access should be safe assuming that the agent has been
 	// already added to the map by the GetConnectedAgent function.
-	agent, agentExists := agents.AgentsMap[addrPort]
+	agent, agentExists := agents.getAgent(addrPort)
 	if !agentExists {
 		err = errors.Errorf("missing agent in agents map: %s", addrPort)
 		return nil, err
@@ -471,7 +552,7 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, app
 		log.WithFields(log.Fields{
 			"agent": addrPort,
 			"kea":   caURL,
-		}).Warnf("Failed to send the following commands: %+v", fdReq.KeaRequests)
+		}).Warnf("Failed to send the following commands: %+v", keaRequests)
 		return nil, err
 	}
 
@@ -480,7 +561,7 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, app
 		agents.EventCenter.AddWarningEvent("Communication with stork agent on {machine} resumed", app.GetMachineTag())
 	}
 
-	fdRsp := resp.(*agentapi.ForwardToKeaOverHTTPRsp)
+	fdRsp := resp.(keaForwardResponse)
 
 	// Gather errors in communication via the Kea Control
 	// Agent. It is possible to send multiple commands so there
@@ -490,10 +571,10 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, app
 
 	result := &KeaCmdsResult{}
 	result.Error = nil
-	if fdRsp.Status.Code != agentapi.Status_OK {
-		result.Error = errors.New(fdRsp.Status.Message)
+	if fdRsp.GetStatus().Code != agentapi.Status_OK {
+		result.Error = errors.New(fdRsp.GetStatus().Message)
 		caErrorsCount++
-		caErrorStr += "\n" + fdRsp.Status.Message
+		caErrorStr += "\n" + fdRsp.GetStatus().Message
 	}
 
 	// Gather errors from daemons (including CA).
@@ -562,13 +643,13 @@ func (agents *connectedAgentsData) ForwardToKeaOverHTTP(ctx context.Context, app
 		result.CmdsErrors = append(result.CmdsErrors, nil)
 	}
 
-	agents.updateErrorStatsAndRaiseEvents(agent, caAddress, caPort, app, caErrorsCount, addrPort, caURL, fdReq, caErrorStr, daemonErrorsCount)
+	agents.updateErrorStatsAndRaiseEvents(agent, caAddress, caPort, app, caErrorsCount, addrPort, caURL, keaRequests, caErrorStr, daemonErrorsCount)
 
 	// Everything was fine, so return no error.
 	return result, nil
 }
 
-func (agents *connectedAgentsData) updateErrorStatsAndRaiseEvents(agent *Agent, caAddress string, caPort int64, app ControlledApp, caErrorsCount int64, addrPort, caURL string, fdReq *agentapi.ForwardToKeaOverHTTPReq, caErrorStr string, daemonErrorsCount map[string]int64) {
+func (agents *connectedAgentsData) updateErrorStatsAndRaiseEvents(agent *Agent, caAddress string, caPort int64, app ControlledApp, caErrorsCount int64, addrPort, caURL string, keaRequests []*agentapi.KeaRequest, caErrorStr string, daemonErrorsCount map[string]int64) {
 	// Start updating error statistics for this agent and the Kea app we've been
 	// communicating with.
 	var (
@@ -603,7 +684,7 @@ func (agents *connectedAgentsData) updateErrorStatsAndRaiseEvents(agent *Agent,
 			log.WithFields(log.Fields{
 				"agent": addrPort,
 				"kea":   caURL,
-			}).Warnf("communication failed: %+v", fdReq.KeaRequests)
+			}).Warnf("communication failed: %+v", keaRequests)
 			dmn, ok := daemonsMap["ca"]
 			if ok {
 				agents.EventCenter.AddErrorEvent("Communication with {daemon} of {app} failed", strings.TrimSpace(caErrorStr), &dmn, app)
@@ -681,3 +762,179 @@ func (agents *connectedAgentsData) TailTextFile(ctx context.Context, agentAddres
 
 	return response.Lines, nil
 }
+
+// Follows the specified text file on the agent and invokes the handler
+// for the lines appended to it. The pattern and severity are optional
+// filters applied by the agent. The call is sent directly to the agent
+// rather than via the agent's queue, because it lasts until the context
+// is canceled and it would block other requests to the agent. The client
+// is taken under the agent's lock because the agent's worker may replace
+// it while re-establishing the connection. It returns
+// nil when the context is canceled or an error when the agent fails to
+// follow the file or the handler returns an error.
+func (agents *connectedAgentsData) FollowTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64, pattern, severity string, handler func(lines []string) error) error {
+	addrPort := net.JoinHostPort(agentAddress, strconv.FormatInt(agentPort, 10))
+
+	agent, err := agents.GetConnectedAgent(addrPort)
+	if err != nil {
+		return err
+	}
+
+	req := &agentapi.FollowTextFileReq{
+		Path:     path,
+		Offset:   offset,
+		Pattern:  pattern,
+		Severity: severity,
+	}
+
+	stream, err := agent.getClient().FollowTextFile(ctx, req)
+	if err != nil {
+		log.WithFields(log.Fields{
+			"agent": addrPort,
+			"file":  path,
+		}).Warnf("Failed to follow text file")
+
+		return errors.Wrapf(err, "failed to follow text file: %s", path)
+	}
+
+	for {
+		response, err := stream.Recv()
+		switch {
+		case errors.Is(err, io.EOF), ctx.Err() != nil:
+			return nil
+		case err != nil:
+			return errors.Wrapf(err, "failed to receive text file contents: %s", path)
+		case response.Status.Code != agentapi.Status_OK:
+			return errors.New(response.Status.Message)
+		}
+
+		if err = handler(response.Lines); err != nil {
+			return err
+		}
+	}
+}
+
+// Fetches the Kea statistics samples stored in the agent's outbox for the
+// specified Kea app. The samples following the specified sequence number
+// are returned. The limit specifies the maximum number of the returned
+// samples. The agent's default limit is used if it is zero.
+func (agents *connectedAgentsData) GetKeaStatsSamples(ctx context.Context, app ControlledApp, sinceSequence uint64, limit int64) (*KeaStatsSamples, error) {
+	agentAddress := app.GetMachineTag().GetAddress()
+	agentPort := app.GetMachineTag().GetAgentPort()
+
+	ctrlAddress, ctrlPort, _, _, err := app.GetControlAccessPoint()
+	if err != nil {
+		return nil, err
+	}
+
+	addrPort := net.JoinHostPort(agentAddress, strconv.FormatInt(agentPort, 10))
+
+	req := &agentapi.GetKeaStatsSamplesReq{
+		Address:       ctrlAddress,
+		Port:          ctrlPort,
+		SinceSequence: sinceSequence,
+		Limit:         limit,
+	}
+
+	agentResponse, err := agents.sendAndRecvViaQueue(addrPort, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch Kea statistics samples from the agent %s", addrPort)
+	}
+
+	response := agentResponse.(*agentapi.GetKeaStatsSamplesRsp)
+	if response.Status.Code != agentapi.Status_OK {
+		return nil, errors.New(response.Status.Message)
+	}
+
+	samples := &KeaStatsSamples{
+		LastSequence: response.LastSequence,
+	}
+	for _, sample := range response.Samples {
+		statistics := make(map[string]json.Number)
+		decoder := json.NewDecoder(bytes.NewBufferString(sample.Statistics))
+		decoder.UseNumber()
+		if err = decoder.Decode(&statistics); err != nil {
+			return nil, errors.Wrapf(err, "failed to parse Kea statistics sample %d from the agent %s", sample.Sequence, addrPort)
+		}
+		samples.Samples = append(samples.Samples, &KeaStatsSample{
+			Sequence:   sample.Sequence,
+			SampledAt:  time.Unix(sample.SampledAt, 0).UTC(),
+			Daemon:     sample.Daemon,
+			Statistics: statistics,
+		})
+	}
+	return samples, nil
+}
+
+// Requests the agent to generate a new private key for its certificate
+// renewal. It returns the CSR for the new agent certificate in the PEM
+// format.
+func (agents *connectedAgentsData) RenewCertificate(ctx context.Context, address string, agentPort int64) ([]byte, error) {
+	addrPort := net.JoinHostPort(address, strconv.FormatInt(agentPort, 10))
+
+	agentResponse, err := agents.sendAndRecvViaQueue(addrPort, &agentapi.RenewCertificateReq{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to request the certificate renewal from the agent %s", addrPort)
+	}
+
+	response := agentResponse.(*agentapi.RenewCertificateRsp)
+	if response.Status.Code != agentapi.Status_OK {
+		return nil, errors.New(response.Status.Message)
+	}
+	return []byte(response.Csr), nil
+}
+
+// Sends the renewed certificate and the server CA certificates, both in
+// the PEM format, to the agent. The certificate must be signed for the CSR
+// returned by the RenewCertificate call.
+func (agents *connectedAgentsData) InstallCertificate(ctx context.Context, address string, agentPort int64, certPEM, caCertPEM []byte) error {
+	addrPort := net.JoinHostPort(address, strconv.FormatInt(agentPort, 10))
+
+	req := &agentapi.InstallCertificateReq{
+		Certificate:    string(certPEM),
+		CaCertificates: string(caCertPEM),
+	}
+
+	agentResponse, err := agents.sendAndRecvViaQueue(addrPort, req)
+	if err != nil {
+		return errors.Wrapf(err, "failed to install the renewed certificate on the agent %s", addrPort)
+	}
+
+	response := agentResponse.(*agentapi.InstallCertificateRsp)
+	if response.Status.Code != agentapi.Status_OK {
+		return errors.New(response.Status.Message)
+	}
+	return nil
+}
+
+// Gets the configuration of the BIND 9 app from the agent and parses it.
+// The configuration includes the contents of the included files.
+func (agents *connectedAgentsData) GetBind9Config(ctx context.Context, app ControlledApp) (*bind9config.Config, error) {
+	agentAddress := app.GetMachineTag().GetAddress()
+	agentPort := app.GetMachineTag().GetAgentPort()
+	addrPort := net.JoinHostPort(agentAddress, strconv.FormatInt(agentPort, 10))
+
+	ctrlAddress, ctrlPort, _, _, err := app.GetControlAccessPoint()
+	if err != nil {
+		return nil, err
+	}
+
+	req := &agentapi.GetBind9ConfigReq{
+		ControlAddress: ctrlAddress,
+		ControlPort:    ctrlPort,
+	}
+	agentResponse, err := agents.sendAndRecvViaQueue(addrPort, req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get the BIND 9 configuration from the agent %s", addrPort)
+	}
+
+	response := agentResponse.(*agentapi.GetBind9ConfigRsp)
+	if response.Status.Code != agentapi.Status_OK {
+		return nil, errors.New(response.Status.Message)
+	}
+	config, err := bind9config.Parse(response.Config)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "cannot parse the BIND 9 configuration received from the agent %s", addrPort)
+	}
+	return config, nil
+}
@@ -2,7 +2,9 @@ package agentcomm
 
 import (
 	"context"
+	"io"
 	"testing"
+	"time"
 
 	"github.com/golang/mock/gomock"
 	pkgerrors "github.com/pkg/errors"
@@ -120,6 +122,20 @@ func TestGetState(t *testing.T) {
 				AccessPoints: makeAccessPoint(AccessPointControl, "1.2.3.4", "", 1234),
 			},
 		},
+		Inventory: []*agentapi.DaemonInventory{
+			{
+				Name:             "kea-dhcp4",
+				Pid:              1234,
+				Package:          "isc-kea-dhcp4-server",
+				ListeningSockets: []string{"udp 0.0.0.0:67"},
+				Files: []*agentapi.FileInventory{
+					{
+						Path: "/etc/kea/kea-dhcp4.conf",
+						Mode: "-rw-r-----",
+					},
+				},
+			},
+		},
 	}
 	mockAgentClient.EXPECT().
 		GetState(gomock.Any(), gomock.Any(), newGZIPMatcher()).
@@ -131,6 +147,11 @@ func TestGetState(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, expVer, state.AgentVersion)
 	require.Equal(t, AppTypeKea, state.Apps[0].Type)
+	require.Len(t, state.Inventory, 1)
+	require.Equal(t, "isc-kea-dhcp4-server", state.Inventory[0].Package)
+	require.Equal(t, []string{"udp 0.0.0.0:67"}, state.Inventory[0].ListeningSockets)
+	require.Len(t, state.Inventory[0].Files, 1)
+	require.Equal(t, "-rw-r-----", state.Inventory[0].Files[0].Mode)
 }
 
 // Test that a command can be successfully forwarded to Kea and the response
@@ -528,6 +549,78 @@ func TestForwardRndcCommand(t *testing.T) {
 	require.Zero(t, appCommStats.CurrentErrorsRNDC)
 }
 
+// Test that the BIND 9 configuration is received from the agent and parsed.
+func TestGetBind9Config(t *testing.T) {
+	mockAgentClient, agents, teardown := setupGrpcliTestCase(t)
+	defer teardown()
+
+	rsp := agentapi.GetBind9ConfigRsp{
+		Status: &agentapi.Status{
+			Code: 0,
+		},
+		Config: `zone "example.org" { type primary; };`,
+	}
+
+	mockAgentClient.EXPECT().GetBind9Config(
+		gomock.Any(), gomock.Any(), newGZIPMatcher(),
+	).Do(func(_ context.Context, req *agentapi.GetBind9ConfigReq, _ ...grpc.CallOption) {
+		require.Equal(t, "127.0.0.1", req.ControlAddress)
+		require.EqualValues(t, 953, req.ControlPort)
+	}).Return(&rsp, nil)
+
+	dbApp := &dbmodel.App{
+		Machine: &dbmodel.Machine{
+			Address:   "127.0.0.1",
+			AgentPort: 8080,
+		},
+		AccessPoints: []*dbmodel.AccessPoint{{
+			Type:    dbmodel.AccessPointControl,
+			Address: "127.0.0.1",
+			Port:    953,
+		}},
+	}
+
+	config, err := agents.GetBind9Config(context.Background(), dbApp)
+	require.NoError(t, err)
+	require.NotNil(t, config)
+	zones := config.GetZones()
+	require.Len(t, zones, 1)
+	require.Equal(t, "example.org", zones[0].Name)
+}
+
+// Test that an error is returned when the agent doesn't find the BIND 9 app.
+func TestGetBind9ConfigError(t *testing.T) {
+	mockAgentClient, agents, teardown := setupGrpcliTestCase(t)
+	defer teardown()
+
+	rsp := agentapi.GetBind9ConfigRsp{
+		Status: &agentapi.Status{
+			Code:    agentapi.Status_ERROR,
+			Message: "Cannot find BIND 9 app",
+		},
+	}
+
+	mockAgentClient.EXPECT().GetBind9Config(
+		gomock.Any(), gomock.Any(), newGZIPMatcher(),
+	).Return(&rsp, nil)
+
+	dbApp := &dbmodel.App{
+		Machine: &dbmodel.Machine{
+			Address:   "127.0.0.1",
+			AgentPort: 8080,
+		},
+		AccessPoints: []*dbmodel.AccessPoint{{
+			Type:    dbmodel.AccessPointControl,
+			Address: "127.0.0.1",
+			Port:    953,
+		}},
+	}
+
+	config, err := agents.GetBind9Config(context.Background(), dbApp)
+	require.ErrorContains(t, err, "Cannot find BIND 9 app")
+	require.Nil(t, config)
+}
+
 // Test the gRPC call which fetches the tail of the specified text file.
 func TestTailTextFile(t *testing.T) {
 	mockAgentClient, agents, teardown := setupGrpcliTestCase(t)
@@ -556,6 +649,99 @@ func TestTailTextFile(t *testing.T) {
 	require.Equal(t, "mock agent client", tail[1])
 }
 
+// Fake stream returned by the mock agent client in response to the
+// FollowTextFile call. It returns the queued responses and io.EOF when
+// the responses channel is closed.
+type fakeFollowTextFileStream struct {
+	grpc.ClientStream
+	responses chan *agentapi.FollowTextFileRsp
+}
+
+// Returns the next queued response.
+func (s *fakeFollowTextFileStream) Recv() (*agentapi.FollowTextFileRsp, error) {
+	response, ok := <-s.responses
+	if !ok {
+		return nil, io.EOF
+	}
+	return response, nil
+}
+
+// Test that the lines appended to the followed file are passed to the
+// handler.
+func TestFollowTextFile(t *testing.T) {
+	mockAgentClient, agents, teardown := setupGrpcliTestCase(t)
+	defer teardown()
+
+	stream := &fakeFollowTextFileStream{
+		responses: make(chan *agentapi.FollowTextFileRsp, 2),
+	}
+	stream.responses <- &agentapi.FollowTextFileRsp{
+		Status: &agentapi.Status{Code: agentapi.Status_OK},
+		Lines:  []string{"foo", "bar"},
+	}
+	stream.responses <- &agentapi.FollowTextFileRsp{
+		Status: &agentapi.Status{Code: agentapi.Status_OK},
+		Lines:  []string{"baz"},
+	}
+	close(stream.responses)
+
+	mockAgentClient.EXPECT().
+		FollowTextFile(gomock.Any(), gomock.Any()).
+		Return(stream, nil)
+
+	var lines []string
+	err := agents.FollowTextFile(context.Background(), "127.0.0.1", 8080, "/tmp/log.txt", 0, "", "", func(received []string) error {
+		lines = append(lines, received...)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"foo", "bar", "baz"}, lines)
+}
+
+// Test that the connection to the agent can be re-established while
+// a text file is being followed. This test is meant to be run with the
+// race detector enabled.
+func TestFollowTextFileReconnect(t *testing.T) {
+	mockAgentClient, agents, teardown := setupGrpcliTestCase(t)
+	defer teardown()
+
+	stream := &fakeFollowTextFileStream{
+		responses: make(chan *agentapi.FollowTextFileRsp),
+	}
+	// The follow may begin after the mock client has been replaced with
+	// the new connection's client.
+	mockAgentClient.EXPECT().
+		FollowTextFile(gomock.Any(), gomock.Any()).
+		Return(stream, nil).
+		MaxTimes(1)
+
+	agent, err := agents.GetConnectedAgent("127.0.0.1:8080")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- agents.FollowTextFile(ctx, "127.0.0.1", 8080, "/tmp/log.txt", 0, "", "", func(lines []string) error {
+			return nil
+		})
+	}()
+
+	// Re-establish the connection while the follow is open.
+	for i := 0; i < 3; i++ {
+		err = agent.MakeGrpcConnection(CACertPEM, ServerCertPEM, ServerKeyPEM)
+		require.NoError(t, err)
+	}
+
+	cancel()
+	close(stream.responses)
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "following the text file hasn't stopped")
+	}
+}
+
 // Check MakeAccessPoint.
 func TestMakeAccessPoint(t *testing.T) {
 	aps := MakeAccessPoint(dbmodel.AccessPointControl, "1.2.3.4", "abcd", 124)
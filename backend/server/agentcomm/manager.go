@@ -2,6 +2,7 @@ package agentcomm
 
 import (
 	"context"
+	"sync/atomic"
 	"time"
 
 	"github.com/pkg/errors"
@@ -12,18 +13,27 @@ import (
 	agentapi "isc.org/stork/api"
 )
 
-// Loop that receives requests to agents, sends to them, receives responses
-// which are passed back to requestor. Requests and responses are passed
-// via channels what guarantees that requests are forwarded to agents one
-// by one.
-func (agents *connectedAgentsData) communicationLoop() {
+// The maximum number of the requests queued for a single agent. The
+// requestors are blocked when the queue is full.
+const agentRequestsQueueSize = 100
+
+// Worker that receives requests to a given agent, sends them to this agent,
+// receives responses which are passed back to requestor. There is one
+// worker per agent, so the requests to different agents are sent in
+// parallel, and one slow agent doesn't delay the communication with other
+// agents. Requests to a given agent are passed via the channel what
+// guarantees that they are forwarded to this agent one by one, in order.
+func (agents *connectedAgentsData) agentWorker(agent *Agent) {
 	defer agents.Wg.Done()
 	for {
 		select {
-		// wait for requests from parties that want to talk to agents
-		case req := <-agents.CommLoopReqs:
+		// wait for requests from parties that want to talk to the agent
+		case req := <-agent.requests:
 			if req != nil {
-				agents.handleRequest(req)
+				start := time.Now()
+				agents.handleRequest(agent, req)
+				agent.Stats.recordLatency(time.Since(start))
+				atomic.AddInt64(&agent.Stats.queueDepth, -1)
 			}
 		// wait for done signal from shutdown function
 		case <-agents.DoneCommLoop:
@@ -43,13 +53,34 @@ type commLoopReq struct {
 	RespChan  chan *channelResp
 }
 
-// Send a request to agent and receive response using channel to communication loop.
+// Send a request to agent and receive response using channel to the agent's
+// worker.
 func (agents *connectedAgentsData) sendAndRecvViaQueue(agentAddr string, in interface{}) (interface{}, error) {
-	respChan := make(chan *channelResp)
+	// get agent and its grpc connection
+	agent, err := agents.GetConnectedAgent(agentAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response channel is buffered, so the worker doesn't block when
+	// the communication is being shut down and nobody waits for the response.
+	respChan := make(chan *channelResp, 1)
 	req := &commLoopReq{AgentAddr: agentAddr, ReqData: in, RespChan: respChan}
-	agents.CommLoopReqs <- req
-	respErr := <-respChan
-	return respErr.Response, respErr.Err
+
+	atomic.AddInt64(&agent.Stats.queueDepth, 1)
+	select {
+	case agent.requests <- req:
+	case <-agents.DoneCommLoop:
+		atomic.AddInt64(&agent.Stats.queueDepth, -1)
+		return nil, errors.Errorf("communication with the agent %s has been stopped", agentAddr)
+	}
+
+	select {
+	case respErr := <-respChan:
+		return respErr.Response, respErr.Err
+	case <-agents.DoneCommLoop:
+		return nil, errors.Errorf("communication with the agent %s has been stopped", agentAddr)
+	}
 }
 
 // Pass given request directly to an agent.
@@ -67,19 +98,30 @@ func doCall(ctx context.Context, agent *Agent, in interface{}) (interface{}, err
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
+	client := agent.getClient()
 	switch inData := in.(type) {
 	case *agentapi.PingReq:
-		response, err = agent.Client.Ping(ctx, inData)
+		response, err = client.Ping(ctx, inData)
 	case *agentapi.GetStateReq:
-		response, err = agent.Client.GetState(ctx, inData, bigMessageOptions...)
+		response, err = client.GetState(ctx, inData, bigMessageOptions...)
 	case *agentapi.ForwardRndcCommandReq:
-		response, err = agent.Client.ForwardRndcCommand(ctx, inData, bigMessageOptions...)
+		response, err = client.ForwardRndcCommand(ctx, inData, bigMessageOptions...)
 	case *agentapi.ForwardToNamedStatsReq:
-		response, err = agent.Client.ForwardToNamedStats(ctx, inData, bigMessageOptions...)
+		response, err = client.ForwardToNamedStats(ctx, inData, bigMessageOptions...)
 	case *agentapi.ForwardToKeaOverHTTPReq:
-		response, err = agent.Client.ForwardToKeaOverHTTP(ctx, inData, bigMessageOptions...)
+		response, err = client.ForwardToKeaOverHTTP(ctx, inData, bigMessageOptions...)
+	case *agentapi.ForwardToKeaReq:
+		response, err = client.ForwardToKea(ctx, inData, bigMessageOptions...)
 	case *agentapi.TailTextFileReq:
-		response, err = agent.Client.TailTextFile(ctx, inData, bigMessageOptions...)
+		response, err = client.TailTextFile(ctx, inData, bigMessageOptions...)
+	case *agentapi.GetKeaStatsSamplesReq:
+		response, err = client.GetKeaStatsSamples(ctx, inData, bigMessageOptions...)
+	case *agentapi.RenewCertificateReq:
+		response, err = client.RenewCertificate(ctx, inData)
+	case *agentapi.InstallCertificateReq:
+		response, err = client.InstallCertificate(ctx, inData)
+	case *agentapi.GetBind9ConfigReq:
+		response, err = client.GetBind9Config(ctx, inData, bigMessageOptions...)
 	default:
 		err = errors.New("doCall: unsupported request type")
 	}
@@ -89,14 +131,7 @@ func doCall(ctx context.Context, agent *Agent, in interface{}) (interface{}, err
 
 // Forward request received from channel to given agent and send back response
 // via channel to requestor.
-func (agents *connectedAgentsData) handleRequest(req *commLoopReq) {
-	// get agent and its grpc connection
-	agent, err := agents.GetConnectedAgent(req.AgentAddr)
-	if err != nil {
-		req.RespChan <- &channelResp{Response: nil, Err: err}
-		return
-	}
-
+func (agents *connectedAgentsData) handleRequest(agent *Agent, req *commLoopReq) {
 	// do call
 	ctx := context.Background()
 	response, err := doCall(ctx, agent, req.ReqData)
@@ -0,0 +1,137 @@
+package agentcomm
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	agentapi "isc.org/stork/api"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Creates connected agents with a mock client for each specified agent
+// address. The returned function performs a test teardown.
+func setupManagerTestCase(t *testing.T, addresses ...string) (*connectedAgentsData, map[string]*MockAgentClient, func()) {
+	settings := AgentsSettings{}
+	fec := &storktest.FakeEventCenter{}
+	agents := NewConnectedAgents(&settings, fec, CACertPEM, ServerCertPEM, ServerKeyPEM)
+
+	ctrl := gomock.NewController(t)
+	mocks := make(map[string]*MockAgentClient)
+	for _, address := range addresses {
+		agent, err := agents.GetConnectedAgent(address)
+		require.NoError(t, err)
+		mockAgentClient := NewMockAgentClient(ctrl)
+		agent.Client = mockAgentClient
+		mocks[address] = mockAgentClient
+	}
+
+	return agents.(*connectedAgentsData), mocks, func() {
+		agents.Shutdown()
+		ctrl.Finish()
+	}
+}
+
+// Test that a slow agent doesn't block the communication with other agents.
+func TestSendToAgentsInParallel(t *testing.T) {
+	agents, mocks, teardown := setupManagerTestCase(t, "127.0.0.1:8080", "127.0.0.1:8081")
+	defer teardown()
+
+	// The first agent doesn't respond until it is released.
+	release := make(chan struct{})
+	mocks["127.0.0.1:8080"].EXPECT().Ping(gomock.Any(), gomock.Any()).
+		DoAndReturn(func(ctx context.Context, in *agentapi.PingReq, opts ...grpc.CallOption) (*agentapi.PingRsp, error) {
+			<-release
+			return &agentapi.PingRsp{}, nil
+		})
+	mocks["127.0.0.1:8081"].EXPECT().Ping(gomock.Any(), gomock.Any()).
+		Return(&agentapi.PingRsp{}, nil)
+
+	slowDone := make(chan error)
+	go func() {
+		_, err := agents.sendAndRecvViaQueue("127.0.0.1:8080", &agentapi.PingReq{})
+		slowDone <- err
+	}()
+
+	// Wait for the request to the slow agent to be picked by its worker.
+	require.Eventually(t, func() bool {
+		return agents.GetConnectedAgentStats("127.0.0.1", 8080).GetQueueDepth() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	// The second agent should respond while the first one is stuck.
+	_, err := agents.sendAndRecvViaQueue("127.0.0.1:8081", &agentapi.PingReq{})
+	require.NoError(t, err)
+
+	close(release)
+	require.NoError(t, <-slowDone)
+}
+
+// Test that the requests to the same agent are sent in order and that
+// the queue depth and latency statistics are updated.
+func TestSendToAgentInOrder(t *testing.T) {
+	agents, mocks, teardown := setupManagerTestCase(t, "127.0.0.1:8080")
+	defer teardown()
+
+	release := make(chan struct{})
+	gomock.InOrder(
+		mocks["127.0.0.1:8080"].EXPECT().Ping(gomock.Any(), gomock.Any()).
+			DoAndReturn(func(ctx context.Context, in *agentapi.PingReq, opts ...grpc.CallOption) (*agentapi.PingRsp, error) {
+				<-release
+				return &agentapi.PingRsp{}, nil
+			}),
+		mocks["127.0.0.1:8080"].EXPECT().GetState(gomock.Any(), gomock.Any(), gomock.Any()).
+			Return(&agentapi.GetStateRsp{}, nil),
+	)
+
+	stats := agents.GetConnectedAgentStats("127.0.0.1", 8080)
+	require.NotNil(t, stats)
+	require.Zero(t, stats.GetQueueDepth())
+	require.Zero(t, stats.GetRequestsCount())
+	require.Zero(t, stats.GetAverageLatency())
+
+	pingDone := make(chan error)
+	go func() {
+		_, err := agents.sendAndRecvViaQueue("127.0.0.1:8080", &agentapi.PingReq{})
+		pingDone <- err
+	}()
+	require.Eventually(t, func() bool {
+		return stats.GetQueueDepth() == 1
+	}, time.Second, 10*time.Millisecond)
+
+	stateDone := make(chan error)
+	go func() {
+		_, err := agents.sendAndRecvViaQueue("127.0.0.1:8080", &agentapi.GetStateReq{})
+		stateDone <- err
+	}()
+	require.Eventually(t, func() bool {
+		return stats.GetQueueDepth() == 2
+	}, time.Second, 10*time.Millisecond)
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	require.NoError(t, <-pingDone)
+	require.NoError(t, <-stateDone)
+
+	require.Eventually(t, func() bool {
+		return stats.GetQueueDepth() == 0
+	}, time.Second, 10*time.Millisecond)
+	require.EqualValues(t, 2, stats.GetRequestsCount())
+	require.GreaterOrEqual(t, stats.GetAverageLatency(), 5*time.Millisecond)
+	require.Positive(t, stats.GetLastLatency())
+}
+
+// Test that sending a request fails after shutdown.
+func TestSendToAgentAfterShutdown(t *testing.T) {
+	settings := AgentsSettings{}
+	fec := &storktest.FakeEventCenter{}
+	agents := NewConnectedAgents(&settings, fec, CACertPEM, ServerCertPEM, ServerKeyPEM)
+	_, err := agents.GetConnectedAgent("127.0.0.1:8080")
+	require.NoError(t, err)
+	agents.Shutdown()
+
+	_, err = agents.(*connectedAgentsData).sendAndRecvViaQueue("127.0.0.1:8080", &agentapi.PingReq{})
+	require.ErrorContains(t, err, "has been stopped")
+}
@@ -20,6 +20,7 @@ type PeriodicPuller struct {
 	intervalSettingName string
 	lastInvokedAt       *atomic.Value
 	lastFinishedAt      *atomic.Value
+	health              *pullerHealth
 	DB                  *dbops.PgDB
 	Agents              ConnectedAgents
 }
@@ -28,20 +29,34 @@ type PeriodicPuller struct {
 // to periodically trigger an action. This action is supplied as a function instance.
 // This function is executed within a goroutine periodically according to the timer
 // interval available in the database. The intervalSettingName is a name of this
-// setting in the database. The pullerName is used for logging purposes.
+// setting in the database. The pullerName is used for logging purposes. The
+// function may check which apps should be pulled in the current execution
+// with ShouldPullApp and report the results with ReportAppPullResult.
 func NewPeriodicPuller(db *dbops.PgDB, agents ConnectedAgents, pullerName, intervalSettingName string, pullFunc func() error) (*PeriodicPuller, error) {
 	var lastInvokedAt atomic.Value
 	var lastFinishedAt atomic.Value
 	lastInvokedAt.Store(time.Time{})
 	lastFinishedAt.Store(time.Time{})
+	health := newPullerHealth()
 
-	periodicExecutor, err := storkutil.NewPeriodicExecutor(
+	// The on-demand pull requests are only served by the triggered
+	// executions.
+	execute := func(onDemand bool) error {
+		lastInvokedAt.Store(time.Now())
+		health.beginExecution(onDemand)
+		err := pullFunc()
+		health.endExecution()
+		lastFinishedAt.Store(time.Now())
+		return err
+	}
+
+	periodicExecutor, err := storkutil.NewPeriodicExecutorWithTrigger(
 		pullerName,
 		func() error {
-			lastInvokedAt.Store(time.Now())
-			err := pullFunc()
-			lastFinishedAt.Store(time.Now())
-			return err
+			return execute(false)
+		},
+		func() error {
+			return execute(true)
 		},
 		func() (int64, error) {
 			interval, err := dbmodel.GetSettingInt(db, intervalSettingName)
@@ -58,6 +73,7 @@ func NewPeriodicPuller(db *dbops.PgDB, agents ConnectedAgents, pullerName, inter
 		intervalSettingName: intervalSettingName,
 		lastInvokedAt:       &lastInvokedAt,
 		lastFinishedAt:      &lastFinishedAt,
+		health:              health,
 		DB:                  db,
 		Agents:              agents,
 	}
@@ -0,0 +1,209 @@
+package agentcomm
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	storkutil "isc.org/stork/util"
+)
+
+const (
+	// The maximum number of the puller intervals between the attempts to
+	// pull from a failing app.
+	maxBackoffIntervals int64 = 32
+	// The fraction of the backoff delay by which the delay is randomly
+	// shortened or extended. It spreads the attempts to pull from the
+	// failing apps across the puller cycles.
+	backoffJitter = 0.2
+)
+
+// Health of pulling the data from an app by a puller. The puller backs off
+// from the app after consecutive failures. The backoff delay doubles with
+// each failure.
+type AppPullHealth struct {
+	AppID               int64
+	ConsecutiveFailures int64
+	LastError           string
+	LastFailureAt       time.Time
+	LastSuccessAt       time.Time
+	// The time before which the puller doesn't pull from the app. It is
+	// zero if the puller doesn't back off from the app.
+	NextAttemptAt time.Time
+}
+
+// Request to pull the data from an app, or from its particular daemon,
+// without waiting for the puller interval. The daemon ID is zero if all
+// daemons of the app should be pulled.
+type PullRequest struct {
+	AppID    int64
+	DaemonID int64
+}
+
+// Holds the health of the apps and the pending pull requests of a puller.
+type pullerHealth struct {
+	mutex  *sync.Mutex
+	apps   map[int64]*AppPullHealth
+	queued []PullRequest
+	// Requests served by the current execution. If it is not empty, the
+	// execution pulls only from the requested apps.
+	current []PullRequest
+}
+
+// Creates a new instance holding the health of the apps.
+func newPullerHealth() *pullerHealth {
+	return &pullerHealth{
+		mutex: &sync.Mutex{},
+		apps:  make(map[int64]*AppPullHealth),
+	}
+}
+
+// Returns the delay before the next attempt to pull from an app after the
+// specified number of consecutive failures. The first failed attempt is
+// retried in the next puller cycle. The delay is randomized by the jitter.
+func getBackoffDelay(interval time.Duration, failures int64) time.Duration {
+	if failures < 2 {
+		return 0
+	}
+	multiplier := int64(1)
+	for i := int64(1); i < failures && multiplier < maxBackoffIntervals; i++ {
+		multiplier *= 2
+	}
+	delay := interval * time.Duration(multiplier)
+	jitter := (rand.Float64()*2 - 1) * backoffJitter * float64(delay) //nolint:gosec
+	return delay + time.Duration(jitter)
+}
+
+// Moves the pending pull requests to the current execution if the
+// execution has been triggered on demand. It is called when the puller
+// begins the execution. The regular executions leave the requests queued
+// for the triggered execution following them.
+func (h *pullerHealth) beginExecution(onDemand bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !onDemand {
+		h.current = nil
+		return
+	}
+	h.current = h.queued
+	h.queued = nil
+}
+
+// Clears the pull requests served by the finished execution.
+func (h *pullerHealth) endExecution() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.current = nil
+}
+
+// Checks if the current execution was requested on demand.
+func (p *PeriodicPuller) IsPullingOnDemand() bool {
+	p.health.mutex.Lock()
+	defer p.health.mutex.Unlock()
+	return len(p.health.current) > 0
+}
+
+// Checks if the puller should pull from the app in the current execution.
+// If the execution was requested on demand, only the requested apps are
+// pulled. Otherwise, the apps are pulled unless the puller backs off from
+// them.
+func (p *PeriodicPuller) ShouldPullApp(appID int64) bool {
+	p.health.mutex.Lock()
+	defer p.health.mutex.Unlock()
+	if len(p.health.current) > 0 {
+		for _, request := range p.health.current {
+			if request.AppID == appID {
+				return true
+			}
+		}
+		return false
+	}
+	health, ok := p.health.apps[appID]
+	if !ok {
+		return true
+	}
+	return !storkutil.UTCNow().Before(health.NextAttemptAt)
+}
+
+// Checks if the puller should pull from the daemon in the current
+// execution. It returns false for the daemons of an app other than the
+// daemon requested on demand.
+func (p *PeriodicPuller) ShouldPullDaemon(appID, daemonID int64) bool {
+	if !p.ShouldPullApp(appID) {
+		return false
+	}
+	p.health.mutex.Lock()
+	defer p.health.mutex.Unlock()
+	if len(p.health.current) == 0 {
+		return true
+	}
+	for _, request := range p.health.current {
+		if request.AppID == appID && (request.DaemonID == 0 || request.DaemonID == daemonID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Records the result of pulling from the app. A success resets the app's
+// health. A failure increases the number of consecutive failures and
+// schedules the next attempt after the backoff delay.
+func (p *PeriodicPuller) ReportAppPullResult(appID int64, err error) {
+	p.health.mutex.Lock()
+	defer p.health.mutex.Unlock()
+	now := storkutil.UTCNow()
+	health, ok := p.health.apps[appID]
+	if !ok {
+		health = &AppPullHealth{AppID: appID}
+		p.health.apps[appID] = health
+	}
+	if err == nil {
+		health.ConsecutiveFailures = 0
+		health.LastSuccessAt = now
+		health.NextAttemptAt = time.Time{}
+		return
+	}
+	health.ConsecutiveFailures++
+	health.LastError = err.Error()
+	health.LastFailureAt = now
+	interval := time.Duration(p.GetInterval()) * time.Second
+	if delay := getBackoffDelay(interval, health.ConsecutiveFailures); delay > 0 {
+		health.NextAttemptAt = now.Add(delay)
+	} else {
+		health.NextAttemptAt = time.Time{}
+	}
+}
+
+// Returns the health of the apps the puller has attempted to pull from,
+// sorted by app ID.
+func (p *PeriodicPuller) GetAppPullHealth() []AppPullHealth {
+	p.health.mutex.Lock()
+	defer p.health.mutex.Unlock()
+	var apps []AppPullHealth
+	for _, health := range p.health.apps {
+		apps = append(apps, *health)
+	}
+	sort.Slice(apps, func(i, j int) bool {
+		return apps[i].AppID < apps[j].AppID
+	})
+	return apps
+}
+
+// Requests pulling the data from the app or its daemon immediately. The
+// backoff from the app is ignored. The data is pulled in the puller's
+// goroutine, so this function doesn't wait for the result. If the puller
+// is paused, the data is pulled when it is unpaused. The request is ignored
+// if the puller is disabled.
+func (p *PeriodicPuller) PullNow(appID, daemonID int64) {
+	if !p.IsActive() {
+		return
+	}
+	p.health.mutex.Lock()
+	p.health.queued = append(p.health.queued, PullRequest{
+		AppID:    appID,
+		DaemonID: daemonID,
+	})
+	p.health.mutex.Unlock()
+	p.Trigger()
+}
@@ -0,0 +1,131 @@
+package agentcomm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the backoff delay doubles with each failure, is capped and
+// randomized by the jitter.
+func TestGetBackoffDelay(t *testing.T) {
+	interval := time.Minute
+	require.Zero(t, getBackoffDelay(interval, 0))
+	require.Zero(t, getBackoffDelay(interval, 1))
+
+	for failures, expected := range map[int64]time.Duration{
+		2:  2 * time.Minute,
+		3:  4 * time.Minute,
+		6:  32 * time.Minute,
+		50: 32 * time.Minute,
+	} {
+		delay := getBackoffDelay(interval, failures)
+		require.GreaterOrEqual(t, delay, time.Duration(float64(expected)*(1-backoffJitter)))
+		require.LessOrEqual(t, delay, time.Duration(float64(expected)*(1+backoffJitter)))
+	}
+}
+
+// Test that the puller backs off from the failing app and resets its health
+// after a success.
+func TestPullerBackoff(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	puller, _ := NewPeriodicPuller(db, nil, "test puller", "kea_hosts_puller_interval",
+		func() error { return nil })
+	defer puller.Shutdown()
+
+	require.True(t, puller.ShouldPullApp(1))
+
+	// The first failure is retried in the next cycle.
+	puller.ReportAppPullResult(1, errors.New("foo"))
+	require.True(t, puller.ShouldPullApp(1))
+
+	// The next failure causes the backoff.
+	puller.ReportAppPullResult(1, errors.New("bar"))
+	require.False(t, puller.ShouldPullApp(1))
+	require.True(t, puller.ShouldPullApp(2))
+
+	health := puller.GetAppPullHealth()
+	require.Len(t, health, 1)
+	require.EqualValues(t, 1, health[0].AppID)
+	require.EqualValues(t, 2, health[0].ConsecutiveFailures)
+	require.Equal(t, "bar", health[0].LastError)
+	require.NotZero(t, health[0].NextAttemptAt)
+
+	puller.ReportAppPullResult(1, nil)
+	require.True(t, puller.ShouldPullApp(1))
+	health = puller.GetAppPullHealth()
+	require.Zero(t, health[0].ConsecutiveFailures)
+	require.Zero(t, health[0].NextAttemptAt)
+	require.NotZero(t, health[0].LastSuccessAt)
+}
+
+// Test that the on-demand execution pulls only from the requested app and
+// daemon, even if the puller backs off from the app.
+func TestPullNow(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	type result struct {
+		app1, app2, daemon1, daemon2 bool
+	}
+	results := make(chan result, 1)
+
+	var puller *PeriodicPuller
+	puller, _ = NewPeriodicPuller(db, nil, "test puller", "kea_hosts_puller_interval",
+		func() error {
+			results <- result{
+				app1:    puller.ShouldPullApp(1),
+				app2:    puller.ShouldPullApp(2),
+				daemon1: puller.ShouldPullDaemon(1, 10),
+				daemon2: puller.ShouldPullDaemon(1, 11),
+			}
+			return nil
+		})
+	defer puller.Shutdown()
+
+	puller.ReportAppPullResult(1, errors.New("foo"))
+	puller.ReportAppPullResult(1, errors.New("foo"))
+
+	puller.PullNow(1, 10)
+
+	select {
+	case r := <-results:
+		require.True(t, r.app1)
+		require.False(t, r.app2)
+		require.True(t, r.daemon1)
+		require.False(t, r.daemon2)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the puller has not been triggered")
+	}
+
+	// The regular execution backs off from the app again.
+	require.Eventually(t, func() bool {
+		return !puller.ShouldPullApp(1) && puller.ShouldPullApp(2)
+	}, 5*time.Second, 100*time.Millisecond)
+}
+
+// Test that the regular executions leave the on-demand pull requests
+// queued for the triggered execution.
+func TestPullerHealthBeginExecution(t *testing.T) {
+	health := newPullerHealth()
+	health.queued = []PullRequest{{AppID: 1, DaemonID: 10}}
+
+	health.beginExecution(false)
+	require.Empty(t, health.current)
+	require.Len(t, health.queued, 1)
+	health.endExecution()
+
+	health.beginExecution(true)
+	require.Equal(t, []PullRequest{{AppID: 1, DaemonID: 10}}, health.current)
+	require.Empty(t, health.queued)
+	health.endExecution()
+	require.Empty(t, health.current)
+}
@@ -3,6 +3,7 @@ package agentcommtest
 import (
 	"context"
 
+	bind9config "isc.org/stork/appcfg/bind9"
 	keactrl "isc.org/stork/appctrl/kea"
 	"isc.org/stork/server/agentcomm"
 	storkutil "isc.org/stork/util"
@@ -26,6 +27,26 @@ type FakeAgents struct {
 
 	MachineState   *agentcomm.State
 	GetStateCalled bool
+
+	KeaStatsSamples       *agentcomm.KeaStatsSamples
+	RecordedSinceSequence uint64
+
+	// CSR returned by RenewCertificate, the certificates recorded by
+	// InstallCertificate and the error returned by both if set.
+	RenewalCSR              []byte
+	InstalledCertificate    []byte
+	InstalledCACertificates []byte
+	CertRenewalError        error
+
+	// BIND 9 configuration returned by GetBind9Config, the error returned
+	// by it if set and the number of its calls.
+	Bind9Config          *bind9config.Config
+	Bind9ConfigError     error
+	Bind9ConfigCallCount int
+
+	// Function invoked by FollowTextFile instead of sending the fake log
+	// line if set.
+	FollowTextFileFunc func(ctx context.Context, handler func(lines []string) error) error
 }
 
 // mockRndcOutput returns some mocked named response.
@@ -199,3 +220,63 @@ func (fa *FakeAgents) ForwardRndcCommand(ctx context.Context, app agentcomm.Cont
 func (fa *FakeAgents) TailTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64) ([]string, error) {
 	return []string{"lorem ipsum"}, nil
 }
+
+// Invokes the handler once with a fake log line or calls the function set
+// in the FollowTextFileFunc field.
+func (fa *FakeAgents) FollowTextFile(ctx context.Context, agentAddress string, agentPort int64, path string, offset int64, pattern, severity string, handler func(lines []string) error) error {
+	if fa.FollowTextFileFunc != nil {
+		return fa.FollowTextFileFunc(ctx, handler)
+	}
+	return handler([]string{"lorem ipsum"})
+}
+
+// Returns the Kea statistics samples set in the KeaStatsSamples field
+// following the specified sequence number. It returns no samples if the
+// field is nil.
+func (fa *FakeAgents) GetKeaStatsSamples(ctx context.Context, app agentcomm.ControlledApp, sinceSequence uint64, limit int64) (*agentcomm.KeaStatsSamples, error) {
+	fa.RecordedSinceSequence = sinceSequence
+	samples := &agentcomm.KeaStatsSamples{}
+	if fa.KeaStatsSamples == nil {
+		return samples, nil
+	}
+	samples.LastSequence = fa.KeaStatsSamples.LastSequence
+	for _, sample := range fa.KeaStatsSamples.Samples {
+		if sample.Sequence > sinceSequence && (limit == 0 || int64(len(samples.Samples)) < limit) {
+			samples.Samples = append(samples.Samples, sample)
+		}
+	}
+	return samples, nil
+}
+
+// Returns the CSR set in the RenewalCSR field or the error set in the
+// CertRenewalError field.
+func (fa *FakeAgents) RenewCertificate(ctx context.Context, address string, agentPort int64) ([]byte, error) {
+	fa.RecordedAddress = address
+	fa.RecordedPort = agentPort
+	if fa.CertRenewalError != nil {
+		return nil, fa.CertRenewalError
+	}
+	return fa.RenewalCSR, nil
+}
+
+// Records the installed certificates or returns the error set in the
+// CertRenewalError field.
+func (fa *FakeAgents) InstallCertificate(ctx context.Context, address string, agentPort int64, certPEM, caCertPEM []byte) error {
+	if fa.CertRenewalError != nil {
+		return fa.CertRenewalError
+	}
+	fa.InstalledCertificate = certPEM
+	fa.InstalledCACertificates = caCertPEM
+	return nil
+}
+
+// Returns the BIND 9 configuration set in the Bind9Config field or the
+// error set in the Bind9ConfigError field.
+func (fa *FakeAgents) GetBind9Config(ctx context.Context, app agentcomm.ControlledApp) (*bind9config.Config, error) {
+	fa.RecordedAddress, fa.RecordedPort, fa.RecordedKey, _, _ = app.GetControlAccessPoint()
+	fa.Bind9ConfigCallCount++
+	if fa.Bind9ConfigError != nil {
+		return nil, fa.Bind9ConfigError
+	}
+	return fa.Bind9Config, nil
+}
@@ -4,9 +4,14 @@ import (
 	"context"
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	bind9config "isc.org/stork/appcfg/bind9"
 	"isc.org/stork/server/agentcomm"
 	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
@@ -16,6 +21,10 @@ import (
 // Provide example date format how named returns dates.
 const namedLongDateFormat = "Mon, 02 Jan 2006 15:04:05 MST"
 
+// The IDs of the apps which agents don't support fetching the BIND 9
+// configuration. The warning about it is logged once per app.
+var bind9ConfigUnsupportedApps sync.Map
+
 // The cache statistics of the Bind9 named daemon.
 type CacheStatsData struct {
 	CacheHits   int64 `json:"CacheHits"`
@@ -168,6 +177,8 @@ func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *d
 		log.Warnf("Cannot get BIND 9 number of zones: unable to find number of zones in output")
 	}
 
+	bind9Daemon.Bind9Daemon.Config = getConfig(ctx2, agents, dbApp, bind9Daemon.ReloadedAt)
+
 	// Save status
 	dbApp.Active = bind9Daemon.Active
 	dbApp.Meta.Version = bind9Daemon.Version
@@ -179,6 +190,38 @@ func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *d
 	GetAppStatistics(ctx, agents, dbApp)
 }
 
+// Returns the BIND 9 configuration of the app. The configuration is fetched
+// from the agent only when named has been reconfigured since the previous
+// configuration was fetched. The previous configuration is returned if the
+// new one cannot be fetched, e.g., the agent doesn't support it yet.
+func getConfig(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App, reloadedAt time.Time) *bind9config.Config {
+	var previous *dbmodel.Daemon
+	if len(dbApp.Daemons) > 0 && dbApp.Daemons[0].Bind9Daemon != nil {
+		previous = dbApp.Daemons[0]
+	}
+	if previous != nil && previous.Bind9Daemon.Config != nil && !reloadedAt.IsZero() && previous.ReloadedAt.Equal(reloadedAt) {
+		return previous.Bind9Daemon.Config
+	}
+
+	config, err := agents.GetBind9Config(ctx, dbApp)
+	if err != nil {
+		if status.Code(errors.Cause(err)) == codes.Unimplemented {
+			if _, warned := bind9ConfigUnsupportedApps.LoadOrStore(dbApp.ID, true); !warned {
+				log.WithField("app", dbApp.ID).
+					Warn("The agent doesn't support fetching the BIND 9 configuration; upgrade the agent")
+			}
+		} else {
+			log.WithError(err).Warn("Problem getting BIND 9 configuration")
+		}
+		if previous != nil {
+			return previous.Bind9Daemon.Config
+		}
+		return nil
+	}
+	bind9ConfigUnsupportedApps.Delete(dbApp.ID)
+	return config
+}
+
 // Inserts or updates information about BIND 9 app in the database.
 func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.EventCenter) (err error) {
 	if app.ID == 0 {
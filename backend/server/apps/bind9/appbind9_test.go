@@ -5,7 +5,11 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	bind9config "isc.org/stork/appcfg/bind9"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
@@ -86,6 +90,82 @@ func TestGetAppState(t *testing.T) {
 	require.EqualValues(t, 30, daemon.Bind9Daemon.Stats.NamedStats.Views["_default"].Resolver.CacheStats["QueryMisses"])
 }
 
+// Test that the BIND 9 configuration fetched from the agent is stored in
+// the daemon.
+func TestGetAppStateConfig(t *testing.T) {
+	fa := agentcommtest.NewFakeAgents(nil, mockNamed)
+	var err error
+	fa.Bind9Config, err = bind9config.Parse(`zone "example.org" { type primary; };`)
+	require.NoError(t, err)
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "abcd", 953, false)
+	dbApp := dbmodel.App{
+		AccessPoints: accessPoints,
+		Machine: &dbmodel.Machine{
+			Address:   "192.0.2.0",
+			AgentPort: 1111,
+		},
+	}
+
+	GetAppState(context.Background(), fa, &dbApp, &storktest.FakeEventCenter{})
+
+	require.Len(t, dbApp.Daemons, 1)
+	require.NotNil(t, dbApp.Daemons[0].Bind9Daemon)
+	require.Equal(t, fa.Bind9Config, dbApp.Daemons[0].Bind9Daemon.Config)
+}
+
+// Test that the BIND 9 configuration is fetched again only when named has
+// been reconfigured, and that the previous configuration is kept when the
+// agent doesn't support fetching it.
+func TestGetAppStateConfigChanged(t *testing.T) {
+	fa := agentcommtest.NewFakeAgents(nil, mockNamed)
+	config, err := bind9config.Parse(`zone "example.org" { type primary; };`)
+	require.NoError(t, err)
+	fa.Bind9Config = config
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "abcd", 953, false)
+	dbApp := dbmodel.App{
+		ID:           1,
+		AccessPoints: accessPoints,
+		Machine: &dbmodel.Machine{
+			Address:   "192.0.2.0",
+			AgentPort: 1111,
+		},
+	}
+
+	GetAppState(context.Background(), fa, &dbApp, &storktest.FakeEventCenter{})
+	require.Equal(t, 1, fa.Bind9ConfigCallCount)
+
+	// The same reload time is returned by named.
+	fa.Bind9Config = nil
+	GetAppState(context.Background(), fa, &dbApp, &storktest.FakeEventCenter{})
+	require.Equal(t, 1, fa.Bind9ConfigCallCount)
+	require.Equal(t, config, dbApp.Daemons[0].Bind9Daemon.Config)
+
+	// Named has been reconfigured but the agent doesn't support fetching
+	// the configuration.
+	dbApp.Daemons[0].ReloadedAt = dbApp.Daemons[0].ReloadedAt.Add(-time.Hour)
+	fa.Bind9ConfigError = errors.WithMessage(status.Error(codes.Unimplemented, "unknown method"), "failed to get the BIND 9 configuration")
+	GetAppState(context.Background(), fa, &dbApp, &storktest.FakeEventCenter{})
+	require.Equal(t, 2, fa.Bind9ConfigCallCount)
+	require.Equal(t, config, dbApp.Daemons[0].Bind9Daemon.Config)
+	_, warned := bind9ConfigUnsupportedApps.Load(dbApp.ID)
+	require.True(t, warned)
+
+	// The agent has been upgraded.
+	dbApp.Daemons[0].ReloadedAt = dbApp.Daemons[0].ReloadedAt.Add(-time.Hour)
+	fa.Bind9ConfigError = nil
+	fa.Bind9Config, err = bind9config.Parse(`zone "example.com" { type primary; };`)
+	require.NoError(t, err)
+	GetAppState(context.Background(), fa, &dbApp, &storktest.FakeEventCenter{})
+	require.Equal(t, 3, fa.Bind9ConfigCallCount)
+	require.Equal(t, fa.Bind9Config, dbApp.Daemons[0].Bind9Daemon.Config)
+	_, warned = bind9ConfigUnsupportedApps.Load(dbApp.ID)
+	require.False(t, warned)
+}
+
 // Tests that BIND 9 can be added and then updated in the database.
 func TestCommitAppIntoDB(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
@@ -49,9 +49,16 @@ func (statsPuller *StatsPuller) pullStats() error {
 	// get stats from each bind9 app
 	var lastErr error
 	appsOkCnt := 0
+	appsCnt := 0
 	for _, dbApp := range dbApps {
+		// Skip the apps the puller backs off from.
+		if !statsPuller.ShouldPullApp(dbApp.ID) {
+			continue
+		}
+		appsCnt++
 		dbApp2 := dbApp
 		err := statsPuller.getStatsFromApp(&dbApp2)
+		statsPuller.ReportAppPullResult(dbApp.ID, err)
 		if err != nil {
 			lastErr = err
 			log.Errorf("Error occurred while getting stats from app %+v: %+v", dbApp, err)
@@ -59,11 +66,18 @@ func (statsPuller *StatsPuller) pullStats() error {
 			appsOkCnt++
 		}
 	}
-	log.Printf("Completed pulling stats from BIND 9 apps: %d/%d succeeded", appsOkCnt, len(dbApps))
+	log.Printf("Completed pulling stats from BIND 9 apps: %d/%d succeeded", appsOkCnt, appsCnt)
+
+	// Compare the zones pulled from all apps to detect the issues with
+	// the zone transfers.
+	if err := DetectZoneIssues(statsPuller.DB, statsPuller.EventCenter); err != nil {
+		lastErr = err
+		log.WithError(err).Error("Error occurred while detecting BIND 9 zone issues")
+	}
 	return lastErr
 }
 
-// Get stats from given bind9 app.
+// Get stats and zones from given bind9 app.
 func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 	// if app or daemon not active then do nothing
 	if len(dbApp.Daemons) > 0 && !dbApp.Daemons[0].Active {
@@ -111,5 +125,20 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 	}
 
 	dbApp.Daemons[0].Bind9Daemon.Stats.NamedStats = namedStats
-	return dbmodel.UpdateDaemon(statsPuller.DB, dbApp.Daemons[0])
+	if err = dbmodel.UpdateDaemon(statsPuller.DB, dbApp.Daemons[0]); err != nil {
+		return err
+	}
+
+	// Get the zones served by the daemon in all views. The statistics have
+	// been pulled successfully, so the failure to get the zones must not
+	// make the puller back off from the app.
+	zones, err := GetAppZones(ctx, statsPuller.Agents, dbApp)
+	if err != nil {
+		log.WithError(err).Warnf("Failed to get zones from BIND 9 app %d", dbApp.ID)
+		return nil
+	}
+	if err = dbmodel.CommitZones(statsPuller.DB, dbApp.Daemons[0].ID, zones); err != nil {
+		log.WithError(err).Errorf("Failed to store zones of BIND 9 app %d", dbApp.ID)
+	}
+	return nil
 }
@@ -0,0 +1,318 @@
+package bind9
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	bind9config "isc.org/stork/appcfg/bind9"
+	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	storkutil "isc.org/stork/util"
+)
+
+// A zone entry returned by the named statistics channel. The serial is
+// a number if the zone is loaded or "-" otherwise. The expiration and
+// next refresh times are only returned for the secondary zones.
+type ZoneData struct {
+	Name    string          `json:"name"`
+	Class   string          `json:"class"`
+	Serial  json.RawMessage `json:"serial"`
+	Type    string          `json:"type"`
+	Loaded  string          `json:"loaded"`
+	Expires string          `json:"expires"`
+	Refresh string          `json:"refresh"`
+}
+
+// The view entry of the zones statistics JSON structure.
+type ZonesViewData struct {
+	Zones []ZoneData `json:"zones"`
+}
+
+// JSON structure of the response returned by the named BIND 9 daemon on
+// fetching the zones statistics.
+type NamedZonesGetResponse struct {
+	Views map[string]*ZonesViewData `json:"views,omitempty"`
+}
+
+// Parses the time returned by the statistics channel. It returns zero time
+// if the time is not specified or invalid.
+func parseZoneTime(value string) time.Time {
+	if len(value) == 0 {
+		return time.Time{}
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.WithError(err).Warnf("Cannot parse zone time %s", value)
+		return time.Time{}
+	}
+	return parsed.UTC()
+}
+
+// Converts the zones returned by the statistics channel to the database
+// model. The zones of all views are returned. The last refresh time of
+// the secondary zone is initially the time when it was loaded, i.e., the
+// time of the last zone transfer. It is updated when the zones are
+// committed to the database.
+func (response NamedZonesGetResponse) getZones(now time.Time) (zones []*dbmodel.Zone) {
+	for viewName, view := range response.Views {
+		if view == nil {
+			continue
+		}
+		for _, zone := range view.Zones {
+			dbZone := &dbmodel.Zone{
+				View:          viewName,
+				Name:          zone.Name,
+				Class:         zone.Class,
+				Type:          zone.Type,
+				LoadedAt:      parseZoneTime(zone.Loaded),
+				ExpiresAt:     parseZoneTime(zone.Expires),
+				NextRefreshAt: parseZoneTime(zone.Refresh),
+				UpdatedAt:     now,
+			}
+			if dbZone.IsSecondary() {
+				dbZone.RefreshedAt = dbZone.LoadedAt
+			}
+			// The serial is not a number if the zone is not loaded.
+			if serial, err := strconv.ParseInt(string(zone.Serial), 10, 64); err == nil {
+				dbZone.Serial = &serial
+			}
+			zones = append(zones, dbZone)
+		}
+	}
+	return zones
+}
+
+// Get the zones served by the named daemon in all views using the
+// ForwardToNamedStats function.
+func GetAppZones(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App) ([]*dbmodel.Zone, error) {
+	statsChannel, err := dbApp.GetAccessPoint(dbmodel.AccessPointStatistics)
+	if err != nil {
+		return nil, err
+	}
+
+	zonesOutput := NamedZonesGetResponse{}
+	err = agents.ForwardToNamedStats(ctx, dbApp.Machine.Address, dbApp.Machine.AgentPort, statsChannel.Address, statsChannel.Port, "json/v1/zones", &zonesOutput)
+	if err != nil {
+		return nil, err
+	}
+	return zonesOutput.getZones(storkutil.UTCNow()), nil
+}
+
+// Issues of a zone detected by comparing it with the zones served by the
+// other daemons.
+type zoneIssues struct {
+	serialLagging  bool
+	transferFailed bool
+	// The serial of the primary zone if the serial of the zone falls
+	// behind it.
+	primarySerial int64
+}
+
+// Returns the key identifying the zone served in a view by different
+// daemons.
+func getZoneKey(zone *dbmodel.Zone) string {
+	return zone.View + "/" + zone.Name + "/" + zone.Class
+}
+
+// Returns the key identifying the zone in a view of the daemon's
+// configuration. The zones outside of the views are returned by the
+// statistics channel in the _default view.
+func getConfigZoneKey(view, name string) string {
+	if view == "" {
+		view = "_default"
+	}
+	return view + "/" + strings.ToLower(strings.TrimSuffix(name, "."))
+}
+
+// Indexes the addresses of the primary servers from which the secondary
+// zones are transferred according to the configurations of the daemons
+// serving them. The returned map is indexed by the daemon ID and the
+// zone key.
+func indexZonePrimaries(configs map[int64]*bind9config.Config) map[int64]map[string][]string {
+	index := make(map[int64]map[string][]string)
+	for daemonID, config := range configs {
+		if config == nil {
+			continue
+		}
+		daemonIndex := make(map[string][]string)
+		for _, configZone := range config.GetAllZones() {
+			daemonIndex[getConfigZoneKey(configZone.View, configZone.Name)] = configZone.Primaries
+		}
+		index[daemonID] = daemonIndex
+	}
+	return index
+}
+
+// Returns the addresses of the primary servers from which the secondary
+// zone is transferred. It returns nil if the configuration of the daemon
+// serving the zone is not available or it doesn't include the zone.
+func getZonePrimaries(index map[int64]map[string][]string, zone *dbmodel.Zone) []string {
+	return index[zone.DaemonID][getConfigZoneKey(zone.View, zone.Name)]
+}
+
+// Checks if the zone is served by the daemon listening on one of the
+// specified addresses. The daemon's machine address and the addresses of
+// its access points are compared with them. The loopback and unspecified
+// addresses of the access points are ignored because they don't
+// identify the machine.
+func isZoneServedAt(zone *dbmodel.Zone, addresses []string) bool {
+	if zone.Daemon == nil || zone.Daemon.App == nil {
+		return false
+	}
+	app := zone.Daemon.App
+	var daemonAddresses []string
+	if app.Machine != nil {
+		daemonAddresses = append(daemonAddresses, app.Machine.Address)
+	}
+	for _, accessPoint := range app.AccessPoints {
+		if ip := net.ParseIP(accessPoint.Address); ip != nil && !ip.IsLoopback() && !ip.IsUnspecified() {
+			daemonAddresses = append(daemonAddresses, accessPoint.Address)
+		}
+	}
+	for _, address := range addresses {
+		for _, daemonAddress := range daemonAddresses {
+			if address == daemonAddress {
+				return true
+			}
+			if ip := net.ParseIP(address); ip != nil && ip.Equal(net.ParseIP(daemonAddress)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Detects the issues of the secondary zones. The serial of the secondary
+// zone falls behind if it is older than the serial of the primary zone
+// with the same view, name and class served by the monitored daemon from
+// which the secondary zone is transferred. The primary servers are taken
+// from the configuration of the secondary server, so the serial isn't
+// compared if the configuration is unknown. The configurations are
+// specified by the daemon IDs. The zone transfer has failed if the
+// secondary zone hasn't been loaded or it has expired.
+func findZoneIssues(zones []dbmodel.Zone, configs map[int64]*bind9config.Config, now time.Time) map[int64]zoneIssues {
+	primariesIndex := indexZonePrimaries(configs)
+	primaryZones := make(map[string][]*dbmodel.Zone)
+	for i := range zones {
+		if !zones[i].IsPrimary() || zones[i].Serial == nil {
+			continue
+		}
+		key := getZoneKey(&zones[i])
+		primaryZones[key] = append(primaryZones[key], &zones[i])
+	}
+	issues := make(map[int64]zoneIssues)
+	for i := range zones {
+		zone := &zones[i]
+		if !zone.IsSecondary() {
+			continue
+		}
+		var zi zoneIssues
+		if zone.Serial == nil || (!zone.ExpiresAt.IsZero() && zone.ExpiresAt.Before(now)) {
+			zi.transferFailed = true
+		}
+		if zone.Serial != nil {
+			primaries := getZonePrimaries(primariesIndex, zone)
+			for _, primaryZone := range primaryZones[getZoneKey(zone)] {
+				if !isZoneServedAt(primaryZone, primaries) {
+					continue
+				}
+				if dbmodel.IsSerialBehind(*zone.Serial, *primaryZone.Serial) &&
+					(!zi.serialLagging || dbmodel.IsSerialBehind(zi.primarySerial, *primaryZone.Serial)) {
+					zi.serialLagging = true
+					zi.primarySerial = *primaryZone.Serial
+				}
+			}
+		}
+		issues[zone.ID] = zi
+	}
+	return issues
+}
+
+// The minimum time for which the serial of the secondary zone must fall
+// behind the primary before it is reported. The serial normally falls
+// behind until the secondary server is notified about the change or
+// refreshes the zone.
+const zoneSerialLagGracePeriod = 15 * time.Minute
+
+// Checks if the serial of the secondary zone falling behind since the
+// specified time has been falling behind for longer than the refresh
+// interval of the zone and the grace period. The refresh interval is
+// estimated from the last and next refresh times.
+func isZoneSerialLagReportable(zone *dbmodel.Zone, since, now time.Time) bool {
+	threshold := zoneSerialLagGracePeriod
+	if !zone.RefreshedAt.IsZero() && zone.NextRefreshAt.Sub(zone.RefreshedAt) > threshold {
+		threshold = zone.NextRefreshAt.Sub(zone.RefreshedAt)
+	}
+	return now.Sub(since) > threshold
+}
+
+// Detects the issues of the zones served by all monitored daemons and
+// updates them in the database. It raises an event when the serial of
+// a secondary zone falls behind the primary for longer than the zone's
+// refresh interval and the grace period, or the zone transfer fails.
+// The events are not raised again until the issue is resolved.
+func DetectZoneIssues(db dbops.DBI, eventCenter eventcenter.EventCenter) error {
+	zones, err := dbmodel.GetAllZones(db)
+	if err != nil {
+		return err
+	}
+	daemons, err := dbmodel.GetAllBind9Daemons(db)
+	if err != nil {
+		return err
+	}
+	configs := make(map[int64]*bind9config.Config)
+	for _, daemon := range daemons {
+		configs[daemon.DaemonID] = daemon.Config
+	}
+	now := storkutil.UTCNow()
+	allIssues := findZoneIssues(zones, configs, now)
+	var lastErr error
+	for i := range zones {
+		zone := &zones[i]
+		issues, ok := allIssues[zone.ID]
+		if !ok {
+			continue
+		}
+		serialLagging := zone.SerialLagging
+		serialLaggingSince := zone.SerialLaggingSince
+		switch {
+		case !issues.serialLagging:
+			serialLagging = false
+			serialLaggingSince = time.Time{}
+		case serialLaggingSince.IsZero():
+			serialLaggingSince = now
+		}
+		if !serialLagging && issues.serialLagging && isZoneSerialLagReportable(zone, serialLaggingSince, now) {
+			serialLagging = true
+			eventCenter.AddWarningEvent(
+				fmt.Sprintf("Serial %d of zone %s in view %s on {daemon} falls behind the primary serial %d",
+					*zone.Serial, zone.Name, zone.View, issues.primarySerial),
+				zone.Daemon, zone.Daemon.App)
+		}
+		if zone.SerialLagging == serialLagging && zone.SerialLaggingSince.Equal(serialLaggingSince) &&
+			zone.TransferFailed == issues.transferFailed {
+			continue
+		}
+		if issues.transferFailed && !zone.TransferFailed {
+			eventCenter.AddErrorEvent(
+				fmt.Sprintf("Transfer of zone %s in view %s to {daemon} failed", zone.Name, zone.View),
+				zone.Daemon, zone.Daemon.App)
+		}
+		zone.SerialLagging = serialLagging
+		zone.SerialLaggingSince = serialLaggingSince
+		zone.TransferFailed = issues.transferFailed
+		if err := dbmodel.UpdateZoneIssues(db, zone); err != nil {
+			lastErr = pkgerrors.WithMessagef(err, "problem updating issues of zone %s", zone.Name)
+		}
+	}
+	return lastErr
+}
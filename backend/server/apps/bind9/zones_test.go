@@ -0,0 +1,303 @@
+package bind9
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	bind9config "isc.org/stork/appcfg/bind9"
+	"isc.org/stork/server/agentcomm"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Test that the zones returned by the statistics channel are converted to
+// the database model.
+func TestGetZones(t *testing.T) {
+	response := NamedZonesGetResponse{}
+	err := agentcomm.UnmarshalNamedStatsResponse(`{
+		"json-stats-version": "1.6",
+		"views": {
+			"_default": {
+				"zones": [
+					{
+						"name": "example.com",
+						"class": "IN",
+						"serial": 2023051001,
+						"type": "primary",
+						"loaded": "2023-05-10T12:00:00Z"
+					},
+					{
+						"name": "example.org",
+						"class": "IN",
+						"serial": "-",
+						"type": "secondary",
+						"expires": "2023-05-17T12:00:00Z",
+						"refresh": "2023-05-10T13:00:00Z"
+					}
+				]
+			}
+		}
+	}`, &response)
+	require.NoError(t, err)
+
+	now := time.Date(2023, 5, 10, 12, 30, 0, 0, time.UTC)
+	zones := response.getZones(now)
+	require.Len(t, zones, 2)
+
+	require.Equal(t, "_default", zones[0].View)
+	require.Equal(t, "example.com", zones[0].Name)
+	require.Equal(t, "IN", zones[0].Class)
+	require.Equal(t, "primary", zones[0].Type)
+	require.NotNil(t, zones[0].Serial)
+	require.EqualValues(t, 2023051001, *zones[0].Serial)
+	require.Equal(t, time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC), zones[0].LoadedAt)
+	require.Zero(t, zones[0].ExpiresAt)
+	require.Equal(t, now, zones[0].UpdatedAt)
+
+	require.Equal(t, "example.org", zones[1].Name)
+	require.Nil(t, zones[1].Serial)
+	require.Zero(t, zones[1].LoadedAt)
+	require.Equal(t, time.Date(2023, 5, 17, 12, 0, 0, 0, time.UTC), zones[1].ExpiresAt)
+	require.Equal(t, time.Date(2023, 5, 10, 13, 0, 0, 0, time.UTC), zones[1].NextRefreshAt)
+	require.Zero(t, zones[1].RefreshedAt)
+}
+
+// Returns a daemon with the specified ID running on the machine with the
+// specified address and having the specified configuration.
+func getZoneIssuesTestDaemon(t *testing.T, id int64, address, config string) *dbmodel.Daemon {
+	parsed, err := bind9config.Parse(config)
+	require.NoError(t, err)
+	return &dbmodel.Daemon{
+		ID: id,
+		Bind9Daemon: &dbmodel.Bind9Daemon{
+			Config: parsed,
+		},
+		App: &dbmodel.App{
+			Machine: &dbmodel.Machine{
+				Address: address,
+			},
+		},
+	}
+}
+
+// Test that the lagging serials and the failed transfers of the secondary
+// zones are detected.
+func TestFindZoneIssues(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	serial := func(serial int64) *int64 {
+		return &serial
+	}
+	primary1 := getZoneIssuesTestDaemon(t, 1, "192.0.2.1", `zone "example.com" { type primary; };`)
+	primary2 := getZoneIssuesTestDaemon(t, 2, "192.0.2.2", `zone "example.com" { type primary; };`)
+	secondary := getZoneIssuesTestDaemon(t, 3, "192.0.2.3", `
+		zone "example.com" { type secondary; primaries { 192.0.2.1; }; };
+		zone "example.org" { type secondary; primaries { 192.0.2.1; }; };
+		zone "example.net" { type secondary; primaries { 192.0.2.1; }; };
+		view "internal" { zone "example.com" { type secondary; primaries { 192.0.2.1; }; }; };
+	`)
+	unconfigured := getZoneIssuesTestDaemon(t, 4, "192.0.2.4", ``)
+	configs := make(map[int64]*bind9config.Config)
+	for _, daemon := range []*dbmodel.Daemon{primary1, primary2, secondary, unconfigured} {
+		configs[daemon.ID] = daemon.Bind9Daemon.Config
+	}
+	zones := []dbmodel.Zone{
+		{ID: 1, View: "_default", Name: "example.com", Class: "IN", Type: "primary", Serial: serial(5), DaemonID: primary1.ID, Daemon: primary1},
+		{ID: 2, View: "_default", Name: "example.com", Class: "IN", Type: "secondary", Serial: serial(4), DaemonID: secondary.ID, Daemon: secondary},
+		{ID: 3, View: "_default", Name: "example.com", Class: "IN", Type: "slave", Serial: serial(5), DaemonID: secondary.ID, Daemon: secondary},
+		{ID: 4, View: "_default", Name: "example.org", Class: "IN", Type: "secondary", DaemonID: secondary.ID, Daemon: secondary},
+		{ID: 5, View: "_default", Name: "example.net", Class: "IN", Type: "secondary", Serial: serial(1), ExpiresAt: now.Add(-time.Minute), DaemonID: secondary.ID, Daemon: secondary},
+		{ID: 6, View: "_default", Name: "example.net", Class: "IN", Type: "secondary", Serial: serial(1), ExpiresAt: now.Add(time.Minute), DaemonID: secondary.ID, Daemon: secondary},
+		// The secondary server doesn't transfer the zone from this primary.
+		{ID: 7, View: "internal", Name: "example.com", Class: "IN", Type: "primary", Serial: serial(9), DaemonID: primary2.ID, Daemon: primary2},
+		{ID: 8, View: "internal", Name: "example.com", Class: "IN", Type: "secondary", Serial: serial(4), DaemonID: secondary.ID, Daemon: secondary},
+		// The zone in another view is not compared.
+		{ID: 9, View: "external", Name: "example.com", Class: "IN", Type: "secondary", Serial: serial(4), DaemonID: secondary.ID, Daemon: secondary},
+		// The primary servers are unknown.
+		{ID: 10, View: "_default", Name: "example.com", Class: "IN", Type: "secondary", Serial: serial(4), DaemonID: unconfigured.ID, Daemon: unconfigured},
+	}
+	issues := findZoneIssues(zones, configs, now)
+	require.Len(t, issues, 8)
+
+	require.True(t, issues[2].serialLagging)
+	require.EqualValues(t, 5, issues[2].primarySerial)
+	require.False(t, issues[2].transferFailed)
+
+	require.False(t, issues[3].serialLagging)
+	require.False(t, issues[3].transferFailed)
+
+	require.False(t, issues[4].serialLagging)
+	require.True(t, issues[4].transferFailed)
+
+	require.True(t, issues[5].transferFailed)
+	require.False(t, issues[6].transferFailed)
+
+	require.False(t, issues[8].serialLagging)
+	require.False(t, issues[9].serialLagging)
+	require.False(t, issues[10].serialLagging)
+}
+
+// Test that the stats puller pulls the zones and raises the events when
+// the serial of the secondary zone falls behind the primary.
+func TestStatsPullerPullZones(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	// The first daemon serves the primary zone and the second daemon
+	// serves the secondary zone.
+	primarySerial := 2023051002
+	bind9Mock := func(callNo int, statsOutput interface{}) {
+		if _, ok := statsOutput.(*NamedZonesGetResponse); !ok {
+			return
+		}
+		zoneType := "primary"
+		serial := primarySerial
+		if callNo > 1 {
+			zoneType = "secondary"
+			serial = 2023051001
+		}
+		json := `{
+			"views": {
+				"_default": {
+					"zones": [
+						{
+							"name": "example.com",
+							"class": "IN",
+							"serial": ` + fmt.Sprint(serial) + `,
+							"type": "` + zoneType + `"
+						}
+					]
+				}
+			}
+		}`
+		_ = agentcomm.UnmarshalNamedStatsResponse(json, statsOutput)
+	}
+	fa := agentcommtest.NewFakeAgents(nil, bind9Mock)
+	fec := &storktest.FakeEventCenter{}
+
+	var accessPoints []*dbmodel.AccessPoint
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "127.0.0.1", "abcd", 953, false)
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointStatistics, "127.0.0.1", "abcd", 8000, false)
+
+	// The secondary server transfers the zone from the primary server.
+	configs := []string{
+		`zone "example.com" { type primary; };`,
+		`zone "example.com" { type secondary; primaries { 192.0.2.1; }; };`,
+	}
+
+	var daemonIDs []int64
+	for i := 0; i < 2; i++ {
+		machine := &dbmodel.Machine{
+			Address:   fmt.Sprintf("192.0.2.%d", i+1),
+			AgentPort: 1111,
+		}
+		err := dbmodel.AddMachine(db, machine)
+		require.NoError(t, err)
+		config, err := bind9config.Parse(configs[i])
+		require.NoError(t, err)
+		dbApp := dbmodel.App{
+			Type:         dbmodel.AppTypeBind9,
+			AccessPoints: accessPoints,
+			MachineID:    machine.ID,
+			Machine:      machine,
+			Daemons: []*dbmodel.Daemon{
+				{
+					Name:   "named",
+					Active: true,
+					Bind9Daemon: &dbmodel.Bind9Daemon{
+						Config: config,
+					},
+				},
+			},
+		}
+		err = CommitAppIntoDB(db, &dbApp, fec)
+		require.NoError(t, err)
+		daemonIDs = append(daemonIDs, dbApp.Daemons[0].ID)
+	}
+	fec.Events = nil
+
+	setting := dbmodel.Setting{
+		Name:    "bind9_stats_puller_interval",
+		ValType: dbmodel.SettingValTypeInt,
+		Value:   "60",
+	}
+	_, err := db.Model(&setting).Insert()
+	require.NoError(t, err)
+
+	sp, err := NewStatsPuller(db, fa, fec)
+	require.NoError(t, err)
+	defer sp.Shutdown()
+
+	err = sp.pullStats()
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:8000/json/v1/zones", fa.RecordedStatsURL)
+
+	zones, err := dbmodel.GetZonesByDaemonID(db, daemonIDs[0])
+	require.NoError(t, err)
+	require.Len(t, zones, 1)
+	require.Equal(t, "primary", zones[0].Type)
+	require.False(t, zones[0].SerialLagging)
+
+	// The serial falls behind but it is not reported until the grace
+	// period elapses.
+	zones, err = dbmodel.GetZonesByDaemonID(db, daemonIDs[1])
+	require.NoError(t, err)
+	require.Len(t, zones, 1)
+	require.Equal(t, "secondary", zones[0].Type)
+	require.False(t, zones[0].SerialLagging)
+	require.NotZero(t, zones[0].SerialLaggingSince)
+	require.False(t, zones[0].TransferFailed)
+	require.Empty(t, fec.Events)
+
+	// Pretend that the serial has been falling behind for an hour.
+	zones[0].SerialLaggingSince = zones[0].SerialLaggingSince.Add(-time.Hour)
+	err = dbmodel.UpdateZoneIssues(db, &zones[0])
+	require.NoError(t, err)
+	fa.CallNo = 0
+	err = sp.pullStats()
+	require.NoError(t, err)
+
+	zones, err = dbmodel.GetZonesByDaemonID(db, daemonIDs[1])
+	require.NoError(t, err)
+	require.True(t, zones[0].SerialLagging)
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "example.com")
+	require.EqualValues(t, daemonIDs[1], fec.Events[0].Relations.DaemonID)
+
+	// The event is not raised again while the serial falls behind.
+	fa.CallNo = 0
+	err = sp.pullStats()
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 1)
+
+	// The issue is cleared when the secondary catches up.
+	primarySerial = 2023051001
+	fa.CallNo = 0
+	err = sp.pullStats()
+	require.NoError(t, err)
+	zones, err = dbmodel.GetZonesByDaemonID(db, daemonIDs[1])
+	require.NoError(t, err)
+	require.False(t, zones[0].SerialLagging)
+	require.Zero(t, zones[0].SerialLaggingSince)
+	require.Len(t, fec.Events, 1)
+}
+
+// Test that the lagging serial is reported when it falls behind for longer
+// than the refresh interval of the zone and the grace period.
+func TestIsZoneSerialLagReportable(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	zone := &dbmodel.Zone{}
+	require.False(t, isZoneSerialLagReportable(zone, now.Add(-10*time.Minute), now))
+	require.True(t, isZoneSerialLagReportable(zone, now.Add(-20*time.Minute), now))
+
+	// The refresh interval is longer than the grace period.
+	zone.RefreshedAt = now.Add(-30 * time.Minute)
+	zone.NextRefreshAt = now.Add(30 * time.Minute)
+	require.False(t, isZoneSerialLagReportable(zone, now.Add(-50*time.Minute), now))
+	require.True(t, isZoneSerialLagReportable(zone, now.Add(-70*time.Minute), now))
+}
@@ -167,6 +167,43 @@ func getStateFromCA(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp
 	return allDaemons, dhcpDaemons, nil
 }
 
+// Get the name of the Kea daemon controlled directly over its control socket,
+// i.e., running without the Control Agent. There is exactly one daemon behind
+// the control socket. Its name is derived from the configuration it returns
+// in response to the config-get command. It returns a list of all Kea daemons
+// and a list of DHCP daemons like the getStateFromCA function.
+func getDaemonFromControlSocket(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App) ([]string, []string, error) {
+	cmds := []keactrl.SerializableCommand{
+		keactrl.NewCommand("config-get", nil, nil),
+	}
+	configGetResp := []keactrl.Response{}
+
+	cmdsResult, err := agents.ForwardToKeaOverHTTP(ctx, dbApp, cmds, &configGetResp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cmdsResult.GetFirstError(); err != nil {
+		return nil, nil, err
+	}
+	if len(configGetResp) == 0 || configGetResp[0].Arguments == nil || configGetResp[0].Result != 0 {
+		return nil, nil, errors.New("problem with config-get response from the Kea daemon controlled over the control socket")
+	}
+
+	config := keaconfig.NewConfigFromMap(configGetResp[0].Arguments)
+	if config == nil {
+		return nil, nil, errors.New("cannot parse the config returned by the Kea daemon controlled over the control socket")
+	}
+
+	switch name := config.GetDaemonName(); name {
+	case dhcp4, dhcp6:
+		return []string{name}, []string{name}, nil
+	case d2:
+		return []string{name}, []string{}, nil
+	default:
+		return nil, nil, errors.Errorf("unsupported Kea daemon controlled over the control socket: %s", name)
+	}
+}
+
 // Get state of Kea application daemons (beside Control Agent) using ForwardToKeaOverHTTP function.
 // The state, that is stored into dbApp, includes: version, config and runtime state of indicated Kea daemons.
 func getStateFromDaemons(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *dbmodel.App, daemonsMap map[string]*dbmodel.Daemon, allDaemons []string, dhcpDaemons []string, daemonsErrors map[string]string) error {
@@ -293,15 +330,29 @@ func GetAppState(ctx context.Context, agents agentcomm.ConnectedAgents, dbApp *d
 	// get state from CA
 	daemonsMap := map[string]*dbmodel.Daemon{}
 	daemonsErrors := map[string]string{}
-	allDaemons, dhcpDaemons, err := getStateFromCA(ctx2, agents, dbApp, daemonsMap, daemonsErrors)
-	if err != nil {
-		log.Warnf("Problem getting state from Kea CA: %s", err)
+	var (
+		allDaemons, dhcpDaemons []string
+		err                     error
+	)
+	if dbApp.UsesControlSocket() {
+		// There is no CA. Talk to the daemon directly.
+		allDaemons, dhcpDaemons, err = getDaemonFromControlSocket(ctx2, agents, dbApp)
+		if err != nil {
+			log.Warnf("Problem getting state from Kea daemon over the control socket: %s", err)
+		}
+	} else {
+		allDaemons, dhcpDaemons, err = getStateFromCA(ctx2, agents, dbApp, daemonsMap, daemonsErrors)
+		if err != nil {
+			log.Warnf("Problem getting state from Kea CA: %s", err)
+		}
 	}
 
 	// if no problems then now get state from the rest of Kea daemons
-	err = getStateFromDaemons(ctx2, agents, dbApp, daemonsMap, allDaemons, dhcpDaemons, daemonsErrors)
-	if err != nil {
-		log.Warnf("Problem getting state from Kea daemons: %s", err)
+	if len(allDaemons) > 0 || !dbApp.UsesControlSocket() {
+		err = getStateFromDaemons(ctx2, agents, dbApp, daemonsMap, allDaemons, dhcpDaemons, daemonsErrors)
+		if err != nil {
+			log.Warnf("Problem getting state from Kea daemons: %s", err)
+		}
 	}
 
 	// If this is new app let's set its active/inactive state based on the
@@ -365,9 +416,23 @@ func findChangesAndRaiseEvents(dbApp *dbmodel.App, daemonsMap map[string]*dbmode
 		events     []*dbmodel.Event
 	)
 
-	newCADaemon, ok := daemonsMap["ca"]
-	if !ok || !newCADaemon.Active {
-		// Kea Control Agent was not found in the response or it is inactive.
+	// The app is reachable when its Control Agent is active. The app controlled
+	// over the control socket has no Control Agent. It is reachable when any
+	// of its daemons is active.
+	reachable := false
+	if dbApp.UsesControlSocket() {
+		for _, daemon := range daemonsMap {
+			if daemon.Active {
+				reachable = true
+				break
+			}
+		}
+	} else if newCADaemon, ok := daemonsMap["ca"]; ok {
+		reachable = newCADaemon.Active
+	}
+	if !reachable {
+		// Kea Control Agent (or the daemon behind the control socket) was not
+		// found in the response or it is inactive.
 		for _, oldDaemon := range dbApp.Daemons {
 			// For all active daemons we need to mark them as inactive and raise events
 			// about the daemons being unreachable.
@@ -485,6 +550,21 @@ func handleConfigEvent(daemon, oldDaemon *dbmodel.Daemon, events *[]*dbmodel.Eve
 	return false
 }
 
+// Adds the daemon's configuration fetched from the daemon as a new version
+// of its configuration. The version is not associated with any user because
+// the configuration could have been changed outside of Stork.
+func addDaemonConfigVersion(tx *pg.Tx, daemon *dbmodel.Daemon) error {
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return nil
+	}
+	version, err := dbmodel.NewKeaDaemonConfigVersion(daemon.ID, daemon.KeaDaemon.Config, 0, "")
+	if err != nil {
+		return err
+	}
+	_, err = dbmodel.AddKeaDaemonConfigVersion(tx, version)
+	return err
+}
+
 // Removes associations between the daemon, shared networks, subnets and hosts.
 func deleteDaemonAssociations(tx *pg.Tx, daemon *dbmodel.Daemon) error {
 	// Remove associations between the daemon and the existing hosts.
@@ -631,6 +711,12 @@ func CommitAppIntoDB(db *dbops.PgDB, app *dbmodel.App, eventCenter eventcenter.E
 				}
 			}
 
+			// Store the daemon's configuration as a new version unless it is
+			// the same as the recent version.
+			if err = addDaemonConfigVersion(tx, daemon); err != nil {
+				return err
+			}
+
 			// Remove daemon associations with hosts, subnets and shared networks.
 			err = deleteDaemonAssociations(tx, daemon)
 			if err != nil {
@@ -8,6 +8,7 @@ import (
 	keactrl "isc.org/stork/appctrl/kea"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
+	dbmodeltest "isc.org/stork/server/database/model/test"
 	dbtest "isc.org/stork/server/database/test"
 	storktest "isc.org/stork/server/test/dbmodel"
 )
@@ -447,3 +448,86 @@ func TestCommitAppIntoDB(t *testing.T) {
 	require.EqualValues(t, 2345, returned.AccessPoints[0].Port)
 	require.True(t, returned.AccessPoints[0].UseSecureProtocol)
 }
+
+// Tests that the distinct configurations of the Kea daemons are stored as
+// the configuration versions when the app is committed into the database.
+func TestCommitAppIntoDBConfigVersions(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	server, err := dbmodeltest.NewKeaDHCPv4Server(db)
+	require.NoError(t, err)
+	err = server.Configure(`{
+		"Dhcp4": {
+			"valid-lifetime": 1000
+		}
+	}`)
+	require.NoError(t, err)
+
+	app, err := server.GetKea()
+	require.NoError(t, err)
+
+	fec := &storktest.FakeEventCenter{}
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+
+	// Commit the same configuration twice. It should result in a single
+	// version.
+	for i := 0; i < 2; i++ {
+		err = CommitAppIntoDB(db, app, fec, nil, lookup)
+		require.NoError(t, err)
+	}
+	versions, err := dbmodel.GetKeaDaemonConfigVersions(db, server.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.Zero(t, versions[0].UserID)
+	require.Empty(t, versions[0].Operation)
+
+	// Change the configuration. It should result in a new version.
+	app.Daemons[0].KeaDaemon.Config, err = dbmodel.NewKeaConfigFromJSON(`{
+		"Dhcp4": {
+			"valid-lifetime": 2000
+		}
+	}`)
+	require.NoError(t, err)
+	err = CommitAppIntoDB(db, app, fec, nil, lookup)
+	require.NoError(t, err)
+
+	versions, err = dbmodel.GetKeaDaemonConfigVersions(db, server.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	version, err := dbmodel.GetKeaDaemonConfigVersion(db, versions[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, version)
+	require.EqualValues(t, 2000, *version.Config.GetValidLifetimeParameters().ValidLifetime)
+}
+
+// Test that the app controlled over the control socket is reachable when
+// any of its daemons is active.
+func TestFindChangesAndRaiseEventsControlSocket(t *testing.T) {
+	dbApp := &dbmodel.App{
+		Type: dbmodel.AppTypeKea,
+		AccessPoints: []*dbmodel.AccessPoint{
+			{
+				Type:    dbmodel.AccessPointControlSocket,
+				Address: "/var/run/kea/kea4-ctrl-socket",
+			},
+		},
+	}
+	daemonsMap := map[string]*dbmodel.Daemon{
+		"dhcp4": dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true),
+		"dhcp6": dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv6, false),
+	}
+
+	for i := 0; i < 10; i++ {
+		_, replace, newDaemons, _, _ := findChangesAndRaiseEvents(dbApp, daemonsMap, map[string]string{})
+		require.True(t, replace)
+		require.Len(t, newDaemons, 2)
+	}
+
+	// The app is unreachable when all daemons are inactive.
+	daemonsMap["dhcp4"].Active = false
+	_, replace, newDaemons, _, _ := findChangesAndRaiseEvents(dbApp, daemonsMap, map[string]string{})
+	require.False(t, replace)
+	require.Empty(t, newDaemons)
+}
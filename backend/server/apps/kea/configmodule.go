@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 
+	"github.com/go-pg/pg/v10"
 	pkgerrors "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 	keaconfig "isc.org/stork/appcfg/kea"
 	keactrl "isc.org/stork/appctrl/kea"
 	config "isc.org/stork/server/config"
@@ -51,6 +53,39 @@ type SubnetConfigRecipeParams struct {
 	SubnetAfterUpdate *dbmodel.Subnet
 	// Edited or deleted subnet ID.
 	SubnetID *int64
+	// Indicates that the ID of the added subnet has been allocated by
+	// Stork rather than specified by the user. Such an ID is allocated
+	// again when the scheduled transaction is committed, if another
+	// subnet has taken it in the meantime.
+	SubnetIDAllocated bool
+}
+
+// A structure embedded in the ConfigRecipe grouping parameters used
+// in transactions adding, updating and deleting shared networks.
+type SharedNetworkConfigRecipeParams struct {
+	// An instance of the shared network before an update. It is fetched
+	// at the beginning of the shared network update or deletion.
+	SharedNetworkBeforeUpdate *dbmodel.SharedNetwork
+	// An instance of the shared network after it has been added or updated.
+	// It holds the subnets belonging to the network after the update.
+	SharedNetworkAfterUpdate *dbmodel.SharedNetwork
+	// Edited or deleted shared network ID.
+	SharedNetworkID *int64
+}
+
+// A structure embedded in the ConfigRecipe grouping parameters used
+// in transactions updating global DHCP parameters.
+type GlobalParametersConfigRecipeParams struct {
+	// Partial configuration holding the updated global parameters. It
+	// is merged into the configurations of the updated daemons.
+	GlobalParameters keaconfig.RawConfig
+}
+
+// A structure embedded in the ConfigRecipe grouping parameters used
+// in transactions restoring the daemons' configurations.
+type ConfigRollbackConfigRecipeParams struct {
+	// Restored version of the daemon's configuration.
+	ConfigVersion *dbmodel.KeaDaemonConfigVersion
 }
 
 // Represents a Kea config change recipe. A recipe is associated with
@@ -69,6 +104,15 @@ type ConfigRecipe struct {
 	// Embedded structure holding the parameters appropriate for the
 	// subnet management.
 	SubnetConfigRecipeParams
+	// Embedded structure holding the parameters appropriate for the
+	// shared network management.
+	SharedNetworkConfigRecipeParams
+	// Embedded structure holding the parameters appropriate for the
+	// global parameters management.
+	GlobalParametersConfigRecipeParams
+	// Embedded structure holding the parameters appropriate for
+	// restoring the configurations.
+	ConfigRollbackConfigRecipeParams
 }
 
 // A configuration manager module responsible for the Kea configuration.
@@ -115,8 +159,22 @@ func (module *ConfigModule) Commit(ctx context.Context) (context.Context, error)
 			ctx, err = module.commitHostUpdate(ctx)
 		case "host_delete":
 			ctx, err = module.commitHostDelete(ctx)
+		case "subnet_add":
+			ctx, err = module.commitSubnetAdd(ctx)
 		case "subnet_update":
 			ctx, err = module.commitSubnetUpdate(ctx)
+		case "subnet_delete":
+			ctx, err = module.commitSubnetDelete(ctx)
+		case "shared_network_add":
+			ctx, err = module.commitSharedNetworkAdd(ctx)
+		case "shared_network_update":
+			ctx, err = module.commitSharedNetworkUpdate(ctx)
+		case "shared_network_delete":
+			ctx, err = module.commitSharedNetworkDelete(ctx)
+		case "global_parameters_update":
+			ctx, err = module.commitGlobalParametersUpdate(ctx)
+		case "config_rollback":
+			ctx, err = module.commitConfigRollback(ctx)
 		default:
 			err = pkgerrors.Errorf("unknown operation %s when called Commit()", pu.Operation)
 		}
@@ -439,6 +497,263 @@ func (module *ConfigModule) commitChanges(ctx context.Context) (context.Context,
 	return ctx, nil
 }
 
+// Begins adding a new subnet. It initializes transaction state.
+func (module *ConfigModule) BeginSubnetAdd(ctx context.Context) (context.Context, error) {
+	// Create transaction state.
+	state := config.NewTransactionStateWithUpdate[ConfigRecipe]("kea", "subnet_add")
+	ctx = context.WithValue(ctx, config.StateContextKey, *state)
+	return ctx, nil
+}
+
+// Assigns the same subnet ID to all local subnets of the new subnet. If
+// the user specified the ID, it must be the same for all daemons. Otherwise,
+// the ID is allocated such that it is not used by any of the daemons. It
+// returns true if the ID has been allocated.
+func (module *ConfigModule) allocateLocalSubnetID(subnet *dbmodel.Subnet) (bool, error) {
+	var (
+		subnetID  int64
+		daemonIDs []int64
+	)
+	for _, ls := range subnet.LocalSubnets {
+		daemonIDs = append(daemonIDs, ls.DaemonID)
+		if ls.LocalSubnetID == 0 {
+			continue
+		}
+		if subnetID != 0 && subnetID != ls.LocalSubnetID {
+			return false, pkgerrors.Errorf("subnet %s must have the same ID in all daemons", subnet.Prefix)
+		}
+		subnetID = ls.LocalSubnetID
+	}
+	if subnetID != 0 {
+		return false, nil
+	}
+	subnetID, err := dbmodel.GetNextFreeLocalSubnetID(module.manager.GetDB(), daemonIDs...)
+	if err != nil {
+		return false, err
+	}
+	for _, ls := range subnet.LocalSubnets {
+		ls.LocalSubnetID = subnetID
+	}
+	return true, nil
+}
+
+// Applies new subnet. It allocates the subnet ID and prepares necessary
+// commands to be sent to Kea upon commit. If the subnet belongs to a shared
+// network, the commands adding the subnet to this network are also sent.
+// It locks the daemons' configurations, so the same subnet ID can't be
+// allocated in another transaction before this one is committed.
+func (module *ConfigModule) ApplySubnetAdd(ctx context.Context, subnet *dbmodel.Subnet) (context.Context, error) {
+	if len(subnet.LocalSubnets) == 0 {
+		return ctx, pkgerrors.Errorf("applied subnet %s is not associated with any daemon", subnet.Prefix)
+	}
+	existingSubnets, err := dbmodel.GetSubnetsByPrefix(module.manager.GetDB(), subnet.Prefix)
+	if err != nil {
+		return ctx, err
+	}
+	if len(existingSubnets) > 0 {
+		return ctx, pkgerrors.Errorf("subnet %s already exists", subnet.Prefix)
+	}
+	var daemonIDs []int64
+	for _, ls := range subnet.LocalSubnets {
+		daemonIDs = append(daemonIDs, ls.DaemonID)
+	}
+	ctx, err = module.manager.Lock(ctx, daemonIDs...)
+	if err != nil {
+		return ctx, pkgerrors.WithStack(config.NewLockError())
+	}
+	allocated, err := module.allocateLocalSubnetID(subnet)
+	if err != nil {
+		return ctx, err
+	}
+	recipe, err := module.createSubnetAddRecipe(subnet)
+	if err != nil {
+		return ctx, err
+	}
+	recipe.SubnetIDAllocated = allocated
+	return config.SetRecipeForUpdate(ctx, 0, recipe)
+}
+
+// Prepares the commands adding the subnet with the allocated ID to the
+// Kea servers, including the commands adding it to its shared network.
+func (module *ConfigModule) createSubnetAddRecipe(subnet *dbmodel.Subnet) (*ConfigRecipe, error) {
+	var (
+		sharedNetwork *dbmodel.SharedNetwork
+		err           error
+	)
+	if subnet.SharedNetworkID != 0 {
+		sharedNetwork, err = dbmodel.GetSharedNetwork(module.manager.GetDB(), subnet.SharedNetworkID)
+		if err != nil {
+			return nil, err
+		}
+		if sharedNetwork == nil {
+			return nil, pkgerrors.Errorf("shared network %d of the applied subnet %s does not exist",
+				subnet.SharedNetworkID, subnet.Prefix)
+		}
+	}
+	var commands []ConfigCommand
+	for _, ls := range subnet.LocalSubnets {
+		if ls.Daemon == nil {
+			return nil, pkgerrors.Errorf("applied subnet %s is associated with nil daemon", subnet.Prefix)
+		}
+		if ls.Daemon.App == nil {
+			return nil, pkgerrors.Errorf("applied subnet %s is associated with nil app", subnet.Prefix)
+		}
+		// Convert the subnet information to Kea subnet.
+		lookup := module.manager.GetDHCPOptionDefinitionLookup()
+		addArguments := make(map[string]any)
+		var commandName, networkCommandName string
+		switch subnet.GetFamily() {
+		case 4:
+			subnet4, err := keaconfig.CreateSubnet4(ls.DaemonID, lookup, subnet)
+			if err != nil {
+				return nil, err
+			}
+			addArguments["subnet4"] = []*keaconfig.Subnet4{
+				subnet4,
+			}
+			commandName = "subnet4-add"
+			networkCommandName = "network4-subnet-add"
+		default:
+			subnet6, err := keaconfig.CreateSubnet6(ls.DaemonID, lookup, subnet)
+			if err != nil {
+				return nil, err
+			}
+			addArguments["subnet6"] = []*keaconfig.Subnet6{
+				subnet6,
+			}
+			commandName = "subnet6-add"
+			networkCommandName = "network6-subnet-add"
+		}
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand(commandName, []string{ls.Daemon.Name}, addArguments),
+			App:     ls.Daemon.App,
+		})
+		if sharedNetwork != nil {
+			networkArguments := map[string]any{
+				"id":   ls.LocalSubnetID,
+				"name": sharedNetwork.Name,
+			}
+			commands = append(commands, ConfigCommand{
+				Command: keactrl.NewCommand(networkCommandName, []string{ls.Daemon.Name}, networkArguments),
+				App:     ls.Daemon.App,
+			})
+		}
+	}
+
+	// Create the commands to write the updated configuration to files. The new
+	// subnet won't persist across the servers' restarts otherwise.
+	for _, ls := range subnet.LocalSubnets {
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand("config-write", []string{ls.Daemon.Name}, nil),
+			App:     ls.Daemon.App,
+		})
+	}
+
+	recipe := &ConfigRecipe{
+		SubnetConfigRecipeParams: SubnetConfigRecipeParams{
+			SubnetAfterUpdate: subnet,
+		},
+		Commands: commands,
+	}
+	return recipe, nil
+}
+
+// Create the subnet in the Kea servers and add it to the Stork database.
+// If the transaction has been scheduled, other subnets could be added
+// before the deadline. In this case, the daemons' configurations are
+// locked and the subnet is verified again. If its ID has been allocated
+// by Stork and is now in use, a new ID is allocated.
+func (module *ConfigModule) commitSubnetAdd(ctx context.Context) (context.Context, error) {
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	var err error
+	if state.Scheduled {
+		for i, update := range state.Updates {
+			if update.Recipe.SubnetAfterUpdate == nil {
+				return ctx, pkgerrors.New("server logic error: the update.Recipe.SubnetAfterUpdate cannot be nil when committing the subnet creation")
+			}
+			var recipe *ConfigRecipe
+			recipe, err = module.verifyScheduledSubnetAdd(ctx, update.Recipe)
+			if err != nil {
+				return ctx, err
+			}
+			if ctx, err = config.SetRecipeForUpdate(ctx, i, recipe); err != nil {
+				return ctx, err
+			}
+		}
+		state, _ = config.GetTransactionState[ConfigRecipe](ctx)
+	}
+	ctx, err = module.commitChanges(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	for _, update := range state.Updates {
+		if update.Recipe.SubnetAfterUpdate == nil {
+			return ctx, pkgerrors.New("server logic error: the update.Recipe.SubnetAfterUpdate cannot be nil when committing the subnet creation")
+		}
+		_, err = dbmodel.CommitNetworksIntoDB(module.manager.GetDB(), []dbmodel.SharedNetwork{}, []dbmodel.Subnet{*update.Recipe.SubnetAfterUpdate})
+		if err != nil {
+			return ctx, pkgerrors.WithMessagef(err, "subnet has been successfully added to Kea but adding it to the Stork database failed")
+		}
+		module.addPendingConfigChanges(ctx, update)
+	}
+	return ctx, nil
+}
+
+// Verifies that the scheduled subnet can still be added to the daemons.
+// It returns the recipe to be committed. The recipe is re-created when
+// the subnet ID allocated by Stork has been taken in the meantime. The
+// subnet ID specified by the user is never replaced.
+func (module *ConfigModule) verifyScheduledSubnetAdd(ctx context.Context, recipe ConfigRecipe) (*ConfigRecipe, error) {
+	subnet := recipe.SubnetAfterUpdate
+	if len(subnet.LocalSubnets) == 0 {
+		return nil, pkgerrors.Errorf("scheduled subnet %s is not associated with any daemon", subnet.Prefix)
+	}
+	var daemonIDs []int64
+	for _, ls := range subnet.LocalSubnets {
+		daemonIDs = append(daemonIDs, ls.DaemonID)
+	}
+	// Prevent other transactions from allocating the same ID.
+	lockedCtx, err := module.manager.Lock(ctx, daemonIDs...)
+	if err != nil {
+		return nil, pkgerrors.WithStack(config.NewLockError())
+	}
+	defer module.manager.Unlock(lockedCtx)
+
+	existingSubnets, err := dbmodel.GetSubnetsByPrefix(module.manager.GetDB(), subnet.Prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(existingSubnets) > 0 {
+		return nil, pkgerrors.Errorf("subnet %s already exists", subnet.Prefix)
+	}
+	subnetID := subnet.LocalSubnets[0].LocalSubnetID
+	used, err := dbmodel.IsLocalSubnetIDUsed(module.manager.GetDB(), subnetID, daemonIDs...)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return &recipe, nil
+	}
+	if !recipe.SubnetIDAllocated {
+		return nil, pkgerrors.Errorf("subnet ID %d of the subnet %s is already used", subnetID, subnet.Prefix)
+	}
+	for _, ls := range subnet.LocalSubnets {
+		ls.LocalSubnetID = 0
+	}
+	if _, err = module.allocateLocalSubnetID(subnet); err != nil {
+		return nil, err
+	}
+	newRecipe, err := module.createSubnetAddRecipe(subnet)
+	if err != nil {
+		return nil, err
+	}
+	newRecipe.SubnetIDAllocated = true
+	return newRecipe, nil
+}
+
 // Begins a subnet update. It fetches the specified subnet from the database
 // and stores it in the context state. Then, it locks the daemons associated
 // with the subnet for updates.
@@ -558,6 +873,998 @@ func (module *ConfigModule) commitSubnetUpdate(ctx context.Context) (context.Con
 		if err != nil {
 			return ctx, pkgerrors.WithMessagef(err, "subnet has been successfully updated in Kea but updating it in the Stork database failed")
 		}
+		module.addPendingConfigChanges(ctx, update)
+	}
+	return ctx, nil
+}
+
+// Begins deleting a subnet. It fetches the specified subnet from the database
+// and stores it in the context state. Then, it locks the daemons associated
+// with the subnet for updates.
+func (module *ConfigModule) BeginSubnetDelete(ctx context.Context, subnetID int64) (context.Context, error) {
+	// Try to get the subnet to be deleted from the database.
+	subnet, err := dbmodel.GetSubnet(module.manager.GetDB(), subnetID)
+	if err != nil {
+		// Internal database error.
+		return ctx, err
+	}
+	// Subnet does not exist.
+	if subnet == nil {
+		return ctx, pkgerrors.WithStack(config.NewSubnetNotFoundError(subnetID))
+	}
+	// Get the list of daemons whose configurations must be locked for
+	// updates.
+	var daemonIDs []int64
+	for _, ls := range subnet.LocalSubnets {
+		daemonIDs = append(daemonIDs, ls.DaemonID)
+	}
+	// Try to lock configurations.
+	ctx, err = module.manager.Lock(ctx, daemonIDs...)
+	if err != nil {
+		return ctx, pkgerrors.WithStack(config.NewLockError())
+	}
+	// Create transaction state.
+	state := config.NewTransactionStateWithUpdate[ConfigRecipe]("kea", "subnet_delete", daemonIDs...)
+	recipe := &ConfigRecipe{
+		SubnetConfigRecipeParams: SubnetConfigRecipeParams{
+			SubnetBeforeUpdate: subnet,
+		},
+	}
+	if err := state.SetRecipeForUpdate(0, recipe); err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, config.StateContextKey, *state)
+	return ctx, nil
+}
+
+// Creates requests to delete a subnet. It prepares necessary commands to be
+// sent to Kea upon commit.
+func (module *ConfigModule) ApplySubnetDelete(ctx context.Context, subnet *dbmodel.Subnet) (context.Context, error) {
+	if len(subnet.LocalSubnets) == 0 {
+		return ctx, pkgerrors.Errorf("deleted subnet %d is not associated with any daemon", subnet.ID)
+	}
+	var commands []ConfigCommand
+	for _, ls := range subnet.LocalSubnets {
+		if ls.Daemon == nil {
+			return ctx, pkgerrors.Errorf("deleted subnet %d is associated with nil daemon", subnet.ID)
+		}
+		if ls.Daemon.App == nil {
+			return ctx, pkgerrors.Errorf("deleted subnet %d is associated with nil app", subnet.ID)
+		}
+		commandName := "subnet6-del"
+		if subnet.GetFamily() == 4 {
+			commandName = "subnet4-del"
+		}
+		arguments := map[string]any{
+			"id": ls.LocalSubnetID,
+		}
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand(commandName, []string{ls.Daemon.Name}, arguments),
+			App:     ls.Daemon.App,
+		})
+	}
+
+	// Create the commands to write the updated configuration to files. The
+	// deleted subnet would be restored after the servers' restarts otherwise.
+	for _, ls := range subnet.LocalSubnets {
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand("config-write", []string{ls.Daemon.Name}, nil),
+			App:     ls.Daemon.App,
+		})
+	}
+
+	// Store the data in the existing recipe.
+	recipe, err := config.GetRecipeForUpdate[ConfigRecipe](ctx, 0)
+	if err != nil {
+		return ctx, err
+	}
+	recipe.SubnetID = &subnet.ID
+	recipe.Commands = commands
+	return config.SetRecipeForUpdate(ctx, 0, recipe)
+}
+
+// Delete the subnet from the Kea servers and from the Stork database.
+func (module *ConfigModule) commitSubnetDelete(ctx context.Context) (context.Context, error) {
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	var err error
+	ctx, err = module.commitChanges(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	for _, update := range state.Updates {
+		if update.Recipe.SubnetID == nil {
+			return ctx, pkgerrors.New("server logic error: the subnet ID cannot be nil when committing subnet deletion")
+		}
+		err = dbmodel.DeleteSubnet(module.manager.GetDB(), *update.Recipe.SubnetID)
+		if err != nil {
+			return ctx, pkgerrors.WithMessagef(err, "subnet has been successfully deleted in Kea but deleting it from the Stork database failed")
+		}
+		module.addPendingConfigChanges(ctx, update)
+	}
+	return ctx, nil
+}
+
+// Begins adding a new shared network. It initializes transaction state.
+func (module *ConfigModule) BeginSharedNetworkAdd(ctx context.Context) (context.Context, error) {
+	// Create transaction state.
+	state := config.NewTransactionStateWithUpdate[ConfigRecipe]("kea", "shared_network_add")
+	ctx = context.WithValue(ctx, config.StateContextKey, *state)
+	return ctx, nil
+}
+
+// Fetches the subnets belonging to the shared network from the database and
+// replaces the subnets held in the network with the fetched ones. Only the
+// subnet IDs are required in the specified network. The subnets must have
+// the same family as the network and must not be served by the daemons
+// that don't serve the network.
+func (module *ConfigModule) populateSharedNetworkSubnets(network *dbmodel.SharedNetwork) error {
+	subnets := []dbmodel.Subnet{}
+	for _, s := range network.Subnets {
+		subnet, err := dbmodel.GetSubnet(module.manager.GetDB(), s.ID)
+		if err != nil {
+			return err
+		}
+		if subnet == nil {
+			return pkgerrors.WithStack(config.NewSubnetNotFoundError(s.ID))
+		}
+		if subnet.GetFamily() != network.Family {
+			return pkgerrors.Errorf("subnet %s cannot belong to the IPv%d shared network %s",
+				subnet.Prefix, network.Family, network.Name)
+		}
+		for _, ls := range subnet.LocalSubnets {
+			if network.GetLocalSharedNetwork(ls.DaemonID) == nil {
+				return pkgerrors.Errorf("subnet %s is served by the daemon %d not serving the shared network %s",
+					subnet.Prefix, ls.DaemonID, network.Name)
+			}
+		}
+		subnets = append(subnets, *subnet)
+	}
+	network.Subnets = subnets
+	return nil
+}
+
+// Prepares the commands creating the shared network in the Kea servers and
+// adding the subnets to this network. The subnets belonging to other shared
+// networks are first removed from these networks. If the oldName is not
+// empty, the shared network with this name is deleted from the servers
+// before creating the new one, but its subnets are kept. Kea has no command
+// updating a shared network, so the network must be re-created to apply
+// the new parameters.
+func (module *ConfigModule) createSharedNetworkCommands(network *dbmodel.SharedNetwork, oldName string) ([]ConfigCommand, error) {
+	for _, lsn := range network.LocalSharedNetworks {
+		if lsn.Daemon == nil {
+			return nil, pkgerrors.Errorf("applied shared network %s is associated with nil daemon", network.Name)
+		}
+		if lsn.Daemon.App == nil {
+			return nil, pkgerrors.Errorf("applied shared network %s is associated with nil app", network.Name)
+		}
+	}
+	commandPrefix := "network6"
+	if network.Family == 4 {
+		commandPrefix = "network4"
+	}
+	var commands []ConfigCommand
+
+	// Remove the subnets moved from other shared networks from these networks.
+	for _, subnet := range network.Subnets {
+		if subnet.SharedNetworkID == 0 || subnet.SharedNetworkID == network.ID || subnet.SharedNetwork == nil {
+			continue
+		}
+		for _, ls := range subnet.LocalSubnets {
+			arguments := map[string]any{
+				"id":   ls.LocalSubnetID,
+				"name": subnet.SharedNetwork.Name,
+			}
+			lsn := network.GetLocalSharedNetwork(ls.DaemonID)
+			commands = append(commands, ConfigCommand{
+				Command: keactrl.NewCommand(commandPrefix+"-subnet-del", []string{lsn.Daemon.Name}, arguments),
+				App:     lsn.Daemon.App,
+			})
+		}
+	}
+
+	// Delete the existing shared network but keep its subnets.
+	if oldName != "" {
+		for _, lsn := range network.LocalSharedNetworks {
+			arguments := map[string]any{
+				"name":           oldName,
+				"subnets-action": "keep",
+			}
+			commands = append(commands, ConfigCommand{
+				Command: keactrl.NewCommand(commandPrefix+"-del", []string{lsn.Daemon.Name}, arguments),
+				App:     lsn.Daemon.App,
+			})
+		}
+	}
+
+	// Create the shared network with the new parameters.
+	lookup := module.manager.GetDHCPOptionDefinitionLookup()
+	for _, lsn := range network.LocalSharedNetworks {
+		arguments := make(map[string]any)
+		switch network.Family {
+		case 4:
+			sharedNetwork4, err := keaconfig.CreateSharedNetwork4(lsn.DaemonID, lookup, network)
+			if err != nil {
+				return nil, err
+			}
+			arguments["shared-networks"] = []*keaconfig.SharedNetwork4{
+				sharedNetwork4,
+			}
+		default:
+			sharedNetwork6, err := keaconfig.CreateSharedNetwork6(lsn.DaemonID, lookup, network)
+			if err != nil {
+				return nil, err
+			}
+			arguments["shared-networks"] = []*keaconfig.SharedNetwork6{
+				sharedNetwork6,
+			}
+		}
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand(commandPrefix+"-add", []string{lsn.Daemon.Name}, arguments),
+			App:     lsn.Daemon.App,
+		})
+	}
+
+	// Add the subnets to the shared network.
+	for _, subnet := range network.Subnets {
+		for _, ls := range subnet.LocalSubnets {
+			arguments := map[string]any{
+				"id":   ls.LocalSubnetID,
+				"name": network.Name,
+			}
+			lsn := network.GetLocalSharedNetwork(ls.DaemonID)
+			commands = append(commands, ConfigCommand{
+				Command: keactrl.NewCommand(commandPrefix+"-subnet-add", []string{lsn.Daemon.Name}, arguments),
+				App:     lsn.Daemon.App,
+			})
+		}
+	}
+
+	// Create the commands to write the updated configuration to files. The
+	// shared network changes won't persist across the servers' restarts
+	// otherwise.
+	for _, lsn := range network.LocalSharedNetworks {
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand("config-write", []string{lsn.Daemon.Name}, nil),
+			App:     lsn.Daemon.App,
+		})
+	}
+	return commands, nil
+}
+
+// Returns an error when a shared network with the specified name and family
+// already exists in the database.
+func (module *ConfigModule) checkSharedNetworkNameUnique(name string, family int) error {
+	existingNetworks, err := dbmodel.GetAllSharedNetworks(module.manager.GetDB(), family)
+	if err != nil {
+		return err
+	}
+	for _, existingNetwork := range existingNetworks {
+		if existingNetwork.Name == name {
+			return pkgerrors.Errorf("shared network %s already exists", name)
+		}
+	}
+	return nil
+}
+
+// Applies new shared network. It prepares necessary commands to be sent to
+// Kea upon commit. The subnets specified in the shared network are moved to
+// this network. It locks the daemons' configurations.
+func (module *ConfigModule) ApplySharedNetworkAdd(ctx context.Context, network *dbmodel.SharedNetwork) (context.Context, error) {
+	if len(network.LocalSharedNetworks) == 0 {
+		return ctx, pkgerrors.Errorf("applied shared network %s is not associated with any daemon", network.Name)
+	}
+	if network.Name == "" {
+		return ctx, pkgerrors.New("applied shared network has no name")
+	}
+	if network.Family != 4 && network.Family != 6 {
+		return ctx, pkgerrors.Errorf("applied shared network %s has invalid family %d", network.Name, network.Family)
+	}
+	err := module.checkSharedNetworkNameUnique(network.Name, network.Family)
+	if err != nil {
+		return ctx, err
+	}
+	var daemonIDs []int64
+	for _, lsn := range network.LocalSharedNetworks {
+		daemonIDs = append(daemonIDs, lsn.DaemonID)
+	}
+	ctx, err = module.manager.Lock(ctx, daemonIDs...)
+	if err != nil {
+		return ctx, pkgerrors.WithStack(config.NewLockError())
+	}
+	if err = module.populateSharedNetworkSubnets(network); err != nil {
+		return ctx, err
+	}
+	commands, err := module.createSharedNetworkCommands(network, "")
+	if err != nil {
+		return ctx, err
+	}
+	recipe := &ConfigRecipe{
+		SharedNetworkConfigRecipeParams: SharedNetworkConfigRecipeParams{
+			SharedNetworkAfterUpdate: network,
+		},
+		Commands: commands,
+	}
+	return config.SetRecipeForUpdate(ctx, 0, recipe)
+}
+
+// Stores the added or updated shared network, its local shared networks and
+// the associations with the subnets in the database.
+func (module *ConfigModule) commitSharedNetworkIntoDB(network *dbmodel.SharedNetwork) error {
+	var subnetIDs []int64
+	for _, subnet := range network.Subnets {
+		subnetIDs = append(subnetIDs, subnet.ID)
+	}
+	return module.manager.GetDB().RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		// The subnets already exist in the database. Their associations
+		// with the network are set below.
+		sharedNetwork := *network
+		sharedNetwork.Subnets = nil
+		var err error
+		if sharedNetwork.ID == 0 {
+			err = dbmodel.AddSharedNetwork(tx, &sharedNetwork)
+		} else {
+			err = dbmodel.UpdateSharedNetwork(tx, &sharedNetwork)
+		}
+		if err != nil {
+			return err
+		}
+		network.ID = sharedNetwork.ID
+		if err = dbmodel.AddLocalSharedNetworks(tx, &sharedNetwork); err != nil {
+			return err
+		}
+		return dbmodel.SetSharedNetworkSubnets(tx, sharedNetwork.ID, subnetIDs)
+	})
+}
+
+// Create the shared network in the Kea servers and add it to the Stork database.
+func (module *ConfigModule) commitSharedNetworkAdd(ctx context.Context) (context.Context, error) {
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	var err error
+	ctx, err = module.commitChanges(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	for _, update := range state.Updates {
+		if update.Recipe.SharedNetworkAfterUpdate == nil {
+			return ctx, pkgerrors.New("server logic error: the update.Recipe.SharedNetworkAfterUpdate cannot be nil when committing the shared network creation")
+		}
+		if err = module.commitSharedNetworkIntoDB(update.Recipe.SharedNetworkAfterUpdate); err != nil {
+			return ctx, pkgerrors.WithMessagef(err, "shared network has been successfully added to Kea but adding it to the Stork database failed")
+		}
+		module.addPendingConfigChanges(ctx, update)
+	}
+	return ctx, nil
+}
+
+// Fetches the specified shared network from the database and locks the
+// daemons serving it for updates. It is called at the beginning of the
+// shared network update and deletion.
+func (module *ConfigModule) beginSharedNetworkTransaction(ctx context.Context, sharedNetworkID int64, operation string) (context.Context, error) {
+	// Try to get the shared network from the database.
+	network, err := dbmodel.GetSharedNetwork(module.manager.GetDB(), sharedNetworkID)
+	if err != nil {
+		// Internal database error.
+		return ctx, err
+	}
+	// Shared network does not exist.
+	if network == nil {
+		return ctx, pkgerrors.WithStack(config.NewSharedNetworkNotFoundError(sharedNetworkID))
+	}
+	// Get the list of daemons whose configurations must be locked for
+	// updates.
+	var daemonIDs []int64
+	for _, lsn := range network.LocalSharedNetworks {
+		daemonIDs = append(daemonIDs, lsn.DaemonID)
+	}
+	// Try to lock configurations.
+	ctx, err = module.manager.Lock(ctx, daemonIDs...)
+	if err != nil {
+		return ctx, pkgerrors.WithStack(config.NewLockError())
+	}
+	// Create transaction state.
+	state := config.NewTransactionStateWithUpdate[ConfigRecipe]("kea", operation, daemonIDs...)
+	recipe := &ConfigRecipe{
+		SharedNetworkConfigRecipeParams: SharedNetworkConfigRecipeParams{
+			SharedNetworkBeforeUpdate: network,
+		},
+	}
+	if err := state.SetRecipeForUpdate(0, recipe); err != nil {
+		return ctx, err
+	}
+	ctx = context.WithValue(ctx, config.StateContextKey, *state)
+	return ctx, nil
+}
+
+// Begins a shared network update. It fetches the specified shared network
+// from the database and stores it in the context state. Then, it locks the
+// daemons associated with the shared network for updates.
+func (module *ConfigModule) BeginSharedNetworkUpdate(ctx context.Context, sharedNetworkID int64) (context.Context, error) {
+	return module.beginSharedNetworkTransaction(ctx, sharedNetworkID, "shared_network_update")
+}
+
+// Applies updated shared network. It prepares necessary commands to be sent
+// to Kea upon commit. The shared network is re-created in the servers with
+// the new parameters, options and subnets. The subnets that no longer belong
+// to the network become top-level subnets. The network can only be updated
+// in the daemons already serving it.
+func (module *ConfigModule) ApplySharedNetworkUpdate(ctx context.Context, network *dbmodel.SharedNetwork) (context.Context, error) {
+	if len(network.LocalSharedNetworks) == 0 {
+		return ctx, pkgerrors.Errorf("applied shared network %d is not associated with any daemon", network.ID)
+	}
+	recipe, err := config.GetRecipeForUpdate[ConfigRecipe](ctx, 0)
+	if err != nil {
+		return ctx, err
+	}
+	existingNetwork := recipe.SharedNetworkBeforeUpdate
+	if existingNetwork == nil {
+		return ctx, pkgerrors.New("server logic error: the recipe lacks the shared network before update")
+	}
+	// The network must be updated in all daemons serving it. Otherwise, the
+	// daemons would end up with different subnets in the network.
+	for _, lsn := range network.LocalSharedNetworks {
+		if existingNetwork.GetLocalSharedNetwork(lsn.DaemonID) == nil {
+			return ctx, pkgerrors.Errorf("shared network %s is not served by the daemon %d", existingNetwork.Name, lsn.DaemonID)
+		}
+	}
+	for _, lsn := range existingNetwork.LocalSharedNetworks {
+		if network.GetLocalSharedNetwork(lsn.DaemonID) == nil {
+			return ctx, pkgerrors.Errorf("applied shared network %s lacks the daemon %d", existingNetwork.Name, lsn.DaemonID)
+		}
+	}
+	// Preserve the statistics and other data not edited by the user.
+	updatedNetwork := *existingNetwork
+	if network.Name != "" && network.Name != existingNetwork.Name {
+		if err = module.checkSharedNetworkNameUnique(network.Name, existingNetwork.Family); err != nil {
+			return ctx, err
+		}
+		updatedNetwork.Name = network.Name
+	}
+	updatedNetwork.LocalSharedNetworks = network.LocalSharedNetworks
+	updatedNetwork.Subnets = network.Subnets
+	network = &updatedNetwork
+	if err = module.populateSharedNetworkSubnets(network); err != nil {
+		return ctx, err
+	}
+	commands, err := module.createSharedNetworkCommands(network, existingNetwork.Name)
+	if err != nil {
+		return ctx, err
+	}
+	// Store the data in the existing recipe.
+	recipe.SharedNetworkAfterUpdate = network
+	recipe.Commands = commands
+	return config.SetRecipeForUpdate(ctx, 0, recipe)
+}
+
+// Create the updated shared network in the Kea servers and update it in the
+// Stork database.
+func (module *ConfigModule) commitSharedNetworkUpdate(ctx context.Context) (context.Context, error) {
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	var err error
+	ctx, err = module.commitChanges(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	for _, update := range state.Updates {
+		if update.Recipe.SharedNetworkAfterUpdate == nil {
+			return ctx, pkgerrors.New("server logic error: the update.Recipe.SharedNetworkAfterUpdate cannot be nil when committing the shared network update")
+		}
+		if err = module.commitSharedNetworkIntoDB(update.Recipe.SharedNetworkAfterUpdate); err != nil {
+			return ctx, pkgerrors.WithMessagef(err, "shared network has been successfully updated in Kea but updating it in the Stork database failed")
+		}
+		module.addPendingConfigChanges(ctx, update)
+	}
+	return ctx, nil
+}
+
+// Begins deleting a shared network. It fetches the specified shared network
+// from the database and stores it in the context state. Then, it locks the
+// daemons associated with the shared network for updates.
+func (module *ConfigModule) BeginSharedNetworkDelete(ctx context.Context, sharedNetworkID int64) (context.Context, error) {
+	return module.beginSharedNetworkTransaction(ctx, sharedNetworkID, "shared_network_delete")
+}
+
+// Creates requests to delete a shared network. It prepares necessary commands
+// to be sent to Kea upon commit. The subnets belonging to the deleted network
+// are kept and become top-level subnets.
+func (module *ConfigModule) ApplySharedNetworkDelete(ctx context.Context, network *dbmodel.SharedNetwork) (context.Context, error) {
+	if len(network.LocalSharedNetworks) == 0 {
+		return ctx, pkgerrors.Errorf("deleted shared network %d is not associated with any daemon", network.ID)
+	}
+	commandName := "network6-del"
+	if network.Family == 4 {
+		commandName = "network4-del"
+	}
+	var commands []ConfigCommand
+	for _, lsn := range network.LocalSharedNetworks {
+		if lsn.Daemon == nil {
+			return ctx, pkgerrors.Errorf("deleted shared network %d is associated with nil daemon", network.ID)
+		}
+		if lsn.Daemon.App == nil {
+			return ctx, pkgerrors.Errorf("deleted shared network %d is associated with nil app", network.ID)
+		}
+		arguments := map[string]any{
+			"name":           network.Name,
+			"subnets-action": "keep",
+		}
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand(commandName, []string{lsn.Daemon.Name}, arguments),
+			App:     lsn.Daemon.App,
+		})
+	}
+
+	// Create the commands to write the updated configuration to files. The
+	// deleted shared network would be restored after the servers' restarts
+	// otherwise.
+	for _, lsn := range network.LocalSharedNetworks {
+		commands = append(commands, ConfigCommand{
+			Command: keactrl.NewCommand("config-write", []string{lsn.Daemon.Name}, nil),
+			App:     lsn.Daemon.App,
+		})
+	}
+
+	// Store the data in the existing recipe.
+	recipe, err := config.GetRecipeForUpdate[ConfigRecipe](ctx, 0)
+	if err != nil {
+		return ctx, err
+	}
+	recipe.SharedNetworkID = &network.ID
+	recipe.Commands = commands
+	return config.SetRecipeForUpdate(ctx, 0, recipe)
+}
+
+// Delete the shared network from the Kea servers and from the Stork database.
+// The subnets of the deleted network remain in the database as top-level
+// subnets.
+func (module *ConfigModule) commitSharedNetworkDelete(ctx context.Context) (context.Context, error) {
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	var err error
+	ctx, err = module.commitChanges(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	for _, update := range state.Updates {
+		if update.Recipe.SharedNetworkID == nil {
+			return ctx, pkgerrors.New("server logic error: the shared network ID cannot be nil when committing shared network deletion")
+		}
+		err = dbmodel.DeleteSharedNetwork(module.manager.GetDB(), *update.Recipe.SharedNetworkID)
+		if err != nil {
+			return ctx, pkgerrors.WithMessagef(err, "shared network has been successfully deleted in Kea but deleting it from the Stork database failed")
+		}
+		module.addPendingConfigChanges(ctx, update)
+	}
+	return ctx, nil
+}
+
+// Begins an update of the global parameters of the specified DHCP daemons.
+// It verifies that the daemons exist and that their configurations can be
+// changed with the config-set command. The daemons fetching their
+// configurations from the config backend are rejected because the global
+// parameters stored in the backend override the parameters set locally.
+// Then, it locks the daemons' configurations for updates.
+func (module *ConfigModule) BeginGlobalParametersUpdate(ctx context.Context, daemonIDs ...int64) (context.Context, error) {
+	if len(daemonIDs) == 0 {
+		return ctx, pkgerrors.New("no daemons specified for the global parameters update")
+	}
+	for _, daemonID := range daemonIDs {
+		daemon, err := module.getDHCPDaemonForConfigUpdate(daemonID)
+		if err != nil {
+			return ctx, err
+		}
+		if daemon.KeaDaemon.Config.UsesConfigBackend() {
+			return ctx, pkgerrors.WithStack(config.NewConfigBackendError(daemonID))
+		}
+	}
+	// Try to lock configurations.
+	ctx, err := module.manager.Lock(ctx, daemonIDs...)
+	if err != nil {
+		return ctx, pkgerrors.WithStack(config.NewLockError())
+	}
+	// Create transaction state.
+	state := config.NewTransactionStateWithUpdate[ConfigRecipe]("kea", "global_parameters_update", daemonIDs...)
+	ctx = context.WithValue(ctx, config.StateContextKey, *state)
+	return ctx, nil
+}
+
+// Fetches the daemon from the database and checks that it is a DHCP
+// daemon with a known configuration.
+func (module *ConfigModule) getDHCPDaemonForConfigUpdate(daemonID int64) (*dbmodel.Daemon, error) {
+	daemon, err := dbmodel.GetDaemonByID(module.manager.GetDB(), daemonID)
+	if err != nil {
+		// Internal database error.
+		return nil, err
+	}
+	// Daemon does not exist.
+	if daemon == nil {
+		return nil, pkgerrors.WithStack(config.NewDaemonNotFoundError(daemonID))
+	}
+	if daemon.Name != dbmodel.DaemonNameDHCPv4 && daemon.Name != dbmodel.DaemonNameDHCPv6 {
+		return nil, pkgerrors.Errorf("configuration can only be updated for the DHCP daemons, daemon %d is %s", daemonID, daemon.Name)
+	}
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return nil, pkgerrors.Errorf("configuration of the daemon %d is not available", daemonID)
+	}
+	return daemon, nil
+}
+
+// Sends a command to the daemon and returns its response. It returns an
+// error if the communication with the daemon fails or the response is empty.
+// It doesn't check the result returned in the response.
+func (module *ConfigModule) sendDaemonCommand(daemon *dbmodel.Daemon, command *keactrl.Command) (*keactrl.Response, error) {
+	var response keactrl.ResponseList
+	result, err := module.manager.GetConnectedAgents().ForwardToKeaOverHTTP(context.Background(), daemon.App, []keactrl.SerializableCommand{command}, &response)
+	if err == nil {
+		err = result.GetFirstError()
+	}
+	if err != nil {
+		return nil, pkgerrors.WithMessagef(err, "%s command to %s failed", command.GetCommand(), daemon.App.GetName())
+	}
+	if len(response) == 0 {
+		return nil, pkgerrors.Errorf("empty response to the %s command from %s", command.GetCommand(), daemon.App.GetName())
+	}
+	return &response[0], nil
+}
+
+// Fetches the current configuration from the daemon with the config-get
+// command.
+func (module *ConfigModule) fetchDaemonConfig(daemon *dbmodel.Daemon) (*keaconfig.Config, error) {
+	response, err := module.sendDaemonCommand(daemon, keactrl.NewCommand("config-get", []string{daemon.Name}, nil))
+	if err != nil {
+		return nil, err
+	}
+	if err = keactrl.GetResponseError(response); err != nil {
+		return nil, pkgerrors.WithMessagef(err, "config-get command to %s failed", daemon.App.GetName())
+	}
+	if response.Arguments == nil {
+		return nil, pkgerrors.Errorf("config-get response from %s lacks the configuration", daemon.App.GetName())
+	}
+	cfg := keaconfig.NewConfigFromMap(response.Arguments)
+	if cfg == nil {
+		return nil, pkgerrors.Errorf("cannot parse the configuration returned by %s", daemon.App.GetName())
+	}
+	return cfg, nil
+}
+
+// Sends the configuration to the daemon in the config-test command. It
+// returns the ConfigTestError if the daemon rejects the configuration.
+func (module *ConfigModule) testDaemonConfig(daemon *dbmodel.Daemon, cfg keaconfig.RawConfig) error {
+	response, err := module.sendDaemonCommand(daemon, keactrl.NewCommand("config-test", []string{daemon.Name}, cfg))
+	if err != nil {
+		return err
+	}
+	if response.Result != keactrl.ResponseSuccess {
+		return pkgerrors.WithStack(config.NewConfigTestError(daemon.ID, daemon.Name, daemon.App.GetName(), response.Text))
+	}
+	return nil
+}
+
+// Merges the global parameters into the current configurations of the
+// specified daemons and creates the recipe with the commands setting the
+// merged configurations. The configurations are fetched from the daemons
+// with the config-get command because the configurations stored in the
+// database may be stale, e.g., when the subnets have been changed with the
+// subnet_cmds hooks library or when the configuration files have been
+// edited since the last configuration pull. Setting the stale configuration
+// would revert these changes. The merged configurations are checked with
+// the config-test command before creating the commands.
+func (module *ConfigModule) createGlobalParametersRecipe(daemonIDs []int64, parameters keaconfig.RawConfig) (*ConfigRecipe, error) {
+	recipe := &ConfigRecipe{
+		GlobalParametersConfigRecipeParams: GlobalParametersConfigRecipeParams{
+			GlobalParameters: parameters,
+		},
+	}
+	var daemons []*dbmodel.Daemon
+	for _, daemonID := range daemonIDs {
+		daemon, err := module.getDHCPDaemonForConfigUpdate(daemonID)
+		if err != nil {
+			return nil, err
+		}
+		cfg, err := module.fetchDaemonConfig(daemon)
+		if err != nil {
+			return nil, err
+		}
+		merged, err := cfg.MergeDHCPParameters(parameters)
+		if err != nil {
+			return nil, err
+		}
+		if err = module.testDaemonConfig(daemon, merged); err != nil {
+			return nil, err
+		}
+		recipe.Commands = append(recipe.Commands, ConfigCommand{
+			Command: keactrl.NewCommand("config-set", []string{daemon.Name}, merged),
+			App:     daemon.App,
+		})
+		daemons = append(daemons, daemon)
+	}
+	// Create the commands to write the updated configuration to files. The
+	// new parameters won't persist across the servers' restarts otherwise.
+	for _, daemon := range daemons {
+		recipe.Commands = append(recipe.Commands, ConfigCommand{
+			Command: keactrl.NewCommand("config-write", []string{daemon.Name}, nil),
+			App:     daemon.App,
+		})
+	}
+	return recipe, nil
+}
+
+// Applies the updated global parameters. The parameters are specified as
+// a partial configuration at the server level (e.g., valid-lifetime and
+// option-data), and they are merged into the current configurations of the
+// daemons selected in the BeginGlobalParametersUpdate. The merged
+// configurations are checked by the daemons with the config-test command.
+// The ConfigTestError is returned when any of the daemons rejects its
+// configuration. The subnets and shared networks can't be updated this way.
+// They are updated in the dedicated transactions.
+func (module *ConfigModule) ApplyGlobalParametersUpdate(ctx context.Context, parameters keaconfig.RawConfig) (context.Context, error) {
+	if len(parameters) == 0 {
+		return ctx, pkgerrors.New("no global parameters specified for the update")
+	}
+	for _, name := range []string{"subnet4", "subnet6", "shared-networks"} {
+		if _, ok := parameters[name]; ok {
+			return ctx, pkgerrors.Errorf("%s can't be updated as a global parameter", name)
+		}
+	}
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	recipe, err := module.createGlobalParametersRecipe(state.Updates[0].DaemonIDs, parameters)
+	if err != nil {
+		return ctx, err
+	}
+	return config.SetRecipeForUpdate(ctx, 0, recipe)
+}
+
+// Sets the updated configurations in the Kea servers. Then, it fetches the
+// configurations from the servers and stores them in the Stork database.
+// If the transaction has been scheduled, the daemons'
+// configurations could change before the deadline. In this case, the
+// global parameters are merged into the current configurations again,
+// and the merged configurations are tested before they are set.
+func (module *ConfigModule) commitGlobalParametersUpdate(ctx context.Context) (context.Context, error) {
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	var err error
+	if state.Scheduled {
+		for i, update := range state.Updates {
+			var recipe *ConfigRecipe
+			recipe, err = module.createGlobalParametersRecipe(update.DaemonIDs, update.Recipe.GlobalParameters)
+			if err != nil {
+				return ctx, err
+			}
+			if ctx, err = config.SetRecipeForUpdate(ctx, i, recipe); err != nil {
+				return ctx, err
+			}
+		}
+		state, _ = config.GetTransactionState[ConfigRecipe](ctx)
+	}
+	ctx, err = module.commitChanges(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	for _, update := range state.Updates {
+		module.storeDaemonConfigVersions(ctx, update)
+	}
+	return ctx, nil
+}
+
+// Stores the configuration set in the daemon in the database. It makes the
+// new configuration visible in Stork before the next configuration pull.
+// The configuration is also stored as a new version of the daemon's
+// configuration associated with the user who committed the transaction
+// and with the specified operation.
+func (module *ConfigModule) storeDaemonConfig(ctx context.Context, daemonID int64, cfg keaconfig.RawConfig, operation string) error {
+	daemon, err := dbmodel.GetDaemonByID(module.manager.GetDB(), daemonID)
+	if err != nil {
+		return err
+	}
+	if daemon == nil {
+		return pkgerrors.WithStack(config.NewDaemonNotFoundError(daemonID))
+	}
+	rawCfg := map[string]any(cfg)
+	keaConfig := dbmodel.NewKeaConfig(&rawCfg)
+	if keaConfig == nil {
+		return pkgerrors.Errorf("cannot parse the configuration of the daemon %d", daemonID)
+	}
+	if err = daemon.SetConfig(keaConfig); err != nil {
+		return err
+	}
+	// The user is unknown if the context has been created without it.
+	userID, _ := config.GetValueAsInt64(ctx, config.UserContextKey)
+	version, err := dbmodel.NewKeaDaemonConfigVersion(daemonID, keaConfig, userID, operation)
+	if err != nil {
+		return err
+	}
+	return module.manager.GetDB().RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if err := dbmodel.UpdateDaemon(tx, daemon); err != nil {
+			return err
+		}
+		_, err := dbmodel.AddKeaDaemonConfigVersion(tx, version)
+		return err
+	})
+}
+
+// Returns the IDs of the daemons whose configurations are changed by the
+// update. They include the daemons locked at the beginning of the
+// transaction and the daemons associated with the added or updated subnet
+// or shared network.
+func getUpdatedDaemonIDs(update *config.Update[ConfigRecipe]) []int64 {
+	daemonIDs := append([]int64{}, update.DaemonIDs...)
+	if subnet := update.Recipe.SubnetAfterUpdate; subnet != nil {
+		for _, ls := range subnet.LocalSubnets {
+			daemonIDs = append(daemonIDs, ls.DaemonID)
+		}
+	}
+	if network := update.Recipe.SharedNetworkAfterUpdate; network != nil {
+		for _, lsn := range network.LocalSharedNetworks {
+			daemonIDs = append(daemonIDs, lsn.DaemonID)
+		}
+	}
+	var uniqueIDs []int64
+	present := make(map[int64]bool)
+	for _, id := range daemonIDs {
+		if !present[id] {
+			present[id] = true
+			uniqueIDs = append(uniqueIDs, id)
+		}
+	}
+	return uniqueIDs
+}
+
+// Fetches the configurations of the daemons changed by the committed
+// update and stores them as new versions associated with the user who
+// committed the transaction and with the update's operation. The changes
+// have already been applied in the daemons, so the failures are logged
+// rather than returned. The configurations are stored upon the next
+// configuration pull in this case, but they aren't associated with the
+// user.
+func (module *ConfigModule) storeDaemonConfigVersions(ctx context.Context, update *config.Update[ConfigRecipe]) {
+	for _, daemonID := range getUpdatedDaemonIDs(update) {
+		daemon, err := module.getDHCPDaemonForConfigUpdate(daemonID)
+		if err == nil {
+			var cfg *keaconfig.Config
+			if cfg, err = module.fetchDaemonConfig(daemon); err == nil {
+				err = module.storeDaemonConfig(ctx, daemonID, cfg.GetSettableConfig(), update.Operation)
+			}
+		}
+		if err != nil {
+			log.WithError(err).Warnf("Failed to store the configuration of the daemon %d changed by the %s operation", daemonID, update.Operation)
+		}
+	}
+}
+
+// Records the pending configuration changes of the daemons changed by the
+// committed update. The subnets and shared networks are changed with the
+// subnet_cmds hooks library commands, so Stork doesn't hold the complete
+// configurations after the update. The configurations including the
+// changes are stored as new versions upon the next configuration pull,
+// and they are associated with the user who committed the transaction and
+// with the update's operation. The changes have already been applied in
+// the daemons, so the failures are logged rather than returned.
+func (module *ConfigModule) addPendingConfigChanges(ctx context.Context, update *config.Update[ConfigRecipe]) {
+	// The user is unknown if the context has been created without it.
+	userID, _ := config.GetValueAsInt64(ctx, config.UserContextKey)
+	for _, daemonID := range getUpdatedDaemonIDs(update) {
+		change := &dbmodel.KeaDaemonPendingConfigChange{
+			DaemonID:  daemonID,
+			UserID:    userID,
+			Operation: update.Operation,
+		}
+		if err := dbmodel.AddKeaDaemonPendingConfigChange(module.manager.GetDB(), change); err != nil {
+			log.WithError(err).Warnf("Failed to record the configuration change of the daemon %d made by the %s operation", daemonID, update.Operation)
+		}
+	}
+}
+
+// Begins restoring the selected version of the daemon's configuration.
+// It verifies that the version exists and belongs to a DHCP daemon not
+// using the configuration backend. Then, it locks the daemon's
+// configuration for updates.
+func (module *ConfigModule) BeginConfigRollback(ctx context.Context, versionID int64) (context.Context, error) {
+	version, err := dbmodel.GetKeaDaemonConfigVersion(module.manager.GetDB(), versionID)
+	if err != nil {
+		// Internal database error.
+		return ctx, err
+	}
+	// Version does not exist.
+	if version == nil {
+		return ctx, pkgerrors.WithStack(config.NewConfigVersionNotFoundError(versionID))
+	}
+	daemon, err := module.getDHCPDaemonForConfigUpdate(version.DaemonID)
+	if err != nil {
+		return ctx, err
+	}
+	// Restoring the configuration would not revert the changes stored in
+	// the configuration backend.
+	if daemon.KeaDaemon.Config.UsesConfigBackend() {
+		return ctx, pkgerrors.WithStack(config.NewConfigBackendError(version.DaemonID))
+	}
+	// Try to lock configurations.
+	ctx, err = module.manager.Lock(ctx, version.DaemonID)
+	if err != nil {
+		return ctx, pkgerrors.WithStack(config.NewLockError())
+	}
+	// Create transaction state.
+	state := config.NewTransactionStateWithUpdate[ConfigRecipe]("kea", "config_rollback", version.DaemonID)
+	ctx = context.WithValue(ctx, config.StateContextKey, *state)
+	return ctx, nil
+}
+
+// Applies the restored version of the daemon's configuration. The daemon
+// checks the configuration with the config-test command. The ConfigTestError
+// is returned when the daemon rejects the configuration, e.g., because it
+// refers to the hooks libraries that are no longer installed. Otherwise,
+// it creates the commands setting the configuration and writing it to
+// the configuration file.
+func (module *ConfigModule) ApplyConfigRollback(ctx context.Context, version *dbmodel.KeaDaemonConfigVersion) (context.Context, error) {
+	if version == nil || version.Config == nil {
+		return ctx, pkgerrors.New("configuration version to restore must not be nil")
+	}
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	daemonIDs := state.Updates[0].DaemonIDs
+	if len(daemonIDs) != 1 || daemonIDs[0] != version.DaemonID {
+		return ctx, pkgerrors.Errorf("configuration version %d doesn't belong to the daemon selected for the rollback", version.ID)
+	}
+	daemon, err := module.getDHCPDaemonForConfigUpdate(version.DaemonID)
+	if err != nil {
+		return ctx, err
+	}
+	cfg := version.Config.GetSettableConfig()
+	if cfg == nil {
+		return ctx, pkgerrors.Errorf("configuration version %d holds unsupported configuration", version.ID)
+	}
+	if err = module.testDaemonConfig(daemon, cfg); err != nil {
+		return ctx, err
+	}
+	recipe := &ConfigRecipe{
+		Commands: []ConfigCommand{
+			{
+				Command: keactrl.NewCommand("config-set", []string{daemon.Name}, cfg),
+				App:     daemon.App,
+			},
+			{
+				Command: keactrl.NewCommand("config-write", []string{daemon.Name}, nil),
+				App:     daemon.App,
+			},
+		},
+		ConfigRollbackConfigRecipeParams: ConfigRollbackConfigRecipeParams{
+			ConfigVersion: version,
+		},
+	}
+	return config.SetRecipeForUpdate(ctx, 0, recipe)
+}
+
+// Sets the restored configuration in the Kea server. Then, it fetches the
+// configuration from the server and stores it in the Stork database as a
+// new version of the daemon's configuration.
+func (module *ConfigModule) commitConfigRollback(ctx context.Context) (context.Context, error) {
+	ctx, err := module.commitChanges(ctx)
+	if err != nil {
+		return ctx, err
+	}
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	if !ok {
+		return ctx, pkgerrors.New("context lacks state")
+	}
+	for _, update := range state.Updates {
+		module.storeDaemonConfigVersions(ctx, update)
 	}
 	return ctx, nil
 }
@@ -2060,3 +2060,1607 @@ func TestCommitSubnetUpdateResponseWithErrorStatus(t *testing.T) {
 	// Other commands should not be sent in this case.
 	require.Len(t, agents.RecordedCommands, 1)
 }
+
+// Adds two Kea servers with the same configuration to the database and
+// returns their apps.
+func addTestSubnetServers(t *testing.T, db *pg.DB, serverConfig string) []dbmodel.App {
+	for i := 0; i < 2; i++ {
+		server, err := dbmodeltest.NewKeaDHCPv4Server(db)
+		require.NoError(t, err)
+		err = server.Configure(serverConfig)
+		require.NoError(t, err)
+
+		app, err := server.GetKea()
+		require.NoError(t, err)
+
+		err = CommitAppIntoDB(db, app, &storktest.FakeEventCenter{}, nil, dbmodel.NewDHCPOptionDefinitionLookup())
+		require.NoError(t, err)
+	}
+	apps, err := dbmodel.GetAllApps(db, true)
+	require.NoError(t, err)
+	require.Len(t, apps, 2)
+	return apps
+}
+
+// Test first stage of adding a new subnet.
+func TestBeginSubnetAdd(t *testing.T) {
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	ctx, err := module.BeginSubnetAdd(context.Background())
+	require.NoError(t, err)
+
+	// There should be no locks on any daemons.
+	require.Empty(t, manager.locks)
+
+	// Make sure that the transaction state has been created.
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	require.Len(t, state.Updates, 1)
+	require.Equal(t, datamodel.AppTypeKea, state.Updates[0].Target)
+	require.Equal(t, "subnet_add", state.Updates[0].Operation)
+}
+
+// Test that the new subnet is sent to the Kea servers with the same ID and
+// is added to the database.
+func TestCommitSubnetAdd(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, `{
+		"Dhcp4": {
+			"shared-networks": [
+				{
+					"name": "foo",
+					"subnet4": [
+						{
+							"id": 7,
+							"subnet": "192.0.2.0/24"
+						}
+					]
+				}
+			]
+		}
+	}`)
+
+	existingSubnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.2.0/24")
+	require.NoError(t, err)
+	require.Len(t, existingSubnets, 1)
+	require.NotZero(t, existingSubnets[0].SharedNetworkID)
+
+	ctx, err := module.BeginSubnetAdd(context.Background())
+	require.NoError(t, err)
+
+	// The subnet IDs must be the same in all daemons.
+	subnet := &dbmodel.Subnet{
+		Prefix:          "192.0.3.0/24",
+		SharedNetworkID: existingSubnets[0].SharedNetworkID,
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{
+				DaemonID:      apps[0].Daemons[0].ID,
+				LocalSubnetID: 10,
+			},
+			{
+				DaemonID:      apps[1].Daemons[0].ID,
+				LocalSubnetID: 11,
+			},
+		},
+	}
+	err = subnet.PopulateDaemons(db)
+	require.NoError(t, err)
+	_, err = module.ApplySubnetAdd(ctx, subnet)
+	require.Error(t, err)
+
+	// The subnet with the same prefix already exists.
+	subnet.Prefix = "192.0.2.0/24"
+	subnet.LocalSubnets[1].LocalSubnetID = 10
+	_, err = module.ApplySubnetAdd(ctx, subnet)
+	require.Error(t, err)
+
+	// The subnet ID should be allocated when it is not specified.
+	subnet.Prefix = "192.0.3.0/24"
+	subnet.LocalSubnets[0].LocalSubnetID = 0
+	subnet.LocalSubnets[1].LocalSubnetID = 0
+	ctx, err = module.ApplySubnetAdd(ctx, subnet)
+	require.NoError(t, err)
+	require.EqualValues(t, 8, subnet.LocalSubnets[0].LocalSubnetID)
+	require.EqualValues(t, 8, subnet.LocalSubnets[1].LocalSubnetID)
+
+	// The daemons should be locked until the transaction is done.
+	require.Contains(t, manager.locks, apps[0].Daemons[0].ID)
+	require.Contains(t, manager.locks, apps[1].Daemons[0].ID)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	// The subnet should be added to the servers and to the shared network.
+	// Then, the configurations should be written.
+	require.Len(t, agents.RecordedCommands, 6)
+	require.Len(t, agents.RecordedURLs, 6)
+	require.Equal(t, agents.RecordedURLs[0], agents.RecordedURLs[1])
+	require.Equal(t, agents.RecordedURLs[2], agents.RecordedURLs[3])
+	require.NotEqual(t, agents.RecordedURLs[0], agents.RecordedURLs[2])
+
+	for i, command := range agents.RecordedCommands {
+		marshalled := command.Marshal()
+		switch {
+		case i < 4 && i%2 == 0:
+			require.JSONEq(t,
+				`{
+					"command": "subnet4-add",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"subnet4": [
+							{
+								"id": 8,
+								"subnet": "192.0.3.0/24"
+							}
+						]
+					}
+				}`,
+				marshalled)
+		case i < 4:
+			require.JSONEq(t,
+				`{
+					"command": "network4-subnet-add",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"id": 8,
+						"name": "foo"
+					}
+				}`,
+				marshalled)
+		default:
+			require.JSONEq(t,
+				`{
+					"command": "config-write",
+					"service": [ "dhcp4" ]
+				}`,
+				marshalled)
+		}
+	}
+
+	// Make sure that the subnet has been added to the database.
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.3.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	require.Equal(t, existingSubnets[0].SharedNetworkID, subnets[0].SharedNetworkID)
+	require.Len(t, subnets[0].LocalSubnets, 2)
+	require.EqualValues(t, 8, subnets[0].LocalSubnets[0].LocalSubnetID)
+	require.EqualValues(t, 8, subnets[0].LocalSubnets[1].LocalSubnetID)
+}
+
+// Test that the scheduled subnet gets a new ID when the ID allocated
+// upon scheduling has been taken before the deadline.
+func TestCommitScheduledSubnetAdd(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, `{
+		"Dhcp4": {
+			"subnet4": [
+				{
+					"id": 7,
+					"subnet": "192.0.2.0/24"
+				}
+			]
+		}
+	}`)
+
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), config.UserContextKey, int64(user.ID))
+	ctx, err = module.BeginSubnetAdd(ctx)
+	require.NoError(t, err)
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.3.0/24",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{
+				DaemonID: apps[0].Daemons[0].ID,
+			},
+			{
+				DaemonID: apps[1].Daemons[0].ID,
+			},
+		},
+	}
+	err = subnet.PopulateDaemons(db)
+	require.NoError(t, err)
+	ctx, err = module.ApplySubnetAdd(ctx, subnet)
+	require.NoError(t, err)
+	require.EqualValues(t, 8, subnet.LocalSubnets[0].LocalSubnetID)
+
+	ctx = manager.scheduleAndGetChange(ctx, t)
+
+	// Another subnet takes the allocated ID before the deadline.
+	_, err = dbmodel.CommitNetworksIntoDB(db, []dbmodel.SharedNetwork{}, []dbmodel.Subnet{
+		{
+			Prefix: "192.0.4.0/24",
+			LocalSubnets: []*dbmodel.LocalSubnet{
+				{
+					DaemonID:      apps[0].Daemons[0].ID,
+					LocalSubnetID: 8,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	// The subnet should be sent with the newly allocated ID.
+	require.Len(t, agents.RecordedCommands, 4)
+	for i, command := range agents.RecordedCommands {
+		if i >= 2 {
+			break
+		}
+		require.JSONEq(t,
+			`{
+				"command": "subnet4-add",
+				"service": [ "dhcp4" ],
+				"arguments": {
+					"subnet4": [
+						{
+							"id": 9,
+							"subnet": "192.0.3.0/24"
+						}
+					]
+				}
+			}`,
+			command.Marshal())
+	}
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.3.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	require.Len(t, subnets[0].LocalSubnets, 2)
+	require.EqualValues(t, 9, subnets[0].LocalSubnets[0].LocalSubnetID)
+	require.EqualValues(t, 9, subnets[0].LocalSubnets[1].LocalSubnetID)
+}
+
+// Test that the scheduled subnet with the ID specified by the user is
+// not added when this ID has been taken before the deadline.
+func TestCommitScheduledSubnetAddConflict(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, `{
+		"Dhcp4": {
+			"subnet4": [
+				{
+					"id": 7,
+					"subnet": "192.0.2.0/24"
+				}
+			]
+		}
+	}`)
+
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), config.UserContextKey, int64(user.ID))
+	ctx, err = module.BeginSubnetAdd(ctx)
+	require.NoError(t, err)
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.3.0/24",
+		LocalSubnets: []*dbmodel.LocalSubnet{
+			{
+				DaemonID:      apps[0].Daemons[0].ID,
+				LocalSubnetID: 10,
+			},
+		},
+	}
+	err = subnet.PopulateDaemons(db)
+	require.NoError(t, err)
+	ctx, err = module.ApplySubnetAdd(ctx, subnet)
+	require.NoError(t, err)
+
+	ctx = manager.scheduleAndGetChange(ctx, t)
+
+	_, err = dbmodel.CommitNetworksIntoDB(db, []dbmodel.SharedNetwork{}, []dbmodel.Subnet{
+		{
+			Prefix: "192.0.4.0/24",
+			LocalSubnets: []*dbmodel.LocalSubnet{
+				{
+					DaemonID:      apps[0].Daemons[0].ID,
+					LocalSubnetID: 10,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = module.Commit(ctx)
+	require.ErrorContains(t, err, "subnet ID 10 of the subnet 192.0.3.0/24 is already used")
+	require.Empty(t, agents.RecordedCommands)
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.3.0/24")
+	require.NoError(t, err)
+	require.Empty(t, subnets)
+}
+
+// Test that the subnet is deleted from the Kea servers and from the database.
+func TestCommitSubnetDelete(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, `{
+		"Dhcp4": {
+			"subnet4": [
+				{
+					"id": 3,
+					"subnet": "192.0.2.0/24"
+				}
+			]
+		}
+	}`)
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.2.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+
+	// Non-existing subnet.
+	_, err = module.BeginSubnetDelete(context.Background(), subnets[0].ID+1)
+	var subnetNotFound *config.SubnetNotFoundError
+	require.ErrorAs(t, err, &subnetNotFound)
+
+	ctx, err := module.BeginSubnetDelete(context.Background(), subnets[0].ID)
+	require.NoError(t, err)
+
+	// The daemons serving the subnet should be locked.
+	require.Contains(t, manager.locks, apps[0].Daemons[0].ID)
+	require.Contains(t, manager.locks, apps[1].Daemons[0].ID)
+
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	require.Len(t, state.Updates, 1)
+	require.Equal(t, "subnet_delete", state.Updates[0].Operation)
+	subnet := state.Updates[0].Recipe.SubnetBeforeUpdate
+	require.NotNil(t, subnet)
+
+	ctx, err = module.ApplySubnetDelete(ctx, subnet)
+	require.NoError(t, err)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, agents.RecordedCommands, 4)
+	for i, command := range agents.RecordedCommands {
+		marshalled := command.Marshal()
+		switch {
+		case i < 2:
+			require.JSONEq(t,
+				`{
+					"command": "subnet4-del",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"id": 3
+					}
+				}`,
+				marshalled)
+		default:
+			require.JSONEq(t,
+				`{
+					"command": "config-write",
+					"service": [ "dhcp4" ]
+				}`,
+				marshalled)
+		}
+	}
+
+	// Make sure that the subnet has been deleted from the database.
+	deletedSubnet, err := dbmodel.GetSubnet(db, subnets[0].ID)
+	require.NoError(t, err)
+	require.Nil(t, deletedSubnet)
+}
+
+// Test that deleting the subnet records the pending configuration changes
+// of the daemons, and that the configurations pulled from the daemons are
+// stored as new versions associated with the user and the operation.
+func TestCommitSubnetDeletePendingConfigChanges(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, `{
+		"Dhcp4": {
+			"subnet4": [
+				{
+					"id": 3,
+					"subnet": "192.0.2.0/24"
+				}
+			]
+		}
+	}`)
+
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.2.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+
+	ctx := context.WithValue(context.Background(), config.UserContextKey, int64(user.ID))
+	ctx, err = module.BeginSubnetDelete(ctx, subnets[0].ID)
+	require.NoError(t, err)
+
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	ctx, err = module.ApplySubnetDelete(ctx, state.Updates[0].Recipe.SubnetBeforeUpdate)
+	require.NoError(t, err)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	// The configurations should not be fetched after the commit.
+	require.Len(t, agents.RecordedCommands, 4)
+
+	for _, app := range apps {
+		change, err := dbmodel.GetKeaDaemonPendingConfigChange(db, app.Daemons[0].ID)
+		require.NoError(t, err)
+		require.NotNil(t, change)
+		require.EqualValues(t, user.ID, change.UserID)
+		require.Equal(t, "subnet_delete", change.Operation)
+
+		// Simulate pulling the changed configuration from the server.
+		pulled, err := dbmodel.NewKeaConfigFromJSON(`{
+			"Dhcp4": {
+				"subnet4": []
+			}
+		}`)
+		require.NoError(t, err)
+		version, err := dbmodel.NewKeaDaemonConfigVersion(app.Daemons[0].ID, pulled, 0, "")
+		require.NoError(t, err)
+		_, err = dbmodel.AddKeaDaemonConfigVersion(db, version)
+		require.NoError(t, err)
+
+		// The pulled version should be associated with the user and the
+		// operation.
+		versions, err := dbmodel.GetKeaDaemonConfigVersions(db, app.Daemons[0].ID)
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		require.EqualValues(t, user.ID, versions[0].UserID)
+		require.Equal(t, "subnet_delete", versions[0].Operation)
+		require.Zero(t, versions[1].UserID)
+	}
+}
+
+// Configuration of the servers used in the shared network tests.
+const sharedNetworkTestServerConfig = `{
+	"Dhcp4": {
+		"shared-networks": [
+			{
+				"name": "foo",
+				"subnet4": [
+					{
+						"id": 1,
+						"subnet": "192.0.2.0/24"
+					},
+					{
+						"id": 2,
+						"subnet": "192.0.3.0/24"
+					}
+				]
+			}
+		],
+		"subnet4": [
+			{
+				"id": 3,
+				"subnet": "192.0.4.0/24"
+			}
+		]
+	}
+}`
+
+// Returns the ID of the subnet with the specified prefix.
+func getTestSubnetID(t *testing.T, db *pg.DB, prefix string) int64 {
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, prefix)
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	return subnets[0].ID
+}
+
+// Test first stage of adding a new shared network.
+func TestBeginSharedNetworkAdd(t *testing.T) {
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	ctx, err := module.BeginSharedNetworkAdd(context.Background())
+	require.NoError(t, err)
+
+	// There should be no locks on any daemons.
+	require.Empty(t, manager.locks)
+
+	// Make sure that the transaction state has been created.
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	require.Len(t, state.Updates, 1)
+	require.Equal(t, datamodel.AppTypeKea, state.Updates[0].Target)
+	require.Equal(t, "shared_network_add", state.Updates[0].Operation)
+}
+
+// Test that the new shared network is created in the Kea servers, the
+// selected subnets are moved to this network and the network is added
+// to the database.
+func TestCommitSharedNetworkAdd(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, sharedNetworkTestServerConfig)
+
+	movedSubnetID := getTestSubnetID(t, db, "192.0.3.0/24")
+	globalSubnetID := getTestSubnetID(t, db, "192.0.4.0/24")
+
+	ctx, err := module.BeginSharedNetworkAdd(context.Background())
+	require.NoError(t, err)
+
+	network := &dbmodel.SharedNetwork{
+		Name:   "bar",
+		Family: 4,
+		Subnets: []dbmodel.Subnet{
+			{
+				ID: movedSubnetID,
+			},
+			{
+				ID: globalSubnetID,
+			},
+		},
+	}
+	for _, app := range apps {
+		network.LocalSharedNetworks = append(network.LocalSharedNetworks, &dbmodel.LocalSharedNetwork{
+			DaemonID: app.Daemons[0].ID,
+			KeaParameters: &keaconfig.SharedNetworkParameters{
+				ValidLifetimeParameters: keaconfig.ValidLifetimeParameters{
+					ValidLifetime: storkutil.Ptr[int64](3600),
+				},
+			},
+		})
+	}
+	err = network.PopulateDaemons(db)
+	require.NoError(t, err)
+	ctx, err = module.ApplySharedNetworkAdd(ctx, network)
+	require.NoError(t, err)
+
+	// The daemons should be locked.
+	require.Contains(t, manager.locks, apps[0].Daemons[0].ID)
+	require.Contains(t, manager.locks, apps[1].Daemons[0].ID)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, agents.RecordedCommands, 10)
+	for i, command := range agents.RecordedCommands {
+		marshalled := command.Marshal()
+		switch {
+		case i < 2:
+			require.JSONEq(t,
+				`{
+					"command": "network4-subnet-del",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"id": 2,
+						"name": "foo"
+					}
+				}`,
+				marshalled)
+		case i < 4:
+			require.JSONEq(t,
+				`{
+					"command": "network4-add",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"shared-networks": [
+							{
+								"name": "bar",
+								"valid-lifetime": 3600
+							}
+						]
+					}
+				}`,
+				marshalled)
+		case i < 6:
+			require.JSONEq(t,
+				`{
+					"command": "network4-subnet-add",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"id": 2,
+						"name": "bar"
+					}
+				}`,
+				marshalled)
+		case i < 8:
+			require.JSONEq(t,
+				`{
+					"command": "network4-subnet-add",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"id": 3,
+						"name": "bar"
+					}
+				}`,
+				marshalled)
+		default:
+			require.JSONEq(t,
+				`{
+					"command": "config-write",
+					"service": [ "dhcp4" ]
+				}`,
+				marshalled)
+		}
+	}
+
+	// Make sure that the network has been added to the database and the
+	// subnets have been moved to it.
+	networks, err := dbmodel.GetAllSharedNetworks(db, 4)
+	require.NoError(t, err)
+	require.Len(t, networks, 2)
+	require.Equal(t, "bar", networks[1].Name)
+	require.Len(t, networks[1].LocalSharedNetworks, 2)
+	require.NotNil(t, networks[1].LocalSharedNetworks[0].KeaParameters)
+	require.EqualValues(t, 3600, *networks[1].LocalSharedNetworks[0].KeaParameters.ValidLifetime)
+
+	added, err := dbmodel.GetSharedNetwork(db, networks[1].ID)
+	require.NoError(t, err)
+	require.Len(t, added.Subnets, 2)
+
+	existing, err := dbmodel.GetSharedNetwork(db, networks[0].ID)
+	require.NoError(t, err)
+	require.Len(t, existing.Subnets, 1)
+	require.Equal(t, "192.0.2.0/24", existing.Subnets[0].Prefix)
+}
+
+// Test that adding a shared network with a name of an existing network
+// fails.
+func TestApplySharedNetworkAddDuplicate(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agentcommtest.NewKeaFakeAgents(),
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+	module := NewConfigModule(manager)
+
+	apps := addTestSubnetServers(t, db, sharedNetworkTestServerConfig)
+
+	ctx, err := module.BeginSharedNetworkAdd(context.Background())
+	require.NoError(t, err)
+
+	network := &dbmodel.SharedNetwork{
+		Name:   "foo",
+		Family: 4,
+		LocalSharedNetworks: []*dbmodel.LocalSharedNetwork{
+			{
+				DaemonID: apps[0].Daemons[0].ID,
+			},
+		},
+	}
+	_, err = module.ApplySharedNetworkAdd(ctx, network)
+	require.ErrorContains(t, err, "shared network foo already exists")
+	require.Empty(t, manager.locks)
+}
+
+// Test that the shared network is re-created in the Kea servers with the new
+// name, parameters and subnets and is updated in the database.
+func TestCommitSharedNetworkUpdate(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, sharedNetworkTestServerConfig)
+
+	networks, err := dbmodel.GetAllSharedNetworks(db, 4)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+
+	// Non-existing shared network.
+	_, err = module.BeginSharedNetworkUpdate(context.Background(), networks[0].ID+1)
+	var sharedNetworkNotFound *config.SharedNetworkNotFoundError
+	require.ErrorAs(t, err, &sharedNetworkNotFound)
+
+	ctx, err := module.BeginSharedNetworkUpdate(context.Background(), networks[0].ID)
+	require.NoError(t, err)
+
+	// The daemons serving the shared network should be locked.
+	require.Contains(t, manager.locks, apps[0].Daemons[0].ID)
+	require.Contains(t, manager.locks, apps[1].Daemons[0].ID)
+
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	require.Len(t, state.Updates, 1)
+	require.Equal(t, "shared_network_update", state.Updates[0].Operation)
+	require.NotNil(t, state.Updates[0].Recipe.SharedNetworkBeforeUpdate)
+
+	// Rename the network, keep the first subnet in it and remove the
+	// second subnet from it.
+	network := &dbmodel.SharedNetwork{
+		ID:   networks[0].ID,
+		Name: "baz",
+		Subnets: []dbmodel.Subnet{
+			{
+				ID: getTestSubnetID(t, db, "192.0.2.0/24"),
+			},
+		},
+	}
+	for _, app := range apps {
+		network.LocalSharedNetworks = append(network.LocalSharedNetworks, &dbmodel.LocalSharedNetwork{
+			DaemonID: app.Daemons[0].ID,
+			KeaParameters: &keaconfig.SharedNetworkParameters{
+				Interface: storkutil.Ptr("eth0"),
+			},
+		})
+	}
+	err = network.PopulateDaemons(db)
+	require.NoError(t, err)
+	ctx, err = module.ApplySharedNetworkUpdate(ctx, network)
+	require.NoError(t, err)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, agents.RecordedCommands, 8)
+	for i, command := range agents.RecordedCommands {
+		marshalled := command.Marshal()
+		switch {
+		case i < 2:
+			require.JSONEq(t,
+				`{
+					"command": "network4-del",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"name": "foo",
+						"subnets-action": "keep"
+					}
+				}`,
+				marshalled)
+		case i < 4:
+			require.JSONEq(t,
+				`{
+					"command": "network4-add",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"shared-networks": [
+							{
+								"name": "baz",
+								"interface": "eth0"
+							}
+						]
+					}
+				}`,
+				marshalled)
+		case i < 6:
+			require.JSONEq(t,
+				`{
+					"command": "network4-subnet-add",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"id": 1,
+						"name": "baz"
+					}
+				}`,
+				marshalled)
+		default:
+			require.JSONEq(t,
+				`{
+					"command": "config-write",
+					"service": [ "dhcp4" ]
+				}`,
+				marshalled)
+		}
+	}
+
+	// Make sure that the network has been updated in the database.
+	updated, err := dbmodel.GetSharedNetwork(db, networks[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, updated)
+	require.Equal(t, "baz", updated.Name)
+	require.Len(t, updated.Subnets, 1)
+	require.Equal(t, "192.0.2.0/24", updated.Subnets[0].Prefix)
+	require.Len(t, updated.LocalSharedNetworks, 2)
+	require.NotNil(t, updated.LocalSharedNetworks[0].KeaParameters)
+	require.Equal(t, "eth0", *updated.LocalSharedNetworks[0].KeaParameters.Interface)
+
+	// The removed subnet should become a top-level subnet.
+	globalSubnets, err := dbmodel.GetGlobalSubnets(db, 4)
+	require.NoError(t, err)
+	require.Len(t, globalSubnets, 2)
+}
+
+// Test that the shared network must be updated in all daemons serving it.
+func TestApplySharedNetworkUpdateMissingDaemon(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agentcommtest.NewKeaFakeAgents(),
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+	module := NewConfigModule(manager)
+
+	apps := addTestSubnetServers(t, db, sharedNetworkTestServerConfig)
+
+	networks, err := dbmodel.GetAllSharedNetworks(db, 4)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+
+	ctx, err := module.BeginSharedNetworkUpdate(context.Background(), networks[0].ID)
+	require.NoError(t, err)
+
+	network := &dbmodel.SharedNetwork{
+		ID:   networks[0].ID,
+		Name: "foo",
+		LocalSharedNetworks: []*dbmodel.LocalSharedNetwork{
+			{
+				DaemonID: apps[0].Daemons[0].ID,
+			},
+		},
+	}
+	_, err = module.ApplySharedNetworkUpdate(ctx, network)
+	require.ErrorContains(t, err, "lacks the daemon")
+}
+
+// Test that the shared network is deleted from the Kea servers and from the
+// database while its subnets are kept.
+func TestCommitSharedNetworkDelete(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents()
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, sharedNetworkTestServerConfig)
+
+	networks, err := dbmodel.GetAllSharedNetworks(db, 4)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+
+	ctx, err := module.BeginSharedNetworkDelete(context.Background(), networks[0].ID)
+	require.NoError(t, err)
+
+	// The daemons serving the shared network should be locked.
+	require.Contains(t, manager.locks, apps[0].Daemons[0].ID)
+	require.Contains(t, manager.locks, apps[1].Daemons[0].ID)
+
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	require.Len(t, state.Updates, 1)
+	require.Equal(t, "shared_network_delete", state.Updates[0].Operation)
+	network := state.Updates[0].Recipe.SharedNetworkBeforeUpdate
+	require.NotNil(t, network)
+
+	ctx, err = module.ApplySharedNetworkDelete(ctx, network)
+	require.NoError(t, err)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	require.Len(t, agents.RecordedCommands, 4)
+	for i, command := range agents.RecordedCommands {
+		marshalled := command.Marshal()
+		switch {
+		case i < 2:
+			require.JSONEq(t,
+				`{
+					"command": "network4-del",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"name": "foo",
+						"subnets-action": "keep"
+					}
+				}`,
+				marshalled)
+		default:
+			require.JSONEq(t,
+				`{
+					"command": "config-write",
+					"service": [ "dhcp4" ]
+				}`,
+				marshalled)
+		}
+	}
+
+	// Make sure that the network has been deleted from the database and
+	// its subnets have become top-level subnets.
+	deletedNetwork, err := dbmodel.GetSharedNetwork(db, networks[0].ID)
+	require.NoError(t, err)
+	require.Nil(t, deletedNetwork)
+
+	globalSubnets, err := dbmodel.GetGlobalSubnets(db, 4)
+	require.NoError(t, err)
+	require.Len(t, globalSubnets, 3)
+}
+
+// Kea server configuration used in the tests updating global parameters.
+const globalParametersTestServerConfig = `{
+	"Dhcp4": {
+		"valid-lifetime": 1000,
+		"renew-timer": 500,
+		"subnet4": [
+			{
+				"id": 1,
+				"subnet": "192.0.2.0/24"
+			}
+		]
+	}
+}`
+
+// Returns a function generating Kea responses with the specified result,
+// text and arguments to all commands sent to the fake agents.
+func mockGlobalParametersResponse(result int, text, arguments string) func(int, []interface{}) {
+	return func(callNo int, cmdResponses []interface{}) {
+		if arguments == "" {
+			arguments = "{}"
+		}
+		json := []byte(fmt.Sprintf(`[
+			{
+				"result": %d,
+				"text": %q,
+				"arguments": %s
+			}
+		]`, result, text, arguments))
+		command := keactrl.NewCommand("config-test", []string{"dhcp4"}, nil)
+		_ = keactrl.UnmarshalResponseList(command, json, cmdResponses[0])
+	}
+}
+
+// Creates the fake agents simulating the Kea servers with the specified
+// configuration. The config-get command returns the configuration most
+// recently set with the config-set command, or the specified configuration
+// if none has been set. The other commands return the specified result and
+// text.
+func newConfigFakeAgents(serverConfig string, result int, text string) *agentcommtest.FakeAgents {
+	var agents *agentcommtest.FakeAgents
+	agents = agentcommtest.NewKeaFakeAgents(func(callNo int, cmdResponses []interface{}) {
+		command := agents.GetLastCommand()
+		switch command.Command {
+		case "config-get":
+			mockGlobalParametersResponse(0, "", serverConfig)(callNo, cmdResponses)
+			return
+		case "config-set":
+			if marshalled, err := json.Marshal(command.Arguments); err == nil && result == keactrl.ResponseSuccess {
+				serverConfig = string(marshalled)
+			}
+		}
+		mockGlobalParametersResponse(result, text, "")(callNo, cmdResponses)
+	})
+	return agents
+}
+
+// Test that the global parameters are merged into the configurations
+// fetched from the Kea servers, tested in the servers, set with the
+// config-set command and stored in the database.
+func TestCommitGlobalParametersUpdate(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := newConfigFakeAgents(globalParametersTestServerConfig, 0, "Configuration seems sane.")
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, globalParametersTestServerConfig)
+	daemonIDs := []int64{apps[0].Daemons[0].ID, apps[1].Daemons[0].ID}
+
+	ctx, err := module.BeginGlobalParametersUpdate(context.Background(), daemonIDs...)
+	require.NoError(t, err)
+
+	// The updated daemons should be locked.
+	require.Contains(t, manager.locks, daemonIDs[0])
+	require.Contains(t, manager.locks, daemonIDs[1])
+
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	require.Len(t, state.Updates, 1)
+	require.Equal(t, "global_parameters_update", state.Updates[0].Operation)
+	require.ElementsMatch(t, daemonIDs, state.Updates[0].DaemonIDs)
+
+	ctx, err = module.ApplyGlobalParametersUpdate(ctx, keaconfig.RawConfig{
+		"valid-lifetime": 2000,
+		"renew-timer":    nil,
+		"option-data": []any{
+			map[string]any{
+				"name": "domain-name-servers",
+				"data": "192.0.2.2",
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	// The configurations should have been fetched and the merged
+	// configurations should have been tested.
+	require.Len(t, agents.RecordedCommands, 4)
+	for i, command := range agents.RecordedCommands {
+		if i%2 == 0 {
+			require.Equal(t, "config-get", command.GetCommand())
+		} else {
+			require.Equal(t, "config-test", command.GetCommand())
+		}
+	}
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	// The configurations should be fetched after setting them.
+	require.Len(t, agents.RecordedCommands, 10)
+	require.Equal(t, "config-get", agents.RecordedCommands[8].GetCommand())
+	require.Equal(t, "config-get", agents.RecordedCommands[9].GetCommand())
+	for i, command := range agents.RecordedCommands[4:8] {
+		marshalled := command.Marshal()
+		switch {
+		case i < 2:
+			require.JSONEq(t,
+				`{
+					"command": "config-set",
+					"service": [ "dhcp4" ],
+					"arguments": {
+						"Dhcp4": {
+							"valid-lifetime": 2000,
+							"option-data": [
+								{
+									"name": "domain-name-servers",
+									"data": "192.0.2.2"
+								}
+							],
+							"subnet4": [
+								{
+									"id": 1,
+									"subnet": "192.0.2.0/24"
+								}
+							]
+						}
+					}
+				}`,
+				marshalled)
+		default:
+			require.JSONEq(t,
+				`{
+					"command": "config-write",
+					"service": [ "dhcp4" ]
+				}`,
+				marshalled)
+		}
+	}
+
+	// Make sure that the updated configurations have been stored in the
+	// database.
+	for _, daemonID := range daemonIDs {
+		daemon, err := dbmodel.GetDaemonByID(db, daemonID)
+		require.NoError(t, err)
+		require.NotNil(t, daemon)
+		parameters := daemon.KeaDaemon.Config.GetValidLifetimeParameters()
+		require.NotNil(t, parameters.ValidLifetime)
+		require.EqualValues(t, 2000, *parameters.ValidLifetime)
+		require.Nil(t, daemon.KeaDaemon.Config.GetTimerParameters().RenewTimer)
+		require.Len(t, daemon.KeaDaemon.Config.GetDHCPOptions(), 1)
+	}
+}
+
+// Test that the configuration rejected in the config-test command is
+// reported as the ConfigTestError and the configuration is not set.
+func TestApplyGlobalParametersUpdateConfigTestError(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := newConfigFakeAgents(globalParametersTestServerConfig, 1, "unsupported parameter 'foo'")
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, globalParametersTestServerConfig)
+
+	ctx, err := module.BeginGlobalParametersUpdate(context.Background(), apps[0].Daemons[0].ID)
+	require.NoError(t, err)
+
+	_, err = module.ApplyGlobalParametersUpdate(ctx, keaconfig.RawConfig{
+		"foo": "bar",
+	})
+	require.Error(t, err)
+
+	var testErr *config.ConfigTestError
+	require.ErrorAs(t, err, &testErr)
+	require.Equal(t, apps[0].Daemons[0].ID, testErr.DaemonID)
+	require.Equal(t, "dhcp4", testErr.DaemonName)
+	require.Equal(t, apps[0].Name, testErr.AppName)
+	require.Equal(t, "unsupported parameter 'foo'", testErr.Text)
+
+	// The configuration should have been fetched and tested but not set.
+	require.Len(t, agents.RecordedCommands, 2)
+	require.Equal(t, "config-get", agents.RecordedCommands[0].GetCommand())
+	require.Equal(t, "config-test", agents.RecordedCommands[1].GetCommand())
+}
+
+// Test that the subnets and shared networks can't be updated as global
+// parameters.
+func TestApplyGlobalParametersUpdateSubnets(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents(mockGlobalParametersResponse(0, "Configuration seems sane.", ""))
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, globalParametersTestServerConfig)
+
+	ctx, err := module.BeginGlobalParametersUpdate(context.Background(), apps[0].Daemons[0].ID)
+	require.NoError(t, err)
+
+	for _, name := range []string{"subnet4", "subnet6", "shared-networks"} {
+		_, err = module.ApplyGlobalParametersUpdate(ctx, keaconfig.RawConfig{
+			name: []any{},
+		})
+		require.ErrorContains(t, err, name)
+	}
+	require.Empty(t, agents.RecordedCommands)
+}
+
+// Test that the global parameters update can't begin for the daemons
+// using the config backend and for non-existing daemons.
+func TestBeginGlobalParametersUpdateErrors(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agentcommtest.NewKeaFakeAgents(),
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, `{
+		"Dhcp4": {
+			"config-control": {
+				"config-databases": [
+					{
+						"type": "mysql",
+						"name": "kea"
+					}
+				]
+			}
+		}
+	}`)
+
+	_, err := module.BeginGlobalParametersUpdate(context.Background(), apps[0].Daemons[0].ID)
+	var backendErr *config.ConfigBackendError
+	require.ErrorAs(t, err, &backendErr)
+
+	_, err = module.BeginGlobalParametersUpdate(context.Background(), apps[1].Daemons[0].ID+1000)
+	var notFoundErr *config.DaemonNotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+
+	// No daemons should be locked.
+	require.Empty(t, manager.locks)
+}
+
+// Test that the global parameters are merged into the current configuration
+// fetched from the daemon again, and that the merged configuration is tested
+// again, when the scheduled transaction is committed.
+func TestCommitScheduledGlobalParametersUpdate(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	// The servers return the configuration including the subnet added
+	// since the last config pull.
+	agents := newConfigFakeAgents(`{
+		"Dhcp4": {
+			"valid-lifetime": 1000,
+			"subnet4": [
+				{
+					"id": 1,
+					"subnet": "192.0.2.0/24"
+				},
+				{
+					"id": 2,
+					"subnet": "192.0.3.0/24"
+				}
+			]
+		},
+		"hash": "1234"
+	}`, 0, "Configuration seems sane.")
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, globalParametersTestServerConfig)
+
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	ctx := context.WithValue(context.Background(), config.UserContextKey, int64(user.ID))
+	ctx, err = module.BeginGlobalParametersUpdate(ctx, apps[0].Daemons[0].ID)
+	require.NoError(t, err)
+
+	ctx, err = module.ApplyGlobalParametersUpdate(ctx, keaconfig.RawConfig{
+		"valid-lifetime": 2000,
+	})
+	require.NoError(t, err)
+
+	// The configuration should have been fetched and tested.
+	require.Len(t, agents.RecordedCommands, 2)
+	require.Equal(t, "config-get", agents.RecordedCommands[0].GetCommand())
+	require.Equal(t, "config-test", agents.RecordedCommands[1].GetCommand())
+
+	// Simulate scheduling the config change and retrieving it from the database.
+	ctx = manager.scheduleAndGetChange(ctx, t)
+	require.NotNil(t, ctx)
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	// The configuration should have been fetched and tested again before
+	// setting it, and fetched after setting it.
+	require.Len(t, agents.RecordedCommands, 7)
+	require.Equal(t, "config-get", agents.RecordedCommands[2].GetCommand())
+	require.Equal(t, "config-test", agents.RecordedCommands[3].GetCommand())
+	require.JSONEq(t,
+		`{
+			"command": "config-set",
+			"service": [ "dhcp4" ],
+			"arguments": {
+				"Dhcp4": {
+					"valid-lifetime": 2000,
+					"subnet4": [
+						{
+							"id": 1,
+							"subnet": "192.0.2.0/24"
+						},
+						{
+							"id": 2,
+							"subnet": "192.0.3.0/24"
+						}
+					]
+				}
+			}
+		}`,
+		agents.RecordedCommands[4].Marshal())
+	require.Equal(t, "config-write", agents.RecordedCommands[5].GetCommand())
+	require.Equal(t, "config-get", agents.RecordedCommands[6].GetCommand())
+}
+
+// Test that the configuration versions are stored when the global parameters
+// are updated and that the selected version is restored in the Kea server.
+func TestCommitConfigRollback(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := newConfigFakeAgents(globalParametersTestServerConfig, 0, "Configuration seems sane.")
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, globalParametersTestServerConfig)
+	daemonID := apps[0].Daemons[0].ID
+
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	// The configuration fetched from the server is the first version.
+	versions, err := dbmodel.GetKeaDaemonConfigVersions(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	initialVersionID := versions[0].ID
+
+	// Update the global parameters.
+	ctx := context.WithValue(context.Background(), config.UserContextKey, int64(user.ID))
+	ctx, err = module.BeginGlobalParametersUpdate(ctx, daemonID)
+	require.NoError(t, err)
+	ctx, err = module.ApplyGlobalParametersUpdate(ctx, keaconfig.RawConfig{
+		"valid-lifetime": 2000,
+	})
+	require.NoError(t, err)
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+	manager.Unlock(ctx)
+
+	// The updated configuration should be stored as a new version.
+	versions, err = dbmodel.GetKeaDaemonConfigVersions(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+	require.EqualValues(t, user.ID, versions[0].UserID)
+	require.Equal(t, "global_parameters_update", versions[0].Operation)
+
+	// Restore the initial configuration.
+	agents.RecordedCommands = nil
+	ctx = context.WithValue(context.Background(), config.UserContextKey, int64(user.ID))
+	ctx, err = module.BeginConfigRollback(ctx, initialVersionID)
+	require.NoError(t, err)
+
+	// The daemon should be locked.
+	require.Contains(t, manager.locks, daemonID)
+
+	state, ok := config.GetTransactionState[ConfigRecipe](ctx)
+	require.True(t, ok)
+	require.Len(t, state.Updates, 1)
+	require.Equal(t, "config_rollback", state.Updates[0].Operation)
+	require.Equal(t, []int64{daemonID}, state.Updates[0].DaemonIDs)
+
+	version, err := dbmodel.GetKeaDaemonConfigVersion(db, initialVersionID)
+	require.NoError(t, err)
+	require.NotNil(t, version)
+
+	ctx, err = module.ApplyConfigRollback(ctx, version)
+	require.NoError(t, err)
+
+	// The restored configuration should have been tested.
+	require.Len(t, agents.RecordedCommands, 1)
+	require.Equal(t, "config-test", agents.RecordedCommands[0].GetCommand())
+
+	_, err = module.Commit(ctx)
+	require.NoError(t, err)
+
+	// The configuration should be fetched after setting it.
+	require.Len(t, agents.RecordedCommands, 4)
+	require.Equal(t, "config-get", agents.RecordedCommands[3].GetCommand())
+	require.JSONEq(t,
+		`{
+			"command": "config-set",
+			"service": [ "dhcp4" ],
+			"arguments": {
+				"Dhcp4": {
+					"valid-lifetime": 1000,
+					"renew-timer": 500,
+					"subnet4": [
+						{
+							"id": 1,
+							"subnet": "192.0.2.0/24"
+						}
+					]
+				}
+			}
+		}`,
+		agents.RecordedCommands[1].Marshal())
+	require.Equal(t, "config-write", agents.RecordedCommands[2].GetCommand())
+
+	// The restored configuration should be stored in the database.
+	daemon, err := dbmodel.GetDaemonByID(db, daemonID)
+	require.NoError(t, err)
+	require.NotNil(t, daemon)
+	require.EqualValues(t, 1000, *daemon.KeaDaemon.Config.GetValidLifetimeParameters().ValidLifetime)
+
+	// The rollback should be recorded as a new version with the same hash
+	// as the restored version.
+	versions, err = dbmodel.GetKeaDaemonConfigVersions(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.EqualValues(t, user.ID, versions[0].UserID)
+	require.Equal(t, "config_rollback", versions[0].Operation)
+	require.Equal(t, version.ConfigHash, versions[0].ConfigHash)
+}
+
+// Test that the configuration rejected by the server in the config-test
+// command is not restored.
+func TestApplyConfigRollbackConfigTestError(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	agents := agentcommtest.NewKeaFakeAgents(mockGlobalParametersResponse(1, "hooks library not found", ""))
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agents,
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, globalParametersTestServerConfig)
+	daemonID := apps[0].Daemons[0].ID
+
+	versions, err := dbmodel.GetKeaDaemonConfigVersions(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	version, err := dbmodel.GetKeaDaemonConfigVersion(db, versions[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, version)
+
+	ctx, err := module.BeginConfigRollback(context.Background(), version.ID)
+	require.NoError(t, err)
+
+	_, err = module.ApplyConfigRollback(ctx, version)
+	require.Error(t, err)
+
+	var testErr *config.ConfigTestError
+	require.ErrorAs(t, err, &testErr)
+	require.Equal(t, "hooks library not found", testErr.Text)
+
+	// Only the config-test command should have been sent.
+	require.Len(t, agents.RecordedCommands, 1)
+	require.Equal(t, "config-test", agents.RecordedCommands[0].GetCommand())
+}
+
+// Test that the rollback can't begin for a non-existing version.
+func TestBeginConfigRollbackNonExistingVersion(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB: db,
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	_, err := module.BeginConfigRollback(context.Background(), 1234)
+	require.Error(t, err)
+
+	var notFoundErr *config.ConfigVersionNotFoundError
+	require.ErrorAs(t, err, &notFoundErr)
+	require.Empty(t, manager.locks)
+}
+
+// Test that the rollback can't begin for the daemon using the config
+// backend.
+func TestBeginConfigRollbackConfigBackend(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	manager := newTestManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    agentcommtest.NewKeaFakeAgents(),
+		DefLookup: dbmodel.NewDHCPOptionDefinitionLookup(),
+	})
+
+	module := NewConfigModule(manager)
+	require.NotNil(t, module)
+
+	apps := addTestSubnetServers(t, db, `{
+		"Dhcp4": {
+			"config-control": {
+				"config-databases": [
+					{
+						"type": "mysql",
+						"name": "kea"
+					}
+				]
+			}
+		}
+	}`)
+
+	versions, err := dbmodel.GetKeaDaemonConfigVersions(db, apps[0].Daemons[0].ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	_, err = module.BeginConfigRollback(context.Background(), versions[0].ID)
+	var backendErr *config.ConfigBackendError
+	require.ErrorAs(t, err, &backendErr)
+	require.Empty(t, manager.locks)
+}
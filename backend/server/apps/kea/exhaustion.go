@@ -0,0 +1,340 @@
+package kea
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// The time range of the utilization history used to estimate the
+// utilization growth.
+const exhaustionForecastWindow = 24 * time.Hour
+
+// The exhaustion projected further in the future than this is too
+// uncertain to be reported.
+const exhaustionForecastMaxRange = 365 * 24 * time.Hour
+
+// Bounds of the RPS trend applied to the utilization growth. They prevent
+// short bursts or drops of the traffic from dominating the forecast.
+const (
+	minRpsTrend = 0.5
+	maxRpsTrend = 2.0
+)
+
+// A single point of the utilization history.
+type utilizationPoint struct {
+	sampledAt   time.Time
+	utilization float64
+}
+
+// Estimates when the utilization reaches 100% using the linear regression
+// of the utilization history. The growth is scaled by the RPS trend, i.e.,
+// the ratio of the recent RPS to the long-term RPS of the daemons serving
+// the leases. It returns zero time if the utilization doesn't grow or the
+// history is too short to estimate the growth.
+func forecastExhaustion(points []utilizationPoint, rpsTrend float64, now time.Time) time.Time {
+	if len(points) == 0 {
+		return time.Time{}
+	}
+	current := points[len(points)-1].utilization
+	if current >= 1 {
+		return now
+	}
+	if len(points) < 2 {
+		return time.Time{}
+	}
+
+	// Utilization growth per hour.
+	origin := points[0].sampledAt
+	var meanX, meanY float64
+	for _, point := range points {
+		meanX += point.sampledAt.Sub(origin).Hours()
+		meanY += point.utilization
+	}
+	meanX /= float64(len(points))
+	meanY /= float64(len(points))
+
+	var covariance, variance float64
+	for _, point := range points {
+		dx := point.sampledAt.Sub(origin).Hours() - meanX
+		covariance += dx * (point.utilization - meanY)
+		variance += dx * dx
+	}
+	if variance == 0 {
+		return time.Time{}
+	}
+	slope := covariance / variance * math.Max(minRpsTrend, math.Min(maxRpsTrend, rpsTrend))
+	if slope <= 0 {
+		return time.Time{}
+	}
+
+	hours := (1 - current) / slope
+	if hours > exhaustionForecastMaxRange.Hours() {
+		return time.Time{}
+	}
+	return now.Add(time.Duration(hours * float64(time.Hour)))
+}
+
+// Returns the ratio of the RPS in the short interval to the RPS in the
+// long interval summed for the specified daemons. It returns 1 if the RPS
+// in the long interval is unknown.
+func getRpsTrend(daemonIDs map[int64]bool, daemons map[int64]*dbmodel.KeaDHCPDaemonStats) float64 {
+	var rps1, rps2 int
+	for daemonID := range daemonIDs {
+		if stats, ok := daemons[daemonID]; ok {
+			rps1 += stats.RPS1
+			rps2 += stats.RPS2
+		}
+	}
+	if rps2 == 0 {
+		return 1
+	}
+	return float64(rps1) / float64(rps2)
+}
+
+// Utilization history of a single subnet or shared network split into the
+// address and delegated prefix utilization.
+type utilizationHistory struct {
+	addr []utilizationPoint
+	pd   []utilizationPoint
+}
+
+// Adds a sample to the history.
+func (h *utilizationHistory) add(sample *dbmodel.UtilizationSample) {
+	h.addr = append(h.addr, utilizationPoint{sample.SampledAt, float64(sample.AddrUtilization) / 1000})
+	h.pd = append(h.pd, utilizationPoint{sample.SampledAt, float64(sample.PdUtilization) / 1000})
+}
+
+// Checks if the projected exhaustion time falls within the horizon and it
+// wasn't within the horizon before. The warning is raised only when the
+// projection enters the horizon to avoid repeating it in each pull.
+func isExhaustionEnteringHorizon(previous, current time.Time, horizon time.Duration, now time.Time) bool {
+	if horizon <= 0 || current.IsZero() || current.Sub(now) > horizon {
+		return false
+	}
+	return previous.IsZero() || previous.Sub(now) > horizon
+}
+
+// Formats the projected exhaustion time in the events.
+func formatExhaustionTime(exhaustionAt time.Time) string {
+	return exhaustionAt.Format("2006-01-02 15:04 MST")
+}
+
+// Utilization history of the address and delegated prefix pools indexed
+// by the pool IDs.
+type poolUtilizationHistories struct {
+	addressPools map[int64][]utilizationPoint
+	prefixPools  map[int64][]utilizationPoint
+}
+
+// Stores the current utilization of the pools with the pulled statistics,
+// deletes the samples older than the forecast window and returns the
+// utilization history of the pools.
+func (statsPuller *StatsPuller) getPoolUtilizationHistories(addressPools []*dbmodel.AddressPool, prefixPools []*dbmodel.PrefixPool, now time.Time) (*poolUtilizationHistories, error) {
+	var samples []*dbmodel.PoolUtilizationSample
+	for _, pool := range addressPools {
+		samples = append(samples, &dbmodel.PoolUtilizationSample{
+			AddressPoolID: pool.ID,
+			SampledAt:     pool.StatsCollectedAt,
+			Utilization:   pool.Utilization,
+		})
+	}
+	for _, pool := range prefixPools {
+		samples = append(samples, &dbmodel.PoolUtilizationSample{
+			PrefixPoolID: pool.ID,
+			SampledAt:    pool.StatsCollectedAt,
+			Utilization:  pool.Utilization,
+		})
+	}
+	if err := dbmodel.AddPoolUtilizationSamples(statsPuller.DB, samples); err != nil {
+		return nil, err
+	}
+	from := now.Add(-exhaustionForecastWindow)
+	if _, err := dbmodel.DeletePoolUtilizationSamplesBefore(statsPuller.DB, from); err != nil {
+		return nil, err
+	}
+	samples, err := dbmodel.GetPoolUtilizationSamples(statsPuller.DB, from, now)
+	if err != nil {
+		return nil, err
+	}
+	histories := &poolUtilizationHistories{
+		addressPools: make(map[int64][]utilizationPoint),
+		prefixPools:  make(map[int64][]utilizationPoint),
+	}
+	for _, sample := range samples {
+		point := utilizationPoint{sample.SampledAt, float64(sample.Utilization) / 1000}
+		if sample.AddressPoolID != 0 {
+			histories.addressPools[sample.AddressPoolID] = append(histories.addressPools[sample.AddressPoolID], point)
+		} else {
+			histories.prefixPools[sample.PrefixPoolID] = append(histories.prefixPools[sample.PrefixPoolID], point)
+		}
+	}
+	return histories, nil
+}
+
+// Projects when the addresses and delegated prefixes in the subnets, shared
+// networks and pools run out using their recent utilization history and the
+// RPS trend of the daemons serving them. The projections are stored in the
+// database. A warning event is raised when a projection falls within the
+// horizon configured in the settings.
+func (statsPuller *StatsPuller) updateExhaustionForecasts(subnets []*dbmodel.Subnet, now time.Time) error {
+	horizonDays, err := dbmodel.GetSettingInt(statsPuller.DB, "exhaustion_forecast_horizon")
+	if err != nil {
+		return err
+	}
+	horizon := time.Duration(horizonDays) * 24 * time.Hour
+
+	samples, err := dbmodel.GetUtilizationSamples(statsPuller.DB, dbmodel.UtilizationTierRaw, now.Add(-exhaustionForecastWindow), now)
+	if err != nil {
+		return err
+	}
+	subnetHistories := make(map[int64]*utilizationHistory)
+	sharedNetworkHistories := make(map[int64]*utilizationHistory)
+	for _, sample := range samples {
+		var histories map[int64]*utilizationHistory
+		var id int64
+		switch {
+		case sample.SubnetID != 0:
+			histories, id = subnetHistories, sample.SubnetID
+		case sample.SharedNetworkID != 0:
+			histories, id = sharedNetworkHistories, sample.SharedNetworkID
+		default:
+			continue
+		}
+		if _, ok := histories[id]; !ok {
+			histories[id] = &utilizationHistory{}
+		}
+		histories[id].add(sample)
+	}
+
+	dbDaemons, err := dbmodel.GetKeaDHCPDaemons(statsPuller.DB)
+	if err != nil {
+		return err
+	}
+	daemonStats := make(map[int64]*dbmodel.KeaDHCPDaemonStats)
+	for i := range dbDaemons {
+		if dbDaemons[i].KeaDaemon != nil && dbDaemons[i].KeaDaemon.KeaDHCPDaemon != nil {
+			daemonStats[dbDaemons[i].ID] = &dbDaemons[i].KeaDaemon.KeaDHCPDaemon.Stats
+		}
+	}
+
+	var lastErr error
+	indexedSubnets := make(map[int64]*dbmodel.Subnet, len(subnets))
+	sharedNetworkDaemons := make(map[int64]map[int64]bool)
+	for _, sn := range subnets {
+		indexedSubnets[sn.ID] = sn
+		daemonIDs := make(map[int64]bool)
+		for _, lsn := range sn.LocalSubnets {
+			daemonIDs[lsn.DaemonID] = true
+		}
+		if sn.SharedNetworkID != 0 {
+			if _, ok := sharedNetworkDaemons[sn.SharedNetworkID]; !ok {
+				sharedNetworkDaemons[sn.SharedNetworkID] = make(map[int64]bool)
+			}
+			for daemonID := range daemonIDs {
+				sharedNetworkDaemons[sn.SharedNetworkID][daemonID] = true
+			}
+		}
+
+		history, ok := subnetHistories[sn.ID]
+		if !ok {
+			history = &utilizationHistory{}
+		}
+		rpsTrend := getRpsTrend(daemonIDs, daemonStats)
+		addrExhaustionAt := forecastExhaustion(history.addr, rpsTrend, now)
+		pdExhaustionAt := forecastExhaustion(history.pd, rpsTrend, now)
+
+		if isExhaustionEnteringHorizon(sn.AddrExhaustionAt, addrExhaustionAt, horizon, now) {
+			statsPuller.EventCenter.AddWarningEvent(
+				fmt.Sprintf("Addresses in {subnet} are projected to run out by %s", formatExhaustionTime(addrExhaustionAt)), sn)
+		}
+		if isExhaustionEnteringHorizon(sn.PdExhaustionAt, pdExhaustionAt, horizon, now) {
+			statsPuller.EventCenter.AddWarningEvent(
+				fmt.Sprintf("Delegated prefixes in {subnet} are projected to run out by %s", formatExhaustionTime(pdExhaustionAt)), sn)
+		}
+		sn.AddrExhaustionAt = addrExhaustionAt
+		sn.PdExhaustionAt = pdExhaustionAt
+	}
+	if err = dbmodel.UpdateExhaustionForecastsInSubnets(statsPuller.DB, subnets); err != nil {
+		lastErr = err
+		log.Errorf("Cannot update exhaustion forecasts in subnets: %s", err)
+	}
+
+	sharedNetworks, err := dbmodel.GetSharedNetworksExhaustionForecasts(statsPuller.DB)
+	if err != nil {
+		return err
+	}
+	var updatedNetworks []*dbmodel.SharedNetwork
+	for sharedNetworkID, daemonIDs := range sharedNetworkDaemons {
+		network, ok := sharedNetworks[sharedNetworkID]
+		if !ok {
+			continue
+		}
+		history, ok := sharedNetworkHistories[sharedNetworkID]
+		if !ok {
+			history = &utilizationHistory{}
+		}
+		rpsTrend := getRpsTrend(daemonIDs, daemonStats)
+		addrExhaustionAt := forecastExhaustion(history.addr, rpsTrend, now)
+		pdExhaustionAt := forecastExhaustion(history.pd, rpsTrend, now)
+
+		if isExhaustionEnteringHorizon(network.AddrExhaustionAt, addrExhaustionAt, horizon, now) {
+			statsPuller.EventCenter.AddWarningEvent(
+				fmt.Sprintf("Addresses in shared network {sharedNetwork} are projected to run out by %s", formatExhaustionTime(addrExhaustionAt)), network)
+		}
+		if isExhaustionEnteringHorizon(network.PdExhaustionAt, pdExhaustionAt, horizon, now) {
+			statsPuller.EventCenter.AddWarningEvent(
+				fmt.Sprintf("Delegated prefixes in shared network {sharedNetwork} are projected to run out by %s", formatExhaustionTime(pdExhaustionAt)), network)
+		}
+		network.AddrExhaustionAt = addrExhaustionAt
+		network.PdExhaustionAt = pdExhaustionAt
+		updatedNetworks = append(updatedNetworks, network)
+	}
+	if err = dbmodel.UpdateExhaustionForecastsInSharedNetworks(statsPuller.DB, updatedNetworks); err != nil {
+		lastErr = err
+		log.Errorf("Cannot update exhaustion forecasts in shared networks: %s", err)
+	}
+
+	addressPools, err := dbmodel.GetAddressPoolsWithStats(statsPuller.DB)
+	if err != nil {
+		return err
+	}
+	prefixPools, err := dbmodel.GetPrefixPoolsWithStats(statsPuller.DB)
+	if err != nil {
+		return err
+	}
+	poolHistories, err := statsPuller.getPoolUtilizationHistories(addressPools, prefixPools, now)
+	if err != nil {
+		return err
+	}
+	for _, pool := range addressPools {
+		rpsTrend := getRpsTrend(map[int64]bool{pool.LocalSubnet.DaemonID: true}, daemonStats)
+		exhaustionAt := forecastExhaustion(poolHistories.addressPools[pool.ID], rpsTrend, now)
+		if sn, ok := indexedSubnets[pool.LocalSubnet.SubnetID]; ok && isExhaustionEnteringHorizon(pool.ExhaustionAt, exhaustionAt, horizon, now) {
+			statsPuller.EventCenter.AddWarningEvent(
+				fmt.Sprintf("Addresses in pool %s-%s in {subnet} are projected to run out by %s", pool.LowerBound, pool.UpperBound, formatExhaustionTime(exhaustionAt)), sn)
+		}
+		pool.ExhaustionAt = exhaustionAt
+	}
+	if err = dbmodel.UpdateExhaustionForecastsInAddressPools(statsPuller.DB, addressPools); err != nil {
+		lastErr = err
+		log.Errorf("Cannot update exhaustion forecasts in address pools: %s", err)
+	}
+	for _, pool := range prefixPools {
+		rpsTrend := getRpsTrend(map[int64]bool{pool.LocalSubnet.DaemonID: true}, daemonStats)
+		exhaustionAt := forecastExhaustion(poolHistories.prefixPools[pool.ID], rpsTrend, now)
+		if sn, ok := indexedSubnets[pool.LocalSubnet.SubnetID]; ok && isExhaustionEnteringHorizon(pool.ExhaustionAt, exhaustionAt, horizon, now) {
+			statsPuller.EventCenter.AddWarningEvent(
+				fmt.Sprintf("Delegated prefixes in pool %s (delegated length %d) in {subnet} are projected to run out by %s", pool.Prefix, pool.DelegatedLen, formatExhaustionTime(exhaustionAt)), sn)
+		}
+		pool.ExhaustionAt = exhaustionAt
+	}
+	if err = dbmodel.UpdateExhaustionForecastsInPrefixPools(statsPuller.DB, prefixPools); err != nil {
+		lastErr = err
+		log.Errorf("Cannot update exhaustion forecasts in prefix pools: %s", err)
+	}
+	return lastErr
+}
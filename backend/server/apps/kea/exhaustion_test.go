@@ -0,0 +1,244 @@
+package kea
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Test that the exhaustion is projected from the utilization growth.
+func TestForecastExhaustion(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	points := []utilizationPoint{
+		{now.Add(-2 * time.Hour), 0.5},
+		{now.Add(-time.Hour), 0.6},
+		{now, 0.7},
+	}
+	// The utilization grows by 10% per hour.
+	exhaustionAt := forecastExhaustion(points, 1, now)
+	require.WithinDuration(t, now.Add(3*time.Hour), exhaustionAt, time.Second)
+
+	// The traffic is twice as high as usual.
+	exhaustionAt = forecastExhaustion(points, 2, now)
+	require.WithinDuration(t, now.Add(90*time.Minute), exhaustionAt, time.Second)
+
+	// The RPS trend is bounded.
+	exhaustionAt = forecastExhaustion(points, 100, now)
+	require.WithinDuration(t, now.Add(90*time.Minute), exhaustionAt, time.Second)
+}
+
+// Test that the exhaustion is not projected if the utilization doesn't
+// grow or the history is too short.
+func TestForecastExhaustionNoGrowth(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	require.Zero(t, forecastExhaustion(nil, 1, now))
+	require.Zero(t, forecastExhaustion([]utilizationPoint{{now, 0.5}}, 1, now))
+	require.Zero(t, forecastExhaustion([]utilizationPoint{{now, 0.5}, {now, 0.6}}, 1, now))
+	require.Zero(t, forecastExhaustion([]utilizationPoint{{now.Add(-time.Hour), 0.6}, {now, 0.5}}, 1, now))
+	require.Zero(t, forecastExhaustion([]utilizationPoint{{now.Add(-time.Hour), 0.5}, {now, 0.5}}, 1, now))
+	// The growth is too slow to project the exhaustion.
+	require.Zero(t, forecastExhaustion([]utilizationPoint{{now.Add(-time.Hour), 0.5}, {now, 0.500001}}, 1, now))
+	// The addresses have already run out.
+	require.Equal(t, now, forecastExhaustion([]utilizationPoint{{now, 1}}, 1, now))
+}
+
+// Test that the RPS trend is calculated for the specified daemons.
+func TestGetRpsTrend(t *testing.T) {
+	daemons := map[int64]*dbmodel.KeaDHCPDaemonStats{
+		1: {RPS1: 10, RPS2: 5},
+		2: {RPS1: 20, RPS2: 5},
+		3: {RPS1: 10, RPS2: 0},
+	}
+	require.EqualValues(t, 2, getRpsTrend(map[int64]bool{1: true}, daemons))
+	require.EqualValues(t, 3, getRpsTrend(map[int64]bool{1: true, 2: true}, daemons))
+	require.EqualValues(t, 1, getRpsTrend(map[int64]bool{3: true}, daemons))
+	require.EqualValues(t, 1, getRpsTrend(map[int64]bool{4: true}, daemons))
+}
+
+// Test that the projection entering the horizon is detected.
+func TestIsExhaustionEnteringHorizon(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	horizon := 24 * time.Hour
+	soon := now.Add(time.Hour)
+	later := now.Add(48 * time.Hour)
+
+	require.True(t, isExhaustionEnteringHorizon(time.Time{}, soon, horizon, now))
+	require.True(t, isExhaustionEnteringHorizon(later, soon, horizon, now))
+	require.False(t, isExhaustionEnteringHorizon(soon, soon, horizon, now))
+	require.False(t, isExhaustionEnteringHorizon(time.Time{}, later, horizon, now))
+	require.False(t, isExhaustionEnteringHorizon(soon, time.Time{}, horizon, now))
+	require.False(t, isExhaustionEnteringHorizon(time.Time{}, soon, 0, now))
+}
+
+// Test that the exhaustion forecasts are stored in the database and the
+// warning events are raised when the projections enter the horizon.
+func TestUpdateExhaustionForecasts(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	network := &dbmodel.SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	err := dbmodel.AddSharedNetwork(db, network)
+	require.NoError(t, err)
+
+	subnet := &dbmodel.Subnet{
+		Prefix:          "192.0.2.0/24",
+		SharedNetworkID: network.ID,
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	// The utilization grows by 10% per hour.
+	now := time.Now().UTC().Truncate(time.Minute)
+	var samples []*dbmodel.UtilizationSample
+	for i := 0; i < 3; i++ {
+		sampledAt := now.Add(time.Duration(i-2) * time.Hour)
+		samples = append(samples,
+			&dbmodel.UtilizationSample{SubnetID: subnet.ID, Family: 4, SampledAt: sampledAt, AddrUtilization: int16(500 + 100*i)},
+			&dbmodel.UtilizationSample{SharedNetworkID: network.ID, Family: 4, SampledAt: sampledAt, AddrUtilization: int16(500 + 100*i)},
+		)
+	}
+	err = dbmodel.AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	fec := &storktest.FakeEventCenter{}
+	sp := &StatsPuller{
+		PeriodicPuller: &agentcomm.PeriodicPuller{DB: db},
+		EventCenter:    fec,
+	}
+
+	subnets, err := dbmodel.GetSubnetsWithLocalSubnets(db)
+	require.NoError(t, err)
+	err = sp.updateExhaustionForecasts(subnets, now)
+	require.NoError(t, err)
+
+	returned, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.WithinDuration(t, now.Add(3*time.Hour), returned.AddrExhaustionAt, time.Second)
+	require.Zero(t, returned.PdExhaustionAt)
+
+	returnedNetwork, err := dbmodel.GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.WithinDuration(t, now.Add(3*time.Hour), returnedNetwork.AddrExhaustionAt, time.Second)
+
+	require.Len(t, fec.Events, 2)
+	require.Contains(t, fec.Events[0].Text, "Addresses in <subnet")
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.EqualValues(t, subnet.ID, fec.Events[0].Relations.SubnetID)
+	require.Contains(t, fec.Events[1].Text, "Addresses in shared network <shared-network")
+	require.EqualValues(t, network.ID, fec.Events[1].Relations.SharedNetworkID)
+
+	// The events are not repeated.
+	subnets, err = dbmodel.GetSubnetsWithLocalSubnets(db)
+	require.NoError(t, err)
+	err = sp.updateExhaustionForecasts(subnets, now)
+	require.NoError(t, err)
+	require.Len(t, fec.Events, 2)
+}
+
+// Test that the exhaustion of the address and delegated prefix pools is
+// projected from their utilization history.
+func TestUpdateExhaustionForecastsPools(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	v6Config := `{
+		"Dhcp6": {
+			"subnet6": [
+				{
+					"id": 1,
+					"subnet": "2001:db8:1::/64",
+					"pools": [
+						{
+							"pool": "2001:db8:1::10-2001:db8:1::ff"
+						}
+					],
+					"pd-pools": [
+						{
+							"prefix": "3000::",
+							"prefix-len": 48,
+							"delegated-len": 64
+						}
+					]
+				}
+			]
+		}
+	}`
+	app := createAppWithSubnets(t, db, 0, "", v6Config)
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	sharedNetworks, subnets, err := detectDaemonNetworks(db, app.Daemons[0], lookup)
+	require.NoError(t, err)
+	_, err = dbmodel.CommitNetworksIntoDB(db, sharedNetworks, subnets)
+	require.NoError(t, err)
+
+	dbSubnets, err := dbmodel.GetSubnetsWithLocalSubnets(db)
+	require.NoError(t, err)
+	require.Len(t, dbSubnets, 1)
+	subnet, err := dbmodel.GetSubnet(db, dbSubnets[0].ID)
+	require.NoError(t, err)
+	require.Len(t, subnet.LocalSubnets, 1)
+	addressPool := subnet.LocalSubnets[0].AddressPools[0]
+	prefixPool := subnet.LocalSubnets[0].PrefixPools[0]
+
+	// The utilization grows by 10% per hour.
+	err = addressPool.UpdateStats(db, dbmodel.SubnetStats{
+		"assigned-nas": uint64(70),
+		"total-nas":    uint64(100),
+	})
+	require.NoError(t, err)
+	err = prefixPool.UpdateStats(db, dbmodel.SubnetStats{
+		"assigned-pds": uint64(70),
+		"total-pds":    uint64(100),
+	})
+	require.NoError(t, err)
+	now := time.Now().UTC()
+	collectedAt := addressPool.StatsCollectedAt
+	err = dbmodel.AddPoolUtilizationSamples(db, []*dbmodel.PoolUtilizationSample{
+		{AddressPoolID: addressPool.ID, SampledAt: collectedAt.Add(-48 * time.Hour), Utilization: 100},
+		{AddressPoolID: addressPool.ID, SampledAt: collectedAt.Add(-2 * time.Hour), Utilization: 500},
+		{AddressPoolID: addressPool.ID, SampledAt: collectedAt.Add(-time.Hour), Utilization: 600},
+		{PrefixPoolID: prefixPool.ID, SampledAt: collectedAt.Add(-2 * time.Hour), Utilization: 500},
+		{PrefixPoolID: prefixPool.ID, SampledAt: collectedAt.Add(-time.Hour), Utilization: 600},
+	})
+	require.NoError(t, err)
+
+	fec := &storktest.FakeEventCenter{}
+	sp := &StatsPuller{
+		PeriodicPuller: &agentcomm.PeriodicPuller{DB: db},
+		EventCenter:    fec,
+	}
+
+	err = sp.updateExhaustionForecasts(dbSubnets, now)
+	require.NoError(t, err)
+
+	addressPools, err := dbmodel.GetAddressPoolsWithStats(db)
+	require.NoError(t, err)
+	require.Len(t, addressPools, 1)
+	require.WithinDuration(t, collectedAt.Add(3*time.Hour), addressPools[0].ExhaustionAt, 5*time.Second)
+
+	prefixPools, err := dbmodel.GetPrefixPoolsWithStats(db)
+	require.NoError(t, err)
+	require.Len(t, prefixPools, 1)
+	require.WithinDuration(t, collectedAt.Add(3*time.Hour), prefixPools[0].ExhaustionAt, 5*time.Second)
+
+	require.Len(t, fec.Events, 2)
+	require.Contains(t, fec.Events[0].Text, "Addresses in pool 2001:db8:1::10-2001:db8:1::ff in <subnet")
+	require.EqualValues(t, subnet.ID, fec.Events[0].Relations.SubnetID)
+	require.Contains(t, fec.Events[1].Text, "Delegated prefixes in pool 3000::/48 (delegated length 64) in <subnet")
+	require.EqualValues(t, subnet.ID, fec.Events[1].Relations.SubnetID)
+
+	// The current utilization has been stored and the samples older than
+	// the forecast window have been deleted.
+	samples, err := dbmodel.GetPoolUtilizationSamples(db, now.Add(-72*time.Hour), now)
+	require.NoError(t, err)
+	require.Len(t, samples, 6)
+}
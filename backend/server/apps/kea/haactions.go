@@ -0,0 +1,143 @@
+package kea
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcomm "isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// An action controlling the HA state machine of a Kea server.
+type HAAction string
+
+// HA actions supported by Stork. Each action corresponds to a Kea command
+// with the ha- prefix.
+const (
+	HAActionMaintenanceStart  HAAction = "maintenance-start"
+	HAActionMaintenanceCancel HAAction = "maintenance-cancel"
+	HAActionSync              HAAction = "sync"
+	HAActionScopes            HAAction = "scopes"
+	HAActionContinue          HAAction = "continue"
+)
+
+// Timeout of the HA action. The ha-sync command may take long because the
+// server fetches all leases from its partner.
+const haActionTimeout = 60 * time.Second
+
+// Checks if the HA action is supported.
+func (a HAAction) IsValid() bool {
+	switch a {
+	case HAActionMaintenanceStart, HAActionMaintenanceCancel, HAActionSync,
+		HAActionScopes, HAActionContinue:
+		return true
+	default:
+		return false
+	}
+}
+
+// Returns the name of the Kea command performing the action.
+func (a HAAction) GetCommandName() string {
+	return "ha-" + string(a)
+}
+
+// Returns the name of the daemon's partner in the HA relationship of the
+// service. It returns an empty string if the daemon doesn't belong to the
+// relationship. The second returned value indicates whether the daemon
+// has multiple HA relationships, i.e., it is a hub in the hub-and-spoke
+// configuration. The backup servers are the partners only in the
+// passive-backup mode, in which there is no other server to partner with.
+func getHAPartnerName(daemon *dbmodel.Daemon, service *dbmodel.Service) (string, bool) {
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return "", false
+	}
+	_, params, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
+	if !ok {
+		return "", false
+	}
+	relationships := params.GetAllValid()
+	for _, relationship := range relationships {
+		if service.HAService.Relationship != "" && service.HAService.Relationship != relationship.GetRelationshipName() {
+			continue
+		}
+		thisServer := relationship.GetThisServer()
+		if thisServer == nil {
+			continue
+		}
+		passiveBackup := *relationship.Mode == "passive-backup"
+		for _, peer := range relationship.Peers {
+			if *peer.Name != *thisServer.Name && (*peer.Role != "backup" || passiveBackup) {
+				return *peer.Name, len(relationships) > 1
+			}
+		}
+	}
+	return "", len(relationships) > 1
+}
+
+// Creates the Kea command performing the HA action on the daemon belonging
+// to the HA service. The ha-sync command always specifies the partner to
+// synchronize the leases with. The other commands specify the partner only
+// if the daemon has multiple HA relationships, so Kea can determine the
+// relationship the command pertains to. The scopes are only used by the
+// ha-scopes command.
+func NewHAActionCommand(daemon *dbmodel.Daemon, service *dbmodel.Service, action HAAction, scopes []string) (*keactrl.Command, error) {
+	if !action.IsValid() {
+		return nil, errors.Errorf("unsupported HA action %s", action)
+	}
+	if service.HAService == nil {
+		return nil, errors.Errorf("service %d is not an HA service", service.ID)
+	}
+	arguments := make(map[string]interface{})
+	partnerName, multipleRelationships := getHAPartnerName(daemon, service)
+	if action == HAActionSync || multipleRelationships {
+		if partnerName == "" {
+			return nil, errors.Errorf("cannot find the partner of %s daemon with ID %d in the HA relationship", daemon.Name, daemon.ID)
+		}
+		arguments["server-name"] = partnerName
+	}
+	if action == HAActionScopes {
+		if scopes == nil {
+			scopes = []string{}
+		}
+		arguments["scopes"] = scopes
+	}
+	if len(arguments) == 0 {
+		// The command takes no arguments.
+		return keactrl.NewCommand(action.GetCommandName(), []string{daemon.Name}, nil), nil
+	}
+	return keactrl.NewCommand(action.GetCommandName(), []string{daemon.Name}, arguments), nil
+}
+
+// Sends the command performing the HA action, created with
+// NewHAActionCommand, to the daemon. It returns the text returned by Kea on
+// success. The error is returned if the communication with the daemon
+// fails or the daemon fails to perform the action.
+func SendHAAction(ctx context.Context, agents agentcomm.ConnectedAgents, daemon *dbmodel.Daemon, command *keactrl.Command) (string, error) {
+	if daemon.App == nil {
+		return "", errors.Errorf("app of daemon with ID %d is not specified", daemon.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, haActionTimeout)
+	defer cancel()
+
+	var response keactrl.ResponseList
+	cmdsResult, err := agents.ForwardToKeaOverHTTP(ctx, daemon.App, []keactrl.SerializableCommand{command}, &response)
+	if err != nil {
+		return "", err
+	}
+	if cmdsResult.Error != nil {
+		return "", cmdsResult.Error
+	}
+	if len(cmdsResult.CmdsErrors) > 0 && cmdsResult.CmdsErrors[0] != nil {
+		return "", cmdsResult.CmdsErrors[0]
+	}
+	if len(response) == 0 {
+		return "", errors.Errorf("invalid response to %s command received", command.GetCommand())
+	}
+	if err = keactrl.GetResponseError(response[0]); err != nil {
+		return "", err
+	}
+	return response[0].Text, nil
+}
@@ -0,0 +1,197 @@
+package kea
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// Returns a daemon having the specified HA configuration and the HA
+// service of the specified relationship.
+func getHAActionTestDaemonAndService(config *dbmodel.KeaConfig, relationship string) (*dbmodel.Daemon, *dbmodel.Service) {
+	accessPoints := []*dbmodel.AccessPoint{}
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", 8000, false)
+	daemon := dbmodel.NewKeaDaemon(dbmodel.DaemonNameDHCPv4, true)
+	daemon.ID = 1
+	daemon.KeaDaemon.Config = config
+	daemon.App = &dbmodel.App{
+		ID:           1,
+		AccessPoints: accessPoints,
+	}
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			ID: 1,
+		},
+		HAService: &dbmodel.BaseHAService{
+			Relationship: relationship,
+		},
+	}
+	return daemon, service
+}
+
+// Test that the commands performing the HA actions are created for the
+// server having a single HA relationship.
+func TestNewHAActionCommand(t *testing.T) {
+	daemon, service := getHAActionTestDaemonAndService(getHubAndSpokeTestConfig("server2"), "server1")
+
+	command, err := NewHAActionCommand(daemon, service, HAActionMaintenanceStart, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"command": "ha-maintenance-start",
+		"service": [ "dhcp4" ]
+	}`, command.Marshal())
+
+	command, err = NewHAActionCommand(daemon, service, HAActionSync, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"command": "ha-sync",
+		"service": [ "dhcp4" ],
+		"arguments": {
+			"server-name": "server1"
+		}
+	}`, command.Marshal())
+
+	command, err = NewHAActionCommand(daemon, service, HAActionScopes, []string{"server1", "server2"})
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"command": "ha-scopes",
+		"service": [ "dhcp4" ],
+		"arguments": {
+			"scopes": [ "server1", "server2" ]
+		}
+	}`, command.Marshal())
+
+	// An empty list of scopes is allowed. The server doesn't respond to
+	// any queries then.
+	command, err = NewHAActionCommand(daemon, service, HAActionScopes, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"command": "ha-scopes",
+		"service": [ "dhcp4" ],
+		"arguments": {
+			"scopes": []
+		}
+	}`, command.Marshal())
+
+	_, err = NewHAActionCommand(daemon, service, HAAction("foo"), nil)
+	require.Error(t, err)
+}
+
+// Test that the commands performing the HA actions specify the partner
+// when the server is a hub in the hub-and-spoke configuration.
+func TestNewHAActionCommandHubAndSpoke(t *testing.T) {
+	daemon, service := getHAActionTestDaemonAndService(getHubAndSpokeTestConfig("server1", "server3"), "server3")
+
+	command, err := NewHAActionCommand(daemon, service, HAActionContinue, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `{
+		"command": "ha-continue",
+		"service": [ "dhcp4" ],
+		"arguments": {
+			"server-name": "server4"
+		}
+	}`, command.Marshal())
+
+	// The daemon doesn't belong to the relationship.
+	service.HAService.Relationship = "server5"
+	_, err = NewHAActionCommand(daemon, service, HAActionMaintenanceCancel, nil)
+	require.Error(t, err)
+}
+
+// Test that the backup server is the partner of the primary server in the
+// passive-backup mode and it is not the partner in the other modes.
+func TestNewHAActionCommandBackup(t *testing.T) {
+	for _, mode := range []string{"passive-backup", "hot-standby"} {
+		mode := mode
+		t.Run(mode, func(t *testing.T) {
+			config, err := dbmodel.NewKeaConfigFromJSON(fmt.Sprintf(`{
+				"Dhcp4": {
+					"hooks-libraries": [
+						{
+							"library": "libdhcp_ha.so",
+							"parameters": {
+								"high-availability": [{
+									"this-server-name": "server1",
+									"mode": "%s",
+									"peers": [
+										{ "name": "server1", "url": "http://192.0.2.1:8000", "role": "primary" },
+										{ "name": "server2", "url": "http://192.0.2.2:8000", "role": "backup" }
+									]
+								}]
+							}
+						}
+					]
+				}
+			}`, mode))
+			require.NoError(t, err)
+			daemon, service := getHAActionTestDaemonAndService(config, "server1")
+
+			command, err := NewHAActionCommand(daemon, service, HAActionSync, nil)
+			if mode != "passive-backup" {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.JSONEq(t, `{
+				"command": "ha-sync",
+				"service": [ "dhcp4" ],
+				"arguments": {
+					"server-name": "server2"
+				}
+			}`, command.Marshal())
+		})
+	}
+}
+
+// Test that the HA action is sent to Kea and the result is returned.
+func TestSendHAAction(t *testing.T) {
+	mock := func(callNo int, cmdResponses []interface{}) {
+		json := []byte(`[
+			{
+				"result": 0,
+				"text": "Server is in-maintenance state."
+			}
+		]`)
+		command := keactrl.NewCommand("ha-maintenance-start", []string{"dhcp4"}, nil)
+		_ = keactrl.UnmarshalResponseList(command, json, cmdResponses[0])
+	}
+	agents := agentcommtest.NewKeaFakeAgents(mock)
+	daemon, service := getHAActionTestDaemonAndService(getHubAndSpokeTestConfig("server2"), "server1")
+
+	command, err := NewHAActionCommand(daemon, service, HAActionMaintenanceStart, nil)
+	require.NoError(t, err)
+
+	text, err := SendHAAction(context.Background(), agents, daemon, command)
+	require.NoError(t, err)
+	require.Equal(t, "Server is in-maintenance state.", text)
+	require.Len(t, agents.RecordedCommands, 1)
+	require.Equal(t, "ha-maintenance-start", agents.RecordedCommands[0].GetCommand())
+	require.Equal(t, "http://localhost:8000/", agents.RecordedURLs[0])
+}
+
+// Test that an error is returned when Kea fails to perform the HA action.
+func TestSendHAActionError(t *testing.T) {
+	mock := func(callNo int, cmdResponses []interface{}) {
+		json := []byte(`[
+			{
+				"result": 1,
+				"text": "Unable to transition the server to the in-maintenance state."
+			}
+		]`)
+		command := keactrl.NewCommand("ha-maintenance-start", []string{"dhcp4"}, nil)
+		_ = keactrl.UnmarshalResponseList(command, json, cmdResponses[0])
+	}
+	agents := agentcommtest.NewKeaFakeAgents(mock)
+	daemon, service := getHAActionTestDaemonAndService(getHubAndSpokeTestConfig("server2"), "server1")
+
+	command, err := NewHAActionCommand(daemon, service, HAActionMaintenanceStart, nil)
+	require.NoError(t, err)
+
+	_, err = SendHAAction(context.Background(), agents, daemon, command)
+	require.ErrorContains(t, err, "Unable to transition the server to the in-maintenance state.")
+}
@@ -2,6 +2,8 @@ package kea
 
 import (
 	"context"
+	"sort"
+	"sync"
 
 	"github.com/go-pg/pg/v10"
 	errors "github.com/pkg/errors"
@@ -18,6 +20,8 @@ import (
 const (
 	// A limit for returned hosts number in the the reservation-get-page command.
 	defaultHostCmdsPageLimit int64 = 1000
+	// A maximum number of apps from which the hosts are pulled in parallel.
+	maxParallelHostsPulls = 8
 )
 
 // A structure reflecting "next" map of the Kea response to the
@@ -48,6 +52,12 @@ type HostsPuller struct {
 	ReviewDispatcher           configreview.Dispatcher
 	DHCPOptionDefinitionLookup keaconfig.DHCPOptionDefinitionLookup
 	traces                     map[int64]*hostIteratorTrace
+	// Protects the traces accessed by the parallel pulls.
+	tracesMutex sync.Mutex
+	// Serializes the hosts updates in the database. The daemons may share
+	// the host reservations (e.g., the HA partners using the same hosts
+	// database), so the parallel updates could add duplicated hosts.
+	updateMutex sync.Mutex
 }
 
 // Create an instance of the puller that periodically fetches host reservations
@@ -85,17 +95,33 @@ func (puller *HostsPuller) pull() error {
 		successCount int
 		skippedCount int
 		erredCount   int
+		countsMutex  sync.Mutex
+		wg           sync.WaitGroup
 	)
 
 	// Iterate over the Kea apps and attempt to pull host reservations
 	// from them via the host_cmds hooks library. Next, update the
-	// hosts in the Stork database.
+	// hosts in the Stork database. The apps are pulled in parallel, but
+	// the daemons belonging to the same app are pulled one after another
+	// because they share the control agent.
+	semaphore := make(chan struct{}, maxParallelHostsPulls)
 	for i := range apps {
-		success, skip, e := puller.pullFromApp(&apps[i])
-		successCount += success
-		skippedCount += skip
-		erredCount += e
-	}
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(app *dbmodel.App) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			success, skip, e := puller.pullFromApp(app)
+			countsMutex.Lock()
+			defer countsMutex.Unlock()
+			successCount += success
+			skippedCount += skip
+			erredCount += e
+		}(&apps[i])
+	}
+	wg.Wait()
 
 	// Remove the hosts that no longer belong to any app.
 	_, err = dbmodel.DeleteOrphanedHosts(puller.DB)
@@ -116,13 +142,19 @@ func (puller *HostsPuller) pull() error {
 // Kea app. The returned values are the daemon counters for which the pull
 // was successful, skipped and/or erred. They are used for logging purposes.
 func (puller *HostsPuller) pullFromApp(app *dbmodel.App) (successCount, skippedCount, erredCount int) {
+	// Skip the apps the puller backs off from.
+	if !puller.ShouldPullApp(app.ID) {
+		return
+	}
+	var lastErr error
 	for _, daemon := range app.Daemons {
-		if daemon.KeaDaemon.KeaDHCPDaemon == nil {
+		if daemon.KeaDaemon.KeaDHCPDaemon == nil || !puller.ShouldPullDaemon(app.ID, daemon.ID) {
 			continue
 		}
 		pulled, err := puller.pullFromDaemon(app, daemon)
 		if err != nil {
 			erredCount++
+			lastErr = err
 			log.WithError(err).Errorf("Problem pulling Kea hosts from daemon %d", daemon.ID)
 			continue
 		}
@@ -132,6 +164,9 @@ func (puller *HostsPuller) pullFromApp(app *dbmodel.App) (successCount, skippedC
 		}
 		successCount++
 	}
+	if successCount > 0 || erredCount > 0 {
+		puller.ReportAppPullResult(app.ID, lastErr)
+	}
 	return
 }
 
@@ -140,23 +175,17 @@ func (puller *HostsPuller) pullFromApp(app *dbmodel.App) (successCount, skippedC
 // attempted to pull the reservations (i.e., host_cmds hook library is used by
 // the daemon and the daemon is active). The function uses the iterator mechanism
 // to pull the hosts. It can result in sending multiple reservation-get-page
-// commands to each Kea instance.
+// commands to each Kea instance. The hosts are updated in the database only
+// for the subnets in which the reservations have changed since the previous
+// pull. All hosts of the daemon are updated in the first pull.
 func (puller *HostsPuller) pullFromDaemon(app *dbmodel.App, daemon *dbmodel.Daemon) (bool, error) {
-	// Hosts update is performed in a transaction but we don't begin the
-	// transaction until we detect that there were some changes in the
-	// host reservations.
-	var (
-		tx  *pg.Tx
-		err error
-	)
-
 	if !daemon.Active {
 		log.Infof("Skip pulling host reservations for inactive daemon %d", daemon.ID)
 		return false, nil
 	}
 
 	if daemon.KeaDaemon.Config == nil {
-		err = errors.Errorf("daemon %d lacks configuration", daemon.ID)
+		err := errors.Errorf("daemon %d lacks configuration", daemon.ID)
 		return false, err
 	}
 
@@ -168,69 +197,32 @@ func (puller *HostsPuller) pullFromDaemon(app *dbmodel.App, daemon *dbmodel.Daem
 		return false, nil
 	}
 
-	// Fetch the hosts as long as they are returned by Kea.
+	// Fetch the hosts as long as they are returned by Kea. The fetched
+	// hosts are held in the iterator's trace.
 	it := newHostIterator(puller.DB, app, daemon, puller.Agents, defaultHostCmdsPageLimit)
-	var done bool
-	for !done {
-		// Send reservation-get-page to Kea.
-		var reservations []keaconfig.Reservation
-		if reservations, done, err = it.getPageFromHostCmds(); err != nil {
+	for done := false; !done; {
+		var err error
+		if _, done, err = it.getPageFromHostCmds(); err != nil {
 			return true, err
 		}
-		// We're probably done getting reservations.
-		if len(reservations) == 0 {
-			continue
-		}
-		// The puller holds traces from the previous attempts to fetch the host
-		// reservations. The traces don't exist when this is the first time
-		// we pull the reservations.
-		if trace, ok := puller.traces[daemon.ID]; ok {
-			// Compare the hash created from the received reservations with the
-			// corresponding hash in the saved trace. If they are equal it means
-			// that there was no change in the host reservations since the last
-			// pull.
-			if it.trace.hasEqualHashes(trace) {
-				continue
-			}
-		}
-		// If we have detected host changes right now, there is no transaction yet.
-		// Begin new transaction.
-		if tx == nil {
-			if tx, err = puller.DB.Begin(); err != nil {
-				err = errors.Wrapf(err, "problem starting transaction to add hosts from host_cmds hooks library for daemon %d", daemon.ID)
-				return true, err
-			}
-			defer dbops.RollbackOnError(tx, &err)
+	}
+	it.updateSubnetHashes()
 
-			// Remove associations between existing host reservations and the
-			// daemon. Some associations will be re-created and some possibly
-			// not. The orphaned hosts will be later removed.
-			if _, err = dbmodel.DeleteDaemonFromHosts(tx, daemon.ID, dbmodel.HostDataSourceAPI); err != nil {
-				return true, err
-			}
+	// The puller holds traces from the previous attempts to fetch the host
+	// reservations. The traces don't exist when this is the first time
+	// we pull the reservations.
+	puller.tracesMutex.Lock()
+	previousTrace, hasPreviousTrace := puller.traces[daemon.ID]
+	puller.tracesMutex.Unlock()
 
-			// We possibly cached some responses for which the hashes haven't
-			// changed. Since we created new transaction and had to remove the
-			// associations with the daemon we now have to process these cached
-			// responses to add the associations with the hosts that haven't
-			// changed.
-			for _, traceResponse := range it.trace.responses {
-				if err = convertAndUpdateHosts(tx, daemon, it.getSubnet(traceResponse.subnetIndex), traceResponse.hosts, puller.DHCPOptionDefinitionLookup); err != nil {
-					return true, err
-				}
-			}
-		}
-		// Add the host reservations from the current response.
-		if err = convertAndUpdateHosts(tx, daemon, it.getCurrentSubnet(), reservations, puller.DHCPOptionDefinitionLookup); err != nil {
-			return true, err
-		}
+	var changedSubnetIDs []int64
+	if hasPreviousTrace {
+		changedSubnetIDs = it.trace.getChangedSubnetIDs(previousTrace)
 	}
 
-	// Only commit if we have started transaction. It means that we
-	// detected host changes.
-	if tx != nil {
-		if err = tx.Commit(); err != nil {
-			err = errors.Wrapf(err, "problem committing transaction to add new hosts from host_cmds hooks library for daemon %d", daemon.ID)
+	// Only update the hosts if we have detected changes.
+	if (hasPreviousTrace && len(changedSubnetIDs) > 0) || (!hasPreviousTrace && it.trace.getResponseCount() > 0) {
+		if err := puller.updateDaemonHosts(it, hasPreviousTrace, changedSubnetIDs); err != nil {
 			return true, err
 		}
 		_ = puller.ReviewDispatcher.BeginReview(daemon, []configreview.Trigger{configreview.DBHostsModified}, nil)
@@ -242,11 +234,69 @@ func (puller *HostsPuller) pullFromDaemon(app *dbmodel.App, daemon *dbmodel.Daem
 	}
 
 	// Remember the current trace.
+	puller.tracesMutex.Lock()
 	puller.traces[daemon.ID] = it.trace
+	puller.tracesMutex.Unlock()
 
 	return true, nil
 }
 
+// Updates the hosts pulled from the daemon in the database. If the
+// incremental flag is false, the daemon is dissociated from all hosts and
+// then associated with all pulled hosts. Otherwise, only the hosts in the
+// specified subnets are updated. The zero subnet ID denotes the global hosts.
+func (puller *HostsPuller) updateDaemonHosts(it *hostIterator, incremental bool, subnetIDs []int64) (err error) {
+	puller.updateMutex.Lock()
+	defer puller.updateMutex.Unlock()
+
+	daemon := it.daemon
+	tx, err := puller.DB.Begin()
+	if err != nil {
+		err = errors.Wrapf(err, "problem starting transaction to add hosts from host_cmds hooks library for daemon %d", daemon.ID)
+		return err
+	}
+	defer dbops.RollbackOnError(tx, &err)
+
+	updatedSubnets := make(map[int64]bool)
+	if incremental {
+		// Remove associations between existing host reservations in the
+		// changed subnets and the daemon. Some associations will be
+		// re-created and some possibly not. The orphaned hosts will be
+		// later removed.
+		for _, subnetID := range subnetIDs {
+			if _, err = dbmodel.DeleteDaemonFromSubnetHosts(tx, daemon.ID, subnetID, dbmodel.HostDataSourceAPI); err != nil {
+				return err
+			}
+			updatedSubnets[subnetID] = true
+		}
+	} else if _, err = dbmodel.DeleteDaemonFromHosts(tx, daemon.ID, dbmodel.HostDataSourceAPI); err != nil {
+		return err
+	}
+
+	for _, traceResponse := range it.trace.responses {
+		subnet := it.getSubnet(traceResponse.subnetIndex)
+		if incremental && !updatedSubnets[getHostSubnetID(subnet)] {
+			continue
+		}
+		if err = convertAndUpdateHosts(tx, daemon, subnet, traceResponse.hosts, puller.DHCPOptionDefinitionLookup); err != nil {
+			return err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = errors.Wrapf(err, "problem committing transaction to add new hosts from host_cmds hooks library for daemon %d", daemon.ID)
+	}
+	return err
+}
+
+// Returns the ID of the subnet or 0 for the global hosts.
+func getHostSubnetID(subnet *dbmodel.Subnet) int64 {
+	if subnet == nil {
+		return 0
+	}
+	return subnet.ID
+}
+
 // A structure used by the host iterator to remember host reservations
 // and hash values created from the host reservations for each
 // reservation-get-page command. The hashes can be later used to check
@@ -257,6 +307,9 @@ func (puller *HostsPuller) pullFromDaemon(app *dbmodel.App, daemon *dbmodel.Daem
 // perform config reviews etc.
 type hostIteratorTrace struct {
 	responses []hostIteratorTraceResponse
+	// Hashes created from the hashes of all responses returned for the
+	// subnets. They are indexed by subnet ID (0 for the global hosts).
+	subnetHashes map[int64]string
 }
 
 // A structure representing a single hosts page returned by Kea. It
@@ -303,6 +356,32 @@ func (trace *hostIteratorTrace) hasEqualHashes(other *hostIteratorTrace) bool {
 	return true
 }
 
+// Returns the IDs of the subnets for which the hashes differ from the
+// hashes in the other (previous) trace. It includes the subnets which
+// had the hosts in the other trace but have none now. The zero ID
+// denotes the global hosts. The returned IDs are sorted.
+func (trace *hostIteratorTrace) getChangedSubnetIDs(other *hostIteratorTrace) []int64 {
+	// Nothing has changed if all responses are the same.
+	if len(trace.responses) == len(other.responses) && trace.hasEqualHashes(other) {
+		return nil
+	}
+	var subnetIDs []int64
+	for subnetID, hash := range trace.subnetHashes {
+		if otherHash, ok := other.subnetHashes[subnetID]; !ok || otherHash != hash {
+			subnetIDs = append(subnetIDs, subnetID)
+		}
+	}
+	for subnetID := range other.subnetHashes {
+		if _, ok := trace.subnetHashes[subnetID]; !ok {
+			subnetIDs = append(subnetIDs, subnetID)
+		}
+	}
+	sort.Slice(subnetIDs, func(i, j int) bool {
+		return subnetIDs[i] < subnetIDs[j]
+	})
+	return subnetIDs
+}
+
 // Structure reflecting a state of fetching host reservations from Kea
 // via the reservation-get-page command. It allows for fetching hosts
 // in chunks to avoid large bulk of data to be generated on the Kea side
@@ -442,6 +521,20 @@ func (iterator *hostIterator) getCurrentSubnet() *dbmodel.Subnet {
 	return iterator.getSubnet(iterator.subnetIndex)
 }
 
+// Creates the per-subnet hashes in the trace from the hashes of the
+// responses returned for the subnets.
+func (iterator *hostIterator) updateSubnetHashes() {
+	responseHashes := make(map[int64][]string)
+	for _, response := range iterator.trace.responses {
+		subnetID := getHostSubnetID(iterator.getSubnet(response.subnetIndex))
+		responseHashes[subnetID] = append(responseHashes[subnetID], response.hash)
+	}
+	iterator.trace.subnetHashes = make(map[int64]string)
+	for subnetID, hashes := range responseHashes {
+		iterator.trace.subnetHashes[subnetID] = storkutil.Fnv128(hashes)
+	}
+}
+
 // Returns the next chunk of host reservations. The first returned value is a slice
 // containing the next chunk of hosts. The second value, done, indicates if the
 // returned chunk of hosts was the last available one for the given daemon. If this
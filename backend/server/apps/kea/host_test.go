@@ -1733,6 +1733,55 @@ func TestHostIteratorTrace(t *testing.T) {
 	require.False(t, trace2.hasEqualHashes(trace0))
 }
 
+// Test that the subnets with changed host reservations are detected by
+// comparing the per-subnet hashes.
+func TestHostIteratorTraceChangedSubnets(t *testing.T) {
+	hosts := []keaconfig.Reservation{}
+
+	it := &hostIterator{
+		subnets: []dbmodel.Subnet{{ID: 10}, {ID: 20}, {ID: 30}},
+		trace:   newHostIteratorTrace(),
+	}
+	// Global hosts and two pages of hosts for the first subnet.
+	it.trace.addResponse("1234", -1, hosts)
+	it.trace.addResponse("2345", 0, hosts)
+	it.trace.addResponse("3456", 0, hosts)
+	it.trace.addResponse("4567", 1, hosts)
+	it.updateSubnetHashes()
+	previous := it.trace
+	require.Len(t, previous.subnetHashes, 3)
+	require.Contains(t, previous.subnetHashes, int64(0))
+	require.Contains(t, previous.subnetHashes, int64(10))
+	require.Contains(t, previous.subnetHashes, int64(20))
+
+	// The same responses.
+	it.trace = newHostIteratorTrace()
+	it.trace.addResponse("1234", -1, hosts)
+	it.trace.addResponse("2345", 0, hosts)
+	it.trace.addResponse("3456", 0, hosts)
+	it.trace.addResponse("4567", 1, hosts)
+	it.updateSubnetHashes()
+	require.Empty(t, it.trace.getChangedSubnetIDs(previous))
+
+	// The second page of the first subnet has changed, the hosts have
+	// been removed from the second subnet and added to the third one.
+	it.trace = newHostIteratorTrace()
+	it.trace.addResponse("1234", -1, hosts)
+	it.trace.addResponse("2345", 0, hosts)
+	it.trace.addResponse("9999", 0, hosts)
+	it.trace.addResponse("5678", 2, hosts)
+	it.updateSubnetHashes()
+	require.Equal(t, []int64{10, 20, 30}, it.trace.getChangedSubnetIDs(previous))
+
+	// The global hosts have been removed.
+	it.trace = newHostIteratorTrace()
+	it.trace.addResponse("2345", 0, hosts)
+	it.trace.addResponse("3456", 0, hosts)
+	it.trace.addResponse("4567", 1, hosts)
+	it.updateSubnetHashes()
+	require.Equal(t, []int64{0}, it.trace.getChangedSubnetIDs(previous))
+}
+
 // Test that host reservation is updated when DHCP identifiers and IP
 // addresses remain unchanged, but the hostname changes.
 func TestUpdateHost(t *testing.T) {
@@ -0,0 +1,206 @@
+package kea
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Matches the names of the per-subnet statistics counting the leases
+// assigned since the server startup, e.g.,
+// subnet[1].cumulative-assigned-addresses.
+var subnetLeaseCounterPattern = regexp.MustCompile(`^subnet\[(\d+)\]\.cumulative-assigned-(addresses|nas|pds)$`)
+
+// Names of the daemon-wide statistics counting the NAKs sent, the declines
+// received and the packets dropped. The DHCPv6 servers don't send NAKs.
+type packetCounterNames struct {
+	naks     string
+	declines string
+	drops    string
+}
+
+// Daemon-wide packet statistics by the inet family.
+var packetCounterNamesByFamily = map[int]packetCounterNames{
+	4: {"pkt4-nak-sent", "pkt4-decline-received", "pkt4-receive-drop"},
+	6: {"", "pkt6-decline-received", "pkt6-receive-drop"},
+}
+
+// Numbers of the packets counted by a daemon or in a subnet at the time
+// of the pull.
+type packetCounters struct {
+	sampledAt      time.Time
+	assignedLeases int64
+	naks           int64
+	declines       int64
+	drops          int64
+}
+
+// Identifies the packet counters of the daemon in the subnet. The subnet
+// ID is zero for the daemon-wide counters.
+type packetCountersKey struct {
+	daemonID int64
+	subnetID int64
+}
+
+// Returns the most recent value of the statistic returned in the
+// statistic-get-all response. The second returned value is false if the
+// statistic is missing.
+func getLatestCounterValue(latest LatestStatisticValue) (int64, bool) {
+	if len(latest) == 0 {
+		return 0, false
+	}
+	value, err := strconv.ParseInt(string(latest), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// Returns the number of the packets counted since the previous pull. The
+// counter was reset (e.g., the server was restarted) if its value is lower
+// than previously. In this case, the current value is the number of the
+// packets counted since the reset.
+func getCounterDelta(previous, current int64) int64 {
+	if current < 0 {
+		return 0
+	}
+	if current >= previous {
+		return current - previous
+	}
+	return current
+}
+
+// Extracts the daemon-wide packet counters and the numbers of the leases
+// assigned in the subnets from the statistic-get-all response. The lease
+// counters are indexed by the local subnet ID.
+func getPacketCountersFromResponse(family int, response *StatisticGetAllResponse) (packetCounters, map[int64]int64) {
+	var daemonCounters packetCounters
+	names := packetCounterNamesByFamily[family]
+	for name, target := range map[string]*int64{
+		names.naks:     &daemonCounters.naks,
+		names.declines: &daemonCounters.declines,
+		names.drops:    &daemonCounters.drops,
+	} {
+		if name == "" {
+			continue
+		}
+		if value, ok := getLatestCounterValue(response.Arguments[name]); ok {
+			*target = value
+		}
+	}
+
+	subnetCounters := make(map[int64]int64)
+	for name, latest := range response.Arguments {
+		match := subnetLeaseCounterPattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		localSubnetID, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if value, ok := getLatestCounterValue(latest); ok {
+			subnetCounters[localSubnetID] += value
+		}
+	}
+	return daemonCounters, subnetCounters
+}
+
+// Processes the statistic-get-all command response. It stores the numbers
+// of the NAKs, declines and drops counted by the daemon and the numbers of
+// the leases assigned in its subnets since the previous pull. Then, it
+// updates the packet rates of the daemon.
+func (rpsWorker *RpsWorker) PacketRatesHandler(daemon *dbmodel.Daemon, response interface{}, subnetsMap map[localSubnetKey]*dbmodel.LocalSubnet, family int) error {
+	sr, ok := response.(*[]StatisticGetAllResponse)
+	if !ok || len(*sr) == 0 {
+		return errors.Errorf("response is empty: %+v", response)
+	}
+	if (*sr)[0].Arguments == nil {
+		return errors.Errorf("missing arguments from the statistic-get-all response %+v", (*sr)[0])
+	}
+
+	sampledAt := storkutil.UTCNow()
+	daemonCounters, subnetCounters := getPacketCountersFromResponse(family, &(*sr)[0])
+	daemonCounters.sampledAt = sampledAt
+	current := map[packetCountersKey]packetCounters{
+		{daemon.ID, 0}: daemonCounters,
+	}
+	for localSubnetID, assignedLeases := range subnetCounters {
+		lsn, ok := subnetsMap[localSubnetKey{localSubnetID, family}]
+		if !ok || lsn.DaemonID != daemon.ID {
+			continue
+		}
+		current[packetCountersKey{daemon.ID, lsn.SubnetID}] = packetCounters{
+			sampledAt:      sampledAt,
+			assignedLeases: assignedLeases,
+		}
+	}
+
+	var intervals []*dbmodel.PacketRateInterval
+	for key, counters := range current {
+		if previous, exist := rpsWorker.previousCounters[key]; exist {
+			duration := counters.sampledAt.Unix() - previous.sampledAt.Unix()
+			if duration > 0 {
+				intervals = append(intervals, &dbmodel.PacketRateInterval{
+					DaemonID:       key.daemonID,
+					SubnetID:       key.subnetID,
+					StartTime:      previous.sampledAt,
+					Duration:       duration,
+					AssignedLeases: getCounterDelta(previous.assignedLeases, counters.assignedLeases),
+					NAKs:           getCounterDelta(previous.naks, counters.naks),
+					Declines:       getCounterDelta(previous.declines, counters.declines),
+					Drops:          getCounterDelta(previous.drops, counters.drops),
+				})
+			}
+		}
+		rpsWorker.previousCounters[key] = counters
+	}
+	if err := dbmodel.AddPacketRateIntervals(rpsWorker.db, intervals); err != nil {
+		return err
+	}
+	return rpsWorker.updateKeaDaemonPacketRates(daemon)
+}
+
+// Updates the NAK, decline and drop rates of the daemon over both intervals
+// using the packet rate intervals stored in the database.
+func (rpsWorker *RpsWorker) updateKeaDaemonPacketRates(daemon *dbmodel.Daemon) error {
+	endTime := storkutil.UTCNow()
+	rates1, err := dbmodel.GetDaemonPacketRates(rpsWorker.db, daemon.ID, endTime.Add(-rpsWorker.Interval1))
+	if err != nil {
+		return errors.WithMessagef(err, "query for packet rates in interval 1 failed")
+	}
+	rates2, err := dbmodel.GetDaemonPacketRates(rpsWorker.db, daemon.ID, endTime.Add(-rpsWorker.Interval2))
+	if err != nil {
+		return errors.WithMessagef(err, "query for packet rates in interval 2 failed")
+	}
+
+	stats := &daemon.KeaDaemon.KeaDHCPDaemon.Stats
+	stats.NAKRate1 = rates1.NAKs
+	stats.NAKRate2 = rates2.NAKs
+	stats.DeclineRate1 = rates1.Declines
+	stats.DeclineRate2 = rates2.Declines
+	stats.DropRate1 = rates1.Drops
+	stats.DropRate2 = rates2.Drops
+	return dbmodel.UpdateDaemon(rpsWorker.db, daemon)
+}
+
+// Calculates the rates of the leases assigned in the subnets and shared
+// networks over both intervals and stores them in the database. Kea doesn't
+// count the responses per subnet, so the lease rates are the closest
+// per-subnet measure of the traffic.
+func (rpsWorker *RpsWorker) UpdateSubnetLeaseRates() error {
+	endTime := storkutil.UTCNow()
+	rates1, err := dbmodel.GetSubnetLeaseRates(rpsWorker.db, endTime.Add(-rpsWorker.Interval1))
+	if err != nil {
+		return err
+	}
+	rates2, err := dbmodel.GetSubnetLeaseRates(rpsWorker.db, endTime.Add(-rpsWorker.Interval2))
+	if err != nil {
+		return err
+	}
+	return dbmodel.UpdateSubnetLeaseRates(rpsWorker.db, rates1, rates2)
+}
@@ -0,0 +1,142 @@
+package kea
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the number of packets since the previous pull is calculated
+// and the counter resets are detected.
+func TestGetCounterDelta(t *testing.T) {
+	require.EqualValues(t, 5, getCounterDelta(10, 15))
+	require.EqualValues(t, 0, getCounterDelta(10, 10))
+	require.EqualValues(t, 3, getCounterDelta(10, 3))
+	require.EqualValues(t, 0, getCounterDelta(10, -1))
+}
+
+// Test that the daemon-wide counters and the per-subnet lease counters are
+// extracted from the statistic-get-all response.
+func TestGetPacketCountersFromResponse(t *testing.T) {
+	response := &StatisticGetAllResponse{}
+	err := json.Unmarshal([]byte(`{
+		"result": 0,
+		"arguments": {
+			"pkt6-receive-drop": [[7, "2023-05-10 12:00:00.000000"], [5, "2023-05-10 11:00:00.000000"]],
+			"pkt6-decline-received": [[2, "2023-05-10 12:00:00.000000"]],
+			"subnet[1].cumulative-assigned-nas": [[10, "2023-05-10 12:00:00.000000"]],
+			"subnet[1].cumulative-assigned-pds": [[4, "2023-05-10 12:00:00.000000"]],
+			"subnet[1].assigned-nas": [[100, "2023-05-10 12:00:00.000000"]],
+			"subnet[2].cumulative-assigned-nas": []
+		}
+	}`), response)
+	require.NoError(t, err)
+
+	daemonCounters, subnetCounters := getPacketCountersFromResponse(6, response)
+	require.EqualValues(t, 7, daemonCounters.drops)
+	require.EqualValues(t, 2, daemonCounters.declines)
+	require.Zero(t, daemonCounters.naks)
+	require.Len(t, subnetCounters, 1)
+	require.EqualValues(t, 14, subnetCounters[1])
+}
+
+// Test that the packet rate intervals are stored since the second pull and
+// the packet rates of the daemon and its subnets are calculated.
+func TestPacketRatesHandler(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	dhcp4Daemon, _ := rpsTestAddMachine(t, db, true, true)
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err := dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	subnetsMap := map[localSubnetKey]*dbmodel.LocalSubnet{
+		{1, 4}: {DaemonID: dhcp4Daemon.ID, SubnetID: subnet.ID, LocalSubnetID: 1},
+	}
+
+	rps, err := NewRpsWorker(db)
+	require.NoError(t, err)
+
+	makeResponse := func(naks, assigned string) *[]StatisticGetAllResponse {
+		return &[]StatisticGetAllResponse{{
+			Arguments: map[string]LatestStatisticValue{
+				"pkt4-nak-sent": LatestStatisticValue(naks),
+				"subnet[1].cumulative-assigned-addresses": LatestStatisticValue(assigned),
+			},
+		}}
+	}
+
+	// The first pull only remembers the counters.
+	err = rps.PacketRatesHandler(dhcp4Daemon, makeResponse("10", "100"), subnetsMap, 4)
+	require.NoError(t, err)
+	require.Len(t, rps.previousCounters, 2)
+
+	// Pretend that the previous pull took place 100 seconds ago.
+	for key, counters := range rps.previousCounters {
+		counters.sampledAt = counters.sampledAt.Add(-100 * time.Second)
+		rps.previousCounters[key] = counters
+	}
+
+	err = rps.PacketRatesHandler(dhcp4Daemon, makeResponse("60", "300"), subnetsMap, 4)
+	require.NoError(t, err)
+
+	daemon, err := dbmodel.GetDaemonByID(db, dhcp4Daemon.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 0.5, daemon.KeaDaemon.KeaDHCPDaemon.Stats.NAKRate1)
+	require.EqualValues(t, 0.5, daemon.KeaDaemon.KeaDHCPDaemon.Stats.NAKRate2)
+	require.Zero(t, daemon.KeaDaemon.KeaDHCPDaemon.Stats.DropRate1)
+
+	err = rps.UpdateSubnetLeaseRates()
+	require.NoError(t, err)
+
+	returnedSubnet, err := dbmodel.GetSubnet(db, subnet.ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, returnedSubnet.LeaseRate1)
+	require.EqualValues(t, 2, returnedSubnet.LeaseRate2)
+
+	// Invalid response.
+	err = rps.PacketRatesHandler(dhcp4Daemon, &[]StatisticGetAllResponse{}, subnetsMap, 4)
+	require.Error(t, err)
+}
+
+// Test that the RPS intervals are read from the settings.
+func TestRpsWorkerLoadIntervals(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := dbmodel.InitializeSettings(db, 0)
+	require.NoError(t, err)
+	err = dbmodel.SetSettingInt(db, "rps_interval1", 5)
+	require.NoError(t, err)
+	err = dbmodel.SetSettingInt(db, "rps_interval2", 0)
+	require.NoError(t, err)
+
+	rps, err := NewRpsWorker(db)
+	require.NoError(t, err)
+
+	err = rps.LoadIntervalsIfOutdated()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, rps.Interval1)
+	// The non-positive setting is ignored.
+	require.Equal(t, 24*time.Hour, rps.Interval2)
+
+	// The intervals are not read again until they are invalidated.
+	err = dbmodel.SetSettingInt(db, "rps_interval1", 10)
+	require.NoError(t, err)
+	err = rps.LoadIntervalsIfOutdated()
+	require.NoError(t, err)
+	require.Equal(t, 5*time.Minute, rps.Interval1)
+
+	rps.InvalidateIntervals()
+	err = rps.LoadIntervalsIfOutdated()
+	require.NoError(t, err)
+	require.Equal(t, 10*time.Minute, rps.Interval1)
+}
@@ -0,0 +1,313 @@
+package kea
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"regexp"
+	"strconv"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	keaconfig "isc.org/stork/appcfg/kea"
+	keactrl "isc.org/stork/appctrl/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+)
+
+// Matches the names of the pool statistics returned by Kea, e.g.,
+// subnet[1].pool[0].assigned-addresses or subnet[1].pd-pool[0].total-pds.
+var poolStatisticPattern = regexp.MustCompile(`^subnet\[(\d+)\]\.(pool|pd-pool)\[(\d+)\]\.(.+)$`)
+
+// Represents unmarshaled response from Kea daemon to the statistic-get-all
+// command. Kea returns a list of the value and timestamp pairs of each
+// statistic starting from the most recent one, but only the most recent
+// values are kept.
+type StatisticGetAllResponse struct {
+	keactrl.ResponseHeader
+	Arguments map[string]LatestStatisticValue `json:"arguments,omitempty"`
+}
+
+// The most recent value of a statistic returned in the statistic-get-all
+// response. Kea keeps up to 20 samples of each statistic by default. The
+// older samples are skipped while unmarshaling, so they are not decoded
+// and held in memory for each statistic of each pool. It is empty if Kea
+// returned no samples.
+type LatestStatisticValue json.RawMessage
+
+// Unmarshals the most recent value from the list of the value and
+// timestamp pairs.
+func (v *LatestStatisticValue) UnmarshalJSON(data []byte) error {
+	*v = nil
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	// Open the list of the samples and the most recent sample.
+	for i := 0; i < 2; i++ {
+		token, err := decoder.Token()
+		if err != nil {
+			return errors.Wrap(err, "problem parsing the statistic samples")
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return errors.Errorf("statistic samples are not a list: %s", data)
+		}
+		if !decoder.More() {
+			return nil
+		}
+	}
+	var value json.RawMessage
+	if err := decoder.Decode(&value); err != nil {
+		return errors.Wrap(err, "problem parsing the statistic value")
+	}
+	*v = LatestStatisticValue(value)
+	return nil
+}
+
+// Identifies a pool in the statistics returned by Kea. Kea identifies the
+// pools by their pool-id parameters. The pools lacking the pool-id have
+// the default identifier of 0.
+type poolStatsKey struct {
+	localSubnetID int64
+	pd            bool
+	poolID        int64
+}
+
+// Checks if the daemon returns the per-pool statistics. They are available
+// since Kea 2.4.0.
+func isPoolStatsSupported(daemon *dbmodel.Daemon) bool {
+	version, err := storkutil.ParseSemanticVersion(daemon.Version)
+	if err != nil {
+		return false
+	}
+	return !version.LessThan(storkutil.NewSemanticVersion(2, 4, 0))
+}
+
+// Checks if the daemon has any address or delegated prefix pools in its
+// configuration.
+func hasPools(daemon *dbmodel.Daemon) bool {
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return false
+	}
+	for _, network := range daemon.KeaDaemon.Config.GetSharedNetworks(true) {
+		for _, subnet := range network.GetSubnets() {
+			if len(subnet.GetPools()) > 0 || len(subnet.GetPDPools()) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Checks if the statistic-get-all command should be sent to the daemon.
+// The response is used to calculate the packet rates and to get the pool
+// statistics. The response holds all samples of all statistics, so the
+// command is sent only if the packet rates are tracked or the daemon has
+// pools. It is not sent to the daemons older than Kea 2.4.0 because they
+// don't return the pool statistics.
+func isStatisticGetAllNeeded(daemon *dbmodel.Daemon, packetRates bool) bool {
+	if !isPoolStatsSupported(daemon) {
+		return false
+	}
+	return packetRates || hasPools(daemon)
+}
+
+// Appends the statistic-get-all command for the given daemon to the command
+// list. It returns an instance of the expected response type.
+func statisticGetAllAddCmd(cmds *[]*keactrl.Command, daemons []string) interface{} {
+	*cmds = append(*cmds, &keactrl.Command{
+		Command: "statistic-get-all",
+		Daemons: daemons,
+	})
+	return &[]StatisticGetAllResponse{}
+}
+
+// Groups the pool statistics returned by Kea by the pools. Other statistics
+// are ignored.
+func getPoolStatsFromResponse(response *StatisticGetAllResponse) map[poolStatsKey]dbmodel.SubnetStats {
+	poolStats := make(map[poolStatsKey]dbmodel.SubnetStats)
+	for name, latest := range response.Arguments {
+		match := poolStatisticPattern.FindStringSubmatch(name)
+		if match == nil || len(latest) == 0 {
+			continue
+		}
+		localSubnetID, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		poolID, err := strconv.ParseInt(match[3], 10, 64)
+		if err != nil {
+			continue
+		}
+		value := parseStatisticValue(json.RawMessage(latest))
+		if value == nil {
+			continue
+		}
+		key := poolStatsKey{localSubnetID, match[2] == "pd-pool", poolID}
+		if _, ok := poolStats[key]; !ok {
+			poolStats[key] = dbmodel.SubnetStats{}
+		}
+		poolStats[key][match[4]] = value
+	}
+	return poolStats
+}
+
+// Returns the address pool matching the pool from the Kea configuration.
+func findAddressPool(pools []dbmodel.AddressPool, configPool keaconfig.Pool) *dbmodel.AddressPool {
+	lb, ub, err := configPool.GetBoundaries()
+	if err != nil {
+		return nil
+	}
+	for i := range pools {
+		if pools[i].LowerBound == lb.String() && pools[i].UpperBound == ub.String() {
+			return &pools[i]
+		}
+	}
+	return nil
+}
+
+// Returns the delegated prefix pool matching the pool from the Kea
+// configuration.
+func findPrefixPool(pools []dbmodel.PrefixPool, configPool keaconfig.PDPool) *dbmodel.PrefixPool {
+	_, prefix, err := net.ParseCIDR(configPool.GetCanonicalPrefix())
+	if err != nil {
+		return nil
+	}
+	for i := range pools {
+		if pools[i].Prefix == prefix.String() && pools[i].DelegatedLen == configPool.DelegatedLen {
+			return &pools[i]
+		}
+	}
+	return nil
+}
+
+// Returns the address pool with the specified pool-id from the Kea
+// configuration. It returns false if there is no such pool or several
+// pools share the pool-id. The statistics of the pools sharing the pool-id
+// are aggregated by Kea, so they can't be attributed to any of them.
+func findConfigPoolByID(configPools []keaconfig.Pool, poolID int64) (keaconfig.Pool, bool) {
+	var found []keaconfig.Pool
+	for _, configPool := range configPools {
+		if configPool.PoolID == poolID {
+			found = append(found, configPool)
+		}
+	}
+	if len(found) != 1 {
+		return keaconfig.Pool{}, false
+	}
+	return found[0], true
+}
+
+// Returns the delegated prefix pool with the specified pool-id from the Kea
+// configuration. It returns false if there is no such pool or several
+// pools share the pool-id.
+func findConfigPDPoolByID(configPools []keaconfig.PDPool, poolID int64) (keaconfig.PDPool, bool) {
+	var found []keaconfig.PDPool
+	for _, configPool := range configPools {
+		if configPool.PoolID == poolID {
+			found = append(found, configPool)
+		}
+	}
+	if len(found) != 1 {
+		return keaconfig.PDPool{}, false
+	}
+	return found[0], true
+}
+
+// Process the pool statistics from the statistic-get-all command response
+// for the given daemon. The pools are matched by their pool-id parameters
+// in the subnets in the daemon's configuration. The statistics of the pools
+// sharing the pool-id in a subnet are not stored.
+func (statsPuller *StatsPuller) storeDaemonPoolStats(daemon *dbmodel.Daemon, response interface{}) error {
+	sr, ok := response.(*[]StatisticGetAllResponse)
+	if !ok || len(*sr) == 0 {
+		return errors.Errorf("response is empty: %+v", response)
+	}
+	if (*sr)[0].Arguments == nil {
+		return errors.Errorf("missing arguments from the statistic-get-all response %+v", (*sr)[0])
+	}
+	if daemon.KeaDaemon == nil || daemon.KeaDaemon.Config == nil {
+		return nil
+	}
+
+	poolStats := getPoolStatsFromResponse(&(*sr)[0])
+	if len(poolStats) == 0 {
+		return nil
+	}
+
+	configSubnets := make(map[int64]keaconfig.Subnet)
+	for _, network := range daemon.KeaDaemon.Config.GetSharedNetworks(true) {
+		for _, subnet := range network.GetSubnets() {
+			configSubnets[subnet.GetID()] = subnet
+		}
+	}
+
+	localSubnets, err := dbmodel.GetDaemonLocalSubnetsWithPools(statsPuller.DB, daemon.ID)
+	if err != nil {
+		return err
+	}
+	localSubnetsMap := make(map[int64]*dbmodel.LocalSubnet)
+	for _, lsn := range localSubnets {
+		localSubnetsMap[lsn.LocalSubnetID] = lsn
+	}
+
+	var (
+		addressPools []*dbmodel.AddressPool
+		prefixPools  []*dbmodel.PrefixPool
+	)
+	for key, stats := range poolStats {
+		configSubnet, ok := configSubnets[key.localSubnetID]
+		if !ok {
+			continue
+		}
+		lsn, ok := localSubnetsMap[key.localSubnetID]
+		if !ok {
+			continue
+		}
+		if key.pd {
+			configPool, ok := findConfigPDPoolByID(configSubnet.GetPDPools(), key.poolID)
+			if !ok {
+				continue
+			}
+			if pool := findPrefixPool(lsn.PrefixPools, configPool); pool != nil {
+				pool.SetStats(stats)
+				prefixPools = append(prefixPools, pool)
+			}
+		} else {
+			configPool, ok := findConfigPoolByID(configSubnet.GetPools(), key.poolID)
+			if !ok {
+				continue
+			}
+			if pool := findAddressPool(lsn.AddressPools, configPool); pool != nil {
+				pool.SetStats(stats)
+				addressPools = append(addressPools, pool)
+			}
+		}
+	}
+
+	var lastErr error
+	if err = dbmodel.UpdateAddressPoolsStats(statsPuller.DB, addressPools); err != nil {
+		log.Errorf("Problem updating Kea stats for address pools of daemon ID %d: %s", daemon.ID, err)
+		lastErr = err
+	}
+	if err = dbmodel.UpdatePrefixPoolsStats(statsPuller.DB, prefixPools); err != nil {
+		log.Errorf("Problem updating Kea stats for prefix pools of daemon ID %d: %s", daemon.ID, err)
+		lastErr = err
+	}
+	return lastErr
+}
+
+// Process the statistic-get-all command response for the given daemon. It
+// calculates the packet rates and stores the pool statistics if the daemon
+// returns them.
+func (statsPuller *StatsPuller) storeDaemonAllStats(daemon *dbmodel.Daemon, response interface{}, subnetsMap map[localSubnetKey]*dbmodel.LocalSubnet, family int) error {
+	var lastErr error
+	if statsPuller.RpsWorker != nil {
+		if err := statsPuller.RpsWorker.PacketRatesHandler(daemon, response, subnetsMap, family); err != nil {
+			lastErr = err
+		}
+	}
+	if isPoolStatsSupported(daemon) {
+		if err := statsPuller.storeDaemonPoolStats(daemon, response); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
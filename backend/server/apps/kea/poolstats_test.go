@@ -0,0 +1,194 @@
+package kea
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Test that the pool statistics are pulled only from the daemons
+// supporting them.
+func TestIsPoolStatsSupported(t *testing.T) {
+	require.True(t, isPoolStatsSupported(&dbmodel.Daemon{Version: "2.4.0"}))
+	require.True(t, isPoolStatsSupported(&dbmodel.Daemon{Version: "2.5.1"}))
+	require.False(t, isPoolStatsSupported(&dbmodel.Daemon{Version: "2.3.8"}))
+	require.False(t, isPoolStatsSupported(&dbmodel.Daemon{Version: ""}))
+	require.False(t, isPoolStatsSupported(&dbmodel.Daemon{Version: "foo"}))
+}
+
+// Test that the statistic-get-all command is sent only to the daemons
+// supporting the pool statistics and only if its response is needed.
+func TestIsStatisticGetAllNeeded(t *testing.T) {
+	configWithPools, err := dbmodel.NewKeaConfigFromJSON(`{
+		"Dhcp4": {
+			"subnet4": [
+				{
+					"id": 1,
+					"subnet": "192.0.2.0/24",
+					"pools": [ { "pool": "192.0.2.10-192.0.2.20" } ]
+				}
+			]
+		}
+	}`)
+	require.NoError(t, err)
+	configWithoutPools, err := dbmodel.NewKeaConfigFromJSON(`{
+		"Dhcp4": {
+			"subnet4": [
+				{
+					"id": 1,
+					"subnet": "192.0.2.0/24"
+				}
+			]
+		}
+	}`)
+	require.NoError(t, err)
+
+	makeDaemon := func(version string, config *dbmodel.KeaConfig) *dbmodel.Daemon {
+		return &dbmodel.Daemon{
+			Version: version,
+			KeaDaemon: &dbmodel.KeaDaemon{
+				Config: config,
+			},
+		}
+	}
+
+	require.True(t, isStatisticGetAllNeeded(makeDaemon("2.4.0", configWithPools), false))
+	require.True(t, isStatisticGetAllNeeded(makeDaemon("2.4.0", configWithoutPools), true))
+	require.False(t, isStatisticGetAllNeeded(makeDaemon("2.4.0", configWithoutPools), false))
+	require.False(t, isStatisticGetAllNeeded(makeDaemon("2.4.0", nil), false))
+	require.False(t, isStatisticGetAllNeeded(makeDaemon("2.2.0", configWithPools), true))
+	require.False(t, isStatisticGetAllNeeded(makeDaemon("", configWithPools), true))
+}
+
+// Test that only the most recent value of the statistic is unmarshaled.
+func TestUnmarshalLatestStatisticValue(t *testing.T) {
+	var value LatestStatisticValue
+	err := json.Unmarshal([]byte(`[[3, "2023-05-10 12:00:00.000000"], [2, "2023-05-10 11:00:00.000000"]]`), &value)
+	require.NoError(t, err)
+	require.EqualValues(t, "3", value)
+
+	err = json.Unmarshal([]byte(`[[36893488147419103232, "2023-05-10 12:00:00.000000"]]`), &value)
+	require.NoError(t, err)
+	require.EqualValues(t, "36893488147419103232", value)
+
+	err = json.Unmarshal([]byte(`[]`), &value)
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	err = json.Unmarshal([]byte(`[[]]`), &value)
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	err = json.Unmarshal([]byte(`{"foo": 1}`), &value)
+	require.Error(t, err)
+}
+
+// Test that the pool statistics are grouped by the pools and the other
+// statistics are ignored.
+func TestGetPoolStatsFromResponse(t *testing.T) {
+	response := &StatisticGetAllResponse{}
+	err := json.Unmarshal([]byte(`{
+		"result": 0,
+		"arguments": {
+			"pkt6-received": [[10, "2023-05-10 12:00:00.000000"]],
+			"subnet[1].assigned-nas": [[5, "2023-05-10 12:00:00.000000"]],
+			"subnet[1].pool[0].assigned-nas": [[3, "2023-05-10 12:00:00.000000"], [2, "2023-05-10 11:00:00.000000"]],
+			"subnet[1].pool[0].total-nas": [[16, "2023-05-10 12:00:00.000000"]],
+			"subnet[1].pd-pool[1].total-pds": [[256, "2023-05-10 12:00:00.000000"]],
+			"subnet[1].pool[2].total-nas": []
+		}
+	}`), response)
+	require.NoError(t, err)
+
+	poolStats := getPoolStatsFromResponse(response)
+	require.Len(t, poolStats, 2)
+	require.Equal(t, dbmodel.SubnetStats{
+		"assigned-nas": uint64(3),
+		"total-nas":    uint64(16),
+	}, poolStats[poolStatsKey{1, false, 0}])
+	require.Equal(t, dbmodel.SubnetStats{
+		"total-pds": uint64(256),
+	}, poolStats[poolStatsKey{1, true, 1}])
+}
+
+// Test that the pool statistics are stored in the pools matched by their
+// pool-id parameters in the daemon's configuration.
+func TestStoreDaemonPoolStats(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	v6Config := `{
+		"Dhcp6": {
+			"subnet6": [
+				{
+					"id": 1,
+					"subnet": "2001:db8:1::/64",
+					"pools": [
+						{ "pool": "2001:db8:1::10-2001:db8:1::1f" },
+						{ "pool": "2001:db8:1::100-2001:db8:1::1ff", "pool-id": 3 },
+						{ "pool": "2001:db8:1::200-2001:db8:1::2ff" }
+					],
+					"pd-pools": [
+						{
+							"prefix": "3000::",
+							"prefix-len": 48,
+							"delegated-len": 56
+						}
+					]
+				}
+			]
+		}
+	}`
+	app := createAppWithSubnets(t, db, 0, "", v6Config)
+	err := CommitAppIntoDB(db, app, &storktest.FakeEventCenter{}, nil, dbmodel.NewDHCPOptionDefinitionLookup())
+	require.NoError(t, err)
+
+	sp := &StatsPuller{
+		PeriodicPuller: &agentcomm.PeriodicPuller{DB: db},
+	}
+	response := &[]StatisticGetAllResponse{{
+		Arguments: map[string]LatestStatisticValue{
+			"subnet[1].pool[3].assigned-nas":    LatestStatisticValue("64"),
+			"subnet[1].pool[3].total-nas":       LatestStatisticValue("256"),
+			"subnet[1].pd-pool[0].assigned-pds": LatestStatisticValue("128"),
+			"subnet[1].pd-pool[0].total-pds":    LatestStatisticValue("256"),
+			// Aggregated statistics of the pools without pool-id.
+			"subnet[1].pool[0].total-nas": LatestStatisticValue("272"),
+			// Non-existing pool and subnet.
+			"subnet[1].pool[5].total-nas": LatestStatisticValue("1"),
+			"subnet[9].pool[0].total-nas": LatestStatisticValue("1"),
+		},
+	}}
+	daemon := app.Daemons[1]
+	err = sp.storeDaemonPoolStats(daemon, response)
+	require.NoError(t, err)
+
+	localSubnets, err := dbmodel.GetDaemonLocalSubnetsWithPools(db, daemon.ID)
+	require.NoError(t, err)
+	require.Len(t, localSubnets, 1)
+
+	// The statistics of the pools sharing the default pool-id are not
+	// attributed to any of them.
+	pools := localSubnets[0].AddressPools
+	require.Len(t, pools, 3)
+	require.Nil(t, pools[0].Stats)
+	require.Zero(t, pools[0].Utilization)
+	require.Equal(t, "2001:db8:1::100", pools[1].LowerBound)
+	require.EqualValues(t, 250, pools[1].Utilization)
+	require.EqualValues(t, 64, pools[1].Stats["assigned-nas"])
+	require.Nil(t, pools[2].Stats)
+
+	prefixPools := localSubnets[0].PrefixPools
+	require.Len(t, prefixPools, 1)
+	require.EqualValues(t, 500, prefixPools[0].Utilization)
+	require.NotZero(t, prefixPools[0].StatsCollectedAt)
+
+	// Empty response.
+	err = sp.storeDaemonPoolStats(daemon, &[]StatisticGetAllResponse{})
+	require.Error(t, err)
+}
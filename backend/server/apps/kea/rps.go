@@ -1,6 +1,7 @@
 package kea
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/go-pg/pg/v10"
@@ -17,6 +18,11 @@ type RpsWorker struct {
 	PreviousRps map[int64]StatSample // map of last known values per Daemon
 	Interval1   time.Duration
 	Interval2   time.Duration
+	// Last known packet counters per daemon and subnet.
+	previousCounters map[packetCountersKey]packetCounters
+	// Indicates that the lengths of the intervals must be read from the
+	// settings before they are used.
+	intervalsOutdated atomic.Bool
 }
 
 // Represents a time/value pair.
@@ -80,20 +86,68 @@ func NewRpsWorker(db *pg.DB) (*RpsWorker, error) {
 
 	rpsWorker.db = db
 	rpsWorker.PreviousRps = map[int64]StatSample{}
+	rpsWorker.previousCounters = map[packetCountersKey]packetCounters{}
 
-	// The interval values may some day be configurable
+	// The default interval values. They are overridden by the settings
+	// in LoadIntervals before the first pull.
 	rpsWorker.Interval1 = (time.Minute * 15)
 	rpsWorker.Interval2 = (time.Hour * 24)
+	rpsWorker.intervalsOutdated.Store(true)
 
 	return rpsWorker, nil
 }
 
+// Reads the lengths of the RPS intervals from the settings. The lengths are
+// specified in minutes. The current lengths are kept if the settings are
+// not positive.
+func (rpsWorker *RpsWorker) LoadIntervals() error {
+	interval1, err := dbmodel.GetSettingInt(rpsWorker.db, "rps_interval1")
+	if err != nil {
+		return err
+	}
+	interval2, err := dbmodel.GetSettingInt(rpsWorker.db, "rps_interval2")
+	if err != nil {
+		return err
+	}
+	if interval1 > 0 {
+		rpsWorker.Interval1 = time.Duration(interval1) * time.Minute
+	}
+	if interval2 > 0 {
+		rpsWorker.Interval2 = time.Duration(interval2) * time.Minute
+	}
+	return nil
+}
+
+// Marks the lengths of the RPS intervals as outdated when the settings
+// have been changed. They are read from the settings before the next pull.
+// It is safe to call it concurrently with the pull.
+func (rpsWorker *RpsWorker) InvalidateIntervals() {
+	rpsWorker.intervalsOutdated.Store(true)
+}
+
+// Reads the lengths of the RPS intervals from the settings if they have
+// been invalidated since they were last read. It avoids querying the
+// settings in each pull.
+func (rpsWorker *RpsWorker) LoadIntervalsIfOutdated() error {
+	if !rpsWorker.intervalsOutdated.Swap(false) {
+		return nil
+	}
+	if err := rpsWorker.LoadIntervals(); err != nil {
+		rpsWorker.intervalsOutdated.Store(true)
+		return err
+	}
+	return nil
+}
+
 // Ages off obsolete RPS interval data.
 func (rpsWorker *RpsWorker) AgeOffRpsIntervals() error {
 	// Age off records more than Interval2 old.
 	deleteTime := storkutil.UTCNow().Add(-rpsWorker.Interval2)
 	err := dbmodel.AgeOffRpsInterval(rpsWorker.db, deleteTime)
-	return err
+	if err != nil {
+		return err
+	}
+	return dbmodel.DeletePacketRateIntervalsBefore(rpsWorker.db, deleteTime)
 }
 
 // Appends the statistic-get command for DHCP4 to the given command list. It returns
@@ -230,7 +284,37 @@ func (rpsWorker *RpsWorker) updateDaemonRpsIntervals(daemon *dbmodel.Daemon, sam
 		return errors.WithMessagef(err, "could not extract RPS statistic")
 	}
 
+	return rpsWorker.addDaemonRpsSample(daemon.KeaDaemon.DaemonID, value, sampledAt)
+}
+
+// Uses the value of the statistic sampled by the agent in the past, while the
+// server was unable to pull the statistics, to fill the gap in the RPS intervals
+// of the given daemon. The samples not newer than the last known sample are
+// ignored.
+func (rpsWorker *RpsWorker) BackfillDaemonRpsSample(daemon *dbmodel.Daemon, value int64, sampledAt time.Time) error {
 	daemonID := daemon.KeaDaemon.DaemonID
+	if previous, exist := rpsWorker.PreviousRps[daemonID]; exist {
+		if !sampledAt.After(previous.SampledAt) {
+			return nil
+		}
+	} else {
+		// The server has been restarted. Don't duplicate the intervals
+		// stored before the restart.
+		last, err := dbmodel.GetLastRpsIntervalForDaemon(rpsWorker.db, daemonID)
+		if err != nil {
+			return err
+		}
+		if last != nil && !sampledAt.After(last.StartTime.Add(time.Duration(last.Duration)*time.Second)) {
+			return nil
+		}
+	}
+	return rpsWorker.addDaemonRpsSample(daemonID, value, sampledAt)
+}
+
+// Calculates and stores an RPS interval between the previous sample and the
+// given sample of the statistic for packets sent by the given daemon. The
+// given sample becomes the previous sample.
+func (rpsWorker *RpsWorker) addDaemonRpsSample(daemonID int64, value int64, sampledAt time.Time) (err error) {
 	if value < 0 {
 		// Shouldn't happen but if it does, we'll record a 0.
 		log.Warnf("Discarding response value: %d returned from KeaDaemonID: %d", value, daemonID)
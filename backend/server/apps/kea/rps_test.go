@@ -224,6 +224,57 @@ func TestRpsWorkerValuePermutations(t *testing.T) {
 	}
 }
 
+// Verifies that the samples collected by the agent while the server was
+// unable to pull the statistics fill the gap in the RPS intervals and that
+// the samples overlapping the existing intervals are ignored.
+func TestRpsWorkerBackfillDaemonRpsSample(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	dhcp4Daemon, _ := rpsTestAddMachine(t, db, true, false)
+
+	// The interval stored before the server restart.
+	timeZero := time.Now().UTC().Add(-10 * time.Minute).Round(time.Second)
+	err := dbmodel.AddRpsInterval(db, &dbmodel.RpsInterval{
+		KeaDaemonID: dhcp4Daemon.KeaDaemon.DaemonID,
+		StartTime:   timeZero,
+		Duration:    60,
+		Responses:   100,
+	})
+	require.NoError(t, err)
+
+	rps, err := NewRpsWorker(db)
+	require.NoError(t, err)
+
+	// The sample overlapping the stored interval is ignored.
+	err = rps.BackfillDaemonRpsSample(dhcp4Daemon, 5, timeZero.Add(30*time.Second))
+	require.NoError(t, err)
+	require.Empty(t, rps.PreviousRps)
+
+	// The first sample after the stored interval has no predecessor.
+	err = rps.BackfillDaemonRpsSample(dhcp4Daemon, 10, timeZero.Add(2*time.Minute))
+	require.NoError(t, err)
+	require.Len(t, rps.PreviousRps, 1)
+
+	err = rps.BackfillDaemonRpsSample(dhcp4Daemon, 25, timeZero.Add(3*time.Minute))
+	require.NoError(t, err)
+
+	// The sample older than the previous one is ignored.
+	err = rps.BackfillDaemonRpsSample(dhcp4Daemon, 30, timeZero.Add(3*time.Minute))
+	require.NoError(t, err)
+
+	previous := rps.PreviousRps[dhcp4Daemon.KeaDaemon.DaemonID]
+	require.EqualValues(t, 25, previous.Value)
+	require.Equal(t, timeZero.Add(3*time.Minute).Unix(), previous.SampledAt.Unix())
+
+	rpsIntervals, err := dbmodel.GetAllRpsIntervals(db)
+	require.NoError(t, err)
+	require.Len(t, rpsIntervals, 2)
+	require.Equal(t, timeZero.Add(2*time.Minute).Unix(), rpsIntervals[1].StartTime.Unix())
+	require.EqualValues(t, 60, rpsIntervals[1].Duration)
+	require.EqualValues(t, 15, rpsIntervals[1].Responses)
+}
+
 // Convenience function that creates a machine with one Kea app and two daemons.
 func rpsTestAddMachine(t *testing.T, db *dbops.PgDB, dhcp4Active bool, dhcp6Active bool) (*dbmodel.Daemon, *dbmodel.Daemon) {
 	// add one machine with one kea app
@@ -6,22 +6,50 @@ import (
 	dbmodel "isc.org/stork/server/database/model"
 )
 
-// Checks if the specified Kea daemon belongs to a given HA service.
-// This is done by matching the HA configuration of the given daemon
-// with the HA configurations of the other daemons already associated
-// with the service. In particular, the HA mode must match and for
-// the peers' configurations the server names, URLs and roles must
-// match.
-func daemonBelongsToHAService(daemon *dbmodel.Daemon, service *dbmodel.Service) bool {
+// Checks if two HA relationship configurations describe the same
+// relationship. The HA mode must match and for each peer in the second
+// configuration there must be a peer with the same name, URL and role in
+// the first configuration.
+func haRelationshipsMatch(relationship, other keaconfig.HA) bool {
+	if *relationship.Mode != *other.Mode {
+		return false
+	}
+	for _, otherPeer := range other.Peers {
+		ok := false
+		for _, peer := range relationship.Peers {
+			if (*peer.Name == *otherPeer.Name) &&
+				(*peer.URL == *otherPeer.URL) &&
+				(*peer.Role == *otherPeer.Role) {
+				// Match found.
+				ok = true
+				break
+			}
+		}
+		// Peer not found so the relationships don't match.
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Checks if the specified HA relationship of a Kea daemon is represented
+// by a given HA service. This is done by matching the relationship with
+// the HA configurations of the other daemons already associated with the
+// service. Each of these daemons must have a relationship with the same
+// HA mode and the peers' configurations in which the server names, URLs
+// and roles match. A daemon may have several relationships, e.g., when it
+// is a hub in the hub-and-spoke configuration.
+func daemonBelongsToHAService(daemon *dbmodel.Daemon, relationship keaconfig.HA, service *dbmodel.Service) bool {
 	// If there are no daemons associated with the service, there is
 	// nothing we can compare the daemon's configuration with.
 	if len(service.Daemons) == 0 {
 		return false
 	}
 
-	// Get the daemon's HA configuration and check if it is set.
-	_, daemonConfigHA, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
-	if !ok || !daemonConfigHA.GetFirst().IsValid() {
+	// The service represents another relationship.
+	if service.HAService != nil && service.HAService.Relationship != "" &&
+		service.HAService.Relationship != relationship.GetRelationshipName() {
 		return false
 	}
 
@@ -40,33 +68,25 @@ func daemonBelongsToHAService(daemon *dbmodel.Daemon, service *dbmodel.Service)
 		}
 
 		// Get the HA configuration of the daemon belonging to the service.
-		var serviceDaemonConfigHA keaconfig.HALibraryParams
-		_, serviceDaemonConfigHA, ok = sd.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
-		if !ok || !serviceDaemonConfigHA.GetFirst().IsValid() || (*daemonConfigHA.GetFirst().Mode != *serviceDaemonConfigHA.GetFirst().Mode) {
-			// There is something wrong with the service or the mode is not matching.
-			// This service is not matching.
+		_, serviceDaemonConfigHA, ok := sd.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
+		if !ok {
+			// There is something wrong with the service. This service is not
+			// matching.
 			return false
 		}
 
-		// Now we have to compare the peers' configurations.
-		for _, servicePeer := range serviceDaemonConfigHA.GetFirst().Peers {
-			// For the given peer in the service let's find the corresponding one
-			// specified in the daemons's configuration.
-			ok = false
-			for _, daemonPeer := range daemonConfigHA.GetFirst().Peers {
-				if (*daemonPeer.Name == *servicePeer.Name) &&
-					(*daemonPeer.URL == *servicePeer.URL) &&
-					(*daemonPeer.Role == *servicePeer.Role) {
-					// Match found.
-					ok = true
-					break
-				}
-			}
-			// Peer not found on the URL is not matching.
-			if !ok {
-				return false
+		// One of the relationships of the daemon belonging to the service
+		// must match.
+		ok = false
+		for _, serviceRelationship := range serviceDaemonConfigHA.GetAllValid() {
+			if haRelationshipsMatch(relationship, serviceRelationship) {
+				ok = true
+				break
 			}
 		}
+		if !ok {
+			return false
+		}
 	}
 
 	// Passed all checks that could possibly eliminate the daemon from the service.
@@ -86,6 +106,8 @@ func daemonBelongsToHAService(daemon *dbmodel.Daemon, service *dbmodel.Service)
 // UpdateBaseHAService function using the service returned by this function.
 // Next, AddDaemonToService() should be called to associate the daemon with the
 // service in the database. A single daemon may belong to multiple services.
+// In particular, a service is returned for each HA relationship of a daemon
+// being a hub in the hub-and-spoke configuration.
 func DetectHAServices(dbi dbops.DBI, daemon *dbmodel.Daemon) (services []dbmodel.Service) {
 	// We only detect HA services for DHCP daemons. Other daemons do not support it.
 	if daemon.KeaDaemon == nil || daemon.KeaDaemon.KeaDHCPDaemon == nil || daemon.KeaDaemon.Config == nil {
@@ -93,38 +115,39 @@ func DetectHAServices(dbi dbops.DBI, daemon *dbmodel.Daemon) (services []dbmodel
 	}
 
 	// Check if the configuration contains any HA configuration.
-	if _, params, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary(); ok {
-		// Make sure that the required parameters are set.
-		if !params.GetFirst().IsValid() {
-			return services
-		}
+	_, params, ok := daemon.KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
+	if !ok {
+		return services
+	}
+
+	// Make sure that the required parameters are set.
+	relationships := params.GetAllValid()
+	if len(relationships) == 0 {
+		return services
+	}
+
+	dbServices, _ := dbmodel.GetDetailedAllServices(dbi)
+	// Remember the services matched by the daemon's relationships so the
+	// same service is not returned for two relationships.
+	matched := make(map[int]bool)
 
+	for _, relationship := range relationships {
 		// HA configuration must contain this-server-name parameter which indicates
-		// which of the peers' configurations belongs to it. Let's iterate over
-		// the configured peers to identify the one.
-		index := -1
-		for i, p := range params.GetFirst().Peers {
-			// this-server-name matches one of the peers. Remember which one.
-			if *p.Name == *params.GetFirst().ThisServerName {
-				index = i
-			}
-		}
+		// which of the peers' configurations belongs to it.
+		thisServer := relationship.GetThisServer()
 
 		// This server not found.
-		if index < 0 {
-			return services
+		if thisServer == nil {
+			continue
 		}
 
-		// This server configuration found.
-		thisServer := params.GetFirst().Peers[index]
-
-		// Next, check if there are any existing services matching this daemon.
-		index = -1
-		dbServices, _ := dbmodel.GetDetailedAllServices(dbi)
+		// Next, check if there are any existing services matching this
+		// relationship.
+		index := -1
 		for i, service := range dbServices {
-			if (service.HAService != nil) &&
+			if !matched[i] && (service.HAService != nil) &&
 				(service.HAService.HAType == daemon.Name) &&
-				daemonBelongsToHAService(daemon, &dbServices[i]) {
+				daemonBelongsToHAService(daemon, relationship, &dbServices[i]) {
 				index = i
 				break
 			}
@@ -133,6 +156,7 @@ func DetectHAServices(dbi dbops.DBI, daemon *dbmodel.Daemon) (services []dbmodel
 		var service dbmodel.Service
 		if index >= 0 {
 			// Service found.
+			matched[index] = true
 			service = dbServices[index]
 		} else {
 			// No service found in the db, so let's create one.
@@ -147,7 +171,12 @@ func DetectHAServices(dbi dbops.DBI, daemon *dbmodel.Daemon) (services []dbmodel
 
 		// Set HA mode, if not set yet.
 		if len(service.HAService.HAMode) == 0 {
-			service.HAService.HAMode = *params.GetFirst().Mode
+			service.HAService.HAMode = *relationship.Mode
+		}
+
+		// Set the relationship name, if not set yet.
+		if len(service.HAService.Relationship) == 0 {
+			service.HAService.Relationship = relationship.GetRelationshipName()
 		}
 
 		// Depending on the role of this server we will be setting different column
@@ -392,6 +392,141 @@ func TestDetectHAServices(t *testing.T) {
 	require.Len(t, services[0].Daemons, 3)
 }
 
+// Returns DHCPv4 server configuration with the HA relationships of the
+// hub-and-spoke configuration. The hub has two relationships. In the
+// first relationship the hub is server1 and the spoke is server2. In the
+// second relationship the hub is server3 and the spoke is server4. The
+// thisServerNames parameter selects the relationships configured for the
+// server and the server's name in each of them.
+func getHubAndSpokeTestConfig(thisServerNames ...string) *dbmodel.KeaConfig {
+	relationships := map[string]string{
+		"server1": `"peers": [
+			{ "name": "server1", "url": "http://192.0.2.33:8000", "role": "primary" },
+			{ "name": "server2", "url": "http://192.0.2.66:8000", "role": "secondary" }
+		]`,
+		"server3": `"peers": [
+			{ "name": "server3", "url": "http://192.0.2.33:8001", "role": "primary" },
+			{ "name": "server4", "url": "http://192.0.2.99:8000", "role": "secondary" }
+		]`,
+	}
+	relationships["server2"] = relationships["server1"]
+	relationships["server4"] = relationships["server3"]
+
+	var relationshipsList string
+	for _, thisServerName := range thisServerNames {
+		if len(relationshipsList) > 0 {
+			relationshipsList += ",\n"
+		}
+		relationshipsList += fmt.Sprintf(`{
+			"this-server-name": "%s",
+			"mode": "load-balancing",
+			%s
+		}`, thisServerName, relationships[thisServerName])
+	}
+
+	configStr := fmt.Sprintf(`{
+		"Dhcp4": {
+			"hooks-libraries": [
+				{
+					"library": "libdhcp_ha.so",
+					"parameters": {
+						"high-availability": [ %s ]
+					}
+				}
+			]
+		}
+	}`, relationshipsList)
+
+	var config dbmodel.KeaConfig
+	_ = json.Unmarshal([]byte(configStr), &config)
+	return &config
+}
+
+// Test that a service is detected for each HA relationship in the
+// hub-and-spoke configuration.
+func TestDetectHAServicesHubAndSpoke(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	// Adds an app with a DHCPv4 daemon having the specified HA configuration
+	// and commits the services detected for the daemon.
+	addApp := func(port int64, config *dbmodel.KeaConfig) *dbmodel.Daemon {
+		var accessPoints []*dbmodel.AccessPoint
+		accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "192.0.2.1", "", port, false)
+		app := dbmodel.App{
+			MachineID:    m.ID,
+			Type:         dbmodel.AppTypeKea,
+			AccessPoints: accessPoints,
+			Daemons: []*dbmodel.Daemon{
+				{
+					Name: "dhcp4",
+					KeaDaemon: &dbmodel.KeaDaemon{
+						Config:        config,
+						KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+					},
+				},
+			},
+		}
+		_, err := dbmodel.AddApp(db, &app)
+		require.NoError(t, err)
+		return app.Daemons[0]
+	}
+
+	// The hub has two relationships.
+	hub := addApp(8000, getHubAndSpokeTestConfig("server1", "server3"))
+	services := DetectHAServices(db, hub)
+	require.Len(t, services, 2)
+	require.True(t, services[0].IsNew())
+	require.Equal(t, "server1", services[0].HAService.Relationship)
+	require.Equal(t, hub.ID, services[0].HAService.PrimaryID)
+	require.True(t, services[1].IsNew())
+	require.Equal(t, "server3", services[1].HAService.Relationship)
+	require.Equal(t, hub.ID, services[1].HAService.PrimaryID)
+	err = dbmodel.CommitServicesIntoDB(db, services, hub)
+	require.NoError(t, err)
+
+	// Each spoke belongs to one of the hub's relationships.
+	spoke1 := addApp(8001, getHubAndSpokeTestConfig("server2"))
+	services = DetectHAServices(db, spoke1)
+	require.Len(t, services, 1)
+	require.False(t, services[0].IsNew())
+	require.Equal(t, "server1", services[0].HAService.Relationship)
+	require.Equal(t, spoke1.ID, services[0].HAService.SecondaryID)
+	err = dbmodel.CommitServicesIntoDB(db, services, spoke1)
+	require.NoError(t, err)
+
+	spoke2 := addApp(8002, getHubAndSpokeTestConfig("server4"))
+	services = DetectHAServices(db, spoke2)
+	require.Len(t, services, 1)
+	require.False(t, services[0].IsNew())
+	require.Equal(t, "server3", services[0].HAService.Relationship)
+	require.Equal(t, spoke2.ID, services[0].HAService.SecondaryID)
+	err = dbmodel.CommitServicesIntoDB(db, services, spoke2)
+	require.NoError(t, err)
+
+	dbServices, err := dbmodel.GetDetailedAllServices(db)
+	require.NoError(t, err)
+	require.Len(t, dbServices, 2)
+	for _, service := range dbServices {
+		require.Len(t, service.Daemons, 2)
+		require.Equal(t, hub.ID, service.HAService.PrimaryID)
+	}
+
+	// Running the detection for the hub again returns the existing services.
+	services = DetectHAServices(db, hub)
+	require.Len(t, services, 2)
+	require.False(t, services[0].IsNew())
+	require.False(t, services[1].IsNew())
+	require.NotEqual(t, services[0].ID, services[1].ID)
+}
+
 // Test that a daemon doesn't belong to a blank service , i.e. a
 // service that comprises no daemons.
 func TestAppBelongsToHAServiceBlankService(t *testing.T) {
@@ -425,7 +560,9 @@ func TestAppBelongsToHAServiceBlankService(t *testing.T) {
 	// The daemon doesn't belong to the service because the service includes
 	// no meaningful information to make such determination. In that case
 	// it is up to the administrator to explicitly add the daemon to the service.
-	require.False(t, daemonBelongsToHAService(app.Daemons[0], service))
+	_, params, ok := app.Daemons[0].KeaDaemon.Config.GetHookLibraries().GetHAHookLibrary()
+	require.True(t, ok)
+	require.False(t, daemonBelongsToHAService(app.Daemons[0], *params.GetFirst(), service))
 }
 
 // Test that a daemon can be dissociated with all services it belongs to.
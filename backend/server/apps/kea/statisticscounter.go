@@ -51,6 +51,23 @@ func (g *globalStats) addIPv6Subnet(subnet *subnetIPv6Stats) {
 	g.totalAssignedDelegatedPrefixes.Add(subnet.totalAssignedDelegatedPrefixes)
 }
 
+// Return the global IPv4 address utilization.
+func (g *globalStats) GetIPv4AddressUtilization() float64 {
+	// The assigned addresses include the declined addresses that aren't reclaimed yet.
+	return g.totalAssignedIPv4Addresses.DivideSafeBy(g.totalIPv4Addresses)
+}
+
+// Return the global IPv6 address utilization.
+func (g *globalStats) GetIPv6AddressUtilization() float64 {
+	// The assigned addresses include the declined addresses that aren't reclaimed yet.
+	return g.totalAssignedIPv6Addresses.DivideSafeBy(g.totalIPv6Addresses)
+}
+
+// Return the global delegated prefix utilization.
+func (g *globalStats) GetDelegatedPrefixUtilization() float64 {
+	return g.totalAssignedDelegatedPrefixes.DivideSafeBy(g.totalDelegatedPrefixes)
+}
+
 // General subnet lease statistics.
 // It unifies the IPv4 and IPv6 subnet data.
 type subnetStats interface {
@@ -2,28 +2,55 @@ package kea
 
 import (
 	"context"
+	"encoding/json"
 	"math/big"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	keactrl "isc.org/stork/appctrl/kea"
 	"isc.org/stork/server/agentcomm"
+	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+	storkutil "isc.org/stork/util"
 )
 
+// Matches the names of the subnet address and delegated prefix counters
+// returned by Kea, e.g., subnet[1].assigned-addresses.
+var subnetStatisticPattern = regexp.MustCompile(`^subnet\[(\d+)\]\.((total|assigned|declined)-(addresses|nas|pds))$`)
+
+// Maximum number of the statistics samples fetched from the agent's outbox
+// in a single request.
+const statsBackfillBatchSize = 1000
+
 // Statistics puller is responsible for fetching the data using the Kea
 // statistic hook.
 type StatsPuller struct {
 	*agentcomm.PeriodicPuller
 	*RpsWorker
+	EventCenter eventcenter.EventCenter
+	// IDs of the apps whose statistics were successfully pulled in the
+	// last attempt. The statistics missed by the server are backfilled
+	// from the agent's outbox for the apps that are not in sync.
+	syncedApps map[int64]bool
+	// Sequence numbers of the last samples fetched from the agent's
+	// outbox by app ID.
+	outboxSequences map[int64]uint64
 }
 
 // Create a StatsPuller object that in background pulls Kea stats about leases.
 // Beneath it spawns a goroutine that pulls stats periodically from Kea apps (that are stored in database).
-func NewStatsPuller(db *pg.DB, agents agentcomm.ConnectedAgents) (*StatsPuller, error) {
-	statsPuller := &StatsPuller{}
+func NewStatsPuller(db *pg.DB, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter) (*StatsPuller, error) {
+	statsPuller := &StatsPuller{
+		EventCenter:     eventCenter,
+		syncedApps:      make(map[int64]bool),
+		outboxSequences: make(map[int64]uint64),
+	}
 	periodicPuller, err := agentcomm.NewPeriodicPuller(db, agents, "Kea Stats puller", "kea_stats_puller_interval",
 		statsPuller.pullStats)
 	if err != nil {
@@ -55,12 +82,26 @@ func (statsPuller *StatsPuller) pullStats() error {
 		return err
 	}
 
+	// The RPS intervals may have been changed in the settings.
+	if statsPuller.RpsWorker != nil {
+		if err := statsPuller.RpsWorker.LoadIntervalsIfOutdated(); err != nil {
+			log.WithError(err).Warn("Cannot load the RPS intervals from the settings")
+		}
+	}
+
 	// get lease stats from each kea app
 	var lastErr error
 	appsOkCnt := 0
+	appsCnt := 0
 	for _, dbApp := range dbApps {
+		// Skip the apps the puller backs off from.
+		if !statsPuller.ShouldPullApp(dbApp.ID) {
+			continue
+		}
+		appsCnt++
 		dbApp2 := dbApp
 		err := statsPuller.getStatsFromApp(&dbApp2)
+		statsPuller.ReportAppPullResult(dbApp.ID, err)
 		if err != nil {
 			lastErr = err
 			log.Errorf("Error occurred while getting stats from app %d: %+v", dbApp.ID, err)
@@ -68,7 +109,15 @@ func (statsPuller *StatsPuller) pullStats() error {
 			appsOkCnt++
 		}
 	}
-	log.Printf("Completed pulling lease stats from Kea apps: %d/%d succeeded", appsOkCnt, len(dbApps))
+	log.Printf("Completed pulling lease stats from Kea apps: %d/%d succeeded", appsOkCnt, appsCnt)
+
+	// update lease rates of the subnets and shared networks
+	if statsPuller.RpsWorker != nil {
+		if err := statsPuller.RpsWorker.UpdateSubnetLeaseRates(); err != nil {
+			lastErr = err
+			log.Errorf("Error occurred while updating lease rates of subnets: %+v", err)
+		}
+	}
 
 	// estimate addresses utilization for subnets
 	subnets, err := dbmodel.GetSubnetsWithLocalSubnets(statsPuller.DB)
@@ -80,44 +129,16 @@ func (statsPuller *StatsPuller) pullStats() error {
 		return lastErr
 	}
 
-	counter := newStatisticsCounter()
-
-	// The total IPv4 and IPv6 addresses statistics returned by Kea exclude
-	// out-of-pool reservations, yielding possibly incorrect utilization.
-	// The utilization can be corrected by including the out-of-pool
-	// reservation counts from the Stork database.
-	outOfPoolCounters, err := dbmodel.CountOutOfPoolAddressReservations(statsPuller.DB)
+	corrections, err := getUtilizationCorrections(statsPuller.DB)
 	if err != nil {
 		return err
 	}
-	counter.setOutOfPoolAddresses(outOfPoolCounters)
+	counter := corrections.newStatisticsCounter()
 
-	outOfPoolCounters, err = dbmodel.CountOutOfPoolPrefixReservations(statsPuller.DB)
-	if err != nil {
-		return err
-	}
-	counter.setOutOfPoolPrefixes(outOfPoolCounters)
-
-	// Assume that all global reservations are out-of-pool for all subnets.
-	outOfPoolGlobalIPv4Addresses, outOfPoolGlobalIPv6Addresses, outOfPoolGlobalDelegatedPrefixes, err := dbmodel.CountGlobalReservations(statsPuller.DB)
-	if err != nil {
-		return err
-	}
-
-	counter.global.totalIPv4Addresses.AddUint64(outOfPoolGlobalIPv4Addresses)
-	counter.global.totalIPv6Addresses.AddUint64(outOfPoolGlobalIPv6Addresses)
-	counter.global.totalDelegatedPrefixes.AddUint64(outOfPoolGlobalDelegatedPrefixes)
-
-	// The HA servers share the same lease database and return the same
-	// statistics. The statistics from the passive daemons are excluded from
-	// calculations to avoid counting the same lease multiple times. The
-	// calculator uses only the active daemon statistics because the active
-	// daemon's database is overriding others.
-	excludedDaemons, err := dbmodel.GetPassiveHADaemonIDs(statsPuller.DB)
-	if err != nil {
-		return err
-	}
-	counter.setExcludedDaemons(excludedDaemons)
+	// All utilization samples gathered in this pull share the same time.
+	sampledAt := storkutil.UTCNow()
+	var samples []*dbmodel.UtilizationSample
+	sharedNetworkFamilies := make(map[int64]int8)
 
 	// go through all Subnets and:
 	// 1) estimate utilization per Subnet and per SharedNetwork
@@ -135,6 +156,17 @@ func (statsPuller *StatsPuller) pullStats() error {
 				su.GetAddressUtilization(), su.GetDelegatedPrefixUtilization(), sn.ID, err)
 			continue
 		}
+
+		samples = append(samples, &dbmodel.UtilizationSample{
+			SubnetID:        sn.ID,
+			Family:          int8(sn.GetFamily()),
+			SampledAt:       sampledAt,
+			AddrUtilization: sn.AddrUtilization,
+			PdUtilization:   sn.PdUtilization,
+		})
+		if sn.SharedNetworkID != 0 {
+			sharedNetworkFamilies[sn.SharedNetworkID] = int8(sn.GetFamily())
+		}
 	}
 
 	// shared network utilization
@@ -149,6 +181,36 @@ func (statsPuller *StatsPuller) pullStats() error {
 				u.GetAddressUtilization(), u.GetDelegatedPrefixUtilization(), sharedNetworkID, err)
 			continue
 		}
+
+		samples = append(samples, &dbmodel.UtilizationSample{
+			SharedNetworkID: sharedNetworkID,
+			Family:          sharedNetworkFamilies[sharedNetworkID],
+			SampledAt:       sampledAt,
+			AddrUtilization: int16(u.GetAddressUtilization() * 1000),
+			PdUtilization:   int16(u.GetDelegatedPrefixUtilization() * 1000),
+		})
+	}
+
+	// global utilization per family
+	samples = append(samples,
+		&dbmodel.UtilizationSample{
+			Family:          4,
+			SampledAt:       sampledAt,
+			AddrUtilization: int16(counter.global.GetIPv4AddressUtilization() * 1000),
+		},
+		&dbmodel.UtilizationSample{
+			Family:          6,
+			SampledAt:       sampledAt,
+			AddrUtilization: int16(counter.global.GetIPv6AddressUtilization() * 1000),
+			PdUtilization:   int16(counter.global.GetDelegatedPrefixUtilization() * 1000),
+		},
+	)
+	if err = statsPuller.storeUtilizationSamples(samples, sampledAt); err != nil {
+		lastErr = err
+		log.Errorf("Cannot store utilization history: %+v", err)
+	} else if err = statsPuller.updateExhaustionForecasts(subnets, sampledAt); err != nil {
+		lastErr = err
+		log.Errorf("Cannot update exhaustion forecasts: %+v", err)
 	}
 
 	// global stats to collect
@@ -172,6 +234,100 @@ func (statsPuller *StatsPuller) pullStats() error {
 	return lastErr
 }
 
+// Data from the Stork database used to correct the utilization calculated
+// from the Kea statistics.
+type utilizationCorrections struct {
+	outOfPoolAddresses               map[int64]uint64
+	outOfPoolPrefixes                map[int64]uint64
+	outOfPoolGlobalIPv4Addresses     uint64
+	outOfPoolGlobalIPv6Addresses     uint64
+	outOfPoolGlobalDelegatedPrefixes uint64
+	excludedDaemons                  []int64
+}
+
+// Fetches the data used to correct the utilization from the database.
+func getUtilizationCorrections(dbi dbops.DBI) (*utilizationCorrections, error) {
+	var (
+		corrections utilizationCorrections
+		err         error
+	)
+
+	// The total IPv4 and IPv6 addresses statistics returned by Kea exclude
+	// out-of-pool reservations, yielding possibly incorrect utilization.
+	// The utilization can be corrected by including the out-of-pool
+	// reservation counts from the Stork database.
+	if corrections.outOfPoolAddresses, err = dbmodel.CountOutOfPoolAddressReservations(dbi); err != nil {
+		return nil, err
+	}
+	if corrections.outOfPoolPrefixes, err = dbmodel.CountOutOfPoolPrefixReservations(dbi); err != nil {
+		return nil, err
+	}
+
+	// Assume that all global reservations are out-of-pool for all subnets.
+	corrections.outOfPoolGlobalIPv4Addresses, corrections.outOfPoolGlobalIPv6Addresses, corrections.outOfPoolGlobalDelegatedPrefixes, err = dbmodel.CountGlobalReservations(dbi)
+	if err != nil {
+		return nil, err
+	}
+
+	// The HA servers share the same lease database and return the same
+	// statistics. The statistics from the passive daemons are excluded from
+	// calculations to avoid counting the same lease multiple times. The
+	// calculator uses only the active daemon statistics because the active
+	// daemon's database is overriding others.
+	if corrections.excludedDaemons, err = dbmodel.GetPassiveHADaemonIDs(dbi); err != nil {
+		return nil, err
+	}
+	return &corrections, nil
+}
+
+// Creates the statistics counter applying the corrections.
+func (corrections *utilizationCorrections) newStatisticsCounter() *statisticsCounter {
+	counter := newStatisticsCounter()
+	counter.setOutOfPoolAddresses(corrections.outOfPoolAddresses)
+	counter.setOutOfPoolPrefixes(corrections.outOfPoolPrefixes)
+	counter.global.totalIPv4Addresses.AddUint64(corrections.outOfPoolGlobalIPv4Addresses)
+	counter.global.totalIPv6Addresses.AddUint64(corrections.outOfPoolGlobalIPv6Addresses)
+	counter.global.totalDelegatedPrefixes.AddUint64(corrections.outOfPoolGlobalDelegatedPrefixes)
+	counter.setExcludedDaemons(corrections.excludedDaemons)
+	return counter
+}
+
+// Stores the utilization samples gathered in the current pull, downsamples
+// the samples into the hourly and daily tiers, and removes the samples older
+// than the retention periods configured in the settings. The retention of 0
+// days means that the samples of the given tier are kept forever.
+func (statsPuller *StatsPuller) storeUtilizationSamples(samples []*dbmodel.UtilizationSample, sampledAt time.Time) error {
+	if err := dbmodel.AddUtilizationSamples(statsPuller.DB, samples); err != nil {
+		return err
+	}
+	if err := dbmodel.DownsampleUtilizationSamples(statsPuller.DB, sampledAt); err != nil {
+		return err
+	}
+
+	retentions := []struct {
+		tier    dbmodel.UtilizationTier
+		setting string
+	}{
+		{dbmodel.UtilizationTierRaw, "utilization_raw_retention"},
+		{dbmodel.UtilizationTierHourly, "utilization_hourly_retention"},
+		{dbmodel.UtilizationTierDaily, "utilization_daily_retention"},
+	}
+	for _, retention := range retentions {
+		days, err := dbmodel.GetSettingInt(statsPuller.DB, retention.setting)
+		if err != nil {
+			return err
+		}
+		if days <= 0 {
+			continue
+		}
+		before := sampledAt.Add(-time.Duration(days) * 24 * time.Hour)
+		if _, err = dbmodel.DeleteUtilizationSamplesBefore(statsPuller.DB, retention.tier, before); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Part of response for stat-lease4-get and stat-lease6-get commands.
 type ResultSetInStatLeaseGet struct {
 	Columns []string
@@ -302,6 +458,13 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 					cmdDaemons = append(cmdDaemons, d)
 					responses = append(responses, RpsAddCmd4(&cmds, dhcp4Daemons))
 				}
+
+				// Add daemon, cmd and response for DHCP4 packet rates and
+				// pool stats if they are needed and the daemon supports them.
+				if isStatisticGetAllNeeded(d, statsPuller.RpsWorker != nil) {
+					cmdDaemons = append(cmdDaemons, d)
+					responses = append(responses, statisticGetAllAddCmd(&cmds, dhcp4Daemons))
+				}
 			case dhcp6:
 
 				// Add daemon, cmd and response for DHCP6 lease stats
@@ -319,6 +482,13 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 					cmdDaemons = append(cmdDaemons, d)
 					responses = append(responses, RpsAddCmd6(&cmds, dhcp6Daemons))
 				}
+
+				// Add daemon, cmd and response for DHCP6 packet rates and
+				// pool stats if they are needed and the daemon supports them.
+				if isStatisticGetAllNeeded(d, statsPuller.RpsWorker != nil) {
+					cmdDaemons = append(cmdDaemons, d)
+					responses = append(responses, statisticGetAllAddCmd(&cmds, dhcp6Daemons))
+				}
 			}
 		}
 	}
@@ -336,18 +506,293 @@ func (statsPuller *StatsPuller) getStatsFromApp(dbApp *dbmodel.App) error {
 		serialCmds = append(serialCmds, cmd)
 	}
 	cmdsResult, err := statsPuller.Agents.ForwardToKeaOverHTTP(ctx, dbApp, serialCmds, responses...)
+	if err == nil {
+		err = cmdsResult.Error
+	}
 	if err != nil {
+		delete(statsPuller.syncedApps, dbApp.ID)
 		return err
 	}
 
-	if cmdsResult.Error != nil {
-		return cmdsResult.Error
+	// The server has been restarted or it couldn't pull the stats in the
+	// last attempt. Fill the gap with the samples stored by the agent
+	// before processing the current stats.
+	if !statsPuller.syncedApps[dbApp.ID] {
+		if err := statsPuller.backfillAppStats(dbApp); err != nil {
+			log.WithError(err).WithField("app", dbApp.ID).
+				Warn("Cannot backfill the Kea statistics missed by the server")
+		}
+		statsPuller.syncedApps[dbApp.ID] = true
 	}
 
 	// Process the response for each command for each daemon.
 	return statsPuller.processAppResponses(dbApp, cmds, cmdDaemons, responses)
 }
 
+// Fetches the statistics samples stored in the agent's outbox since the last
+// fetched sample and uses them to fill the gaps in the RPS intervals of the
+// app's DHCP daemons and in the utilization history. The agents with the
+// disabled outbox return no samples.
+func (statsPuller *StatsPuller) backfillAppStats(dbApp *dbmodel.App) error {
+	daemons := make(map[string]*dbmodel.Daemon)
+	for _, d := range dbApp.Daemons {
+		if d.KeaDaemon != nil && d.Active && (d.Name == dhcp4 || d.Name == dhcp6) {
+			daemons[d.Name] = d
+		}
+	}
+
+	statNames := map[string]string{
+		dhcp4: "pkt4-ack-sent",
+		dhcp6: "pkt6-reply-sent",
+	}
+
+	var utilization *utilizationBackfill
+
+	ctx := context.Background()
+	sequence := statsPuller.outboxSequences[dbApp.ID]
+	for {
+		samples, err := statsPuller.Agents.GetKeaStatsSamples(ctx, dbApp, sequence, statsBackfillBatchSize)
+		if err != nil {
+			return err
+		}
+		if samples.LastSequence < sequence {
+			// The outbox has been recreated by the agent. Start over.
+			sequence = 0
+			continue
+		}
+		if utilization == nil && len(samples.Samples) > 0 {
+			// The subnets are fetched only when there is anything to backfill.
+			if utilization, err = newUtilizationBackfill(statsPuller.DB, dbApp); err != nil {
+				return err
+			}
+		}
+		for _, sample := range samples.Samples {
+			sequence = sample.Sequence
+			daemon, ok := daemons[sample.Daemon]
+			if !ok {
+				continue
+			}
+			utilization.addSample(daemon, sample)
+			if statsPuller.RpsWorker == nil {
+				continue
+			}
+			rawValue, ok := sample.Statistics[statNames[sample.Daemon]]
+			if !ok {
+				continue
+			}
+			value, err := rawValue.Int64()
+			if err != nil {
+				log.WithError(err).Warnf("Discarding invalid %s statistic value sampled by the agent", statNames[sample.Daemon])
+				continue
+			}
+			if err = statsPuller.RpsWorker.BackfillDaemonRpsSample(daemon, value, sample.SampledAt); err != nil {
+				statsPuller.outboxSequences[dbApp.ID] = sequence
+				return err
+			}
+		}
+		// Store the utilization samples gathered from the batch.
+		if utilization != nil {
+			if err = utilization.store(statsPuller.DB); err != nil {
+				statsPuller.outboxSequences[dbApp.ID] = sequence
+				return err
+			}
+		}
+		if len(samples.Samples) < statsBackfillBatchSize {
+			break
+		}
+	}
+	statsPuller.outboxSequences[dbApp.ID] = sequence
+
+	// Calculate the utilization for the statistics sampled most recently.
+	if utilization != nil {
+		utilization.calculate()
+		return utilization.store(statsPuller.DB)
+	}
+	return nil
+}
+
+// Converts the statistic value returned by Kea. The values exceeding the
+// int64 range (e.g., the total number of addresses in the large IPv6
+// subnets) are returned as big integers. It returns nil if the value is not
+// an integer.
+func parseStatisticValue(raw json.RawMessage) interface{} {
+	text := string(raw)
+	if value, err := strconv.ParseUint(text, 10, 64); err == nil {
+		return value
+	}
+	if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return value
+	}
+	if value, ok := new(big.Int).SetString(text, 10); ok {
+		return value
+	}
+	return nil
+}
+
+// Calculates the utilization history from the subnet statistics sampled by
+// the agent. The samples are calculated for the subnets of the app's daemons,
+// their shared networks and globally. The statistics of the subnets of other
+// apps are not sampled by this agent, so the ones most recently pulled by the
+// server are used instead. The samples not newer than the utilization history
+// stored by the server are skipped.
+type utilizationBackfill struct {
+	corrections *utilizationCorrections
+	subnets     []*dbmodel.Subnet
+	// The local subnets of the app's daemons by the daemon ID and the local
+	// subnet ID.
+	localSubnets map[int64]map[int64]*dbmodel.LocalSubnet
+	// The IDs of the subnets served by the app's daemons.
+	appSubnets map[int64]bool
+	// The time of the latest sample stored by the server.
+	storedUntil time.Time
+	// The time of the statistics applied to the local subnets but not
+	// calculated yet.
+	pendingAt *time.Time
+	samples   []*dbmodel.UtilizationSample
+}
+
+// Creates the utilization backfill for the app.
+func newUtilizationBackfill(dbi dbops.DBI, dbApp *dbmodel.App) (*utilizationBackfill, error) {
+	subnets, err := dbmodel.GetSubnetsWithLocalSubnets(dbi)
+	if err != nil {
+		return nil, err
+	}
+	corrections, err := getUtilizationCorrections(dbi)
+	if err != nil {
+		return nil, err
+	}
+	daemonIDs := make(map[int64]bool)
+	for _, d := range dbApp.Daemons {
+		daemonIDs[d.ID] = true
+	}
+	backfill := &utilizationBackfill{
+		corrections:  corrections,
+		subnets:      subnets,
+		localSubnets: make(map[int64]map[int64]*dbmodel.LocalSubnet),
+		appSubnets:   make(map[int64]bool),
+	}
+	var subnetIDs []int64
+	for _, sn := range subnets {
+		for _, lsn := range sn.LocalSubnets {
+			if !daemonIDs[lsn.DaemonID] {
+				continue
+			}
+			if _, ok := backfill.localSubnets[lsn.DaemonID]; !ok {
+				backfill.localSubnets[lsn.DaemonID] = make(map[int64]*dbmodel.LocalSubnet)
+			}
+			backfill.localSubnets[lsn.DaemonID][lsn.LocalSubnetID] = lsn
+			if !backfill.appSubnets[sn.ID] {
+				backfill.appSubnets[sn.ID] = true
+				subnetIDs = append(subnetIDs, sn.ID)
+			}
+		}
+	}
+	backfill.storedUntil, err = dbmodel.GetLatestSubnetUtilizationSampleTime(dbi, subnetIDs)
+	if err != nil {
+		return nil, err
+	}
+	return backfill, nil
+}
+
+// Applies the subnet statistics sampled from the daemon to its local
+// subnets. The samples of all daemons of the app taken at the same time are
+// applied before the utilization is calculated.
+func (backfill *utilizationBackfill) addSample(daemon *dbmodel.Daemon, sample *agentcomm.KeaStatsSample) {
+	if !sample.SampledAt.After(backfill.storedUntil) {
+		return
+	}
+	if backfill.pendingAt != nil && !backfill.pendingAt.Equal(sample.SampledAt) {
+		backfill.calculate()
+	}
+	for name, rawValue := range sample.Statistics {
+		match := subnetStatisticPattern.FindStringSubmatch(name)
+		if match == nil {
+			continue
+		}
+		localSubnetID, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		lsn, ok := backfill.localSubnets[daemon.ID][localSubnetID]
+		if !ok {
+			continue
+		}
+		value := parseStatisticValue(json.RawMessage(rawValue))
+		if value == nil {
+			continue
+		}
+		if lsn.Stats == nil {
+			lsn.Stats = dbmodel.SubnetStats{}
+		}
+		lsn.Stats[match[2]] = value
+		sampledAt := sample.SampledAt
+		backfill.pendingAt = &sampledAt
+	}
+}
+
+// Calculates the utilization samples using the statistics applied to the
+// local subnets.
+func (backfill *utilizationBackfill) calculate() {
+	if backfill.pendingAt == nil {
+		return
+	}
+	sampledAt := *backfill.pendingAt
+	backfill.pendingAt = nil
+
+	counter := backfill.corrections.newStatisticsCounter()
+	sharedNetworkFamilies := make(map[int64]int8)
+	for _, sn := range backfill.subnets {
+		// All subnets contribute to the global utilization.
+		su := counter.add(sn)
+		if !backfill.appSubnets[sn.ID] {
+			continue
+		}
+		backfill.samples = append(backfill.samples, &dbmodel.UtilizationSample{
+			SubnetID:        sn.ID,
+			Family:          int8(sn.GetFamily()),
+			SampledAt:       sampledAt,
+			AddrUtilization: int16(su.GetAddressUtilization() * 1000),
+			PdUtilization:   int16(su.GetDelegatedPrefixUtilization() * 1000),
+		})
+		if sn.SharedNetworkID != 0 {
+			sharedNetworkFamilies[sn.SharedNetworkID] = int8(sn.GetFamily())
+		}
+	}
+	for sharedNetworkID, family := range sharedNetworkFamilies {
+		u := counter.sharedNetworks[sharedNetworkID]
+		backfill.samples = append(backfill.samples, &dbmodel.UtilizationSample{
+			SharedNetworkID: sharedNetworkID,
+			Family:          family,
+			SampledAt:       sampledAt,
+			AddrUtilization: int16(u.GetAddressUtilization() * 1000),
+			PdUtilization:   int16(u.GetDelegatedPrefixUtilization() * 1000),
+		})
+	}
+	backfill.samples = append(backfill.samples,
+		&dbmodel.UtilizationSample{
+			Family:          4,
+			SampledAt:       sampledAt,
+			AddrUtilization: int16(counter.global.GetIPv4AddressUtilization() * 1000),
+		},
+		&dbmodel.UtilizationSample{
+			Family:          6,
+			SampledAt:       sampledAt,
+			AddrUtilization: int16(counter.global.GetIPv6AddressUtilization() * 1000),
+			PdUtilization:   int16(counter.global.GetDelegatedPrefixUtilization() * 1000),
+		},
+	)
+}
+
+// Stores the calculated samples in the database. The samples are
+// downsampled and pruned in the next regular pull.
+func (backfill *utilizationBackfill) store(dbi dbops.DBI) error {
+	if err := dbmodel.AddUtilizationSamples(dbi, backfill.samples); err != nil {
+		return err
+	}
+	backfill.samples = nil
+	return nil
+}
+
 // Iterates through the commands for each daemon and processes the command responses
 // Was part of getStatsFromApp() until lint:backend complained about cognitive complexity.
 func (statsPuller *StatsPuller) processAppResponses(dbApp *dbmodel.App, cmds []*keactrl.Command, cmdDaemons []*dbmodel.Daemon, responses []interface{}) error {
@@ -382,6 +827,12 @@ func (statsPuller *StatsPuller) processAppResponses(dbApp *dbmodel.App, cmds []*
 					log.Errorf("Error handling statistic-get (v4) response: %+v", err)
 					lastErr = err
 				}
+			case "statistic-get-all":
+				err = statsPuller.storeDaemonAllStats(cmdDaemons[idx], responses[idx], subnetsMap, 4)
+				if err != nil {
+					log.Errorf("Error handling statistic-get-all (v4) response: %+v", err)
+					lastErr = err
+				}
 			}
 
 		case dhcp6:
@@ -398,6 +849,12 @@ func (statsPuller *StatsPuller) processAppResponses(dbApp *dbmodel.App, cmds []*
 					log.Errorf("Error handling statistic-get (v6) response: %+v", err)
 					lastErr = err
 				}
+			case "statistic-get-all":
+				err = statsPuller.storeDaemonAllStats(cmdDaemons[idx], responses[idx], subnetsMap, 6)
+				if err != nil {
+					log.Errorf("Error handling statistic-get-all (v6) response: %+v", err)
+					lastErr = err
+				}
 			}
 		}
 	}
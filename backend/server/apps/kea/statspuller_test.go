@@ -5,13 +5,16 @@ import (
 	"math"
 	"math/big"
 	"testing"
+	"time"
 
 	"github.com/go-pg/pg/v10"
 	"github.com/stretchr/testify/require"
 	keactrl "isc.org/stork/appctrl/kea"
+	"isc.org/stork/server/agentcomm"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
 )
 
 // Prepares the Kea mock. It accepts list of serialized JSON responses in order:
@@ -354,7 +357,7 @@ func TestStatsPullerBasic(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(nil, nil)
 
 	// Act
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 	defer sp.Shutdown()
 
 	// Assert
@@ -404,7 +407,7 @@ func TestStatsPullerEmptyResponse(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, _ := NewStatsPuller(db, fa)
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 	defer sp.Shutdown()
 
 	// Act
@@ -447,7 +450,7 @@ func checkStatsPullerPullStats(t *testing.T, statsFormat string) {
 	}
 
 	// prepare stats puller
-	sp, _ := NewStatsPuller(db, fa)
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 	defer sp.Shutdown()
 
 	// Act
@@ -496,6 +499,22 @@ func checkStatsPullerPullStats(t *testing.T, statsFormat string) {
 		}
 	}
 
+	// Check the utilization history
+	from := time.Now().UTC().Add(-time.Hour)
+	to := time.Now().UTC().Add(time.Hour)
+	for _, sn := range subnets {
+		samples, err := dbmodel.GetSubnetUtilizationSamples(db, sn.ID, dbmodel.UtilizationTierRaw, from, to)
+		require.NoError(t, err)
+		require.Len(t, samples, 1)
+		require.EqualValues(t, sn.GetFamily(), samples[0].Family)
+		require.Equal(t, sn.AddrUtilization, samples[0].AddrUtilization)
+		require.Equal(t, sn.PdUtilization, samples[0].PdUtilization)
+	}
+	samples, err := dbmodel.GetGlobalUtilizationSamples(db, 4, dbmodel.UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	require.InDelta(t, 2145.0/4358.0, float64(samples[0].AddrUtilization)/1000.0, 0.001)
+
 	// Check global statistics
 	globals, err := dbmodel.GetAllStats(db)
 	require.NoError(t, err)
@@ -560,7 +579,7 @@ func TestGetStatsFromAppWithoutStatCmd(t *testing.T) {
 		},
 	}
 
-	sp, _ := NewStatsPuller(db, fa)
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 
 	// Act
 	err := sp.getStatsFromApp(app)
@@ -920,7 +939,7 @@ func TestPrepareHAEnvironment(t *testing.T) {
 	keaMock := createKeaMock(func(callNo int) (jsons []string) { return []string{} })
 
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 
 	// Assert
 	require.NoError(t, err)
@@ -943,7 +962,7 @@ func TestStatsPullerPullStatsHAPairNotInitializedYet(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -986,7 +1005,7 @@ func TestStatsPullerPullStatsHAPairHealthy(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -1025,7 +1044,7 @@ func TestStatsPullerPullStatsHAPairPrimaryIsDownSecondaryIsReady(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -1064,7 +1083,7 @@ func TestStatsPullerPullStatsHAPairPrimaryIsDownSecondaryIsDown(t *testing.T) {
 	fa := agentcommtest.NewFakeAgents(keaMock, nil)
 
 	// prepare stats puller
-	sp, err := NewStatsPuller(db, fa)
+	sp, err := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
 	require.NoError(t, err)
 	defer sp.Shutdown()
 
@@ -1078,3 +1097,219 @@ func TestStatsPullerPullStatsHAPairPrimaryIsDownSecondaryIsDown(t *testing.T) {
 
 	verifyCountingStatisticsFromPrimary(t, db)
 }
+
+// Test that the statistics samples stored in the agent's outbox are used to
+// fill the gaps in the RPS intervals and that the puller remembers the last
+// fetched sample.
+func TestStatsPullerBackfillAppStats(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	dhcp4Daemon, dhcp6Daemon := rpsTestAddMachine(t, db, true, true)
+	app, err := dbmodel.GetAppByID(db, dhcp4Daemon.AppID)
+	require.NoError(t, err)
+
+	timeZero := time.Now().UTC().Add(-10 * time.Minute).Round(time.Second)
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fa.KeaStatsSamples = &agentcomm.KeaStatsSamples{
+		Samples: []*agentcomm.KeaStatsSample{
+			{
+				Sequence:   1,
+				SampledAt:  timeZero,
+				Daemon:     "dhcp4",
+				Statistics: map[string]json.Number{"pkt4-ack-sent": "10"},
+			},
+			{
+				Sequence:   2,
+				SampledAt:  timeZero,
+				Daemon:     "dhcp6",
+				Statistics: map[string]json.Number{"pkt6-reply-sent": "20"},
+			},
+			{
+				Sequence:   3,
+				SampledAt:  timeZero.Add(time.Minute),
+				Daemon:     "dhcp4",
+				Statistics: map[string]json.Number{"pkt4-ack-sent": "15"},
+			},
+			{
+				Sequence:   4,
+				SampledAt:  timeZero.Add(2 * time.Minute),
+				Daemon:     "dhcp4",
+				Statistics: map[string]json.Number{},
+			},
+		},
+		LastSequence: 4,
+	}
+
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
+	defer sp.Shutdown()
+
+	// Act
+	err = sp.backfillAppStats(app)
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 0, fa.RecordedSinceSequence)
+	require.EqualValues(t, 4, sp.outboxSequences[app.ID])
+
+	rpsIntervals, err := dbmodel.GetAllRpsIntervals(db)
+	require.NoError(t, err)
+	require.Len(t, rpsIntervals, 1)
+	require.EqualValues(t, dhcp4Daemon.KeaDaemon.DaemonID, rpsIntervals[0].KeaDaemonID)
+	require.Equal(t, timeZero.Unix(), rpsIntervals[0].StartTime.Unix())
+	require.EqualValues(t, 60, rpsIntervals[0].Duration)
+	require.EqualValues(t, 5, rpsIntervals[0].Responses)
+	require.Contains(t, sp.PreviousRps, dhcp6Daemon.KeaDaemon.DaemonID)
+
+	// Act
+	// The next backfill continues from the last fetched sample.
+	err = sp.backfillAppStats(app)
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 4, fa.RecordedSinceSequence)
+
+	// Act
+	// The agent's outbox has been recreated.
+	fa.KeaStatsSamples.Samples = fa.KeaStatsSamples.Samples[:1]
+	fa.KeaStatsSamples.LastSequence = 1
+	err = sp.backfillAppStats(app)
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 0, fa.RecordedSinceSequence)
+	require.EqualValues(t, 1, sp.outboxSequences[app.ID])
+
+	// The samples older than the known ones are ignored.
+	rpsIntervals, err = dbmodel.GetAllRpsIntervals(db)
+	require.NoError(t, err)
+	require.Len(t, rpsIntervals, 1)
+}
+
+// Test that the statistic values of various sizes are parsed.
+func TestParseStatisticValue(t *testing.T) {
+	require.Equal(t, uint64(42), parseStatisticValue(json.RawMessage("42")))
+	require.Equal(t, int64(-1), parseStatisticValue(json.RawMessage("-1")))
+	expected, _ := new(big.Int).SetString("36893488147419103232", 10)
+	require.Equal(t, expected, parseStatisticValue(json.RawMessage("36893488147419103232")))
+	require.Nil(t, parseStatisticValue(json.RawMessage(`"2023-05-10 12:00:00.000000"`)))
+}
+
+// Test that the subnet statistics stored in the agent's outbox are used to
+// fill the gaps in the subnet, shared network and global utilization
+// history.
+func TestStatsPullerBackfillUtilization(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	v4Config := `{
+		"Dhcp4": {
+			"shared-networks": [
+				{
+					"name": "frog",
+					"subnet4": [
+						{
+							"id": 10,
+							"subnet": "192.0.2.0/24"
+						}
+					]
+				}
+			]
+		}
+	}`
+	app := createAppWithSubnets(t, db, 0, v4Config, "")
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	sharedNetworks, subnets, err := detectDaemonNetworks(db, app.Daemons[0], lookup)
+	require.NoError(t, err)
+	_, err = dbmodel.CommitNetworksIntoDB(db, sharedNetworks, subnets)
+	require.NoError(t, err)
+	dbSubnets, err := dbmodel.GetSubnetsWithLocalSubnets(db)
+	require.NoError(t, err)
+	require.Len(t, dbSubnets, 1)
+	subnet := dbSubnets[0]
+
+	// The server has stored the utilization before it lost the connection.
+	timeZero := time.Now().UTC().Add(-10 * time.Minute).Round(time.Second)
+	err = dbmodel.AddUtilizationSamples(db, []*dbmodel.UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: timeZero, AddrUtilization: 100},
+	})
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fa.KeaStatsSamples = &agentcomm.KeaStatsSamples{
+		Samples: []*agentcomm.KeaStatsSample{
+			{
+				// This sample is already covered by the stored history.
+				Sequence:  1,
+				SampledAt: timeZero,
+				Daemon:    "dhcp4",
+				Statistics: map[string]json.Number{
+					"subnet[10].total-addresses":    "256",
+					"subnet[10].assigned-addresses": "10",
+				},
+			},
+			{
+				Sequence:  2,
+				SampledAt: timeZero.Add(time.Minute),
+				Daemon:    "dhcp4",
+				Statistics: map[string]json.Number{
+					"subnet[10].total-addresses":    "256",
+					"subnet[10].assigned-addresses": "64",
+					// Unknown subnet.
+					"subnet[20].assigned-addresses": "128",
+				},
+			},
+			{
+				Sequence:  3,
+				SampledAt: timeZero.Add(2 * time.Minute),
+				Daemon:    "dhcp4",
+				Statistics: map[string]json.Number{
+					"subnet[10].total-addresses":    "256",
+					"subnet[10].assigned-addresses": "128",
+				},
+			},
+		},
+		LastSequence: 3,
+	}
+
+	sp, _ := NewStatsPuller(db, fa, &storktest.FakeEventCenter{})
+	defer sp.Shutdown()
+
+	// Act
+	err = sp.backfillAppStats(app)
+
+	// Assert
+	require.NoError(t, err)
+
+	from := timeZero.Add(-time.Hour)
+	to := timeZero.Add(time.Hour)
+	samples, err := dbmodel.GetSubnetUtilizationSamples(db, subnet.ID, dbmodel.UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Len(t, samples, 3)
+	require.EqualValues(t, 100, samples[0].AddrUtilization)
+	require.WithinDuration(t, timeZero.Add(time.Minute), samples[1].SampledAt, 0)
+	require.EqualValues(t, 250, samples[1].AddrUtilization)
+	require.WithinDuration(t, timeZero.Add(2*time.Minute), samples[2].SampledAt, 0)
+	require.EqualValues(t, 500, samples[2].AddrUtilization)
+
+	samples, err = dbmodel.GetSharedNetworkUtilizationSamples(db, subnet.SharedNetworkID, dbmodel.UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	require.EqualValues(t, 250, samples[0].AddrUtilization)
+	require.EqualValues(t, 500, samples[1].AddrUtilization)
+
+	samples, err = dbmodel.GetGlobalUtilizationSamples(db, 4, dbmodel.UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Len(t, samples, 2)
+	require.EqualValues(t, 250, samples[0].AddrUtilization)
+	require.EqualValues(t, 500, samples[1].AddrUtilization)
+
+	// The RPS intervals are not backfilled without the response counters.
+	rpsIntervals, err := dbmodel.GetAllRpsIntervals(db)
+	require.NoError(t, err)
+	require.Empty(t, rpsIntervals)
+}
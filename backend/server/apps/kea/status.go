@@ -18,9 +18,10 @@ import (
 // Represents the status of the local server (the one that
 // responded to the command).
 type HALocalStatus struct {
-	Role   string
-	Scopes []string
-	State  string
+	Role       string
+	Scopes     []string
+	State      string
+	ServerName string `json:"server-name"`
 }
 
 // Represents the status of the remote server.
@@ -35,6 +36,7 @@ type HARemoteStatus struct {
 	UnackedClients     int64    `json:"unacked-clients"`
 	UnackedClientsLeft int64    `json:"unacked-clients-left"`
 	AnalyzedPackets    int64    `json:"analyzed-packets"`
+	ServerName         string   `json:"server-name"`
 }
 
 // Represents the status of the HA enabled Kea servers.
@@ -43,6 +45,20 @@ type HAServersStatus struct {
 	Remote HARemoteStatus
 }
 
+// Returns the name of the primary server in the HA relationship. It
+// identifies the relationship. The server names are returned by Kea 2.4.0
+// and later. The empty string is returned if the name is unknown.
+func (s HAServersStatus) GetRelationshipName() string {
+	switch {
+	case s.Local.Role == "primary":
+		return s.Local.ServerName
+	case s.Remote.Role == "primary":
+		return s.Remote.ServerName
+	default:
+		return ""
+	}
+}
+
 // Represent a status of a single HA relationship encapsulated in the
 // high-availability list of a status-get response.
 type HARelationshipStatus struct {
@@ -80,6 +96,19 @@ type daemonStatus struct {
 	Daemon string
 }
 
+// Returns the status of each HA relationship of the daemon regardless of
+// the format of the status-get response.
+func (s daemonStatus) getHARelationships() (relationships []HAServersStatus) {
+	if len(s.HA) > 0 {
+		for _, relationship := range s.HA {
+			relationships = append(relationships, relationship.HAServers)
+		}
+	} else if s.HAServers != nil {
+		relationships = append(relationships, *s.HAServers)
+	}
+	return relationships
+}
+
 // Holds the status of the Kea app.
 type appStatus []daemonStatus
 
@@ -219,7 +248,11 @@ func updateHAServiceStatus(status *HAServersStatus, daemon *dbmodel.Daemon, serv
 }
 
 // Iterates over the slice of HA services and updates them in the database.
-func (puller *HAStatusPuller) commitHAServicesStatus(appID int64, services []dbmodel.Service) {
+// The state transitions and the changes of the failover counters since the
+// previous version of the service are stored in the HA status history.
+// The previous versions of the services are indexed by the HA service ID.
+func (puller *HAStatusPuller) commitHAServicesStatus(appID int64, services []dbmodel.Service, previous map[int64]dbmodel.BaseHAService) {
+	now := storkutil.UTCNow()
 	for i := range services {
 		// Update the information about the HA service in the database.
 		err := dbmodel.UpdateBaseHAService(puller.DB, services[i].HAService)
@@ -228,6 +261,15 @@ func (puller *HAStatusPuller) commitHAServicesStatus(appID int64, services []dbm
 
 			continue
 		}
+		previousService, ok := previous[services[i].HAService.ID]
+		if !ok {
+			continue
+		}
+		transitions, samples := services[i].HAService.GetStatusChanges(&previousService, now)
+		err = dbmodel.AddHAStatusHistory(puller.DB, transitions, samples)
+		if err != nil {
+			log.Errorf("Error occurred while updating HA status history for Kea app %d: %+v", appID, err)
+		}
 	}
 }
 
@@ -245,33 +287,60 @@ func (puller *HAStatusPuller) pullData() error {
 	appsOkCnt := 0
 	appsCnt := 0
 	for i := range apps {
-		pulled, ok := puller.pullDataForApp(&apps[i])
+		// Skip the apps the puller backs off from.
+		if !puller.ShouldPullApp(apps[i].ID) {
+			continue
+		}
+		pulled, err := puller.pullDataForApp(&apps[i])
 		if pulled {
 			appsCnt++
-		}
-		if ok {
-			appsOkCnt++
+			puller.ReportAppPullResult(apps[i].ID, err)
+			if err == nil {
+				appsOkCnt++
+			}
 		}
 	}
 	log.Printf("Completed pulling DHCP status from Kea apps: %d/%d succeeded", appsOkCnt, appsCnt)
 
+	if err = puller.deleteOutdatedHAStatusHistory(); err != nil {
+		log.Errorf("Error occurred while deleting outdated HA status history: %+v", err)
+	}
+
 	return lastErr
 }
 
+// Removes the HA state transitions and the samples of the HA counters
+// older than the retention period configured in the settings. The
+// retention of 0 days means that the history is kept forever.
+func (puller *HAStatusPuller) deleteOutdatedHAStatusHistory() error {
+	days, err := dbmodel.GetSettingInt(puller.DB, "ha_status_history_retention")
+	if err != nil {
+		return err
+	}
+	if days <= 0 {
+		return nil
+	}
+	before := storkutil.UTCNow().Add(-time.Duration(days) * 24 * time.Hour)
+	_, err = dbmodel.DeleteHAStatusHistoryBefore(puller.DB, before)
+	return err
+}
+
 // Gets the status of a Kea app and stores useful information in the database.
 // The High Availability status is stored in the database for those apps which
-// have the HA enabled.
-func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
+// have the HA enabled. The first returned value indicates whether the puller
+// attempted to get the status from the app. The error is returned if the
+// attempt failed.
+func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, error) {
 	// Before contacting the DHCP server, let's check if there is any service
 	// the app belongs to.
 	dbServices, err := dbmodel.GetDetailedServicesByAppID(puller.DB, app.ID)
 	if err != nil {
 		log.Errorf("Error while getting services for Kea app %d: %+v", app.ID, err)
-		return false, false
+		return false, err
 	}
 	// No services for this app, so nothing to do.
 	if len(dbServices) == 0 {
-		return false, false
+		return false, nil
 	}
 
 	// Pick only those services for the app that have the HA type. At the
@@ -279,10 +348,13 @@ func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
 	// command. These values will indicate that we can't say what is happening
 	// with the server we failed to connect to.
 	var haServices []dbmodel.Service
+	previous := make(map[int64]dbmodel.BaseHAService)
 	for j := range dbServices {
 		if dbServices[j].HAService == nil {
 			continue
 		}
+		// Remember the stored status to record the changes in the history.
+		previous[dbServices[j].HAService.ID] = *dbServices[j].HAService
 		for _, d := range app.Daemons {
 			switch d.ID {
 			case dbServices[j].HAService.PrimaryID:
@@ -294,8 +366,8 @@ func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
 				dbServices[j].HAService.SecondaryLastScopes = []string{}
 				dbServices[j].HAService.SecondaryReachable = false
 			}
-			haServices = append(haServices, dbServices[j])
 		}
+		haServices = append(haServices, dbServices[j])
 	}
 
 	ctx := context.Background()
@@ -304,39 +376,26 @@ func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
 	if err != nil {
 		log.Errorf("Error occurred while getting Kea app %d status: %+v", app.ID, err)
 
-		return true, false
+		return true, err
 	}
 	// Go over the returned status values and match with the daemons.
 	for _, status := range appStatus {
-		// If no HA status, there is nothing to do.
-		if status.HAServers == nil && len(status.HA) == 0 {
-			continue
-		}
-		// Find the matching service for the returned status.
-		index := -1
-
-		for i := range haServices {
-			if haServices[i].HAService.HAType == status.Daemon {
-				index = i
+		// A daemon may have several HA relationships, e.g., when it is a hub
+		// in the hub-and-spoke configuration. Each relationship is represented
+		// by a separate service. If no HA status, there is nothing to do.
+		relationships := status.getHARelationships()
+		for i := range relationships {
+			// Find the matching service for the returned status.
+			index := findHAServiceForStatus(haServices, status.Daemon, relationships[i], len(relationships) == 1)
+			if index < 0 {
+				continue
 			}
-		}
-		if index < 0 {
-			continue
-		}
-		service := haServices[index].HAService
-		for _, daemon := range app.Daemons {
-			// Update the HA service status only if the given server is primary
-			// or secondary.
-			if service.PrimaryID == daemon.ID || service.SecondaryID == daemon.ID {
-				// todo: Currently Kea supports only one HA service per daemon. This
-				// will change but for now it is safe to assume that only one status
-				// is returned. Supporting more requires some mechanisms to
-				// distinguish the HA relationships which should be first designed
-				// on the Kea side.
-				if len(status.HA) > 0 {
-					updateHAServiceStatus(&status.HA[0].HAServers, daemon, service)
-				} else if status.HAServers != nil {
-					updateHAServiceStatus(status.HAServers, daemon, service)
+			service := haServices[index].HAService
+			for _, daemon := range app.Daemons {
+				// Update the HA service status only if the given server is primary
+				// or secondary.
+				if service.PrimaryID == daemon.ID || service.SecondaryID == daemon.ID {
+					updateHAServiceStatus(&relationships[i], daemon, service)
 				}
 			}
 		}
@@ -344,8 +403,30 @@ func (puller *HAStatusPuller) pullDataForApp(app *dbmodel.App) (bool, bool) {
 
 	// Update the services as appropriate regardless if we successfully communicated
 	// with the servers or not.
-	puller.commitHAServicesStatus(app.ID, haServices)
-	return true, true
+	puller.commitHAServicesStatus(app.ID, haServices, previous)
+	return true, nil
+}
+
+// Returns the index of the HA service matching the status of the HA
+// relationship returned by the daemon, or -1 if no service matches. The
+// services are matched by the relationship name if the status includes the
+// server names. Otherwise, the service of the daemon's type is returned
+// if the daemon has a single relationship.
+func findHAServiceForStatus(services []dbmodel.Service, daemonName string, status HAServersStatus, single bool) int {
+	name := status.GetRelationshipName()
+	index := -1
+	for i := range services {
+		if services[i].HAService.HAType != daemonName {
+			continue
+		}
+		if name != "" && services[i].HAService.Relationship == name {
+			return i
+		}
+		if single && index < 0 {
+			index = i
+		}
+	}
+	return index
 }
 
 // Sends the status-get command to Kea DHCP servers and returns this status to the caller.
@@ -3,6 +3,7 @@ package kea
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	keactrl "isc.org/stork/appctrl/kea"
@@ -678,8 +679,32 @@ func testPullHAStatus(t *testing.T, version178 bool) {
 	require.False(t, service.HAService.PrimaryLastFailoverAt.IsZero())
 	require.True(t, service.HAService.SecondaryLastFailoverAt.IsZero())
 
+	// The state transitions of both servers should have been recorded in
+	// the history.
+	transitions, err := dbmodel.GetHAStateTransitions(db, service.ID, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, transitions, 4)
+	require.Empty(t, transitions[0].PreviousState)
+	require.Equal(t, "load-balancing", transitions[0].State)
+	require.Equal(t, "load-balancing", transitions[2].PreviousState)
+	require.ElementsMatch(t, []string{"partner-down", "unavailable"},
+		[]string{transitions[2].State, transitions[3].State})
+
+	// The primary server is in the failover.
+	failovers := dbmodel.GetHAFailovers(transitions)
+	require.Len(t, failovers, 1)
+	require.EqualValues(t, service.HAService.PrimaryID, failovers[0].DaemonID)
+	require.Zero(t, failovers[0].EndedAt)
+
 	// These fields are only available in Kea 1.7.8+.
 	if version178 {
+		// The changes of the failover counters should have been recorded.
+		samples, err := dbmodel.GetHAClientsSamples(db, service.ID, time.Time{})
+		require.NoError(t, err)
+		require.Len(t, samples, 2)
+		require.EqualValues(t, 2, samples[0].UnackedClients)
+		require.Zero(t, samples[1].UnackedClients)
+
 		require.NotNil(t, service.HAService.SecondaryCommInterrupted)
 		require.True(t, *service.HAService.SecondaryCommInterrupted)
 		// In the partner-down state they should be all reset.
@@ -726,3 +751,159 @@ func TestPullHAStatus(t *testing.T) {
 func TestPullHAStatus178(t *testing.T) {
 	testPullHAStatus(t, true)
 }
+
+// Generates a response to the status-get command returned by the hub in
+// the hub-and-spoke configuration. The hub has two HA relationships.
+func mockGetStatusHubAndSpoke(callNo int, cmdResponses []interface{}) {
+	command := keactrl.NewCommand("status-get", []string{"dhcp4"}, nil)
+	json := `[
+        {
+            "result": 0,
+            "text": "Everything is fine",
+            "arguments": {
+                "pid": 1234,
+                "uptime": 3024,
+                "reload": 1111,
+                "high-availability": [
+                    {
+                        "ha-mode": "load-balancing",
+                        "ha-servers": {
+                            "local": {
+                                "role": "primary",
+                                "scopes": [ "server1" ],
+                                "state": "load-balancing",
+                                "server-name": "server1"
+                            },
+                            "remote": {
+                                "age": 10,
+                                "in-touch": true,
+                                "role": "secondary",
+                                "last-scopes": [ "server2" ],
+                                "last-state": "load-balancing",
+                                "server-name": "server2"
+                            }
+                        }
+                    },
+                    {
+                        "ha-mode": "load-balancing",
+                        "ha-servers": {
+                            "local": {
+                                "role": "primary",
+                                "scopes": [ "server3", "server4" ],
+                                "state": "partner-down",
+                                "server-name": "server3"
+                            },
+                            "remote": {
+                                "age": 0,
+                                "in-touch": false,
+                                "role": "secondary",
+                                "last-scopes": [ ],
+                                "last-state": "unavailable",
+                                "server-name": "server4"
+                            }
+                        }
+                    }
+                ]
+            }
+        }
+    ]`
+	_ = keactrl.UnmarshalResponseList(command, []byte(json), cmdResponses[0])
+}
+
+// Test that the status of each HA relationship of the hub in the
+// hub-and-spoke configuration is stored in a separate service.
+func TestPullHAStatusHubAndSpoke(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	var keaPoints []*dbmodel.AccessPoint
+	keaPoints = dbmodel.AppendAccessPoint(keaPoints, dbmodel.AccessPointControl, "", "", 1234, false)
+	keaApp := &dbmodel.App{
+		MachineID:    m.ID,
+		Machine:      m,
+		Type:         dbmodel.AppTypeKea,
+		Active:       true,
+		AccessPoints: keaPoints,
+		Daemons: []*dbmodel.Daemon{
+			{
+				Name:   "dhcp4",
+				Active: true,
+				KeaDaemon: &dbmodel.KeaDaemon{
+					Config:        getHubAndSpokeTestConfig("server1", "server3"),
+					KeaDHCPDaemon: &dbmodel.KeaDHCPDaemon{},
+				},
+			},
+		},
+	}
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	err = CommitAppIntoDB(db, keaApp, fec, nil, lookup)
+	require.NoError(t, err)
+
+	err = dbmodel.InitializeSettings(db, 0)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(mockGetStatusHubAndSpoke, nil)
+	puller, err := NewHAStatusPuller(db, fa)
+	require.NoError(t, err)
+	defer puller.Shutdown()
+
+	err = puller.pullData()
+	require.NoError(t, err)
+
+	services, err := dbmodel.GetDetailedAllServices(db)
+	require.NoError(t, err)
+	require.Len(t, services, 2)
+
+	for _, service := range services {
+		require.NotNil(t, service.HAService)
+		switch service.HAService.Relationship {
+		case "server1":
+			require.Equal(t, "load-balancing", service.HAService.PrimaryLastState)
+			require.Equal(t, "load-balancing", service.HAService.SecondaryLastState)
+			require.ElementsMatch(t, []string{"server1"}, service.HAService.PrimaryLastScopes)
+		case "server3":
+			require.Equal(t, "partner-down", service.HAService.PrimaryLastState)
+			require.Equal(t, "unavailable", service.HAService.SecondaryLastState)
+			require.ElementsMatch(t, []string{"server3", "server4"}, service.HAService.PrimaryLastScopes)
+		default:
+			require.Fail(t, "unexpected relationship", service.HAService.Relationship)
+		}
+	}
+}
+
+// Test that the HA service matching the status of the relationship is
+// found by the relationship name or by the daemon name.
+func TestFindHAServiceForStatus(t *testing.T) {
+	services := []dbmodel.Service{
+		{HAService: &dbmodel.BaseHAService{HAType: "dhcp6", Relationship: "server1"}},
+		{HAService: &dbmodel.BaseHAService{HAType: "dhcp4", Relationship: "server1"}},
+		{HAService: &dbmodel.BaseHAService{HAType: "dhcp4", Relationship: "server3"}},
+	}
+	status := HAServersStatus{
+		Local: HALocalStatus{
+			Role:       "secondary",
+			ServerName: "server4",
+		},
+		Remote: HARemoteStatus{
+			Role:       "primary",
+			ServerName: "server3",
+		},
+	}
+	require.Equal(t, "server3", status.GetRelationshipName())
+	require.Equal(t, 2, findHAServiceForStatus(services, "dhcp4", status, false))
+
+	// The server names are not returned by older Kea versions.
+	status = HAServersStatus{}
+	require.Equal(t, 1, findHAServiceForStatus(services, "dhcp4", status, true))
+	require.Equal(t, -1, findHAServiceForStatus(services, "dhcp4", status, false))
+	require.Equal(t, -1, findHAServiceForStatus(services, "dhcp6", HAServersStatus{}, false))
+}
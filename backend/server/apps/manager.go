@@ -355,17 +355,20 @@ func (manager *configManagerImpl) Commit(ctx context.Context) (context.Context,
 }
 
 // Commit all configuration changes in the database which are due, i.e. for which
-// the deadline_at time expired.
-func (manager *configManagerImpl) CommitDue() error {
+// the deadline_at time expired. It returns the IDs of the executed changes,
+// including the ones that failed. If an error occurs, the IDs of the changes
+// executed before the error are returned.
+func (manager *configManagerImpl) CommitDue() ([]int64, error) {
 	// Get due configuration changes.
 	changes, err := dbmodel.GetDueConfigChanges(manager.GetDB())
 	if err != nil {
-		return err
+		return nil, err
 	}
 	// Nothing to do.
 	if len(changes) == 0 {
-		return nil
+		return nil, nil
 	}
+	var executedIDs []int64
 	// Iterate over the changes.
 	for _, change := range changes {
 		var state any
@@ -396,6 +399,7 @@ func (manager *configManagerImpl) CommitDue() error {
 			ctx = context.WithValue(ctx, config.StateContextKey, state)
 			// Commit the changes in the monitored daemons.
 			_, err = manager.Commit(ctx)
+			manager.Done(ctx)
 		}
 		var errtext string
 		if err != nil {
@@ -403,10 +407,11 @@ func (manager *configManagerImpl) CommitDue() error {
 		}
 		// Mark the current config change as executed.
 		if err = dbmodel.SetScheduledConfigChangeExecuted(manager.GetDB(), change.ID, errtext); err != nil {
-			return err
+			return executedIDs, err
 		}
+		executedIDs = append(executedIDs, change.ID)
 	}
-	return nil
+	return executedIDs, nil
 }
 
 // Schedules sending the changes queued in the context to one or multiple daemons.
@@ -478,8 +478,9 @@ func TestCommitDue(t *testing.T) {
 		require.NoError(t, err)
 	}
 	// Commit due changes.
-	err = manager.CommitDue()
+	executedIDs, err := manager.CommitDue()
 	require.NoError(t, err)
+	require.Len(t, executedIDs, 2)
 	require.Len(t, fkm.ops, 2)
 	// The changes should be ordered by deadline.
 	require.Equal(t, "kea.config_edit", fkm.ops[0])
@@ -559,8 +560,10 @@ func TestCommitDueErrors(t *testing.T) {
 		require.NoError(t, err)
 	}
 	// Commit due changes.
-	err = manager.CommitDue()
+	executedIDs, err := manager.CommitDue()
 	require.NoError(t, err)
+	// The failed changes are also executed.
+	require.Len(t, executedIDs, 2)
 
 	// The changes should have been marked as executed.
 	returned, err := dbmodel.GetScheduledConfigChanges(db)
@@ -644,8 +647,9 @@ func TestCommitDueNoChanges(t *testing.T) {
 	fkm := newFakeKeaModuleCommit()
 	impl.keaCommit = fkm
 
-	err := manager.CommitDue()
+	executedIDs, err := manager.CommitDue()
 	require.NoError(t, err)
+	require.Empty(t, executedIDs)
 	require.Empty(t, fkm.ops)
 }
 
@@ -0,0 +1,114 @@
+package apps
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	log "github.com/sirupsen/logrus"
+	"isc.org/stork/server/config"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// The maximum time the scheduler waits before checking for the due
+// config changes. The scheduler doesn't get notified about the newly
+// scheduled changes, so it has to check for them periodically.
+const maxConfigChangeSchedulerWait = 30 * time.Second
+
+// Executes the scheduled config changes when their deadlines expire.
+// It uses the config manager to commit the changes and raises an event
+// for each executed change.
+type ConfigChangeScheduler struct {
+	db          *pg.DB
+	manager     config.Manager
+	eventCenter eventcenter.EventCenter
+	done        chan bool
+	wg          *sync.WaitGroup
+}
+
+// Creates a new scheduler instance and starts its goroutine.
+func NewConfigChangeScheduler(db *pg.DB, manager config.Manager, eventCenter eventcenter.EventCenter) *ConfigChangeScheduler {
+	scheduler := &ConfigChangeScheduler{
+		db:          db,
+		manager:     manager,
+		eventCenter: eventCenter,
+		done:        make(chan bool),
+		wg:          &sync.WaitGroup{},
+	}
+	scheduler.wg.Add(1)
+	go scheduler.mainLoop()
+
+	log.Printf("Started config change scheduler")
+	return scheduler
+}
+
+// Stops the scheduler's goroutine.
+func (scheduler *ConfigChangeScheduler) Shutdown() {
+	log.Printf("Stopping config change scheduler")
+	scheduler.done <- true
+	scheduler.wg.Wait()
+	log.Printf("Stopped config change scheduler")
+}
+
+// Returns the time to wait before executing the next config change.
+// The time is capped by the maximum wait time. The minimum wait time
+// prevents the busy loop when the due changes cannot be executed.
+func (scheduler *ConfigChangeScheduler) getWaitTime() time.Duration {
+	wait, ok, err := dbmodel.GetTimeToNextScheduledConfigChange(scheduler.db)
+	if err != nil {
+		log.WithError(err).Error("Problem with getting time to next scheduled config change")
+		return maxConfigChangeSchedulerWait
+	}
+	if !ok || wait > maxConfigChangeSchedulerWait {
+		return maxConfigChangeSchedulerWait
+	}
+	if wait < time.Second {
+		return time.Second
+	}
+	return wait
+}
+
+// Commits the due config changes and raises the events describing the
+// results of their execution. The events are raised only for the changes
+// executed by this call.
+func (scheduler *ConfigChangeScheduler) commitDue() {
+	executedIDs, err := scheduler.manager.CommitDue()
+	if err != nil {
+		log.WithError(err).Error("Problem with committing due config changes")
+	}
+	for _, id := range executedIDs {
+		executed, err := dbmodel.GetScheduledConfigChange(scheduler.db, id)
+		if err != nil {
+			log.WithError(err).Errorf("Problem with getting executed config change %d", id)
+			continue
+		}
+		if executed == nil {
+			continue
+		}
+		if executed.Error != "" {
+			scheduler.eventCenter.AddErrorEvent(fmt.Sprintf("Failed to execute config change %d scheduled by {user}", executed.ID),
+				executed.User, executed.Error)
+			continue
+		}
+		scheduler.eventCenter.AddInfoEvent(fmt.Sprintf("Executed config change %d scheduled by {user}", executed.ID),
+			executed.User)
+	}
+}
+
+// The scheduler's main loop. It waits until the deadline of the next
+// config change and commits the due changes.
+func (scheduler *ConfigChangeScheduler) mainLoop() {
+	defer scheduler.wg.Done()
+	for {
+		timer := time.NewTimer(scheduler.getWaitTime())
+		select {
+		case <-scheduler.done:
+			timer.Stop()
+			return
+		case <-timer.C:
+			scheduler.commitDue()
+		}
+	}
+}
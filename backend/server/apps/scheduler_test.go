@@ -0,0 +1,129 @@
+package apps
+
+import (
+	"testing"
+	"time"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+	appstest "isc.org/stork/server/apps/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the scheduler executes the due config changes and raises
+// the events describing the results of their execution.
+func TestConfigChangeSchedulerCommitDue(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	// Scheduled config changes must be associated with a user.
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	manager := NewManager(&appstest.ManagerAccessorsWrapper{
+		DB: db,
+	})
+	fkm := newFakeKeaModuleCommit()
+	manager.(*configManagerImpl).keaCommit = fkm
+
+	fec := &storktest.FakeEventCenter{}
+	scheduler := NewConfigChangeScheduler(db, manager, fec)
+	defer scheduler.Shutdown()
+
+	// The first change is due and the second one is in the future.
+	changes := []dbmodel.ScheduledConfigChange{
+		{
+			DeadlineAt: storkutil.UTCNow().Add(-time.Second * 10),
+			UserID:     int64(user.ID),
+			Updates: []*dbmodel.ConfigUpdate{
+				dbmodel.NewConfigUpdate(dbmodel.AppTypeKea, "host_add"),
+			},
+		},
+		{
+			DeadlineAt: storkutil.UTCNow().Add(time.Second * 100),
+			UserID:     int64(user.ID),
+			Updates: []*dbmodel.ConfigUpdate{
+				dbmodel.NewConfigUpdate(dbmodel.AppTypeKea, "host_update"),
+			},
+		},
+	}
+	for i := range changes {
+		err := dbmodel.AddScheduledConfigChange(db, &changes[i])
+		require.NoError(t, err)
+	}
+
+	// The wait time is capped by the deadline of the next change.
+	require.Equal(t, time.Second, scheduler.getWaitTime())
+
+	scheduler.commitDue()
+	require.Len(t, fkm.ops, 1)
+	require.Equal(t, "kea.host_add", fkm.ops[0])
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "Executed config change")
+	require.EqualValues(t, user.ID, fec.Events[0].Relations.UserID)
+
+	// The executed change is not committed again.
+	scheduler.commitDue()
+	require.Len(t, fkm.ops, 1)
+	require.Len(t, fec.Events, 1)
+
+	wait := scheduler.getWaitTime()
+	require.Greater(t, wait, time.Second)
+	require.LessOrEqual(t, wait, maxConfigChangeSchedulerWait)
+}
+
+// Test that the scheduler raises an error event when the execution of the
+// config change fails.
+func TestConfigChangeSchedulerCommitDueError(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	manager := NewManager(&appstest.ManagerAccessorsWrapper{
+		DB: db,
+	})
+	fkm := newFakeKeaModuleCommit()
+	fkm.err = pkgerrors.New("custom test error")
+	manager.(*configManagerImpl).keaCommit = fkm
+
+	fec := &storktest.FakeEventCenter{}
+	scheduler := NewConfigChangeScheduler(db, manager, fec)
+	defer scheduler.Shutdown()
+
+	change := &dbmodel.ScheduledConfigChange{
+		DeadlineAt: storkutil.UTCNow().Add(-time.Second * 10),
+		UserID:     int64(user.ID),
+		Updates: []*dbmodel.ConfigUpdate{
+			dbmodel.NewConfigUpdate(dbmodel.AppTypeKea, "host_add"),
+		},
+	}
+	err = dbmodel.AddScheduledConfigChange(db, change)
+	require.NoError(t, err)
+
+	scheduler.commitDue()
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvError, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "Failed to execute config change")
+	require.Equal(t, "custom test error", fec.Events[0].Details)
+
+	// There are no more changes to execute.
+	require.Equal(t, maxConfigChangeSchedulerWait, scheduler.getWaitTime())
+}
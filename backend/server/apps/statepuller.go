@@ -10,6 +10,7 @@ import (
 	"isc.org/stork/server/agentcomm"
 	"isc.org/stork/server/apps/bind9"
 	"isc.org/stork/server/apps/kea"
+	"isc.org/stork/server/certs"
 	"isc.org/stork/server/configreview"
 	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
@@ -59,21 +60,49 @@ func (puller *StatePuller) pullData() error {
 	// get state from machines and their apps
 	var lastErr error
 	okCnt := 0
+	machinesCnt := 0
 	for _, dbM := range dbMachines {
 		dbM2 := dbM
+		if !puller.shouldPullMachine(&dbM2) {
+			continue
+		}
+		machinesCnt++
+		// Remember the apps before they are refreshed from the machine.
+		apps := dbM2.Apps
 		ctx := context.Background()
 		errStr := GetMachineAndAppsState(ctx, puller.DB, &dbM2, puller.Agents, puller.EventCenter, puller.ReviewDispatcher, puller.DHCPOptionDefinitionLookup)
+		var machineErr error
 		if errStr != "" {
-			lastErr = errors.New(errStr)
+			machineErr = errors.New(errStr)
+			lastErr = machineErr
 			log.Errorf("Error occurred while getting info from machine %d: %s", dbM2.ID, errStr)
 		} else {
 			okCnt++
 		}
+		for _, app := range apps {
+			puller.ReportAppPullResult(app.ID, machineErr)
+		}
 	}
-	log.Printf("Completed pulling information from machines: %d/%d succeeded", okCnt, len(dbMachines))
+	log.Printf("Completed pulling information from machines: %d/%d succeeded", okCnt, machinesCnt)
 	return lastErr
 }
 
+// Checks if the state of the machine should be pulled in the current
+// execution. The state is pulled together for all apps of the machine,
+// so it is pulled if any of the apps should be pulled. The machines
+// without apps are pulled in the regular executions to detect new apps.
+func (puller *StatePuller) shouldPullMachine(machine *dbmodel.Machine) bool {
+	if len(machine.Apps) == 0 {
+		return !puller.IsPullingOnDemand()
+	}
+	for _, app := range machine.Apps {
+		if puller.ShouldPullApp(app.ID) {
+			return true
+		}
+	}
+	return false
+}
+
 // Store updated machine fields in to database.
 func updateMachineFields(db *dbops.PgDB, dbMachine *dbmodel.Machine, m *agentcomm.State) error {
 	// update state fields in machine
@@ -94,6 +123,7 @@ func updateMachineFields(db *dbops.PgDB, dbMachine *dbmodel.Machine, m *agentcom
 	dbMachine.State.VirtualizationRole = m.VirtualizationRole
 	dbMachine.State.HostID = m.HostID
 	dbMachine.State.AgentUsesHTTPCredentials = m.AgentUsesHTTPCredentials
+	dbMachine.State.Inventory = m.Inventory
 	dbMachine.LastVisitedAt = m.LastVisitedAt
 	dbMachine.Error = m.Error
 	err := dbmodel.UpdateMachine(db, dbMachine)
@@ -104,8 +134,9 @@ func updateMachineFields(db *dbops.PgDB, dbMachine *dbmodel.Machine, m *agentcom
 }
 
 // appCompare compares two apps for equality.  Two apps are considered equal if
-// their type matches and if they have the same control port.  Return true if
-// equal, false otherwise.
+// their type matches and if they have the same control port. The Kea apps
+// controlled over the control sockets are equal if they have the same socket
+// path. Return true if equal, false otherwise.
 func appCompare(dbApp *dbmodel.App, app *agentcomm.App) bool {
 	if dbApp.Type.String() != app.Type {
 		return false
@@ -113,11 +144,19 @@ func appCompare(dbApp *dbmodel.App, app *agentcomm.App) bool {
 
 	var controlPortEqual bool
 	for _, pt1 := range dbApp.AccessPoints {
-		if pt1.Type != dbmodel.AccessPointControl {
+		if pt1.Type != dbmodel.AccessPointControl && pt1.Type != dbmodel.AccessPointControlSocket {
 			continue
 		}
 		for _, pt2 := range app.AccessPoints {
-			if pt2.Type != dbmodel.AccessPointControl {
+			if pt2.Type != pt1.Type {
+				continue
+			}
+
+			if pt1.Type == dbmodel.AccessPointControlSocket {
+				if pt1.Address == pt2.Address {
+					controlPortEqual = true
+					break
+				}
 				continue
 			}
 
@@ -266,6 +305,12 @@ func GetMachineAndAppsState(ctx context.Context, db *dbops.PgDB, dbMachine *dbmo
 		return "Cannot update machine in db"
 	}
 
+	// renew the agent's cert before it expires or when the CA is rotated
+	err = certs.RenewAgentCertIfNeeded(ctx2, db, dbMachine, state.AgentCertificate, agents, eventCenter)
+	if err != nil {
+		log.WithError(err).WithField("machine", dbMachine.Address).Warn("Cannot renew the agent certificate")
+	}
+
 	// take old apps from db and new apps fetched from the machine
 	// and match them and prepare a list of all apps
 	allApps, errStr := mergeNewAndOldApps(db, dbMachine, state.Apps)
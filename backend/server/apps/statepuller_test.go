@@ -163,6 +163,29 @@ func TestAppCompare(t *testing.T) {
 	require.False(t, appCompare(dbApp, app))
 }
 
+// Test that the Kea apps controlled over the control sockets are compared
+// using the socket paths.
+func TestAppCompareControlSocket(t *testing.T) {
+	var ap []*dbmodel.AccessPoint
+	dbApp := &dbmodel.App{
+		Type:         dbmodel.AppTypeKea,
+		AccessPoints: dbmodel.AppendAccessPoint(ap, dbmodel.AccessPointControlSocket, "/run/kea/kea4.sock", "", 0, false),
+	}
+	app := &agentcomm.App{
+		Type:         dbmodel.AppTypeKea.String(),
+		AccessPoints: agentcomm.MakeAccessPoint(dbmodel.AccessPointControlSocket, "/run/kea/kea4.sock", "", 0),
+	}
+	require.True(t, appCompare(dbApp, app))
+
+	// Different socket paths so not equal.
+	app.AccessPoints[0].Address = "/run/kea/kea6.sock"
+	require.False(t, appCompare(dbApp, app))
+
+	// The control access point with the same port doesn't match the socket.
+	app.AccessPoints = agentcomm.MakeAccessPoint(dbmodel.AccessPointControl, "/run/kea/kea4.sock", "", 0)
+	require.False(t, appCompare(dbApp, app))
+}
+
 // Test that new configuration review is scheduled when a daemon's
 // configuration has changed or when review dispatcher's checkers
 // have changed.
@@ -16,6 +16,7 @@ import (
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"isc.org/stork/pki"
+	dbops "isc.org/stork/server/database"
 	dbmodel "isc.org/stork/server/database/model"
 )
 
@@ -106,7 +107,7 @@ func setupRootKeyAndCert(db *pg.DB) (*ecdsa.PrivateKey, *x509.Certificate, []byt
 
 // Check if a server key and a server cert are present in db. If not generate them
 // and store in database.
-func setupServerKeyAndCert(db *pg.DB, rootKey *ecdsa.PrivateKey, rootCert *x509.Certificate) ([]byte, []byte, error) {
+func setupServerKeyAndCert(db dbops.DBI, rootKey *ecdsa.PrivateKey, rootCert *x509.Certificate) ([]byte, []byte, error) {
 	// check server cert, if missing then generate it
 	serverKeyPEM, err := dbmodel.GetSecret(db, dbmodel.SecretServerKey)
 	if err != nil {
@@ -186,7 +187,8 @@ func setupServerKeyAndCert(db *pg.DB, rootKey *ecdsa.PrivateKey, rootCert *x509.
 // Check if there are root CA and server keys and certs, and server
 // token in the database.  If they are missing then create them and
 // store in the database. In the end return root CA cert, server key
-// and cert, all in PEM format.
+// and cert, all in PEM format. The returned root CA cert is followed
+// by the next CA cert when the CA rollover is in progress.
 func SetupServerCerts(db *pg.DB) ([]byte, []byte, []byte, error) {
 	log.Printf("Preparing certs, it may take several minutes")
 
@@ -214,6 +216,16 @@ func SetupServerCerts(db *pg.DB) ([]byte, []byte, []byte, error) {
 		}
 	}
 
+	// trust the agent certs signed by the next CA during the rollover
+	nextCACertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	if err != nil {
+		return nil, nil, nil, errors.Wrapf(err, "cannot get next CA cert from database")
+	}
+	if nextCACertPEM != nil {
+		log.Printf("CA rollover in progress, trusting the next CA cert")
+		rootCertPEM = joinPEM(rootCertPEM, nextCACertPEM)
+	}
+
 	return rootCertPEM, serverCertPEM, serverKeyPEM, nil
 }
 
@@ -0,0 +1,187 @@
+package certs
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"isc.org/stork/pki"
+	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/eventcenter"
+)
+
+// The agent certs are renewed when they expire within this period.
+const AgentCertRenewalPeriod = 30 * 24 * time.Hour
+
+// The error event is raised when the agent cert can't be renewed and it
+// expires within this period.
+const AgentCertExpiryWarningPeriod = 7 * 24 * time.Hour
+
+// Checks if the agent cert must be renewed. It is the case when the cert
+// expires soon or when it is not signed by the next CA during the CA
+// rollover. The next CA cert is nil if the rollover is not in progress.
+func isAgentCertRenewalRequired(cert, nextCACert *x509.Certificate, now time.Time) bool {
+	if cert.NotAfter.Sub(now) < AgentCertRenewalPeriod {
+		return true
+	}
+	return nextCACert != nil && cert.CheckSignatureFrom(nextCACert) != nil
+}
+
+// Renews the agent cert if it expires soon or it isn't signed by the next
+// CA during the CA rollover. The agent cert is the one returned by the
+// agent in its state. The agent generates a new private key and sends the
+// CSR over the existing connection. The server signs it and sends the new
+// cert back to the agent along with the trusted CA certs. The events are
+// raised when the cert is renewed and when the renewal fails for the first
+// time. Another event is raised once when the renewal fails and the cert
+// expires very soon. The expiration time and the renewal error are stored
+// in the machine state.
+func RenewAgentCertIfNeeded(ctx context.Context, db *pg.DB, machine *dbmodel.Machine, agentCertPEM string, agents agentcomm.ConnectedAgents, eventCenter eventcenter.EventCenter) error {
+	// The agents prior to the renewal support don't return their certs.
+	if agentCertPEM == "" {
+		return nil
+	}
+	cert, err := pki.ParseCert([]byte(agentCertPEM))
+	if err != nil {
+		return errors.WithMessagef(err, "cannot parse the certificate of the agent %s", machine.Address)
+	}
+
+	var nextCACert *x509.Certificate
+	nextCACertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	if err != nil {
+		return err
+	}
+	if nextCACertPEM != nil {
+		if nextCACert, err = pki.ParseCert(nextCACertPEM); err != nil {
+			return errors.WithMessage(err, "cannot parse next CA cert")
+		}
+	}
+
+	now := time.Now()
+	expiresAt := cert.NotAfter.UTC()
+	if !isAgentCertRenewalRequired(cert, nextCACert, now) {
+		if machine.State.AgentCertExpiresAt.Equal(expiresAt) && machine.State.AgentCertRenewalError == "" && !machine.State.AgentCertExpiryWarned {
+			return nil
+		}
+		machine.State.AgentCertExpiresAt = expiresAt
+		machine.State.AgentCertRenewalError = ""
+		machine.State.AgentCertExpiryWarned = false
+		return dbmodel.UpdateMachine(db, machine)
+	}
+
+	certPEM, fingerprint, err := renewAgentCert(ctx, db, machine, agents)
+	if err != nil {
+		if machine.State.AgentCertRenewalError == "" {
+			eventCenter.AddWarningEvent(
+				fmt.Sprintf("Cannot renew the certificate of {machine} expiring on %s", expiresAt.Format(time.RFC3339)),
+				machine, err.Error(),
+			)
+		}
+		if !machine.State.AgentCertExpiryWarned && cert.NotAfter.Sub(now) < AgentCertExpiryWarningPeriod {
+			eventCenter.AddErrorEvent(
+				fmt.Sprintf("Certificate of {machine} expires on %s; the server will not communicate with the agent after that unless it is renewed", expiresAt.Format(time.RFC3339)),
+				machine, err.Error(),
+			)
+			machine.State.AgentCertExpiryWarned = true
+		}
+		machine.State.AgentCertExpiresAt = expiresAt
+		machine.State.AgentCertRenewalError = err.Error()
+		if updateErr := dbmodel.UpdateMachine(db, machine); updateErr != nil {
+			log.WithError(updateErr).Error("Problem updating machine in database")
+		}
+		return err
+	}
+
+	newCert, err := pki.ParseCert(certPEM)
+	if err != nil {
+		return err
+	}
+	machine.CertFingerprint = fingerprint
+	machine.State.AgentCertExpiresAt = newCert.NotAfter.UTC()
+	machine.State.AgentCertRenewalError = ""
+	machine.State.AgentCertExpiryWarned = false
+	if err = dbmodel.UpdateMachine(db, machine); err != nil {
+		return err
+	}
+	eventCenter.AddInfoEvent(
+		fmt.Sprintf("Renewed the certificate of {machine}; the new certificate expires on %s", machine.State.AgentCertExpiresAt.Format(time.RFC3339)),
+		machine,
+	)
+	return nil
+}
+
+// Requests the CSR from the agent, signs it using the agent signing CA
+// and installs the signed cert on the agent. It returns the new agent
+// cert and its fingerprint.
+func renewAgentCert(ctx context.Context, db *pg.DB, machine *dbmodel.Machine, agents agentcomm.ConnectedAgents) ([]byte, [32]byte, error) {
+	var fingerprint [32]byte
+
+	csrPEM, err := agents.RenewCertificate(ctx, machine.Address, machine.AgentPort)
+	if err != nil {
+		return nil, fingerprint, err
+	}
+
+	caKeyPEM, caCertPEM, err := GetAgentSigningCA(db)
+	if err != nil {
+		return nil, fingerprint, err
+	}
+	caCertBundlePEM, err := GetCACertBundle(db)
+	if err != nil {
+		return nil, fingerprint, err
+	}
+	certSerialNumber, err := dbmodel.GetNewCertSerialNumber(db)
+	if err != nil {
+		return nil, fingerprint, errors.Wrapf(err, "cannot get new cert S/N")
+	}
+
+	certPEM, fingerprint, paramsErr, innerErr := pki.SignCert(csrPEM, certSerialNumber, caCertPEM, caKeyPEM)
+	if paramsErr != nil {
+		return nil, fingerprint, errors.WithMessage(paramsErr, "problem with agent CSR")
+	}
+	if innerErr != nil {
+		return nil, fingerprint, errors.WithMessage(innerErr, "problem signing agent CSR")
+	}
+
+	if err = agents.InstallCertificate(ctx, machine.Address, machine.AgentPort, certPEM, caCertBundlePEM); err != nil {
+		return nil, fingerprint, err
+	}
+	return certPEM, fingerprint, nil
+}
+
+// Raises the warning events if the server CA cert or the server cert
+// expires within the agent cert renewal period. They are not renewed
+// automatically and require the CA rollover.
+func CheckServerCertsExpiration(db *pg.DB, eventCenter eventcenter.EventCenter) error {
+	secrets := []struct {
+		name        string
+		displayName string
+	}{
+		{dbmodel.SecretCACert, SecretTypeCACert},
+		{dbmodel.SecretServerCert, SecretTypeSrvCert},
+	}
+	for _, secret := range secrets {
+		certPEM, err := dbmodel.GetSecret(db, secret.name)
+		if err != nil {
+			return err
+		}
+		if certPEM == nil {
+			continue
+		}
+		cert, err := pki.ParseCert(certPEM)
+		if err != nil {
+			return errors.WithMessagef(err, "cannot parse %s", secret.displayName)
+		}
+		if time.Until(cert.NotAfter) < AgentCertRenewalPeriod {
+			eventCenter.AddWarningEvent(fmt.Sprintf(
+				"%s expires on %s; start the CA rollover to replace it",
+				secret.displayName, cert.NotAfter.UTC().Format(time.RFC3339),
+			))
+		}
+	}
+	return nil
+}
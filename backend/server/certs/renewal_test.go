@@ -0,0 +1,270 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/pki"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Generates the agent CSR and signs it using the current CA. It returns
+// the CSR and the signed agent cert.
+func signTestAgentCert(t *testing.T, db *dbops.PgDB) ([]byte, []byte) {
+	keyPEM, err := pki.GenKey()
+	require.NoError(t, err)
+	csrPEM, _, err := pki.GenCSRUsingKey("agent", []string{"agent"}, nil, keyPEM)
+	require.NoError(t, err)
+	caKeyPEM, err := dbmodel.GetSecret(db, dbmodel.SecretCAKey)
+	require.NoError(t, err)
+	caCertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretCACert)
+	require.NoError(t, err)
+	certPEM, _, paramsErr, innerErr := pki.SignCert(csrPEM, 100, caCertPEM, caKeyPEM)
+	require.NoError(t, paramsErr)
+	require.NoError(t, innerErr)
+	return csrPEM, certPEM
+}
+
+// Adds the machine to the database.
+func addTestMachine(t *testing.T, db *dbops.PgDB) *dbmodel.Machine {
+	machine := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	require.NoError(t, dbmodel.AddMachine(db, machine))
+	return machine
+}
+
+// Check if the agent cert renewal is required when the cert expires soon
+// or it isn't signed by the next CA.
+func TestIsAgentCertRenewalRequired(t *testing.T) {
+	now := time.Now()
+	require.True(t, isAgentCertRenewalRequired(&x509.Certificate{NotAfter: now.AddDate(0, 0, 10)}, nil, now))
+	require.False(t, isAgentCertRenewalRequired(&x509.Certificate{NotAfter: now.AddDate(0, 0, 60)}, nil, now))
+
+	caKey, _, caCert, _, err := pki.GenCAKeyCert(1)
+	require.NoError(t, err)
+	_, _, nextCACert, _, err := pki.GenCAKeyCert(2)
+	require.NoError(t, err)
+	certPEM, _, err := pki.GenKeyCert("agent", []string{"agent"}, nil, 3, caCert, caKey)
+	require.NoError(t, err)
+	cert, err := pki.ParseCert(certPEM)
+	require.NoError(t, err)
+
+	require.False(t, isAgentCertRenewalRequired(cert, caCert, now))
+	require.True(t, isAgentCertRenewalRequired(cert, nextCACert, now))
+}
+
+// Check if the agent cert is not renewed when it is not needed.
+func TestRenewAgentCertIfNeededNotRequired(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	_, _, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+	_, certPEM := signTestAgentCert(t, db)
+	machine := addTestMachine(t, db)
+
+	agents := &agentcommtest.FakeAgents{}
+	fec := &storktest.FakeEventCenter{}
+
+	err = RenewAgentCertIfNeeded(context.Background(), db, machine, string(certPEM), agents, fec)
+	require.NoError(t, err)
+	require.Nil(t, agents.InstalledCertificate)
+	require.Empty(t, fec.Events)
+
+	machine, err = dbmodel.GetMachineByID(db, machine.ID)
+	require.NoError(t, err)
+	require.True(t, machine.State.AgentCertExpiresAt.After(time.Now().AddDate(1, 0, 0)))
+	require.Empty(t, machine.State.AgentCertRenewalError)
+
+	// No cert is returned by the old agents.
+	err = RenewAgentCertIfNeeded(context.Background(), db, machine, "", agents, fec)
+	require.NoError(t, err)
+}
+
+// Check if the agent cert is renewed during the CA rollover.
+func TestRenewAgentCertIfNeededDuringRollover(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	_, _, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+	csrPEM, certPEM := signTestAgentCert(t, db)
+	machine := addTestMachine(t, db)
+	require.NoError(t, StartCARollover(db))
+
+	agents := &agentcommtest.FakeAgents{
+		RenewalCSR: csrPEM,
+	}
+	fec := &storktest.FakeEventCenter{}
+
+	err = RenewAgentCertIfNeeded(context.Background(), db, machine, string(certPEM), agents, fec)
+	require.NoError(t, err)
+
+	// The new cert is signed by the next CA.
+	require.NotNil(t, agents.InstalledCertificate)
+	nextCACertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	require.NoError(t, err)
+	nextCACert, err := pki.ParseCert(nextCACertPEM)
+	require.NoError(t, err)
+	newCert, err := pki.ParseCert(agents.InstalledCertificate)
+	require.NoError(t, err)
+	require.NoError(t, newCert.CheckSignatureFrom(nextCACert))
+
+	// The agent receives both CA certs.
+	bundlePEM, err := GetCACertBundle(db)
+	require.NoError(t, err)
+	require.Equal(t, bundlePEM, agents.InstalledCACertificates)
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "Renewed the certificate")
+	require.Contains(t, fec.Events[0].Text, newCert.NotAfter.UTC().Format(time.RFC3339))
+
+	machine, err = dbmodel.GetMachineByID(db, machine.ID)
+	require.NoError(t, err)
+	require.NotEqual(t, [32]byte{}, machine.CertFingerprint)
+	require.Equal(t, newCert.NotAfter.UTC(), machine.State.AgentCertExpiresAt.UTC())
+
+	// The renewed cert doesn't require another renewal.
+	newCertPEM := agents.InstalledCertificate
+	agents.InstalledCertificate = nil
+	err = RenewAgentCertIfNeeded(context.Background(), db, machine, string(newCertPEM), agents, fec)
+	require.NoError(t, err)
+	require.Nil(t, agents.InstalledCertificate)
+	require.Len(t, fec.Events, 1)
+}
+
+// Check if the warning event is raised only once when the agent cert
+// renewal fails repeatedly.
+func TestRenewAgentCertIfNeededFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	_, _, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+	_, certPEM := signTestAgentCert(t, db)
+	machine := addTestMachine(t, db)
+	require.NoError(t, StartCARollover(db))
+
+	agents := &agentcommtest.FakeAgents{
+		CertRenewalError: errors.New("agent unreachable"),
+	}
+	fec := &storktest.FakeEventCenter{}
+
+	for i := 0; i < 2; i++ {
+		err = RenewAgentCertIfNeeded(context.Background(), db, machine, string(certPEM), agents, fec)
+		require.Error(t, err)
+	}
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Equal(t, "agent unreachable", fec.Events[0].Details)
+
+	machine, err = dbmodel.GetMachineByID(db, machine.ID)
+	require.NoError(t, err)
+	require.Equal(t, "agent unreachable", machine.State.AgentCertRenewalError)
+}
+
+// Check if the error event is raised once when the agent cert expiring
+// very soon can't be renewed.
+func TestRenewAgentCertIfNeededExpiringSoon(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	_, _, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+	machine := addTestMachine(t, db)
+
+	// Sign the agent cert expiring in three days.
+	caKeyPEM, err := dbmodel.GetSecret(db, dbmodel.SecretCAKey)
+	require.NoError(t, err)
+	caKey, err := pki.ParsePrivateKey(caKeyPEM)
+	require.NoError(t, err)
+	caCertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretCACert)
+	require.NoError(t, err)
+	caCert, err := pki.ParseCert(caCertPEM)
+	require.NoError(t, err)
+	agentKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(100),
+		Subject:      pkix.Name{CommonName: "agent"},
+		NotBefore:    time.Now().AddDate(0, 0, -1),
+		NotAfter:     time.Now().AddDate(0, 0, 3),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, caCert, &agentKey.PublicKey, caKey)
+	require.NoError(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+
+	agents := &agentcommtest.FakeAgents{
+		CertRenewalError: errors.New("agent unreachable"),
+	}
+	fec := &storktest.FakeEventCenter{}
+
+	for i := 0; i < 2; i++ {
+		err = RenewAgentCertIfNeeded(context.Background(), db, machine, string(certPEM), agents, fec)
+		require.Error(t, err)
+	}
+
+	require.Len(t, fec.Events, 2)
+	require.Equal(t, dbmodel.EvWarning, fec.Events[0].Level)
+	require.Equal(t, dbmodel.EvError, fec.Events[1].Level)
+	require.Contains(t, fec.Events[1].Text, "expires on")
+
+	machine, err = dbmodel.GetMachineByID(db, machine.ID)
+	require.NoError(t, err)
+	require.True(t, machine.State.AgentCertExpiryWarned)
+}
+
+// Check if no events are raised for the server certs valid for a long time.
+func TestCheckServerCertsExpiration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fec := &storktest.FakeEventCenter{}
+
+	// No certs yet.
+	require.NoError(t, CheckServerCertsExpiration(db, fec))
+
+	_, _, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+	require.NoError(t, CheckServerCertsExpiration(db, fec))
+	require.Empty(t, fec.Events)
+}
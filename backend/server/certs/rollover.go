@@ -0,0 +1,182 @@
+package certs
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"isc.org/stork/pki"
+	dbmodel "isc.org/stork/server/database/model"
+)
+
+// The server CA rollover replaces the root CA without re-registering the
+// agents. It consists of the following steps:
+//
+//  1. StartCARollover generates the next CA. The server is restarted to
+//     trust the agent certs signed by both CAs. The agent certs are
+//     renewed and signed by the next CA, and the agents receive both CA
+//     certs, so they still trust the current server cert.
+//  2. FinishCARollover promotes the next CA to the current one and
+//     generates a new server cert signed by it. The server is restarted
+//     to use the new server cert and trust only the new CA.
+//
+// Both CA certs are published during the transition window between the
+// steps. The rollover should be finished when all agents have renewed
+// their certs.
+
+// Checks if the server CA rollover has been started and not finished yet.
+func IsCARolloverInProgress(db *pg.DB) (bool, error) {
+	nextCACertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	if err != nil {
+		return false, errors.WithMessage(err, "problem getting next CA cert from database")
+	}
+	return nextCACertPEM != nil, nil
+}
+
+// Generates the next CA key and cert and stores them in the database. It
+// returns an error if the rollover is already in progress.
+func StartCARollover(db *pg.DB) error {
+	inProgress, err := IsCARolloverInProgress(db)
+	if err != nil {
+		return err
+	}
+	if inProgress {
+		return errors.New("CA rollover is already in progress")
+	}
+
+	certSerialNumber, err := dbmodel.GetNewCertSerialNumber(db)
+	if err != nil {
+		return errors.Wrapf(err, "cannot get new cert S/N")
+	}
+	_, nextKeyPEM, _, nextCertPEM, err := pki.GenCAKeyCert(certSerialNumber)
+	if err != nil {
+		return errors.WithMessage(err, "cannot generate next CA cert")
+	}
+	if err = dbmodel.SetSecret(db, dbmodel.SecretNextCAKey, nextKeyPEM); err != nil {
+		return errors.Wrapf(err, "cannot store next CA key in database")
+	}
+	if err = dbmodel.SetSecret(db, dbmodel.SecretNextCACert, nextCertPEM); err != nil {
+		return errors.Wrapf(err, "cannot store next CA cert in database")
+	}
+	log.Info("Started CA rollover; restart the server to trust the agent certs signed by the next CA")
+	return nil
+}
+
+// Replaces the current CA key and cert with the next ones and generates
+// the new server key and cert signed by the new CA. It returns an error if
+// the rollover hasn't been started.
+func FinishCARollover(db *pg.DB) error {
+	nextKeyPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCAKey)
+	if err != nil {
+		return errors.WithMessage(err, "problem getting next CA key from database")
+	}
+	nextCertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	if err != nil {
+		return errors.WithMessage(err, "problem getting next CA cert from database")
+	}
+	if nextKeyPEM == nil || nextCertPEM == nil {
+		return errors.New("CA rollover is not in progress")
+	}
+
+	nextKey, err := pki.ParsePrivateKey(nextKeyPEM)
+	if err != nil {
+		return errors.WithMessage(err, "cannot parse next CA key")
+	}
+	nextCert, err := pki.ParseCert(nextCertPEM)
+	if err != nil {
+		return errors.WithMessage(err, "cannot parse next CA cert")
+	}
+
+	// Replace the secrets in one transaction, so the new CA key is never
+	// paired with the old CA cert and the next CA is not left behind when
+	// any of the steps fails.
+	err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+		if err := dbmodel.SetSecret(tx, dbmodel.SecretCAKey, nextKeyPEM); err != nil {
+			return errors.Wrapf(err, "cannot store root CA key in database")
+		}
+		if err := dbmodel.SetSecret(tx, dbmodel.SecretCACert, nextCertPEM); err != nil {
+			return errors.Wrapf(err, "cannot store root CA cert in database")
+		}
+
+		// Remove the server key and cert, so they are generated again
+		// using the new CA.
+		for _, name := range []string{dbmodel.SecretServerKey, dbmodel.SecretServerCert} {
+			if err := dbmodel.DeleteSecret(tx, name); err != nil {
+				return err
+			}
+		}
+		if _, _, err := setupServerKeyAndCert(tx, nextKey, nextCert); err != nil {
+			return err
+		}
+
+		for _, name := range []string{dbmodel.SecretNextCAKey, dbmodel.SecretNextCACert} {
+			if err := dbmodel.DeleteSecret(tx, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("Finished CA rollover; restart the server to use the new server cert")
+	return nil
+}
+
+// Returns the CA key and cert used to sign the agent certs. It is the
+// next CA during the rollover and the current CA otherwise.
+func GetAgentSigningCA(db *pg.DB) (keyPEM, certPEM []byte, err error) {
+	keyPEM, err = dbmodel.GetSecret(db, dbmodel.SecretNextCAKey)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "problem getting next CA key from database")
+	}
+	certPEM, err = dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "problem getting next CA cert from database")
+	}
+	if keyPEM != nil && certPEM != nil {
+		return keyPEM, certPEM, nil
+	}
+
+	keyPEM, err = dbmodel.GetSecret(db, dbmodel.SecretCAKey)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "problem getting CA key from database")
+	}
+	certPEM, err = dbmodel.GetSecret(db, dbmodel.SecretCACert)
+	if err != nil {
+		return nil, nil, errors.WithMessage(err, "problem getting CA cert from database")
+	}
+	return keyPEM, certPEM, nil
+}
+
+// Returns the CA certs trusted by the server in the PEM format. It is the
+// current CA cert followed by the next CA cert during the rollover.
+func GetCACertBundle(db *pg.DB) ([]byte, error) {
+	caCertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretCACert)
+	if err != nil {
+		return nil, errors.WithMessage(err, "problem getting CA cert from database")
+	}
+	nextCACertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	if err != nil {
+		return nil, errors.WithMessage(err, "problem getting next CA cert from database")
+	}
+	return joinPEM(caCertPEM, nextCACertPEM), nil
+}
+
+// Concatenates the PEM contents ensuring they are separated with a new
+// line. The empty contents are skipped.
+func joinPEM(contents ...[]byte) []byte {
+	var joined []byte
+	for _, content := range contents {
+		if len(content) == 0 {
+			continue
+		}
+		joined = append(joined, content...)
+		if !bytes.HasSuffix(content, []byte("\n")) {
+			joined = append(joined, '\n')
+		}
+	}
+	return joined
+}
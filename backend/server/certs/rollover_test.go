@@ -0,0 +1,88 @@
+package certs
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"isc.org/stork/pki"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Check if the CA rollover replaces the CA and the server cert.
+func TestCARollover(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode.")
+	}
+
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rootCertPEM, serverCertPEM, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+
+	inProgress, err := IsCARolloverInProgress(db)
+	require.NoError(t, err)
+	require.False(t, inProgress)
+
+	// The rollover cannot be finished before it is started.
+	require.Error(t, FinishCARollover(db))
+
+	// The agent certs are signed by the current CA.
+	_, signingCertPEM, err := GetAgentSigningCA(db)
+	require.NoError(t, err)
+	require.Equal(t, rootCertPEM, signingCertPEM)
+
+	// Start the rollover.
+	require.NoError(t, StartCARollover(db))
+	require.Error(t, StartCARollover(db))
+
+	inProgress, err = IsCARolloverInProgress(db)
+	require.NoError(t, err)
+	require.True(t, inProgress)
+
+	nextCACertPEM, err := dbmodel.GetSecret(db, dbmodel.SecretNextCACert)
+	require.NoError(t, err)
+	require.NotNil(t, nextCACertPEM)
+
+	// The agent certs are signed by the next CA.
+	_, signingCertPEM, err = GetAgentSigningCA(db)
+	require.NoError(t, err)
+	require.Equal(t, nextCACertPEM, signingCertPEM)
+
+	// Both CA certs are trusted.
+	bundlePEM, err := GetCACertBundle(db)
+	require.NoError(t, err)
+	require.Equal(t, joinPEM(rootCertPEM, nextCACertPEM), bundlePEM)
+
+	rootCertPEM2, serverCertPEM2, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+	require.Equal(t, bundlePEM, rootCertPEM2)
+	require.Equal(t, serverCertPEM, serverCertPEM2)
+
+	// Finish the rollover.
+	require.NoError(t, FinishCARollover(db))
+
+	inProgress, err = IsCARolloverInProgress(db)
+	require.NoError(t, err)
+	require.False(t, inProgress)
+
+	rootCertPEM3, serverCertPEM3, _, err := SetupServerCerts(db)
+	require.NoError(t, err)
+	require.Equal(t, nextCACertPEM, rootCertPEM3)
+	require.NotEqual(t, serverCertPEM, serverCertPEM3)
+
+	// The new server cert is signed by the new CA.
+	rootCert, err := pki.ParseCert(rootCertPEM3)
+	require.NoError(t, err)
+	serverCert, err := pki.ParseCert(serverCertPEM3)
+	require.NoError(t, err)
+	require.NoError(t, serverCert.CheckSignatureFrom(rootCert))
+}
+
+// Check if the PEM contents are joined with the new lines.
+func TestJoinPEM(t *testing.T) {
+	require.Equal(t, []byte("foo\nbar\n"), joinPEM([]byte("foo"), nil, []byte("bar\n")))
+	require.Nil(t, joinPEM(nil))
+}
@@ -64,8 +64,22 @@ type KeaModule interface {
 	ApplyHostUpdate(context.Context, *dbmodel.Host) (context.Context, error)
 	BeginHostDelete(context.Context) (context.Context, error)
 	ApplyHostDelete(context.Context, *dbmodel.Host) (context.Context, error)
+	BeginSubnetAdd(context.Context) (context.Context, error)
+	ApplySubnetAdd(context.Context, *dbmodel.Subnet) (context.Context, error)
 	BeginSubnetUpdate(context.Context, int64) (context.Context, error)
 	ApplySubnetUpdate(context.Context, *dbmodel.Subnet) (context.Context, error)
+	BeginSubnetDelete(context.Context, int64) (context.Context, error)
+	ApplySubnetDelete(context.Context, *dbmodel.Subnet) (context.Context, error)
+	BeginSharedNetworkAdd(context.Context) (context.Context, error)
+	ApplySharedNetworkAdd(context.Context, *dbmodel.SharedNetwork) (context.Context, error)
+	BeginSharedNetworkUpdate(context.Context, int64) (context.Context, error)
+	ApplySharedNetworkUpdate(context.Context, *dbmodel.SharedNetwork) (context.Context, error)
+	BeginSharedNetworkDelete(context.Context, int64) (context.Context, error)
+	ApplySharedNetworkDelete(context.Context, *dbmodel.SharedNetwork) (context.Context, error)
+	BeginGlobalParametersUpdate(context.Context, ...int64) (context.Context, error)
+	ApplyGlobalParametersUpdate(context.Context, keaconfig.RawConfig) (context.Context, error)
+	BeginConfigRollback(context.Context, int64) (context.Context, error)
+	ApplyConfigRollback(context.Context, *dbmodel.KeaDaemonConfigVersion) (context.Context, error)
 }
 
 // Interface of the Kea configuration module used by the manager to
@@ -89,8 +103,9 @@ type Manager interface {
 	Done(context.Context)
 	// Sends configuration changes to the daemons.
 	Commit(context.Context) (context.Context, error)
-	// Sends scheduled configuration changes to the daemons.
-	CommitDue() error
+	// Sends scheduled configuration changes to the daemons and returns
+	// the IDs of the executed changes.
+	CommitDue() ([]int64, error)
 	// Schedules configuration changes to apply them in the future.
 	Schedule(context.Context, time.Time) (context.Context, error)
 }
@@ -36,6 +36,102 @@ func (e SubnetNotFoundError) Error() string {
 	return fmt.Sprintf("subnet with ID %d not found", e.subnetID)
 }
 
+// An error returned when specified shared network is not found in the database.
+type SharedNetworkNotFoundError struct {
+	sharedNetworkID int64
+}
+
+// Create new instance of the SharedNetworkNotFoundError.
+func NewSharedNetworkNotFoundError(sharedNetworkID int64) error {
+	return &SharedNetworkNotFoundError{
+		sharedNetworkID: sharedNetworkID,
+	}
+}
+
+// Returns error string.
+func (e SharedNetworkNotFoundError) Error() string {
+	return fmt.Sprintf("shared network with ID %d not found", e.sharedNetworkID)
+}
+
+// An error returned when specified daemon is not found in the database.
+type DaemonNotFoundError struct {
+	daemonID int64
+}
+
+// Create new instance of the DaemonNotFoundError.
+func NewDaemonNotFoundError(daemonID int64) error {
+	return &DaemonNotFoundError{
+		daemonID: daemonID,
+	}
+}
+
+// Returns error string.
+func (e DaemonNotFoundError) Error() string {
+	return fmt.Sprintf("daemon with ID %d not found", e.daemonID)
+}
+
+// An error returned when specified configuration version is not found
+// in the database.
+type ConfigVersionNotFoundError struct {
+	versionID int64
+}
+
+// Create new instance of the ConfigVersionNotFoundError.
+func NewConfigVersionNotFoundError(versionID int64) error {
+	return &ConfigVersionNotFoundError{
+		versionID: versionID,
+	}
+}
+
+// Returns error string.
+func (e ConfigVersionNotFoundError) Error() string {
+	return fmt.Sprintf("configuration version with ID %d not found", e.versionID)
+}
+
+// An error returned when the daemon's configuration can't be changed
+// because the daemon fetches it from the config backend.
+type ConfigBackendError struct {
+	daemonID int64
+}
+
+// Create new instance of the ConfigBackendError.
+func NewConfigBackendError(daemonID int64) error {
+	return &ConfigBackendError{
+		daemonID: daemonID,
+	}
+}
+
+// Returns error string.
+func (e ConfigBackendError) Error() string {
+	return fmt.Sprintf("daemon with ID %d uses the config backend", e.daemonID)
+}
+
+// An error returned when a daemon rejected the configuration sent in
+// the config-test command. It holds the daemon and app names and the
+// text returned by the daemon, so the caller can report the details
+// of the failure to the user.
+type ConfigTestError struct {
+	DaemonID   int64
+	DaemonName string
+	AppName    string
+	Text       string
+}
+
+// Create new instance of the ConfigTestError.
+func NewConfigTestError(daemonID int64, daemonName, appName, text string) error {
+	return &ConfigTestError{
+		DaemonID:   daemonID,
+		DaemonName: daemonName,
+		AppName:    appName,
+		Text:       text,
+	}
+}
+
+// Returns error string.
+func (e ConfigTestError) Error() string {
+	return fmt.Sprintf("configuration rejected by %s daemon in %s: %s", e.DaemonName, e.AppName, e.Text)
+}
+
 // An error returned when it was not possible to lock daemons' configuration.
 type LockError struct{}
 
@@ -18,6 +18,46 @@ func TestSubnetNotFoundError(t *testing.T) {
 	require.EqualError(t, err, "subnet with ID 234 not found")
 }
 
+// Test creation of an error which indicates that shared network was not found.
+func TestSharedNetworkNotFoundError(t *testing.T) {
+	err := NewSharedNetworkNotFoundError(345)
+	require.EqualError(t, err, "shared network with ID 345 not found")
+}
+
+// Test creation of an error which indicates that daemon was not found.
+func TestDaemonNotFoundError(t *testing.T) {
+	err := NewDaemonNotFoundError(456)
+	require.EqualError(t, err, "daemon with ID 456 not found")
+}
+
+// Test creation of an error which indicates that the configuration version
+// doesn't exist.
+func TestConfigVersionNotFoundError(t *testing.T) {
+	err := NewConfigVersionNotFoundError(789)
+	require.EqualError(t, err, "configuration version with ID 789 not found")
+}
+
+// Test creation of an error which indicates that daemon uses the config
+// backend.
+func TestConfigBackendError(t *testing.T) {
+	err := NewConfigBackendError(567)
+	require.EqualError(t, err, "daemon with ID 567 uses the config backend")
+}
+
+// Test creation of an error which indicates that the daemon rejected
+// the tested configuration.
+func TestConfigTestError(t *testing.T) {
+	err := NewConfigTestError(678, "dhcp4", "kea@192.0.2.1", "unsupported parameter")
+	require.EqualError(t, err, "configuration rejected by dhcp4 daemon in kea@192.0.2.1: unsupported parameter")
+
+	var testErr *ConfigTestError
+	require.ErrorAs(t, err, &testErr)
+	require.EqualValues(t, 678, testErr.DaemonID)
+	require.Equal(t, "dhcp4", testErr.DaemonName)
+	require.Equal(t, "kea@192.0.2.1", testErr.AppName)
+	require.Equal(t, "unsupported parameter", testErr.Text)
+}
+
 // Test creation of an error which indicates a problem with locking
 // configuration.
 func TestLockError(t *testing.T) {
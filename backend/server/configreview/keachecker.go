@@ -792,8 +792,13 @@ func highAvailabilityDedicatedPorts(ctx *ReviewContext) (*Report, error) {
 	}
 
 	// The loop checks if the subject daemon connects directly to the
-	// dedicated listeners on the external peers.
-	for _, peer := range haConfig.GetFirst().Peers {
+	// dedicated listeners on the external peers. The hub in the
+	// hub-and-spoke configuration has peers in several relationships.
+	var peers []keaconfig.Peer
+	for _, relationship := range haConfig.HA {
+		peers = append(peers, relationship.Peers...)
+	}
+	for _, peer := range peers {
 		if !peer.IsValid() {
 			// Invalid peer. Skip.
 			continue
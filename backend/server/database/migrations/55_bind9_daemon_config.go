@@ -0,0 +1,20 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration adds a column holding the parsed BIND 9 configuration.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE bind9_daemon
+				ADD COLUMN IF NOT EXISTS config JSONB;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE bind9_daemon
+				DROP COLUMN IF EXISTS config;
+		`)
+		return err
+	})
+}
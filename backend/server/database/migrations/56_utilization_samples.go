@@ -0,0 +1,52 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration adds a table holding the history of the address and
+// delegated prefix utilization of the subnets, shared networks and
+// global utilization. The samples without a subnet and a shared network
+// hold the global utilization for the given family. The samples are
+// downsampled into the hourly and daily tiers.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS utilization_sample (
+				id BIGSERIAL NOT NULL,
+				tier TEXT NOT NULL,
+				subnet_id BIGINT,
+				shared_network_id BIGINT,
+				family SMALLINT NOT NULL,
+				sampled_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				addr_utilization SMALLINT NOT NULL,
+				pd_utilization SMALLINT NOT NULL,
+				sample_count BIGINT NOT NULL DEFAULT 1,
+				CONSTRAINT utilization_sample_pkey PRIMARY KEY (id),
+				CONSTRAINT utilization_sample_subnet_id_fkey FOREIGN KEY (subnet_id)
+					REFERENCES subnet (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT utilization_sample_shared_network_id_fkey FOREIGN KEY (shared_network_id)
+					REFERENCES shared_network (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT utilization_sample_tier_check CHECK (tier IN ('raw', 'hourly', 'daily')),
+				CONSTRAINT utilization_sample_family_check CHECK (family IN (4, 6)),
+				CONSTRAINT utilization_sample_scope_check CHECK (subnet_id IS NULL OR shared_network_id IS NULL)
+			);
+
+			-- Each series has at most one sample at a time in a tier. The
+			-- global series have no subnet and shared network.
+			CREATE UNIQUE INDEX IF NOT EXISTS utilization_sample_series_idx
+				ON utilization_sample (tier, COALESCE(subnet_id, 0), COALESCE(shared_network_id, 0), family, sampled_at);
+
+			CREATE INDEX IF NOT EXISTS utilization_sample_sampled_at_idx
+				ON utilization_sample (tier, sampled_at);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS utilization_sample;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,32 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration adds the columns holding the projected times when the
+// addresses and delegated prefixes in the subnets and shared networks run
+// out.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE subnet
+				ADD COLUMN IF NOT EXISTS addr_exhaustion_at TIMESTAMP WITHOUT TIME ZONE,
+				ADD COLUMN IF NOT EXISTS pd_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+
+			ALTER TABLE shared_network
+				ADD COLUMN IF NOT EXISTS addr_exhaustion_at TIMESTAMP WITHOUT TIME ZONE,
+				ADD COLUMN IF NOT EXISTS pd_exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE subnet
+				DROP COLUMN IF EXISTS addr_exhaustion_at,
+				DROP COLUMN IF EXISTS pd_exhaustion_at;
+
+			ALTER TABLE shared_network
+				DROP COLUMN IF EXISTS addr_exhaustion_at,
+				DROP COLUMN IF EXISTS pd_exhaustion_at;
+		`)
+		return err
+	})
+}
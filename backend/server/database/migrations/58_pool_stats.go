@@ -0,0 +1,69 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration adds the columns holding the lease statistics, the
+// utilization and the projected time when the address and prefix pools run
+// out. It also adds the table holding the recent utilization history of the
+// pools used to project their exhaustion. Only the samples within the
+// forecast window are kept.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE address_pool
+				ADD COLUMN IF NOT EXISTS stats JSONB,
+				ADD COLUMN IF NOT EXISTS stats_collected_at TIMESTAMP WITHOUT TIME ZONE,
+				ADD COLUMN IF NOT EXISTS utilization SMALLINT,
+				ADD COLUMN IF NOT EXISTS exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+
+			ALTER TABLE prefix_pool
+				ADD COLUMN IF NOT EXISTS stats JSONB,
+				ADD COLUMN IF NOT EXISTS stats_collected_at TIMESTAMP WITHOUT TIME ZONE,
+				ADD COLUMN IF NOT EXISTS utilization SMALLINT,
+				ADD COLUMN IF NOT EXISTS exhaustion_at TIMESTAMP WITHOUT TIME ZONE;
+
+			CREATE TABLE IF NOT EXISTS pool_utilization_sample (
+				id BIGSERIAL NOT NULL,
+				address_pool_id BIGINT,
+				prefix_pool_id BIGINT,
+				sampled_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				utilization SMALLINT NOT NULL,
+				CONSTRAINT pool_utilization_sample_pkey PRIMARY KEY (id),
+				CONSTRAINT pool_utilization_sample_address_pool_id_fkey FOREIGN KEY (address_pool_id)
+					REFERENCES address_pool (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT pool_utilization_sample_prefix_pool_id_fkey FOREIGN KEY (prefix_pool_id)
+					REFERENCES prefix_pool (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT pool_utilization_sample_pool_check CHECK ((address_pool_id IS NULL) <> (prefix_pool_id IS NULL))
+			);
+
+			-- Each pool has at most one sample at a time.
+			CREATE UNIQUE INDEX IF NOT EXISTS pool_utilization_sample_series_idx
+				ON pool_utilization_sample (COALESCE(address_pool_id, 0), COALESCE(prefix_pool_id, 0), sampled_at);
+
+			CREATE INDEX IF NOT EXISTS pool_utilization_sample_sampled_at_idx
+				ON pool_utilization_sample (sampled_at);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS pool_utilization_sample;
+
+			ALTER TABLE address_pool
+				DROP COLUMN IF EXISTS stats,
+				DROP COLUMN IF EXISTS stats_collected_at,
+				DROP COLUMN IF EXISTS utilization,
+				DROP COLUMN IF EXISTS exhaustion_at;
+
+			ALTER TABLE prefix_pool
+				DROP COLUMN IF EXISTS stats,
+				DROP COLUMN IF EXISTS stats_collected_at,
+				DROP COLUMN IF EXISTS utilization,
+				DROP COLUMN IF EXISTS exhaustion_at;
+		`)
+		return err
+	})
+}
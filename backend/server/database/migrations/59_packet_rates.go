@@ -0,0 +1,60 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration creates the table holding the numbers of the packets
+// counted by the Kea daemons during the intervals between the statistics
+// pulls. The rows without the subnet hold the daemon-wide counters. It
+// also adds the columns holding the rates of the leases assigned in the
+// subnets and shared networks.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS packet_rate_interval (
+				id BIGSERIAL NOT NULL,
+				daemon_id BIGINT NOT NULL,
+				subnet_id BIGINT,
+				start_time TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				duration BIGINT NOT NULL,
+				assigned_leases BIGINT NOT NULL DEFAULT 0,
+				naks BIGINT NOT NULL DEFAULT 0,
+				declines BIGINT NOT NULL DEFAULT 0,
+				drops BIGINT NOT NULL DEFAULT 0,
+				CONSTRAINT packet_rate_interval_pkey PRIMARY KEY (id),
+				CONSTRAINT packet_rate_interval_daemon_id_fkey FOREIGN KEY (daemon_id)
+					REFERENCES daemon (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT packet_rate_interval_subnet_id_fkey FOREIGN KEY (subnet_id)
+					REFERENCES subnet (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS packet_rate_interval_start_time_idx
+				ON packet_rate_interval (start_time);
+
+			ALTER TABLE subnet
+				ADD COLUMN IF NOT EXISTS lease_rate1 DOUBLE PRECISION,
+				ADD COLUMN IF NOT EXISTS lease_rate2 DOUBLE PRECISION;
+
+			ALTER TABLE shared_network
+				ADD COLUMN IF NOT EXISTS lease_rate1 DOUBLE PRECISION,
+				ADD COLUMN IF NOT EXISTS lease_rate2 DOUBLE PRECISION;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS packet_rate_interval;
+
+			ALTER TABLE subnet
+				DROP COLUMN IF EXISTS lease_rate1,
+				DROP COLUMN IF EXISTS lease_rate2;
+
+			ALTER TABLE shared_network
+				DROP COLUMN IF EXISTS lease_rate1,
+				DROP COLUMN IF EXISTS lease_rate2;
+		`)
+		return err
+	})
+}
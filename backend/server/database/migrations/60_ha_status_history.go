@@ -0,0 +1,64 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration creates the tables holding the history of the HA status.
+// The first table holds the HA state transitions of the servers. The
+// second table holds the failover-related counters of the servers
+// sampled when they change.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS ha_state_transition (
+				id BIGSERIAL NOT NULL,
+				service_id BIGINT NOT NULL,
+				daemon_id BIGINT NOT NULL,
+				previous_state TEXT,
+				state TEXT NOT NULL,
+				transitioned_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				CONSTRAINT ha_state_transition_pkey PRIMARY KEY (id),
+				CONSTRAINT ha_state_transition_service_id_fkey FOREIGN KEY (service_id)
+					REFERENCES service (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT ha_state_transition_daemon_id_fkey FOREIGN KEY (daemon_id)
+					REFERENCES daemon (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS ha_state_transition_service_id_transitioned_at_idx
+				ON ha_state_transition (service_id, transitioned_at);
+
+			CREATE TABLE IF NOT EXISTS ha_clients_sample (
+				id BIGSERIAL NOT NULL,
+				service_id BIGINT NOT NULL,
+				daemon_id BIGINT NOT NULL,
+				collected_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				connecting_clients BIGINT NOT NULL DEFAULT 0,
+				unacked_clients BIGINT NOT NULL DEFAULT 0,
+				unacked_clients_left BIGINT NOT NULL DEFAULT 0,
+				analyzed_packets BIGINT NOT NULL DEFAULT 0,
+				CONSTRAINT ha_clients_sample_pkey PRIMARY KEY (id),
+				CONSTRAINT ha_clients_sample_service_id_fkey FOREIGN KEY (service_id)
+					REFERENCES service (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT ha_clients_sample_daemon_id_fkey FOREIGN KEY (daemon_id)
+					REFERENCES daemon (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS ha_clients_sample_service_id_collected_at_idx
+				ON ha_clients_sample (service_id, collected_at);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS ha_clients_sample;
+			DROP TABLE IF EXISTS ha_state_transition;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,23 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration adds the column holding the name of the HA relationship
+// to the ha_service table. A Kea server being a hub in the hub-and-spoke
+// configuration belongs to multiple HA relationships. The relationship is
+// identified by the name of its primary server.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE ha_service
+				ADD COLUMN IF NOT EXISTS relationship TEXT;
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			ALTER TABLE ha_service
+				DROP COLUMN IF EXISTS relationship;
+		`)
+		return err
+	})
+}
@@ -0,0 +1,48 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration creates the table holding the DNS zones served by the
+// BIND 9 daemons. The zones are pulled from the statistics channel of the
+// daemons. The next refresh time is returned by BIND 9 and the last
+// refresh time is tracked by the server. The flags indicate whether the
+// serial of the secondary zone falls behind the serial of the primary zone
+// for too long and whether the zone transfer has failed. The time when the
+// serial started falling behind is stored to delay reporting the lag.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS zone (
+				id BIGSERIAL NOT NULL,
+				daemon_id BIGINT NOT NULL,
+				view TEXT NOT NULL,
+				name TEXT NOT NULL,
+				class TEXT NOT NULL,
+				type TEXT NOT NULL,
+				serial BIGINT,
+				loaded_at TIMESTAMP WITHOUT TIME ZONE,
+				expires_at TIMESTAMP WITHOUT TIME ZONE,
+				next_refresh_at TIMESTAMP WITHOUT TIME ZONE,
+				refreshed_at TIMESTAMP WITHOUT TIME ZONE,
+				serial_lagging BOOLEAN NOT NULL DEFAULT FALSE,
+				serial_lagging_since TIMESTAMP WITHOUT TIME ZONE,
+				transfer_failed BOOLEAN NOT NULL DEFAULT FALSE,
+				updated_at TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+				CONSTRAINT zone_pkey PRIMARY KEY (id),
+				CONSTRAINT zone_daemon_id_view_name_class_key UNIQUE (daemon_id, view, name, class),
+				CONSTRAINT zone_daemon_id_fkey FOREIGN KEY (daemon_id)
+					REFERENCES daemon (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS zone_name_idx ON zone (name);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS zone;
+		`)
+		return err
+	})
+}
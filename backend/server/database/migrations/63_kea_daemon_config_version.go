@@ -0,0 +1,60 @@
+package dbmigs
+
+import "github.com/go-pg/migrations/v8"
+
+// The migration creates the table holding the versions of the Kea daemons'
+// configurations. A new version is stored whenever the configuration of
+// the daemon differs from its recent version. The version is associated
+// with the user and the config transaction operation if the configuration
+// has been changed by Stork. The second table holds the configuration
+// changes made by Stork that will be included in the next version pulled
+// from the daemon.
+func init() {
+	migrations.MustRegisterTx(func(db migrations.DB) error {
+		_, err := db.Exec(`
+			CREATE TABLE IF NOT EXISTS kea_daemon_config_version (
+				id BIGSERIAL NOT NULL,
+				daemon_id BIGINT NOT NULL,
+				config JSONB NOT NULL,
+				config_hash TEXT NOT NULL,
+				created_at TIMESTAMP WITHOUT TIME ZONE DEFAULT timezone('utc'::text, now()) NOT NULL,
+				user_id BIGINT,
+				operation TEXT,
+				CONSTRAINT kea_daemon_config_version_pkey PRIMARY KEY (id),
+				CONSTRAINT kea_daemon_config_version_daemon_id_fkey FOREIGN KEY (daemon_id)
+					REFERENCES daemon (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT kea_daemon_config_version_user_id_fkey FOREIGN KEY (user_id)
+					REFERENCES system_user (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS kea_daemon_config_version_daemon_id_idx
+				ON kea_daemon_config_version (daemon_id, id);
+
+			CREATE TABLE IF NOT EXISTS kea_daemon_pending_config_change (
+				daemon_id BIGINT NOT NULL,
+				user_id BIGINT,
+				operation TEXT NOT NULL,
+				CONSTRAINT kea_daemon_pending_config_change_pkey PRIMARY KEY (daemon_id),
+				CONSTRAINT kea_daemon_pending_config_change_daemon_id_fkey FOREIGN KEY (daemon_id)
+					REFERENCES daemon (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE CASCADE,
+				CONSTRAINT kea_daemon_pending_config_change_user_id_fkey FOREIGN KEY (user_id)
+					REFERENCES system_user (id) MATCH SIMPLE
+					ON UPDATE CASCADE
+					ON DELETE SET NULL
+			);
+		`)
+		return err
+	}, func(db migrations.DB) error {
+		_, err := db.Exec(`
+			DROP TABLE IF EXISTS kea_daemon_pending_config_change;
+			DROP TABLE IF EXISTS kea_daemon_config_version;
+		`)
+		return err
+	})
+}
@@ -19,10 +19,14 @@ type AccessPoint struct {
 	UseSecureProtocol bool `pg:",use_zero"`
 }
 
-// Valid kinds of the access points.
+// Valid kinds of the access points. The control socket access point is
+// used by the Kea daemons controlled directly over the UNIX domain socket
+// rather than through the Kea Control Agent. Its address holds the socket
+// path and the port is zero.
 const (
-	AccessPointControl    = "control"
-	AccessPointStatistics = "statistics"
+	AccessPointControl       = "control"
+	AccessPointControlSocket = "control-socket"
+	AccessPointStatistics    = "statistics"
 )
 
 // AppendAccessPoint is an utility function that appends an access point to a
@@ -511,6 +511,28 @@ func (app *App) GetAccessPoint(accessPointType string) (ap *AccessPoint, err err
 	return nil, pkgerrors.Errorf("no access point of type %s found for app ID %d", accessPointType, app.ID)
 }
 
+// FindControlAccessPoint returns the access point used to control the app.
+// It is the control access point if the app has one. Otherwise, it is the
+// control socket access point of the Kea daemon running without the Kea
+// Control Agent.
+func (app *App) FindControlAccessPoint() (*AccessPoint, error) {
+	ap, err := app.GetAccessPoint(AccessPointControl)
+	if err == nil {
+		return ap, nil
+	}
+	if ap, errSocket := app.GetAccessPoint(AccessPointControlSocket); errSocket == nil {
+		return ap, nil
+	}
+	return nil, err
+}
+
+// UsesControlSocket returns true if the app is controlled directly over
+// the UNIX domain socket rather than through the network access point.
+func (app App) UsesControlSocket() bool {
+	ap, err := app.FindControlAccessPoint()
+	return err == nil && ap.Type == AccessPointControlSocket
+}
+
 // AppTag implementation.
 
 // Returns app ID.
@@ -541,10 +563,12 @@ func (app App) GetMachineID() int64 {
 // Remaining functions for the agentcomm.ControlledApp implementation.
 
 // Returns app control access point including control address, port and
-// the flag indicating if the connection is secure.
+// the flag indicating if the connection is secure. If the app is
+// controlled over the UNIX domain socket, the returned address is the
+// socket path and the port is zero.
 func (app App) GetControlAccessPoint() (address string, port int64, key string, secure bool, err error) {
 	var ap *AccessPoint
-	ap, err = app.GetAccessPoint(AccessPointControl)
+	ap, err = app.FindControlAccessPoint()
 	if err == nil {
 		address = ap.Address
 		port = ap.Port
@@ -1423,6 +1423,29 @@ func TestGetControlAccessPoint(t *testing.T) {
 	require.True(t, secure)
 }
 
+// Test that the control socket access point is returned when the app
+// has no control access point.
+func TestGetControlAccessPointSocket(t *testing.T) {
+	app := &App{}
+	require.False(t, app.UsesControlSocket())
+
+	app.AccessPoints = AppendAccessPoint(app.AccessPoints, AccessPointControlSocket, "/run/kea/kea4-ctrl-socket", "", 0, false)
+	address, port, _, secure, err := app.GetControlAccessPoint()
+	require.NoError(t, err)
+	require.Equal(t, "/run/kea/kea4-ctrl-socket", address)
+	require.Zero(t, port)
+	require.False(t, secure)
+	require.True(t, app.UsesControlSocket())
+
+	// The control access point takes precedence.
+	app.AccessPoints = AppendAccessPoint(app.AccessPoints, AccessPointControl, "localhost", "", 8000, false)
+	address, port, _, _, err = app.GetControlAccessPoint()
+	require.NoError(t, err)
+	require.Equal(t, "localhost", address)
+	require.EqualValues(t, 8000, port)
+	require.False(t, app.UsesControlSocket())
+}
+
 // Test getting MachineTag interface from an app.
 func TestGetMachineTag(t *testing.T) {
 	app := App{
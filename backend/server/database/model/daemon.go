@@ -10,6 +10,7 @@ import (
 
 	"github.com/go-pg/pg/v10"
 	pkgerrors "github.com/pkg/errors"
+	bind9config "isc.org/stork/appcfg/bind9"
 	dbops "isc.org/stork/server/database"
 	storkutil "isc.org/stork/util"
 )
@@ -30,6 +31,14 @@ const (
 type KeaDHCPDaemonStats struct {
 	RPS1 int `pg:"rps1"`
 	RPS2 int `pg:"rps2"`
+	// Numbers of the NAKs sent, the declines received and the packets
+	// dropped per second over the short and long RPS intervals.
+	NAKRate1     float64 `pg:"nak_rate1"`
+	NAKRate2     float64 `pg:"nak_rate2"`
+	DeclineRate1 float64 `pg:"decline_rate1"`
+	DeclineRate2 float64 `pg:"decline_rate2"`
+	DropRate1    float64 `pg:"drop_rate1"`
+	DropRate2    float64 `pg:"drop_rate2"`
 }
 
 // A structure holding Kea DHCP specific information about a daemon. It
@@ -174,6 +183,7 @@ type Bind9Daemon struct {
 	ID       int64
 	DaemonID int64
 	Stats    Bind9DaemonStats
+	Config   *bind9config.Config
 }
 
 // A structure reflecting all SQL tables holding information about the
@@ -209,8 +219,10 @@ type Daemon struct {
 }
 
 // Structure representing HA service information displayed for the daemon
-// in the dashboard.
+// in the dashboard. There is one overview for each HA relationship of the
+// daemon.
 type DaemonServiceOverview struct {
+	Relationship  string
 	State         string
 	LastFailureAt time.Time
 }
@@ -284,6 +296,18 @@ func GetKeaDHCPDaemons(dbi pg.DBI) (daemons []Daemon, err error) {
 	return
 }
 
+// Get the BIND 9 daemon details, including the configurations, of all
+// BIND 9 daemons.
+func GetAllBind9Daemons(dbi pg.DBI) (daemons []Bind9Daemon, err error) {
+	err = dbi.Model(&daemons).
+		OrderExpr("daemon_id ASC").
+		Select()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem with getting BIND 9 daemons")
+	}
+	return
+}
+
 // Select one or more daemons for update. The main use case for this function is
 // to prevent modifications and deletions of the daemons while the server inserts
 // config reports for them. It must be called within a transaction and the selected
@@ -486,6 +510,7 @@ func (d *Daemon) GetHAOverview() (overviews []DaemonServiceOverview) {
 			continue
 		}
 		var overview DaemonServiceOverview
+		overview.Relationship = service.HAService.Relationship
 		overview.State = service.GetDaemonHAState(d.ID)
 		overview.LastFailureAt = service.GetPartnerHAFailureTime(d.ID)
 		overviews = append(overviews, overview)
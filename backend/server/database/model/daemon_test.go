@@ -7,6 +7,7 @@ import (
 
 	"github.com/go-pg/pg/v10"
 	require "github.com/stretchr/testify/require"
+	bind9config "isc.org/stork/appcfg/bind9"
 	dbtest "isc.org/stork/server/database/test"
 )
 
@@ -147,6 +148,8 @@ func TestUpdateBind9Daemon(t *testing.T) {
 	daemon.Version = "9.20"
 
 	daemon.Bind9Daemon.Stats.ZoneCount = 123
+	daemon.Bind9Daemon.Config, err = bind9config.Parse(`zone "example.org" { type primary; };`)
+	require.NoError(t, err)
 
 	err = UpdateDaemon(db, daemon)
 	require.NoError(t, err)
@@ -163,6 +166,10 @@ func TestUpdateBind9Daemon(t *testing.T) {
 	require.Equal(t, "9.20", daemon.Version)
 	require.NotNil(t, daemon.Bind9Daemon)
 	require.EqualValues(t, 123, daemon.Bind9Daemon.Stats.ZoneCount)
+	require.NotNil(t, daemon.Bind9Daemon.Config)
+	zones := daemon.Bind9Daemon.Config.GetZones()
+	require.Len(t, zones, 1)
+	require.Equal(t, "example.org", zones[0].Name)
 }
 
 // Returns all HA state names to which the daemon belongs and the
@@ -178,6 +185,7 @@ func TestGetHAOverview(t *testing.T) {
 			},
 			HAService: &BaseHAService{
 				HAType:                "dhcp4",
+				Relationship:          "server1",
 				PrimaryID:             1,
 				SecondaryID:           2,
 				BackupID:              []int64{3, 4},
@@ -208,6 +216,7 @@ func TestGetHAOverview(t *testing.T) {
 	overviews := daemon.GetHAOverview()
 	require.Len(t, overviews, 2)
 
+	require.Equal(t, "server1", overviews[0].Relationship)
 	require.Equal(t, "load-balancing", overviews[0].State)
 	require.Zero(t, overviews[0].LastFailureAt)
 
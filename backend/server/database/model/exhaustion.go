@@ -0,0 +1,192 @@
+package dbmodel
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// The format of the times passed as text to the queries updating many rows
+// at once.
+const batchUpdateTimeFormat = "2006-01-02 15:04:05.999999"
+
+// A single sample of the utilization of an address or delegated prefix
+// pool. The samples are used to project the pool exhaustion and they are
+// kept only for the forecast window.
+type PoolUtilizationSample struct {
+	ID            int64
+	AddressPoolID int64
+	PrefixPoolID  int64
+	SampledAt     time.Time
+	Utilization   int16 `pg:",use_zero"`
+}
+
+// Inserts the pool utilization samples. The samples duplicating the
+// existing ones, i.e., the samples for the same pool at the same time, are
+// ignored.
+func AddPoolUtilizationSamples(dbi dbops.DBI, samples []*PoolUtilizationSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	_, err := dbi.Model(&samples).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem inserting %d pool utilization samples", len(samples))
+	}
+	return err
+}
+
+// Returns the pool utilization samples within the time range ordered by
+// time.
+func GetPoolUtilizationSamples(dbi dbops.DBI, from, to time.Time) ([]*PoolUtilizationSample, error) {
+	samples := []*PoolUtilizationSample{}
+	err := dbi.Model(&samples).
+		Where("sampled_at >= ?", from).
+		Where("sampled_at <= ?", to).
+		Order("sampled_at ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting pool utilization samples")
+	}
+	return samples, nil
+}
+
+// Deletes the pool utilization samples older than the specified time. It
+// returns the number of the deleted samples.
+func DeletePoolUtilizationSamplesBefore(dbi dbops.DBI, before time.Time) (int, error) {
+	result, err := dbi.Model((*PoolUtilizationSample)(nil)).
+		Where("sampled_at < ?", before).
+		Delete()
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem deleting pool utilization samples older than %s", before)
+	}
+	return result.RowsAffected(), nil
+}
+
+// Returns the address pools with the pulled statistics along with their
+// local subnets. Only the columns needed to project the pool exhaustion
+// are selected.
+func GetAddressPoolsWithStats(dbi dbops.DBI) ([]*AddressPool, error) {
+	pools := []*AddressPool{}
+	err := dbi.Model(&pools).
+		Column("address_pool.id", "lower_bound", "upper_bound", "local_subnet_id", "stats_collected_at", "utilization", "exhaustion_at").
+		Relation("LocalSubnet").
+		Where("stats_collected_at IS NOT NULL").
+		OrderExpr("address_pool.id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting address pools with statistics")
+	}
+	return pools, nil
+}
+
+// Returns the delegated prefix pools with the pulled statistics along with
+// their local subnets. Only the columns needed to project the pool
+// exhaustion are selected.
+func GetPrefixPoolsWithStats(dbi dbops.DBI) ([]*PrefixPool, error) {
+	pools := []*PrefixPool{}
+	err := dbi.Model(&pools).
+		Column("prefix_pool.id", "prefix", "delegated_len", "local_subnet_id", "stats_collected_at", "utilization", "exhaustion_at").
+		Relation("LocalSubnet").
+		Where("stats_collected_at IS NOT NULL").
+		OrderExpr("prefix_pool.id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting prefix pools with statistics")
+	}
+	return pools, nil
+}
+
+// Formats the projected exhaustion time for the batch update. The zero
+// time is stored as NULL.
+func formatExhaustionForecastTime(exhaustionAt time.Time) string {
+	if exhaustionAt.IsZero() {
+		return ""
+	}
+	return exhaustionAt.UTC().Format(batchUpdateTimeFormat)
+}
+
+// Updates the projected exhaustion times of many rows of the table in a
+// single query. The values hold the times for each column in the order of
+// the IDs.
+func updateExhaustionForecasts(dbi dbops.DBI, table string, ids []int64, columns []string, values [][]time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	var (
+		assignments []string
+		selections  []string
+	)
+	params := []any{pg.Array(ids)}
+	for i, column := range columns {
+		assignments = append(assignments, fmt.Sprintf("%s = data.%s", column, column))
+		selections = append(selections, fmt.Sprintf("NULLIF(unnest(?::text[]), '')::timestamp AS %s", column))
+		formatted := make([]string, len(values[i]))
+		for j, value := range values[i] {
+			formatted[j] = formatExhaustionForecastTime(value)
+		}
+		params = append(params, pg.Array(formatted))
+	}
+	query := fmt.Sprintf(`
+		UPDATE %s SET %s
+		FROM (SELECT unnest(?::bigint[]) AS id, %s) AS data
+		WHERE %s.id = data.id`,
+		table, strings.Join(assignments, ", "), strings.Join(selections, ", "), table,
+	)
+	if _, err := dbi.Exec(query, params...); err != nil {
+		return pkgerrors.Wrapf(err, "problem updating exhaustion forecasts of %d rows in %s", len(ids), table)
+	}
+	return nil
+}
+
+// Updates the projected exhaustion times in the subnets in a single query.
+func UpdateExhaustionForecastsInSubnets(dbi dbops.DBI, subnets []*Subnet) error {
+	ids := make([]int64, len(subnets))
+	values := [][]time.Time{make([]time.Time, len(subnets)), make([]time.Time, len(subnets))}
+	for i, subnet := range subnets {
+		ids[i] = subnet.ID
+		values[0][i] = subnet.AddrExhaustionAt
+		values[1][i] = subnet.PdExhaustionAt
+	}
+	return updateExhaustionForecasts(dbi, "subnet", ids, []string{"addr_exhaustion_at", "pd_exhaustion_at"}, values)
+}
+
+// Updates the projected exhaustion times in the shared networks in a single
+// query.
+func UpdateExhaustionForecastsInSharedNetworks(dbi dbops.DBI, networks []*SharedNetwork) error {
+	ids := make([]int64, len(networks))
+	values := [][]time.Time{make([]time.Time, len(networks)), make([]time.Time, len(networks))}
+	for i, network := range networks {
+		ids[i] = network.ID
+		values[0][i] = network.AddrExhaustionAt
+		values[1][i] = network.PdExhaustionAt
+	}
+	return updateExhaustionForecasts(dbi, "shared_network", ids, []string{"addr_exhaustion_at", "pd_exhaustion_at"}, values)
+}
+
+// Updates the projected exhaustion times in the address pools in a single
+// query.
+func UpdateExhaustionForecastsInAddressPools(dbi dbops.DBI, pools []*AddressPool) error {
+	ids := make([]int64, len(pools))
+	values := [][]time.Time{make([]time.Time, len(pools))}
+	for i, pool := range pools {
+		ids[i] = pool.ID
+		values[0][i] = pool.ExhaustionAt
+	}
+	return updateExhaustionForecasts(dbi, "address_pool", ids, []string{"exhaustion_at"}, values)
+}
+
+// Updates the projected exhaustion times in the delegated prefix pools in
+// a single query.
+func UpdateExhaustionForecastsInPrefixPools(dbi dbops.DBI, pools []*PrefixPool) error {
+	ids := make([]int64, len(pools))
+	values := [][]time.Time{make([]time.Time, len(pools))}
+	for i, pool := range pools {
+		ids[i] = pool.ID
+		values[0][i] = pool.ExhaustionAt
+	}
+	return updateExhaustionForecasts(dbi, "prefix_pool", ids, []string{"exhaustion_at"}, values)
+}
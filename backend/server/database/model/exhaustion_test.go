@@ -0,0 +1,68 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the exhaustion forecasts of many subnets and shared networks
+// are updated at once.
+func TestUpdateExhaustionForecastsInSubnetsAndSharedNetworks(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	network := &SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	err := AddSharedNetwork(db, network)
+	require.NoError(t, err)
+
+	subnets := []*Subnet{
+		{Prefix: "192.0.2.0/24", SharedNetworkID: network.ID},
+		{Prefix: "2001:db8:1::/64"},
+	}
+	for _, subnet := range subnets {
+		err = AddSubnet(db, subnet)
+		require.NoError(t, err)
+	}
+
+	exhaustionAt := time.Date(2023, 5, 10, 12, 30, 15, 123000, time.UTC)
+	subnets[0].AddrExhaustionAt = exhaustionAt
+	subnets[1].PdExhaustionAt = exhaustionAt.Add(time.Hour)
+	err = UpdateExhaustionForecastsInSubnets(db, subnets)
+	require.NoError(t, err)
+
+	network.AddrExhaustionAt = exhaustionAt
+	err = UpdateExhaustionForecastsInSharedNetworks(db, []*SharedNetwork{network})
+	require.NoError(t, err)
+
+	returned, err := GetSubnet(db, subnets[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, exhaustionAt, returned.AddrExhaustionAt.UTC())
+	require.Zero(t, returned.PdExhaustionAt)
+
+	returned, err = GetSubnet(db, subnets[1].ID)
+	require.NoError(t, err)
+	require.Zero(t, returned.AddrExhaustionAt)
+	require.Equal(t, exhaustionAt.Add(time.Hour), returned.PdExhaustionAt.UTC())
+
+	returnedNetwork, err := GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.Equal(t, exhaustionAt, returnedNetwork.AddrExhaustionAt.UTC())
+	require.Zero(t, returnedNetwork.PdExhaustionAt)
+
+	// The zero time clears the forecast.
+	subnets[0].AddrExhaustionAt = time.Time{}
+	err = UpdateExhaustionForecastsInSubnets(db, subnets)
+	require.NoError(t, err)
+	returned, err = GetSubnet(db, subnets[0].ID)
+	require.NoError(t, err)
+	require.Zero(t, returned.AddrExhaustionAt)
+
+	// Updating no rows is not an error.
+	require.NoError(t, UpdateExhaustionForecastsInSubnets(db, nil))
+}
@@ -0,0 +1,228 @@
+package dbmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	errors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Transition of an HA server from one state to another. The previous
+// state is empty when the state of the server is observed for the first
+// time.
+type HAStateTransition struct {
+	ID             int64
+	ServiceID      int64
+	DaemonID       int64
+	PreviousState  HAState
+	State          HAState
+	TransitionedAt time.Time
+}
+
+// Failover-related counters of an HA server collected at a given time.
+// The counters are returned by the partner of the server and describe
+// the clients the partner has seen since the communication with the
+// server was interrupted.
+type HAClientsSample struct {
+	ID                 int64
+	ServiceID          int64
+	DaemonID           int64
+	CollectedAt        time.Time
+	ConnectingClients  int64 `pg:",use_zero"`
+	UnackedClients     int64 `pg:",use_zero"`
+	UnackedClientsLeft int64 `pg:",use_zero"`
+	AnalyzedPackets    int64 `pg:",use_zero"`
+}
+
+// Period of time during which an HA server was in the partner-down state.
+// The end time is zero if the failover is still in progress.
+type HAFailover struct {
+	DaemonID  int64
+	StartedAt time.Time
+	EndedAt   time.Time
+}
+
+// Returns the duration of the failover. The duration of the failover in
+// progress is calculated until the specified time.
+func (f HAFailover) GetDuration(now time.Time) time.Duration {
+	if f.EndedAt.IsZero() {
+		return now.Sub(f.StartedAt)
+	}
+	return f.EndedAt.Sub(f.StartedAt)
+}
+
+// Compares the HA service with its previous version and returns the
+// state transitions of the servers and the samples of their counters that
+// changed. The time of the transition is the time when the status of the
+// server was collected, or the specified time if the server is
+// unavailable.
+func (s *BaseHAService) GetStatusChanges(previous *BaseHAService, now time.Time) (transitions []*HAStateTransition, samples []*HAClientsSample) {
+	type serverStatus struct {
+		daemonID           int64
+		state              HAState
+		collectedAt        time.Time
+		connectingClients  int64
+		unackedClients     int64
+		unackedClientsLeft int64
+		analyzedPackets    int64
+	}
+	getServers := func(service *BaseHAService) []serverStatus {
+		return []serverStatus{
+			{
+				service.PrimaryID, service.PrimaryLastState, service.PrimaryStatusCollectedAt,
+				service.PrimaryConnectingClients, service.PrimaryUnackedClients,
+				service.PrimaryUnackedClientsLeft, service.PrimaryAnalyzedPackets,
+			},
+			{
+				service.SecondaryID, service.SecondaryLastState, service.SecondaryStatusCollectedAt,
+				service.SecondaryConnectingClients, service.SecondaryUnackedClients,
+				service.SecondaryUnackedClientsLeft, service.SecondaryAnalyzedPackets,
+			},
+		}
+	}
+	previousServers := getServers(previous)
+	for i, server := range getServers(s) {
+		// The secondary server doesn't exist in the passive-backup mode.
+		if server.daemonID == 0 {
+			continue
+		}
+		previousServer := previousServers[i]
+		if server.state != previousServer.state && server.state != HAStateNone {
+			transitionedAt := server.collectedAt
+			if server.state == HAStateUnavailable || transitionedAt.IsZero() {
+				transitionedAt = now
+			}
+			transitions = append(transitions, &HAStateTransition{
+				ServiceID:      s.ServiceID,
+				DaemonID:       server.daemonID,
+				PreviousState:  previousServer.state,
+				State:          server.state,
+				TransitionedAt: transitionedAt,
+			})
+		}
+		if server.connectingClients != previousServer.connectingClients ||
+			server.unackedClients != previousServer.unackedClients ||
+			server.unackedClientsLeft != previousServer.unackedClientsLeft ||
+			server.analyzedPackets != previousServer.analyzedPackets {
+			samples = append(samples, &HAClientsSample{
+				ServiceID:          s.ServiceID,
+				DaemonID:           server.daemonID,
+				CollectedAt:        now,
+				ConnectingClients:  server.connectingClients,
+				UnackedClients:     server.unackedClients,
+				UnackedClientsLeft: server.unackedClientsLeft,
+				AnalyzedPackets:    server.analyzedPackets,
+			})
+		}
+	}
+	return transitions, samples
+}
+
+// Adds the HA state transitions and the samples of the HA counters to
+// the database in a transaction.
+func addHAStatusHistory(tx *pg.Tx, transitions []*HAStateTransition, samples []*HAClientsSample) error {
+	if len(transitions) > 0 {
+		_, err := tx.Model(&transitions).Insert()
+		if err != nil {
+			return errors.Wrapf(err, "problem inserting %d HA state transitions", len(transitions))
+		}
+	}
+	if len(samples) > 0 {
+		_, err := tx.Model(&samples).Insert()
+		if err != nil {
+			return errors.Wrapf(err, "problem inserting %d HA clients samples", len(samples))
+		}
+	}
+	return nil
+}
+
+// Adds the HA state transitions and the samples of the HA counters to
+// the database.
+func AddHAStatusHistory(dbi dbops.DBI, transitions []*HAStateTransition, samples []*HAClientsSample) error {
+	if len(transitions) == 0 && len(samples) == 0 {
+		return nil
+	}
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return addHAStatusHistory(tx, transitions, samples)
+		})
+	}
+	return addHAStatusHistory(dbi.(*pg.Tx), transitions, samples)
+}
+
+// Deletes the HA state transitions and the samples of the HA counters
+// recorded before the specified time. It returns the total number of the
+// deleted rows.
+func DeleteHAStatusHistoryBefore(dbi dbops.DBI, before time.Time) (int, error) {
+	transitions, err := dbi.Model((*HAStateTransition)(nil)).
+		Where("transitioned_at < ?", before).
+		Delete()
+	if err != nil {
+		return 0, errors.Wrapf(err, "problem deleting HA state transitions older than %s", before)
+	}
+	samples, err := dbi.Model((*HAClientsSample)(nil)).
+		Where("collected_at < ?", before).
+		Delete()
+	if err != nil {
+		return 0, errors.Wrapf(err, "problem deleting HA clients samples older than %s", before)
+	}
+	return transitions.RowsAffected() + samples.RowsAffected(), nil
+}
+
+// Returns the HA state transitions of the service's servers that took
+// place at or after the specified time, ordered by time.
+func GetHAStateTransitions(dbi dbops.DBI, serviceID int64, since time.Time) ([]HAStateTransition, error) {
+	var transitions []HAStateTransition
+	err := dbi.Model(&transitions).
+		Where("service_id = ?", serviceID).
+		Where("transitioned_at >= ?", since).
+		OrderExpr("transitioned_at ASC").
+		OrderExpr("id ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem getting HA state transitions of service %d", serviceID)
+	}
+	return transitions, nil
+}
+
+// Returns the samples of the HA counters of the service's servers
+// collected at or after the specified time, ordered by time.
+func GetHAClientsSamples(dbi dbops.DBI, serviceID int64, since time.Time) ([]HAClientsSample, error) {
+	var samples []HAClientsSample
+	err := dbi.Model(&samples).
+		Where("service_id = ?", serviceID).
+		Where("collected_at >= ?", since).
+		OrderExpr("collected_at ASC").
+		OrderExpr("id ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem getting HA clients samples of service %d", serviceID)
+	}
+	return samples, nil
+}
+
+// Finds the failovers in the HA state transitions ordered by time. The
+// failover starts when a server transitions to the partner-down state and
+// ends when it leaves this state. The failovers started before the first
+// transition are not returned.
+func GetHAFailovers(transitions []HAStateTransition) []HAFailover {
+	var failovers []HAFailover
+	inProgress := make(map[int64]int)
+	for _, transition := range transitions {
+		if index, ok := inProgress[transition.DaemonID]; ok && transition.State != HAStatePartnerDown {
+			failovers[index].EndedAt = transition.TransitionedAt
+			delete(inProgress, transition.DaemonID)
+			continue
+		}
+		if _, ok := inProgress[transition.DaemonID]; !ok && transition.State == HAStatePartnerDown {
+			inProgress[transition.DaemonID] = len(failovers)
+			failovers = append(failovers, HAFailover{
+				DaemonID:  transition.DaemonID,
+				StartedAt: transition.TransitionedAt,
+			})
+		}
+	}
+	return failovers
+}
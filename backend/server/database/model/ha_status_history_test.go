@@ -0,0 +1,145 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the state transitions and the changed counters are detected
+// by comparing the HA service with its previous version.
+func TestHAServiceGetStatusChanges(t *testing.T) {
+	now := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	previous := &BaseHAService{
+		ServiceID:                5,
+		PrimaryID:                1,
+		SecondaryID:              2,
+		PrimaryLastState:         HAStateLoadBalancing,
+		SecondaryLastState:       HAStateLoadBalancing,
+		PrimaryStatusCollectedAt: now.Add(-time.Minute),
+	}
+	current := *previous
+	current.PrimaryLastState = HAStatePartnerDown
+	current.PrimaryStatusCollectedAt = now.Add(-10 * time.Second)
+	current.SecondaryLastState = HAStateUnavailable
+	current.SecondaryUnackedClients = 3
+
+	transitions, samples := current.GetStatusChanges(previous, now)
+	require.Len(t, transitions, 2)
+
+	require.EqualValues(t, 5, transitions[0].ServiceID)
+	require.EqualValues(t, 1, transitions[0].DaemonID)
+	require.Equal(t, HAStateLoadBalancing, transitions[0].PreviousState)
+	require.Equal(t, HAStatePartnerDown, transitions[0].State)
+	require.Equal(t, now.Add(-10*time.Second), transitions[0].TransitionedAt)
+
+	require.EqualValues(t, 2, transitions[1].DaemonID)
+	require.Equal(t, HAStateUnavailable, transitions[1].State)
+	require.Equal(t, now, transitions[1].TransitionedAt)
+
+	require.Len(t, samples, 1)
+	require.EqualValues(t, 2, samples[0].DaemonID)
+	require.EqualValues(t, 3, samples[0].UnackedClients)
+	require.Equal(t, now, samples[0].CollectedAt)
+
+	// No changes.
+	transitions, samples = current.GetStatusChanges(&current, now)
+	require.Empty(t, transitions)
+	require.Empty(t, samples)
+}
+
+// Test that the HA status history is stored and returned.
+func TestAddGetHAStatusHistory(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	services := addTestServices(t, db)
+	service := services[1]
+	primaryID := service.HAService.PrimaryID
+
+	startTime := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	err := AddHAStatusHistory(db, []*HAStateTransition{
+		{
+			ServiceID:      service.ID,
+			DaemonID:       primaryID,
+			PreviousState:  HAStatePartnerDown,
+			State:          HAStateLoadBalancing,
+			TransitionedAt: startTime.Add(time.Minute),
+		},
+		{
+			ServiceID:      service.ID,
+			DaemonID:       primaryID,
+			PreviousState:  HAStateLoadBalancing,
+			State:          HAStatePartnerDown,
+			TransitionedAt: startTime,
+		},
+	}, []*HAClientsSample{
+		{
+			ServiceID:      service.ID,
+			DaemonID:       primaryID,
+			CollectedAt:    startTime,
+			UnackedClients: 2,
+		},
+	})
+	require.NoError(t, err)
+
+	transitions, err := GetHAStateTransitions(db, service.ID, startTime)
+	require.NoError(t, err)
+	require.Len(t, transitions, 2)
+	require.Equal(t, HAStatePartnerDown, transitions[0].State)
+	require.Equal(t, HAStateLoadBalancing, transitions[1].State)
+
+	transitions, err = GetHAStateTransitions(db, service.ID, startTime.Add(time.Second))
+	require.NoError(t, err)
+	require.Len(t, transitions, 1)
+
+	samples, err := GetHAClientsSamples(db, service.ID, startTime)
+	require.NoError(t, err)
+	require.Len(t, samples, 1)
+	require.EqualValues(t, 2, samples[0].UnackedClients)
+	require.Zero(t, samples[0].ConnectingClients)
+
+	// Delete the history older than the second transition.
+	deleted, err := DeleteHAStatusHistoryBefore(db, startTime.Add(time.Second))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, deleted)
+	transitions, err = GetHAStateTransitions(db, service.ID, startTime)
+	require.NoError(t, err)
+	require.Len(t, transitions, 1)
+	require.Equal(t, HAStateLoadBalancing, transitions[0].State)
+	samples, err = GetHAClientsSamples(db, service.ID, startTime)
+	require.NoError(t, err)
+	require.Empty(t, samples)
+
+	// The history is deleted together with the service.
+	err = DeleteService(db, service.ID)
+	require.NoError(t, err)
+	transitions, err = GetHAStateTransitions(db, service.ID, startTime)
+	require.NoError(t, err)
+	require.Empty(t, transitions)
+}
+
+// Test that the failovers are found in the HA state transitions.
+func TestGetHAFailovers(t *testing.T) {
+	startTime := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	transitions := []HAStateTransition{
+		// The failover started before the first transition is ignored.
+		{DaemonID: 2, PreviousState: HAStatePartnerDown, State: HAStateWaiting, TransitionedAt: startTime},
+		{DaemonID: 1, PreviousState: HAStateLoadBalancing, State: HAStatePartnerDown, TransitionedAt: startTime.Add(time.Minute)},
+		{DaemonID: 2, PreviousState: HAStateWaiting, State: HAStateSyncing, TransitionedAt: startTime.Add(2 * time.Minute)},
+		{DaemonID: 1, PreviousState: HAStatePartnerDown, State: HAStateLoadBalancing, TransitionedAt: startTime.Add(3 * time.Minute)},
+		{DaemonID: 2, PreviousState: HAStateSyncing, State: HAStatePartnerDown, TransitionedAt: startTime.Add(4 * time.Minute)},
+	}
+
+	failovers := GetHAFailovers(transitions)
+	require.Len(t, failovers, 2)
+
+	require.EqualValues(t, 1, failovers[0].DaemonID)
+	require.Equal(t, 2*time.Minute, failovers[0].GetDuration(startTime.Add(time.Hour)))
+
+	require.EqualValues(t, 2, failovers[1].DaemonID)
+	require.Zero(t, failovers[1].EndedAt)
+	require.Equal(t, time.Minute, failovers[1].GetDuration(startTime.Add(5*time.Minute)))
+}
@@ -618,6 +618,34 @@ func DeleteDaemonFromHosts(dbi dbops.DBI, daemonID int64, dataSource HostDataSou
 	return int64(result.RowsAffected()), nil
 }
 
+// Dissociates a daemon from the hosts belonging to the specified subnet.
+// The global hosts are dissociated when the subnet ID is 0. The dataSource
+// has the same meaning as in DeleteDaemonFromHosts. The first returned value
+// is the number of rows removed from the local_host table.
+func DeleteDaemonFromSubnetHosts(dbi dbops.DBI, daemonID, subnetID int64, dataSource HostDataSource) (int64, error) {
+	subquery := dbi.Model((*Host)(nil)).Column("id")
+	if subnetID == 0 {
+		subquery = subquery.Where("subnet_id IS NULL")
+	} else {
+		subquery = subquery.Where("subnet_id = ?", subnetID)
+	}
+
+	q := dbi.Model((*LocalHost)(nil)).
+		Where("daemon_id = ?", daemonID).
+		Where("host_id IN (?)", subquery)
+
+	if len(dataSource) > 0 {
+		q = q.Where("data_source = ?", dataSource)
+	}
+
+	result, err := q.Delete()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		err = pkgerrors.Wrapf(err, "problem deleting the daemon %d from hosts in subnet %d", daemonID, subnetID)
+		return 0, err
+	}
+	return int64(result.RowsAffected()), nil
+}
+
 // Deletes hosts which are not associated with any apps. Returns deleted host
 // count and an error.
 func DeleteOrphanedHosts(dbi dbops.DBI) (int64, error) {
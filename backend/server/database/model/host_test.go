@@ -1086,6 +1086,47 @@ func TestDeleteDaemonFromHosts(t *testing.T) {
 	require.Len(t, returned, 1)
 }
 
+// Test that the daemon is dissociated only from the hosts belonging to the
+// specified subnet or from the global hosts.
+func TestDeleteDaemonFromSubnetHosts(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestSubnetApps(t, db)
+	hosts := addTestHosts(t, db)
+	daemonID := apps[0].Daemons[0].ID
+
+	// The first host belongs to the subnet and the second host is global.
+	err := AddDaemonToHost(db, &hosts[0], daemonID, HostDataSourceAPI)
+	require.NoError(t, err)
+
+	err = AddDaemonToHost(db, &hosts[1], daemonID, HostDataSourceAPI)
+	require.NoError(t, err)
+
+	// Non-matching data source.
+	count, err := DeleteDaemonFromSubnetHosts(db, daemonID, hosts[0].SubnetID, HostDataSourceConfig)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	// Remove the association with the subnet host.
+	count, err = DeleteDaemonFromSubnetHosts(db, daemonID, hosts[0].SubnetID, HostDataSourceAPI)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	host, err := GetHost(db, hosts[1].ID)
+	require.NoError(t, err)
+	require.Len(t, host.LocalHosts, 1)
+
+	// Remove the association with the global host.
+	count, err = DeleteDaemonFromSubnetHosts(db, daemonID, 0, HostDataSourceAPI)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+
+	host, err = GetHost(db, hosts[1].ID)
+	require.NoError(t, err)
+	require.Empty(t, host.LocalHosts)
+}
+
 // Test deleting hosts not assigned to any apps.
 func TestDeleteOrphanedHosts(t *testing.T) {
 	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
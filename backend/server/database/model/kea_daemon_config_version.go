@@ -0,0 +1,228 @@
+package dbmodel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+	storkutil "isc.org/stork/util"
+)
+
+// Maximum number of the configuration versions stored for a daemon. The
+// oldest versions are deleted when a new version exceeds this limit.
+const KeaDaemonConfigVersionsLimit = 100
+
+// Represents a version of the Kea daemon's configuration. A new version is
+// stored whenever the configuration fetched from the daemon or set by Stork
+// differs from the recent version of the daemon's configuration. The user
+// and the operation are specified when the configuration has been changed
+// by a config manager transaction. They are empty when the changed
+// configuration has been fetched from the daemon.
+type KeaDaemonConfigVersion struct {
+	ID         int64
+	DaemonID   int64
+	Config     *KeaConfig
+	ConfigHash string
+	CreatedAt  time.Time
+
+	UserID int64
+	User   *SystemUser `pg:"rel:has-one"`
+
+	Operation string
+}
+
+// Represents the change of the Kea daemon's configuration made by a config
+// manager transaction that hasn't been stored as a configuration version
+// yet. Stork doesn't hold the complete configuration after changing the
+// subnets or shared networks with the subnet_cmds hooks library. The
+// configuration including the change is stored as a new version upon the
+// next configuration pull, and the version is associated with the user
+// and the operation of the pending change.
+type KeaDaemonPendingConfigChange struct {
+	DaemonID  int64 `pg:",pk"`
+	UserID    int64
+	Operation string
+}
+
+// Computes the hash of the Kea configuration. The configuration is
+// serialized to JSON without the top-level hash parameter returned by
+// the recent Kea versions in the config-get response. The serialized
+// map keys are sorted, so the same configurations have the same hashes
+// regardless of whether they have been fetched from the daemon or set
+// by Stork.
+func computeKeaDaemonConfigHash(config *KeaConfig) (string, error) {
+	raw := make(map[string]any)
+	for key, value := range config.Raw {
+		if key != "hash" {
+			raw[key] = value
+		}
+	}
+	marshalled, err := json.Marshal(raw)
+	if err != nil {
+		return "", pkgerrors.Wrapf(err, "problem serializing Kea daemon configuration")
+	}
+	return storkutil.Fnv128(string(marshalled)), nil
+}
+
+// Creates new version of the Kea daemon's configuration. The user ID
+// and the operation are optional. They should be specified when the
+// configuration has been changed by a config manager transaction.
+func NewKeaDaemonConfigVersion(daemonID int64, config *KeaConfig, userID int64, operation string) (*KeaDaemonConfigVersion, error) {
+	if config == nil || config.Config == nil {
+		return nil, pkgerrors.Errorf("no configuration specified for the version of the daemon %d", daemonID)
+	}
+	hash, err := computeKeaDaemonConfigHash(config)
+	if err != nil {
+		return nil, err
+	}
+	return &KeaDaemonConfigVersion{
+		DaemonID:   daemonID,
+		Config:     config,
+		ConfigHash: hash,
+		UserID:     userID,
+		Operation:  operation,
+	}, nil
+}
+
+// Inserts the version of the Kea daemon's configuration into the database
+// in the transaction unless its hash is equal to the hash of the recent
+// version of the daemon's configuration. The version without the user and
+// the operation is associated with the pending configuration change of the
+// daemon if it exists. The pending change is deleted when the version is
+// inserted. The oldest versions exceeding the KeaDaemonConfigVersionsLimit
+// are deleted. It returns a boolean value indicating whether the version
+// has been inserted.
+func addKeaDaemonConfigVersion(tx *pg.Tx, version *KeaDaemonConfigVersion) (bool, error) {
+	recent := &KeaDaemonConfigVersion{}
+	err := tx.Model(recent).
+		ExcludeColumn("config").
+		Where("daemon_id = ?", version.DaemonID).
+		OrderExpr("id DESC").
+		Limit(1).
+		Select()
+	switch {
+	case err == nil:
+		if recent.ConfigHash == version.ConfigHash {
+			return false, nil
+		}
+	case !errors.Is(err, pg.ErrNoRows):
+		return false, pkgerrors.Wrapf(err, "problem getting recent configuration version of the daemon %d", version.DaemonID)
+	}
+	// The configuration fetched from the daemon may include the change
+	// made by a config manager transaction. Associate the version with
+	// the user and the operation of this transaction.
+	if version.UserID == 0 && version.Operation == "" {
+		pending, err := GetKeaDaemonPendingConfigChange(tx, version.DaemonID)
+		if err != nil {
+			return false, err
+		}
+		if pending != nil {
+			version.UserID = pending.UserID
+			version.Operation = pending.Operation
+		}
+	}
+	if _, err = tx.Model(version).Insert(); err != nil {
+		return false, pkgerrors.Wrapf(err, "problem inserting configuration version of the daemon %d", version.DaemonID)
+	}
+	// The inserted version includes the pending change.
+	_, err = tx.Model((*KeaDaemonPendingConfigChange)(nil)).
+		Where("daemon_id = ?", version.DaemonID).
+		Delete()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem deleting pending configuration change of the daemon %d", version.DaemonID)
+	}
+	// Keep the limited number of the recent versions.
+	_, err = tx.Model((*KeaDaemonConfigVersion)(nil)).
+		Where("daemon_id = ?", version.DaemonID).
+		Where("id <= (SELECT id FROM kea_daemon_config_version WHERE daemon_id = ? ORDER BY id DESC OFFSET ? LIMIT 1)",
+			version.DaemonID, KeaDaemonConfigVersionsLimit).
+		Delete()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem deleting old configuration versions of the daemon %d", version.DaemonID)
+	}
+	return true, nil
+}
+
+// Inserts the version of the Kea daemon's configuration into the database
+// unless its hash is equal to the hash of the recent version of the daemon's
+// configuration. It returns a boolean value indicating whether the version
+// has been inserted.
+func AddKeaDaemonConfigVersion(dbi dbops.DBI, version *KeaDaemonConfigVersion) (added bool, err error) {
+	if db, ok := dbi.(*pg.DB); ok {
+		err = db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			added, err = addKeaDaemonConfigVersion(tx, version)
+			return err
+		})
+		return
+	}
+	return addKeaDaemonConfigVersion(dbi.(*pg.Tx), version)
+}
+
+// Returns the versions of the Kea daemon's configuration from the newest
+// to the oldest. The configurations are not fetched to limit the size of
+// the returned data. A selected configuration can be fetched with the
+// GetKeaDaemonConfigVersion function.
+func GetKeaDaemonConfigVersions(dbi dbops.DBI, daemonID int64) ([]KeaDaemonConfigVersion, error) {
+	var versions []KeaDaemonConfigVersion
+	err := dbi.Model(&versions).
+		ExcludeColumn("config").
+		Relation("User").
+		Where("kea_daemon_config_version.daemon_id = ?", daemonID).
+		OrderExpr("kea_daemon_config_version.id DESC").
+		Select()
+	if err != nil && !errors.Is(err, pg.ErrNoRows) {
+		return nil, pkgerrors.Wrapf(err, "problem getting configuration versions of the daemon %d", daemonID)
+	}
+	return versions, nil
+}
+
+// Returns the version of the Kea daemon's configuration by ID. It returns
+// nil if the version doesn't exist.
+func GetKeaDaemonConfigVersion(dbi dbops.DBI, id int64) (*KeaDaemonConfigVersion, error) {
+	version := &KeaDaemonConfigVersion{}
+	err := dbi.Model(version).
+		Relation("User").
+		Where("kea_daemon_config_version.id = ?", id).
+		Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem getting configuration version with ID %d", id)
+	}
+	return version, nil
+}
+
+// Inserts the pending change of the Kea daemon's configuration into the
+// database or replaces the existing pending change of the daemon.
+func AddKeaDaemonPendingConfigChange(dbi dbops.DBI, change *KeaDaemonPendingConfigChange) error {
+	_, err := dbi.Model(change).
+		OnConflict("(daemon_id) DO UPDATE").
+		Set("user_id = EXCLUDED.user_id").
+		Set("operation = EXCLUDED.operation").
+		Insert()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem inserting pending configuration change of the daemon %d", change.DaemonID)
+	}
+	return nil
+}
+
+// Returns the pending change of the Kea daemon's configuration. It returns
+// nil if the daemon has no pending change.
+func GetKeaDaemonPendingConfigChange(dbi dbops.DBI, daemonID int64) (*KeaDaemonPendingConfigChange, error) {
+	change := &KeaDaemonPendingConfigChange{}
+	err := dbi.Model(change).
+		Where("daemon_id = ?", daemonID).
+		Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem getting pending configuration change of the daemon %d", daemonID)
+	}
+	return change, nil
+}
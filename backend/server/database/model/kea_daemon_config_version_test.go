@@ -0,0 +1,265 @@
+package dbmodel
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the hash of the configuration version doesn't depend on the
+// top-level hash parameter returned by Kea.
+func TestNewKeaDaemonConfigVersion(t *testing.T) {
+	config1, err := NewKeaConfigFromJSON(`{
+		"Dhcp4": {
+			"valid-lifetime": 1000
+		}
+	}`)
+	require.NoError(t, err)
+	config2, err := NewKeaConfigFromJSON(`{
+		"Dhcp4": {
+			"valid-lifetime": 1000
+		},
+		"hash": "ABCDEF"
+	}`)
+	require.NoError(t, err)
+	config3, err := NewKeaConfigFromJSON(`{
+		"Dhcp4": {
+			"valid-lifetime": 2000
+		}
+	}`)
+	require.NoError(t, err)
+
+	version1, err := NewKeaDaemonConfigVersion(1, config1, 0, "")
+	require.NoError(t, err)
+	require.NotEmpty(t, version1.ConfigHash)
+	require.EqualValues(t, 1, version1.DaemonID)
+
+	version2, err := NewKeaDaemonConfigVersion(1, config2, 2, "config_rollback")
+	require.NoError(t, err)
+	require.Equal(t, version1.ConfigHash, version2.ConfigHash)
+	require.EqualValues(t, 2, version2.UserID)
+	require.Equal(t, "config_rollback", version2.Operation)
+
+	version3, err := NewKeaDaemonConfigVersion(1, config3, 0, "")
+	require.NoError(t, err)
+	require.NotEqual(t, version1.ConfigHash, version3.ConfigHash)
+
+	_, err = NewKeaDaemonConfigVersion(1, nil, 0, "")
+	require.Error(t, err)
+}
+
+// Test that the distinct configuration versions are stored and returned.
+func TestAddGetKeaDaemonConfigVersions(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon1, daemon2, err := addTestDaemons(db)
+	require.NoError(t, err)
+
+	user := &SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err = CreateUser(db, user)
+	require.NoError(t, err)
+
+	configs := []string{
+		`{ "Dhcp4": { "valid-lifetime": 1000 } }`,
+		`{ "Dhcp4": { "valid-lifetime": 1000 }, "hash": "ABCDEF" }`,
+		`{ "Dhcp4": { "valid-lifetime": 2000 } }`,
+		`{ "Dhcp4": { "valid-lifetime": 1000 } }`,
+	}
+	expectedAdded := []bool{true, false, true, true}
+	for i, configJSON := range configs {
+		config, err := NewKeaConfigFromJSON(configJSON)
+		require.NoError(t, err)
+		userID := int64(0)
+		operation := ""
+		if i == 2 {
+			userID = int64(user.ID)
+			operation = "global_parameters_update"
+		}
+		version, err := NewKeaDaemonConfigVersion(daemon1.ID, config, userID, operation)
+		require.NoError(t, err)
+		added, err := AddKeaDaemonConfigVersion(db, version)
+		require.NoError(t, err)
+		require.Equal(t, expectedAdded[i], added, "unexpected result for config %d", i)
+	}
+
+	// The same configuration of another daemon is a distinct version.
+	config, err := NewKeaConfigFromJSON(configs[0])
+	require.NoError(t, err)
+	version, err := NewKeaDaemonConfigVersion(daemon2.ID, config, 0, "")
+	require.NoError(t, err)
+	added, err := AddKeaDaemonConfigVersion(db, version)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	// The versions should be returned from the newest.
+	versions, err := GetKeaDaemonConfigVersions(db, daemon1.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 3)
+	require.Greater(t, versions[0].ID, versions[1].ID)
+	require.Greater(t, versions[1].ID, versions[2].ID)
+	for _, v := range versions {
+		require.EqualValues(t, daemon1.ID, v.DaemonID)
+		require.NotEmpty(t, v.ConfigHash)
+		require.NotZero(t, v.CreatedAt)
+		require.Nil(t, v.Config)
+	}
+	require.Zero(t, versions[0].UserID)
+	require.Nil(t, versions[0].User)
+	require.EqualValues(t, user.ID, versions[1].UserID)
+	require.NotNil(t, versions[1].User)
+	require.Equal(t, "test", versions[1].User.Login)
+	require.Equal(t, "global_parameters_update", versions[1].Operation)
+
+	// Get a single version with the configuration.
+	returned, err := GetKeaDaemonConfigVersion(db, versions[1].ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.NotNil(t, returned.Config)
+	require.EqualValues(t, 2000, *returned.Config.GetValidLifetimeParameters().ValidLifetime)
+	require.NotNil(t, returned.User)
+
+	// Non-existing version.
+	returned, err = GetKeaDaemonConfigVersion(db, versions[0].ID+1000)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+
+	// The versions should be deleted with the daemon.
+	err = DeleteApp(db, daemon1.App)
+	require.NoError(t, err)
+	versions, err = GetKeaDaemonConfigVersions(db, daemon1.ID)
+	require.NoError(t, err)
+	require.Empty(t, versions)
+}
+
+// Test that the number of the stored versions of the daemon's configuration
+// is limited and the oldest versions are deleted.
+func TestAddKeaDaemonConfigVersionLimit(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon1, daemon2, err := addTestDaemons(db)
+	require.NoError(t, err)
+
+	// Add a version of another daemon. It must not be deleted.
+	config, err := NewKeaConfigFromJSON(`{ "Dhcp4": { "valid-lifetime": 1 } }`)
+	require.NoError(t, err)
+	version, err := NewKeaDaemonConfigVersion(daemon2.ID, config, 0, "")
+	require.NoError(t, err)
+	_, err = AddKeaDaemonConfigVersion(db, version)
+	require.NoError(t, err)
+
+	// Add more versions than the limit.
+	for i := 0; i < KeaDaemonConfigVersionsLimit+2; i++ {
+		config, err := NewKeaConfigFromJSON(fmt.Sprintf(`{ "Dhcp4": { "valid-lifetime": %d } }`, i+1))
+		require.NoError(t, err)
+		version, err := NewKeaDaemonConfigVersion(daemon1.ID, config, 0, "")
+		require.NoError(t, err)
+		added, err := AddKeaDaemonConfigVersion(db, version)
+		require.NoError(t, err)
+		require.True(t, added)
+	}
+
+	// The oldest versions should be deleted.
+	versions, err := GetKeaDaemonConfigVersions(db, daemon1.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, KeaDaemonConfigVersionsLimit)
+	returned, err := GetKeaDaemonConfigVersion(db, versions[KeaDaemonConfigVersionsLimit-1].ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.EqualValues(t, 3, *returned.Config.GetValidLifetimeParameters().ValidLifetime)
+
+	versions, err = GetKeaDaemonConfigVersions(db, daemon2.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+}
+
+// Test that the version pulled from the daemon is associated with the
+// user and the operation of the pending configuration change, and that
+// the pending change is deleted when the version is inserted.
+func TestAddKeaDaemonConfigVersionPendingChange(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon1, daemon2, err := addTestDaemons(db)
+	require.NoError(t, err)
+
+	user := &SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err = CreateUser(db, user)
+	require.NoError(t, err)
+
+	// No pending change initially.
+	change, err := GetKeaDaemonPendingConfigChange(db, daemon1.ID)
+	require.NoError(t, err)
+	require.Nil(t, change)
+
+	// Record the pending change and replace it with another one.
+	err = AddKeaDaemonPendingConfigChange(db, &KeaDaemonPendingConfigChange{
+		DaemonID:  daemon1.ID,
+		Operation: "subnet_add",
+	})
+	require.NoError(t, err)
+	err = AddKeaDaemonPendingConfigChange(db, &KeaDaemonPendingConfigChange{
+		DaemonID:  daemon1.ID,
+		UserID:    int64(user.ID),
+		Operation: "subnet_delete",
+	})
+	require.NoError(t, err)
+
+	change, err = GetKeaDaemonPendingConfigChange(db, daemon1.ID)
+	require.NoError(t, err)
+	require.NotNil(t, change)
+	require.EqualValues(t, user.ID, change.UserID)
+	require.Equal(t, "subnet_delete", change.Operation)
+
+	// The version pulled from another daemon is not associated with the
+	// pending change.
+	config, err := NewKeaConfigFromJSON(`{ "Dhcp4": { "valid-lifetime": 1000 } }`)
+	require.NoError(t, err)
+	version, err := NewKeaDaemonConfigVersion(daemon2.ID, config, 0, "")
+	require.NoError(t, err)
+	_, err = AddKeaDaemonConfigVersion(db, version)
+	require.NoError(t, err)
+	require.Zero(t, version.UserID)
+	require.Empty(t, version.Operation)
+
+	// The version pulled from the daemon should be associated with the
+	// pending change.
+	version, err = NewKeaDaemonConfigVersion(daemon1.ID, config, 0, "")
+	require.NoError(t, err)
+	added, err := AddKeaDaemonConfigVersion(db, version)
+	require.NoError(t, err)
+	require.True(t, added)
+
+	versions, err := GetKeaDaemonConfigVersions(db, daemon1.ID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+	require.EqualValues(t, user.ID, versions[0].UserID)
+	require.Equal(t, "subnet_delete", versions[0].Operation)
+
+	// The pending change should be deleted.
+	change, err = GetKeaDaemonPendingConfigChange(db, daemon1.ID)
+	require.NoError(t, err)
+	require.Nil(t, change)
+
+	// The next pulled version should not be associated with any user.
+	config, err = NewKeaConfigFromJSON(`{ "Dhcp4": { "valid-lifetime": 2000 } }`)
+	require.NoError(t, err)
+	version, err = NewKeaDaemonConfigVersion(daemon1.ID, config, 0, "")
+	require.NoError(t, err)
+	added, err = AddKeaDaemonConfigVersion(db, version)
+	require.NoError(t, err)
+	require.True(t, added)
+	require.Zero(t, version.UserID)
+	require.Empty(t, version.Operation)
+}
@@ -28,6 +28,44 @@ type MachineState struct {
 	VirtualizationRole       string
 	HostID                   string
 	AgentUsesHTTPCredentials bool
+	// Expiration time of the certificate the agent presents to the server.
+	AgentCertExpiresAt time.Time
+	// Error of the last failed agent certificate renewal. It is empty if
+	// the certificate was renewed or the renewal wasn't needed.
+	AgentCertRenewalError string
+	// Indicates that the event about the agent certificate expiring soon
+	// has been raised. It is reset when the certificate is renewed.
+	AgentCertExpiryWarned bool
+	// Facts about the Kea and BIND 9 daemons running on the machine.
+	Inventory []DaemonInventory
+}
+
+// Permissions and ownership of a file used by a daemon, e.g., a
+// configuration file or a control socket.
+type DaemonFileInventory struct {
+	Path  string
+	Mode  string
+	Owner string
+	Group string
+	// Set when the agent cannot inspect the file.
+	Error string
+}
+
+// Facts about the host related to a daemon running on the machine,
+// gathered by the agent for troubleshooting.
+type DaemonInventory struct {
+	// Process name, e.g., kea-dhcp4 or named.
+	Name       string
+	Pid        int32
+	Executable string
+	// Name of the package providing the executable.
+	Package     string
+	SystemdUnit string
+	// Output of the systemctl is-enabled command, e.g., enabled.
+	SystemdUnitState string
+	// Listening sockets, e.g., tcp 127.0.0.1:8000.
+	ListeningSockets []string
+	Files            []DaemonFileInventory
 }
 
 // Represents a machine held in machine table in the database.
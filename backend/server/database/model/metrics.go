@@ -15,6 +15,16 @@ type CalculatedNetworkMetrics struct {
 	PdUtilization int16
 }
 
+// Metric values calculated for specific address or delegated prefix pool.
+type CalculatedPoolMetrics struct {
+	// Prefix of the subnet the pool belongs to.
+	Subnet string
+	// Address range or delegated prefix pool.
+	Pool string
+	// Pool utilization in percentage multiplied by 10.
+	Utilization int16
+}
+
 // Metric values calculated from the database.
 type CalculatedMetrics struct {
 	AuthorizedMachines   int64
@@ -22,6 +32,8 @@ type CalculatedMetrics struct {
 	UnreachableMachines  int64
 	SubnetMetrics        []CalculatedNetworkMetrics
 	SharedNetworkMetrics []CalculatedNetworkMetrics
+	AddressPoolMetrics   []CalculatedPoolMetrics
+	PrefixPoolMetrics    []CalculatedPoolMetrics
 }
 
 // Calculates various metrics using several SELECT queries.
@@ -57,5 +69,37 @@ func GetCalculatedMetrics(db *pg.DB) (*CalculatedMetrics, error) {
 		return nil, errors.Wrap(err, "cannot calculate shared network metrics")
 	}
 
+	// The same pool may be served by several daemons, e.g., in the HA
+	// setup. The highest reported utilization is taken.
+	err = db.Model().
+		Table("address_pool").
+		ColumnExpr("subnet.prefix AS \"subnet\"").
+		ColumnExpr("host(address_pool.lower_bound) || '-' || host(address_pool.upper_bound) AS \"pool\"").
+		ColumnExpr("MAX(address_pool.utilization) AS \"utilization\"").
+		Join("JOIN local_subnet ON local_subnet.id = address_pool.local_subnet_id").
+		Join("JOIN subnet ON subnet.id = local_subnet.subnet_id").
+		Where("address_pool.stats_collected_at IS NOT NULL").
+		Group("subnet.prefix", "address_pool.lower_bound", "address_pool.upper_bound").
+		Select(&metrics.AddressPoolMetrics)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot calculate address pool metrics")
+	}
+
+	err = db.Model().
+		Table("prefix_pool").
+		ColumnExpr("subnet.prefix AS \"subnet\"").
+		ColumnExpr("text(prefix_pool.prefix) AS \"pool\"").
+		ColumnExpr("MAX(prefix_pool.utilization) AS \"utilization\"").
+		Join("JOIN local_subnet ON local_subnet.id = prefix_pool.local_subnet_id").
+		Join("JOIN subnet ON subnet.id = local_subnet.subnet_id").
+		Where("prefix_pool.stats_collected_at IS NOT NULL").
+		Group("subnet.prefix", "prefix_pool.prefix").
+		Select(&metrics.PrefixPoolMetrics)
+
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot calculate prefix pool metrics")
+	}
+
 	return &metrics, nil
 }
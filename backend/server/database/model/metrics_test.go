@@ -137,3 +137,65 @@ func TestFilledSharedNetworksDatabaseMetrics(t *testing.T) {
 	require.Zero(t, metrics.SharedNetworkMetrics[2].AddrUtilization)
 	require.Zero(t, metrics.SharedNetworkMetrics[2].PdUtilization)
 }
+
+// Metrics per pool should be properly calculated.
+func TestFilledPoolsDatabaseMetrics(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	apps := addTestSubnetApps(t, db)
+
+	subnet := &Subnet{
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*LocalSubnet{
+			{DaemonID: apps[0].Daemons[1].ID, LocalSubnetID: 1},
+			{DaemonID: apps[1].Daemons[1].ID, LocalSubnetID: 1},
+		},
+	}
+	_ = AddSubnet(db, subnet)
+	_ = AddLocalSubnets(db, subnet)
+
+	for i, stats := range []SubnetStats{
+		{"assigned-nas": uint64(1), "total-nas": uint64(10)},
+		{"assigned-nas": uint64(2), "total-nas": uint64(10)},
+	} {
+		addressPool := &AddressPool{
+			LowerBound:  "2001:db8:1::10",
+			UpperBound:  "2001:db8:1::20",
+			LocalSubnet: &LocalSubnet{ID: subnet.LocalSubnets[i].ID},
+		}
+		_ = AddAddressPool(db, addressPool)
+		_ = addressPool.UpdateStats(db, stats)
+	}
+	prefixPool := &PrefixPool{
+		Prefix:       "2001:db8:1:1::/80",
+		DelegatedLen: 96,
+		LocalSubnet:  &LocalSubnet{ID: subnet.LocalSubnets[0].ID},
+	}
+	_ = AddPrefixPool(db, prefixPool)
+	_ = prefixPool.UpdateStats(db, SubnetStats{"assigned-pds": uint64(1), "total-pds": uint64(4)})
+
+	// The pool without statistics is not included.
+	_ = AddPrefixPool(db, &PrefixPool{
+		Prefix:       "2001:db8:1:2::/80",
+		DelegatedLen: 96,
+		LocalSubnet:  &LocalSubnet{ID: subnet.LocalSubnets[0].ID},
+	})
+
+	// Act
+	metrics, err := GetCalculatedMetrics(db)
+
+	// Assert
+	require.NoError(t, err)
+
+	// The highest utilization reported by the daemons is taken.
+	require.Len(t, metrics.AddressPoolMetrics, 1)
+	require.Equal(t, "2001:db8:1::/64", metrics.AddressPoolMetrics[0].Subnet)
+	require.Equal(t, "2001:db8:1::10-2001:db8:1::20", metrics.AddressPoolMetrics[0].Pool)
+	require.EqualValues(t, 200, metrics.AddressPoolMetrics[0].Utilization)
+
+	require.Len(t, metrics.PrefixPoolMetrics, 1)
+	require.Equal(t, "2001:db8:1::/64", metrics.PrefixPoolMetrics[0].Subnet)
+	require.Equal(t, "2001:db8:1:1::/80", metrics.PrefixPoolMetrics[0].Pool)
+	require.EqualValues(t, 250, metrics.PrefixPoolMetrics[0].Utilization)
+}
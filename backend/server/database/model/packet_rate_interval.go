@@ -0,0 +1,154 @@
+package dbmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	errors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// The numbers of the packets counted by a daemon during an interval of
+// time. The subnet ID is zero for the daemon-wide counters. The leases
+// assigned are counted per subnet. The NAKs, declines and drops are
+// counted daemon-wide.
+type PacketRateInterval struct {
+	ID             int64
+	DaemonID       int64
+	SubnetID       int64
+	StartTime      time.Time
+	Duration       int64 // duration of this interval (seconds)
+	AssignedLeases int64 `pg:",use_zero"`
+	NAKs           int64 `pg:"naks,use_zero"`
+	Declines       int64 `pg:",use_zero"`
+	Drops          int64 `pg:",use_zero"`
+}
+
+// The rates of the packets per second over a period of time.
+type PacketRates struct {
+	NAKs     float64 `pg:"naks"`
+	Declines float64
+	Drops    float64
+}
+
+// Adds the packet rate intervals to the database.
+func AddPacketRateIntervals(dbi dbops.DBI, intervals []*PacketRateInterval) error {
+	if len(intervals) == 0 {
+		return nil
+	}
+	_, err := dbi.Model(&intervals).Insert()
+	if err != nil {
+		err = errors.Wrapf(err, "problem inserting %d packet rate intervals", len(intervals))
+	}
+	return err
+}
+
+// Deletes the packet rate intervals starting before the specified time.
+func DeletePacketRateIntervalsBefore(dbi dbops.DBI, startTime time.Time) error {
+	_, err := dbi.Model((*PacketRateInterval)(nil)).
+		Where("start_time < ?", startTime).
+		Delete()
+	if err != nil {
+		err = errors.Wrapf(err, "problem deleting packet rate intervals before %s", startTime)
+	}
+	return err
+}
+
+// Returns the daemon-wide packet rates of the daemon over the intervals
+// starting at or after the specified time.
+func GetDaemonPacketRates(dbi dbops.DBI, daemonID int64, since time.Time) (*PacketRates, error) {
+	rates := &PacketRates{}
+	err := dbi.Model().
+		Table("packet_rate_interval").
+		ColumnExpr("COALESCE(SUM(naks)::float / NULLIF(SUM(duration), 0), 0) AS naks").
+		ColumnExpr("COALESCE(SUM(declines)::float / NULLIF(SUM(duration), 0), 0) AS declines").
+		ColumnExpr("COALESCE(SUM(drops)::float / NULLIF(SUM(duration), 0), 0) AS drops").
+		Where("daemon_id = ?", daemonID).
+		Where("subnet_id IS NULL").
+		Where("start_time >= ?", since).
+		Select(rates)
+	if err != nil {
+		return nil, errors.Wrapf(err, "problem getting packet rates of daemon %d", daemonID)
+	}
+	return rates, nil
+}
+
+// Returns the rates of the leases assigned in the subnets over the
+// intervals starting at or after the specified time. The rate is calculated
+// for each daemon serving the subnet and the rates of the daemons are
+// summed. The returned map is indexed by subnet ID.
+func GetSubnetLeaseRates(dbi dbops.DBI, since time.Time) (map[int64]float64, error) {
+	var rows []struct {
+		SubnetID int64
+		Rate     float64
+	}
+	_, err := dbi.Query(&rows, `
+		SELECT subnet_id, SUM(rate) AS rate
+		FROM (
+			SELECT subnet_id, SUM(assigned_leases)::float / NULLIF(SUM(duration), 0) AS rate
+			FROM packet_rate_interval
+			WHERE subnet_id IS NOT NULL AND start_time >= ?
+			GROUP BY subnet_id, daemon_id
+		) AS daemon_rates
+		WHERE rate IS NOT NULL
+		GROUP BY subnet_id`, since)
+	if err != nil {
+		return nil, errors.Wrap(err, "problem getting lease rates of subnets")
+	}
+	rates := make(map[int64]float64)
+	for _, row := range rows {
+		rates[row.SubnetID] = row.Rate
+	}
+	return rates, nil
+}
+
+// Stores the lease rates in the subnets over the short and long
+// intervals. The rates of the subnets missing in the maps are zeroed. The
+// rates of the shared networks are the sums of the rates of their subnets.
+func UpdateSubnetLeaseRates(dbi dbops.DBI, rates1, rates2 map[int64]float64) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return updateSubnetLeaseRates(tx, rates1, rates2)
+		})
+	}
+	return updateSubnetLeaseRates(dbi.(*pg.Tx), rates1, rates2)
+}
+
+// Stores the lease rates in the subnets and shared networks within a
+// transaction.
+func updateSubnetLeaseRates(tx *pg.Tx, rates1, rates2 map[int64]float64) error {
+	_, err := tx.Exec(`UPDATE subnet SET lease_rate1 = 0, lease_rate2 = 0 WHERE lease_rate1 <> 0 OR lease_rate2 <> 0`)
+	if err != nil {
+		return errors.Wrap(err, "problem resetting lease rates of subnets")
+	}
+	subnetIDs := make(map[int64]bool)
+	for subnetID := range rates1 {
+		subnetIDs[subnetID] = true
+	}
+	for subnetID := range rates2 {
+		subnetIDs[subnetID] = true
+	}
+	for subnetID := range subnetIDs {
+		_, err = tx.Exec(`UPDATE subnet SET lease_rate1 = ?, lease_rate2 = ? WHERE id = ?`,
+			rates1[subnetID], rates2[subnetID], subnetID)
+		if err != nil {
+			return errors.Wrapf(err, "problem updating lease rates of subnet %d", subnetID)
+		}
+	}
+	_, err = tx.Exec(`
+		UPDATE shared_network
+		SET lease_rate1 = COALESCE(rates.lease_rate1, 0), lease_rate2 = COALESCE(rates.lease_rate2, 0)
+		FROM shared_network AS n
+		LEFT JOIN (
+			SELECT shared_network_id, SUM(lease_rate1) AS lease_rate1, SUM(lease_rate2) AS lease_rate2
+			FROM subnet
+			WHERE shared_network_id IS NOT NULL
+			GROUP BY shared_network_id
+		) AS rates ON rates.shared_network_id = n.id
+		WHERE shared_network.id = n.id`)
+	if err != nil {
+		return errors.Wrap(err, "problem updating lease rates of shared networks")
+	}
+	return nil
+}
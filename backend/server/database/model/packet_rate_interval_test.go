@@ -0,0 +1,129 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the daemon-wide packet rates are calculated from the intervals
+// starting at or after the specified time.
+func TestGetDaemonPacketRates(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon1, daemon2, err := addTestDaemons(db)
+	require.NoError(t, err)
+
+	startTime := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	err = AddPacketRateIntervals(db, []*PacketRateInterval{
+		{DaemonID: daemon1.ID, StartTime: startTime, Duration: 60, NAKs: 60, Declines: 6, Drops: 120},
+		{DaemonID: daemon1.ID, StartTime: startTime.Add(time.Minute), Duration: 60, NAKs: 0, Declines: 6, Drops: 0},
+		{DaemonID: daemon2.ID, StartTime: startTime, Duration: 60, NAKs: 600},
+	})
+	require.NoError(t, err)
+
+	rates, err := GetDaemonPacketRates(db, daemon1.ID, startTime)
+	require.NoError(t, err)
+	require.EqualValues(t, 0.5, rates.NAKs)
+	require.EqualValues(t, 0.1, rates.Declines)
+	require.EqualValues(t, 1, rates.Drops)
+
+	rates, err = GetDaemonPacketRates(db, daemon1.ID, startTime.Add(time.Minute))
+	require.NoError(t, err)
+	require.Zero(t, rates.NAKs)
+	require.EqualValues(t, 0.1, rates.Declines)
+	require.Zero(t, rates.Drops)
+
+	// No intervals.
+	rates, err = GetDaemonPacketRates(db, daemon1.ID, startTime.Add(time.Hour))
+	require.NoError(t, err)
+	require.Zero(t, rates.NAKs)
+
+	// The old intervals are deleted.
+	err = DeletePacketRateIntervalsBefore(db, startTime.Add(time.Minute))
+	require.NoError(t, err)
+	rates, err = GetDaemonPacketRates(db, daemon2.ID, startTime)
+	require.NoError(t, err)
+	require.Zero(t, rates.NAKs)
+}
+
+// Test that the lease rates of the subnets are summed over the daemons
+// and stored in the subnets and their shared networks.
+func TestUpdateSubnetLeaseRates(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon1, daemon2, err := addTestDaemons(db)
+	require.NoError(t, err)
+
+	network := &SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	err = AddSharedNetwork(db, network)
+	require.NoError(t, err)
+
+	subnet1 := &Subnet{
+		Prefix:          "192.0.2.0/24",
+		SharedNetworkID: network.ID,
+	}
+	err = AddSubnet(db, subnet1)
+	require.NoError(t, err)
+
+	subnet2 := &Subnet{
+		Prefix:          "192.0.3.0/24",
+		SharedNetworkID: network.ID,
+	}
+	err = AddSubnet(db, subnet2)
+	require.NoError(t, err)
+
+	startTime := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	err = AddPacketRateIntervals(db, []*PacketRateInterval{
+		{DaemonID: daemon1.ID, SubnetID: subnet1.ID, StartTime: startTime, Duration: 10, AssignedLeases: 20},
+		{DaemonID: daemon1.ID, SubnetID: subnet1.ID, StartTime: startTime.Add(10 * time.Second), Duration: 10, AssignedLeases: 0},
+		{DaemonID: daemon2.ID, SubnetID: subnet1.ID, StartTime: startTime, Duration: 20, AssignedLeases: 10},
+		{DaemonID: daemon1.ID, SubnetID: subnet2.ID, StartTime: startTime, Duration: 10, AssignedLeases: 5},
+		// Daemon-wide interval.
+		{DaemonID: daemon1.ID, StartTime: startTime, Duration: 10, Drops: 5},
+	})
+	require.NoError(t, err)
+
+	rates1, err := GetSubnetLeaseRates(db, startTime.Add(10*time.Second))
+	require.NoError(t, err)
+	require.Len(t, rates1, 1)
+	require.Zero(t, rates1[subnet1.ID])
+
+	rates2, err := GetSubnetLeaseRates(db, startTime)
+	require.NoError(t, err)
+	require.Len(t, rates2, 2)
+	require.EqualValues(t, 1.5, rates2[subnet1.ID])
+	require.EqualValues(t, 0.5, rates2[subnet2.ID])
+
+	err = UpdateSubnetLeaseRates(db, rates1, rates2)
+	require.NoError(t, err)
+
+	returnedSubnet, err := GetSubnet(db, subnet1.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedSubnet.LeaseRate1)
+	require.EqualValues(t, 1.5, returnedSubnet.LeaseRate2)
+
+	returnedNetwork, err := GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedNetwork.LeaseRate1)
+	require.EqualValues(t, 2, returnedNetwork.LeaseRate2)
+
+	// The rates of the subnets missing in the maps are zeroed.
+	err = UpdateSubnetLeaseRates(db, map[int64]float64{}, map[int64]float64{})
+	require.NoError(t, err)
+
+	returnedSubnet, err = GetSubnet(db, subnet1.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedSubnet.LeaseRate2)
+
+	returnedNetwork, err = GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.Zero(t, returnedNetwork.LeaseRate2)
+}
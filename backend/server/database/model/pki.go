@@ -5,6 +5,7 @@ import (
 
 	"github.com/go-pg/pg/v10"
 	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
 )
 
 // Secret types.
@@ -14,6 +15,8 @@ const (
 	SecretServerKey   = "srvkey"
 	SecretServerCert  = "srvcert"
 	SecretServerToken = "srvtkn"
+	SecretNextCAKey   = "nextcakey"
+	SecretNextCACert  = "nextcacert"
 )
 
 // Structure holding named secret.
@@ -23,14 +26,14 @@ type Secret struct {
 }
 
 // Generate new serial number from database.
-func GetNewCertSerialNumber(db *pg.DB) (int64, error) {
+func GetNewCertSerialNumber(db dbops.DBI) (int64, error) {
 	var certSerialNumber int64
 	_, err := db.QueryOne(pg.Scan(&certSerialNumber), "SELECT nextval('certs_serial_number_seq')")
 	return certSerialNumber, err
 }
 
 // Get named secret from database.
-func GetSecret(db *pg.DB, name string) ([]byte, error) {
+func GetSecret(db dbops.DBI, name string) ([]byte, error) {
 	secret := Secret{}
 	q := db.Model(&secret)
 	q = q.Where("secret.name = ?", name)
@@ -44,7 +47,7 @@ func GetSecret(db *pg.DB, name string) ([]byte, error) {
 }
 
 // Set secret in database under given name.
-func SetSecret(db *pg.DB, name string, content []byte) error {
+func SetSecret(db dbops.DBI, name string, content []byte) error {
 	secret := &Secret{
 		Name:    name,
 		Content: string(content),
@@ -55,3 +58,13 @@ func SetSecret(db *pg.DB, name string, content []byte) error {
 	_, err := q.Insert()
 	return err
 }
+
+// Delete the secret with the given name from database. It is not an
+// error if the secret doesn't exist.
+func DeleteSecret(db dbops.DBI, name string) error {
+	_, err := db.Model(&Secret{}).Where("secret.name = ?", name).Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting secret by name: %s", name)
+	}
+	return nil
+}
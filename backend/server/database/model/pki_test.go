@@ -34,6 +34,8 @@ func TestGetSetSecrets(t *testing.T) {
 		SecretServerKey,
 		SecretServerCert,
 		SecretServerToken,
+		SecretNextCAKey,
+		SecretNextCACert,
 	}
 
 	for _, key := range keys {
@@ -49,3 +51,23 @@ func TestGetSetSecrets(t *testing.T) {
 		require.EqualValues(t, "content", string(val))
 	}
 }
+
+// Check if deleting secrets from database works.
+func TestDeleteSecret(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	err := SetSecret(db, SecretNextCACert, []byte("content"))
+	require.NoError(t, err)
+
+	err = DeleteSecret(db, SecretNextCACert)
+	require.NoError(t, err)
+
+	val, err := GetSecret(db, SecretNextCACert)
+	require.NoError(t, err)
+	require.Nil(t, val)
+
+	// Deleting non-existing secret is not an error.
+	err = DeleteSecret(db, SecretNextCACert)
+	require.NoError(t, err)
+}
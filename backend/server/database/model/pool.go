@@ -1,9 +1,13 @@
 package dbmodel
 
 import (
+	"encoding/json"
+	"fmt"
+	"math/big"
 	"net"
 	"time"
 
+	"github.com/go-pg/pg/v10"
 	errors "github.com/pkg/errors"
 	keaconfig "isc.org/stork/appcfg/kea"
 	dhcpmodel "isc.org/stork/datamodel/dhcp"
@@ -29,6 +33,13 @@ type AddressPool struct {
 	LocalSubnet       *LocalSubnet `pg:"rel:has-one"`
 
 	KeaParameters *keaconfig.PoolParameters
+
+	// Lease statistics of the pool and its utilization in permilles.
+	Stats            SubnetStats
+	StatsCollectedAt time.Time
+	Utilization      int16
+	// Projected time when the pool runs out.
+	ExhaustionAt time.Time
 }
 
 // Returns lower pool boundary.
@@ -74,6 +85,13 @@ type PrefixPool struct {
 	LocalSubnet       *LocalSubnet `pg:"rel:has-one"`
 
 	KeaParameters *keaconfig.PoolParameters
+
+	// Lease statistics of the pool and its utilization in permilles.
+	Stats            SubnetStats
+	StatsCollectedAt time.Time
+	Utilization      int16
+	// Projected time when the pool runs out.
+	ExhaustionAt time.Time
 }
 
 // Returns a pointer to a structure holding the delegated prefix data.
@@ -237,3 +255,148 @@ func DeletePrefixPool(db *dbops.PgDB, poolID int64) error {
 	}
 	return err
 }
+
+// Converts the statistic value to a big integer. It returns nil if the
+// statistic is missing or has an unexpected type.
+func getStatisticAsBigInt(stats SubnetStats, name string) *big.Int {
+	switch value := stats[name].(type) {
+	case uint64:
+		return new(big.Int).SetUint64(value)
+	case int64:
+		return big.NewInt(value)
+	case *big.Int:
+		return value
+	default:
+		return nil
+	}
+}
+
+// Calculates the utilization in permilles from the statistics holding the
+// number of the assigned and total leases. It returns zero if any of the
+// statistics is missing or not positive.
+func calculatePoolUtilization(stats SubnetStats, assignedName, totalName string) int16 {
+	assigned := getStatisticAsBigInt(stats, assignedName)
+	total := getStatisticAsBigInt(stats, totalName)
+	if assigned == nil || total == nil || assigned.Sign() <= 0 || total.Sign() <= 0 {
+		return 0
+	}
+	utilization, _ := new(big.Float).Quo(new(big.Float).SetInt(assigned), new(big.Float).SetInt(total)).Float64()
+	return int16(utilization * 1000)
+}
+
+// Sets the statistics pulled for the address pool and calculates its
+// utilization without updating the database. The IPv4 pools are described
+// by the address statistics and the IPv6 pools are described by the NA
+// statistics.
+func (ap *AddressPool) SetStats(stats SubnetStats) {
+	ap.Stats = stats
+	ap.StatsCollectedAt = storkutil.UTCNow()
+	if _, ok := stats["total-addresses"]; ok {
+		ap.Utilization = calculatePoolUtilization(stats, "assigned-addresses", "total-addresses")
+	} else {
+		ap.Utilization = calculatePoolUtilization(stats, "assigned-nas", "total-nas")
+	}
+}
+
+// Sets the statistics pulled for the delegated prefix pool and calculates
+// its utilization without updating the database.
+func (pp *PrefixPool) SetStats(stats SubnetStats) {
+	pp.Stats = stats
+	pp.StatsCollectedAt = storkutil.UTCNow()
+	pp.Utilization = calculatePoolUtilization(stats, "assigned-pds", "total-pds")
+}
+
+// Update the statistics pulled for the address pool and its utilization.
+func (ap *AddressPool) UpdateStats(dbi dbops.DBI, stats SubnetStats) error {
+	ap.SetStats(stats)
+	result, err := dbi.Model(ap).
+		Column("stats", "stats_collected_at", "utilization").
+		WherePK().
+		Update()
+	if err != nil {
+		err = errors.Wrapf(err, "problem updating stats in the address pool %s-%s", ap.LowerBound, ap.UpperBound)
+	} else if result.RowsAffected() <= 0 {
+		err = errors.Wrapf(ErrNotExists, "pool with ID %d does not exist", ap.ID)
+	}
+	return err
+}
+
+// Update the statistics pulled for the delegated prefix pool and its
+// utilization.
+func (pp *PrefixPool) UpdateStats(dbi dbops.DBI, stats SubnetStats) error {
+	pp.SetStats(stats)
+	result, err := dbi.Model(pp).
+		Column("stats", "stats_collected_at", "utilization").
+		WherePK().
+		Update()
+	if err != nil {
+		err = errors.Wrapf(err, "problem updating stats in the prefix pool %s", pp.Prefix)
+	} else if result.RowsAffected() <= 0 {
+		err = errors.Wrapf(ErrNotExists, "pool with ID %d does not exist", pp.ID)
+	}
+	return err
+}
+
+// The statistics of a single pool updated in a batch.
+type poolStatsUpdate struct {
+	id          int64
+	stats       SubnetStats
+	collectedAt time.Time
+	utilization int16
+}
+
+// Updates the statistics and utilization of many pools in the table in a
+// single query.
+func updatePoolsStats(dbi dbops.DBI, table string, updates []poolStatsUpdate) error {
+	if len(updates) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(updates))
+	stats := make([]string, len(updates))
+	collectedAt := make([]string, len(updates))
+	utilizations := make([]int64, len(updates))
+	for i, update := range updates {
+		marshaled, err := json.Marshal(update.stats)
+		if err != nil {
+			return errors.Wrapf(err, "problem marshaling stats of the pool %d", update.id)
+		}
+		ids[i] = update.id
+		stats[i] = string(marshaled)
+		collectedAt[i] = update.collectedAt.UTC().Format(batchUpdateTimeFormat)
+		utilizations[i] = int64(update.utilization)
+	}
+	query := fmt.Sprintf(`
+		UPDATE %s SET stats = data.stats, stats_collected_at = data.stats_collected_at, utilization = data.utilization
+		FROM (
+			SELECT unnest(?::bigint[]) AS id,
+				unnest(?::jsonb[]) AS stats,
+				unnest(?::timestamp[]) AS stats_collected_at,
+				unnest(?::smallint[]) AS utilization
+		) AS data
+		WHERE %s.id = data.id`, table, table)
+	_, err := dbi.Exec(query, pg.Array(ids), pg.Array(stats), pg.Array(collectedAt), pg.Array(utilizations))
+	if err != nil {
+		return errors.Wrapf(err, "problem updating stats of %d pools in %s", len(updates), table)
+	}
+	return nil
+}
+
+// Updates the statistics and utilization of many address pools in a single
+// query. The statistics must be set in the pools using SetStats.
+func UpdateAddressPoolsStats(dbi dbops.DBI, pools []*AddressPool) error {
+	updates := make([]poolStatsUpdate, len(pools))
+	for i, pool := range pools {
+		updates[i] = poolStatsUpdate{pool.ID, pool.Stats, pool.StatsCollectedAt, pool.Utilization}
+	}
+	return updatePoolsStats(dbi, "address_pool", updates)
+}
+
+// Updates the statistics and utilization of many delegated prefix pools in
+// a single query. The statistics must be set in the pools using SetStats.
+func UpdatePrefixPoolsStats(dbi dbops.DBI, pools []*PrefixPool) error {
+	updates := make([]poolStatsUpdate, len(pools))
+	for i, pool := range pools {
+		updates[i] = poolStatsUpdate{pool.ID, pool.Stats, pool.StatsCollectedAt, pool.Utilization}
+	}
+	return updatePoolsStats(dbi, "prefix_pool", updates)
+}
@@ -1,6 +1,7 @@
 package dbmodel
 
 import (
+	"math/big"
 	"testing"
 	"time"
 
@@ -498,3 +499,194 @@ func TestAddressPoolHasEqualDataTheSame(t *testing.T) {
 	require.True(t, equalityFirstSecond)
 	require.True(t, equalitySecondFirst)
 }
+
+// Test that the pool utilization is calculated from the statistics of
+// various types.
+func TestCalculatePoolUtilization(t *testing.T) {
+	require.EqualValues(t, 250, calculatePoolUtilization(SubnetStats{
+		"assigned-addresses": uint64(25),
+		"total-addresses":    uint64(100),
+	}, "assigned-addresses", "total-addresses"))
+
+	total, _ := new(big.Int).SetString("36893488147419103232", 10)
+	assigned, _ := new(big.Int).SetString("9223372036854775808", 10)
+	require.EqualValues(t, 250, calculatePoolUtilization(SubnetStats{
+		"assigned-nas": assigned,
+		"total-nas":    total,
+	}, "assigned-nas", "total-nas"))
+
+	require.EqualValues(t, 500, calculatePoolUtilization(SubnetStats{
+		"assigned-pds": int64(1),
+		"total-pds":    uint64(2),
+	}, "assigned-pds", "total-pds"))
+
+	// Missing or invalid statistics.
+	require.Zero(t, calculatePoolUtilization(SubnetStats{}, "assigned-pds", "total-pds"))
+	require.Zero(t, calculatePoolUtilization(SubnetStats{
+		"assigned-pds": uint64(1),
+		"total-pds":    uint64(0),
+	}, "assigned-pds", "total-pds"))
+	require.Zero(t, calculatePoolUtilization(SubnetStats{
+		"assigned-pds": "1",
+		"total-pds":    uint64(2),
+	}, "assigned-pds", "total-pds"))
+}
+
+// Test that the pool statistics and utilization are updated and the
+// local subnets are returned with the pools.
+func TestUpdatePoolStats(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestSubnetApps(t, db)
+
+	subnet := Subnet{
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*LocalSubnet{
+			{
+				DaemonID:      apps[0].Daemons[1].ID,
+				LocalSubnetID: 7,
+			},
+		},
+	}
+	err := AddSubnet(db, &subnet)
+	require.NoError(t, err)
+	err = AddLocalSubnets(db, &subnet)
+	require.NoError(t, err)
+
+	addressPool := AddressPool{
+		LowerBound: "2001:db8:1::10",
+		UpperBound: "2001:db8:1::20",
+		LocalSubnet: &LocalSubnet{
+			ID: subnet.LocalSubnets[0].ID,
+		},
+	}
+	err = AddAddressPool(db, &addressPool)
+	require.NoError(t, err)
+
+	prefixPool := PrefixPool{
+		Prefix:       "2001:db8:1:1::/80",
+		DelegatedLen: 96,
+		LocalSubnet: &LocalSubnet{
+			ID: subnet.LocalSubnets[0].ID,
+		},
+	}
+	err = AddPrefixPool(db, &prefixPool)
+	require.NoError(t, err)
+
+	err = addressPool.UpdateStats(db, SubnetStats{
+		"assigned-nas": uint64(4),
+		"total-nas":    uint64(16),
+	})
+	require.NoError(t, err)
+	err = prefixPool.UpdateStats(db, SubnetStats{
+		"assigned-pds": uint64(3),
+		"total-pds":    uint64(4),
+	})
+	require.NoError(t, err)
+
+	localSubnets, err := GetDaemonLocalSubnetsWithPools(db, apps[0].Daemons[1].ID)
+	require.NoError(t, err)
+	require.Len(t, localSubnets, 1)
+	require.EqualValues(t, 7, localSubnets[0].LocalSubnetID)
+
+	require.Len(t, localSubnets[0].AddressPools, 1)
+	returnedAddressPool := localSubnets[0].AddressPools[0]
+	require.EqualValues(t, 250, returnedAddressPool.Utilization)
+	require.EqualValues(t, 4, returnedAddressPool.Stats["assigned-nas"])
+	require.EqualValues(t, 16, returnedAddressPool.Stats["total-nas"])
+	require.NotZero(t, returnedAddressPool.StatsCollectedAt)
+
+	require.Len(t, localSubnets[0].PrefixPools, 1)
+	returnedPrefixPool := localSubnets[0].PrefixPools[0]
+	require.EqualValues(t, 750, returnedPrefixPool.Utilization)
+	require.EqualValues(t, 3, returnedPrefixPool.Stats["assigned-pds"])
+	require.NotZero(t, returnedPrefixPool.StatsCollectedAt)
+
+	// The other daemon has no subnets.
+	localSubnets, err = GetDaemonLocalSubnetsWithPools(db, apps[0].Daemons[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, localSubnets)
+
+	// Updating a non-existing pool returns an error.
+	err = (&AddressPool{ID: addressPool.ID + 100}).UpdateStats(db, SubnetStats{})
+	require.ErrorIs(t, err, ErrNotExists)
+}
+
+// Test that the statistics of many pools are updated at once.
+func TestUpdatePoolsStats(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestSubnetApps(t, db)
+
+	subnet := Subnet{
+		Prefix: "2001:db8:1::/64",
+		LocalSubnets: []*LocalSubnet{
+			{
+				DaemonID:      apps[0].Daemons[1].ID,
+				LocalSubnetID: 7,
+			},
+		},
+	}
+	err := AddSubnet(db, &subnet)
+	require.NoError(t, err)
+	err = AddLocalSubnets(db, &subnet)
+	require.NoError(t, err)
+
+	var addressPools []*AddressPool
+	for _, bounds := range [][]string{{"2001:db8:1::10", "2001:db8:1::20"}, {"2001:db8:1::30", "2001:db8:1::40"}} {
+		pool := &AddressPool{
+			LowerBound: bounds[0],
+			UpperBound: bounds[1],
+			LocalSubnet: &LocalSubnet{
+				ID: subnet.LocalSubnets[0].ID,
+			},
+		}
+		err = AddAddressPool(db, pool)
+		require.NoError(t, err)
+		addressPools = append(addressPools, pool)
+	}
+	prefixPool := &PrefixPool{
+		Prefix:       "2001:db8:1:1::/80",
+		DelegatedLen: 96,
+		LocalSubnet: &LocalSubnet{
+			ID: subnet.LocalSubnets[0].ID,
+		},
+	}
+	err = AddPrefixPool(db, prefixPool)
+	require.NoError(t, err)
+
+	totalNAs, _ := new(big.Int).SetString("36893488147419103232", 10)
+	addressPools[0].SetStats(SubnetStats{
+		"assigned-nas": uint64(4),
+		"total-nas":    uint64(16),
+	})
+	addressPools[1].SetStats(SubnetStats{
+		"assigned-nas": uint64(1),
+		"total-nas":    totalNAs,
+	})
+	prefixPool.SetStats(SubnetStats{
+		"assigned-pds": uint64(1),
+		"total-pds":    uint64(2),
+	})
+	err = UpdateAddressPoolsStats(db, addressPools)
+	require.NoError(t, err)
+	err = UpdatePrefixPoolsStats(db, []*PrefixPool{prefixPool})
+	require.NoError(t, err)
+
+	localSubnets, err := GetDaemonLocalSubnetsWithPools(db, apps[0].Daemons[1].ID)
+	require.NoError(t, err)
+	require.Len(t, localSubnets, 1)
+	require.Len(t, localSubnets[0].AddressPools, 2)
+	require.EqualValues(t, 250, localSubnets[0].AddressPools[0].Utilization)
+	require.EqualValues(t, 4, localSubnets[0].AddressPools[0].Stats["assigned-nas"])
+	require.WithinDuration(t, addressPools[0].StatsCollectedAt, localSubnets[0].AddressPools[0].StatsCollectedAt, time.Millisecond)
+	require.Zero(t, localSubnets[0].AddressPools[1].Utilization)
+	require.Equal(t, totalNAs, localSubnets[0].AddressPools[1].Stats["total-nas"])
+	require.Len(t, localSubnets[0].PrefixPools, 1)
+	require.EqualValues(t, 500, localSubnets[0].PrefixPools[0].Utilization)
+
+	// Updating no pools is not an error.
+	require.NoError(t, UpdateAddressPoolsStats(db, nil))
+}
@@ -51,6 +51,24 @@ func GetTotalRpsOverIntervalForDaemon(db *pg.DB, startTime time.Time, endTime ti
 	return rpsTotals, nil
 }
 
+// Returns the most recent interval for a given daemon or nil if there are no
+// intervals for this daemon.
+func GetLastRpsIntervalForDaemon(db *pg.DB, daemonID int64) (*RpsInterval, error) {
+	rpsInterval := &RpsInterval{}
+	err := db.Model(rpsInterval).
+		Where("kea_daemon_id = ?", daemonID).
+		Order("start_time DESC").
+		Limit(1).
+		Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "problem getting the last RPS interval for daemon: %d", daemonID)
+	}
+	return rpsInterval, nil
+}
+
 // Add an interval to the database.
 func AddRpsInterval(db *pg.DB, rpsInterval *RpsInterval) error {
 	_, err := db.Model(rpsInterval).Insert()
@@ -164,3 +164,34 @@ func checkIntervalPerDaemon(t *testing.T, db *pg.DB, startTime time.Time, endTim
 	require.EqualValues(t, expDuration, interval.Duration)
 	require.EqualValues(t, expResponses, interval.Responses)
 }
+
+// Test that the most recent RPS interval is returned for a daemon.
+func TestGetLastRpsIntervalForDaemon(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	// No intervals yet.
+	interval, err := GetLastRpsIntervalForDaemon(db, 1)
+	require.NoError(t, err)
+	require.Nil(t, interval)
+
+	timeZero := storkutil.UTCNow().Round(time.Second)
+	for i := 0; i < 3; i++ {
+		for daemonID := int64(1); daemonID <= 2; daemonID++ {
+			err = AddRpsInterval(db, &RpsInterval{
+				KeaDaemonID: daemonID,
+				StartTime:   timeZero.Add(time.Duration(i*10) * time.Second),
+				Duration:    10,
+				Responses:   int64(i) + daemonID*100,
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	interval, err = GetLastRpsIntervalForDaemon(db, 2)
+	require.NoError(t, err)
+	require.NotNil(t, interval)
+	require.EqualValues(t, 2, interval.KeaDaemonID)
+	require.Equal(t, timeZero.Add(20*time.Second).Unix(), interval.StartTime.Unix())
+	require.EqualValues(t, 202, interval.Responses)
+}
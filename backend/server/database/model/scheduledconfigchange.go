@@ -85,11 +85,13 @@ func AddScheduledConfigChange(dbi dbops.DBI, scc *ScheduledConfigChange) error {
 	return addScheduledConfigChange(dbi.(*pg.Tx), scc)
 }
 
-// Returns all scheduled config changes.
+// Returns all scheduled config changes with the users who scheduled them.
 func GetScheduledConfigChanges(dbi dbops.DBI) ([]ScheduledConfigChange, error) {
 	var changes []ScheduledConfigChange
 	err := dbi.Model(&changes).
-		OrderExpr("deadline_at ASC").
+		Relation("User").
+		OrderExpr("scheduled_config_change.deadline_at ASC").
+		OrderExpr("scheduled_config_change.id ASC").
 		Select()
 	if err != nil {
 		if errors.Is(err, pg.ErrNoRows) {
@@ -100,6 +102,23 @@ func GetScheduledConfigChanges(dbi dbops.DBI) ([]ScheduledConfigChange, error) {
 	return changes, err
 }
 
+// Returns the scheduled config change with the user who scheduled it.
+// It returns nil if the change doesn't exist.
+func GetScheduledConfigChange(dbi dbops.DBI, changeID int64) (*ScheduledConfigChange, error) {
+	change := &ScheduledConfigChange{}
+	err := dbi.Model(change).
+		Relation("User").
+		Where("scheduled_config_change.id = ?", changeID).
+		Select()
+	if err != nil {
+		if errors.Is(err, pg.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, pkgerrors.Wrapf(err, "problem with getting scheduled config change with id %d", changeID)
+	}
+	return change, nil
+}
+
 // Returns scheduled and not executed config changes which deadline has expired.
 func GetDueConfigChanges(dbi dbops.DBI) ([]ScheduledConfigChange, error) {
 	var changes []ScheduledConfigChange
@@ -104,6 +104,23 @@ func TestAddScheduledConfigChange(t *testing.T) {
 	require.Equal(t, "host_update", returned[2].Updates[1].Operation)
 	require.Len(t, returned[2].Updates[1].DaemonIDs, 1)
 	require.EqualValues(t, 3, returned[2].Updates[1].DaemonIDs[0])
+
+	// The user who scheduled the change is returned.
+	require.NotNil(t, returned[0].User)
+	require.Equal(t, "test", returned[0].User.Login)
+
+	// Get the config change by ID.
+	change, err = GetScheduledConfigChange(db, returned[2].ID)
+	require.NoError(t, err)
+	require.NotNil(t, change)
+	require.Len(t, change.Updates, 2)
+	require.NotNil(t, change.User)
+	require.EqualValues(t, user.ID, change.User.ID)
+
+	// Non-existing config change.
+	change, err = GetScheduledConfigChange(db, returned[2].ID+1000)
+	require.NoError(t, err)
+	require.Nil(t, change)
 }
 
 // Test getting due config changes.
@@ -73,13 +73,15 @@ const (
 // A structure holding HA specific information about the service. It
 // reflects the ha_service table which extends the service table with
 // High Availability specific information. It is embedded in the
-// Service structure.
+// Service structure. Each service represents a single HA relationship.
+// The relationship is identified by the name of its primary server.
 type BaseHAService struct {
 	tableName                   struct{} `pg:"ha_service"` //nolint:unused
 	ID                          int64
 	ServiceID                   int64
 	HAType                      HAType
 	HAMode                      HAMode
+	Relationship                string
 	PrimaryID                   int64
 	SecondaryID                 int64
 	BackupID                    []int64 `pg:",array"`
@@ -93,6 +93,41 @@ func InitializeSettings(db *pg.DB, initialPullerInterval int64) error {
 			ValType: SettingValTypeInt,
 			Value:   shortInterval, // in seconds
 		},
+		{
+			Name:    "utilization_raw_retention", // in days
+			ValType: SettingValTypeInt,
+			Value:   "2",
+		},
+		{
+			Name:    "utilization_hourly_retention", // in days
+			ValType: SettingValTypeInt,
+			Value:   "60",
+		},
+		{
+			Name:    "utilization_daily_retention", // in days
+			ValType: SettingValTypeInt,
+			Value:   "730",
+		},
+		{
+			Name:    "ha_status_history_retention", // in days
+			ValType: SettingValTypeInt,
+			Value:   "90",
+		},
+		{
+			Name:    "exhaustion_forecast_horizon", // in days
+			ValType: SettingValTypeInt,
+			Value:   "7",
+		},
+		{
+			Name:    "rps_interval1", // in minutes
+			ValType: SettingValTypeInt,
+			Value:   "15",
+		},
+		{
+			Name:    "rps_interval2", // in minutes
+			ValType: SettingValTypeInt,
+			Value:   "1440",
+		},
 	}
 
 	// Check if there are new settings vs existing ones. Add new ones to DB.
@@ -49,6 +49,14 @@ func TestInitializeSettings(t *testing.T) {
 	require.NoError(t, err)
 	require.EqualValues(t, 30, val)
 
+	val, err = GetSettingInt(db, "utilization_raw_retention")
+	require.NoError(t, err)
+	require.EqualValues(t, 2, val)
+
+	val, err = GetSettingInt(db, "rps_interval2")
+	require.NoError(t, err)
+	require.EqualValues(t, 1440, val)
+
 	// change the setting
 	err = SetSettingInt(db, "kea_stats_puller_interval", 123)
 	require.NoError(t, err)
@@ -33,6 +33,16 @@ type SharedNetwork struct {
 	PdUtilization    int16
 	Stats            SubnetStats
 	StatsCollectedAt time.Time
+
+	// Projected times when the addresses and delegated prefixes run out.
+	// They are zero if the exhaustion is not expected.
+	AddrExhaustionAt time.Time
+	PdExhaustionAt   time.Time
+
+	// Numbers of the leases assigned per second over the short and long
+	// RPS intervals.
+	LeaseRate1 float64
+	LeaseRate2 float64
 }
 
 // This structure holds shared network information retrieved from an app.
@@ -169,6 +179,44 @@ func UpdateSharedNetwork(dbi dbops.DBI, network *SharedNetwork) error {
 	return updateSharedNetwork(dbi.(*pg.Tx), network)
 }
 
+// Associates the specified subnets with the shared network and dissociates
+// the remaining subnets from this network. The dissociated subnets become
+// top-level subnets. It begins a new transaction when dbi has a *pg.DB type
+// or uses an existing transaction when dbi has a *pg.Tx type.
+func SetSharedNetworkSubnets(dbi dbops.DBI, networkID int64, subnetIDs []int64) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return setSharedNetworkSubnets(tx, networkID, subnetIDs)
+		})
+	}
+	return setSharedNetworkSubnets(dbi.(*pg.Tx), networkID, subnetIDs)
+}
+
+// Associates the specified subnets with the shared network and dissociates
+// the remaining subnets from this network in a transaction.
+func setSharedNetworkSubnets(tx *pg.Tx, networkID int64, subnetIDs []int64) error {
+	q := tx.Model((*Subnet)(nil)).
+		Set("shared_network_id = NULL").
+		Where("shared_network_id = ?", networkID)
+	if len(subnetIDs) > 0 {
+		q = q.Where("id NOT IN (?)", pg.In(subnetIDs))
+	}
+	if _, err := q.Update(); err != nil {
+		return pkgerrors.Wrapf(err, "problem dissociating subnets from the shared network with ID %d", networkID)
+	}
+	if len(subnetIDs) == 0 {
+		return nil
+	}
+	_, err := tx.Model((*Subnet)(nil)).
+		Set("shared_network_id = ?", networkID).
+		Where("id IN (?)", pg.In(subnetIDs)).
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem associating subnets with the shared network with ID %d", networkID)
+	}
+	return nil
+}
+
 // Dissociates a daemon from the shared networks. The first returned value
 // indicates if any row was removed from the local_shared_network table.
 func DeleteDaemonFromSharedNetworks(dbi dbops.DBI, daemonID int64) (int64, error) {
@@ -379,6 +427,23 @@ func DeleteSharedNetworkWithSubnets(db *dbops.PgDB, networkID int64) (err error)
 	return err
 }
 
+// Returns the shared networks with their names and projected exhaustion
+// times only. The shared networks are indexed by ID.
+func GetSharedNetworksExhaustionForecasts(dbi dbops.DBI) (map[int64]*SharedNetwork, error) {
+	networks := []*SharedNetwork{}
+	err := dbi.Model(&networks).
+		Column("id", "name", "inet_family", "addr_exhaustion_at", "pd_exhaustion_at").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting exhaustion forecasts of the shared networks")
+	}
+	indexed := make(map[int64]*SharedNetwork, len(networks))
+	for _, network := range networks {
+		indexed[network.ID] = network
+	}
+	return indexed, nil
+}
+
 // Update statistics and utilization of addresses and delegated prefixes in a SharedNetwork.
 func UpdateStatisticsInSharedNetwork(dbi dbops.DBI, sharedNetworkID int64, statistics utilizationStats) error {
 	addrUtilization := statistics.GetAddressUtilization()
@@ -458,3 +523,31 @@ func (sn *SharedNetwork) Join(other *SharedNetwork) {
 		sn.SetLocalSharedNetwork(other.LocalSharedNetworks[i])
 	}
 }
+
+// Fetches daemons by the daemon IDs specified in the local shared networks
+// and assigns them to the respective LocalSharedNetwork instances. If any
+// of the daemons does not exist or an error occurs, the shared network is
+// not updated.
+func (sn SharedNetwork) PopulateDaemons(dbi dbops.DBI) error {
+	var daemons []*Daemon
+	for _, lsn := range sn.LocalSharedNetworks {
+		// DaemonID is required for this function to run.
+		if lsn.DaemonID == 0 {
+			return pkgerrors.Errorf("problem with populating daemons: shared network %d lacks daemon ID", sn.ID)
+		}
+		daemon, err := GetDaemonByID(dbi, lsn.DaemonID)
+		if err != nil {
+			return pkgerrors.WithMessage(err, "problem with populating daemons")
+		}
+		// Daemon does not exist.
+		if daemon == nil {
+			return pkgerrors.Errorf("problem with populating daemons for shared network %d: daemon %d does not exist", sn.ID, lsn.DaemonID)
+		}
+		daemons = append(daemons, daemon)
+	}
+	// Everything fine. Assign fetched daemons to the shared network.
+	for i := range sn.LocalSharedNetworks {
+		sn.LocalSharedNetworks[i].Daemon = daemons[i]
+	}
+	return nil
+}
@@ -725,3 +725,90 @@ func TestJoinSharedNetworks(t *testing.T) {
 	require.EqualValues(t, 2, sharedNetwork0.LocalSharedNetworks[1].DaemonID)
 	require.EqualValues(t, 3, sharedNetwork0.LocalSharedNetworks[2].DaemonID)
 }
+
+// Test that the subnets are associated with and dissociated from the shared
+// network.
+func TestSetSharedNetworkSubnets(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	network := SharedNetwork{
+		Name:   "foo",
+		Family: 4,
+		Subnets: []Subnet{
+			{
+				Prefix: "192.0.2.0/24",
+			},
+			{
+				Prefix: "192.0.3.0/24",
+			},
+		},
+	}
+	err := AddSharedNetwork(db, &network)
+	require.NoError(t, err)
+
+	subnet := Subnet{
+		Prefix: "192.0.4.0/24",
+	}
+	err = AddSubnet(db, &subnet)
+	require.NoError(t, err)
+
+	// Move the top-level subnet to the network and remove the first subnet
+	// from the network.
+	err = SetSharedNetworkSubnets(db, network.ID, []int64{network.Subnets[1].ID, subnet.ID})
+	require.NoError(t, err)
+
+	returned, err := GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Len(t, returned.Subnets, 2)
+	require.ElementsMatch(t, []int64{network.Subnets[1].ID, subnet.ID},
+		[]int64{returned.Subnets[0].ID, returned.Subnets[1].ID})
+
+	globalSubnets, err := GetGlobalSubnets(db, 4)
+	require.NoError(t, err)
+	require.Len(t, globalSubnets, 1)
+	require.Equal(t, network.Subnets[0].ID, globalSubnets[0].ID)
+
+	// Remove all subnets from the network.
+	err = SetSharedNetworkSubnets(db, network.ID, []int64{})
+	require.NoError(t, err)
+
+	returned, err = GetSharedNetwork(db, network.ID)
+	require.NoError(t, err)
+	require.NotNil(t, returned)
+	require.Empty(t, returned.Subnets)
+}
+
+// Test populating the daemons in the local shared networks.
+func TestSharedNetworkPopulateDaemons(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestApps(t, db)
+
+	network := SharedNetwork{
+		Name: "foo",
+		LocalSharedNetworks: []*LocalSharedNetwork{
+			{
+				DaemonID: apps[0].Daemons[0].ID,
+			},
+			{
+				DaemonID: apps[1].Daemons[0].ID,
+			},
+		},
+	}
+	err := network.PopulateDaemons(db)
+	require.NoError(t, err)
+	require.NotNil(t, network.LocalSharedNetworks[0].Daemon)
+	require.Equal(t, apps[0].Daemons[0].ID, network.LocalSharedNetworks[0].Daemon.ID)
+	require.NotNil(t, network.LocalSharedNetworks[1].Daemon)
+	require.Equal(t, apps[1].Daemons[0].ID, network.LocalSharedNetworks[1].Daemon.ID)
+
+	// Non-existing daemon.
+	network.LocalSharedNetworks[1].DaemonID = apps[1].Daemons[0].ID + 1000
+	network.LocalSharedNetworks[0].Daemon = nil
+	err = network.PopulateDaemons(db)
+	require.Error(t, err)
+	require.Nil(t, network.LocalSharedNetworks[0].Daemon)
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"math/big"
 	"strconv"
 	"strings"
@@ -166,6 +167,16 @@ type Subnet struct {
 	PdUtilization    int16
 	Stats            SubnetStats
 	StatsCollectedAt time.Time
+
+	// Projected times when the addresses and delegated prefixes run out.
+	// They are zero if the exhaustion is not expected.
+	AddrExhaustionAt time.Time
+	PdExhaustionAt   time.Time
+
+	// Numbers of the leases assigned per second over the short and long
+	// RPS intervals.
+	LeaseRate1 float64
+	LeaseRate2 float64
 }
 
 // Returns local subnet id for the specified daemon.
@@ -688,7 +699,7 @@ func GetSubnetsWithLocalSubnets(dbi dbops.DBI) ([]*Subnet, error) {
 	subnets := []*Subnet{}
 	q := dbi.Model(&subnets)
 	// only selected columns are returned for performance reasons
-	q = q.Column("id", "shared_network_id", "prefix")
+	q = q.Column("id", "shared_network_id", "prefix", "addr_exhaustion_at", "pd_exhaustion_at")
 	q = q.Relation("LocalSubnets")
 	q = q.Order("shared_network_id ASC")
 
@@ -783,6 +794,63 @@ func DeleteDaemonFromSubnets(dbi dbops.DBI, daemonID int64) (int64, error) {
 	return int64(result.RowsAffected()), nil
 }
 
+// The highest subnet ID accepted by Kea. The subnet IDs are 32-bit unsigned
+// integers and Kea reserves the maximum value for the unused subnet ID.
+const MaxLocalSubnetID int64 = math.MaxUint32 - 1
+
+// Returns the subnet ID which is not used by any of the specified daemons.
+// It is greater than the highest subnet ID configured in these daemons.
+// It is used to assign the same ID to a new subnet in all daemons. It
+// returns an error if the highest subnet ID is already used.
+func GetNextFreeLocalSubnetID(dbi dbops.DBI, daemonIDs ...int64) (int64, error) {
+	if len(daemonIDs) == 0 {
+		return 1, nil
+	}
+	var maxID int64
+	err := dbi.Model((*LocalSubnet)(nil)).
+		ColumnExpr("COALESCE(MAX(local_subnet_id), 0)").
+		Where("daemon_id IN (?)", pg.In(daemonIDs)).
+		Select(&maxID)
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem getting the highest subnet ID of the daemons %v", daemonIDs)
+	}
+	if maxID >= MaxLocalSubnetID {
+		return 0, pkgerrors.Errorf("no free subnet ID above %d in the daemons %v", maxID, daemonIDs)
+	}
+	return maxID + 1, nil
+}
+
+// Checks if the subnet ID is used by any of the specified daemons.
+func IsLocalSubnetIDUsed(dbi dbops.DBI, subnetID int64, daemonIDs ...int64) (bool, error) {
+	if len(daemonIDs) == 0 {
+		return false, nil
+	}
+	exists, err := dbi.Model((*LocalSubnet)(nil)).
+		Where("local_subnet_id = ?", subnetID).
+		Where("daemon_id IN (?)", pg.In(daemonIDs)).
+		Exists()
+	if err != nil {
+		return false, pkgerrors.Wrapf(err, "problem checking if the subnet ID %d is used by the daemons %v", subnetID, daemonIDs)
+	}
+	return exists, nil
+}
+
+// Deletes the subnet with the specified ID. The associations of the subnet
+// with the daemons, its pools and host reservations are deleted too.
+func DeleteSubnet(dbi dbops.DBI, subnetID int64) error {
+	subnet := &Subnet{
+		ID: subnetID,
+	}
+	result, err := dbi.Model(subnet).WherePK().Delete()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting the subnet with ID %d", subnetID)
+	}
+	if result.RowsAffected() <= 0 {
+		return pkgerrors.Wrapf(ErrNotExists, "subnet with ID %d does not exist", subnetID)
+	}
+	return nil
+}
+
 // Finds and returns an app associated with a subnet having the specified id.
 func (s *Subnet) GetApp(appID int64) *App {
 	for _, s := range s.LocalSubnets {
@@ -913,6 +981,26 @@ func GetAppLocalSubnets(dbi dbops.DBI, appID int64) ([]*LocalSubnet, error) {
 	return subnets, nil
 }
 
+// Returns the local subnets of the daemon with their address and prefix
+// pools. The statistics of the local subnets are not returned.
+func GetDaemonLocalSubnetsWithPools(dbi dbops.DBI, daemonID int64) ([]*LocalSubnet, error) {
+	subnets := []*LocalSubnet{}
+	err := dbi.Model(&subnets).
+		Column("local_subnet.id", "local_subnet.daemon_id", "local_subnet.subnet_id", "local_subnet.local_subnet_id").
+		Relation("AddressPools", func(q *orm.Query) (*orm.Query, error) {
+			return q.Order("address_pool.id ASC"), nil
+		}).
+		Relation("PrefixPools", func(q *orm.Query) (*orm.Query, error) {
+			return q.Order("prefix_pool.id ASC"), nil
+		}).
+		Where("local_subnet.daemon_id = ?", daemonID).
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting local subnets with pools for daemon %d", daemonID)
+	}
+	return subnets, nil
+}
+
 // Update stats pulled for given local subnet.
 func (lsn *LocalSubnet) UpdateStats(dbi dbops.DBI, stats SubnetStats) error {
 	lsn.Stats = stats
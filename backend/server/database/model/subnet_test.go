@@ -1733,3 +1733,123 @@ func TestPopulateSubnetDaemons(t *testing.T) {
 	require.NotNil(t, subnet.LocalSubnets[1].Daemon)
 	require.EqualValues(t, apps[1].Daemons[0].ID, subnet.LocalSubnets[1].Daemon.ID)
 }
+
+// Test that the next free subnet ID is the same for all specified daemons.
+func TestGetNextFreeLocalSubnetID(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestApps(t, db)
+
+	// No subnets yet.
+	id, err := GetNextFreeLocalSubnetID(db, apps[0].Daemons[0].ID, apps[1].Daemons[0].ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, id)
+
+	subnets := []Subnet{
+		{
+			Prefix: "192.0.2.0/24",
+			LocalSubnets: []*LocalSubnet{
+				{
+					DaemonID:      apps[0].Daemons[0].ID,
+					LocalSubnetID: 5,
+				},
+			},
+		},
+		{
+			Prefix: "192.0.3.0/24",
+			LocalSubnets: []*LocalSubnet{
+				{
+					DaemonID:      apps[1].Daemons[0].ID,
+					LocalSubnetID: 12,
+				},
+			},
+		},
+		{
+			Prefix: "192.0.4.0/24",
+			LocalSubnets: []*LocalSubnet{
+				{
+					DaemonID:      apps[2].Daemons[0].ID,
+					LocalSubnetID: 100,
+				},
+			},
+		},
+	}
+	_, err = CommitNetworksIntoDB(db, []SharedNetwork{}, subnets)
+	require.NoError(t, err)
+
+	// The ID must be free in both daemons. The subnets of the third
+	// daemon are not taken into account.
+	id, err = GetNextFreeLocalSubnetID(db, apps[0].Daemons[0].ID, apps[1].Daemons[0].ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 13, id)
+
+	id, err = GetNextFreeLocalSubnetID(db, apps[0].Daemons[0].ID)
+	require.NoError(t, err)
+	require.EqualValues(t, 6, id)
+
+	used, err := IsLocalSubnetIDUsed(db, 12, apps[0].Daemons[0].ID, apps[1].Daemons[0].ID)
+	require.NoError(t, err)
+	require.True(t, used)
+	used, err = IsLocalSubnetIDUsed(db, 100, apps[0].Daemons[0].ID, apps[1].Daemons[0].ID)
+	require.NoError(t, err)
+	require.False(t, used)
+
+	// There is no free ID above the highest subnet ID accepted by Kea.
+	_, err = CommitNetworksIntoDB(db, []SharedNetwork{}, []Subnet{
+		{
+			Prefix: "192.0.5.0/24",
+			LocalSubnets: []*LocalSubnet{
+				{
+					DaemonID:      apps[2].Daemons[0].ID,
+					LocalSubnetID: MaxLocalSubnetID,
+				},
+			},
+		},
+	})
+	require.NoError(t, err)
+	_, err = GetNextFreeLocalSubnetID(db, apps[2].Daemons[0].ID)
+	require.Error(t, err)
+}
+
+// Test that the subnet is deleted with its associations with the daemons.
+func TestDeleteSubnet(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	apps := addTestApps(t, db)
+
+	subnet := &Subnet{
+		Prefix: "192.0.2.0/24",
+		LocalSubnets: []*LocalSubnet{
+			{
+				DaemonID:      apps[0].Daemons[0].ID,
+				LocalSubnetID: 1,
+				AddressPools: []AddressPool{
+					{LowerBound: "192.0.2.10", UpperBound: "192.0.2.20"},
+				},
+			},
+		},
+	}
+	_, err := CommitNetworksIntoDB(db, []SharedNetwork{}, []Subnet{*subnet})
+	require.NoError(t, err)
+
+	subnets, err := GetSubnetsByPrefix(db, "192.0.2.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+
+	err = DeleteSubnet(db, subnets[0].ID)
+	require.NoError(t, err)
+
+	returned, err := GetSubnet(db, subnets[0].ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+
+	localSubnets, err := GetDaemonLocalSubnetsWithPools(db, apps[0].Daemons[0].ID)
+	require.NoError(t, err)
+	require.Empty(t, localSubnets)
+
+	// Deleting a non-existing subnet is an error.
+	err = DeleteSubnet(db, subnets[0].ID)
+	require.ErrorIs(t, err, ErrNotExists)
+}
@@ -0,0 +1,195 @@
+package dbmodel
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Tier of the utilization samples. The raw samples are stored by the
+// statistics puller. They are downsampled into the hourly samples and
+// the hourly samples are downsampled into the daily samples.
+type UtilizationTier string
+
+// Supported utilization tiers.
+const (
+	UtilizationTierRaw    UtilizationTier = "raw"
+	UtilizationTierHourly UtilizationTier = "hourly"
+	UtilizationTierDaily  UtilizationTier = "daily"
+)
+
+// The maximum time ranges for which the utilization samples of a given tier
+// are returned when the tier is not explicitly specified. The daily samples
+// are returned for the longer time ranges.
+const (
+	utilizationRawTierMaxRange    = 48 * time.Hour
+	utilizationHourlyTierMaxRange = 60 * 24 * time.Hour
+)
+
+// A single sample of the address and delegated prefix utilization of a
+// subnet, a shared network or the global utilization for a given family.
+// The global samples have neither subnet ID nor shared network ID. The
+// utilizations are stored in permilles, like in the subnet and shared
+// network tables. The downsampled samples hold the average utilization
+// in the hour or day they begin and the number of the raw samples they
+// aggregate.
+type UtilizationSample struct {
+	ID              int64
+	Tier            UtilizationTier
+	SubnetID        int64
+	SharedNetworkID int64
+	Family          int8
+	SampledAt       time.Time
+	AddrUtilization int16 `pg:",use_zero"`
+	PdUtilization   int16 `pg:",use_zero"`
+	SampleCount     int64
+}
+
+// Returns the tier which samples should be returned for the time range,
+// so the number of returned samples is reasonable.
+func GetUtilizationTierForRange(from, to time.Time) UtilizationTier {
+	switch length := to.Sub(from); {
+	case length <= utilizationRawTierMaxRange:
+		return UtilizationTierRaw
+	case length <= utilizationHourlyTierMaxRange:
+		return UtilizationTierHourly
+	default:
+		return UtilizationTierDaily
+	}
+}
+
+// Inserts the raw utilization samples. The samples duplicating the existing
+// ones, i.e., the samples for the same series at the same time, are
+// ignored.
+func AddUtilizationSamples(dbi dbops.DBI, samples []*UtilizationSample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	for _, sample := range samples {
+		sample.Tier = UtilizationTierRaw
+		if sample.SampleCount == 0 {
+			sample.SampleCount = 1
+		}
+	}
+	_, err := dbi.Model(&samples).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem inserting %d utilization samples", len(samples))
+	}
+	return err
+}
+
+// Returns the time of the most recent raw utilization sample of any of the
+// specified subnets. It returns zero time if there are no such samples.
+func GetLatestSubnetUtilizationSampleTime(dbi dbops.DBI, subnetIDs []int64) (time.Time, error) {
+	var latest pg.NullTime
+	if len(subnetIDs) == 0 {
+		return latest.Time, nil
+	}
+	err := dbi.Model((*UtilizationSample)(nil)).
+		ColumnExpr("MAX(sampled_at)").
+		Where("tier = ?", UtilizationTierRaw).
+		Where("subnet_id IN (?)", pg.In(subnetIDs)).
+		Select(pg.Scan(&latest))
+	if err != nil {
+		err = pkgerrors.Wrap(err, "problem getting the time of the latest subnet utilization sample")
+	}
+	return latest.Time, err
+}
+
+// Aggregates the samples from the source tier into the target tier. Each
+// target sample holds the average of the source samples within a period
+// specified by the unit (hour or day). Only the periods that ended before
+// the specified time are processed. The periods of each series are
+// aggregated independently, so the samples arriving late for a series
+// are aggregated too. The existing target samples are recomputed if the
+// source tier holds more samples for their periods than they aggregate.
+// They are never recomputed from fewer samples, so the periods partially
+// removed from the source tier by the retention keep their aggregates.
+func downsampleUtilizationSamples(dbi dbops.DBI, source, target UtilizationTier, unit string, now time.Time) error {
+	_, err := dbi.Exec(`
+		INSERT INTO utilization_sample
+			(tier, subnet_id, shared_network_id, family, sampled_at, addr_utilization, pd_utilization, sample_count)
+		SELECT ?, subnet_id, shared_network_id, family, date_trunc(?, sampled_at) AS period,
+			ROUND(SUM(addr_utilization * sample_count)::numeric / SUM(sample_count)),
+			ROUND(SUM(pd_utilization * sample_count)::numeric / SUM(sample_count)),
+			SUM(sample_count)
+		FROM utilization_sample
+		WHERE tier = ?
+			AND sampled_at < date_trunc(?, ?::timestamp)
+		GROUP BY subnet_id, shared_network_id, family, period
+		ON CONFLICT (tier, COALESCE(subnet_id, 0), COALESCE(shared_network_id, 0), family, sampled_at)
+		DO UPDATE SET
+			addr_utilization = EXCLUDED.addr_utilization,
+			pd_utilization = EXCLUDED.pd_utilization,
+			sample_count = EXCLUDED.sample_count
+		WHERE utilization_sample.sample_count < EXCLUDED.sample_count
+	`, target, unit, source, unit, now)
+	if err != nil {
+		err = pkgerrors.Wrapf(err, "problem downsampling %s utilization samples into %s samples", source, target)
+	}
+	return err
+}
+
+// Downsamples the raw utilization samples into the hourly samples and
+// the hourly samples into the daily samples. The hours and days which
+// haven't ended before the specified time are not downsampled yet.
+func DownsampleUtilizationSamples(dbi dbops.DBI, now time.Time) error {
+	if err := downsampleUtilizationSamples(dbi, UtilizationTierRaw, UtilizationTierHourly, "hour", now); err != nil {
+		return err
+	}
+	return downsampleUtilizationSamples(dbi, UtilizationTierHourly, UtilizationTierDaily, "day", now)
+}
+
+// Deletes the samples of the given tier which are older than the specified
+// time. It returns the number of the deleted samples.
+func DeleteUtilizationSamplesBefore(dbi dbops.DBI, tier UtilizationTier, before time.Time) (int, error) {
+	result, err := dbi.Model((*UtilizationSample)(nil)).
+		Where("tier = ?", tier).
+		Where("sampled_at < ?", before).
+		Delete()
+	if err != nil {
+		return 0, pkgerrors.Wrapf(err, "problem deleting %s utilization samples older than %s", tier, before)
+	}
+	return result.RowsAffected(), nil
+}
+
+// Returns the samples of the given tier selected using the specified
+// condition, ordered by time.
+func getUtilizationSamples(dbi dbops.DBI, tier UtilizationTier, from, to time.Time, condition string, params ...interface{}) ([]*UtilizationSample, error) {
+	samples := []*UtilizationSample{}
+	err := dbi.Model(&samples).
+		Where("tier = ?", tier).
+		Where("sampled_at >= ?", from).
+		Where("sampled_at <= ?", to).
+		Where(condition, params...).
+		Order("sampled_at ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting %s utilization samples", tier)
+	}
+	return samples, nil
+}
+
+// Returns the utilization samples of all series within the time range.
+func GetUtilizationSamples(dbi dbops.DBI, tier UtilizationTier, from, to time.Time) ([]*UtilizationSample, error) {
+	return getUtilizationSamples(dbi, tier, from, to, "TRUE")
+}
+
+// Returns the utilization samples of the subnet within the time range.
+func GetSubnetUtilizationSamples(dbi dbops.DBI, subnetID int64, tier UtilizationTier, from, to time.Time) ([]*UtilizationSample, error) {
+	return getUtilizationSamples(dbi, tier, from, to, "subnet_id = ?", subnetID)
+}
+
+// Returns the utilization samples of the shared network within the time
+// range.
+func GetSharedNetworkUtilizationSamples(dbi dbops.DBI, sharedNetworkID int64, tier UtilizationTier, from, to time.Time) ([]*UtilizationSample, error) {
+	return getUtilizationSamples(dbi, tier, from, to, "shared_network_id = ?", sharedNetworkID)
+}
+
+// Returns the global utilization samples for the family within the time
+// range.
+func GetGlobalUtilizationSamples(dbi dbops.DBI, family int8, tier UtilizationTier, from, to time.Time) ([]*UtilizationSample, error) {
+	return getUtilizationSamples(dbi, tier, from, to, "subnet_id IS NULL AND shared_network_id IS NULL AND family = ?", family)
+}
@@ -0,0 +1,296 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	dbops "isc.org/stork/server/database"
+	dbtest "isc.org/stork/server/database/test"
+)
+
+// Test that the tier is selected according to the time range length.
+func TestGetUtilizationTierForRange(t *testing.T) {
+	to := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	require.Equal(t, UtilizationTierRaw, GetUtilizationTierForRange(to.Add(-time.Hour), to))
+	require.Equal(t, UtilizationTierRaw, GetUtilizationTierForRange(to.Add(-48*time.Hour), to))
+	require.Equal(t, UtilizationTierHourly, GetUtilizationTierForRange(to.Add(-49*time.Hour), to))
+	require.Equal(t, UtilizationTierHourly, GetUtilizationTierForRange(to.AddDate(0, 0, -60), to))
+	require.Equal(t, UtilizationTierDaily, GetUtilizationTierForRange(to.AddDate(0, 0, -61), to))
+}
+
+// Adds a subnet and a shared network used in the utilization tests.
+func addUtilizationTestNetworks(t *testing.T, db dbops.DBI) (*Subnet, *SharedNetwork) {
+	network := &SharedNetwork{
+		Name:   "frog",
+		Family: 4,
+	}
+	err := AddSharedNetwork(db, network)
+	require.NoError(t, err)
+
+	subnet := &Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = AddSubnet(db, subnet)
+	require.NoError(t, err)
+	return subnet, network
+}
+
+// Test that the raw utilization samples are inserted and selected for
+// the subnets, shared networks and globally.
+func TestAddAndGetUtilizationSamples(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet, network := addUtilizationTestNetworks(t, db)
+
+	sampledAt := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	samples := []*UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: sampledAt, AddrUtilization: 100},
+		{SubnetID: subnet.ID, Family: 4, SampledAt: sampledAt.Add(time.Minute), AddrUtilization: 200},
+		{SharedNetworkID: network.ID, Family: 4, SampledAt: sampledAt, AddrUtilization: 300},
+		{Family: 4, SampledAt: sampledAt, AddrUtilization: 400},
+		{Family: 6, SampledAt: sampledAt, AddrUtilization: 500, PdUtilization: 600},
+	}
+	err := AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	// The duplicated samples are ignored.
+	err = AddUtilizationSamples(db, []*UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: sampledAt, AddrUtilization: 900},
+		{Family: 6, SampledAt: sampledAt, AddrUtilization: 900},
+	})
+	require.NoError(t, err)
+
+	from := sampledAt.Add(-time.Hour)
+	to := sampledAt.Add(time.Hour)
+
+	returned, err := GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Len(t, returned, 2)
+	require.WithinDuration(t, sampledAt, returned[0].SampledAt, 0)
+	require.EqualValues(t, 100, returned[0].AddrUtilization)
+	require.EqualValues(t, 1, returned[0].SampleCount)
+	require.Equal(t, UtilizationTierRaw, returned[0].Tier)
+	require.EqualValues(t, 200, returned[1].AddrUtilization)
+
+	// The time range is respected.
+	returned, err = GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierRaw, from, sampledAt)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+
+	returned, err = GetSharedNetworkUtilizationSamples(db, network.ID, UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.EqualValues(t, 300, returned[0].AddrUtilization)
+
+	returned, err = GetGlobalUtilizationSamples(db, 6, UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.EqualValues(t, 500, returned[0].AddrUtilization)
+	require.EqualValues(t, 600, returned[0].PdUtilization)
+
+	// There are no hourly samples yet.
+	returned, err = GetGlobalUtilizationSamples(db, 4, UtilizationTierHourly, from, to)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+
+	// Deleting the subnet deletes its samples. The subnet is orphaned
+	// because it isn't associated with any daemon.
+	count, err := DeleteOrphanedSubnets(db)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, count)
+	returned, err = GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierRaw, from, to)
+	require.NoError(t, err)
+	require.Empty(t, returned)
+}
+
+// Test that the raw samples are downsampled into the hourly samples and
+// the hourly samples are downsampled into the daily samples.
+func TestDownsampleUtilizationSamples(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet, _ := addUtilizationTestNetworks(t, db)
+
+	day := time.Date(2023, 5, 10, 0, 0, 0, 0, time.UTC)
+	samples := []*UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: day.Add(10*time.Hour + 10*time.Minute), AddrUtilization: 100},
+		{SubnetID: subnet.ID, Family: 4, SampledAt: day.Add(10*time.Hour + 40*time.Minute), AddrUtilization: 300},
+		{SubnetID: subnet.ID, Family: 4, SampledAt: day.Add(11*time.Hour + 20*time.Minute), AddrUtilization: 500},
+	}
+	err := AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	// Only the hour that has ended is downsampled. Repeating the
+	// downsampling doesn't duplicate the samples.
+	for i := 0; i < 2; i++ {
+		err = DownsampleUtilizationSamples(db, day.Add(11*time.Hour+30*time.Minute))
+		require.NoError(t, err)
+	}
+
+	from := day.AddDate(0, 0, -1)
+	to := day.AddDate(0, 0, 2)
+	hourly, err := GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierHourly, from, to)
+	require.NoError(t, err)
+	require.Len(t, hourly, 1)
+	require.WithinDuration(t, day.Add(10*time.Hour), hourly[0].SampledAt, 0)
+	require.EqualValues(t, 200, hourly[0].AddrUtilization)
+	require.EqualValues(t, 2, hourly[0].SampleCount)
+
+	daily, err := GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierDaily, from, to)
+	require.NoError(t, err)
+	require.Empty(t, daily)
+
+	// The next day, the remaining hour and the whole day are downsampled.
+	// The daily sample is weighted by the number of the raw samples.
+	err = DownsampleUtilizationSamples(db, day.AddDate(0, 0, 1).Add(30*time.Minute))
+	require.NoError(t, err)
+
+	hourly, err = GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierHourly, from, to)
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+	require.EqualValues(t, 500, hourly[1].AddrUtilization)
+	require.EqualValues(t, 1, hourly[1].SampleCount)
+
+	daily, err = GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierDaily, from, to)
+	require.NoError(t, err)
+	require.Len(t, daily, 1)
+	require.WithinDuration(t, day, daily[0].SampledAt, 0)
+	require.EqualValues(t, 300, daily[0].AddrUtilization)
+	require.EqualValues(t, 3, daily[0].SampleCount)
+}
+
+// Test that the samples arriving late for a series and the samples of the
+// series lagging behind the other series are downsampled.
+func TestDownsampleLateUtilizationSamples(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet, sharedNetwork := addUtilizationTestNetworks(t, db)
+
+	day := time.Date(2023, 5, 10, 0, 0, 0, 0, time.UTC)
+	samples := []*UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: day.Add(10*time.Hour + 10*time.Minute), AddrUtilization: 100},
+		{SubnetID: subnet.ID, Family: 4, SampledAt: day.Add(11*time.Hour + 20*time.Minute), AddrUtilization: 500},
+	}
+	err := AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	err = DownsampleUtilizationSamples(db, day.AddDate(0, 0, 1).Add(30*time.Minute))
+	require.NoError(t, err)
+
+	// Add the late samples of the subnet and the samples of the shared
+	// network preceding the already downsampled subnet samples.
+	samples = []*UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: day.Add(10*time.Hour + 40*time.Minute), AddrUtilization: 400},
+		{SharedNetworkID: sharedNetwork.ID, Family: 4, SampledAt: day.Add(9*time.Hour + 15*time.Minute), AddrUtilization: 250},
+	}
+	err = AddUtilizationSamples(db, samples)
+	require.NoError(t, err)
+
+	err = DownsampleUtilizationSamples(db, day.AddDate(0, 0, 1).Add(40*time.Minute))
+	require.NoError(t, err)
+
+	from := day.AddDate(0, 0, -1)
+	to := day.AddDate(0, 0, 2)
+
+	// The hourly and daily samples of the subnet include the late sample.
+	hourly, err := GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierHourly, from, to)
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+	require.EqualValues(t, 250, hourly[0].AddrUtilization)
+	require.EqualValues(t, 2, hourly[0].SampleCount)
+
+	daily, err := GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierDaily, from, to)
+	require.NoError(t, err)
+	require.Len(t, daily, 1)
+	require.EqualValues(t, 333, daily[0].AddrUtilization)
+	require.EqualValues(t, 3, daily[0].SampleCount)
+
+	// The shared network samples are downsampled too.
+	hourly, err = GetSharedNetworkUtilizationSamples(db, sharedNetwork.ID, UtilizationTierHourly, from, to)
+	require.NoError(t, err)
+	require.Len(t, hourly, 1)
+	require.WithinDuration(t, day.Add(9*time.Hour), hourly[0].SampledAt, 0)
+	require.EqualValues(t, 250, hourly[0].AddrUtilization)
+
+	daily, err = GetSharedNetworkUtilizationSamples(db, sharedNetwork.ID, UtilizationTierDaily, from, to)
+	require.NoError(t, err)
+	require.Len(t, daily, 1)
+	require.EqualValues(t, 250, daily[0].AddrUtilization)
+
+	// Removing the raw samples doesn't change the downsampled samples.
+	_, err = DeleteUtilizationSamplesBefore(db, UtilizationTierRaw, day.Add(10*time.Hour+30*time.Minute))
+	require.NoError(t, err)
+	err = DownsampleUtilizationSamples(db, day.AddDate(0, 0, 1).Add(50*time.Minute))
+	require.NoError(t, err)
+
+	hourly, err = GetSubnetUtilizationSamples(db, subnet.ID, UtilizationTierHourly, from, to)
+	require.NoError(t, err)
+	require.Len(t, hourly, 2)
+	require.EqualValues(t, 250, hourly[0].AddrUtilization)
+	require.EqualValues(t, 2, hourly[0].SampleCount)
+}
+
+// Test that the samples of a tier older than the specified time are deleted.
+func TestDeleteUtilizationSamplesBefore(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	sampledAt := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	err := AddUtilizationSamples(db, []*UtilizationSample{
+		{Family: 4, SampledAt: sampledAt.Add(-2 * time.Hour)},
+		{Family: 4, SampledAt: sampledAt.Add(-time.Hour)},
+		{Family: 4, SampledAt: sampledAt},
+	})
+	require.NoError(t, err)
+
+	// The hourly samples don't exist.
+	count, err := DeleteUtilizationSamplesBefore(db, UtilizationTierHourly, sampledAt)
+	require.NoError(t, err)
+	require.Zero(t, count)
+
+	count, err = DeleteUtilizationSamplesBefore(db, UtilizationTierRaw, sampledAt.Add(-30*time.Minute))
+	require.NoError(t, err)
+	require.EqualValues(t, 2, count)
+
+	returned, err := GetGlobalUtilizationSamples(db, 4, UtilizationTierRaw, sampledAt.Add(-time.Hour*24), sampledAt)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+}
+
+// Test that the time of the latest raw sample of the subnets is returned.
+func TestGetLatestSubnetUtilizationSampleTime(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	subnet, network := addUtilizationTestNetworks(t, db)
+
+	// No samples yet.
+	latest, err := GetLatestSubnetUtilizationSampleTime(db, []int64{subnet.ID})
+	require.NoError(t, err)
+	require.Zero(t, latest)
+
+	sampledAt := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	err = AddUtilizationSamples(db, []*UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: sampledAt},
+		{SubnetID: subnet.ID, Family: 4, SampledAt: sampledAt.Add(time.Minute)},
+		{SharedNetworkID: network.ID, Family: 4, SampledAt: sampledAt.Add(time.Hour)},
+		{Family: 4, SampledAt: sampledAt.Add(time.Hour)},
+	})
+	require.NoError(t, err)
+
+	latest, err = GetLatestSubnetUtilizationSampleTime(db, []int64{subnet.ID})
+	require.NoError(t, err)
+	require.WithinDuration(t, sampledAt.Add(time.Minute), latest, 0)
+
+	// Other subnets have no samples.
+	latest, err = GetLatestSubnetUtilizationSampleTime(db, []int64{subnet.ID + 1})
+	require.NoError(t, err)
+	require.Zero(t, latest)
+
+	latest, err = GetLatestSubnetUtilizationSampleTime(db, nil)
+	require.NoError(t, err)
+	require.Zero(t, latest)
+}
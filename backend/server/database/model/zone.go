@@ -0,0 +1,169 @@
+package dbmodel
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	pkgerrors "github.com/pkg/errors"
+	dbops "isc.org/stork/server/database"
+)
+
+// Types of the DNS zones returned by BIND 9. The older BIND 9 versions
+// use the master and slave names for the primary and secondary zones.
+const (
+	ZoneTypePrimary      = "primary"
+	ZoneTypeSecondary    = "secondary"
+	zoneTypeMasterLegacy = "master"
+	zoneTypeSlaveLegacy  = "slave"
+)
+
+// Half of the serial number space. The serial is newer than the other
+// serial if the distance between them is lower than this limit.
+const serialArithmeticLimit = 1 << 31
+
+// A DNS zone served by a BIND 9 daemon in a view. The zones are pulled
+// from the statistics channel of the daemon. The serial is nil if the zone
+// hasn't been loaded, e.g., the secondary server has failed to transfer
+// it. The expiration and refresh times are only set for the secondary
+// zones. The next refresh time is returned by BIND 9. The last refresh
+// time is not returned by BIND 9, so it is derived from the zone load
+// times and the changes of the next refresh time. The flags are set by the
+// server when it detects that the serial of the secondary zone falls behind
+// the serial of the primary zone for too long or the zone transfer has
+// failed. The time when the serial started falling behind is zero if the
+// serial doesn't fall behind.
+type Zone struct {
+	ID                 int64
+	DaemonID           int64
+	Daemon             *Daemon `pg:"rel:has-one"`
+	View               string
+	Name               string
+	Class              string
+	Type               string
+	Serial             *int64
+	LoadedAt           time.Time
+	ExpiresAt          time.Time
+	NextRefreshAt      time.Time
+	RefreshedAt        time.Time
+	SerialLagging      bool `pg:",use_zero"`
+	SerialLaggingSince time.Time
+	TransferFailed     bool `pg:",use_zero"`
+	UpdatedAt          time.Time
+}
+
+// Checks if the zone is a primary zone.
+func (z *Zone) IsPrimary() bool {
+	return z.Type == ZoneTypePrimary || z.Type == zoneTypeMasterLegacy
+}
+
+// Checks if the zone is a secondary zone.
+func (z *Zone) IsSecondary() bool {
+	return z.Type == ZoneTypeSecondary || z.Type == zoneTypeSlaveLegacy
+}
+
+// Checks if the serial falls behind the other serial according to the
+// serial number arithmetic (RFC 1982). The serials wrap around at 2^32,
+// so the greater serial isn't always the newer one.
+func IsSerialBehind(serial, other int64) bool {
+	s1 := uint32(serial)
+	s2 := uint32(other)
+	return (s1 < s2 && s2-s1 < serialArithmeticLimit) || (s1 > s2 && s1-s2 > serialArithmeticLimit)
+}
+
+// Inserts or updates the zones of the daemon in a transaction and deletes
+// the daemon's zones that are not specified. The flags indicating the
+// zone issues are preserved for the existing zones. The last refresh
+// time of the existing zone is the latest of its previous value, the
+// specified value and the previous next refresh time, if BIND 9 has
+// rescheduled the refresh after that time, i.e., it has refreshed the
+// zone.
+func commitZones(tx *pg.Tx, daemonID int64, zones []*Zone) error {
+	var ids []int64
+	for _, zone := range zones {
+		zone.DaemonID = daemonID
+		_, err := tx.Model(zone).
+			OnConflict("(daemon_id, view, name, class) DO UPDATE").
+			Set("type = EXCLUDED.type").
+			Set("serial = EXCLUDED.serial").
+			Set("loaded_at = EXCLUDED.loaded_at").
+			Set("expires_at = EXCLUDED.expires_at").
+			Set("next_refresh_at = EXCLUDED.next_refresh_at").
+			Set(`refreshed_at = GREATEST(zone.refreshed_at, EXCLUDED.refreshed_at,
+				CASE WHEN zone.next_refresh_at IS DISTINCT FROM EXCLUDED.next_refresh_at
+					AND zone.next_refresh_at <= EXCLUDED.updated_at
+				THEN zone.next_refresh_at END)`).
+			Set("updated_at = EXCLUDED.updated_at").
+			Returning("id, refreshed_at, serial_lagging, serial_lagging_since, transfer_failed").
+			Insert()
+		if err != nil {
+			return pkgerrors.Wrapf(err, "problem upserting zone %s in view %s for daemon %d",
+				zone.Name, zone.View, daemonID)
+		}
+		ids = append(ids, zone.ID)
+	}
+	q := tx.Model((*Zone)(nil)).Where("daemon_id = ?", daemonID)
+	if len(ids) > 0 {
+		q = q.Where("id NOT IN (?)", pg.In(ids))
+	}
+	if _, err := q.Delete(); err != nil {
+		return pkgerrors.Wrapf(err, "problem deleting outdated zones of daemon %d", daemonID)
+	}
+	return nil
+}
+
+// Inserts or updates the zones of the daemon and deletes the daemon's
+// zones that are not specified, i.e., the zones no longer served by the
+// daemon.
+func CommitZones(dbi dbops.DBI, daemonID int64, zones []*Zone) error {
+	if db, ok := dbi.(*pg.DB); ok {
+		return db.RunInTransaction(context.Background(), func(tx *pg.Tx) error {
+			return commitZones(tx, daemonID, zones)
+		})
+	}
+	return commitZones(dbi.(*pg.Tx), daemonID, zones)
+}
+
+// Returns the zones served by the daemon ordered by view and name.
+func GetZonesByDaemonID(dbi dbops.DBI, daemonID int64) ([]Zone, error) {
+	var zones []Zone
+	err := dbi.Model(&zones).
+		Where("daemon_id = ?", daemonID).
+		OrderExpr("view ASC").
+		OrderExpr("name ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrapf(err, "problem getting zones of daemon %d", daemonID)
+	}
+	return zones, nil
+}
+
+// Returns all zones with the daemons serving them, their apps, machines
+// and access points, ordered by name and ID. The daemons' configurations
+// are not returned because they would be decoded for each zone.
+func GetAllZones(dbi dbops.DBI) ([]Zone, error) {
+	var zones []Zone
+	err := dbi.Model(&zones).
+		Relation("Daemon.App.Machine").
+		Relation("Daemon.App.AccessPoints").
+		OrderExpr("zone.name ASC").
+		OrderExpr("zone.id ASC").
+		Select()
+	if err != nil {
+		return nil, pkgerrors.Wrap(err, "problem getting zones")
+	}
+	return zones, nil
+}
+
+// Updates the flags indicating the zone issues and the time when the
+// serial started falling behind.
+func UpdateZoneIssues(dbi dbops.DBI, zone *Zone) error {
+	_, err := dbi.Model(zone).
+		Column("serial_lagging", "serial_lagging_since", "transfer_failed").
+		WherePK().
+		Update()
+	if err != nil {
+		return pkgerrors.Wrapf(err, "problem updating issues of zone %d", zone.ID)
+	}
+	return nil
+}
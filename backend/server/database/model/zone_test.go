@@ -0,0 +1,145 @@
+package dbmodel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg/v10"
+	"github.com/stretchr/testify/require"
+	dbtest "isc.org/stork/server/database/test"
+	storkutil "isc.org/stork/util"
+)
+
+// Adds a machine with a BIND 9 app and returns the daemon.
+func addTestBind9Daemon(t *testing.T, db *pg.DB) *Daemon {
+	m := &Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := AddMachine(db, m)
+	require.NoError(t, err)
+
+	app := &App{
+		MachineID: m.ID,
+		Type:      AppTypeBind9,
+		Daemons: []*Daemon{
+			NewBind9Daemon(true),
+		},
+	}
+	_, err = AddApp(db, app)
+	require.NoError(t, err)
+	return app.Daemons[0]
+}
+
+// Test that the primary and secondary zones are recognized, including
+// the legacy type names.
+func TestZoneType(t *testing.T) {
+	require.True(t, (&Zone{Type: "primary"}).IsPrimary())
+	require.True(t, (&Zone{Type: "master"}).IsPrimary())
+	require.False(t, (&Zone{Type: "secondary"}).IsPrimary())
+	require.True(t, (&Zone{Type: "secondary"}).IsSecondary())
+	require.True(t, (&Zone{Type: "slave"}).IsSecondary())
+	require.False(t, (&Zone{Type: "builtin"}).IsSecondary())
+}
+
+// Test that the serials are compared according to the serial number
+// arithmetic.
+func TestIsSerialBehind(t *testing.T) {
+	require.True(t, IsSerialBehind(1, 2))
+	require.False(t, IsSerialBehind(2, 1))
+	require.False(t, IsSerialBehind(2, 2))
+	// The serial wraps around.
+	require.True(t, IsSerialBehind(4294967295, 1))
+	require.False(t, IsSerialBehind(1, 4294967295))
+}
+
+// Test that the zones of the daemon are inserted, updated and deleted.
+func TestCommitZones(t *testing.T) {
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	daemon := addTestBind9Daemon(t, db)
+
+	serial := int64(2023051001)
+	now := storkutil.UTCNow().Truncate(time.Second)
+	zones := []*Zone{
+		{
+			View:      "_default",
+			Name:      "example.com",
+			Class:     "IN",
+			Type:      ZoneTypePrimary,
+			Serial:    &serial,
+			LoadedAt:  now,
+			UpdatedAt: now,
+		},
+		{
+			View:          "_default",
+			Name:          "example.org",
+			Class:         "IN",
+			Type:          ZoneTypeSecondary,
+			NextRefreshAt: now.Add(-time.Minute),
+			UpdatedAt:     now,
+		},
+	}
+	err := CommitZones(db, daemon.ID, zones)
+	require.NoError(t, err)
+	require.NotZero(t, zones[0].ID)
+
+	returned, err := GetZonesByDaemonID(db, daemon.ID)
+	require.NoError(t, err)
+	require.Len(t, returned, 2)
+	require.Equal(t, "example.com", returned[0].Name)
+	require.EqualValues(t, serial, *returned[0].Serial)
+	require.Equal(t, now, returned[0].LoadedAt)
+	require.Nil(t, returned[1].Serial)
+	require.Zero(t, returned[1].LoadedAt)
+	require.Zero(t, returned[1].RefreshedAt)
+
+	// Set the issue flags. They should be preserved when the zones are
+	// updated.
+	returned[1].TransferFailed = true
+	returned[1].SerialLaggingSince = now.Add(-time.Hour)
+	err = UpdateZoneIssues(db, &returned[1])
+	require.NoError(t, err)
+
+	newSerial := int64(2023051002)
+	err = CommitZones(db, daemon.ID, []*Zone{
+		{
+			View:          "_default",
+			Name:          "example.org",
+			Class:         "IN",
+			Type:          ZoneTypeSecondary,
+			Serial:        &newSerial,
+			NextRefreshAt: now.Add(time.Hour),
+			UpdatedAt:     now,
+		},
+	})
+	require.NoError(t, err)
+
+	all, err := GetAllZones(db)
+	require.NoError(t, err)
+	require.Len(t, all, 1)
+	require.Equal(t, "example.org", all[0].Name)
+	require.EqualValues(t, newSerial, *all[0].Serial)
+	require.True(t, all[0].TransferFailed)
+	require.Equal(t, now.Add(-time.Hour), all[0].SerialLaggingSince)
+	// The refresh has been rescheduled, so the zone was refreshed at the
+	// previous next refresh time.
+	require.Equal(t, now.Add(-time.Minute), all[0].RefreshedAt)
+	require.Equal(t, now.Add(time.Hour), all[0].NextRefreshAt)
+	require.NotNil(t, all[0].Daemon)
+	require.NotNil(t, all[0].Daemon.App)
+
+	// The configurations are fetched separately for each daemon.
+	bind9Daemons, err := GetAllBind9Daemons(db)
+	require.NoError(t, err)
+	require.Len(t, bind9Daemons, 1)
+	require.Equal(t, daemon.ID, bind9Daemons[0].DaemonID)
+
+	// The zones no longer served by the daemon are deleted.
+	err = CommitZones(db, daemon.ID, nil)
+	require.NoError(t, err)
+	all, err = GetAllZones(db)
+	require.NoError(t, err)
+	require.Empty(t, all)
+}
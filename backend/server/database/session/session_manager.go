@@ -106,6 +106,23 @@ func (s *SessionMgr) SessionMiddleware(handler http.Handler) http.Handler {
 	return s.scsSessionMgr.LoadAndSave(handler)
 }
 
+// Loads the session data for the token taken from the session cookie of the
+// request and returns the request with the session data stored in its
+// context. Contrary to the SessionMiddleware, it doesn't buffer the response
+// and doesn't save the session, so it is suitable for streaming the responses
+// to the handlers that don't modify the session.
+func (s *SessionMgr) LoadFromRequest(req *http.Request) (*http.Request, error) {
+	var token string
+	if cookie, err := req.Cookie(s.scsSessionMgr.Cookie.Name); err == nil {
+		token = cookie.Value
+	}
+	ctx, err := s.scsSessionMgr.Load(req.Context(), token)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error while loading the user session")
+	}
+	return req.WithContext(ctx), nil
+}
+
 // Checks if the given session token exists in the database. This is typically used
 // in unit testing to validate that the session data is persisted in the database.
 func (s *SessionMgr) HasToken(token string) bool {
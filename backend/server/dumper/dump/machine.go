@@ -20,7 +20,8 @@ func NewMachineDump(m *dbmodel.Machine) *MachineDump {
 	}
 }
 
-// Dumps the machine instance provided in the constructor.
+// Dumps the machine instance provided in the constructor and
+// the inventory of its daemons reported by the agent.
 // It removes the sensitive data from the dumped data.
 // The removed data:
 //
@@ -43,5 +44,14 @@ func (d *MachineDump) Execute() error {
 		d.machine,
 	))
 
+	// The inventory of the daemons is also included in the machine state,
+	// but it is dumped separately for easier troubleshooting.
+	if len(d.machine.State.Inventory) > 0 {
+		d.AppendArtifact(NewBasicStructArtifact(
+			fmt.Sprintf("%d-%s-inventory", d.machine.ID, d.machine.Address),
+			d.machine.State.Inventory,
+		))
+	}
+
 	return nil
 }
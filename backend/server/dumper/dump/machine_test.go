@@ -158,3 +158,44 @@ func TestMachineDumpExecuteHideSecrets(t *testing.T) {
 		}
 	}
 }
+
+// Test that the inventory of the daemons is dumped as a separate artifact.
+func TestMachineDumpExecuteInventory(t *testing.T) {
+	// Arrange
+	db, _, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	m := initDatabase(db)
+	m.State.Inventory = []dbmodel.DaemonInventory{
+		{
+			Name:             "kea-dhcp4",
+			Pid:              1234,
+			Executable:       "/usr/sbin/kea-dhcp4",
+			Package:          "isc-kea-dhcp4-server",
+			SystemdUnit:      "isc-kea-dhcp4-server.service",
+			SystemdUnitState: "enabled",
+			ListeningSockets: []string{"udp 0.0.0.0:67"},
+			Files: []dbmodel.DaemonFileInventory{
+				{
+					Path:  "/etc/kea/kea-dhcp4.conf",
+					Mode:  "-rw-r-----",
+					Owner: "root",
+					Group: "_kea",
+				},
+			},
+		},
+	}
+	dump := dumppkg.NewMachineDump(m)
+
+	// Act
+	err := dump.Execute()
+
+	// Assert
+	require.NoError(t, err)
+	require.EqualValues(t, 2, dump.GetArtifactsNumber())
+	artifact := dump.GetArtifact(1).(dumppkg.StructArtifact)
+	require.EqualValues(t, "42-localhost-inventory", artifact.GetName())
+	inventory, ok := artifact.GetStruct().([]dbmodel.DaemonInventory)
+	require.True(t, ok)
+	require.Len(t, inventory, 1)
+	require.EqualValues(t, "isc-kea-dhcp4-server", inventory[0].Package)
+}
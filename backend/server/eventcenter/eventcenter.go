@@ -90,6 +90,9 @@ func CreateEvent(level dbmodel.EventLevel, text string, objects ...interface{})
 		} else if s, ok := obj.(*dbmodel.Subnet); ok {
 			text = strings.ReplaceAll(text, "{subnet}", subnetTag(s))
 			relations.SubnetID = s.ID
+		} else if n, ok := obj.(*dbmodel.SharedNetwork); ok {
+			text = strings.ReplaceAll(text, "{sharedNetwork}", sharedNetworkTag(n))
+			relations.SharedNetworkID = n.ID
 		} else if u, ok := obj.(*dbmodel.SystemUser); ok {
 			text = strings.ReplaceAll(text, "{user}", userTag(u))
 			relations.UserID = int64(u.ID)
@@ -184,6 +187,13 @@ func subnetTag(subnet *dbmodel.Subnet) string {
 	return tag
 }
 
+// Prepare a tag describing a shared network.
+func sharedNetworkTag(network *dbmodel.SharedNetwork) string {
+	tag := fmt.Sprintf("<shared-network id=\"%d\" name=\"%s\">",
+		network.ID, network.Name)
+	return tag
+}
+
 // Prepare a tag describing a user.
 func userTag(user *dbmodel.SystemUser) string {
 	tag := fmt.Sprintf("<user id=\"%d\" login=\"%s\" email=\"%s\">",
@@ -29,6 +29,8 @@ type metrics struct {
 	SubnetPdUtilization             *prometheus.GaugeVec
 	SharedNetworkAddressUtilization *prometheus.GaugeVec
 	SharedNetworkPdUtilization      *prometheus.GaugeVec
+	PoolAddressUtilization          *prometheus.GaugeVec
+	PoolPdUtilization               *prometheus.GaugeVec
 }
 
 // Constructor of the metrics. They are automatically
@@ -85,6 +87,18 @@ func newMetrics(db *pg.DB) *metrics {
 			Subsystem: "shared_network",
 			Help:      "Shared-network delegated-prefix utilization",
 		}, []string{"name"}),
+		PoolAddressUtilization: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "address_utilization",
+			Subsystem: "pool",
+			Help:      "Address pool utilization",
+		}, []string{"subnet", "pool"}),
+		PoolPdUtilization: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pd_utilization",
+			Subsystem: "pool",
+			Help:      "Delegated-prefix pool utilization",
+		}, []string{"subnet", "pool"}),
 	}
 
 	return &metrics
@@ -119,6 +133,18 @@ func (m *metrics) Update() error {
 			Set(float64(networkMetrics.PdUtilization) / 1000.)
 	}
 
+	for _, poolMetrics := range calculatedMetrics.AddressPoolMetrics {
+		m.PoolAddressUtilization.
+			With(prometheus.Labels{"subnet": poolMetrics.Subnet, "pool": poolMetrics.Pool}).
+			Set(float64(poolMetrics.Utilization) / 1000.)
+	}
+
+	for _, poolMetrics := range calculatedMetrics.PrefixPoolMetrics {
+		m.PoolPdUtilization.
+			With(prometheus.Labels{"subnet": poolMetrics.Subnet, "pool": poolMetrics.Pool}).
+			Set(float64(poolMetrics.Utilization) / 1000.)
+	}
+
 	return nil
 }
 
@@ -16,7 +16,8 @@ import (
 	storkutil "isc.org/stork/util"
 )
 
-// Get daemon config. Only Kea daemon supported.
+// Get daemon config. Kea and BIND 9 daemons are supported. The secrets
+// are hidden from the users who are not super admins.
 func (r *RestAPI) GetDaemonConfig(ctx context.Context, params services.GetDaemonConfigParams) middleware.Responder {
 	dbDaemon, err := dbmodel.GetDaemonByID(r.DB, params.ID)
 	if err != nil {
@@ -35,28 +36,33 @@ func (r *RestAPI) GetDaemonConfig(ctx context.Context, params services.GetDaemon
 		return rsp
 	}
 
-	if dbDaemon.KeaDaemon == nil {
-		msg := fmt.Sprintf("Daemon with ID %d is not a Kea daemon", params.ID)
+	_, dbUser := r.SessionManager.Logged(ctx)
+	hideSensitiveData := !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID})
+
+	switch {
+	case dbDaemon.KeaDaemon != nil && dbDaemon.KeaDaemon.Config != nil:
+		if hideSensitiveData {
+			dbDaemon.KeaDaemon.Config.HideSensitiveData()
+		}
+		return services.NewGetDaemonConfigOK().WithPayload(dbDaemon.KeaDaemon.Config)
+	case dbDaemon.Bind9Daemon != nil && dbDaemon.Bind9Daemon.Config != nil:
+		if hideSensitiveData {
+			dbDaemon.Bind9Daemon.Config.HideSensitiveData()
+		}
+		return services.NewGetDaemonConfigOK().WithPayload(dbDaemon.Bind9Daemon.Config)
+	case dbDaemon.KeaDaemon == nil && dbDaemon.Bind9Daemon == nil:
+		msg := fmt.Sprintf("Daemon with ID %d is neither a Kea nor a BIND 9 daemon", params.ID)
 		rsp := services.NewGetDaemonConfigDefault(http.StatusBadRequest).WithPayload(&models.APIError{
 			Message: &msg,
 		})
 		return rsp
-	}
-	if dbDaemon.KeaDaemon.Config == nil {
+	default:
 		msg := fmt.Sprintf("Config not assigned for daemon with ID %d", params.ID)
 		rsp := services.NewGetDaemonConfigDefault(http.StatusNotFound).WithPayload(&models.APIError{
 			Message: &msg,
 		})
 		return rsp
 	}
-
-	_, dbUser := r.SessionManager.Logged(ctx)
-	if !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
-		dbDaemon.KeaDaemon.Config.HideSensitiveData()
-	}
-
-	rsp := services.NewGetDaemonConfigOK().WithPayload(dbDaemon.KeaDaemon.Config)
-	return rsp
 }
 
 // Get configuration review reports for a specified daemon. Only Kea
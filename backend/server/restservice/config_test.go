@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/require"
+	bind9config "isc.org/stork/appcfg/bind9"
 	agentcommtest "isc.org/stork/server/agentcomm/test"
 	"isc.org/stork/server/configreview"
 	dbmodel "isc.org/stork/server/database/model"
@@ -354,7 +355,8 @@ func TestGetDaemonConfigForKeaDaemonWithoutAssignedConfiguration(t *testing.T) {
 	require.Equal(t, msg, *defaultRsp.Payload.Message)
 }
 
-// Test that GetDaemonConfig returns HTTP Bad Request status for not-Kea daemon.
+// Test that GetDaemonConfig returns the BIND 9 configuration and HTTP Not
+// Found status if the configuration is not assigned.
 func TestGetDaemonConfigForBind9Daemon(t *testing.T) {
 	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
 	defer teardown()
@@ -381,6 +383,8 @@ func TestGetDaemonConfigForBind9Daemon(t *testing.T) {
 	require.NoError(t, err)
 
 	// add BIND 9 app
+	config, err := bind9config.Parse(`key "rndc-key" { algorithm "hmac-sha256"; secret "abcd"; };`)
+	require.NoError(t, err)
 	var bind9Points []*dbmodel.AccessPoint
 	bind9Points = dbmodel.AppendAccessPoint(bind9Points, dbmodel.AccessPointControl, "1.2.3.4", "abcd", 124, true)
 	app := &dbmodel.App{
@@ -390,7 +394,9 @@ func TestGetDaemonConfigForBind9Daemon(t *testing.T) {
 		AccessPoints: bind9Points,
 		Daemons: []*dbmodel.Daemon{
 			{
-				Bind9Daemon: &dbmodel.Bind9Daemon{},
+				Bind9Daemon: &dbmodel.Bind9Daemon{
+					Config: config,
+				},
 			},
 		},
 	}
@@ -403,10 +409,23 @@ func TestGetDaemonConfigForBind9Daemon(t *testing.T) {
 	}
 
 	rsp := rapi.GetDaemonConfig(ctx, params)
+	require.IsType(t, &services.GetDaemonConfigOK{}, rsp)
+	okRsp := rsp.(*services.GetDaemonConfigOK)
+	returnedConfig, ok := okRsp.Payload.(*bind9config.Config)
+	require.True(t, ok)
+	// The secret is returned to the super admin.
+	require.NotNil(t, returnedConfig.GetKey("rndc-key"))
+
+	// Remove the configuration.
+	app.Daemons[0].Bind9Daemon.Config = nil
+	err = dbmodel.UpdateDaemon(db, app.Daemons[0])
+	require.NoError(t, err)
+
+	rsp = rapi.GetDaemonConfig(ctx, params)
 	require.IsType(t, &services.GetDaemonConfigDefault{}, rsp)
 	defaultRsp := rsp.(*services.GetDaemonConfigDefault)
-	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
-	msg := fmt.Sprintf("Daemon with ID %d is not a Kea daemon", params.ID)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+	msg := fmt.Sprintf("Config not assigned for daemon with ID %d", params.ID)
 	require.Equal(t, msg, *defaultRsp.Payload.Message)
 }
 
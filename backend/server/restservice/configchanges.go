@@ -0,0 +1,108 @@
+package restservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+)
+
+// Converts the scheduled config change fetched from the database to the
+// format used in REST API.
+func newRestConfigChange(dbChange *dbmodel.ScheduledConfigChange) *models.ConfigChange {
+	change := &models.ConfigChange{
+		ID:         dbChange.ID,
+		CreatedAt:  strfmt.DateTime(dbChange.CreatedAt),
+		DeadlineAt: strfmt.DateTime(dbChange.DeadlineAt),
+		UserID:     dbChange.UserID,
+		Executed:   dbChange.Executed,
+		Error:      dbChange.Error,
+	}
+	if dbChange.User != nil {
+		change.UserLogin = dbChange.User.Login
+	}
+	for _, update := range dbChange.Updates {
+		change.Updates = append(change.Updates, &models.ConfigUpdate{
+			Target:    string(update.Target),
+			Operation: update.Operation,
+			DaemonIds: update.DaemonIDs,
+		})
+	}
+	return change
+}
+
+// Get the list of the config changes scheduled by the users.
+func (r *RestAPI) GetConfigChanges(ctx context.Context, params dhcp.GetConfigChangesParams) middleware.Responder {
+	dbChanges, err := dbmodel.GetScheduledConfigChanges(r.DB)
+	if err != nil {
+		msg := "Cannot get scheduled config changes from the database"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewGetConfigChangesDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	changes := &models.ConfigChanges{
+		Items: []*models.ConfigChange{},
+		Total: int64(len(dbChanges)),
+	}
+	for i := range dbChanges {
+		changes.Items = append(changes.Items, newRestConfigChange(&dbChanges[i]))
+	}
+	rsp := dhcp.NewGetConfigChangesOK().WithPayload(changes)
+	return rsp
+}
+
+// Get the scheduled config change by ID.
+func (r *RestAPI) GetConfigChange(ctx context.Context, params dhcp.GetConfigChangeParams) middleware.Responder {
+	dbChange, err := dbmodel.GetScheduledConfigChange(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Cannot get scheduled config change with ID %d from the database", params.ID)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewGetConfigChangeDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbChange == nil {
+		msg := fmt.Sprintf("Cannot find scheduled config change with ID %d", params.ID)
+		rsp := dhcp.NewGetConfigChangeDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewGetConfigChangeOK().WithPayload(newRestConfigChange(dbChange))
+	return rsp
+}
+
+// Delete the scheduled config change by ID. The change is never sent to
+// the servers if it hasn't been executed yet.
+func (r *RestAPI) DeleteConfigChange(ctx context.Context, params dhcp.DeleteConfigChangeParams) middleware.Responder {
+	err := dbmodel.DeleteScheduledConfigChange(r.DB, params.ID)
+	if err != nil {
+		status := http.StatusInternalServerError
+		msg := fmt.Sprintf("Cannot delete scheduled config change with ID %d", params.ID)
+		if errors.Is(err, dbmodel.ErrNotExists) {
+			status = http.StatusNotFound
+			msg = fmt.Sprintf("Cannot find scheduled config change with ID %d", params.ID)
+		}
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewDeleteConfigChangeDefault(status).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	_, user := r.SessionManager.Logged(ctx)
+	r.EventCenter.AddInfoEvent(fmt.Sprintf("{user} deleted scheduled config change %d", params.ID), user)
+
+	rsp := dhcp.NewDeleteConfigChangeOK()
+	return rsp
+}
@@ -0,0 +1,150 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storktest "isc.org/stork/server/test/dbmodel"
+	storkutil "isc.org/stork/util"
+)
+
+// Adds a user and two config changes scheduled by this user. The first
+// change has been executed with an error.
+func addTestConfigChanges(t *testing.T, db *dbops.PgDB) (*dbmodel.SystemUser, []dbmodel.ScheduledConfigChange) {
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err := dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	changes := []dbmodel.ScheduledConfigChange{
+		{
+			DeadlineAt: storkutil.UTCNow().Add(-time.Second * 10),
+			UserID:     int64(user.ID),
+			Updates: []*dbmodel.ConfigUpdate{
+				dbmodel.NewConfigUpdate(dbmodel.AppTypeKea, "host_add", 1, 2),
+			},
+		},
+		{
+			DeadlineAt: storkutil.UTCNow().Add(time.Hour),
+			UserID:     int64(user.ID),
+			Updates: []*dbmodel.ConfigUpdate{
+				dbmodel.NewConfigUpdate(dbmodel.AppTypeKea, "subnet_update", 3),
+			},
+		},
+	}
+	for i := range changes {
+		err = dbmodel.AddScheduledConfigChange(db, &changes[i])
+		require.NoError(t, err)
+	}
+	err = dbmodel.SetScheduledConfigChangeExecuted(db, changes[0].ID, "failed to add host")
+	require.NoError(t, err)
+
+	return user, changes
+}
+
+// Test that the scheduled config changes are returned.
+func TestGetConfigChanges(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user, changes := addTestConfigChanges(t, db)
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	rsp := rapi.GetConfigChanges(ctx, dhcp.GetConfigChangesParams{})
+	require.IsType(t, &dhcp.GetConfigChangesOK{}, rsp)
+	okRsp := rsp.(*dhcp.GetConfigChangesOK)
+	require.EqualValues(t, 2, okRsp.Payload.Total)
+	require.Len(t, okRsp.Payload.Items, 2)
+
+	item := okRsp.Payload.Items[0]
+	require.Equal(t, changes[0].ID, item.ID)
+	require.EqualValues(t, user.ID, item.UserID)
+	require.Equal(t, "test", item.UserLogin)
+	require.True(t, item.Executed)
+	require.Equal(t, "failed to add host", item.Error)
+	require.Len(t, item.Updates, 1)
+	require.Equal(t, "kea", item.Updates[0].Target)
+	require.Equal(t, "host_add", item.Updates[0].Operation)
+	require.ElementsMatch(t, []int64{1, 2}, item.Updates[0].DaemonIds)
+
+	item = okRsp.Payload.Items[1]
+	require.Equal(t, changes[1].ID, item.ID)
+	require.False(t, item.Executed)
+	require.Empty(t, item.Error)
+}
+
+// Test that the scheduled config change is returned by ID.
+func TestGetConfigChange(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	_, changes := addTestConfigChanges(t, db)
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	rsp := rapi.GetConfigChange(ctx, dhcp.GetConfigChangeParams{ID: changes[1].ID})
+	require.IsType(t, &dhcp.GetConfigChangeOK{}, rsp)
+	okRsp := rsp.(*dhcp.GetConfigChangeOK)
+	require.Equal(t, changes[1].ID, okRsp.Payload.ID)
+	require.Equal(t, "test", okRsp.Payload.UserLogin)
+	require.Len(t, okRsp.Payload.Updates, 1)
+	require.Equal(t, "subnet_update", okRsp.Payload.Updates[0].Operation)
+
+	// Non-existing change.
+	rsp = rapi.GetConfigChange(ctx, dhcp.GetConfigChangeParams{ID: changes[1].ID + 1})
+	require.IsType(t, &dhcp.GetConfigChangeDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.GetConfigChangeDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+}
+
+// Test that the scheduled config change is deleted and the event is raised.
+func TestDeleteConfigChange(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	user, changes := addTestConfigChanges(t, db)
+
+	fec := &storktest.FakeEventCenter{}
+	rapi, err := NewRestAPI(dbSettings, db, fec)
+	require.NoError(t, err)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	rsp := rapi.DeleteConfigChange(ctx, dhcp.DeleteConfigChangeParams{ID: changes[1].ID})
+	require.IsType(t, &dhcp.DeleteConfigChangeOK{}, rsp)
+
+	returned, err := dbmodel.GetScheduledConfigChanges(db)
+	require.NoError(t, err)
+	require.Len(t, returned, 1)
+	require.Equal(t, changes[0].ID, returned[0].ID)
+
+	require.Len(t, fec.Events, 1)
+	require.Contains(t, fec.Events[0].Text, "deleted scheduled config change")
+	require.EqualValues(t, user.ID, fec.Events[0].Relations.UserID)
+
+	// The change no longer exists.
+	rsp = rapi.DeleteConfigChange(ctx, dhcp.DeleteConfigChangeParams{ID: changes[1].ID})
+	require.IsType(t, &dhcp.DeleteConfigChangeDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.DeleteConfigChangeDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+	require.Len(t, fec.Events, 1)
+}
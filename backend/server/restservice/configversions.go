@@ -0,0 +1,227 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"isc.org/stork/server/config"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Converts the version of the Kea daemon's configuration to the format
+// used in REST API. The configuration is included if it has been fetched
+// from the database.
+func (r *RestAPI) convertKeaDaemonConfigVersionToRestAPI(dbVersion *dbmodel.KeaDaemonConfigVersion) *models.KeaDaemonConfigVersion {
+	version := &models.KeaDaemonConfigVersion{
+		ID:         dbVersion.ID,
+		DaemonID:   dbVersion.DaemonID,
+		ConfigHash: dbVersion.ConfigHash,
+		CreatedAt:  strfmt.DateTime(dbVersion.CreatedAt),
+		Operation:  dbVersion.Operation,
+	}
+	if dbVersion.UserID != 0 {
+		userID := dbVersion.UserID
+		version.UserID = &userID
+	}
+	if dbVersion.User != nil {
+		login := dbVersion.User.Login
+		version.UserLogin = &login
+	}
+	if dbVersion.Config != nil {
+		version.Config = dbVersion.Config
+	}
+	return version
+}
+
+// Get the versions of the Kea daemon's configuration from the newest to
+// the oldest. The configurations are not returned.
+func (r *RestAPI) GetDaemonConfigVersions(ctx context.Context, params services.GetDaemonConfigVersionsParams) middleware.Responder {
+	dbDaemon, err := dbmodel.GetDaemonByID(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get daemon with ID %d from db", params.ID)
+		rsp := services.NewGetDaemonConfigVersionsDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbDaemon == nil || dbDaemon.KeaDaemon == nil {
+		msg := fmt.Sprintf("Cannot find Kea daemon with ID %d", params.ID)
+		rsp := services.NewGetDaemonConfigVersionsDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	dbVersions, err := dbmodel.GetKeaDaemonConfigVersions(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get configuration versions for daemon with ID %d from db", params.ID)
+		rsp := services.NewGetDaemonConfigVersionsDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	versions := &models.KeaDaemonConfigVersions{
+		Items: []*models.KeaDaemonConfigVersion{},
+		Total: int64(len(dbVersions)),
+	}
+	for i := range dbVersions {
+		versions.Items = append(versions.Items, r.convertKeaDaemonConfigVersionToRestAPI(&dbVersions[i]))
+	}
+	rsp := services.NewGetDaemonConfigVersionsOK().WithPayload(versions)
+	return rsp
+}
+
+// Get the version of the Kea daemon's configuration including the
+// configuration. The secrets are hidden from the users who are not
+// super admins.
+func (r *RestAPI) GetDaemonConfigVersion(ctx context.Context, params services.GetDaemonConfigVersionParams) middleware.Responder {
+	dbVersion, err := dbmodel.GetKeaDaemonConfigVersion(r.DB, params.VersionID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get configuration version with ID %d from db", params.VersionID)
+		rsp := services.NewGetDaemonConfigVersionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbVersion == nil || dbVersion.DaemonID != params.ID {
+		msg := fmt.Sprintf("Cannot find configuration version with ID %d for daemon with ID %d", params.VersionID, params.ID)
+		rsp := services.NewGetDaemonConfigVersionDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	_, dbUser := r.SessionManager.Logged(ctx)
+	if dbVersion.Config != nil && !dbUser.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		dbVersion.Config.HideSensitiveData()
+	}
+
+	rsp := services.NewGetDaemonConfigVersionOK().WithPayload(r.convertKeaDaemonConfigVersionToRestAPI(dbVersion))
+	return rsp
+}
+
+// Restores the version of the Kea daemon's configuration. The daemon is
+// locked for updates by the config manager while the configuration is
+// tested with the config-test command, set with the config-set command
+// and written to the configuration file with the config-write command.
+// If the daemon rejects the configuration, the error text returned by
+// the daemon is reported to the user.
+func (r *RestAPI) RestoreDaemonConfigVersion(ctx context.Context, params services.RestoreDaemonConfigVersionParams) middleware.Responder {
+	dbVersion, err := dbmodel.GetKeaDaemonConfigVersion(r.DB, params.VersionID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get configuration version with ID %d from db", params.VersionID)
+		rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbVersion == nil || dbVersion.DaemonID != params.ID {
+		msg := fmt.Sprintf("Cannot find configuration version with ID %d for daemon with ID %d", params.VersionID, params.ID)
+		rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Create configuration context.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, err := r.ConfigManager.CreateContext(int64(user.ID))
+	if err != nil {
+		msg := "Problem with creating transaction context"
+		log.WithError(err).Error(msg)
+		rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Begin the rollback transaction. It locks the daemon for updates.
+	cctx, err = r.ConfigManager.GetKeaModule().BeginConfigRollback(cctx, params.VersionID)
+	defer r.ConfigManager.Done(cctx)
+	if err != nil {
+		var (
+			versionNotFound *config.ConfigVersionNotFoundError
+			configBackend   *config.ConfigBackendError
+			lock            *config.LockError
+		)
+		switch {
+		case errors.As(err, &versionNotFound):
+			// Failed to find the version.
+			msg := fmt.Sprintf("Cannot find configuration version with ID %d", params.VersionID)
+			log.Error(msg)
+			rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusNotFound).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		case errors.As(err, &configBackend):
+			// The configuration is partially stored in the config backend.
+			msg := fmt.Sprintf("Unable to restore the configuration of the daemon with ID %d because it uses the configuration backend", params.ID)
+			log.Error(msg)
+			rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		case errors.As(err, &lock):
+			// Failed to lock the daemon.
+			msg := fmt.Sprintf("Unable to restore the configuration of the daemon with ID %d because it may be currently edited by another user", params.ID)
+			log.WithError(err).Error(msg)
+			rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusLocked).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		default:
+			// Other error.
+			msg := fmt.Sprintf("Problem with initializing transaction for restoring the configuration of the daemon with ID %d", params.ID)
+			log.WithError(err).Error(msg)
+			rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+	}
+	// Test the configuration and create Kea commands to restore it.
+	cctx, err = r.ConfigManager.GetKeaModule().ApplyConfigRollback(cctx, dbVersion)
+	if err != nil {
+		var configTest *config.ConfigTestError
+		var (
+			status int
+			msg    string
+		)
+		switch {
+		case errors.As(err, &configTest):
+			status = http.StatusBadRequest
+			msg = fmt.Sprintf("Restored configuration rejected by %s: %s", configTest.AppName, configTest.Text)
+		default:
+			status = http.StatusInternalServerError
+			msg = fmt.Sprintf("Problem with preparing commands for restoring the configuration: %s", err)
+		}
+		log.WithError(err).Error(msg)
+		rsp := services.NewRestoreDaemonConfigVersionDefault(status).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Send the commands to the Kea server.
+	_, err = r.ConfigManager.Commit(cctx)
+	if err != nil {
+		msg := fmt.Sprintf("Problem with restoring the configuration: %s", err)
+		log.WithError(err).Error(msg)
+		rsp := services.NewRestoreDaemonConfigVersionDefault(http.StatusConflict).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Send OK to the client.
+	rsp := services.NewRestoreDaemonConfigVersionOK()
+	return rsp
+}
@@ -0,0 +1,227 @@
+package restservice
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	"isc.org/stork/server/gen/restapi/operations/services"
+)
+
+// Test getting the configuration versions created by the configuration
+// pull and by the global parameters update, and restoring a selected
+// version.
+func TestGetAndRestoreDaemonConfigVersions(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": {
+			"valid-lifetime": 1000,
+			"lease-database": {
+				"type": "memfile",
+				"password": "secret"
+			}
+		}
+	}`, 0, "Configuration seems sane.")
+	daemonID := app.Daemons[0].ID
+
+	// The configuration committed into the database is the first version.
+	rsp := rapi.GetDaemonConfigVersions(ctx, services.GetDaemonConfigVersionsParams{
+		ID: daemonID,
+	})
+	require.IsType(t, &services.GetDaemonConfigVersionsOK{}, rsp)
+	versions := rsp.(*services.GetDaemonConfigVersionsOK).Payload
+	require.EqualValues(t, 1, versions.Total)
+	require.Len(t, versions.Items, 1)
+	require.Nil(t, versions.Items[0].UserID)
+	require.Nil(t, versions.Items[0].Config)
+	initialVersionID := versions.Items[0].ID
+
+	// Update the global parameters.
+	rsp = rapi.UpdateGlobalParametersBegin(ctx, dhcp.UpdateGlobalParametersBeginParams{
+		DaemonID: daemonID,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersBeginOK{}, rsp)
+	rsp = rapi.UpdateGlobalParametersSubmit(ctx, dhcp.UpdateGlobalParametersSubmitParams{
+		DaemonID: daemonID,
+		ID:       rsp.(*dhcp.UpdateGlobalParametersBeginOK).Payload.ID,
+		Parameters: map[string]any{
+			"valid-lifetime": 2000,
+		},
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersSubmitOK{}, rsp)
+
+	// The update should be recorded as a new version.
+	rsp = rapi.GetDaemonConfigVersions(ctx, services.GetDaemonConfigVersionsParams{
+		ID: daemonID,
+	})
+	require.IsType(t, &services.GetDaemonConfigVersionsOK{}, rsp)
+	versions = rsp.(*services.GetDaemonConfigVersionsOK).Payload
+	require.EqualValues(t, 2, versions.Total)
+	require.Len(t, versions.Items, 2)
+	require.NotNil(t, versions.Items[0].UserID)
+	require.NotNil(t, versions.Items[0].UserLogin)
+	require.Equal(t, "test", *versions.Items[0].UserLogin)
+	require.Equal(t, "global_parameters_update", versions.Items[0].Operation)
+	require.Equal(t, initialVersionID, versions.Items[1].ID)
+
+	// Get the initial version with the configuration.
+	rsp = rapi.GetDaemonConfigVersion(ctx, services.GetDaemonConfigVersionParams{
+		ID:        daemonID,
+		VersionID: initialVersionID,
+	})
+	require.IsType(t, &services.GetDaemonConfigVersionOK{}, rsp)
+	version := rsp.(*services.GetDaemonConfigVersionOK).Payload
+	require.IsType(t, &dbmodel.KeaConfig{}, version.Config)
+	cfg := version.Config.(*dbmodel.KeaConfig)
+	require.EqualValues(t, 1000, *cfg.GetValidLifetimeParameters().ValidLifetime)
+
+	// The password should be hidden from the user who is not a super admin.
+	leaseDatabase := cfg.Raw["Dhcp4"].(map[string]any)["lease-database"].(map[string]any)
+	require.Contains(t, leaseDatabase, "password")
+	require.Nil(t, leaseDatabase["password"])
+
+	// Restore the initial version.
+	rsp = rapi.RestoreDaemonConfigVersion(ctx, services.RestoreDaemonConfigVersionParams{
+		ID:        daemonID,
+		VersionID: initialVersionID,
+	})
+	require.IsType(t, &services.RestoreDaemonConfigVersionOK{}, rsp)
+
+	// It should result in sending commands to the Kea server.
+	require.Len(t, fa.RecordedCommands, 9)
+	require.Equal(t, "config-test", fa.RecordedCommands[5].GetCommand())
+	require.Equal(t, "config-set", fa.RecordedCommands[6].GetCommand())
+	require.Equal(t, "config-write", fa.RecordedCommands[7].GetCommand())
+	require.Equal(t, "config-get", fa.RecordedCommands[8].GetCommand())
+
+	// The password must be sent to the server.
+	require.Contains(t, fa.RecordedCommands[6].Marshal(), "secret")
+
+	// The restored configuration should be stored in the database.
+	daemon, err := dbmodel.GetDaemonByID(db, daemonID)
+	require.NoError(t, err)
+	require.NotNil(t, daemon)
+	require.EqualValues(t, 1000, *daemon.KeaDaemon.Config.GetValidLifetimeParameters().ValidLifetime)
+
+	// The rollback should be recorded as a new version.
+	rsp = rapi.GetDaemonConfigVersions(ctx, services.GetDaemonConfigVersionsParams{
+		ID: daemonID,
+	})
+	require.IsType(t, &services.GetDaemonConfigVersionsOK{}, rsp)
+	versions = rsp.(*services.GetDaemonConfigVersionsOK).Payload
+	require.Len(t, versions.Items, 3)
+	require.Equal(t, "config_rollback", versions.Items[0].Operation)
+}
+
+// Test that the configuration rejected by the Kea server is not restored
+// and the rejection is reported to the user.
+func TestRestoreDaemonConfigVersionConfigTestError(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": {
+			"valid-lifetime": 1000
+		}
+	}`, 1, "hooks library not found")
+	daemonID := app.Daemons[0].ID
+
+	versions, err := dbmodel.GetKeaDaemonConfigVersions(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	rsp := rapi.RestoreDaemonConfigVersion(ctx, services.RestoreDaemonConfigVersionParams{
+		ID:        daemonID,
+		VersionID: versions[0].ID,
+	})
+	require.IsType(t, &services.RestoreDaemonConfigVersionDefault{}, rsp)
+	defaultRsp := rsp.(*services.RestoreDaemonConfigVersionDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+	require.Contains(t, *defaultRsp.Payload.Message, "hooks library not found")
+
+	// The configuration must not be set.
+	require.Len(t, fa.RecordedCommands, 1)
+	require.Equal(t, "config-test", fa.RecordedCommands[0].GetCommand())
+}
+
+// Test that the configuration of the daemon using the config backend
+// can't be restored.
+func TestRestoreDaemonConfigVersionConfigBackend(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": {
+			"config-control": {
+				"config-databases": [
+					{
+						"type": "mysql",
+						"name": "kea"
+					}
+				]
+			}
+		}
+	}`, 0, "Configuration seems sane.")
+	daemonID := app.Daemons[0].ID
+
+	versions, err := dbmodel.GetKeaDaemonConfigVersions(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	rsp := rapi.RestoreDaemonConfigVersion(ctx, services.RestoreDaemonConfigVersionParams{
+		ID:        daemonID,
+		VersionID: versions[0].ID,
+	})
+	require.IsType(t, &services.RestoreDaemonConfigVersionDefault{}, rsp)
+	defaultRsp := rsp.(*services.RestoreDaemonConfigVersionDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+	require.Contains(t, *defaultRsp.Payload.Message, "configuration backend")
+	require.Empty(t, fa.RecordedCommands)
+}
+
+// Test that the non-existing configuration versions are reported.
+func TestDaemonConfigVersionsNotFound(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": { }
+	}`, 0, "")
+	daemonID := app.Daemons[0].ID
+
+	versions, err := dbmodel.GetKeaDaemonConfigVersions(db, daemonID)
+	require.NoError(t, err)
+	require.Len(t, versions, 1)
+
+	// Non-existing daemon.
+	rsp := rapi.GetDaemonConfigVersions(ctx, services.GetDaemonConfigVersionsParams{
+		ID: daemonID + 1000,
+	})
+	require.IsType(t, &services.GetDaemonConfigVersionsDefault{}, rsp)
+	listRsp := rsp.(*services.GetDaemonConfigVersionsDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*listRsp))
+
+	// The version doesn't belong to the specified daemon.
+	rsp = rapi.GetDaemonConfigVersion(ctx, services.GetDaemonConfigVersionParams{
+		ID:        daemonID + 1000,
+		VersionID: versions[0].ID,
+	})
+	require.IsType(t, &services.GetDaemonConfigVersionDefault{}, rsp)
+	getRsp := rsp.(*services.GetDaemonConfigVersionDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*getRsp))
+
+	// Non-existing version.
+	rsp = rapi.RestoreDaemonConfigVersion(ctx, services.RestoreDaemonConfigVersionParams{
+		ID:        daemonID,
+		VersionID: versions[0].ID + 1000,
+	})
+	require.IsType(t, &services.RestoreDaemonConfigVersionDefault{}, rsp)
+	restoreRsp := rsp.(*services.RestoreDaemonConfigVersionDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*restoreRsp))
+	require.Empty(t, fa.RecordedCommands)
+}
@@ -0,0 +1,269 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	keaconfig "isc.org/stork/appcfg/kea"
+	"isc.org/stork/server/apps/kea"
+	"isc.org/stork/server/config"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+
+	"isc.org/stork/server/gen/models"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+)
+
+// Implements the POST call to create new transaction for updating the
+// global parameters of a DHCP server
+// (daemons/{daemonId}/global-parameters/transaction). It returns the
+// current server configuration. The secrets are hidden from the users
+// who are not super admins.
+func (r *RestAPI) UpdateGlobalParametersBegin(ctx context.Context, params dhcp.UpdateGlobalParametersBeginParams) middleware.Responder {
+	// Create configuration context.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, err := r.ConfigManager.CreateContext(int64(user.ID))
+	if err != nil {
+		msg := "Problem with creating transaction context"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewUpdateGlobalParametersBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Begin global parameters update transaction. It checks that the
+	// daemon's configuration can be updated and locks the daemon for
+	// updates.
+	cctx, err = r.ConfigManager.GetKeaModule().BeginGlobalParametersUpdate(cctx, params.DaemonID)
+	if err != nil {
+		var (
+			daemonNotFound *config.DaemonNotFoundError
+			configBackend  *config.ConfigBackendError
+			lock           *config.LockError
+		)
+		switch {
+		case errors.As(err, &daemonNotFound):
+			// Failed to find daemon.
+			msg := fmt.Sprintf("Unable to edit the global parameters of the daemon with ID %d because it cannot be found", params.DaemonID)
+			log.Error(msg)
+			rsp := dhcp.NewUpdateGlobalParametersBeginDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		case errors.As(err, &configBackend):
+			// The parameters are managed in the config backend.
+			msg := fmt.Sprintf("Unable to edit the global parameters of the daemon with ID %d because it uses the configuration backend", params.DaemonID)
+			log.Error(msg)
+			rsp := dhcp.NewUpdateGlobalParametersBeginDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		case errors.As(err, &lock):
+			// Failed to lock daemons.
+			msg := fmt.Sprintf("Unable to edit the global parameters of the daemon with ID %d because it may be currently edited by another user", params.DaemonID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewUpdateGlobalParametersBeginDefault(http.StatusLocked).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		default:
+			// Other error.
+			msg := fmt.Sprintf("Problem with initializing transaction for an update of the global parameters of the daemon with ID %d", params.DaemonID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewUpdateGlobalParametersBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+	}
+	// The daemon's configuration is returned to the user, so it can be
+	// edited in the form.
+	dbDaemon, err := dbmodel.GetDaemonByID(r.DB, params.DaemonID)
+	if err != nil || dbDaemon == nil || dbDaemon.KeaDaemon == nil || dbDaemon.KeaDaemon.Config == nil {
+		r.ConfigManager.Done(cctx)
+		msg := fmt.Sprintf("Problem with fetching the configuration of the daemon with ID %d", params.DaemonID)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewUpdateGlobalParametersBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if !user.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) {
+		dbDaemon.KeaDaemon.Config.HideSensitiveData()
+	}
+
+	// Retrieve the generated context ID.
+	cctxID, ok := config.GetValueAsInt64(cctx, config.ContextIDKey)
+	if !ok {
+		msg := "Problem with retrieving context ID for a transaction to update global parameters"
+		log.Error(msg)
+		rsp := dhcp.NewUpdateGlobalParametersBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Remember the context, i.e. new transaction has been successfully created.
+	_ = r.ConfigManager.RememberContext(cctx, time.Minute*10)
+
+	// Return transaction ID and the daemon's configuration to the user.
+	contents := &models.UpdateGlobalParametersBeginResponse{
+		ID:     cctxID,
+		Config: dbDaemon.KeaDaemon.Config,
+	}
+	rsp := dhcp.NewUpdateGlobalParametersBeginOK().WithPayload(contents)
+	return rsp
+}
+
+// Implements the POST call to apply and commit the updated global parameters
+// (daemons/{daemonId}/global-parameters/transaction/{id}/submit). The Kea
+// server checks the configuration with the merged parameters before it is
+// set. If the server rejects the configuration, the error text returned by
+// the server is reported to the user.
+func (r *RestAPI) UpdateGlobalParametersSubmit(ctx context.Context, params dhcp.UpdateGlobalParametersSubmitParams) middleware.Responder {
+	// Make sure that the global parameters are present.
+	parameters, ok := params.Parameters.(map[string]any)
+	if !ok || len(parameters) == 0 {
+		msg := "Global parameters not specified"
+		log.Errorf("Problem with submitting global parameters because they are missing")
+		rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Retrieve the context from the config manager.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, _ := r.ConfigManager.RecoverContext(params.ID, int64(user.ID))
+	if cctx == nil {
+		msg := "Transaction expired for the global parameters update"
+		log.Errorf("Problem with recovering transaction context for transaction ID %d and user ID %d", params.ID, user.ID)
+		rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Make sure that the transaction updates the daemon specified in the path.
+	if !isGlobalParametersTransactionForDaemon(cctx, params.DaemonID) {
+		msg := fmt.Sprintf("Transaction %d does not update the global parameters of the daemon with ID %d", params.ID, params.DaemonID)
+		log.Error(msg)
+		rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// The sensitive data are hidden from the users who are not super admins.
+	// The values they submit for the passwords, secrets and tokens would
+	// remove or replace the actual values.
+	if !user.InGroup(&dbmodel.SystemGroup{ID: dbmodel.SuperAdminGroupID}) && keaconfig.RawConfig(parameters).HasSensitiveData() {
+		msg := "User is forbidden to update passwords, secrets and tokens"
+		log.Errorf("Problem with submitting global parameters by user ID %d because they contain sensitive data", user.ID)
+		rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(http.StatusForbidden).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Apply the global parameters (test the configuration and create Kea commands).
+	cctx, err := r.ConfigManager.GetKeaModule().ApplyGlobalParametersUpdate(cctx, keaconfig.RawConfig(parameters))
+	if err != nil {
+		var (
+			configTest *config.ConfigTestError
+			lock       *config.LockError
+		)
+		var (
+			status int
+			msg    string
+		)
+		switch {
+		case errors.As(err, &configTest):
+			status = http.StatusBadRequest
+			msg = fmt.Sprintf("Configuration with the updated global parameters rejected by %s: %s", configTest.AppName, configTest.Text)
+		case errors.As(err, &lock):
+			status = http.StatusLocked
+			msg = "Unable to apply the global parameters because the server's configuration may be currently edited by another user"
+		default:
+			status = http.StatusInternalServerError
+			msg = fmt.Sprintf("Problem with applying global parameters: %s", err)
+		}
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(status).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Schedule sending the commands to Kea servers if the deadline is specified.
+	if params.Deadline != nil {
+		if !time.Time(*params.Deadline).After(storkutil.UTCNow()) {
+			msg := "Deadline for the scheduled global parameters change must be in the future"
+			log.Error(msg)
+			rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+		cctx, err = r.ConfigManager.Schedule(cctx, time.Time(*params.Deadline))
+		if err != nil {
+			msg := fmt.Sprintf("Problem with scheduling global parameters: %s", err)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+		r.ConfigManager.Done(cctx)
+		rsp := dhcp.NewUpdateGlobalParametersSubmitOK()
+		return rsp
+	}
+	// Send the commands to Kea servers.
+	cctx, err = r.ConfigManager.Commit(cctx)
+	if err != nil {
+		msg := fmt.Sprintf("Problem with committing global parameters: %s", err)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewUpdateGlobalParametersSubmitDefault(http.StatusConflict).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Everything ok. Cleanup and send OK to the client.
+	r.ConfigManager.Done(cctx)
+	rsp := dhcp.NewUpdateGlobalParametersSubmitOK()
+	return rsp
+}
+
+// Checks if the transaction in the context updates the global parameters
+// of the specified daemon.
+func isGlobalParametersTransactionForDaemon(cctx context.Context, daemonID int64) bool {
+	state, ok := config.GetTransactionState[kea.ConfigRecipe](cctx)
+	if !ok || len(state.Updates) == 0 || state.Updates[0].Operation != "global_parameters_update" {
+		return false
+	}
+	for _, id := range state.Updates[0].DaemonIDs {
+		if id == daemonID {
+			return true
+		}
+	}
+	return false
+}
+
+// Implements the DELETE call to cancel updating the global parameters
+// (daemons/{daemonId}/global-parameters/transaction/{id}). It removes the
+// specified transaction from the config manager, if the transaction exists.
+func (r *RestAPI) UpdateGlobalParametersDelete(ctx context.Context, params dhcp.UpdateGlobalParametersDeleteParams) middleware.Responder {
+	// Retrieve the context from the config manager.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, _ := r.ConfigManager.RecoverContext(params.ID, int64(user.ID))
+	if cctx == nil {
+		msg := "Transaction expired for the global parameters update"
+		log.Errorf("Problem with recovering transaction context for transaction ID %d and user ID %d", params.ID, user.ID)
+		rsp := dhcp.NewUpdateGlobalParametersDeleteDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	r.ConfigManager.Done(cctx)
+	rsp := dhcp.NewUpdateGlobalParametersDeleteOK()
+	return rsp
+}
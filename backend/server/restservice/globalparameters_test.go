@@ -0,0 +1,325 @@
+package restservice
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	"isc.org/stork/server/apps"
+	"isc.org/stork/server/apps/kea"
+	appstest "isc.org/stork/server/apps/test"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	dbmodeltest "isc.org/stork/server/database/model/test"
+	dbtest "isc.org/stork/server/database/test"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Adds a Kea server with the specified configuration. Then, it creates
+// the REST API with the config manager and the logged user. The fake agents
+// respond to the config-get command with the server configuration and to
+// the other commands with the specified result and text. The configuration
+// set with the config-set command replaces the server configuration. It returns
+// the fake agents receiving the commands, the REST API instance, the context
+// with the user session, and the added app.
+func prepareGlobalParametersTest(t *testing.T, db *dbops.PgDB, dbSettings *dbops.DatabaseSettings, serverConfig string, result int, text string) (*agentcommtest.FakeAgents, *RestAPI, context.Context, *dbmodel.App) {
+	server, err := dbmodeltest.NewKeaDHCPv4Server(db)
+	require.NoError(t, err)
+	err = server.Configure(serverConfig)
+	require.NoError(t, err)
+
+	app, err := server.GetKea()
+	require.NoError(t, err)
+
+	err = kea.CommitAppIntoDB(db, app, &storktest.FakeEventCenter{}, nil, dbmodel.NewDHCPOptionDefinitionLookup())
+	require.NoError(t, err)
+
+	dbapps, err := dbmodel.GetAllApps(db, true)
+	require.NoError(t, err)
+	require.Len(t, dbapps, 1)
+
+	// Create fake agents receiving commands. The config-get command returns
+	// the server configuration most recently set with the config-set
+	// command.
+	var fa *agentcommtest.FakeAgents
+	fa = agentcommtest.NewFakeAgents(func(callNo int, cmdResponses []interface{}) {
+		response := []byte(fmt.Sprintf(`[
+			{
+				"result": %d,
+				"text": %q
+			}
+		]`, result, text))
+		switch last := fa.GetLastCommand(); last.GetCommand() {
+		case "config-get":
+			response = []byte(fmt.Sprintf(`[
+				{
+					"result": 0,
+					"arguments": %s
+				}
+			]`, serverConfig))
+		case "config-set":
+			if marshalled, err := json.Marshal(last.Arguments); err == nil && result == keactrl.ResponseSuccess {
+				serverConfig = string(marshalled)
+			}
+		}
+		command := keactrl.NewCommand("config-test", []string{"dhcp4"}, nil)
+		_ = keactrl.UnmarshalResponseList(command, response, cmdResponses[0])
+	}, nil)
+	require.NotNil(t, fa)
+
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	require.NotNil(t, lookup)
+
+	// Create the config manager.
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	require.NotNil(t, cm)
+
+	// Create API.
+	rapi, err := NewRestAPI(dbSettings, db, fa, cm, lookup)
+	require.NoError(t, err)
+
+	// Create session manager.
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Create user session. The user must exist in the database because
+	// the committed configuration versions are associated with the user.
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err = dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	return fa, rapi, ctx, &dbapps[0]
+}
+
+// Test the calls for creating transaction and submitting the updated
+// global parameters.
+func TestUpdateGlobalParametersBeginSubmit(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": {
+			"valid-lifetime": 1000,
+			"lease-database": {
+				"type": "memfile",
+				"password": "secret"
+			}
+		}
+	}`, 0, "Configuration seems sane.")
+	daemonID := app.Daemons[0].ID
+
+	// Begin transaction.
+	rsp := rapi.UpdateGlobalParametersBegin(ctx, dhcp.UpdateGlobalParametersBeginParams{
+		DaemonID: daemonID,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersBeginOK{}, rsp)
+	okRsp := rsp.(*dhcp.UpdateGlobalParametersBeginOK)
+	contents := okRsp.Payload
+
+	// Make sure the server returned transaction ID and the configuration.
+	transactionID := contents.ID
+	require.NotZero(t, transactionID)
+	require.IsType(t, &dbmodel.KeaConfig{}, contents.Config)
+	cfg := contents.Config.(*dbmodel.KeaConfig)
+	require.EqualValues(t, 1000, *cfg.GetValidLifetimeParameters().ValidLifetime)
+
+	// The password should be hidden from the user who is not a super admin.
+	leaseDatabase := cfg.Raw["Dhcp4"].(map[string]any)["lease-database"].(map[string]any)
+	require.Contains(t, leaseDatabase, "password")
+	require.Nil(t, leaseDatabase["password"])
+
+	// Submit transaction.
+	params := dhcp.UpdateGlobalParametersSubmitParams{
+		DaemonID: daemonID,
+		ID:       transactionID,
+		Parameters: map[string]any{
+			"valid-lifetime": 2000,
+		},
+	}
+	rsp = rapi.UpdateGlobalParametersSubmit(ctx, params)
+	require.IsType(t, &dhcp.UpdateGlobalParametersSubmitOK{}, rsp)
+
+	// It should result in sending commands to the Kea server.
+	require.Len(t, fa.RecordedCommands, 5)
+	require.Equal(t, "config-get", fa.RecordedCommands[0].GetCommand())
+	require.Equal(t, "config-test", fa.RecordedCommands[1].GetCommand())
+	require.Equal(t, "config-set", fa.RecordedCommands[2].GetCommand())
+	require.Equal(t, "config-write", fa.RecordedCommands[3].GetCommand())
+	require.Equal(t, "config-get", fa.RecordedCommands[4].GetCommand())
+
+	// The password must be sent to the server.
+	require.Contains(t, fa.RecordedCommands[2].Marshal(), "secret")
+
+	// The configuration in the database should be updated.
+	daemon, err := dbmodel.GetDaemonByID(db, daemonID)
+	require.NoError(t, err)
+	require.NotNil(t, daemon)
+	require.EqualValues(t, 2000, *daemon.KeaDaemon.Config.GetValidLifetimeParameters().ValidLifetime)
+}
+
+// Test that the submitted global parameters are rejected when the daemon
+// doesn't match the transaction or when the user who doesn't see the
+// sensitive data tries to update them.
+func TestUpdateGlobalParametersSubmitRejected(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": {
+			"valid-lifetime": 1000,
+			"lease-database": {
+				"type": "memfile",
+				"password": "secret"
+			}
+		}
+	}`, 0, "Configuration seems sane.")
+	daemonID := app.Daemons[0].ID
+
+	rsp := rapi.UpdateGlobalParametersBegin(ctx, dhcp.UpdateGlobalParametersBeginParams{
+		DaemonID: daemonID,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersBeginOK{}, rsp)
+	transactionID := rsp.(*dhcp.UpdateGlobalParametersBeginOK).Payload.ID
+
+	// The daemon doesn't match the transaction.
+	rsp = rapi.UpdateGlobalParametersSubmit(ctx, dhcp.UpdateGlobalParametersSubmitParams{
+		DaemonID: daemonID + 1000,
+		ID:       transactionID,
+		Parameters: map[string]any{
+			"valid-lifetime": 2000,
+		},
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersSubmitDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.UpdateGlobalParametersSubmitDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// The user is not a super admin, so the password is hidden from
+	// this user and can't be submitted.
+	rsp = rapi.UpdateGlobalParametersSubmit(ctx, dhcp.UpdateGlobalParametersSubmitParams{
+		DaemonID: daemonID,
+		ID:       transactionID,
+		Parameters: map[string]any{
+			"lease-database": map[string]any{
+				"type":     "memfile",
+				"password": nil,
+			},
+		},
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersSubmitDefault{}, rsp)
+	defaultRsp = rsp.(*dhcp.UpdateGlobalParametersSubmitDefault)
+	require.Equal(t, http.StatusForbidden, getStatusCode(*defaultRsp))
+
+	// No commands should be sent.
+	require.Empty(t, fa.RecordedCommands)
+
+	// The password should remain in the database.
+	daemon, err := dbmodel.GetDaemonByID(db, daemonID)
+	require.NoError(t, err)
+	require.NotNil(t, daemon)
+	leaseDatabase := daemon.KeaDaemon.Config.Raw["Dhcp4"].(map[string]any)["lease-database"].(map[string]any)
+	require.Equal(t, "secret", leaseDatabase["password"])
+}
+
+// Test that the configuration rejected by the Kea server is reported to
+// the user and the transaction can be canceled.
+func TestUpdateGlobalParametersSubmitConfigTestErrorAndCancel(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": {
+			"valid-lifetime": 1000
+		}
+	}`, 1, "unsupported parameter 'foo'")
+	daemonID := app.Daemons[0].ID
+
+	rsp := rapi.UpdateGlobalParametersBegin(ctx, dhcp.UpdateGlobalParametersBeginParams{
+		DaemonID: daemonID,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersBeginOK{}, rsp)
+	transactionID := rsp.(*dhcp.UpdateGlobalParametersBeginOK).Payload.ID
+
+	rsp = rapi.UpdateGlobalParametersSubmit(ctx, dhcp.UpdateGlobalParametersSubmitParams{
+		DaemonID: daemonID,
+		ID:       transactionID,
+		Parameters: map[string]any{
+			"foo": "bar",
+		},
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersSubmitDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.UpdateGlobalParametersSubmitDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+	require.Contains(t, *defaultRsp.Payload.Message, "unsupported parameter 'foo'")
+
+	// The configuration must not be set.
+	require.Len(t, fa.RecordedCommands, 2)
+	require.Equal(t, "config-get", fa.RecordedCommands[0].GetCommand())
+	require.Equal(t, "config-test", fa.RecordedCommands[1].GetCommand())
+
+	// Cancel the transaction.
+	rsp = rapi.UpdateGlobalParametersDelete(ctx, dhcp.UpdateGlobalParametersDeleteParams{
+		DaemonID: daemonID,
+		ID:       transactionID,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersDeleteOK{}, rsp)
+
+	// The transaction no longer exists.
+	rsp = rapi.UpdateGlobalParametersDelete(ctx, dhcp.UpdateGlobalParametersDeleteParams{
+		DaemonID: daemonID,
+		ID:       transactionID,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersDeleteDefault{}, rsp)
+	defaultDeleteRsp := rsp.(*dhcp.UpdateGlobalParametersDeleteDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultDeleteRsp))
+}
+
+// Test that the transaction can't begin for the daemon using the config
+// backend and for a non-existing daemon.
+func TestUpdateGlobalParametersBeginErrors(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	_, rapi, ctx, app := prepareGlobalParametersTest(t, db, dbSettings, `{
+		"Dhcp4": {
+			"config-control": {
+				"config-databases": [
+					{
+						"type": "mysql",
+						"name": "kea"
+					}
+				]
+			}
+		}
+	}`, 0, "")
+
+	rsp := rapi.UpdateGlobalParametersBegin(ctx, dhcp.UpdateGlobalParametersBeginParams{
+		DaemonID: app.Daemons[0].ID,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersBeginDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.UpdateGlobalParametersBeginDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+	require.Contains(t, *defaultRsp.Payload.Message, "configuration backend")
+
+	rsp = rapi.UpdateGlobalParametersBegin(ctx, dhcp.UpdateGlobalParametersBeginParams{
+		DaemonID: app.Daemons[0].ID + 1000,
+	})
+	require.IsType(t, &dhcp.UpdateGlobalParametersBeginDefault{}, rsp)
+	defaultRsp = rsp.(*dhcp.UpdateGlobalParametersBeginDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+}
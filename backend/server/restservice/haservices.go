@@ -0,0 +1,230 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	"isc.org/stork/server/apps/kea"
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+	storkutil "isc.org/stork/util"
+)
+
+// Returns the HA timeline of a service, i.e., the HA state transitions of
+// its servers, the failovers and the changes of the failover counters.
+func (r *RestAPI) GetHATimeline(ctx context.Context, params services.GetHATimelineParams) middleware.Responder {
+	service, err := dbmodel.GetDetailedService(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get service with ID %d from the database", params.ID)
+		rsp := services.NewGetHATimelineDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if service == nil || service.HAService == nil {
+		msg := fmt.Sprintf("Cannot find HA service with ID %d", params.ID)
+		rsp := services.NewGetHATimelineDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	var since time.Time
+	if params.Since != nil {
+		since = time.Time(*params.Since)
+	}
+
+	transitions, err := dbmodel.GetHAStateTransitions(r.DB, service.ID, since)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get HA state transitions of service with ID %d from the database", service.ID)
+		rsp := services.NewGetHATimelineDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	samples, err := dbmodel.GetHAClientsSamples(r.DB, service.ID, since)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get HA clients samples of service with ID %d from the database", service.ID)
+		rsp := services.NewGetHATimelineDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	timeline := &models.HATimeline{
+		ServiceID:      service.ID,
+		Transitions:    []*models.HAStateTransition{},
+		Failovers:      []*models.HAFailover{},
+		ClientsSamples: []*models.HAClientsSample{},
+	}
+	for _, transition := range transitions {
+		timeline.Transitions = append(timeline.Transitions, &models.HAStateTransition{
+			DaemonID:       transition.DaemonID,
+			PreviousState:  transition.PreviousState,
+			State:          transition.State,
+			TransitionedAt: strfmt.DateTime(transition.TransitionedAt),
+		})
+	}
+	now := storkutil.UTCNow()
+	for _, failover := range dbmodel.GetHAFailovers(transitions) {
+		timeline.Failovers = append(timeline.Failovers, &models.HAFailover{
+			DaemonID:  failover.DaemonID,
+			StartedAt: strfmt.DateTime(failover.StartedAt),
+			EndedAt:   convertToOptionalDatetime(failover.EndedAt),
+			Duration:  int64(failover.GetDuration(now).Seconds()),
+		})
+	}
+	for _, sample := range samples {
+		timeline.ClientsSamples = append(timeline.ClientsSamples, &models.HAClientsSample{
+			DaemonID:           sample.DaemonID,
+			CollectedAt:        strfmt.DateTime(sample.CollectedAt),
+			ConnectingClients:  sample.ConnectingClients,
+			UnackedClients:     sample.UnackedClients,
+			UnackedClientsLeft: sample.UnackedClientsLeft,
+			AnalyzedPackets:    sample.AnalyzedPackets,
+		})
+	}
+
+	rsp := services.NewGetHATimelineOK().WithPayload(timeline)
+	return rsp
+}
+
+// Performs an action controlling the HA state of one of the servers
+// belonging to the HA service, e.g., starts the maintenance. The
+// configurations of all daemons belonging to the service are locked while
+// the action is performed, so the daemons cannot be edited by other users
+// at the same time. The action and its result are recorded as an event.
+func (r *RestAPI) PerformHAAction(ctx context.Context, params services.PerformHAActionParams) middleware.Responder {
+	if params.Action == nil || params.Action.Action == nil || params.Action.DaemonID == nil {
+		msg := "Missing HA action or daemon ID"
+		rsp := services.NewPerformHAActionDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	action := kea.HAAction(*params.Action.Action)
+	if !action.IsValid() {
+		msg := fmt.Sprintf("Unsupported HA action %s", action)
+		rsp := services.NewPerformHAActionDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	service, err := dbmodel.GetDetailedService(r.DB, params.ID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get service with ID %d from the database", params.ID)
+		rsp := services.NewPerformHAActionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if service == nil || service.HAService == nil {
+		msg := fmt.Sprintf("Cannot find HA service with ID %d", params.ID)
+		rsp := services.NewPerformHAActionDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	// The daemon must belong to the service.
+	var daemonIDs []int64
+	daemonFound := false
+	for _, d := range service.Daemons {
+		daemonIDs = append(daemonIDs, d.ID)
+		if d.ID == *params.Action.DaemonID {
+			daemonFound = true
+		}
+	}
+	if !daemonFound {
+		msg := fmt.Sprintf("Daemon with ID %d does not belong to the HA service with ID %d", *params.Action.DaemonID, service.ID)
+		rsp := services.NewPerformHAActionDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	// Fetch the daemon with its app, access points and machine. They are
+	// required to send the command and to record the event.
+	dbDaemon, err := dbmodel.GetDaemonByID(r.DB, *params.Action.DaemonID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get daemon with ID %d from the database", *params.Action.DaemonID)
+		rsp := services.NewPerformHAActionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbDaemon == nil {
+		msg := fmt.Sprintf("Cannot find daemon with ID %d", *params.Action.DaemonID)
+		rsp := services.NewPerformHAActionDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	// The command cannot be created if the HA configuration of the daemon
+	// stored in the database doesn't match the service.
+	command, err := kea.NewHAActionCommand(dbDaemon, service, action, params.Action.Scopes)
+	if err != nil {
+		msg := fmt.Sprintf("Cannot create %s command for daemon with ID %d", action.GetCommandName(), dbDaemon.ID)
+		log.WithError(err).Error(msg)
+		rsp := services.NewPerformHAActionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	_, dbUser := r.SessionManager.Logged(ctx)
+	cctx, err := r.ConfigManager.CreateContext(int64(dbUser.ID))
+	if err != nil {
+		msg := "Problem with creating transaction context"
+		log.WithError(err).Error(msg)
+		rsp := services.NewPerformHAActionDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	cctx, err = r.ConfigManager.Lock(cctx, daemonIDs...)
+	if err != nil {
+		msg := fmt.Sprintf("Unable to perform the HA action because the daemons of the HA service with ID %d may be currently edited by another user", service.ID)
+		log.WithError(err).Error(msg)
+		rsp := services.NewPerformHAActionDefault(http.StatusLocked).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	defer r.ConfigManager.Done(cctx)
+
+	text, err := kea.SendHAAction(ctx, r.Agents, dbDaemon, command)
+	if err != nil {
+		r.EventCenter.AddErrorEvent(fmt.Sprintf("{user} failed to perform %s on {daemon}", action.GetCommandName()),
+			dbUser, dbDaemon, dbDaemon.App, dbDaemon.App.Machine, err.Error())
+		msg := fmt.Sprintf("Failed to perform %s on daemon with ID %d", action.GetCommandName(), dbDaemon.ID)
+		log.WithError(err).Error(msg)
+		rsp := services.NewPerformHAActionDefault(http.StatusBadGateway).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	r.EventCenter.AddInfoEvent(fmt.Sprintf("{user} performed %s on {daemon}", action.GetCommandName()),
+		dbUser, dbDaemon, dbDaemon.App, dbDaemon.App.Machine, text)
+
+	rsp := services.NewPerformHAActionOK().WithPayload(&models.HAActionResult{
+		DaemonID: dbDaemon.ID,
+		Text:     text,
+	})
+	return rsp
+}
@@ -0,0 +1,402 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+
+	keactrl "isc.org/stork/appctrl/kea"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	apps "isc.org/stork/server/apps"
+	appstest "isc.org/stork/server/apps/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	"isc.org/stork/server/gen/restapi/operations/services"
+	storktest "isc.org/stork/server/test/dbmodel"
+)
+
+// Test that the HA timeline of a service is returned.
+func TestGetHATimeline(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	fd := &storktest.FakeDispatcher{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, fd)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	keaApp := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Active:    true,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon("dhcp4", true),
+		},
+	}
+	_, err = dbmodel.AddApp(db, keaApp)
+	require.NoError(t, err)
+	daemonID := keaApp.Daemons[0].ID
+
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			ServiceType: "ha_dhcp",
+			Daemons:     keaApp.Daemons,
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAType:    "dhcp4",
+			HAMode:    "load-balancing",
+			PrimaryID: daemonID,
+		},
+	}
+	err = dbmodel.AddService(db, service)
+	require.NoError(t, err)
+
+	startTime := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+	err = dbmodel.AddHAStatusHistory(db, []*dbmodel.HAStateTransition{
+		{
+			ServiceID:      service.ID,
+			DaemonID:       daemonID,
+			PreviousState:  dbmodel.HAStateLoadBalancing,
+			State:          dbmodel.HAStatePartnerDown,
+			TransitionedAt: startTime,
+		},
+		{
+			ServiceID:      service.ID,
+			DaemonID:       daemonID,
+			PreviousState:  dbmodel.HAStatePartnerDown,
+			State:          dbmodel.HAStateLoadBalancing,
+			TransitionedAt: startTime.Add(time.Minute),
+		},
+	}, []*dbmodel.HAClientsSample{
+		{
+			ServiceID:         service.ID,
+			DaemonID:          daemonID,
+			CollectedAt:       startTime,
+			ConnectingClients: 5,
+		},
+	})
+	require.NoError(t, err)
+
+	rsp := rapi.GetHATimeline(ctx, services.GetHATimelineParams{
+		ID: service.ID,
+	})
+	require.IsType(t, &services.GetHATimelineOK{}, rsp)
+	timeline := rsp.(*services.GetHATimelineOK).Payload
+	require.EqualValues(t, service.ID, timeline.ServiceID)
+
+	require.Len(t, timeline.Transitions, 2)
+	require.EqualValues(t, daemonID, timeline.Transitions[0].DaemonID)
+	require.Equal(t, "load-balancing", timeline.Transitions[0].PreviousState)
+	require.Equal(t, "partner-down", timeline.Transitions[0].State)
+
+	require.Len(t, timeline.Failovers, 1)
+	require.EqualValues(t, daemonID, timeline.Failovers[0].DaemonID)
+	require.NotNil(t, timeline.Failovers[0].EndedAt)
+	require.EqualValues(t, 60, timeline.Failovers[0].Duration)
+
+	require.Len(t, timeline.ClientsSamples, 1)
+	require.EqualValues(t, 5, timeline.ClientsSamples[0].ConnectingClients)
+
+	// Only the history since the specified time is returned.
+	since := strfmt.DateTime(startTime.Add(time.Second))
+	rsp = rapi.GetHATimeline(ctx, services.GetHATimelineParams{
+		ID:    service.ID,
+		Since: &since,
+	})
+	require.IsType(t, &services.GetHATimelineOK{}, rsp)
+	timeline = rsp.(*services.GetHATimelineOK).Payload
+	require.Len(t, timeline.Transitions, 1)
+	require.Empty(t, timeline.Failovers)
+	require.Empty(t, timeline.ClientsSamples)
+
+	// Non-existing service.
+	rsp = rapi.GetHATimeline(ctx, services.GetHATimelineParams{
+		ID: service.ID + 1,
+	})
+	require.IsType(t, &services.GetHATimelineDefault{}, rsp)
+	defaultRsp := rsp.(*services.GetHATimelineDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+}
+
+// Test that the HA action is sent to the daemon belonging to the HA service
+// and the action is recorded as an event.
+func TestPerformHAAction(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mock := func(callNo int, cmdResponses []interface{}) {
+		json := []byte(`[
+			{
+				"result": 0,
+				"text": "Server is in-maintenance state."
+			}
+		]`)
+		command := keactrl.NewCommand("ha-maintenance-start", []string{"dhcp4"}, nil)
+		_ = keactrl.UnmarshalResponseList(command, json, cmdResponses[0])
+	}
+	fa := agentcommtest.NewKeaFakeAgents(mock)
+	fec := &storktest.FakeEventCenter{}
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	rapi, err := NewRestAPI(dbSettings, db, fa, fec, cm, lookup)
+	require.NoError(t, err)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	user := &dbmodel.SystemUser{
+		ID: 1234,
+	}
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	var daemons []*dbmodel.Daemon
+	for i := 0; i < 3; i++ {
+		m := &dbmodel.Machine{
+			Address:   "localhost",
+			AgentPort: int64(8080 + i),
+		}
+		err = dbmodel.AddMachine(db, m)
+		require.NoError(t, err)
+
+		accessPoints := []*dbmodel.AccessPoint{}
+		accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", int64(8000+i), false)
+		keaApp := &dbmodel.App{
+			MachineID:    m.ID,
+			Type:         dbmodel.AppTypeKea,
+			Active:       true,
+			AccessPoints: accessPoints,
+			Daemons: []*dbmodel.Daemon{
+				dbmodel.NewKeaDaemon("dhcp4", true),
+			},
+		}
+		_, err = dbmodel.AddApp(db, keaApp)
+		require.NoError(t, err)
+		daemons = append(daemons, keaApp.Daemons[0])
+	}
+
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			ServiceType: "ha_dhcp",
+			Daemons:     daemons[:2],
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAType:      "dhcp4",
+			HAMode:      "hot-standby",
+			PrimaryID:   daemons[0].ID,
+			SecondaryID: daemons[1].ID,
+		},
+	}
+	err = dbmodel.AddService(db, service)
+	require.NoError(t, err)
+
+	action := "maintenance-start"
+	daemonID := daemons[0].ID
+	rsp := rapi.PerformHAAction(ctx, services.PerformHAActionParams{
+		ID: service.ID,
+		Action: &models.HAActionRequest{
+			Action:   &action,
+			DaemonID: &daemonID,
+		},
+	})
+	require.IsType(t, &services.PerformHAActionOK{}, rsp)
+	result := rsp.(*services.PerformHAActionOK).Payload
+	require.EqualValues(t, daemonID, result.DaemonID)
+	require.Equal(t, "Server is in-maintenance state.", result.Text)
+
+	require.Len(t, fa.RecordedCommands, 1)
+	require.Equal(t, "ha-maintenance-start", fa.RecordedCommands[0].GetCommand())
+	require.Equal(t, "http://localhost:8000/", fa.RecordedURLs[0])
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvInfo, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "ha-maintenance-start")
+	require.EqualValues(t, daemonID, fec.Events[0].Relations.DaemonID)
+	require.EqualValues(t, user.ID, fec.Events[0].Relations.UserID)
+	require.Equal(t, "Server is in-maintenance state.", fec.Events[0].Details)
+
+	// The daemons are unlocked after the action.
+	cctx, _ := cm.CreateContext(1)
+	_, err = cm.Lock(cctx, daemons[0].ID, daemons[1].ID)
+	require.NoError(t, err)
+
+	// The action cannot be performed while the daemons are locked by
+	// another user.
+	rsp = rapi.PerformHAAction(ctx, services.PerformHAActionParams{
+		ID: service.ID,
+		Action: &models.HAActionRequest{
+			Action:   &action,
+			DaemonID: &daemonID,
+		},
+	})
+	require.IsType(t, &services.PerformHAActionDefault{}, rsp)
+	defaultRsp := rsp.(*services.PerformHAActionDefault)
+	require.Equal(t, http.StatusLocked, getStatusCode(*defaultRsp))
+	cm.Done(cctx)
+
+	// The daemon doesn't belong to the service.
+	otherDaemonID := daemons[2].ID
+	rsp = rapi.PerformHAAction(ctx, services.PerformHAActionParams{
+		ID: service.ID,
+		Action: &models.HAActionRequest{
+			Action:   &action,
+			DaemonID: &otherDaemonID,
+		},
+	})
+	require.IsType(t, &services.PerformHAActionDefault{}, rsp)
+	defaultRsp = rsp.(*services.PerformHAActionDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// Unsupported action.
+	invalidAction := "foo"
+	rsp = rapi.PerformHAAction(ctx, services.PerformHAActionParams{
+		ID: service.ID,
+		Action: &models.HAActionRequest{
+			Action:   &invalidAction,
+			DaemonID: &daemonID,
+		},
+	})
+	require.IsType(t, &services.PerformHAActionDefault{}, rsp)
+	defaultRsp = rsp.(*services.PerformHAActionDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// Non-existing service.
+	rsp = rapi.PerformHAAction(ctx, services.PerformHAActionParams{
+		ID: service.ID + 1,
+		Action: &models.HAActionRequest{
+			Action:   &action,
+			DaemonID: &daemonID,
+		},
+	})
+	require.IsType(t, &services.PerformHAActionDefault{}, rsp)
+	defaultRsp = rsp.(*services.PerformHAActionDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+
+	require.Len(t, fa.RecordedCommands, 1)
+}
+
+// Test that a failed HA action is recorded as an error event.
+func TestPerformHAActionError(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	mock := func(callNo int, cmdResponses []interface{}) {
+		mockStatusError("ha-sync", cmdResponses)
+	}
+	fa := agentcommtest.NewKeaFakeAgents(mock)
+	fec := &storktest.FakeEventCenter{}
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	rapi, err := NewRestAPI(dbSettings, db, fa, fec, cm, lookup)
+	require.NoError(t, err)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	user := &dbmodel.SystemUser{
+		ID: 1234,
+	}
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err = dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	accessPoints := []*dbmodel.AccessPoint{}
+	accessPoints = dbmodel.AppendAccessPoint(accessPoints, dbmodel.AccessPointControl, "localhost", "", 8000, false)
+	keaApp := &dbmodel.App{
+		MachineID:    m.ID,
+		Type:         dbmodel.AppTypeKea,
+		Active:       true,
+		AccessPoints: accessPoints,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon("dhcp4", true),
+		},
+	}
+	_, err = dbmodel.AddApp(db, keaApp)
+	require.NoError(t, err)
+	daemonID := keaApp.Daemons[0].ID
+
+	err = keaApp.Daemons[0].SetConfigFromJSON(`{
+		"Dhcp4": {
+			"hooks-libraries": [
+				{
+					"library": "libdhcp_ha.so",
+					"parameters": {
+						"high-availability": [{
+							"this-server-name": "server1",
+							"mode": "hot-standby",
+							"peers": [
+								{ "name": "server1", "url": "http://192.0.2.1:8000", "role": "primary" },
+								{ "name": "server2", "url": "http://192.0.2.2:8000", "role": "standby" }
+							]
+						}]
+					}
+				}
+			]
+		}
+	}`)
+	require.NoError(t, err)
+	err = dbmodel.UpdateDaemon(db, keaApp.Daemons[0])
+	require.NoError(t, err)
+
+	service := &dbmodel.Service{
+		BaseService: dbmodel.BaseService{
+			ServiceType: "ha_dhcp",
+			Daemons:     keaApp.Daemons,
+		},
+		HAService: &dbmodel.BaseHAService{
+			HAType:    "dhcp4",
+			HAMode:    "hot-standby",
+			PrimaryID: daemonID,
+		},
+	}
+	err = dbmodel.AddService(db, service)
+	require.NoError(t, err)
+
+	action := "sync"
+	rsp := rapi.PerformHAAction(ctx, services.PerformHAActionParams{
+		ID: service.ID,
+		Action: &models.HAActionRequest{
+			Action:   &action,
+			DaemonID: &daemonID,
+		},
+	})
+	require.IsType(t, &services.PerformHAActionDefault{}, rsp)
+	defaultRsp := rsp.(*services.PerformHAActionDefault)
+	require.Equal(t, http.StatusBadGateway, getStatusCode(*defaultRsp))
+
+	// The partner is specified in the ha-sync command.
+	require.Len(t, fa.RecordedCommands, 1)
+	require.Equal(t, map[string]interface{}{"server-name": "server2"}, fa.GetLastCommand().Arguments)
+
+	require.Len(t, fec.Events, 1)
+	require.Equal(t, dbmodel.EvError, fec.Events[0].Level)
+	require.Contains(t, fec.Events[0].Text, "ha-sync")
+	require.Contains(t, fec.Events[0].Details, "unable to communicate with the daemon")
+}
@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 
@@ -348,12 +349,14 @@ func (r *RestAPI) CreateHostBegin(ctx context.Context, params dhcp.CreateHostBeg
 // of the Kea config module that applies the specified reservation. It is
 // one of the ApplyHostAdd or ApplyHostUpdate, depending on whether the
 // new host is created (via CreateHostSubmit) or updated (via UpdateHostSubmit).
+// If the deadline is specified, the changes are scheduled for committing at
+// this time rather than committed right away.
 // The apply functions receive the transaction context and a pointer to the
 // host reservation. They return the updated context and error. This function
 // returns the HTTP error code if an error occurs or 0 when there is no error.
 // In addition it returns an error string to be included in the HTTP response
 // or an empty string if there is no error.
-func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, transactionID int64, restHost *models.Host, applyFunc func(context.Context, *dbmodel.Host) (context.Context, error)) (int, string) {
+func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, transactionID int64, restHost *models.Host, deadline *strfmt.DateTime, applyFunc func(context.Context, *dbmodel.Host) (context.Context, error)) (int, string) {
 	// Make sure that the host information is present.
 	if restHost == nil {
 		msg := "Host information not specified"
@@ -395,6 +398,22 @@ func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, transactio
 		log.WithError(err).Error(msg)
 		return http.StatusInternalServerError, msg
 	}
+	// Schedule sending the commands to Kea servers if the deadline is specified.
+	if deadline != nil {
+		if !time.Time(*deadline).After(storkutil.UTCNow()) {
+			msg := "Deadline for the scheduled host change must be in the future"
+			log.Error(msg)
+			return http.StatusBadRequest, msg
+		}
+		cctx, err = r.ConfigManager.Schedule(cctx, time.Time(*deadline))
+		if err != nil {
+			msg := fmt.Sprintf("Problem with scheduling host information: %s", err)
+			log.WithError(err).Error(msg)
+			return http.StatusInternalServerError, msg
+		}
+		r.ConfigManager.Done(cctx)
+		return 0, ""
+	}
 	// Send the commands to Kea servers.
 	cctx, err = r.ConfigManager.Commit(cctx)
 	if err != nil {
@@ -409,7 +428,7 @@ func (r *RestAPI) commonCreateOrUpdateHostSubmit(ctx context.Context, transactio
 
 // Implements the POST call to apply and commit host reservation (hosts/new/transaction/{id}/submit).
 func (r *RestAPI) CreateHostSubmit(ctx context.Context, params dhcp.CreateHostSubmitParams) middleware.Responder {
-	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.ID, params.Host, r.ConfigManager.GetKeaModule().ApplyHostAdd); code != 0 {
+	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.ID, params.Host, params.Deadline, r.ConfigManager.GetKeaModule().ApplyHostAdd); code != 0 {
 		// Error case.
 		rsp := dhcp.NewCreateHostSubmitDefault(code).WithPayload(&models.APIError{
 			Message: &msg,
@@ -532,7 +551,7 @@ func (r *RestAPI) UpdateHostBegin(ctx context.Context, params dhcp.UpdateHostBeg
 
 // Implements the POST call and commit an updated host reservation (hosts/{hostId}/transaction/{id}/submit).
 func (r *RestAPI) UpdateHostSubmit(ctx context.Context, params dhcp.UpdateHostSubmitParams) middleware.Responder {
-	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.ID, params.Host, r.ConfigManager.GetKeaModule().ApplyHostUpdate); code != 0 {
+	if code, msg := r.commonCreateOrUpdateHostSubmit(ctx, params.ID, params.Host, params.Deadline, r.ConfigManager.GetKeaModule().ApplyHostUpdate); code != 0 {
 		// Error case.
 		rsp := dhcp.NewUpdateHostSubmitDefault(code).WithPayload(&models.APIError{
 			Message: &msg,
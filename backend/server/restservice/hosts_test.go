@@ -4,7 +4,9 @@ import (
 	"context"
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/go-openapi/strfmt"
 	"github.com/stretchr/testify/require"
 	keactrl "isc.org/stork/appctrl/kea"
 	dhcpmodel "isc.org/stork/datamodel/dhcp"
@@ -669,6 +671,93 @@ func TestCreateHostSubmitError(t *testing.T) {
 	})
 }
 
+// Test that submitting a new host reservation with a deadline schedules
+// the config change instead of sending the commands to Kea servers.
+func TestCreateHostSubmitScheduled(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	rapi, err := NewRestAPI(dbSettings, db, fa, cm, lookup)
+	require.NoError(t, err)
+
+	// The scheduled config change must be associated with an existing user.
+	user := &dbmodel.SystemUser{
+		Login:    "test",
+		Lastname: "test",
+		Name:     "test",
+	}
+	_, err = dbmodel.CreateUser(db, user)
+	require.NoError(t, err)
+
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	_, apps := storktestdbmodel.AddTestHosts(t, db)
+
+	rsp := rapi.CreateHostBegin(ctx, dhcp.CreateHostBeginParams{})
+	require.IsType(t, &dhcp.CreateHostBeginOK{}, rsp)
+	transactionID := rsp.(*dhcp.CreateHostBeginOK).Payload.ID
+
+	params := dhcp.CreateHostSubmitParams{
+		ID: transactionID,
+		Host: &models.Host{
+			SubnetID: 1,
+			Hostname: "example.org",
+			HostIdentifiers: []*models.HostIdentifier{
+				{
+					IDType:     "hw-address",
+					IDHexValue: "010203040506",
+				},
+			},
+			LocalHosts: []*models.LocalHost{
+				{
+					DaemonID:   apps[0].Daemons[0].ID,
+					DataSource: dbmodel.HostDataSourceAPI.String(),
+				},
+			},
+		},
+	}
+
+	// The deadline must be in the future.
+	deadline := strfmt.DateTime(time.Now().Add(-time.Hour))
+	params.Deadline = &deadline
+	rsp = rapi.CreateHostSubmit(ctx, params)
+	require.IsType(t, &dhcp.CreateHostSubmitDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.CreateHostSubmitDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	deadline = strfmt.DateTime(time.Now().Add(time.Hour))
+	rsp = rapi.CreateHostSubmit(ctx, params)
+	require.IsType(t, &dhcp.CreateHostSubmitOK{}, rsp)
+
+	// No commands should be sent to the servers until the deadline.
+	require.Empty(t, fa.RecordedCommands)
+
+	changes, err := dbmodel.GetScheduledConfigChanges(db)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	require.False(t, changes[0].Executed)
+	require.EqualValues(t, user.ID, changes[0].UserID)
+	require.Len(t, changes[0].Updates, 1)
+	require.Equal(t, "host_add", changes[0].Updates[0].Operation)
+
+	// The transaction is done.
+	cctx, _ := cm.RecoverContext(transactionID, int64(user.ID))
+	if cctx != nil {
+		cm.Done(cctx)
+	}
+	require.Nil(t, cctx)
+}
+
 // Test that the transaction to add a new host can be canceled, resulting
 // in the removal of this transaction from the config manager.
 func TestCreateHostBeginCancel(t *testing.T) {
@@ -2,8 +2,11 @@ package restservice
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/go-openapi/runtime/middleware"
@@ -15,28 +18,24 @@ import (
 	storkutil "isc.org/stork/util"
 )
 
-// Get tail of the specified log file.
-func (r *RestAPI) GetLogTail(ctx context.Context, params services.GetLogTailParams) middleware.Responder {
+// Fetches the log target with the specified ID from the database and checks
+// if its contents can be viewed. If the log target cannot be viewed, it
+// returns the HTTP status code and the message describing the problem.
+func (r *RestAPI) getViewableLogTarget(id int64) (*dbmodel.LogTarget, int, string) {
 	// We have ID of the log file to display. We need to get the details
 	// of the file from the database.
-	dbLogTarget, err := dbmodel.GetLogTargetByID(r.DB, params.ID)
+	dbLogTarget, err := dbmodel.GetLogTargetByID(r.DB, id)
 	if err != nil {
-		msg := fmt.Sprintf("Cannot get information about log file with ID %d from the database", params.ID)
+		msg := fmt.Sprintf("Cannot get information about log file with ID %d from the database", id)
 		log.Error(msg)
-		rsp := services.NewGetLogTailDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
-			Message: &msg,
-		})
-		return rsp
+		return nil, http.StatusInternalServerError, msg
 	}
 
 	// Handle the case when referencing the non-existing file.
 	if dbLogTarget == nil {
-		msg := fmt.Sprintf("Log file with ID %d does not exist", params.ID)
+		msg := fmt.Sprintf("Log file with ID %d does not exist", id)
 		log.Warn(msg)
-		rsp := services.NewGetLogTailDefault(http.StatusNotFound).WithPayload(&models.APIError{
-			Message: &msg,
-		})
-		return rsp
+		return nil, http.StatusNotFound, msg
 	}
 
 	// Currently we only support viewing log files.
@@ -44,7 +43,20 @@ func (r *RestAPI) GetLogTail(ctx context.Context, params services.GetLogTailPara
 		strings.HasPrefix(dbLogTarget.Output, "syslog") {
 		msg := fmt.Sprintf("Viewing log from %s is not supported", dbLogTarget.Output)
 		log.Warn(msg)
-		rsp := services.NewGetLogTailDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+		return nil, http.StatusBadRequest, msg
+	}
+
+	return dbLogTarget, http.StatusOK, ""
+}
+
+// Pattern of the URL path used to follow the log file.
+var logFollowPathPattern = regexp.MustCompile(`^/api/logs/(\d+)/follow/?$`) //nolint:gochecknoglobals
+
+// Get tail of the specified log file.
+func (r *RestAPI) GetLogTail(ctx context.Context, params services.GetLogTailParams) middleware.Responder {
+	dbLogTarget, status, msg := r.getViewableLogTarget(params.ID)
+	if dbLogTarget == nil {
+		rsp := services.NewGetLogTailDefault(status).WithPayload(&models.APIError{
 			Message: &msg,
 		})
 		return rsp
@@ -83,3 +95,78 @@ func (r *RestAPI) GetLogTail(ctx context.Context, params services.GetLogTailPara
 
 	return rsp
 }
+
+// Follows the specified log file and sends the lines appended to it to the
+// client as server-sent events (SSE). Each event holds a JSON list of lines.
+// The log file ID is taken from the /api/logs/{id}/follow path. The optional
+// maxLength, pattern and severity query parameters specify the maximum length
+// of the data sent initially from the end of the file, the regular expression
+// the lines must match and the minimum severity of the log messages. The
+// error event is sent when following the file fails.
+func (r *RestAPI) FollowLog(w http.ResponseWriter, req *http.Request) {
+	matches := logFollowPathPattern.FindStringSubmatch(req.URL.Path)
+	if matches == nil {
+		http.Error(w, "Invalid log file follow path", http.StatusNotFound)
+		return
+	}
+	id, err := strconv.ParseInt(matches[1], 10, 64)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid log file ID %s", matches[1]), http.StatusBadRequest)
+		return
+	}
+
+	dbLogTarget, status, msg := r.getViewableLogTarget(id)
+	if dbLogTarget == nil {
+		http.Error(w, msg, status)
+		return
+	}
+
+	// Set the maximum length of the data fetched initially. Default is 4000 bytes.
+	query := req.URL.Query()
+	maxLength := int64(4000)
+	if value := query.Get("maxLength"); value != "" {
+		if maxLength, err = strconv.ParseInt(value, 10, 64); err != nil || maxLength < 0 {
+			http.Error(w, fmt.Sprintf("Invalid maxLength value %s", value), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Prepare proper HTTP headers for SSE response.
+	h := w.Header()
+	h.Set("Connection", "keep-alive")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("X-Accel-Buffering", "no")
+
+	sendEvent := func(event string, data interface{}) error {
+		dataJSON, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		if event != "" {
+			fmt.Fprintf(w, "event: %s\n", event)
+		}
+		if _, err = fmt.Fprintf(w, "data: %s\n\n", dataJSON); err != nil {
+			return err
+		}
+		// Not all ResponseWriter instances implement http.Flusher interface.
+		// Test if this instance implement it before attempting to use it.
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	// The agent follows the file until the client closes the connection.
+	err = r.Agents.FollowTextFile(req.Context(), dbLogTarget.Daemon.App.Machine.Address,
+		dbLogTarget.Daemon.App.Machine.AgentPort, dbLogTarget.Output, maxLength,
+		query.Get("pattern"), query.Get("severity"), func(lines []string) error {
+			return sendEvent("", lines)
+		})
+	if err != nil {
+		log.WithError(err).WithField("file", dbLogTarget.Output).Warn("Failed to follow the log file")
+		_ = sendEvent("error", map[string]string{
+			"message": err.Error(),
+		})
+	}
+}
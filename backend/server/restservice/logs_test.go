@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -156,3 +157,81 @@ func TestLogTailBadParams(t *testing.T) {
 			*defaultRsp.Payload.Message)
 	}
 }
+
+// Test that the lines appended to the log file are sent as server-sent
+// events.
+func TestFollowLog(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	a := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Name:      "test-app",
+		Active:    true,
+		Daemons: []*dbmodel.Daemon{
+			{
+				Name:    "kea-dhcp4",
+				Version: "1.7.5",
+				Active:  true,
+				LogTargets: []*dbmodel.LogTarget{
+					{
+						Output: "/tmp/filename.log",
+					},
+					{
+						Output: "stdout",
+					},
+				},
+			},
+		},
+	}
+	_, err = dbmodel.AddApp(db, a)
+	require.NoError(t, err)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	rapi, err := NewRestAPI(dbSettings, db, fa)
+	require.NoError(t, err)
+
+	t.Run("follow log file", func(t *testing.T) {
+		url := fmt.Sprintf("http://localhost/api/logs/%d/follow?severity=info", a.Daemons[0].LogTargets[0].ID)
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		rapi.FollowLog(w, req)
+		resp := w.Result()
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+		require.Equal(t, "data: [\"lorem ipsum\"]\n\n", w.Body.String())
+	})
+
+	t.Run("non-existing log file", func(t *testing.T) {
+		url := fmt.Sprintf("http://localhost/api/logs/%d/follow", a.Daemons[0].LogTargets[1].ID+10)
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		rapi.FollowLog(w, req)
+		require.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("unsupported log target", func(t *testing.T) {
+		url := fmt.Sprintf("http://localhost/api/logs/%d/follow", a.Daemons[0].LogTargets[1].ID)
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		rapi.FollowLog(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("invalid max length", func(t *testing.T) {
+		url := fmt.Sprintf("http://localhost/api/logs/%d/follow?maxLength=-1", a.Daemons[0].LogTargets[0].ID)
+		req := httptest.NewRequest("GET", url, nil)
+		w := httptest.NewRecorder()
+		rapi.FollowLog(w, req)
+		require.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
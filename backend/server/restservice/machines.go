@@ -343,18 +343,18 @@ func (r *RestAPI) CreateMachine(ctx context.Context, params services.CreateMachi
 		})
 		return rsp
 	}
-	// TODO: consider providing a database query which returns multiple
-	// secrets to avoid multiple database roundtrips.
-	rootKeyPEM, err := dbmodel.GetSecret(r.DB, dbmodel.SecretCAKey)
+	// The agent certs are signed by the next CA during the CA rollover.
+	rootKeyPEM, rootCertPEM, err := certs.GetAgentSigningCA(r.DB)
 	if err != nil {
 		log.Error(err)
-		msg := "Problem loading server CA private key"
+		msg := "Problem loading server CA private key and cert"
 		rsp := services.NewCreateMachineDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
 			Message: &msg,
 		})
 		return rsp
 	}
-	rootCertPEM, err := dbmodel.GetSecret(r.DB, dbmodel.SecretCACert)
+	// The agent trusts both CA certs during the CA rollover.
+	caCertBundlePEM, err := certs.GetCACertBundle(r.DB)
 	if err != nil {
 		log.Error(err)
 		msg := "Problem loading server CA cert"
@@ -425,7 +425,7 @@ func (r *RestAPI) CreateMachine(ctx context.Context, params services.CreateMachi
 
 	m := &models.NewMachineResp{
 		ID:           dbMachine.ID,
-		ServerCACert: string(rootCertPEM),
+		ServerCACert: string(caCertBundlePEM),
 		AgentCert:    string(agentCertPEM),
 	}
 	rsp := services.NewCreateMachineOK().WithPayload(m)
@@ -860,7 +860,7 @@ func (r *RestAPI) appToRestAPI(dbApp *dbmodel.App) *models.App {
 		agentStats = r.Agents.GetConnectedAgentStats(dbApp.Machine.Address, dbApp.Machine.AgentPort)
 		if agentStats != nil {
 			agentErrors = agentStats.CurrentErrors
-			accessPoint, _ = dbApp.GetAccessPoint(dbmodel.AccessPointControl)
+			accessPoint, _ = dbApp.FindControlAccessPoint()
 		}
 	}
 
@@ -1141,7 +1141,9 @@ func getKeaServicesStatus(db *dbops.PgDB, app *dbmodel.App) *models.ServicesStat
 		}
 		ha := s.HAService
 		keaStatus := models.KeaStatus{
-			Daemon: ha.HAType,
+			Daemon:       ha.HAType,
+			ServiceID:    s.ID,
+			Relationship: ha.Relationship,
 		}
 		secondaryRole := "secondary"
 		if ha.HAMode == dbmodel.HAModeHotStandby {
@@ -1441,6 +1443,7 @@ func (r *RestAPI) GetDhcpOverview(ctx context.Context, params dhcp.GetDhcpOvervi
 				haEnabled   bool
 				haState     string
 				haFailureAt *strfmt.DateTime
+				haOverviews []*models.DhcpDaemonHAOverview
 			)
 			if haOverview := dbDaemon.GetHAOverview(); len(haOverview) > 0 {
 				haEnabled = true
@@ -1448,6 +1451,14 @@ func (r *RestAPI) GetDhcpOverview(ctx context.Context, params dhcp.GetDhcpOvervi
 				if !haOverview[0].LastFailureAt.IsZero() {
 					haFailureAt = convertToOptionalDatetime(haOverview[0].LastFailureAt)
 				}
+				// The daemon may have several HA relationships.
+				for _, overview := range haOverview {
+					haOverviews = append(haOverviews, &models.DhcpDaemonHAOverview{
+						Relationship: overview.Relationship,
+						HaState:      overview.State,
+						HaFailureAt:  convertToOptionalDatetime(overview.LastFailureAt),
+					})
+				}
 			}
 			agentErrors := int64(0)
 			caErrors := int64(0)
@@ -1455,7 +1466,7 @@ func (r *RestAPI) GetDhcpOverview(ctx context.Context, params dhcp.GetDhcpOvervi
 			agentStats := r.Agents.GetConnectedAgentStats(dbApp.Machine.Address, dbApp.Machine.AgentPort)
 			if agentStats != nil {
 				agentErrors = agentStats.CurrentErrors
-				accessPoint, _ := dbApp.GetAccessPoint(dbmodel.AccessPointControl)
+				accessPoint, _ := dbApp.FindControlAccessPoint()
 				if accessPoint != nil {
 					if keaStats, ok := agentStats.AppCommStats[agentcomm.AppCommStatsKey{
 						Address: accessPoint.Address,
@@ -1477,9 +1488,16 @@ func (r *RestAPI) GetDhcpOverview(ctx context.Context, params dhcp.GetDhcpOvervi
 				Monitored:        dbDaemon.Monitored,
 				Rps1:             int64(dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.RPS1),
 				Rps2:             int64(dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.RPS2),
+				NakRate1:         dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.NAKRate1,
+				NakRate2:         dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.NAKRate2,
+				DeclineRate1:     dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.DeclineRate1,
+				DeclineRate2:     dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.DeclineRate2,
+				DropRate1:        dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.DropRate1,
+				DropRate2:        dbDaemon.KeaDaemon.KeaDHCPDaemon.Stats.DropRate2,
 				HaEnabled:        haEnabled,
 				HaState:          haState,
 				HaFailureAt:      haFailureAt,
+				HaOverview:       haOverviews,
 				Uptime:           dbDaemon.Uptime,
 				AgentCommErrors:  agentErrors,
 				CaCommErrors:     caErrors,
@@ -1489,6 +1507,16 @@ func (r *RestAPI) GetDhcpOverview(ctx context.Context, params dhcp.GetDhcpOvervi
 		}
 	}
 
+	// get the lengths of the RPS intervals to describe the rates
+	rpsInterval1, err := dbmodel.GetSettingInt(r.DB, "rps_interval1")
+	if err != nil {
+		log.Warn(err)
+	}
+	rpsInterval2, err := dbmodel.GetSettingInt(r.DB, "rps_interval2")
+	if err != nil {
+		log.Warn(err)
+	}
+
 	// combine gathered information
 	overview := &models.DhcpOverview{
 		Subnets4:        subnets4,
@@ -1498,6 +1526,8 @@ func (r *RestAPI) GetDhcpOverview(ctx context.Context, params dhcp.GetDhcpOvervi
 		Dhcp4Stats:      dhcp4Stats,
 		Dhcp6Stats:      dhcp6Stats,
 		DhcpDaemons:     dhcpDaemons,
+		RpsInterval1:    rpsInterval1,
+		RpsInterval2:    rpsInterval2,
 	}
 
 	rsp := dhcp.NewGetDhcpOverviewOK().WithPayload(overview)
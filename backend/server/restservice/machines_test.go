@@ -1324,6 +1324,7 @@ func TestRestGetAppServicesStatus(t *testing.T) {
 
 	// Validate the status of the DHCPv4 pair.
 	status := statusList[0].Status.KeaStatus
+	require.EqualValues(t, keaServices[0].ID, status.ServiceID)
 	require.NotNil(t, status.HaServers)
 
 	haStatus := status.HaServers
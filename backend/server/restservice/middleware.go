@@ -145,6 +145,37 @@ func sseMiddleware(next http.Handler, eventCenter eventcenter.EventCenter) http.
 	})
 }
 
+// Install a middleware that is serving the lines appended to the log files
+// as `server-sent events` (SSE). These requests are not handled by the
+// generated REST API handler, so the middleware loads the user's session
+// and checks if the user is authorized to access the log file. The session
+// middleware is not used here because it buffers the whole response until
+// the handler returns, so the events would never be streamed.
+func logFollowMiddleware(next http.Handler, r *RestAPI) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if logFollowPathPattern.MatchString(req.URL.Path) {
+			if r.SessionManager == nil {
+				http.Error(w, "user unauthorized", http.StatusUnauthorized)
+				return
+			}
+			sessionReq, err := r.SessionManager.LoadFromRequest(req)
+			if err != nil {
+				log.WithError(err).Error("Failed to load the user session")
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if err := r.Authorizer(sessionReq); err != nil {
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			r.FollowLog(w, sessionReq)
+		} else {
+			// pass request to another handler
+			next.ServeHTTP(w, req)
+		}
+	})
+}
+
 // Install a middleware that is serving Agent installer.
 func agentInstallerMiddleware(next http.Handler, staticFilesDir string) http.Handler {
 	// Agent installer as Bash script.
@@ -314,6 +345,7 @@ func (r *RestAPI) GlobalMiddleware(handler http.Handler, staticFilesDir, baseURL
 	handler = fileServerMiddleware(handler, staticFilesDir)
 	handler = agentInstallerMiddleware(handler, staticFilesDir)
 	handler = sseMiddleware(handler, eventCenter)
+	handler = logFollowMiddleware(handler, r)
 	handler = metricsMiddleware(handler, r.MetricsCollector)
 	handler = trimBaseURLMiddleware(handler, baseURL)
 	handler = loggingMiddleware(handler)
@@ -1,6 +1,8 @@
 package restservice
 
 import (
+	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
@@ -11,8 +13,11 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
 	dbsession "isc.org/stork/server/database/session"
 	dbtest "isc.org/stork/server/database/test"
 	storktest "isc.org/stork/server/test"
@@ -93,6 +98,144 @@ func TestSSEMiddleware(t *testing.T) {
 	require.True(t, requestReceived)
 }
 
+// Check that the logFollowMiddleware rejects unauthorized requests and
+// passes other requests to the next handler.
+func TestLogFollowMiddleware(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	rapi, err := NewRestAPI(dbSettings, db)
+	require.NoError(t, err)
+
+	requestReceived := false
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestReceived = true
+	})
+
+	handler := logFollowMiddleware(nextHandler, rapi)
+
+	// The user is not logged in.
+	req := httptest.NewRequest("GET", "http://localhost/api/logs/1/follow", nil)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	resp := w.Result()
+	resp.Body.Close()
+	require.EqualValues(t, http.StatusUnauthorized, resp.StatusCode)
+	require.False(t, requestReceived)
+
+	// Other requests are forwarded to the next handler.
+	req = httptest.NewRequest("GET", "http://localhost/api/logs/1", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.True(t, requestReceived)
+}
+
+// Check that the logFollowMiddleware streams the events to the logged user
+// as soon as they are sent by the agent rather than when the request ends.
+func TestLogFollowMiddlewareStreaming(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	m := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, m)
+	require.NoError(t, err)
+
+	a := &dbmodel.App{
+		MachineID: m.ID,
+		Type:      dbmodel.AppTypeKea,
+		Daemons: []*dbmodel.Daemon{
+			{
+				Name: "kea-dhcp4",
+				LogTargets: []*dbmodel.LogTarget{
+					{
+						Output: "/tmp/filename.log",
+					},
+				},
+			},
+		},
+	}
+	_, err = dbmodel.AddApp(db, a)
+	require.NoError(t, err)
+
+	// The agent sends the second line when the first one is received
+	// by the client.
+	release := make(chan struct{})
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fa.FollowTextFileFunc = func(ctx context.Context, handler func(lines []string) error) error {
+		if err := handler([]string{"first"}); err != nil {
+			return err
+		}
+		select {
+		case <-release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		return handler([]string{"second"})
+	}
+	rapi, err := NewRestAPI(dbSettings, db, fa)
+	require.NoError(t, err)
+
+	// Log in the super admin and take the session cookie.
+	login := rapi.SessionManager.SessionMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := &dbmodel.SystemUser{
+			ID: 1,
+			Groups: []*dbmodel.SystemGroup{
+				{
+					ID: dbmodel.SuperAdminGroupID,
+				},
+			},
+		}
+		require.NoError(t, rapi.SessionManager.LoginHandler(r.Context(), user))
+	}))
+	w := httptest.NewRecorder()
+	login.ServeHTTP(w, httptest.NewRequest("GET", "http://localhost/api/users/login", nil))
+	resp := w.Result()
+	resp.Body.Close()
+	cookies := resp.Cookies()
+	require.NotEmpty(t, cookies)
+
+	nextHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	server := httptest.NewServer(logFollowMiddleware(nextHandler, rapi))
+	defer server.Close()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("%s/api/logs/%d/follow", server.URL, a.Daemons[0].LogTargets[0].ID), nil)
+	require.NoError(t, err)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	// Reads the next event from the stream.
+	reader := bufio.NewReader(resp.Body)
+	readEvent := func() string {
+		events := make(chan string, 1)
+		go func() {
+			line, _ := reader.ReadString('\n')
+			_, _ = reader.ReadString('\n')
+			events <- line
+		}()
+		select {
+		case event := <-events:
+			return event
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "timeout waiting for the event")
+			return ""
+		}
+	}
+
+	// The first event must arrive while the agent is still following the file.
+	require.Equal(t, "data: [\"first\"]\n", readEvent())
+	close(release)
+	require.Equal(t, "data: [\"second\"]\n", readEvent())
+}
+
 // Check if agentInstallerMiddleware works and handles requests correctly.
 func TestAgentInstallerMiddleware(t *testing.T) {
 	requestReceived := false
@@ -9,7 +9,9 @@ import (
 
 	"github.com/go-openapi/runtime/middleware"
 	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
 	"isc.org/stork/server/agentcomm"
+	dbmodel "isc.org/stork/server/database/model"
 	"isc.org/stork/server/gen/models"
 	"isc.org/stork/server/gen/restapi/operations/settings"
 )
@@ -21,10 +23,58 @@ type pullerMetadata interface {
 	GetInterval() int64
 	GetLastInvokedAt() time.Time
 	GetLastFinishedAt() time.Time
+	GetAppPullHealth() []agentcomm.AppPullHealth
+	IsActive() bool
+	PullNow(appID, daemonID int64)
 }
 
 var _ pullerMetadata = (*agentcomm.PeriodicPuller)(nil)
 
+// Converts the puller metadata to the REST API format.
+func newRestPuller(puller pullerMetadata) *models.Puller {
+	metadata := &models.Puller{
+		Name:           puller.GetName(),
+		ID:             puller.GetIntervalSettingName(),
+		Interval:       puller.GetInterval(),
+		LastInvokedAt:  strfmt.DateTime(puller.GetLastInvokedAt()),
+		LastFinishedAt: strfmt.DateTime(puller.GetLastFinishedAt()),
+		Apps:           []*models.PullerAppHealth{},
+	}
+	for _, health := range puller.GetAppPullHealth() {
+		metadata.Apps = append(metadata.Apps, &models.PullerAppHealth{
+			AppID:               health.AppID,
+			ConsecutiveFailures: health.ConsecutiveFailures,
+			LastError:           health.LastError,
+			LastFailureAt:       convertToOptionalDatetime(health.LastFailureAt),
+			LastSuccessAt:       convertToOptionalDatetime(health.LastSuccessAt),
+			NextAttemptAt:       convertToOptionalDatetime(health.NextAttemptAt),
+		})
+	}
+	return metadata
+}
+
+// Returns the puller with a given ID or nil if it doesn't exist.
+func (r *RestAPI) findPuller(id string) pullerMetadata {
+	v := reflect.ValueOf(*r.Pullers)
+
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !field.CanInterface() || field.IsNil() {
+			continue
+		}
+
+		puller, ok := field.Interface().(pullerMetadata)
+		if !ok {
+			continue
+		}
+
+		if puller.GetIntervalSettingName() == id {
+			return puller
+		}
+	}
+	return nil
+}
+
 // Returns a list of puller statuses.
 func (r *RestAPI) GetPullers(ctx context.Context, params settings.GetPullersParams) middleware.Responder {
 	v := reflect.ValueOf(*r.Pullers)
@@ -41,15 +91,7 @@ func (r *RestAPI) GetPullers(ctx context.Context, params settings.GetPullersPara
 			continue
 		}
 
-		metadata := &models.Puller{
-			Name:           puller.GetName(),
-			ID:             puller.GetIntervalSettingName(),
-			Interval:       puller.GetInterval(),
-			LastInvokedAt:  strfmt.DateTime(puller.GetLastInvokedAt()),
-			LastFinishedAt: strfmt.DateTime(puller.GetLastFinishedAt()),
-		}
-
-		pullers = append(pullers, metadata)
+		pullers = append(pullers, newRestPuller(puller))
 	}
 
 	rsp := settings.NewGetPullersOK().WithPayload(&models.Pullers{
@@ -61,40 +103,84 @@ func (r *RestAPI) GetPullers(ctx context.Context, params settings.GetPullersPara
 
 // Returns a specific puller status.
 func (r *RestAPI) GetPuller(ctx context.Context, params settings.GetPullerParams) middleware.Responder {
-	v := reflect.ValueOf(*r.Pullers)
+	puller := r.findPuller(params.ID)
+	if puller == nil {
+		msg := fmt.Sprintf("Cannot get puller with ID %s", params.ID)
+		rsp := settings.NewGetPullerDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
 
-	for i := 0; i < v.NumField(); i++ {
-		field := v.Field(i)
-		if !field.CanInterface() || field.IsNil() {
-			continue
-		}
+	rsp := settings.NewGetPullerOK().WithPayload(newRestPuller(puller))
+	return rsp
+}
 
-		puller, ok := field.Interface().(pullerMetadata)
-		if !ok {
-			continue
-		}
+// Requests the puller to pull the data from an app or its daemon on demand.
+// The data is pulled in the background, so the handler doesn't wait for
+// the result.
+func (r *RestAPI) PullNow(ctx context.Context, params settings.PullNowParams) middleware.Responder {
+	puller := r.findPuller(params.ID)
+	if puller == nil {
+		msg := fmt.Sprintf("Cannot get puller with ID %s", params.ID)
+		rsp := settings.NewPullNowDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
 
-		pullerID := puller.GetIntervalSettingName()
+	if !puller.IsActive() {
+		msg := fmt.Sprintf("Puller %s is disabled", params.ID)
+		rsp := settings.NewPullNowDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
 
-		if params.ID != pullerID {
-			continue
-		}
+	if params.Request == nil || params.Request.AppID == nil {
+		msg := "Missing app ID in the request"
+		rsp := settings.NewPullNowDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	appID := *params.Request.AppID
+	daemonID := params.Request.DaemonID
+
+	app, err := dbmodel.GetAppByID(r.DB, appID)
+	if err != nil {
+		log.Error(err)
+		msg := fmt.Sprintf("Cannot get app with ID %d from db", appID)
+		rsp := settings.NewPullNowDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if app == nil {
+		msg := fmt.Sprintf("Cannot find app with ID %d", appID)
+		rsp := settings.NewPullNowDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
 
-		metadata := &models.Puller{
-			Name:           puller.GetName(),
-			ID:             puller.GetIntervalSettingName(),
-			Interval:       puller.GetInterval(),
-			LastInvokedAt:  strfmt.DateTime(puller.GetLastInvokedAt()),
-			LastFinishedAt: strfmt.DateTime(puller.GetLastFinishedAt()),
+	daemonFound := daemonID == 0
+	for _, daemon := range app.Daemons {
+		if daemon.ID == daemonID {
+			daemonFound = true
+			break
 		}
-
-		rsp := settings.NewGetPullerOK().WithPayload(metadata)
+	}
+	if !daemonFound {
+		msg := fmt.Sprintf("Cannot find daemon with ID %d in app with ID %d", daemonID, appID)
+		rsp := settings.NewPullNowDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
 		return rsp
 	}
 
-	msg := fmt.Sprintf("Cannot get puller with ID %s", params.ID)
-	rsp := settings.NewGetPullerDefault(http.StatusNotFound).WithPayload(&models.APIError{
-		Message: &msg,
-	})
+	puller.PullNow(appID, daemonID)
+
+	rsp := settings.NewPullNowAccepted()
 	return rsp
 }
@@ -5,12 +5,15 @@ import (
 	"net/http"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	apps "isc.org/stork/server/apps"
 	"isc.org/stork/server/apps/bind9"
 	dbmodel "isc.org/stork/server/database/model"
 	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
 	"isc.org/stork/server/gen/restapi/operations/settings"
+	storkutil "isc.org/stork/util"
 )
 
 // Test that the puller status list is returned properly.
@@ -100,3 +103,171 @@ func TestGetNonExistPuller(t *testing.T) {
 	rspDefault := rsp.(*settings.GetPullerDefault)
 	require.Equal(t, http.StatusNotFound, getStatusCode(*rspDefault))
 }
+
+// Test that the puller status includes the health of the apps.
+func TestGetPullerAppHealth(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	rapiSettings := RestAPISettings{}
+
+	bind9Puller, _ := bind9.NewStatsPuller(db, nil, nil)
+	defer bind9Puller.Shutdown()
+	bind9Puller.ReportAppPullResult(2, nil)
+	bind9Puller.ReportAppPullResult(1, errors.New("foo"))
+	bind9Puller.ReportAppPullResult(1, errors.New("bar"))
+
+	pullers := &apps.Pullers{
+		Bind9StatsPuller: bind9Puller,
+	}
+	rapi, _ := NewRestAPI(&rapiSettings, dbSettings, db, pullers)
+
+	ctx := context.Background()
+	params := settings.GetPullerParams{
+		ID: "bind9_stats_puller_interval",
+	}
+
+	// Act
+	rsp := rapi.GetPuller(ctx, params)
+
+	// Assert
+	require.IsType(t, &settings.GetPullerOK{}, rsp)
+	appsHealth := rsp.(*settings.GetPullerOK).Payload.Apps
+	require.Len(t, appsHealth, 2)
+
+	require.EqualValues(t, 1, appsHealth[0].AppID)
+	require.EqualValues(t, 2, appsHealth[0].ConsecutiveFailures)
+	require.Equal(t, "bar", appsHealth[0].LastError)
+	require.NotNil(t, appsHealth[0].LastFailureAt)
+	require.Nil(t, appsHealth[0].LastSuccessAt)
+	require.NotNil(t, appsHealth[0].NextAttemptAt)
+
+	require.EqualValues(t, 2, appsHealth[1].AppID)
+	require.Zero(t, appsHealth[1].ConsecutiveFailures)
+	require.NotNil(t, appsHealth[1].LastSuccessAt)
+	require.Nil(t, appsHealth[1].NextAttemptAt)
+}
+
+// Test that the on-demand pull request is validated and accepted.
+func TestPullNow(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+
+	machine := &dbmodel.Machine{
+		Address:   "localhost",
+		AgentPort: 8080,
+	}
+	err := dbmodel.AddMachine(db, machine)
+	require.NoError(t, err)
+
+	app := &dbmodel.App{
+		MachineID: machine.ID,
+		Type:      dbmodel.AppTypeKea,
+		Name:      "test-app",
+		Active:    true,
+		Daemons: []*dbmodel.Daemon{
+			dbmodel.NewKeaDaemon("dhcp4", true),
+		},
+	}
+	_, err = dbmodel.AddApp(db, app)
+	require.NoError(t, err)
+
+	rapiSettings := RestAPISettings{}
+
+	bind9Puller, _ := bind9.NewStatsPuller(db, nil, nil)
+	defer bind9Puller.Shutdown()
+	pullers := &apps.Pullers{
+		Bind9StatsPuller: bind9Puller,
+	}
+	rapi, _ := NewRestAPI(&rapiSettings, dbSettings, db, pullers)
+
+	ctx := context.Background()
+
+	t.Run("accepted", func(t *testing.T) {
+		params := settings.PullNowParams{
+			ID: "bind9_stats_puller_interval",
+			Request: &models.PullNowRequest{
+				AppID:    storkutil.Ptr(app.ID),
+				DaemonID: app.Daemons[0].ID,
+			},
+		}
+		rsp := rapi.PullNow(ctx, params)
+		require.IsType(t, &settings.PullNowAccepted{}, rsp)
+	})
+
+	t.Run("non-existing puller", func(t *testing.T) {
+		params := settings.PullNowParams{
+			ID: "not_exists",
+			Request: &models.PullNowRequest{
+				AppID: storkutil.Ptr(app.ID),
+			},
+		}
+		rsp := rapi.PullNow(ctx, params)
+		require.IsType(t, &settings.PullNowDefault{}, rsp)
+		rspDefault := rsp.(*settings.PullNowDefault)
+		require.Equal(t, http.StatusNotFound, getStatusCode(*rspDefault))
+	})
+
+	t.Run("non-existing app", func(t *testing.T) {
+		params := settings.PullNowParams{
+			ID: "bind9_stats_puller_interval",
+			Request: &models.PullNowRequest{
+				AppID: storkutil.Ptr(app.ID + 1),
+			},
+		}
+		rsp := rapi.PullNow(ctx, params)
+		require.IsType(t, &settings.PullNowDefault{}, rsp)
+		rspDefault := rsp.(*settings.PullNowDefault)
+		require.Equal(t, http.StatusBadRequest, getStatusCode(*rspDefault))
+	})
+
+	t.Run("daemon of another app", func(t *testing.T) {
+		params := settings.PullNowParams{
+			ID: "bind9_stats_puller_interval",
+			Request: &models.PullNowRequest{
+				AppID:    storkutil.Ptr(app.ID),
+				DaemonID: app.Daemons[0].ID + 1,
+			},
+		}
+		rsp := rapi.PullNow(ctx, params)
+		require.IsType(t, &settings.PullNowDefault{}, rsp)
+		rspDefault := rsp.(*settings.PullNowDefault)
+		require.Equal(t, http.StatusBadRequest, getStatusCode(*rspDefault))
+	})
+}
+
+// Test that pulling the data on demand is rejected for a disabled puller.
+func TestPullNowDisabledPuller(t *testing.T) {
+	// Arrange
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+	_ = dbmodel.InitializeSettings(db, 0)
+	err := dbmodel.SetSettingInt(db, "bind9_stats_puller_interval", 0)
+	require.NoError(t, err)
+
+	bind9Puller, _ := bind9.NewStatsPuller(db, nil, nil)
+	defer bind9Puller.Shutdown()
+	pullers := &apps.Pullers{
+		Bind9StatsPuller: bind9Puller,
+	}
+	rapi, _ := NewRestAPI(&RestAPISettings{}, dbSettings, db, pullers)
+
+	params := settings.PullNowParams{
+		ID: "bind9_stats_puller_interval",
+		Request: &models.PullNowRequest{
+			AppID: storkutil.Ptr(int64(1)),
+		},
+	}
+
+	// Act
+	rsp := rapi.PullNow(context.Background(), params)
+
+	// Assert
+	require.IsType(t, &settings.PullNowDefault{}, rsp)
+	rspDefault := rsp.(*settings.PullNowDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rspDefault))
+}
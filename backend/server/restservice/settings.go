@@ -25,14 +25,21 @@ func (r *RestAPI) GetSettings(ctx context.Context, params settings.GetSettingsPa
 	}
 
 	s := &models.Settings{
-		Bind9StatsPullerInterval: dbSettingsMap["bind9_stats_puller_interval"].(int64),
-		GrafanaURL:               dbSettingsMap["grafana_url"].(string),
-		KeaHostsPullerInterval:   dbSettingsMap["kea_hosts_puller_interval"].(int64),
-		KeaStatsPullerInterval:   dbSettingsMap["kea_stats_puller_interval"].(int64),
-		KeaStatusPullerInterval:  dbSettingsMap["kea_status_puller_interval"].(int64),
-		AppsStatePullerInterval:  dbSettingsMap["apps_state_puller_interval"].(int64),
-		PrometheusURL:            dbSettingsMap["prometheus_url"].(string),
-		MetricsCollectorInterval: dbSettingsMap["metrics_collector_interval"].(int64),
+		Bind9StatsPullerInterval:   dbSettingsMap["bind9_stats_puller_interval"].(int64),
+		GrafanaURL:                 dbSettingsMap["grafana_url"].(string),
+		KeaHostsPullerInterval:     dbSettingsMap["kea_hosts_puller_interval"].(int64),
+		KeaStatsPullerInterval:     dbSettingsMap["kea_stats_puller_interval"].(int64),
+		KeaStatusPullerInterval:    dbSettingsMap["kea_status_puller_interval"].(int64),
+		AppsStatePullerInterval:    dbSettingsMap["apps_state_puller_interval"].(int64),
+		PrometheusURL:              dbSettingsMap["prometheus_url"].(string),
+		MetricsCollectorInterval:   dbSettingsMap["metrics_collector_interval"].(int64),
+		UtilizationRawRetention:    dbSettingsMap["utilization_raw_retention"].(int64),
+		UtilizationHourlyRetention: dbSettingsMap["utilization_hourly_retention"].(int64),
+		UtilizationDailyRetention:  dbSettingsMap["utilization_daily_retention"].(int64),
+		HaStatusHistoryRetention:   dbSettingsMap["ha_status_history_retention"].(int64),
+		ExhaustionForecastHorizon:  dbSettingsMap["exhaustion_forecast_horizon"].(int64),
+		RpsInterval1:               dbSettingsMap["rps_interval1"].(int64),
+		RpsInterval2:               dbSettingsMap["rps_interval2"].(int64),
 	}
 	rsp := settings.NewGetSettingsOK().WithPayload(s)
 
@@ -96,6 +103,44 @@ func (r *RestAPI) UpdateSettings(ctx context.Context, params settings.UpdateSett
 		log.Error(err)
 		return errRsp
 	}
+	err = dbmodel.SetSettingInt(r.DB, "utilization_raw_retention", s.UtilizationRawRetention)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "utilization_hourly_retention", s.UtilizationHourlyRetention)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "utilization_daily_retention", s.UtilizationDailyRetention)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "ha_status_history_retention", s.HaStatusHistoryRetention)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "exhaustion_forecast_horizon", s.ExhaustionForecastHorizon)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "rps_interval1", s.RpsInterval1)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	err = dbmodel.SetSettingInt(r.DB, "rps_interval2", s.RpsInterval2)
+	if err != nil {
+		log.Error(err)
+		return errRsp
+	}
+	if r.Pullers != nil && r.Pullers.KeaStatsPuller != nil && r.Pullers.KeaStatsPuller.RpsWorker != nil {
+		r.Pullers.KeaStatsPuller.RpsWorker.InvalidateIntervals()
+	}
 
 	rsp := settings.NewUpdateSettingsOK()
 	return rsp
@@ -0,0 +1,479 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	keaconfig "isc.org/stork/appcfg/kea"
+	"isc.org/stork/server/apps/kea"
+	"isc.org/stork/server/config"
+	dbmodel "isc.org/stork/server/database/model"
+	storkutil "isc.org/stork/util"
+
+	"isc.org/stork/server/gen/models"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+)
+
+// Converts a shared network from the REST API format to the database model.
+// The subnets belonging to the network are converted to the subnets holding
+// only their IDs. The subnets' details are fetched from the database by the
+// config manager.
+func (r *RestAPI) convertSharedNetworkFromRestAPI(restSharedNetwork *models.SharedNetwork) (*dbmodel.SharedNetwork, error) {
+	sharedNetwork := &dbmodel.SharedNetwork{
+		ID:     restSharedNetwork.ID,
+		Name:   restSharedNetwork.Name,
+		Family: int(restSharedNetwork.Universe),
+	}
+	for _, subnet := range restSharedNetwork.Subnets {
+		sharedNetwork.Subnets = append(sharedNetwork.Subnets, dbmodel.Subnet{
+			ID: subnet.ID,
+		})
+	}
+	// Convert local shared networks containing associations of the shared
+	// network with daemons.
+	for _, lsn := range restSharedNetwork.LocalSharedNetworks {
+		localSharedNetwork := &dbmodel.LocalSharedNetwork{
+			DaemonID: lsn.DaemonID,
+		}
+		if lsn.KeaConfigSharedNetworkParameters != nil && lsn.KeaConfigSharedNetworkParameters.SharedNetworkLevelParameters != nil {
+			keaParameters := lsn.KeaConfigSharedNetworkParameters.SharedNetworkLevelParameters
+			localSharedNetwork.KeaParameters = &keaconfig.SharedNetworkParameters{
+				CacheParameters: keaconfig.CacheParameters{
+					CacheThreshold: keaParameters.CacheThreshold,
+					CacheMaxAge:    keaParameters.CacheMaxAge,
+				},
+				ClientClassParameters: keaconfig.ClientClassParameters{
+					ClientClass:          storkutil.NullifyEmptyString(keaParameters.ClientClass),
+					RequireClientClasses: keaParameters.RequireClientClasses,
+				},
+				DDNSParameters: keaconfig.DDNSParameters{
+					DDNSGeneratedPrefix:       storkutil.NullifyEmptyString(keaParameters.DdnsGeneratedPrefix),
+					DDNSOverrideClientUpdate:  keaParameters.DdnsOverrideClientUpdate,
+					DDNSOverrideNoUpdate:      keaParameters.DdnsOverrideNoUpdate,
+					DDNSQualifyingSuffix:      storkutil.NullifyEmptyString(keaParameters.DdnsQualifyingSuffix),
+					DDNSReplaceClientName:     storkutil.NullifyEmptyString(keaParameters.DdnsReplaceClientName),
+					DDNSSendUpdates:           keaParameters.DdnsSendUpdates,
+					DDNSUpdateOnRenew:         keaParameters.DdnsUpdateOnRenew,
+					DDNSUseConflictResolution: keaParameters.DdnsUseConflictResolution,
+				},
+				HostnameCharParameters: keaconfig.HostnameCharParameters{
+					HostnameCharReplacement: storkutil.NullifyEmptyString(keaParameters.HostnameCharReplacement),
+					HostnameCharSet:         storkutil.NullifyEmptyString(keaParameters.HostnameCharSet),
+				},
+				PreferredLifetimeParameters: keaconfig.PreferredLifetimeParameters{
+					MaxPreferredLifetime: keaParameters.MaxPreferredLifetime,
+					MinPreferredLifetime: keaParameters.MinPreferredLifetime,
+					PreferredLifetime:    keaParameters.PreferredLifetime,
+				},
+				ReservationParameters: keaconfig.ReservationParameters{
+					ReservationMode:       storkutil.NullifyEmptyString(keaParameters.ReservationMode),
+					ReservationsGlobal:    keaParameters.ReservationsGlobal,
+					ReservationsInSubnet:  keaParameters.ReservationsInSubnet,
+					ReservationsOutOfPool: keaParameters.ReservationsOutOfPool,
+				},
+				TimerParameters: keaconfig.TimerParameters{
+					CalculateTeeTimes: keaParameters.CalculateTeeTimes,
+					RebindTimer:       keaParameters.RebindTimer,
+					RenewTimer:        keaParameters.RenewTimer,
+					T1Percent:         keaParameters.T1Percent,
+					T2Percent:         keaParameters.T2Percent,
+				},
+				ValidLifetimeParameters: keaconfig.ValidLifetimeParameters{
+					MaxValidLifetime: keaParameters.MaxValidLifetime,
+					MinValidLifetime: keaParameters.MinValidLifetime,
+					ValidLifetime:    keaParameters.ValidLifetime,
+				},
+				Allocator:         storkutil.NullifyEmptyString(keaParameters.Allocator),
+				Authoritative:     keaParameters.Authoritative,
+				BootFileName:      storkutil.NullifyEmptyString(keaParameters.BootFileName),
+				Interface:         storkutil.NullifyEmptyString(keaParameters.Interface),
+				InterfaceID:       storkutil.NullifyEmptyString(keaParameters.InterfaceID),
+				MatchClientID:     keaParameters.MatchClientID,
+				NextServer:        storkutil.NullifyEmptyString(keaParameters.NextServer),
+				PDAllocator:       storkutil.NullifyEmptyString(keaParameters.PdAllocator),
+				RapidCommit:       keaParameters.RapidCommit,
+				ServerHostname:    storkutil.NullifyEmptyString(keaParameters.ServerHostname),
+				StoreExtendedInfo: keaParameters.StoreExtendedInfo,
+			}
+			if keaParameters.Relay != nil {
+				localSharedNetwork.KeaParameters.Relay = &keaconfig.Relay{
+					IPAddresses: keaParameters.Relay.IPAddresses,
+				}
+			}
+			// DHCP options.
+			var err error
+			localSharedNetwork.DHCPOptionSet, err = r.flattenDHCPOptions("", keaParameters.Options, 0)
+			if err != nil {
+				return nil, err
+			}
+			if len(localSharedNetwork.DHCPOptionSet) > 0 {
+				localSharedNetwork.DHCPOptionSetHash = storkutil.Fnv128(localSharedNetwork.DHCPOptionSet)
+			}
+		}
+		sharedNetwork.SetLocalSharedNetwork(localSharedNetwork)
+	}
+	return sharedNetwork, nil
+}
+
+// Common function for handling the submission of the added or updated
+// shared network. It recovers the transaction context, converts the shared
+// network to the database model, applies it using the specified function
+// and commits or schedules the transaction. It returns an HTTP error code
+// and a message if an error occurs.
+func (r *RestAPI) commonCreateOrUpdateSharedNetworkSubmit(ctx context.Context, transactionID int64, restSharedNetwork *models.SharedNetwork, deadline *strfmt.DateTime, applyFunc func(context.Context, *dbmodel.SharedNetwork) (context.Context, error)) (int, string) {
+	// Make sure that the shared network information is present.
+	if restSharedNetwork == nil {
+		msg := "Shared network information not specified"
+		log.Errorf("Problem with submitting a shared network because the shared network information is missing")
+		return http.StatusBadRequest, msg
+	}
+	// Retrieve the context from the config manager.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, _ := r.ConfigManager.RecoverContext(transactionID, int64(user.ID))
+	if cctx == nil {
+		msg := "Transaction expired for the shared network update"
+		log.Errorf("Problem with recovering transaction context for transaction ID %d and user ID %d", transactionID, user.ID)
+		return http.StatusNotFound, msg
+	}
+
+	// Convert shared network information from REST API to database format.
+	sharedNetwork, err := r.convertSharedNetworkFromRestAPI(restSharedNetwork)
+	if err != nil {
+		msg := "Error parsing specified shared network"
+		log.WithError(err).Error(msg)
+		return http.StatusBadRequest, msg
+	}
+	err = sharedNetwork.PopulateDaemons(r.DB)
+	if err != nil {
+		msg := "Specified shared network is associated with daemons that no longer exist"
+		log.WithError(err).Error(err)
+		return http.StatusNotFound, msg
+	}
+	// Apply the shared network information (create Kea commands).
+	cctx, err = applyFunc(cctx, sharedNetwork)
+	if err != nil {
+		var (
+			subnetNotFound *config.SubnetNotFoundError
+			lock           *config.LockError
+		)
+		switch {
+		case errors.As(err, &subnetNotFound):
+			msg := "Unable to apply the shared network because one of its subnets cannot be found"
+			log.WithError(err).Error(msg)
+			return http.StatusBadRequest, msg
+		case errors.As(err, &lock):
+			msg := "Unable to apply the shared network because the servers' configurations may be currently edited by another user"
+			log.WithError(err).Error(msg)
+			return http.StatusLocked, msg
+		default:
+			msg := fmt.Sprintf("Problem with applying shared network information: %s", err)
+			log.WithError(err).Error(msg)
+			return http.StatusInternalServerError, msg
+		}
+	}
+	// Schedule sending the commands to Kea servers if the deadline is specified.
+	if deadline != nil {
+		if !time.Time(*deadline).After(storkutil.UTCNow()) {
+			msg := "Deadline for the scheduled shared network change must be in the future"
+			log.Error(msg)
+			return http.StatusBadRequest, msg
+		}
+		cctx, err = r.ConfigManager.Schedule(cctx, time.Time(*deadline))
+		if err != nil {
+			msg := fmt.Sprintf("Problem with scheduling shared network information: %s", err)
+			log.WithError(err).Error(msg)
+			return http.StatusInternalServerError, msg
+		}
+		r.ConfigManager.Done(cctx)
+		return 0, ""
+	}
+	// Send the commands to Kea servers.
+	cctx, err = r.ConfigManager.Commit(cctx)
+	if err != nil {
+		msg := fmt.Sprintf("Problem with committing shared network information: %s", err)
+		log.WithError(err).Error(msg)
+		return http.StatusConflict, msg
+	}
+	// Everything ok. Cleanup and send OK to the client.
+	r.ConfigManager.Done(cctx)
+	return 0, ""
+}
+
+// Common function for handling the cancellation of the transaction adding
+// or updating a shared network. It removes the transaction from the config
+// manager, if the transaction exists.
+func (r *RestAPI) commonCreateOrUpdateSharedNetworkDelete(ctx context.Context, transactionID int64) (int, string) {
+	// Retrieve the context from the config manager.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, _ := r.ConfigManager.RecoverContext(transactionID, int64(user.ID))
+	if cctx == nil {
+		msg := "Transaction expired for the shared network update"
+		log.Errorf("Problem with recovering transaction context for transaction ID %d and user ID %d", transactionID, user.ID)
+		return http.StatusNotFound, msg
+	}
+	r.ConfigManager.Done(cctx)
+	return 0, ""
+}
+
+// Implements the POST call to create new transaction for adding a new
+// shared network (shared-networks/new/transaction).
+func (r *RestAPI) CreateSharedNetworkBegin(ctx context.Context, params dhcp.CreateSharedNetworkBeginParams) middleware.Responder {
+	// The same daemons are selected for the shared networks as for the
+	// subnets, i.e., the daemons with the subnet_cmds hooks library.
+	respDaemons, cctx, code, msg := r.commonCreateOrUpdateSubnetBegin(ctx)
+	if code != 0 {
+		// Error case.
+		rsp := dhcp.NewCreateSharedNetworkBeginDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Begin shared network add transaction.
+	var err error
+	if cctx, err = r.ConfigManager.GetKeaModule().BeginSharedNetworkAdd(cctx); err != nil {
+		msg := "Problem with initializing transaction for creating shared network"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewCreateSharedNetworkBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	// Retrieve the generated context ID.
+	cctxID, ok := config.GetValueAsInt64(cctx, config.ContextIDKey)
+	if !ok {
+		msg := "Problem with retrieving context ID for a transaction to create shared network"
+		log.Error(msg)
+		rsp := dhcp.NewCreateSharedNetworkBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Remember the context, i.e. new transaction has been successfully created.
+	_ = r.ConfigManager.RememberContext(cctx, time.Minute*10)
+
+	// Return transaction ID and daemons to the user.
+	contents := &models.CreateSharedNetworkBeginResponse{
+		ID:      cctxID,
+		Daemons: respDaemons,
+	}
+	rsp := dhcp.NewCreateSharedNetworkBeginOK().WithPayload(contents)
+	return rsp
+}
+
+// Implements the POST call to apply and commit a new shared network
+// (shared-networks/new/transaction/{id}/submit).
+func (r *RestAPI) CreateSharedNetworkSubmit(ctx context.Context, params dhcp.CreateSharedNetworkSubmitParams) middleware.Responder {
+	if code, msg := r.commonCreateOrUpdateSharedNetworkSubmit(ctx, params.ID, params.SharedNetwork, params.Deadline, r.ConfigManager.GetKeaModule().ApplySharedNetworkAdd); code != 0 {
+		// Error case.
+		rsp := dhcp.NewCreateSharedNetworkSubmitDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewCreateSharedNetworkSubmitOK()
+	return rsp
+}
+
+// Implements the DELETE call to cancel adding new shared network
+// (shared-networks/new/transaction/{id}). It removes the specified
+// transaction from the config manager, if the transaction exists.
+func (r *RestAPI) CreateSharedNetworkDelete(ctx context.Context, params dhcp.CreateSharedNetworkDeleteParams) middleware.Responder {
+	if code, msg := r.commonCreateOrUpdateSharedNetworkDelete(ctx, params.ID); code != 0 {
+		// Error case.
+		rsp := dhcp.NewCreateSharedNetworkDeleteDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewCreateSharedNetworkDeleteOK()
+	return rsp
+}
+
+// Implements the POST call to create new transaction for updating an
+// existing shared network (shared-networks/{sharedNetworkId}/transaction).
+func (r *RestAPI) UpdateSharedNetworkBegin(ctx context.Context, params dhcp.UpdateSharedNetworkBeginParams) middleware.Responder {
+	respDaemons, cctx, code, msg := r.commonCreateOrUpdateSubnetBegin(ctx)
+	if code != 0 {
+		// Error case.
+		rsp := dhcp.NewUpdateSharedNetworkBeginDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Begin shared network update transaction. It retrieves current shared
+	// network information and locks daemons for updates.
+	var err error
+	cctx, err = r.ConfigManager.GetKeaModule().BeginSharedNetworkUpdate(cctx, params.SharedNetworkID)
+	if err != nil {
+		var (
+			sharedNetworkNotFound *config.SharedNetworkNotFoundError
+			lock                  *config.LockError
+		)
+		switch {
+		case errors.As(err, &sharedNetworkNotFound):
+			// Failed to find shared network.
+			msg := fmt.Sprintf("Unable to edit the shared network with ID %d because it cannot be found", params.SharedNetworkID)
+			log.Error(msg)
+			rsp := dhcp.NewUpdateSharedNetworkBeginDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		case errors.As(err, &lock):
+			// Failed to lock daemons.
+			msg := fmt.Sprintf("Unable to edit the shared network with ID %d because it may be currently edited by another user", params.SharedNetworkID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewUpdateSharedNetworkBeginDefault(http.StatusLocked).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		default:
+			// Other error.
+			msg := fmt.Sprintf("Problem with initializing transaction for an update of the shared network with ID %d", params.SharedNetworkID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewUpdateSharedNetworkBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+	}
+	state, _ := config.GetTransactionState[kea.ConfigRecipe](cctx)
+	sharedNetwork := state.Updates[0].Recipe.SharedNetworkBeforeUpdate
+
+	// Retrieve the generated context ID.
+	cctxID, ok := config.GetValueAsInt64(cctx, config.ContextIDKey)
+	if !ok {
+		msg := "problem with retrieving context ID for a transaction to update a shared network"
+		log.Error(msg)
+		rsp := dhcp.NewUpdateSharedNetworkBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Remember the context, i.e. new transaction has been successfully created.
+	_ = r.ConfigManager.RememberContext(cctx, time.Minute*10)
+
+	// Return transaction ID, shared network and daemons to the user.
+	contents := &models.UpdateSharedNetworkBeginResponse{
+		ID:            cctxID,
+		SharedNetwork: r.sharedNetworkToRestAPI(sharedNetwork),
+		Daemons:       respDaemons,
+	}
+	rsp := dhcp.NewUpdateSharedNetworkBeginOK().WithPayload(contents)
+	return rsp
+}
+
+// Implements the POST call and commits an updated shared network
+// (shared-networks/{sharedNetworkId}/transaction/{id}/submit).
+func (r *RestAPI) UpdateSharedNetworkSubmit(ctx context.Context, params dhcp.UpdateSharedNetworkSubmitParams) middleware.Responder {
+	if code, msg := r.commonCreateOrUpdateSharedNetworkSubmit(ctx, params.ID, params.SharedNetwork, params.Deadline, r.ConfigManager.GetKeaModule().ApplySharedNetworkUpdate); code != 0 {
+		// Error case.
+		rsp := dhcp.NewUpdateSharedNetworkSubmitDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewUpdateSharedNetworkSubmitOK()
+	return rsp
+}
+
+// Implements the DELETE call to cancel updating a shared network
+// (shared-networks/{sharedNetworkId}/transaction/{id}). It removes the
+// specified transaction from the config manager, if the transaction exists.
+func (r *RestAPI) UpdateSharedNetworkDelete(ctx context.Context, params dhcp.UpdateSharedNetworkDeleteParams) middleware.Responder {
+	if code, msg := r.commonCreateOrUpdateSharedNetworkDelete(ctx, params.ID); code != 0 {
+		// Error case.
+		rsp := dhcp.NewUpdateSharedNetworkDeleteDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewUpdateSharedNetworkDeleteOK()
+	return rsp
+}
+
+// Implements the DELETE call for a shared network (shared-networks/{id}). It
+// sends suitable commands to the Kea servers owning the shared network and
+// deletes the shared network from the database. The subnets belonging to
+// the shared network become top-level subnets.
+func (r *RestAPI) DeleteSharedNetwork(ctx context.Context, params dhcp.DeleteSharedNetworkParams) middleware.Responder {
+	// Create configuration context.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, err := r.ConfigManager.CreateContext(int64(user.ID))
+	if err != nil {
+		msg := "Problem with creating transaction context"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Begin shared network delete transaction. It retrieves current shared
+	// network information and locks daemons for updates.
+	cctx, err = r.ConfigManager.GetKeaModule().BeginSharedNetworkDelete(cctx, params.ID)
+	defer r.ConfigManager.Done(cctx)
+	if err != nil {
+		var (
+			sharedNetworkNotFound *config.SharedNetworkNotFoundError
+			lock                  *config.LockError
+		)
+		switch {
+		case errors.As(err, &sharedNetworkNotFound):
+			// Failed to find shared network.
+			msg := fmt.Sprintf("Cannot find shared network with ID %d", params.ID)
+			log.Error(msg)
+			rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusNotFound).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		case errors.As(err, &lock):
+			// Failed to lock daemons.
+			msg := fmt.Sprintf("Unable to delete the shared network with ID %d because it may be currently edited by another user", params.ID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusLocked).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		default:
+			// Other error.
+			msg := fmt.Sprintf("Problem with initializing transaction for deleting the shared network with ID %d", params.ID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+	}
+	state, _ := config.GetTransactionState[kea.ConfigRecipe](cctx)
+	sharedNetwork := state.Updates[0].Recipe.SharedNetworkBeforeUpdate
+
+	// Create Kea commands to delete the shared network.
+	cctx, err = r.ConfigManager.GetKeaModule().ApplySharedNetworkDelete(cctx, sharedNetwork)
+	if err != nil {
+		msg := "Problem with preparing commands for deleting the shared network"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Send the commands to Kea servers.
+	cctx, err = r.ConfigManager.Commit(cctx)
+	if err != nil {
+		msg := fmt.Sprintf("Problem with deleting the shared network: %s", err)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewDeleteSharedNetworkDefault(http.StatusConflict).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Send OK to the client.
+	rsp := dhcp.NewDeleteSharedNetworkOK()
+	return rsp
+}
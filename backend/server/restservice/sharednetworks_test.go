@@ -0,0 +1,391 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	"isc.org/stork/server/apps"
+	"isc.org/stork/server/apps/kea"
+	appstest "isc.org/stork/server/apps/test"
+	dbops "isc.org/stork/server/database"
+	dbmodel "isc.org/stork/server/database/model"
+	dbmodeltest "isc.org/stork/server/database/model/test"
+	dbtest "isc.org/stork/server/database/test"
+	"isc.org/stork/server/gen/models"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storktest "isc.org/stork/server/test/dbmodel"
+	storkutil "isc.org/stork/util"
+)
+
+// Adds two Kea servers with the subnet_cmds hooks library, a shared network
+// and a top-level subnet. Then, it creates the REST API with the config
+// manager and the logged user. It returns the fake agents receiving the
+// commands, the REST API instance, the context with the user session, and
+// the added apps.
+func prepareSharedNetworkTest(t *testing.T, db *dbops.PgDB, dbSettings *dbops.DatabaseSettings) (*agentcommtest.FakeAgents, *RestAPI, context.Context, []dbmodel.App) {
+	serverConfig := `{
+		"Dhcp4": {
+			"shared-networks": [
+				{
+					"name": "foo",
+					"subnet4": [
+						{
+							"id": 1,
+							"subnet": "192.0.2.0/24"
+						}
+					]
+				}
+			],
+			"subnet4": [
+				{
+					"id": 2,
+					"subnet": "192.0.3.0/24"
+				}
+			],
+			"hooks-libraries": [
+				{
+					"library": "libdhcp_subnet_cmds"
+				}
+			]
+		}
+	}`
+
+	for i := 0; i < 2; i++ {
+		server, err := dbmodeltest.NewKeaDHCPv4Server(db)
+		require.NoError(t, err)
+		err = server.Configure(serverConfig)
+		require.NoError(t, err)
+
+		app, err := server.GetKea()
+		require.NoError(t, err)
+
+		err = kea.CommitAppIntoDB(db, app, &storktest.FakeEventCenter{}, nil, dbmodel.NewDHCPOptionDefinitionLookup())
+		require.NoError(t, err)
+	}
+
+	dbapps, err := dbmodel.GetAllApps(db, true)
+	require.NoError(t, err)
+	require.Len(t, dbapps, 2)
+
+	// Create fake agents receiving commands.
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	require.NotNil(t, fa)
+
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	require.NotNil(t, lookup)
+
+	// Create the config manager.
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	require.NotNil(t, cm)
+
+	// Create API.
+	rapi, err := NewRestAPI(dbSettings, db, fa, cm, lookup)
+	require.NoError(t, err)
+
+	// Create session manager.
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Create user session.
+	user := &dbmodel.SystemUser{
+		ID: 1234,
+	}
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	return fa, rapi, ctx, dbapps
+}
+
+// Test the calls for creating transaction and submitting a new shared
+// network with a subnet moved from the top level.
+func TestCreateSharedNetworkBeginSubmit(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, dbapps := prepareSharedNetworkTest(t, db, dbSettings)
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.3.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+
+	// Begin transaction.
+	rsp := rapi.CreateSharedNetworkBegin(ctx, dhcp.CreateSharedNetworkBeginParams{})
+	require.IsType(t, &dhcp.CreateSharedNetworkBeginOK{}, rsp)
+	okRsp := rsp.(*dhcp.CreateSharedNetworkBeginOK)
+	contents := okRsp.Payload
+
+	// Make sure the server returned transaction ID and daemons.
+	transactionID := contents.ID
+	require.NotZero(t, transactionID)
+	require.Len(t, contents.Daemons, 2)
+
+	// Submit transaction.
+	params := dhcp.CreateSharedNetworkSubmitParams{
+		ID: transactionID,
+		SharedNetwork: &models.SharedNetwork{
+			Name:     "bar",
+			Universe: 4,
+			Subnets: []*models.Subnet{
+				{
+					ID: subnets[0].ID,
+				},
+			},
+			LocalSharedNetworks: []*models.LocalSharedNetwork{
+				{
+					DaemonID: dbapps[0].Daemons[0].ID,
+					KeaConfigSharedNetworkParameters: &models.KeaConfigSharedNetworkParameters{
+						SharedNetworkLevelParameters: &models.KeaConfigSubnetDerivedParameters{
+							KeaConfigValidLifetimeParameters: models.KeaConfigValidLifetimeParameters{
+								ValidLifetime: storkutil.Ptr[int64](3600),
+							},
+						},
+					},
+				},
+				{
+					DaemonID: dbapps[1].Daemons[0].ID,
+					KeaConfigSharedNetworkParameters: &models.KeaConfigSharedNetworkParameters{
+						SharedNetworkLevelParameters: &models.KeaConfigSubnetDerivedParameters{
+							KeaConfigValidLifetimeParameters: models.KeaConfigValidLifetimeParameters{
+								ValidLifetime: storkutil.Ptr[int64](3600),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	rsp2 := rapi.CreateSharedNetworkSubmit(ctx, params)
+	require.IsType(t, &dhcp.CreateSharedNetworkSubmitOK{}, rsp2)
+
+	// Each server should receive the network4-add, network4-subnet-add and
+	// config-write commands.
+	require.Len(t, fa.RecordedCommands, 6)
+
+	for i, c := range fa.RecordedCommands {
+		switch {
+		case i < 2:
+			require.JSONEq(t,
+				`{
+				"command": "network4-add",
+				"service": [ "dhcp4" ],
+				"arguments": {
+					"shared-networks": [
+						{
+							"name": "bar",
+							"valid-lifetime": 3600
+						}
+					]
+				}
+			}`,
+				c.Marshal())
+		case i < 4:
+			require.JSONEq(t,
+				`{
+				"command": "network4-subnet-add",
+				"service": [ "dhcp4" ],
+				"arguments": {
+					"id": 2,
+					"name": "bar"
+				}
+			}`,
+				c.Marshal())
+		default:
+			require.JSONEq(t,
+				`{
+				"command": "config-write",
+				"service": [ "dhcp4" ]
+			}`,
+				c.Marshal())
+		}
+	}
+
+	// The new shared network should be in the database and include the subnet.
+	subnets, err = dbmodel.GetSubnetsByPrefix(db, "192.0.3.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	require.NotZero(t, subnets[0].SharedNetworkID)
+
+	network, err := dbmodel.GetSharedNetwork(db, subnets[0].SharedNetworkID)
+	require.NoError(t, err)
+	require.NotNil(t, network)
+	require.Equal(t, "bar", network.Name)
+	require.Len(t, network.LocalSharedNetworks, 2)
+}
+
+// Test that submitting a shared network with the name of an existing shared
+// network fails and the transaction can be canceled.
+func TestCreateSharedNetworkSubmitDuplicateAndCancel(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	_, rapi, ctx, dbapps := prepareSharedNetworkTest(t, db, dbSettings)
+
+	rsp := rapi.CreateSharedNetworkBegin(ctx, dhcp.CreateSharedNetworkBeginParams{})
+	require.IsType(t, &dhcp.CreateSharedNetworkBeginOK{}, rsp)
+	transactionID := rsp.(*dhcp.CreateSharedNetworkBeginOK).Payload.ID
+
+	// No shared network information.
+	rsp2 := rapi.CreateSharedNetworkSubmit(ctx, dhcp.CreateSharedNetworkSubmitParams{
+		ID: transactionID,
+	})
+	require.IsType(t, &dhcp.CreateSharedNetworkSubmitDefault{}, rsp2)
+	defaultRsp := rsp2.(*dhcp.CreateSharedNetworkSubmitDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// Duplicate name.
+	rsp2 = rapi.CreateSharedNetworkSubmit(ctx, dhcp.CreateSharedNetworkSubmitParams{
+		ID: transactionID,
+		SharedNetwork: &models.SharedNetwork{
+			Name:     "foo",
+			Universe: 4,
+			LocalSharedNetworks: []*models.LocalSharedNetwork{
+				{
+					DaemonID: dbapps[0].Daemons[0].ID,
+				},
+			},
+		},
+	})
+	require.IsType(t, &dhcp.CreateSharedNetworkSubmitDefault{}, rsp2)
+	defaultRsp = rsp2.(*dhcp.CreateSharedNetworkSubmitDefault)
+	require.Equal(t, http.StatusInternalServerError, getStatusCode(*defaultRsp))
+
+	// Cancel the transaction.
+	rsp3 := rapi.CreateSharedNetworkDelete(ctx, dhcp.CreateSharedNetworkDeleteParams{
+		ID: transactionID,
+	})
+	require.IsType(t, &dhcp.CreateSharedNetworkDeleteOK{}, rsp3)
+
+	// The transaction no longer exists.
+	rsp3 = rapi.CreateSharedNetworkDelete(ctx, dhcp.CreateSharedNetworkDeleteParams{
+		ID: transactionID,
+	})
+	require.IsType(t, &dhcp.CreateSharedNetworkDeleteDefault{}, rsp3)
+	defaultRsp2 := rsp3.(*dhcp.CreateSharedNetworkDeleteDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp2))
+}
+
+// Test the calls for creating transaction and submitting an updated shared
+// network. The top-level subnet is moved to the shared network.
+func TestUpdateSharedNetworkBeginSubmit(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, dbapps := prepareSharedNetworkTest(t, db, dbSettings)
+
+	networks, err := dbmodel.GetAllSharedNetworks(db, 4)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+
+	// Non-existing shared network.
+	rsp := rapi.UpdateSharedNetworkBegin(ctx, dhcp.UpdateSharedNetworkBeginParams{
+		SharedNetworkID: networks[0].ID + 1,
+	})
+	require.IsType(t, &dhcp.UpdateSharedNetworkBeginDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.UpdateSharedNetworkBeginDefault)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*defaultRsp))
+
+	// Begin transaction.
+	rsp = rapi.UpdateSharedNetworkBegin(ctx, dhcp.UpdateSharedNetworkBeginParams{
+		SharedNetworkID: networks[0].ID,
+	})
+	require.IsType(t, &dhcp.UpdateSharedNetworkBeginOK{}, rsp)
+	okRsp := rsp.(*dhcp.UpdateSharedNetworkBeginOK)
+	contents := okRsp.Payload
+
+	// Make sure the server returned transaction ID, shared network and daemons.
+	transactionID := contents.ID
+	require.NotZero(t, transactionID)
+	require.NotNil(t, contents.SharedNetwork)
+	require.Equal(t, "foo", contents.SharedNetwork.Name)
+	require.Len(t, contents.SharedNetwork.Subnets, 1)
+	require.Len(t, contents.Daemons, 2)
+
+	globalSubnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.3.0/24")
+	require.NoError(t, err)
+	require.Len(t, globalSubnets, 1)
+
+	// Submit transaction.
+	sharedNetwork := contents.SharedNetwork
+	sharedNetwork.Subnets = append(sharedNetwork.Subnets, &models.Subnet{
+		ID: globalSubnets[0].ID,
+	})
+	params := dhcp.UpdateSharedNetworkSubmitParams{
+		SharedNetworkID: networks[0].ID,
+		ID:              transactionID,
+		SharedNetwork:   sharedNetwork,
+	}
+	rsp2 := rapi.UpdateSharedNetworkSubmit(ctx, params)
+	require.IsType(t, &dhcp.UpdateSharedNetworkSubmitOK{}, rsp2)
+
+	// Each server should receive the network4-del, network4-add, two
+	// network4-subnet-add and config-write commands.
+	require.Len(t, fa.RecordedCommands, 10)
+	require.Equal(t, "network4-del", fa.RecordedCommands[0].GetCommand())
+	require.Equal(t, "network4-add", fa.RecordedCommands[2].GetCommand())
+	require.Equal(t, "network4-subnet-add", fa.RecordedCommands[4].GetCommand())
+	require.Equal(t, "config-write", fa.RecordedCommands[9].GetCommand())
+
+	// Both subnets should belong to the shared network.
+	network, err := dbmodel.GetSharedNetwork(db, networks[0].ID)
+	require.NoError(t, err)
+	require.NotNil(t, network)
+	require.Len(t, network.Subnets, 2)
+	require.Len(t, network.LocalSharedNetworks, len(dbapps))
+}
+
+// Test that the shared network is deleted from the Kea servers and the database.
+func TestDeleteSharedNetwork(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa, rapi, ctx, _ := prepareSharedNetworkTest(t, db, dbSettings)
+
+	networks, err := dbmodel.GetAllSharedNetworks(db, 4)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+
+	// Non-existing shared network.
+	rsp := rapi.DeleteSharedNetwork(ctx, dhcp.DeleteSharedNetworkParams{
+		ID: networks[0].ID + 1,
+	})
+	require.IsType(t, &dhcp.DeleteSharedNetworkDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.DeleteSharedNetworkDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+	require.Empty(t, fa.RecordedCommands)
+
+	// Existing shared network.
+	rsp = rapi.DeleteSharedNetwork(ctx, dhcp.DeleteSharedNetworkParams{
+		ID: networks[0].ID,
+	})
+	require.IsType(t, &dhcp.DeleteSharedNetworkOK{}, rsp)
+
+	require.Len(t, fa.RecordedCommands, 4)
+	require.JSONEq(t,
+		`{
+			"command": "network4-del",
+			"service": [ "dhcp4" ],
+			"arguments": {
+				"name": "foo",
+				"subnets-action": "keep"
+			}
+		}`,
+		fa.RecordedCommands[0].Marshal())
+
+	// The shared network should be gone from the database but its subnet
+	// should be kept.
+	returned, err := dbmodel.GetSharedNetwork(db, networks[0].ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.2.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	require.Zero(t, subnets[0].SharedNetworkID)
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	keaconfig "isc.org/stork/appcfg/kea"
@@ -29,6 +30,10 @@ func (r *RestAPI) convertSubnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 		PdUtilization:    float64(sn.PdUtilization) / 10,
 		Stats:            sn.Stats,
 		StatsCollectedAt: convertToOptionalDatetime(sn.StatsCollectedAt),
+		AddrExhaustionAt: convertToOptionalDatetime(sn.AddrExhaustionAt),
+		PdExhaustionAt:   convertToOptionalDatetime(sn.PdExhaustionAt),
+		LeaseRate1:       sn.LeaseRate1,
+		LeaseRate2:       sn.LeaseRate2,
 	}
 
 	if sn.SharedNetwork != nil {
@@ -49,7 +54,11 @@ func (r *RestAPI) convertSubnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 		}
 		for _, poolDetails := range lsn.AddressPools {
 			pool := &models.Pool{
-				Pool: storkutil.Ptr(poolDetails.LowerBound + "-" + poolDetails.UpperBound),
+				Pool:             storkutil.Ptr(poolDetails.LowerBound + "-" + poolDetails.UpperBound),
+				Utilization:      float64(poolDetails.Utilization) / 10,
+				Stats:            poolDetails.Stats,
+				StatsCollectedAt: convertToOptionalDatetime(poolDetails.StatsCollectedAt),
+				ExhaustionAt:     convertToOptionalDatetime(poolDetails.ExhaustionAt),
 			}
 			if poolDetails.KeaParameters != nil {
 				pool.KeaConfigPoolParameters = &models.KeaConfigPoolParameters{
@@ -70,9 +79,13 @@ func (r *RestAPI) convertSubnetToRestAPI(sn *dbmodel.Subnet) *models.Subnet {
 			prefix := prefixPoolDetails.Prefix
 			delegatedLength := int64(prefixPoolDetails.DelegatedLen)
 			pool := &models.DelegatedPrefixPool{
-				Prefix:          &prefix,
-				DelegatedLength: &delegatedLength,
-				ExcludedPrefix:  prefixPoolDetails.ExcludedPrefix,
+				Prefix:           &prefix,
+				DelegatedLength:  &delegatedLength,
+				ExcludedPrefix:   prefixPoolDetails.ExcludedPrefix,
+				Utilization:      float64(prefixPoolDetails.Utilization) / 10,
+				Stats:            prefixPoolDetails.Stats,
+				StatsCollectedAt: convertToOptionalDatetime(prefixPoolDetails.StatsCollectedAt),
+				ExhaustionAt:     convertToOptionalDatetime(prefixPoolDetails.ExhaustionAt),
 			}
 			localSubnet.PrefixDelegationPools = append(localSubnet.PrefixDelegationPools, pool)
 			if prefixPoolDetails.KeaParameters != nil {
@@ -577,6 +590,10 @@ func (r *RestAPI) sharedNetworkToRestAPI(sn *dbmodel.SharedNetwork) *models.Shar
 		PdUtilization:    float64(sn.PdUtilization) / 10,
 		Stats:            sn.Stats,
 		StatsCollectedAt: convertToOptionalDatetime(sn.StatsCollectedAt),
+		AddrExhaustionAt: convertToOptionalDatetime(sn.AddrExhaustionAt),
+		PdExhaustionAt:   convertToOptionalDatetime(sn.PdExhaustionAt),
+		LeaseRate1:       sn.LeaseRate1,
+		LeaseRate2:       sn.LeaseRate2,
 	}
 
 	for _, lsn := range sn.LocalSharedNetworks {
@@ -880,11 +897,12 @@ func (r *RestAPI) commonCreateOrUpdateSubnetBegin(ctx context.Context) ([]*model
 // or ApplySubnetUpdate, depending on whether the new subnet is created (via
 // CreateSubnetSubmit) or updated (via UpdateSubnetSubmit). The apply functions
 // receive the transaction context and a pointer to the subnet. They return the
-// updated context and error. This function returns the HTTP error code if an
-// error occurs or 0 when there is no error. In addition it returns an error
-// string to be included in the HTTP response or an empty string if there is no
-// error.
-func (r *RestAPI) commonCreateOrUpdateSubnetSubmit(ctx context.Context, transactionID int64, restSubnet *models.Subnet, applyFunc func(context.Context, *dbmodel.Subnet) (context.Context, error)) (int, string) {
+// updated context and error. If the deadline is specified, the changes are
+// scheduled for committing at this time rather than committed right away.
+// This function returns the HTTP error code if an error occurs or 0 when there
+// is no error. In addition it returns an error string to be included in the
+// HTTP response or an empty string if there is no error.
+func (r *RestAPI) commonCreateOrUpdateSubnetSubmit(ctx context.Context, transactionID int64, restSubnet *models.Subnet, deadline *strfmt.DateTime, applyFunc func(context.Context, *dbmodel.Subnet) (context.Context, error)) (int, string) {
 	// Make sure that the subnet information is present.
 	if restSubnet == nil {
 		msg := "Subnet information not specified"
@@ -916,10 +934,32 @@ func (r *RestAPI) commonCreateOrUpdateSubnetSubmit(ctx context.Context, transact
 	// Apply the subnet information (create Kea commands).
 	cctx, err = applyFunc(cctx, subnet)
 	if err != nil {
+		var lock *config.LockError
+		if errors.As(err, &lock) {
+			msg := "Unable to apply the subnet because the servers' configurations may be currently edited by another user"
+			log.WithError(err).Error(msg)
+			return http.StatusLocked, msg
+		}
 		msg := "Problem with applying subnet information"
 		log.WithError(err).Error(msg)
 		return http.StatusInternalServerError, msg
 	}
+	// Schedule sending the commands to Kea servers if the deadline is specified.
+	if deadline != nil {
+		if !time.Time(*deadline).After(storkutil.UTCNow()) {
+			msg := "Deadline for the scheduled subnet change must be in the future"
+			log.Error(msg)
+			return http.StatusBadRequest, msg
+		}
+		cctx, err = r.ConfigManager.Schedule(cctx, time.Time(*deadline))
+		if err != nil {
+			msg := fmt.Sprintf("Problem with scheduling subnet information: %s", err)
+			log.WithError(err).Error(msg)
+			return http.StatusInternalServerError, msg
+		}
+		r.ConfigManager.Done(cctx)
+		return 0, ""
+	}
 	// Send the commands to Kea servers.
 	cctx, err = r.ConfigManager.Commit(cctx)
 	if err != nil {
@@ -951,6 +991,79 @@ func (r *RestAPI) commonCreateOrUpdateSubnetDelete(ctx context.Context, transact
 	return 0, ""
 }
 
+// Implements the POST call to create new transaction for adding a new
+// subnet (subnets/new/transaction).
+func (r *RestAPI) CreateSubnetBegin(ctx context.Context, params dhcp.CreateSubnetBeginParams) middleware.Responder {
+	// Execute the common part between create and update operations. It retrieves
+	// the daemons and creates a transaction context.
+	respDaemons, cctx, code, msg := r.commonCreateOrUpdateSubnetBegin(ctx)
+	if code != 0 {
+		// Error case.
+		rsp := dhcp.NewCreateSubnetBeginDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Begin subnet add transaction.
+	var err error
+	if cctx, err = r.ConfigManager.GetKeaModule().BeginSubnetAdd(cctx); err != nil {
+		msg := "Problem with initializing transaction for creating subnet"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewCreateSubnetBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	// Retrieve the generated context ID.
+	cctxID, ok := config.GetValueAsInt64(cctx, config.ContextIDKey)
+	if !ok {
+		msg := "Problem with retrieving context ID for a transaction to create subnet"
+		log.Error(msg)
+		rsp := dhcp.NewCreateSubnetBeginDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Remember the context, i.e. new transaction has been successfully created.
+	_ = r.ConfigManager.RememberContext(cctx, time.Minute*10)
+
+	// Return transaction ID and daemons to the user.
+	contents := &models.CreateSubnetBeginResponse{
+		ID:      cctxID,
+		Daemons: respDaemons,
+	}
+	rsp := dhcp.NewCreateSubnetBeginOK().WithPayload(contents)
+	return rsp
+}
+
+// Implements the POST call to apply and commit a new subnet (subnets/new/transaction/{id}/submit).
+func (r *RestAPI) CreateSubnetSubmit(ctx context.Context, params dhcp.CreateSubnetSubmitParams) middleware.Responder {
+	if code, msg := r.commonCreateOrUpdateSubnetSubmit(ctx, params.ID, params.Subnet, params.Deadline, r.ConfigManager.GetKeaModule().ApplySubnetAdd); code != 0 {
+		// Error case.
+		rsp := dhcp.NewCreateSubnetSubmitDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewCreateSubnetSubmitOK()
+	return rsp
+}
+
+// Implements the DELETE call to cancel adding new subnet (subnets/new/transaction/{id}).
+// It removes the specified transaction from the config manager, if the transaction exists.
+func (r *RestAPI) CreateSubnetDelete(ctx context.Context, params dhcp.CreateSubnetDeleteParams) middleware.Responder {
+	if code, msg := r.commonCreateOrUpdateSubnetDelete(ctx, params.ID); code != 0 {
+		// Error case.
+		rsp := dhcp.NewCreateSubnetDeleteDefault(code).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	rsp := dhcp.NewCreateSubnetDeleteOK()
+	return rsp
+}
+
 // Implements the POST call to create new transaction for updating an
 // existing subnet (subnets/{subnetId}/transaction).
 func (r *RestAPI) UpdateSubnetBegin(ctx context.Context, params dhcp.UpdateSubnetBeginParams) middleware.Responder {
@@ -1028,7 +1141,7 @@ func (r *RestAPI) UpdateSubnetBegin(ctx context.Context, params dhcp.UpdateSubne
 
 // Implements the POST call and commits an updated subnet (subnets/{subnetId}/transaction/{id}/submit).
 func (r *RestAPI) UpdateSubnetSubmit(ctx context.Context, params dhcp.UpdateSubnetSubmitParams) middleware.Responder {
-	if code, msg := r.commonCreateOrUpdateSubnetSubmit(ctx, params.ID, params.Subnet, r.ConfigManager.GetKeaModule().ApplySubnetUpdate); code != 0 {
+	if code, msg := r.commonCreateOrUpdateSubnetSubmit(ctx, params.ID, params.Subnet, params.Deadline, r.ConfigManager.GetKeaModule().ApplySubnetUpdate); code != 0 {
 		// Error case.
 		rsp := dhcp.NewUpdateSubnetSubmitDefault(code).WithPayload(&models.APIError{
 			Message: &msg,
@@ -1052,3 +1165,82 @@ func (r *RestAPI) UpdateSubnetDelete(ctx context.Context, params dhcp.UpdateSubn
 	rsp := dhcp.NewUpdateSubnetDeleteOK()
 	return rsp
 }
+
+// Implements the DELETE call for a subnet (subnets/{id}). It sends suitable
+// commands to the Kea servers owning the subnet and deletes the subnet from
+// the database.
+func (r *RestAPI) DeleteSubnet(ctx context.Context, params dhcp.DeleteSubnetParams) middleware.Responder {
+	// Create configuration context.
+	_, user := r.SessionManager.Logged(ctx)
+	cctx, err := r.ConfigManager.CreateContext(int64(user.ID))
+	if err != nil {
+		msg := "Problem with creating transaction context"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Begin subnet delete transaction. It retrieves current subnet information and
+	// locks daemons for updates.
+	cctx, err = r.ConfigManager.GetKeaModule().BeginSubnetDelete(cctx, params.ID)
+	defer r.ConfigManager.Done(cctx)
+	if err != nil {
+		var (
+			subnetNotFound *config.SubnetNotFoundError
+			lock           *config.LockError
+		)
+		switch {
+		case errors.As(err, &subnetNotFound):
+			// Failed to find subnet.
+			msg := fmt.Sprintf("Cannot find subnet with ID %d", params.ID)
+			log.Error(msg)
+			rsp := dhcp.NewDeleteSubnetDefault(http.StatusNotFound).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		case errors.As(err, &lock):
+			// Failed to lock daemons.
+			msg := fmt.Sprintf("Unable to delete the subnet with ID %d because it may be currently edited by another user", params.ID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewDeleteSubnetDefault(http.StatusLocked).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		default:
+			// Other error.
+			msg := fmt.Sprintf("Problem with initializing transaction for deleting the subnet with ID %d", params.ID)
+			log.WithError(err).Error(msg)
+			rsp := dhcp.NewDeleteSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+				Message: &msg,
+			})
+			return rsp
+		}
+	}
+	state, _ := config.GetTransactionState[kea.ConfigRecipe](cctx)
+	subnet := state.Updates[0].Recipe.SubnetBeforeUpdate
+
+	// Create Kea commands to delete the subnet.
+	cctx, err = r.ConfigManager.GetKeaModule().ApplySubnetDelete(cctx, subnet)
+	if err != nil {
+		msg := "Problem with preparing commands for deleting the subnet"
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Send the commands to Kea servers.
+	cctx, err = r.ConfigManager.Commit(cctx)
+	if err != nil {
+		msg := fmt.Sprintf("Problem with deleting the subnet: %s", err)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewDeleteSubnetDefault(http.StatusConflict).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	// Send OK to the client.
+	rsp := dhcp.NewDeleteSubnetOK()
+	return rsp
+}
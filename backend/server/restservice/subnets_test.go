@@ -2878,3 +2878,320 @@ func TestUpdateSubnetBeginCancel(t *testing.T) {
 	rsp = rapi.UpdateSubnetBegin(ctx2, params)
 	require.IsType(t, &dhcp.UpdateSubnetBeginOK{}, rsp)
 }
+
+// Test the calls for creating transaction and submitting a new subnet.
+func TestCreateSubnet4BeginSubmit(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	serverConfig := `{
+		"Dhcp4": {
+			"subnet4": [
+				{
+					"id": 1,
+					"subnet": "192.0.2.0/24"
+				}
+			],
+			"hooks-libraries": [
+				{
+					"library": "libdhcp_subnet_cmds"
+				}
+			]
+		}
+	}`
+
+	for i := 0; i < 2; i++ {
+		server, err := dbmodeltest.NewKeaDHCPv4Server(db)
+		require.NoError(t, err)
+		err = server.Configure(serverConfig)
+		require.NoError(t, err)
+
+		app, err := server.GetKea()
+		require.NoError(t, err)
+
+		err = kea.CommitAppIntoDB(db, app, &storktest.FakeEventCenter{}, nil, dbmodel.NewDHCPOptionDefinitionLookup())
+		require.NoError(t, err)
+	}
+
+	dbapps, err := dbmodel.GetAllApps(db, true)
+	require.NoError(t, err)
+	require.Len(t, dbapps, 2)
+
+	// Create fake agents receiving commands.
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	require.NotNil(t, fa)
+
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	require.NotNil(t, lookup)
+
+	// Create the config manager.
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	require.NotNil(t, cm)
+
+	// Create API.
+	rapi, err := NewRestAPI(dbSettings, db, fa, cm, lookup)
+	require.NoError(t, err)
+
+	// Create session manager.
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Create user session.
+	user := &dbmodel.SystemUser{
+		ID: 1234,
+	}
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	// Begin transaction.
+	rsp := rapi.CreateSubnetBegin(ctx, dhcp.CreateSubnetBeginParams{})
+	require.IsType(t, &dhcp.CreateSubnetBeginOK{}, rsp)
+	okRsp := rsp.(*dhcp.CreateSubnetBeginOK)
+	contents := okRsp.Payload
+
+	// Make sure the server returned transaction ID and daemons.
+	transactionID := contents.ID
+	require.NotZero(t, transactionID)
+	require.Len(t, contents.Daemons, 2)
+
+	// Submit transaction. The subnet ID is not specified, so it should
+	// be allocated by the server.
+	params := dhcp.CreateSubnetSubmitParams{
+		ID: transactionID,
+		Subnet: &models.Subnet{
+			Subnet: "192.0.3.0/24",
+			LocalSubnets: []*models.LocalSubnet{
+				{
+					DaemonID: dbapps[0].Daemons[0].ID,
+					Pools: []*models.Pool{
+						{
+							Pool: storkutil.Ptr("192.0.3.10-192.0.3.20"),
+						},
+					},
+				},
+				{
+					DaemonID: dbapps[1].Daemons[0].ID,
+					Pools: []*models.Pool{
+						{
+							Pool: storkutil.Ptr("192.0.3.10-192.0.3.20"),
+						},
+					},
+				},
+			},
+		},
+	}
+	rsp2 := rapi.CreateSubnetSubmit(ctx, params)
+	require.IsType(t, &dhcp.CreateSubnetSubmitOK{}, rsp2)
+
+	// It should result in sending commands to two Kea servers. Each server
+	// receives the subnet4-add and config-write commands.
+	require.Len(t, fa.RecordedCommands, 4)
+
+	for i, c := range fa.RecordedCommands {
+		switch {
+		case i < 2:
+			require.JSONEq(t,
+				`{
+				"command": "subnet4-add",
+				"service": [ "dhcp4" ],
+				"arguments": {
+					"subnet4": [
+						{
+							"id": 2,
+							"subnet": "192.0.3.0/24",
+							"pools": [
+								{
+									"pool": "192.0.3.10-192.0.3.20"
+								}
+							]
+						}
+					]
+				}
+			}`,
+				c.Marshal())
+		default:
+			require.JSONEq(t,
+				`{
+				"command": "config-write",
+				"service": [ "dhcp4" ]
+			}`,
+				c.Marshal())
+		}
+	}
+
+	// The new subnet should be in the database.
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "192.0.3.0/24")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+	require.Len(t, subnets[0].LocalSubnets, 2)
+	require.EqualValues(t, 2, subnets[0].LocalSubnets[0].LocalSubnetID)
+	require.EqualValues(t, 2, subnets[0].LocalSubnets[1].LocalSubnetID)
+
+	// The transaction has been completed, so submitting it again fails.
+	rsp2 = rapi.CreateSubnetSubmit(ctx, params)
+	require.IsType(t, &dhcp.CreateSubnetSubmitDefault{}, rsp2)
+	defaultRsp := rsp2.(*dhcp.CreateSubnetSubmitDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+}
+
+// Test that the transaction to add a subnet can be canceled, resulting
+// in the removal of this transaction from the config manager.
+func TestCreateSubnetBeginCancel(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	require.NotNil(t, fa)
+
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	require.NotNil(t, lookup)
+
+	// Create the config manager.
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	require.NotNil(t, cm)
+
+	// Create API.
+	rapi, err := NewRestAPI(dbSettings, db, fa, cm, lookup)
+	require.NoError(t, err)
+
+	// Create session manager.
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Create user session.
+	user := &dbmodel.SystemUser{
+		ID: 1234,
+	}
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	// Begin transaction.
+	rsp := rapi.CreateSubnetBegin(ctx, dhcp.CreateSubnetBeginParams{})
+	require.IsType(t, &dhcp.CreateSubnetBeginOK{}, rsp)
+	okRsp := rsp.(*dhcp.CreateSubnetBeginOK)
+	transactionID := okRsp.Payload.ID
+	require.NotZero(t, transactionID)
+
+	// Cancel the transaction.
+	rsp2 := rapi.CreateSubnetDelete(ctx, dhcp.CreateSubnetDeleteParams{
+		ID: transactionID,
+	})
+	require.IsType(t, &dhcp.CreateSubnetDeleteOK{}, rsp2)
+
+	cctx, _ := cm.RecoverContext(transactionID, int64(user.ID))
+	if cctx != nil {
+		cm.Done(cctx)
+	}
+	require.Nil(t, cctx)
+}
+
+// Test that the subnet is deleted from the Kea servers and the database.
+func TestDeleteSubnet(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	serverConfig := `{
+		"Dhcp6": {
+			"subnet6": [
+				{
+					"id": 3,
+					"subnet": "2001:db8:1::/64"
+				}
+			],
+			"hooks-libraries": [
+				{
+					"library": "libdhcp_subnet_cmds"
+				}
+			]
+		}
+	}`
+
+	server, err := dbmodeltest.NewKeaDHCPv6Server(db)
+	require.NoError(t, err)
+	err = server.Configure(serverConfig)
+	require.NoError(t, err)
+
+	app, err := server.GetKea()
+	require.NoError(t, err)
+
+	err = kea.CommitAppIntoDB(db, app, &storktest.FakeEventCenter{}, nil, dbmodel.NewDHCPOptionDefinitionLookup())
+	require.NoError(t, err)
+
+	subnets, err := dbmodel.GetSubnetsByPrefix(db, "2001:db8:1::/64")
+	require.NoError(t, err)
+	require.Len(t, subnets, 1)
+
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	require.NotNil(t, fa)
+
+	lookup := dbmodel.NewDHCPOptionDefinitionLookup()
+	require.NotNil(t, lookup)
+
+	// Create the config manager.
+	cm := apps.NewManager(&appstest.ManagerAccessorsWrapper{
+		DB:        db,
+		Agents:    fa,
+		DefLookup: lookup,
+	})
+	require.NotNil(t, cm)
+
+	// Create API.
+	rapi, err := NewRestAPI(dbSettings, db, fa, cm, lookup)
+	require.NoError(t, err)
+
+	// Create session manager.
+	ctx, err := rapi.SessionManager.Load(context.Background(), "")
+	require.NoError(t, err)
+
+	// Create user session.
+	user := &dbmodel.SystemUser{
+		ID: 1234,
+	}
+	err = rapi.SessionManager.LoginHandler(ctx, user)
+	require.NoError(t, err)
+
+	// Non-existing subnet.
+	rsp := rapi.DeleteSubnet(ctx, dhcp.DeleteSubnetParams{
+		ID: subnets[0].ID + 1,
+	})
+	require.IsType(t, &dhcp.DeleteSubnetDefault{}, rsp)
+	defaultRsp := rsp.(*dhcp.DeleteSubnetDefault)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*defaultRsp))
+	require.Empty(t, fa.RecordedCommands)
+
+	// Existing subnet.
+	rsp = rapi.DeleteSubnet(ctx, dhcp.DeleteSubnetParams{
+		ID: subnets[0].ID,
+	})
+	require.IsType(t, &dhcp.DeleteSubnetOK{}, rsp)
+
+	require.Len(t, fa.RecordedCommands, 2)
+	require.JSONEq(t,
+		`{
+			"command": "subnet6-del",
+			"service": [ "dhcp6" ],
+			"arguments": {
+				"id": 3
+			}
+		}`,
+		fa.RecordedCommands[0].Marshal())
+	require.JSONEq(t,
+		`{
+			"command": "config-write",
+			"service": [ "dhcp6" ]
+		}`,
+		fa.RecordedCommands[1].Marshal())
+
+	// The subnet should be gone from the database.
+	returned, err := dbmodel.GetSubnet(db, subnets[0].ID)
+	require.NoError(t, err)
+	require.Nil(t, returned)
+}
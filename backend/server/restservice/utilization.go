@@ -0,0 +1,160 @@
+package restservice
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-openapi/runtime/middleware"
+	"github.com/go-openapi/strfmt"
+	log "github.com/sirupsen/logrus"
+
+	dbmodel "isc.org/stork/server/database/model"
+	"isc.org/stork/server/gen/models"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storkutil "isc.org/stork/util"
+)
+
+// The default length of the time range for which the utilization samples
+// are returned when the beginning of the range is not specified.
+const defaultUtilizationTimeRange = 24 * time.Hour
+
+// Returns the time range and the tier of the utilization samples to return
+// using the specified query parameters. The range ends at the current time
+// and has 24 hours by default. The tier is selected according to the range
+// length, unless it is specified explicitly.
+func getUtilizationQuery(from, to *strfmt.DateTime, tier *string) (time.Time, time.Time, dbmodel.UtilizationTier) {
+	toTime := storkutil.UTCNow()
+	if to != nil {
+		toTime = time.Time(*to).UTC()
+	}
+	fromTime := toTime.Add(-defaultUtilizationTimeRange)
+	if from != nil {
+		fromTime = time.Time(*from).UTC()
+	}
+	utilizationTier := dbmodel.GetUtilizationTierForRange(fromTime, toTime)
+	if tier != nil {
+		utilizationTier = dbmodel.UtilizationTier(*tier)
+	}
+	return fromTime, toTime, utilizationTier
+}
+
+// Converts the utilization samples to the REST API format. The utilizations
+// are converted from permilles to percents.
+func utilizationSamplesToRestAPI(tier dbmodel.UtilizationTier, samples []*dbmodel.UtilizationSample) *models.UtilizationTimeSeries {
+	series := &models.UtilizationTimeSeries{
+		Tier:  string(tier),
+		Items: []*models.UtilizationSample{},
+	}
+	for _, sample := range samples {
+		series.Items = append(series.Items, &models.UtilizationSample{
+			SampledAt:       strfmt.DateTime(sample.SampledAt),
+			AddrUtilization: float64(sample.AddrUtilization) / 10,
+			PdUtilization:   float64(sample.PdUtilization) / 10,
+			SampleCount:     sample.SampleCount,
+		})
+	}
+	return series
+}
+
+// Get the utilization history of a subnet.
+func (r *RestAPI) GetSubnetUtilization(ctx context.Context, params dhcp.GetSubnetUtilizationParams) middleware.Responder {
+	dbSubnet, err := dbmodel.GetSubnet(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching subnet with ID %d from db", params.ID)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewGetSubnetUtilizationDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSubnet == nil {
+		msg := fmt.Sprintf("Cannot find subnet with ID %d", params.ID)
+		log.Error(msg)
+		rsp := dhcp.NewGetSubnetUtilizationDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	from, to, tier := getUtilizationQuery(params.From, params.To, params.Tier)
+	samples, err := dbmodel.GetSubnetUtilizationSamples(r.DB, params.ID, tier, from, to)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching utilization history of subnet with ID %d from db", params.ID)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewGetSubnetUtilizationDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewGetSubnetUtilizationOK().WithPayload(utilizationSamplesToRestAPI(tier, samples))
+	return rsp
+}
+
+// Get the utilization history of a shared network.
+func (r *RestAPI) GetSharedNetworkUtilization(ctx context.Context, params dhcp.GetSharedNetworkUtilizationParams) middleware.Responder {
+	dbSharedNetwork, err := dbmodel.GetSharedNetwork(r.DB, params.ID)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching shared network with ID %d from db", params.ID)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewGetSharedNetworkUtilizationDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	if dbSharedNetwork == nil {
+		msg := fmt.Sprintf("Cannot find shared network with ID %d", params.ID)
+		log.Error(msg)
+		rsp := dhcp.NewGetSharedNetworkUtilizationDefault(http.StatusNotFound).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	from, to, tier := getUtilizationQuery(params.From, params.To, params.Tier)
+	samples, err := dbmodel.GetSharedNetworkUtilizationSamples(r.DB, params.ID, tier, from, to)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching utilization history of shared network with ID %d from db", params.ID)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewGetSharedNetworkUtilizationDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewGetSharedNetworkUtilizationOK().WithPayload(utilizationSamplesToRestAPI(tier, samples))
+	return rsp
+}
+
+// Get the global utilization history for DHCPv4 or DHCPv6.
+func (r *RestAPI) GetGlobalUtilization(ctx context.Context, params dhcp.GetGlobalUtilizationParams) middleware.Responder {
+	dhcpVersion := int64(4)
+	if params.DhcpVersion != nil {
+		dhcpVersion = *params.DhcpVersion
+	}
+	if dhcpVersion != 4 && dhcpVersion != 6 {
+		msg := fmt.Sprintf("Invalid DHCP version %d", dhcpVersion)
+		log.Error(msg)
+		rsp := dhcp.NewGetGlobalUtilizationDefault(http.StatusBadRequest).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+	family := int8(dhcpVersion)
+
+	from, to, tier := getUtilizationQuery(params.From, params.To, params.Tier)
+	samples, err := dbmodel.GetGlobalUtilizationSamples(r.DB, family, tier, from, to)
+	if err != nil {
+		msg := fmt.Sprintf("Problem fetching global DHCPv%d utilization history from db", family)
+		log.WithError(err).Error(msg)
+		rsp := dhcp.NewGetGlobalUtilizationDefault(http.StatusInternalServerError).WithPayload(&models.APIError{
+			Message: &msg,
+		})
+		return rsp
+	}
+
+	rsp := dhcp.NewGetGlobalUtilizationOK().WithPayload(utilizationSamplesToRestAPI(tier, samples))
+	return rsp
+}
@@ -0,0 +1,109 @@
+package restservice
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-openapi/strfmt"
+	"github.com/stretchr/testify/require"
+	agentcommtest "isc.org/stork/server/agentcomm/test"
+	dbmodel "isc.org/stork/server/database/model"
+	dbtest "isc.org/stork/server/database/test"
+	dhcp "isc.org/stork/server/gen/restapi/operations/d_h_c_p"
+	storktest "isc.org/stork/server/test/dbmodel"
+	storkutil "isc.org/stork/util"
+)
+
+// Test that the time range and the tier are selected according to the
+// query parameters.
+func TestGetUtilizationQuery(t *testing.T) {
+	from, to, tier := getUtilizationQuery(nil, nil, nil)
+	require.WithinDuration(t, time.Now(), to, 5*time.Second)
+	require.Equal(t, 24*time.Hour, to.Sub(from))
+	require.Equal(t, dbmodel.UtilizationTierRaw, tier)
+
+	end := strfmt.DateTime(time.Date(2023, 5, 10, 0, 0, 0, 0, time.UTC))
+	begin := strfmt.DateTime(time.Date(2023, 4, 10, 0, 0, 0, 0, time.UTC))
+	from, to, tier = getUtilizationQuery(&begin, &end, nil)
+	require.WithinDuration(t, time.Time(begin), from, 0)
+	require.WithinDuration(t, time.Time(end), to, 0)
+	require.Equal(t, dbmodel.UtilizationTierHourly, tier)
+
+	_, _, tier = getUtilizationQuery(&begin, &end, storkutil.Ptr("daily"))
+	require.Equal(t, dbmodel.UtilizationTierDaily, tier)
+}
+
+// Test that the utilization history of a subnet and the global utilization
+// history are returned over the REST API.
+func TestGetUtilization(t *testing.T) {
+	db, dbSettings, teardown := dbtest.SetupDatabaseTestCase(t)
+	defer teardown()
+
+	settings := RestAPISettings{}
+	fa := agentcommtest.NewFakeAgents(nil, nil)
+	fec := &storktest.FakeEventCenter{}
+	fd := &storktest.FakeDispatcher{}
+	rapi, err := NewRestAPI(&settings, dbSettings, db, fa, fec, nil, fd, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	subnet := &dbmodel.Subnet{
+		Prefix: "192.0.2.0/24",
+	}
+	err = dbmodel.AddSubnet(db, subnet)
+	require.NoError(t, err)
+
+	sampledAt := storkutil.UTCNow().Add(-time.Hour)
+	err = dbmodel.AddUtilizationSamples(db, []*dbmodel.UtilizationSample{
+		{SubnetID: subnet.ID, Family: 4, SampledAt: sampledAt, AddrUtilization: 125},
+		{Family: 4, SampledAt: sampledAt, AddrUtilization: 250},
+		{Family: 6, SampledAt: sampledAt, AddrUtilization: 375, PdUtilization: 500},
+	})
+	require.NoError(t, err)
+
+	// Subnet utilization.
+	rsp := rapi.GetSubnetUtilization(ctx, dhcp.GetSubnetUtilizationParams{ID: subnet.ID})
+	require.IsType(t, &dhcp.GetSubnetUtilizationOK{}, rsp)
+	series := rsp.(*dhcp.GetSubnetUtilizationOK).Payload
+	require.Equal(t, "raw", series.Tier)
+	require.Len(t, series.Items, 1)
+	require.EqualValues(t, 12.5, series.Items[0].AddrUtilization)
+	require.EqualValues(t, 1, series.Items[0].SampleCount)
+
+	// Non-existing subnet.
+	rsp = rapi.GetSubnetUtilization(ctx, dhcp.GetSubnetUtilizationParams{ID: subnet.ID + 1})
+	require.IsType(t, &dhcp.GetSubnetUtilizationDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.GetSubnetUtilizationDefault)))
+
+	// Non-existing shared network.
+	rsp = rapi.GetSharedNetworkUtilization(ctx, dhcp.GetSharedNetworkUtilizationParams{ID: 1000})
+	require.IsType(t, &dhcp.GetSharedNetworkUtilizationDefault{}, rsp)
+	require.Equal(t, http.StatusNotFound, getStatusCode(*rsp.(*dhcp.GetSharedNetworkUtilizationDefault)))
+
+	// Global DHCPv6 utilization.
+	rsp = rapi.GetGlobalUtilization(ctx, dhcp.GetGlobalUtilizationParams{DhcpVersion: storkutil.Ptr(int64(6))})
+	require.IsType(t, &dhcp.GetGlobalUtilizationOK{}, rsp)
+	series = rsp.(*dhcp.GetGlobalUtilizationOK).Payload
+	require.Len(t, series.Items, 1)
+	require.EqualValues(t, 37.5, series.Items[0].AddrUtilization)
+	require.EqualValues(t, 50, series.Items[0].PdUtilization)
+
+	// There are no hourly samples.
+	rsp = rapi.GetGlobalUtilization(ctx, dhcp.GetGlobalUtilizationParams{Tier: storkutil.Ptr("hourly")})
+	require.IsType(t, &dhcp.GetGlobalUtilizationOK{}, rsp)
+	series = rsp.(*dhcp.GetGlobalUtilizationOK).Payload
+	require.Equal(t, "hourly", series.Tier)
+	require.Empty(t, series.Items)
+
+	// Invalid DHCP version.
+	rsp = rapi.GetGlobalUtilization(ctx, dhcp.GetGlobalUtilizationParams{DhcpVersion: storkutil.Ptr(int64(5))})
+	require.IsType(t, &dhcp.GetGlobalUtilizationDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.GetGlobalUtilizationDefault)))
+
+	// The DHCP version overflowing the family type is not accepted.
+	rsp = rapi.GetGlobalUtilization(ctx, dhcp.GetGlobalUtilizationParams{DhcpVersion: storkutil.Ptr(int64(260))})
+	require.IsType(t, &dhcp.GetGlobalUtilizationDefault{}, rsp)
+	require.Equal(t, http.StatusBadRequest, getStatusCode(*rsp.(*dhcp.GetGlobalUtilizationDefault)))
+}
@@ -49,6 +49,8 @@ type StorkServer struct {
 	// Configuration manager instance. Note that it inherits some fields
 	// maintained by the server.
 	ConfigManager config.Manager
+	// Executes the config changes scheduled with the config manager.
+	ConfigChangeScheduler *apps.ConfigChangeScheduler
 	// Provides lookup functionality for DHCP option definitions.
 	DHCPOptionDefinitionLookup keaconfig.DHCPOptionDefinitionLookup
 	shutdownOnce               sync.Once
@@ -127,6 +129,11 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 	// setup event center
 	ss.EventCenter = eventcenter.NewEventCenter(ss.DB)
 
+	// warn about the server certs which need to be replaced soon
+	if err = certs.CheckServerCertsExpiration(ss.DB, ss.EventCenter); err != nil {
+		log.WithError(err).Warn("Cannot check the expiration of the server certs")
+	}
+
 	// setup connected agents
 	ss.Agents = agentcomm.NewConnectedAgents(&ss.AgentsSettings, ss.EventCenter, caCertPEM, serverCertPEM, serverKeyPEM)
 	// TODO: if any operation below fails then this Shutdown here causes segfault.
@@ -171,7 +178,7 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 	}
 
 	// setup kea stats puller
-	ss.Pullers.KeaStatsPuller, err = kea.NewStatsPuller(ss.DB, ss.Agents)
+	ss.Pullers.KeaStatsPuller, err = kea.NewStatsPuller(ss.DB, ss.Agents, ss.EventCenter)
 	if err != nil {
 		return err
 	}
@@ -205,6 +212,7 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 	// on the option definitions it returns. Indexing should be done only once at
 	// server startup.
 	ss.ConfigManager = apps.NewManager(ss)
+	ss.ConfigChangeScheduler = apps.NewConfigChangeScheduler(ss.DB, ss.ConfigManager, ss.EventCenter)
 
 	// setup ReST API service
 	r, err := restservice.NewRestAPI(&ss.RestAPISettings, &ss.DBSettings,
@@ -212,6 +220,7 @@ func (ss *StorkServer) Bootstrap(reload bool) (err error) {
 		ss.Pullers, ss.ReviewDispatcher, ss.MetricsCollector, ss.ConfigManager,
 		ss.DHCPOptionDefinitionLookup, ss.HookManager)
 	if err != nil {
+		ss.ConfigChangeScheduler.Shutdown()
 		ss.Pullers.HAStatusPuller.Shutdown()
 		ss.Pullers.KeaHostsPuller.Shutdown()
 		ss.Pullers.KeaStatsPuller.Shutdown()
@@ -260,6 +269,7 @@ func (ss *StorkServer) Shutdown(reload bool) {
 			log.Println("Shutting down Stork Server")
 		}
 		ss.RestAPI.Shutdown()
+		ss.ConfigChangeScheduler.Shutdown()
 		ss.Pullers.HAStatusPuller.Shutdown()
 		ss.Pullers.KeaHostsPuller.Shutdown()
 		ss.Pullers.KeaStatsPuller.Shutdown()
@@ -1,6 +1,7 @@
 package storkutil
 
 import (
+	"math/rand"
 	"sync"
 	"time"
 
@@ -13,11 +14,14 @@ import (
 type PeriodicExecutor struct {
 	name            string
 	executorFunc    func() error
+	triggeredFunc   func() error
 	interval        int64
 	ticker          *time.Ticker
 	active          bool
 	pauseCount      uint16
 	done            chan bool
+	trigger         chan bool
+	triggerDeferred bool
 	wg              *sync.WaitGroup
 	mutex           *sync.Mutex
 	getIntervalFunc func() (int64, error)
@@ -26,11 +30,33 @@ type PeriodicExecutor struct {
 // Interval is used while the puller is inactive to check if it was re-enabled.
 const InactiveInterval int64 = 60
 
+// The fraction of the interval by which each cycle is randomly shortened or
+// extended. It prevents the executors with the same interval, e.g., the
+// pullers of many servers, from running in lockstep.
+const IntervalJitter = 0.1
+
+// Returns the duration of the next cycle, i.e., the interval randomized by
+// the jitter.
+func getJitteredInterval(interval int64) time.Duration {
+	duration := time.Duration(interval) * time.Second
+	jitter := (rand.Float64()*2 - 1) * IntervalJitter * float64(duration) //nolint:gosec
+	return duration + time.Duration(jitter)
+}
+
 // Creates an instance of a new periodic executor. The periodic executor offers a mechanism
 // to periodically trigger an action. This action is supplied as a function instance.
 // This function is executed within a goroutine periodically according to the timer
 // interval calculated by `getIntervalFunc`. It accepts previous interval and returns next value.
 func NewPeriodicExecutor(name string, executorFunc func() error, getIntervalFunc func() (int64, error)) (*PeriodicExecutor, error) {
+	return NewPeriodicExecutorWithTrigger(name, executorFunc, executorFunc, getIntervalFunc)
+}
+
+// Creates an instance of a new periodic executor running a distinct action
+// when the execution is requested on demand with Trigger(). The executorFunc
+// is executed periodically according to the timer and the triggeredFunc is
+// executed on demand. Both actions are executed in the same goroutine, so
+// they never run concurrently.
+func NewPeriodicExecutorWithTrigger(name string, executorFunc, triggeredFunc func() error, getIntervalFunc func() (int64, error)) (*PeriodicExecutor, error) {
 	log.Printf("Starting %s", name)
 
 	interval, err := getIntervalFunc()
@@ -51,10 +77,12 @@ func NewPeriodicExecutor(name string, executorFunc func() error, getIntervalFunc
 	periodicExecutor := &PeriodicExecutor{
 		name:            name,
 		executorFunc:    executorFunc,
-		ticker:          time.NewTicker(time.Duration(interval) * time.Second),
+		triggeredFunc:   triggeredFunc,
+		ticker:          time.NewTicker(getJitteredInterval(interval)),
 		active:          active,
 		pauseCount:      0,
 		done:            make(chan bool),
+		trigger:         make(chan bool, 1),
 		wg:              &sync.WaitGroup{},
 		mutex:           &sync.Mutex{},
 		interval:        interval,
@@ -124,7 +152,12 @@ func (executor *PeriodicExecutor) unpause(lock bool, intervals ...int64) {
 			executor.interval = intervals[0]
 		}
 		// Reschedule the timer.
-		executor.ticker.Reset(time.Duration(executor.interval) * time.Second)
+		executor.ticker.Reset(getJitteredInterval(executor.interval))
+		// Run the execution requested while the executor was paused.
+		if executor.triggerDeferred {
+			executor.triggerDeferred = false
+			executor.Trigger()
+		}
 	}
 }
 
@@ -152,6 +185,70 @@ func (executor *PeriodicExecutor) Reset(interval int64) {
 	executor.unpause(false, interval)
 }
 
+// Checks if the executor is enabled, i.e., its interval is positive.
+func (executor *PeriodicExecutor) IsActive() bool {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	return executor.active
+}
+
+// Requests an immediate execution of the executor action, regardless of
+// the timer. The action is executed in the executor's goroutine, so it never
+// runs concurrently with the periodic execution. The requests made while the
+// previous request is pending are merged. If the executor is paused, the
+// action is deferred until it is unpaused. The requests are ignored while
+// the executor is disabled.
+func (executor *PeriodicExecutor) Trigger() {
+	select {
+	case executor.trigger <- true:
+	default:
+	}
+}
+
+// Runs the executor action requested by Trigger(). If the executor is
+// paused, e.g., by an operation which must not run concurrently with the
+// executor action, the execution is deferred until the executor is
+// unpaused. Checking and pausing the executor are done under the same
+// lock, so the other operation cannot pause it in between.
+func (executor *PeriodicExecutor) executeTriggered() {
+	executor.mutex.Lock()
+	if executor.pauseCount > 0 {
+		executor.triggerDeferred = true
+		executor.mutex.Unlock()
+		return
+	}
+	executor.ticker.Stop()
+	executor.pauseCount++
+	executor.mutex.Unlock()
+
+	executor.runPaused(executor.triggeredFunc)
+}
+
+// Enables or disables the executor.
+func (executor *PeriodicExecutor) setActive(active bool) {
+	executor.mutex.Lock()
+	defer executor.mutex.Unlock()
+	executor.active = active
+}
+
+// Runs the executor action with the timer stopped.
+func (executor *PeriodicExecutor) execute() {
+	// Temporarily stop the executor while running the external action.
+	// It will be resumed when the action ends.
+	executor.Pause()
+	executor.runPaused(executor.executorFunc)
+}
+
+// Runs the specified executor action and unpauses the executor paused by
+// the caller.
+func (executor *PeriodicExecutor) runPaused(action func() error) {
+	err := action()
+	executor.Unpause()
+	if err != nil {
+		log.Errorf("Errors were encountered while pulling data from apps: %+v", err)
+	}
+}
+
 // This function controls the timing of the function execution and captures the
 // termination signal.
 func (executor *PeriodicExecutor) executorLoop() {
@@ -160,15 +257,13 @@ func (executor *PeriodicExecutor) executorLoop() {
 		select {
 		// every N seconds execute user defined function
 		case <-executor.ticker.C:
-			if executor.active {
-				// Temporarily stop the executor while running the external action.
-				// It will be resumed when the action ends.
-				executor.Pause()
-				err := executor.executorFunc()
-				executor.Unpause()
-				if err != nil {
-					log.Errorf("Errors were encountered while pulling data from apps: %+v", err)
-				}
+			if executor.IsActive() && !executor.Paused() {
+				executor.execute()
+			}
+		// execute user defined function on demand
+		case <-executor.trigger:
+			if executor.IsActive() {
+				executor.executeTriggered()
 			}
 		// wait for done signal from shutdown function
 		case <-executor.done:
@@ -186,18 +281,19 @@ func (executor *PeriodicExecutor) executorLoop() {
 
 		executor.mutex.Lock()
 		executorInterval := executor.interval
+		active := executor.active
 		executor.mutex.Unlock()
 
-		if interval <= 0 && executor.active {
+		if interval <= 0 && active {
 			// if executor should be disabled but it is active then
 			if executorInterval != InactiveInterval {
 				executor.Reset(InactiveInterval)
 			}
-			executor.active = false
+			executor.setActive(false)
 		} else if interval > 0 && interval != executorInterval {
 			// if executor interval is changed and is not 0 (disabled)
 			executor.Reset(interval)
-			executor.active = true
+			executor.setActive(true)
 		}
 	}
 }
@@ -171,3 +171,107 @@ func TestGetName(t *testing.T) {
 	// Assert
 	require.EqualValues(t, "foobar", name)
 }
+
+// Test that the executor action is executed on demand without waiting for
+// the timer.
+func TestTrigger(t *testing.T) {
+	// Arrange
+	var calls int64
+	executor, _ := NewPeriodicExecutor("", func() error {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	}, func() (int64, error) { return 3600, nil })
+	defer executor.Shutdown()
+
+	// Act
+	executor.Trigger()
+
+	// Assert
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&calls) == 1
+	}, 5*time.Second, 100*time.Millisecond,
+		"test executor did not execute the triggered action")
+}
+
+// Test that the executor created with the distinct triggered action runs
+// this action on demand and the regular action according to the timer.
+func TestTriggerDistinctAction(t *testing.T) {
+	// Arrange
+	var regularCalls, triggeredCalls int64
+	executor, _ := NewPeriodicExecutorWithTrigger("", func() error {
+		atomic.AddInt64(&regularCalls, 1)
+		return nil
+	}, func() error {
+		atomic.AddInt64(&triggeredCalls, 1)
+		return nil
+	}, func() (int64, error) { return 1, nil })
+	defer executor.Shutdown()
+
+	// Act
+	executor.Trigger()
+
+	// Assert
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&triggeredCalls) == 1 && atomic.LoadInt64(&regularCalls) > 0
+	}, 5*time.Second, 100*time.Millisecond,
+		"test executor did not execute both actions")
+	require.EqualValues(t, 1, atomic.LoadInt64(&triggeredCalls))
+}
+
+// Test that the action triggered while the executor is paused is deferred
+// until the executor is unpaused.
+func TestTriggerWhilePaused(t *testing.T) {
+	// Arrange
+	var calls int64
+	executor, _ := NewPeriodicExecutor("", func() error {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	}, func() (int64, error) { return 3600, nil })
+	defer executor.Shutdown()
+	executor.Pause()
+
+	// Act
+	executor.Trigger()
+
+	// Assert
+	require.Never(t, func() bool {
+		return atomic.LoadInt64(&calls) > 0
+	}, time.Second, 100*time.Millisecond,
+		"test executor executed the triggered action while paused")
+
+	executor.Unpause()
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&calls) == 1
+	}, 5*time.Second, 100*time.Millisecond,
+		"test executor did not execute the deferred action")
+}
+
+// Test that the action is not triggered while the executor is disabled.
+func TestTriggerInactive(t *testing.T) {
+	// Arrange
+	var calls int64
+	executor, _ := NewPeriodicExecutor("", func() error {
+		atomic.AddInt64(&calls, 1)
+		return nil
+	}, func() (int64, error) { return 0, nil })
+	defer executor.Shutdown()
+
+	// Act
+	executor.Trigger()
+
+	// Assert
+	require.False(t, executor.IsActive())
+	require.Never(t, func() bool {
+		return atomic.LoadInt64(&calls) > 0
+	}, time.Second, 100*time.Millisecond,
+		"test executor executed the action while disabled")
+}
+
+// Test that the cycle duration is randomized within the jitter bounds.
+func TestGetJitteredInterval(t *testing.T) {
+	for i := 0; i < 100; i++ {
+		duration := getJitteredInterval(10)
+		require.GreaterOrEqual(t, duration, 9*time.Second)
+		require.LessOrEqual(t, duration, 11*time.Second)
+	}
+}
@@ -239,6 +239,21 @@ describe('DashboardComponent', () => {
         expect(component.showHAFailureTime(daemon)).not.toBe('')
     })
 
+    it('should describe the RPS intervals', () => {
+        expect(component.rpsIntervalLabel(15)).toBe('15min')
+        expect(component.rpsIntervalLabel(1440)).toBe('24h')
+        expect(component.rpsIntervalLabel(60, true)).toBe('1 hour')
+        expect(component.rpsIntervalLabel(90, true)).toBe('90 minutes')
+
+        component.overview.rpsInterval1 = 5
+        const daemon = { name: 'dhcp4', nakRate1: 0.5, declineRate1: 0, dropRate1: 1 }
+        const tooltip = component.daemonRpsTooltip(daemon, 1)
+        expect(tooltip).toContain('over the last 5 minutes')
+        expect(tooltip).toContain('NAKs: 0.50/s')
+        expect(tooltip).toContain('drops: 1.00/s')
+        expect(component.daemonRpsTooltip({ name: 'dhcp6' }, 2)).not.toContain('NAKs')
+    })
+
     it('should parse integer statistics', async () => {
         await component.refreshDhcpOverview()
         expect(component.overview.subnets4.items[0].stats['total-addresses']).toBe(BigInt(65530))
@@ -294,8 +294,36 @@ export class DashboardComponent implements OnInit, OnDestroy {
      */
     daemonRpsTooltip(daemon, interval) {
         const typeStr = daemon.name === 'dhcp4' ? 'ACKs' : 'REPLYs'
-        const intervalStr = interval === 1 ? '15 minutes' : '24 hours'
-        return 'Number of ' + typeStr + ' sent by the daemon per second over the last ' + intervalStr
+        const minutes = interval === 1 ? this.overview.rpsInterval1 || 15 : this.overview.rpsInterval2 || 1440
+        const suffix = interval === 1 ? '1' : '2'
+        const rate = (name) => (daemon[name + suffix] ?? 0).toFixed(2)
+        let tooltip =
+            'Number of ' +
+            typeStr +
+            ' sent by the daemon per second over the last ' +
+            this.rpsIntervalLabel(minutes, true) +
+            '. '
+        if (daemon.name === 'dhcp4') {
+            tooltip += 'NAKs: ' + rate('nakRate') + '/s, '
+        }
+        tooltip += 'declines: ' + rate('declineRate') + '/s, drops: ' + rate('dropRate') + '/s'
+        return tooltip
+    }
+
+    /**
+     * Returns a human readable length of an RPS interval
+     *
+     * @param minutes length of the interval in minutes.
+     * @param long indicates whether the full unit names should be used.
+     *
+     * @returns Interval length as text, e.g. 15min or 24h.
+     */
+    rpsIntervalLabel(minutes: number, long = false): string {
+        if (minutes % 60 === 0) {
+            const hours = minutes / 60
+            return long ? hours + (hours === 1 ? ' hour' : ' hours') : hours + 'h'
+        }
+        return long ? minutes + (minutes === 1 ? ' minute' : ' minutes') : minutes + 'min'
     }
 
     /**
@@ -360,6 +388,26 @@ export class DashboardComponent implements OnInit, OnDestroy {
         return daemon.haState
     }
 
+    /**
+     * Returns tooltip listing the HA states of the daemon in all its
+     * HA relationships.
+     *
+     * A daemon being a hub in the hub-and-spoke configuration has several
+     * relationships. The dashboard shows the state in the first of them.
+     *
+     * @param daemon daemon which relationships should be listed.
+     * @returns Tooltip as text or empty string if the daemon has at most
+     *          one relationship.
+     */
+    haRelationshipsTooltip(daemon: DhcpDaemon) {
+        if (!daemon.haOverview || daemon.haOverview.length < 2) {
+            return ''
+        }
+        return daemon.haOverview
+            .map((overview) => `${overview.relationship || 'unknown'}: ${overview.haState || 'fetching...'}`)
+            .join('\n')
+    }
+
     /**
      * Returns printable time when failover was last triggered for a
      * given daemon.
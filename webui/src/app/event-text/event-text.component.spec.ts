@@ -21,4 +21,13 @@ describe('EventTextComponent', () => {
     it('should create', () => {
         expect(component).toBeTruthy()
     })
+
+    it('should parse entities with hyphens in their names', () => {
+        component.text = 'Addresses in <shared-network id="3" name="frog"> run out'
+        expect(component.textParts).toEqual([
+            ['text', 'Addresses in '],
+            ['shared-network', { id: '3', name: 'frog' }],
+            ['text', ' run out'],
+        ])
+    })
 })
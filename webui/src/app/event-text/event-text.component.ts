@@ -29,7 +29,7 @@ export class EventTextComponent {
      */
     parseText() {
         // match e.g. <daemon id="123" name="dhcp4">
-        const reEntity = /<(\w+) +((?:\w+="[^"]*" *){1,})>/g
+        const reEntity = /<([\w-]+) +((?:\w+="[^"]*" *){1,})>/g
         // match e.g. name="dhcp4"
         const reAttrs = /(\w+)="([^"]+)"/g
         const matches = this._text.matchAll(reEntity)
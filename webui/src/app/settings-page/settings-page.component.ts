@@ -24,6 +24,13 @@ export class SettingsPageComponent implements OnInit {
             kea_stats_puller_interval: ['', [Validators.required, Validators.min(0)]],
             kea_status_puller_interval: ['', [Validators.required, Validators.min(0)]],
             prometheus_url: [''],
+            utilization_raw_retention: ['', [Validators.required, Validators.min(0)]],
+            utilization_hourly_retention: ['', [Validators.required, Validators.min(0)]],
+            utilization_daily_retention: ['', [Validators.required, Validators.min(0)]],
+            ha_status_history_retention: ['', [Validators.required, Validators.min(0)]],
+            exhaustion_forecast_horizon: ['', [Validators.required, Validators.min(0)]],
+            rps_interval1: ['', [Validators.required, Validators.min(1)]],
+            rps_interval2: ['', [Validators.required, Validators.min(1)]],
         })
     }
 
@@ -35,6 +42,13 @@ export class SettingsPageComponent implements OnInit {
                     'kea_hosts_puller_interval',
                     'kea_stats_puller_interval',
                     'kea_status_puller_interval',
+                    'utilization_raw_retention',
+                    'utilization_hourly_retention',
+                    'utilization_daily_retention',
+                    'ha_status_history_retention',
+                    'exhaustion_forecast_horizon',
+                    'rps_interval1',
+                    'rps_interval2',
                 ]
                 const stringSettings = ['grafana_url', 'prometheus_url']
 